@@ -0,0 +1,45 @@
+// Command audit-verify checks the audit log's hash chain and prints the
+// result as JSON. It exits with status 1 when the chain is broken, so it can
+// run from cron or a CI job.
+//
+//	go run ./cmd/audit-verify
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+
+	"github.com/ssoydabas/auth-service/internal/repository"
+	"github.com/ssoydabas/auth-service/internal/service"
+	"github.com/ssoydabas/auth-service/pkg/config"
+	"github.com/ssoydabas/auth-service/pkg/postgres"
+)
+
+func main() {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	db, err := postgres.ConnectPQ(*cfg)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	result, err := service.NewAuditService(repository.NewAuditRepository(db)).VerifyAuditChain(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to verify audit log: %v", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		log.Fatalf("Failed to write result: %v", err)
+	}
+
+	if !result.Valid {
+		os.Exit(1)
+	}
+}
@@ -53,9 +53,17 @@ import (
 
 	"log"
 
+	"github.com/ssoydabas/auth-service/internal/federation"
+	"github.com/ssoydabas/auth-service/internal/notification"
+	"github.com/ssoydabas/auth-service/internal/oauth"
+	"github.com/ssoydabas/auth-service/internal/outbox"
+	"github.com/ssoydabas/auth-service/internal/purge"
 	"github.com/ssoydabas/auth-service/internal/repository"
 	"github.com/ssoydabas/auth-service/internal/service"
+	"github.com/ssoydabas/auth-service/internal/siem"
+	"github.com/ssoydabas/auth-service/internal/storage"
 	"github.com/ssoydabas/auth-service/internal/transport/http/handler"
+	"github.com/ssoydabas/auth-service/internal/transport/http/pages"
 	"github.com/ssoydabas/auth-service/pkg/config"
 	"github.com/ssoydabas/auth-service/pkg/postgres"
 
@@ -78,13 +86,119 @@ func main() {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 
+	notifier, err := notification.NewNotifier(*cfg)
+	if err != nil {
+		log.Fatalf("Failed to create notifier: %v", err)
+	}
+
+	templates, err := notification.NewRenderer(cfg.NotificationTemplateDir, cfg.NotificationBrandName)
+	if err != nil {
+		log.Fatalf("Failed to load notification templates: %v", err)
+	}
+
+	blobStore, err := storage.NewBlobStore(*cfg)
+	if err != nil {
+		log.Fatalf("Failed to create blob store: %v", err)
+	}
+
+	pageRenderer, err := pages.NewRenderer(cfg.NotificationBrandName)
+	if err != nil {
+		log.Fatalf("Failed to load pages: %v", err)
+	}
+
+	signingKey, err := loadSigningKey(cfg.OIDCSigningKeyFile)
+	if err != nil {
+		log.Fatalf("Failed to load OIDC signing key: %v", err)
+	}
+
+	if _, err := oauth.NewExchangePolicy(cfg.OAuthTokenExchangePolicy); err != nil {
+		log.Fatalf("Invalid OAUTH_TOKEN_EXCHANGE_POLICY: %v", err)
+	}
+	for _, scope := range cfg.OAuthRegistrationScopes {
+		if !oauth.ValidScopeToken(scope) {
+			log.Fatalf("Invalid OAUTH_REGISTRATION_SCOPES: %q is not a scope", scope)
+		}
+	}
+
+	connectors, err := federation.LoadConnectors(cfg.FederationProvidersFile)
+	if err != nil {
+		log.Fatalf("Invalid FEDERATION_PROVIDERS_FILE: %v", err)
+	}
+
+	auditSinks, err := siem.NewSinks(*cfg)
+	if err != nil {
+		log.Fatalf("Failed to create audit sinks: %v", err)
+	}
+
 	e := echo.New()
 	e.Use(middleware.ErrorHandler)
+	e.Use(middleware.Locale)
+	e.Use(middleware.ClientInfo)
 	e.GET("/swagger/*", echoSwagger.WrapHandler)
 	apiVersion := "/v1"
 	apiPrefix := e.Group("/api" + apiVersion)
 
-	handler.NewAccountHandler(service.NewAccountService(repository.NewAccountRepository(db))).AddRoutes(apiPrefix)
+	accountRepository := repository.NewAccountRepository(db)
+	roleRepository := repository.NewRoleRepository(db)
+	auditRepository := repository.NewAuditRepository(db)
+	oauthRepository := repository.NewOAuthRepository(db)
+	accountService := service.NewAccountService(accountRepository, roleRepository, auditRepository, templates, *cfg)
+	roleService := service.NewRoleService(roleRepository, accountRepository, *cfg)
+	if granted, err := roleService.BootstrapAdmin(context.Background()); err != nil {
+		log.Printf("Initial admin not granted: %v", err)
+	} else if granted {
+		log.Printf("Granted the admin role to %s", cfg.InitialAdminEmail)
+	}
+	photoService := service.NewPhotoService(accountRepository, blobStore, *cfg)
+	auditService := service.NewAuditService(auditRepository)
+	oauthService := service.NewOAuthService(oauthRepository, accountRepository, auditRepository, accountService, signingKey, *cfg)
+	federationService := service.NewFederationService(repository.NewFederationRepository(db), oauthRepository, accountRepository, roleRepository, auditRepository, connectors, *cfg)
+	exportService := service.NewDataExportService(repository.NewDataExportRepository(db), accountRepository, auditRepository, oauthRepository, blobStore, templates, *cfg)
+
+	handler.NewAccountHandler(accountService, roleService).AddRoutes(apiPrefix)
+	handler.NewAdminHandler(accountService, roleService).AddRoutes(apiPrefix)
+	handler.NewNotificationHandler(accountService, roleService, templates).AddRoutes(apiPrefix)
+	handler.NewPhotoHandler(accountService, roleService, photoService, cfg.PhotoMaxBytes).AddRoutes(apiPrefix)
+	handler.NewExportHandler(accountService, roleService, exportService).AddRoutes(apiPrefix)
+	handler.NewAuditHandler(accountService, roleService, auditService).AddRoutes(apiPrefix)
+	handler.NewOAuthClientHandler(accountService, roleService, oauthService).AddRoutes(apiPrefix)
+	handler.NewOAuthGrantHandler(accountService, roleService, oauthService).AddRoutes(apiPrefix)
+	handler.NewOAuthHandler(oauthService, federationService, pageRenderer, cfg.PublicBaseURL).AddRoutes(e.Group("/oauth"))
+	handler.NewDiscoveryHandler(oauthService).AddRoutes(e.Group("/.well-known"))
+
+	dispatcher := outbox.NewDispatcher(repository.NewOutboxRepository(db), outbox.Config{
+		PollInterval:   cfg.OutboxPollInterval,
+		BatchSize:      cfg.OutboxBatchSize,
+		MaxAttempts:    cfg.OutboxMaxAttempts,
+		RetryBaseDelay: cfg.OutboxRetryBaseDelay,
+		RetryMaxDelay:  cfg.OutboxRetryMaxDelay,
+		ClaimLease:     cfg.OutboxClaimLease,
+	})
+	dispatcher.Register(outbox.TopicNotification, outbox.NotificationHandler(notifier))
+	dispatcher.Register(outbox.TopicDataExport, outbox.DataExportHandler(exportService.GenerateExport))
+	dispatcher.Register(outbox.TopicBackchannelLogout, outbox.BackchannelLogoutHandler(signingKey, cfg.PublicBaseURL))
+
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	go dispatcher.Run(workerCtx)
+
+	purger := purge.NewPurger(accountRepository, photoService, exportService, purge.Config{
+		Interval:    cfg.AccountPurgeInterval,
+		GracePeriod: cfg.AccountDeletionGracePeriod,
+		BatchSize:   cfg.AccountPurgeBatchSize,
+	})
+	go purger.Run(workerCtx)
+
+	if len(auditSinks) > 0 {
+		forwarder := siem.NewForwarder(auditRepository, auditSinks, siem.Config{
+			PollInterval:   cfg.AuditSinkPollInterval,
+			BatchSize:      cfg.AuditSinkBatchSize,
+			ClaimLease:     cfg.AuditSinkClaimLease,
+			RetryBaseDelay: cfg.AuditSinkRetryBaseDelay,
+			RetryMaxDelay:  cfg.AuditSinkRetryMaxDelay,
+		})
+		go forwarder.Run(workerCtx)
+	}
 
 	// Graceful shutdown
 	shutdownChan := make(chan os.Signal, 1)
@@ -102,6 +216,7 @@ func main() {
 		log.Fatalf("Failed to start server: %v", err)
 	case <-shutdownChan:
 		log.Println("Received shutdown signal, shutting down...")
+		stopWorkers()
 
 		ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
 		defer cancel()
@@ -113,3 +228,15 @@ func main() {
 		log.Println("Server shutdown successfully")
 	}
 }
+
+// loadSigningKey reads the OIDC signing key, or generates one when no file
+// is configured. A generated key changes on every restart, invalidating the
+// ID tokens clients hold.
+func loadSigningKey(path string) (*oauth.SigningKey, error) {
+	if path != "" {
+		return oauth.LoadSigningKey(path)
+	}
+
+	log.Println("OIDC_SIGNING_KEY_FILE is not set, generating a temporary signing key")
+	return oauth.GenerateSigningKey()
+}
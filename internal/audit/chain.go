@@ -0,0 +1,155 @@
+// Package audit implements the hash chain that makes the audit log tamper
+// evident.
+package audit
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ssoydabas/auth-service/models"
+)
+
+// ChainLockKey is the Postgres advisory lock taken while an event is
+// appended to the chain, so concurrent writers link to the right
+// predecessor.
+const ChainLockKey int64 = 0x617564697463 // "auditc"
+
+// chainEntry is the canonical form of an event that Hash covers. Its field
+// order is part of the chain format and must not change.
+type chainEntry struct {
+	Sequence  uint64          `json:"sequence"`
+	PrevHash  string          `json:"prev_hash"`
+	CreatedAt string          `json:"created_at"`
+	Action    string          `json:"action"`
+	ActorID   *uint           `json:"actor_id"`
+	TargetID  *uint           `json:"target_id"`
+	IP        string          `json:"ip"`
+	UserAgent string          `json:"user_agent"`
+	Outcome   string          `json:"outcome"`
+	Metadata  json.RawMessage `json:"metadata"`
+}
+
+// Seal links event to previous, the last event of the chain or nil when the
+// chain is empty, and sets its Sequence, PrevHash and Hash. CreatedAt is
+// truncated to the microsecond precision Postgres stores, so the hash still
+// matches once the event is read back.
+func Seal(event *models.AuditEvent, previous *models.AuditEvent) {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	event.CreatedAt = event.CreatedAt.UTC().Truncate(time.Microsecond)
+
+	if event.Outcome == "" {
+		event.Outcome = models.AuditOutcomeSuccess
+	}
+
+	event.Sequence = 1
+	event.PrevHash = ""
+	if previous != nil {
+		event.Sequence = previous.Sequence + 1
+		event.PrevHash = previous.Hash
+	}
+
+	event.Hash = Hash(*event)
+}
+
+// Hash returns the hex encoded SHA-256 of the canonical form of event.
+func Hash(event models.AuditEvent) string {
+	encoded, _ := json.Marshal(chainEntry{
+		Sequence:  event.Sequence,
+		PrevHash:  event.PrevHash,
+		CreatedAt: event.CreatedAt.UTC().Format(time.RFC3339Nano),
+		Action:    event.Action,
+		ActorID:   event.ActorID,
+		TargetID:  event.TargetID,
+		IP:        event.IP,
+		UserAgent: event.UserAgent,
+		Outcome:   event.Outcome,
+		Metadata:  canonicalMetadata(event.Metadata),
+	})
+
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])
+}
+
+// canonicalMetadata re-encodes metadata with sorted keys and no whitespace.
+// Postgres stores jsonb in its own normalised form, so the text read back
+// differs from the text written and cannot be hashed as is.
+func canonicalMetadata(metadata string) json.RawMessage {
+	if metadata == "" {
+		return json.RawMessage("{}")
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader([]byte(metadata)))
+	decoder.UseNumber()
+
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		// Not valid JSON, so Postgres would not have stored it; hash the
+		// raw text as a string so the event still gets a stable hash.
+		encoded, _ := json.Marshal(metadata)
+		return encoded
+	}
+
+	encoded, _ := json.Marshal(value)
+	return encoded
+}
+
+// ChainError describes the first event at which the chain does not verify.
+type ChainError struct {
+	EventID  uint
+	Sequence uint64
+	Reason   string
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("audit chain broken at sequence %d (event %d): %s", e.Sequence, e.EventID, e.Reason)
+}
+
+// Verifier checks events in sequence order, starting from the first event
+// of the chain.
+type Verifier struct {
+	previous *models.AuditEvent
+	checked  int64
+}
+
+// Verify checks that event follows the previously verified event and that
+// its hash matches its contents.
+func (v *Verifier) Verify(event models.AuditEvent) error {
+	expectedSequence, expectedPrevHash := uint64(1), ""
+	if v.previous != nil {
+		expectedSequence = v.previous.Sequence + 1
+		expectedPrevHash = v.previous.Hash
+	}
+
+	switch {
+	case event.Sequence != expectedSequence:
+		return &ChainError{EventID: event.ID, Sequence: event.Sequence, Reason: fmt.Sprintf("expected sequence %d", expectedSequence)}
+	case event.PrevHash != expectedPrevHash:
+		return &ChainError{EventID: event.ID, Sequence: event.Sequence, Reason: "previous hash does not match"}
+	case event.Hash != Hash(event):
+		return &ChainError{EventID: event.ID, Sequence: event.Sequence, Reason: "hash does not match event contents"}
+	}
+
+	v.previous = &event
+	v.checked++
+	return nil
+}
+
+// Checked returns the number of events verified so far.
+func (v *Verifier) Checked() int64 {
+	return v.checked
+}
+
+// LastHash returns the hash of the last verified event, which can be kept
+// elsewhere to detect the chain being truncated or rewritten from the start.
+func (v *Verifier) LastHash() string {
+	if v.previous == nil {
+		return ""
+	}
+	return v.previous.Hash
+}
@@ -0,0 +1,139 @@
+package audit
+
+import (
+	stderrors "errors"
+	"testing"
+	"time"
+
+	"github.com/ssoydabas/auth-service/internal/audit"
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/stretchr/testify/suite"
+)
+
+type ChainTestSuite struct {
+	suite.Suite
+}
+
+// buildChain seals n events in order, as the repository does.
+func (suite *ChainTestSuite) buildChain(n int) []models.AuditEvent {
+	events := make([]models.AuditEvent, n)
+	var previous *models.AuditEvent
+	for i := range events {
+		actorID := uint(i + 1)
+		events[i] = models.AuditEvent{
+			ID:        uint(i + 1),
+			CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 123456789, time.UTC).Add(time.Duration(i) * time.Second),
+			Action:    models.AuditActionAccountLogin,
+			ActorID:   &actorID,
+			TargetID:  &actorID,
+			IP:        "203.0.113.7",
+			UserAgent: "curl/8.0",
+			Metadata:  `{"b":1,"a":"x"}`,
+		}
+		audit.Seal(&events[i], previous)
+		previous = &events[i]
+	}
+	return events
+}
+
+func (suite *ChainTestSuite) verify(events []models.AuditEvent) error {
+	var verifier audit.Verifier
+	for _, event := range events {
+		if err := verifier.Verify(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (suite *ChainTestSuite) TestSealLinksEvents() {
+	events := suite.buildChain(3)
+
+	suite.Equal(uint64(1), events[0].Sequence)
+	suite.Empty(events[0].PrevHash)
+	suite.Equal(models.AuditOutcomeSuccess, events[0].Outcome)
+	suite.Equal(0, events[0].CreatedAt.Nanosecond()%1000, "created_at is truncated to microseconds")
+	for i := 1; i < len(events); i++ {
+		suite.Equal(uint64(i+1), events[i].Sequence)
+		suite.Equal(events[i-1].Hash, events[i].PrevHash)
+		suite.Len(events[i].Hash, 64)
+	}
+
+	var verifier audit.Verifier
+	for _, event := range events {
+		suite.Require().NoError(verifier.Verify(event))
+	}
+	suite.Equal(int64(3), verifier.Checked())
+	suite.Equal(events[2].Hash, verifier.LastHash())
+}
+
+func (suite *ChainTestSuite) TestHashIgnoresJSONBNormalisation() {
+	event := suite.buildChain(1)[0]
+
+	// Postgres returns jsonb with its own key order and spacing.
+	event.Metadata = `{"a": "x", "b": 1}`
+	suite.Equal(event.Hash, audit.Hash(event))
+}
+
+func (suite *ChainTestSuite) TestTamperingIsDetected() {
+	tests := []struct {
+		name     string
+		tamper   func([]models.AuditEvent) []models.AuditEvent
+		sequence uint64
+	}{
+		{
+			name: "edited metadata",
+			tamper: func(events []models.AuditEvent) []models.AuditEvent {
+				events[1].Metadata = `{"b":2,"a":"x"}`
+				return events
+			},
+			sequence: 2,
+		},
+		{
+			name: "edited outcome",
+			tamper: func(events []models.AuditEvent) []models.AuditEvent {
+				events[2].Outcome = models.AuditOutcomeFailure
+				return events
+			},
+			sequence: 3,
+		},
+		{
+			name: "removed event",
+			tamper: func(events []models.AuditEvent) []models.AuditEvent {
+				return append(events[:1], events[2:]...)
+			},
+			sequence: 3,
+		},
+		{
+			name: "removed first event",
+			tamper: func(events []models.AuditEvent) []models.AuditEvent {
+				return events[1:]
+			},
+			sequence: 2,
+		},
+		{
+			name: "rehashed edit",
+			tamper: func(events []models.AuditEvent) []models.AuditEvent {
+				events[1].IP = "198.51.100.1"
+				events[1].Hash = audit.Hash(events[1])
+				return events
+			},
+			sequence: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			err := suite.verify(tt.tamper(suite.buildChain(4)))
+			suite.Require().Error(err)
+
+			var chainErr *audit.ChainError
+			suite.Require().True(stderrors.As(err, &chainErr))
+			suite.Equal(tt.sequence, chainErr.Sequence)
+		})
+	}
+}
+
+func TestChainSuite(t *testing.T) {
+	suite.Run(t, new(ChainTestSuite))
+}
@@ -1,8 +1,12 @@
 package dto
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"slices"
 
+	"github.com/ssoydabas/auth-service/internal/oauth"
 	"github.com/ssoydabas/auth-service/pkg/validator"
 )
 
@@ -12,6 +16,7 @@ type CreateAccountRequest struct {
 	Email     string `json:"email" validate:"required,email"`
 	Phone     string `json:"phone" validate:"required,e164"`
 	Password  string `json:"password" validate:"required,min=8"`
+	Locale    string `json:"locale" validate:"omitempty,bcp47_language_tag"`
 }
 
 type AuthenticateAccountRequest struct {
@@ -35,6 +40,229 @@ type VerifyAccountRequest struct {
 	Token string `json:"token" validate:"required"`
 }
 
+type ResendEmailVerificationRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type AssignRolesRequest struct {
+	Roles []string `json:"roles" validate:"required,min=1,dive,required"`
+}
+
+type CreateRoleRequest struct {
+	Name        string   `json:"name" validate:"required,min=2,max=50,identifier"`
+	Description string   `json:"description" validate:"max=255"`
+	Permissions []string `json:"permissions" validate:"dive,required"`
+}
+
+type UpdateRoleRequest struct {
+	Name        string   `json:"name" validate:"required,min=2,max=50,identifier"`
+	Description string   `json:"description" validate:"max=255"`
+	Permissions []string `json:"permissions" validate:"dive,required"`
+}
+
+type CreatePermissionRequest struct {
+	Name        string `json:"name" validate:"required,min=3,max=100,identifier"`
+	Description string `json:"description" validate:"max=255"`
+}
+
+// UpdateAccountRequest holds the editable profile fields of an account. The
+// validation rules are the same as on models.Account.
+type UpdateAccountRequest struct {
+	FirstName string `json:"first_name" validate:"required,min=2,max=50"`
+	LastName  string `json:"last_name" validate:"required,min=2,max=50"`
+	PhotoUrl  string `json:"photo_url" validate:"omitempty,url"`
+	Locale    string `json:"locale" validate:"omitempty,bcp47_language_tag"`
+}
+
+// DeleteAccountRequest confirms self-deletion with the account's password.
+type DeleteAccountRequest struct {
+	Password string `json:"password" validate:"required,min=8"`
+}
+
+// SuspendAccountRequest suspends an account, indefinitely when Until is
+// empty.
+type SuspendAccountRequest struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+	Until  string `json:"until" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+}
+
+// StartImpersonationRequest explains why an administrator needs to act as
+// the account; the reason is kept in the audit log.
+type StartImpersonationRequest struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+// ListAccountsRequest holds the query parameters of the admin account
+// listing. Time bounds are RFC 3339 timestamps; the "from" bounds are
+// inclusive and the "to" bounds exclusive. Sort is a field name, prefixed
+// with "-" for descending order. Pages are selected either with Page or with
+// the NextCursor of a previous response, not both.
+type ListAccountsRequest struct {
+	Role               string `query:"role" validate:"omitempty,identifier"`
+	VerificationStatus string `query:"verification_status" validate:"omitempty,oneof=pending verified"`
+	CreatedFrom        string `query:"created_from" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	CreatedTo          string `query:"created_to" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	LastLoginFrom      string `query:"last_login_from" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	LastLoginTo        string `query:"last_login_to" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	Search             string `query:"q" validate:"omitempty,max=100"`
+	Sort               string `query:"sort" validate:"omitempty,oneof=created_at -created_at last_login_at -last_login_at email -email first_name -first_name last_name -last_name"`
+	Page               int    `query:"page" validate:"omitempty,min=1"`
+	PageSize           int    `query:"page_size" validate:"omitempty,min=1,max=100"`
+	Cursor             string `query:"cursor"`
+}
+
+// ListAuditEventsRequest holds the query parameters of the audit log
+// listing. Time bounds are RFC 3339 timestamps; From is inclusive and To
+// exclusive.
+type ListAuditEventsRequest struct {
+	Action   string `query:"action" validate:"omitempty,max=100"`
+	ActorID  uint   `query:"actor_id"`
+	TargetID uint   `query:"target_id"`
+	Outcome  string `query:"outcome" validate:"omitempty,oneof=success failure"`
+	From     string `query:"from" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	To       string `query:"to" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	Page     int    `query:"page" validate:"omitempty,min=1"`
+	PageSize int    `query:"page_size" validate:"omitempty,min=1,max=100"`
+}
+
+// AuthorizeRequest holds the parameters of an OAuth authorization request.
+// They are checked by the OAuth service, which reports problems as OAuth
+// errors rather than validation errors.
+type AuthorizeRequest struct {
+	ResponseType        string `query:"response_type"`
+	ClientID            string `query:"client_id"`
+	RedirectURI         string `query:"redirect_uri"`
+	Scope               string `query:"scope"`
+	State               string `query:"state"`
+	CodeChallenge       string `query:"code_challenge"`
+	CodeChallengeMethod string `query:"code_challenge_method"`
+	Nonce               string `query:"nonce"`
+	Prompt              string `query:"prompt"`
+	MaxAge              string `query:"max_age"`
+}
+
+// EndSessionRequest holds the parameters of an RP-initiated logout
+// request, sent in the query or as a form.
+type EndSessionRequest struct {
+	IDTokenHint           string `query:"id_token_hint" form:"id_token_hint"`
+	ClientID              string `query:"client_id" form:"client_id"`
+	PostLogoutRedirectURI string `query:"post_logout_redirect_uri" form:"post_logout_redirect_uri"`
+	State                 string `query:"state" form:"state"`
+}
+
+// FederationCallbackRequest holds the parameters an upstream identity
+// provider sends the browser back with: a code on success, an error
+// otherwise, and iss when the provider identifies itself (RFC 9207).
+type FederationCallbackRequest struct {
+	Code             string `query:"code"`
+	State            string `query:"state"`
+	Issuer           string `query:"iss"`
+	Error            string `query:"error"`
+	ErrorDescription string `query:"error_description"`
+}
+
+// SAMLResponseRequest holds the form a SAML identity provider posts to the
+// assertion consumer service with the HTTP-POST binding.
+type SAMLResponseRequest struct {
+	SAMLResponse string `form:"SAMLResponse"`
+	RelayState   string `form:"RelayState"`
+}
+
+// TokenRequest holds the form parameters of a request to the OAuth token
+// endpoint. Which of them are required depends on GrantType. The Subject,
+// Actor, Audience and Resource parameters belong to token exchange, where
+// audience and resource may be repeated.
+//
+// Confidential clients authenticate with ClientSecret or ClientAssertion.
+// BasicAuth is set by the handler when the client ID and secret came from
+// the Authorization header rather than the form.
+type TokenRequest struct {
+	GrantType           string   `form:"grant_type"`
+	Code                string   `form:"code"`
+	RedirectURI         string   `form:"redirect_uri"`
+	ClientID            string   `form:"client_id"`
+	ClientSecret        string   `form:"client_secret"`
+	ClientAssertionType string   `form:"client_assertion_type"`
+	ClientAssertion     string   `form:"client_assertion"`
+	CodeVerifier        string   `form:"code_verifier"`
+	RefreshToken        string   `form:"refresh_token"`
+	DeviceCode          string   `form:"device_code"`
+	SubjectToken        string   `form:"subject_token"`
+	SubjectTokenType    string   `form:"subject_token_type"`
+	ActorToken          string   `form:"actor_token"`
+	ActorTokenType      string   `form:"actor_token_type"`
+	RequestedTokenType  string   `form:"requested_token_type"`
+	Audience            []string `form:"audience"`
+	Resource            []string `form:"resource"`
+	Scope               string   `form:"scope"`
+	BasicAuth           bool     `form:"-"`
+}
+
+// DeviceAuthorizationRequest holds the form parameters of a request to the
+// device authorization endpoint (RFC 8628 section 3.1). Clients
+// authenticate as they do at the token endpoint.
+type DeviceAuthorizationRequest struct {
+	ClientID            string `form:"client_id"`
+	ClientSecret        string `form:"client_secret"`
+	ClientAssertionType string `form:"client_assertion_type"`
+	ClientAssertion     string `form:"client_assertion"`
+	Scope               string `form:"scope"`
+	BasicAuth           bool   `form:"-"`
+}
+
+// ClientAuthentication is what a client presents to authenticate at the
+// token and device authorization endpoints.
+type ClientAuthentication struct {
+	ClientID            string
+	ClientSecret        string
+	ClientAssertionType string
+	ClientAssertion     string
+	BasicAuth           bool
+}
+
+// CreateOAuthClientRequest registers a client. GrantTypes defaults to the
+// authorization code and refresh token grants, TokenEndpointAuthMethod to
+// none, which makes the client public. JWKS holds the public keys of a
+// private_key_jwt client, or JWKSURI the URI it publishes them at.
+// PostLogoutRedirectURIs and BackchannelLogoutURI register the client for
+// logout.
+type CreateOAuthClientRequest struct {
+	Name                    string      `json:"name" validate:"required,min=2,max=100"`
+	RedirectURIs            []string    `json:"redirect_uris" validate:"omitempty,max=10"`
+	Scopes                  []string    `json:"scopes" validate:"required,min=1"`
+	GrantTypes              []string    `json:"grant_types" validate:"omitempty,unique,dive,oneof=authorization_code refresh_token client_credentials urn:ietf:params:oauth:grant-type:device_code urn:ietf:params:oauth:grant-type:token-exchange"`
+	TokenEndpointAuthMethod string      `json:"token_endpoint_auth_method" validate:"omitempty,oneof=none client_secret_basic client_secret_post private_key_jwt"`
+	JWKS                    *oauth.JWKS `json:"jwks,omitempty"`
+	JWKSURI                 string      `json:"jwks_uri" validate:"omitempty,max=2048"`
+	PostLogoutRedirectURIs  []string    `json:"post_logout_redirect_uris" validate:"omitempty,max=10"`
+	BackchannelLogoutURI    string      `json:"backchannel_logout_uri" validate:"omitempty,max=2048"`
+}
+
+// ClientRegistrationRequest is the client metadata of a dynamic client
+// registration request (RFC 7591 section 2). A request updating the
+// registration (RFC 7592 section 2.2) also carries the client's ID, and
+// may carry its secret.
+type ClientRegistrationRequest struct {
+	ClientID                string      `json:"client_id,omitempty"`
+	ClientSecret            string      `json:"client_secret,omitempty"`
+	ClientName              string      `json:"client_name"`
+	RedirectURIs            []string    `json:"redirect_uris"`
+	GrantTypes              []string    `json:"grant_types"`
+	ResponseTypes           []string    `json:"response_types"`
+	TokenEndpointAuthMethod string      `json:"token_endpoint_auth_method"`
+	Scope                   string      `json:"scope"`
+	JWKS                    *oauth.JWKS `json:"jwks,omitempty"`
+	JWKSURI                 string      `json:"jwks_uri"`
+	PostLogoutRedirectURIs  []string    `json:"post_logout_redirect_uris"`
+	BackchannelLogoutURI    string      `json:"backchannel_logout_uri"`
+}
+
+// CreateInitialAccessTokenRequest issues an initial access token for one
+// dynamic client registration. Description records who it is for.
+type CreateInitialAccessTokenRequest struct {
+	Description string `json:"description" validate:"max=255"`
+}
+
 func (r *CreateAccountRequest) Validate() error {
 	return validator.ValidateStruct(r)
 }
@@ -74,3 +302,233 @@ func (r *VerifyAccountRequest) Validate() error {
 
 	return validator.ValidateStruct(r)
 }
+
+func (r *ResendEmailVerificationRequest) Validate() error {
+	return validator.ValidateStruct(r)
+}
+
+func (r *AssignRolesRequest) Validate() error {
+	return validator.ValidateStruct(r)
+}
+
+func (r *CreateRoleRequest) Validate() error {
+	return validator.ValidateStruct(r)
+}
+
+func (r *UpdateRoleRequest) Validate() error {
+	return validator.ValidateStruct(r)
+}
+
+func (r *CreatePermissionRequest) Validate() error {
+	return validator.ValidateStruct(r)
+}
+
+// ApplyMergePatch applies a JSON merge patch (RFC 7396) to r. Members set to
+// null reset the field to its zero value, and members that are not editable
+// profile fields are rejected.
+func (r *UpdateAccountRequest) ApplyMergePatch(patch []byte) error {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &members); err != nil || members == nil {
+		return fmt.Errorf("patch must be a JSON object")
+	}
+
+	fields := map[string]*string{
+		"first_name": &r.FirstName,
+		"last_name":  &r.LastName,
+		"photo_url":  &r.PhotoUrl,
+		"locale":     &r.Locale,
+	}
+
+	for name, value := range members {
+		field, ok := fields[name]
+		if !ok {
+			return fmt.Errorf("field %q cannot be updated", name)
+		}
+
+		if bytes.Equal(bytes.TrimSpace(value), []byte("null")) {
+			*field = ""
+			continue
+		}
+
+		if err := json.Unmarshal(value, field); err != nil {
+			return fmt.Errorf("field %q must be a string", name)
+		}
+	}
+
+	return nil
+}
+
+func (r *UpdateAccountRequest) Validate() error {
+	return validator.ValidateStruct(r)
+}
+
+func (r *DeleteAccountRequest) Validate() error {
+	return validator.ValidateStruct(r)
+}
+
+func (r *SuspendAccountRequest) Validate() error {
+	return validator.ValidateStruct(r)
+}
+
+func (r *StartImpersonationRequest) Validate() error {
+	return validator.ValidateStruct(r)
+}
+
+func (r *ListAuditEventsRequest) Validate() error {
+	return validator.ValidateStruct(r)
+}
+
+func (r *ListAccountsRequest) Validate() error {
+	if r.Cursor != "" && r.Page != 0 {
+		return fmt.Errorf("page and cursor cannot be combined")
+	}
+
+	return validator.ValidateStruct(r)
+}
+
+func (r *TokenRequest) Client() ClientAuthentication {
+	return ClientAuthentication{
+		ClientID:            r.ClientID,
+		ClientSecret:        r.ClientSecret,
+		ClientAssertionType: r.ClientAssertionType,
+		ClientAssertion:     r.ClientAssertion,
+		BasicAuth:           r.BasicAuth,
+	}
+}
+
+func (r *DeviceAuthorizationRequest) Client() ClientAuthentication {
+	return ClientAuthentication{
+		ClientID:            r.ClientID,
+		ClientSecret:        r.ClientSecret,
+		ClientAssertionType: r.ClientAssertionType,
+		ClientAssertion:     r.ClientAssertion,
+		BasicAuth:           r.BasicAuth,
+	}
+}
+
+// GrantTypesOrDefault returns GrantTypes, or the grants of an app that
+// signs users in when none were given.
+func (r *CreateOAuthClientRequest) GrantTypesOrDefault() []string {
+	if len(r.GrantTypes) == 0 {
+		return []string{oauth.GrantAuthorizationCode, oauth.GrantRefreshToken}
+	}
+	return r.GrantTypes
+}
+
+func (r *CreateOAuthClientRequest) AuthMethodOrDefault() string {
+	if r.TokenEndpointAuthMethod == "" {
+		return oauth.AuthMethodNone
+	}
+	return r.TokenEndpointAuthMethod
+}
+
+// ClientRequest returns the registration as the request an administrator
+// would send to register the same client. Unset fields take the defaults of
+// RFC 7591 section 2, the authorization_code grant and client_secret_basic,
+// and scope defaults to defaultScopes.
+func (r *ClientRegistrationRequest) ClientRequest(defaultScopes []string) CreateOAuthClientRequest {
+	grantTypes := r.GrantTypes
+	if len(grantTypes) == 0 {
+		grantTypes = []string{oauth.GrantAuthorizationCode}
+	}
+
+	authMethod := r.TokenEndpointAuthMethod
+	if authMethod == "" {
+		authMethod = oauth.AuthMethodClientSecretBasic
+	}
+
+	scopes := oauth.ParseScope(r.Scope)
+	if len(scopes) == 0 {
+		scopes = defaultScopes
+	}
+
+	return CreateOAuthClientRequest{
+		Name:                    r.ClientName,
+		RedirectURIs:            r.RedirectURIs,
+		Scopes:                  scopes,
+		GrantTypes:              grantTypes,
+		TokenEndpointAuthMethod: authMethod,
+		JWKS:                    r.JWKS,
+		JWKSURI:                 r.JWKSURI,
+		PostLogoutRedirectURIs:  r.PostLogoutRedirectURIs,
+		BackchannelLogoutURI:    r.BackchannelLogoutURI,
+	}
+}
+
+func (r *CreateInitialAccessTokenRequest) Validate() error {
+	return validator.ValidateStruct(r)
+}
+
+func (r *CreateOAuthClientRequest) Validate() error {
+	if err := validator.ValidateStruct(r); err != nil {
+		return err
+	}
+
+	grantTypes := r.GrantTypesOrDefault()
+	authMethod := r.AuthMethodOrDefault()
+
+	authorizationCode := slices.Contains(grantTypes, oauth.GrantAuthorizationCode)
+	if authorizationCode && len(r.RedirectURIs) == 0 {
+		return fmt.Errorf("at least one redirect URI is required for the authorization_code grant")
+	}
+	if !authorizationCode && len(r.RedirectURIs) > 0 {
+		return fmt.Errorf("redirect URIs are only used by the authorization_code grant")
+	}
+	signsUsersIn := authorizationCode || slices.Contains(grantTypes, oauth.GrantDeviceCode)
+	if slices.Contains(grantTypes, oauth.GrantRefreshToken) && !signsUsersIn {
+		return fmt.Errorf("the refresh_token grant requires the authorization_code or device_code grant")
+	}
+	if slices.Contains(grantTypes, oauth.GrantClientCredentials) && authMethod == oauth.AuthMethodNone {
+		return fmt.Errorf("the client_credentials grant requires a confidential client")
+	}
+	if slices.Contains(grantTypes, oauth.GrantTokenExchange) && authMethod == oauth.AuthMethodNone {
+		return fmt.Errorf("the token-exchange grant requires a confidential client")
+	}
+
+	if authMethod == oauth.AuthMethodPrivateKeyJWT {
+		switch {
+		case r.JWKS != nil && r.JWKSURI != "":
+			return fmt.Errorf("jwks and jwks_uri cannot both be given")
+		case r.JWKS != nil:
+			if err := r.JWKS.Validate(); err != nil {
+				return fmt.Errorf("invalid jwks: %w", err)
+			}
+		case r.JWKSURI != "":
+			if err := oauth.ValidateJWKSURI(r.JWKSURI); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("jwks or jwks_uri is required for private_key_jwt")
+		}
+	} else if r.JWKS != nil || r.JWKSURI != "" {
+		return fmt.Errorf("jwks and jwks_uri are only used by private_key_jwt")
+	}
+
+	for _, uri := range r.RedirectURIs {
+		if err := oauth.ValidateRedirectURI(uri); err != nil {
+			return err
+		}
+	}
+
+	if !signsUsersIn && (len(r.PostLogoutRedirectURIs) > 0 || r.BackchannelLogoutURI != "") {
+		return fmt.Errorf("logout URIs require the authorization_code or device_code grant")
+	}
+	for _, uri := range r.PostLogoutRedirectURIs {
+		if err := oauth.ValidateRedirectURI(uri); err != nil {
+			return err
+		}
+	}
+	if r.BackchannelLogoutURI != "" {
+		if err := oauth.ValidateLogoutURI(r.BackchannelLogoutURI); err != nil {
+			return err
+		}
+	}
+
+	for _, scope := range r.Scopes {
+		if !oauth.ValidScopeToken(scope) {
+			return fmt.Errorf("invalid scope %q", scope)
+		}
+	}
+
+	return nil
+}
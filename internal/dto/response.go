@@ -1,6 +1,9 @@
 package dto
 
 import (
+	"encoding/json"
+
+	"github.com/ssoydabas/auth-service/internal/oauth"
 	"github.com/ssoydabas/auth-service/pkg/validator"
 )
 
@@ -20,32 +23,262 @@ type PaginatedResponse struct {
 	PageSize    int         `json:"pageSize"`
 	TotalItems  int64       `json:"totalItems"`
 	TotalPages  int         `json:"totalPages"`
+	NextCursor  string      `json:"nextCursor,omitempty"`
 }
 
 type AccountResponse struct {
-	ID                 uint    `json:"id"`
-	FirstName          string  `json:"first_name"`
-	LastName           string  `json:"last_name"`
-	Email              string  `json:"email"`
-	Phone              string  `json:"phone"`
-	PhotoUrl           string  `json:"photo_url"`
-	CreatedAt          string  `json:"created_at"`
-	UpdatedAt          string  `json:"updated_at"`
-	VerificationStatus string  `json:"verification_status"`
-	Role               string  `json:"role"`
-	LastLoginAt        *string `json:"last_login_at,omitempty"`
+	ID                 uint                `json:"id"`
+	FirstName          string              `json:"first_name"`
+	LastName           string              `json:"last_name"`
+	Email              string              `json:"email"`
+	Phone              string              `json:"phone"`
+	PhotoUrl           string              `json:"photo_url"`
+	CreatedAt          string              `json:"created_at"`
+	UpdatedAt          string              `json:"updated_at"`
+	VerificationStatus string              `json:"verification_status"`
+	Roles              []string            `json:"roles"`
+	LastLoginAt        *string             `json:"last_login_at,omitempty"`
+	Locale             string              `json:"locale,omitempty"`
+	Suspension         *SuspensionResponse `json:"suspension,omitempty"`
+
+	// ImpersonatedBy is set when the request was made with an impersonation
+	// token and holds the ID of the administrator behind it.
+	ImpersonatedBy *uint `json:"impersonated_by,omitempty"`
+
+	// ETag identifies this version of the account. It is sent in the ETag
+	// header rather than the body and is required in If-Match on updates.
+	ETag string `json:"-"`
+}
+
+type SuspensionResponse struct {
+	Reason      string  `json:"reason"`
+	SuspendedAt string  `json:"suspended_at"`
+	Until       *string `json:"until,omitempty"`
+	SuspendedBy *uint   `json:"suspended_by,omitempty"`
+}
+
+// AccountDeletionResponse tells a deleted account until when it can still be
+// restored.
+type AccountDeletionResponse struct {
+	PurgeAfter string `json:"purge_after"`
+}
+
+// DataExportResponse describes a personal data export. DownloadURL is a
+// signed link that is set once the export is ready and stops working at
+// ExpiresAt.
+type DataExportResponse struct {
+	ID          string  `json:"id"`
+	Status      string  `json:"status"`
+	CreatedAt   string  `json:"created_at"`
+	ExpiresAt   *string `json:"expires_at,omitempty"`
+	DownloadURL string  `json:"download_url,omitempty"`
+}
+
+type AuditEventResponse struct {
+	ID        uint            `json:"id"`
+	Sequence  uint64          `json:"sequence"`
+	CreatedAt string          `json:"created_at"`
+	Action    string          `json:"action"`
+	Outcome   string          `json:"outcome"`
+	ActorID   *uint           `json:"actor_id,omitempty"`
+	TargetID  *uint           `json:"target_id,omitempty"`
+	IP        string          `json:"ip,omitempty"`
+	UserAgent string          `json:"user_agent,omitempty"`
+	Metadata  json.RawMessage `json:"metadata,omitempty"`
+	PrevHash  string          `json:"prev_hash"`
+	Hash      string          `json:"hash"`
+}
+
+// AuditVerificationResponse reports the result of checking the audit log's
+// hash chain. When Valid is false, BrokenAt describes the first event that
+// did not verify.
+type AuditVerificationResponse struct {
+	Valid     bool                     `json:"valid"`
+	Checked   int64                    `json:"checked"`
+	Unchained int64                    `json:"unchained"`
+	LastHash  string                   `json:"last_hash,omitempty"`
+	BrokenAt  *AuditChainBreakResponse `json:"broken_at,omitempty"`
+}
+
+type AuditChainBreakResponse struct {
+	EventID  uint   `json:"event_id"`
+	Sequence uint64 `json:"sequence"`
+	Reason   string `json:"reason"`
+}
+
+type PhotoResponse struct {
+	PhotoUrl string            `json:"photo_url"`
+	Variants map[string]string `json:"variants"`
+}
+
+type RoleResponse struct {
+	ID          uint     `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	System      bool     `json:"system"`
+	Permissions []string `json:"permissions"`
+}
+
+type PermissionResponse struct {
+	ID          uint   `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	System      bool   `json:"system"`
+}
+
+type NotificationPreviewResponse struct {
+	Kind    string `json:"kind"`
+	Locale  string `json:"locale"`
+	Subject string `json:"subject"`
+	Text    string `json:"text"`
+	HTML    string `json:"html,omitempty"`
 }
 
 type TokenResponse struct {
 	Token string `json:"token"`
 }
 
+type ImpersonationResponse struct {
+	SessionID string `json:"session_id"`
+	Token     string `json:"token"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+// OAuthTokenResponse is a successful response of the OAuth token endpoint
+// (RFC 6749 section 5.1). IssuedTokenType is only set by token exchange
+// (RFC 8693 section 2.2.1).
+type OAuthTokenResponse struct {
+	AccessToken     string `json:"access_token"`
+	TokenType       string `json:"token_type"`
+	ExpiresIn       int64  `json:"expires_in"`
+	RefreshToken    string `json:"refresh_token,omitempty"`
+	Scope           string `json:"scope,omitempty"`
+	IDToken         string `json:"id_token,omitempty"`
+	IssuedTokenType string `json:"issued_token_type,omitempty"`
+}
+
+// DeviceAuthorizationResponse is a successful response of the device
+// authorization endpoint (RFC 8628 section 3.2). Interval is the number of
+// seconds the device must wait between polls of the token endpoint.
+type DeviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// OpenIDConfigurationResponse is the OpenID Provider metadata served at
+// /.well-known/openid-configuration (OpenID Connect Discovery 1.0).
+type OpenIDConfigurationResponse struct {
+	Issuer                                     string   `json:"issuer"`
+	AuthorizationEndpoint                      string   `json:"authorization_endpoint"`
+	TokenEndpoint                              string   `json:"token_endpoint"`
+	DeviceAuthorizationEndpoint                string   `json:"device_authorization_endpoint"`
+	UserinfoEndpoint                           string   `json:"userinfo_endpoint"`
+	EndSessionEndpoint                         string   `json:"end_session_endpoint"`
+	RegistrationEndpoint                       string   `json:"registration_endpoint"`
+	JWKSURI                                    string   `json:"jwks_uri"`
+	ScopesSupported                            []string `json:"scopes_supported"`
+	ResponseTypesSupported                     []string `json:"response_types_supported"`
+	ResponseModesSupported                     []string `json:"response_modes_supported"`
+	GrantTypesSupported                        []string `json:"grant_types_supported"`
+	SubjectTypesSupported                      []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported           []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthSigningAlgValuesSupported []string `json:"token_endpoint_auth_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported          []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported              []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                            []string `json:"claims_supported"`
+	PromptValuesSupported                      []string `json:"prompt_values_supported"`
+	AuthorizationResponseIssParameterSupported bool     `json:"authorization_response_iss_parameter_supported"`
+	BackchannelLogoutSupported                 bool     `json:"backchannel_logout_supported"`
+	BackchannelLogoutSessionSupported          bool     `json:"backchannel_logout_session_supported"`
+}
+
+// OAuthErrorResponse is an error response of the OAuth token endpoint
+// (RFC 6749 section 5.2).
+type OAuthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// OAuthClientResponse describes a client. ClientSecret is only set in the
+// response that creates the secret; it cannot be retrieved later.
+type OAuthClientResponse struct {
+	ClientID                string   `json:"client_id"`
+	ClientSecret            string   `json:"client_secret,omitempty"`
+	Name                    string   `json:"name"`
+	RedirectURIs            []string `json:"redirect_uris"`
+	Scopes                  []string `json:"scopes"`
+	GrantTypes              []string `json:"grant_types"`
+	TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method"`
+	PostLogoutRedirectURIs  []string `json:"post_logout_redirect_uris,omitempty"`
+	BackchannelLogoutURI    string   `json:"backchannel_logout_uri,omitempty"`
+	JWKSURI                 string   `json:"jwks_uri,omitempty"`
+	SecretRotatedAt         *string  `json:"secret_rotated_at,omitempty"`
+	CreatedAt               string   `json:"created_at"`
+	DisabledAt              *string  `json:"disabled_at,omitempty"`
+}
+
+// ClientRegistrationResponse describes a dynamically registered client
+// (RFC 7591 section 3.2.1). ClientSecret is only set in the response that
+// creates the secret. ClientSecretExpiresAt is 0 for clients with a
+// secret, which does not expire, and unset otherwise.
+type ClientRegistrationResponse struct {
+	ClientID                string      `json:"client_id"`
+	ClientSecret            string      `json:"client_secret,omitempty"`
+	ClientIDIssuedAt        int64       `json:"client_id_issued_at"`
+	ClientSecretExpiresAt   *int64      `json:"client_secret_expires_at,omitempty"`
+	RegistrationAccessToken string      `json:"registration_access_token"`
+	RegistrationClientURI   string      `json:"registration_client_uri"`
+	ClientName              string      `json:"client_name"`
+	RedirectURIs            []string    `json:"redirect_uris"`
+	GrantTypes              []string    `json:"grant_types"`
+	ResponseTypes           []string    `json:"response_types"`
+	TokenEndpointAuthMethod string      `json:"token_endpoint_auth_method"`
+	Scope                   string      `json:"scope"`
+	JWKS                    *oauth.JWKS `json:"jwks,omitempty"`
+	JWKSURI                 string      `json:"jwks_uri,omitempty"`
+	PostLogoutRedirectURIs  []string    `json:"post_logout_redirect_uris,omitempty"`
+	BackchannelLogoutURI    string      `json:"backchannel_logout_uri,omitempty"`
+}
+
+// InitialAccessTokenResponse returns an initial access token. The token is
+// only returned here; it cannot be retrieved later.
+type InitialAccessTokenResponse struct {
+	Token       string `json:"token"`
+	Description string `json:"description,omitempty"`
+	ExpiresAt   string `json:"expires_at"`
+}
+
+// OAuthGrantResponse describes the scopes an account has allowed a client
+// to use.
+type OAuthGrantResponse struct {
+	ClientID   string   `json:"client_id"`
+	ClientName string   `json:"client_name"`
+	Scopes     []string `json:"scopes"`
+	CreatedAt  string   `json:"created_at"`
+	UpdatedAt  string   `json:"updated_at"`
+}
+
+// SessionResponse describes a browser sign-in at the authorization server.
+type SessionResponse struct {
+	ID        string   `json:"id"`
+	AuthTime  string   `json:"auth_time"`
+	AMR       []string `json:"amr"`
+	IP        string   `json:"ip"`
+	UserAgent string   `json:"user_agent"`
+	ExpiresAt string   `json:"expires_at"`
+	RevokedAt *string  `json:"revoked_at,omitempty"`
+}
+
 type AuthenticateAccountResponse struct {
 	Token string `json:"token"`
 }
 
-type VerificationCodeResponse struct {
-	VerificationCode string `json:"verification_code"`
+type MessageResponse struct {
+	Message string `json:"message"`
 }
 
 type ValidationErrorResponse struct {
@@ -0,0 +1,113 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// FormatVersion is written to the manifest and changes whenever the layout
+// of the archive changes in a way readers have to know about.
+const FormatVersion = 1
+
+const manifestName = "manifest.json"
+
+// Collector returns the data of one archive section for an account. The
+// result is encoded as JSON.
+type Collector func(ctx context.Context, accountID uint) (any, error)
+
+type section struct {
+	name    string
+	collect Collector
+}
+
+type manifest struct {
+	FormatVersion int       `json:"format_version"`
+	AccountID     uint      `json:"account_id"`
+	GeneratedAt   time.Time `json:"generated_at"`
+	Sections      []string  `json:"sections"`
+}
+
+// Builder assembles personal data archives: a zip holding manifest.json and
+// one <section>.json file per registered section.
+type Builder struct {
+	mu       sync.RWMutex
+	sections []section
+}
+
+func NewBuilder() *Builder {
+	return &Builder{}
+}
+
+// Register adds a section to every archive built from now on. Sections are
+// written in the order they were registered, and registering a name again
+// replaces its collector.
+func (b *Builder) Register(name string, collect Collector) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i := range b.sections {
+		if b.sections[i].name == name {
+			b.sections[i].collect = collect
+			return
+		}
+	}
+	b.sections = append(b.sections, section{name: name, collect: collect})
+}
+
+// Build collects every section for accountID and returns the zip archive.
+func (b *Builder) Build(ctx context.Context, accountID uint, generatedAt time.Time) ([]byte, error) {
+	b.mu.RLock()
+	sections := append([]section(nil), b.sections...)
+	b.mu.RUnlock()
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+
+	m := manifest{
+		FormatVersion: FormatVersion,
+		AccountID:     accountID,
+		GeneratedAt:   generatedAt.UTC(),
+		Sections:      make([]string, 0, len(sections)),
+	}
+
+	for _, s := range sections {
+		data, err := s.collect(ctx, accountID)
+		if err != nil {
+			return nil, fmt.Errorf("export section %s: %w", s.name, err)
+		}
+		if err := writeJSON(archive, s.name+".json", generatedAt, data); err != nil {
+			return nil, err
+		}
+		m.Sections = append(m.Sections, s.name)
+	}
+
+	if err := writeJSON(archive, manifestName, generatedAt, m); err != nil {
+		return nil, err
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func writeJSON(archive *zip.Writer, name string, modified time.Time, data any) error {
+	w, err := archive.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: modified,
+	})
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(data)
+}
@@ -0,0 +1,103 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/ssoydabas/auth-service/internal/export"
+	"github.com/stretchr/testify/suite"
+)
+
+type BuilderTestSuite struct {
+	suite.Suite
+	builder *export.Builder
+}
+
+func (suite *BuilderTestSuite) SetupTest() {
+	suite.builder = export.NewBuilder()
+}
+
+func readArchive(data []byte) (map[string][]byte, []string, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	files := make(map[string][]byte)
+	var names []string
+	for _, file := range reader.File {
+		rc, err := file.Open()
+		if err != nil {
+			return nil, nil, err
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+		files[file.Name] = content
+		names = append(names, file.Name)
+	}
+	return files, names, nil
+}
+
+func (suite *BuilderTestSuite) TestBuild() {
+	suite.builder.Register("profile", func(ctx context.Context, accountID uint) (any, error) {
+		return map[string]any{"id": accountID, "first_name": "John"}, nil
+	})
+	suite.builder.Register("sessions", func(ctx context.Context, accountID uint) (any, error) {
+		return []string{}, nil
+	})
+	// Registering a name again replaces the collector but keeps its position.
+	suite.builder.Register("profile", func(ctx context.Context, accountID uint) (any, error) {
+		return map[string]any{"id": accountID, "first_name": "Jane"}, nil
+	})
+
+	generatedAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	archive, err := suite.builder.Build(context.Background(), 7, generatedAt)
+	suite.Require().NoError(err)
+
+	files, names, err := readArchive(archive)
+	suite.Require().NoError(err)
+	suite.Equal([]string{"profile.json", "sessions.json", "manifest.json"}, names)
+
+	var profile map[string]any
+	suite.Require().NoError(json.Unmarshal(files["profile.json"], &profile))
+	suite.Equal("Jane", profile["first_name"])
+	suite.Equal(float64(7), profile["id"])
+
+	suite.JSONEq(`[]`, string(files["sessions.json"]))
+
+	var manifest struct {
+		FormatVersion int       `json:"format_version"`
+		AccountID     uint      `json:"account_id"`
+		GeneratedAt   time.Time `json:"generated_at"`
+		Sections      []string  `json:"sections"`
+	}
+	suite.Require().NoError(json.Unmarshal(files["manifest.json"], &manifest))
+	suite.Equal(export.FormatVersion, manifest.FormatVersion)
+	suite.Equal(uint(7), manifest.AccountID)
+	suite.True(generatedAt.Equal(manifest.GeneratedAt))
+	suite.Equal([]string{"profile", "sessions"}, manifest.Sections)
+}
+
+func (suite *BuilderTestSuite) TestBuildFailsWhenASectionFails() {
+	suite.builder.Register("profile", func(ctx context.Context, accountID uint) (any, error) {
+		return nil, fmt.Errorf("connection reset")
+	})
+
+	_, err := suite.builder.Build(context.Background(), 7, time.Now())
+
+	suite.Error(err)
+	suite.Contains(err.Error(), "profile")
+}
+
+func TestBuilderTestSuite(t *testing.T) {
+	suite.Run(t, new(BuilderTestSuite))
+}
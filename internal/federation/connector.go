@@ -0,0 +1,175 @@
+// Package federation signs users in with upstream OpenID Connect, OAuth 2.0
+// and SAML 2.0 identity providers, such as the single sign-on of a school.
+// Each provider is configured by a Connector.
+package federation
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+
+	"github.com/ssoydabas/auth-service/internal/oauth"
+)
+
+// Protocols an identity provider can be spoken to with.
+const (
+	ProtocolOAuth = "oauth"
+	ProtocolSAML  = "saml"
+)
+
+// Connector configures an upstream identity provider. With the oauth
+// protocol, the default, scopes that include openid make it an OpenID
+// Connect provider, whose ID token identifies the user; otherwise the user
+// is identified by the claims of its userinfo endpoint. Endpoints left out
+// are discovered from the issuer. A saml provider is described by its
+// metadata, given inline or as a file, and identifies the user by the
+// NameID of its assertions; Claims then name its attributes.
+type Connector struct {
+	ID           string   `json:"id"`
+	Name         string   `json:"name"`
+	Protocol     string   `json:"protocol"`
+	Issuer       string   `json:"issuer"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	Scopes       []string `json:"scopes"`
+
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserInfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+
+	SAMLMetadata     string `json:"saml_metadata"`
+	SAMLMetadataFile string `json:"saml_metadata_file"`
+
+	Claims ClaimMapping `json:"claims"`
+
+	// TrustEmail treats every email address the provider shares as
+	// verified, for providers that manage their users' addresses but do not
+	// send email_verified.
+	TrustEmail bool `json:"trust_email"`
+}
+
+// ClaimMapping names the upstream claims, or SAML attributes, that fill the
+// fields of an account. Names left out default to the standard OpenID
+// Connect claims, or the standard LDAP attributes for SAML. Subject only
+// applies to providers without ID tokens; the sub of an ID token always
+// identifies the user.
+type ClaimMapping struct {
+	Subject       string `json:"subject"`
+	Email         string `json:"email"`
+	EmailVerified string `json:"email_verified"`
+	FirstName     string `json:"first_name"`
+	LastName      string `json:"last_name"`
+	Name          string `json:"name"`
+	Phone         string `json:"phone"`
+	PhotoURL      string `json:"photo_url"`
+	Locale        string `json:"locale"`
+}
+
+// defaultScopes are requested from providers configured without scopes.
+var defaultScopes = []string{oauth.ScopeOpenID, oauth.ScopeProfile, oauth.ScopeEmail}
+
+// idPattern restricts connector IDs to what can appear in a URL path as is.
+var idPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// LoadConnectors reads the JSON array of connectors in the file at path and
+// validates them. An empty path configures no providers.
+func LoadConnectors(path string) ([]Connector, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var connectors []Connector
+	if err := json.Unmarshal(data, &connectors); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	seen := map[string]bool{}
+	for i := range connectors {
+		if err := connectors[i].normalize(); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if seen[connectors[i].ID] {
+			return nil, fmt.Errorf("%s: connector %q is configured twice", path, connectors[i].ID)
+		}
+		seen[connectors[i].ID] = true
+	}
+
+	return connectors, nil
+}
+
+// normalize fills in the defaults of c and checks that it can be used.
+func (c *Connector) normalize() error {
+	if !idPattern.MatchString(c.ID) {
+		return fmt.Errorf("connector ID %q must be lowercase letters, digits, - and _", c.ID)
+	}
+	if c.Name == "" {
+		c.Name = c.ID
+	}
+
+	switch c.Protocol {
+	case "", ProtocolOAuth:
+		c.Protocol = ProtocolOAuth
+	case ProtocolSAML:
+		return c.normalizeSAML()
+	default:
+		return fmt.Errorf("connector %q: protocol must be %s or %s", c.ID, ProtocolOAuth, ProtocolSAML)
+	}
+
+	if c.ClientID == "" {
+		return fmt.Errorf("connector %q: client_id is required", c.ID)
+	}
+	if len(c.Scopes) == 0 {
+		c.Scopes = slices.Clone(defaultScopes)
+	}
+	for _, scope := range c.Scopes {
+		if !oauth.ValidScopeToken(scope) {
+			return fmt.Errorf("connector %q: %q is not a scope", c.ID, scope)
+		}
+	}
+
+	if c.Issuer == "" && !c.explicitEndpoints() {
+		return fmt.Errorf("connector %q: issuer is required unless authorization_endpoint and token_endpoint are given", c.ID)
+	}
+	if c.OpenID() && c.Issuer == "" {
+		return fmt.Errorf("connector %q: issuer is required to verify ID tokens", c.ID)
+	}
+	if !c.OpenID() && c.explicitEndpoints() && c.UserInfoEndpoint == "" {
+		return fmt.Errorf("connector %q: userinfo_endpoint is required without the openid scope", c.ID)
+	}
+
+	for kind, uri := range map[string]string{
+		"issuer":                 c.Issuer,
+		"authorization_endpoint": c.AuthorizationEndpoint,
+		"token_endpoint":         c.TokenEndpoint,
+		"userinfo_endpoint":      c.UserInfoEndpoint,
+		"jwks_uri":               c.JWKSURI,
+	} {
+		if uri == "" {
+			continue
+		}
+		if err := oauth.ValidateServerURI(kind, uri); err != nil {
+			return fmt.Errorf("connector %q: %w", c.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// OpenID reports whether the provider is asked for an ID token.
+func (c *Connector) OpenID() bool {
+	return slices.Contains(c.Scopes, oauth.ScopeOpenID)
+}
+
+// explicitEndpoints reports whether the endpoints the sign-in needs are
+// configured, so the issuer's discovery document need not be fetched.
+func (c *Connector) explicitEndpoints() bool {
+	return c.AuthorizationEndpoint != "" && c.TokenEndpoint != "" && (!c.OpenID() || c.JWKSURI != "")
+}
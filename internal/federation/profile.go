@@ -0,0 +1,203 @@
+package federation
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/ssoydabas/auth-service/internal/oauth"
+)
+
+// clockSkew is the leeway given to the time claims of ID tokens, since the
+// provider's clock may differ from ours.
+const clockSkew = time.Minute
+
+// standardClaims are the OpenID Connect claims (OpenID Connect Core 1.0
+// section 5.1) that fill the fields of an account unless the connector
+// maps others.
+var standardClaims = ClaimMapping{
+	Subject:       "sub",
+	Email:         "email",
+	EmailVerified: "email_verified",
+	FirstName:     "given_name",
+	LastName:      "family_name",
+	Name:          "name",
+	Phone:         "phone_number",
+	PhotoURL:      "picture",
+	Locale:        "locale",
+}
+
+// Profile is what a provider tells about the user who signed in, mapped to
+// the fields of an account. Fields the provider did not share are empty.
+type Profile struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	FirstName     string
+	LastName      string
+	Phone         string
+	PhotoURL      string
+	Locale        string
+}
+
+// Authenticate identifies the user the tokens were issued for. The ID token
+// of an OpenID Connect provider is verified against nonce, and claims it
+// lacks are taken from the userinfo endpoint, if there is one, as long as
+// its sub matches. Providers without ID tokens identify the user by their
+// userinfo claims alone.
+func (p *Provider) Authenticate(ctx context.Context, tokens *Tokens, nonce string, now time.Time) (*Profile, error) {
+	metadata, err := p.Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.connector.OpenID() {
+		claims, err := p.UserInfo(ctx, tokens.AccessToken)
+		if err != nil {
+			return nil, err
+		}
+		return p.profile(claims, claimString(claims, claimName(p.connector.Claims.Subject, standardClaims.Subject)), standardClaims)
+	}
+
+	claims, err := p.VerifyIDToken(ctx, tokens.IDToken, nonce, now)
+	if err != nil {
+		return nil, err
+	}
+	subject := claimString(claims, "sub")
+
+	if metadata.UserInfoEndpoint != "" {
+		userInfo, err := p.UserInfo(ctx, tokens.AccessToken)
+		if err != nil {
+			return nil, err
+		}
+		// OpenID Connect Core 1.0 section 5.3.2: userinfo about another
+		// user must not be used.
+		if claimString(userInfo, "sub") != subject {
+			return nil, errors.New("the userinfo response is about another user")
+		}
+		for name, value := range userInfo {
+			if _, ok := claims[name]; !ok {
+				claims[name] = value
+			}
+		}
+	}
+
+	return p.profile(claims, subject, standardClaims)
+}
+
+// VerifyIDToken checks an ID token of the provider (OpenID Connect Core 1.0
+// section 3.1.3.7) and returns its claims. It must be signed with RS256 by
+// a key the provider publishes, be issued by the provider for our client ID
+// and carry nonce.
+func (p *Provider) VerifyIDToken(ctx context.Context, idToken, nonce string, now time.Time) (map[string]any, error) {
+	metadata, err := p.Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := oauth.FetchJWKS(ctx, p.client, metadata.JWKSURI)
+	if err != nil {
+		return nil, fmt.Errorf("provider keys: %w", err)
+	}
+
+	claims := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(idToken, claims, keys.Keyfunc,
+		jwt.WithValidMethods([]string{oauth.SigningAlgorithm}),
+		jwt.WithTimeFunc(func() time.Time { return now }),
+		jwt.WithLeeway(clockSkew),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithIssuer(metadata.Issuer),
+		jwt.WithAudience(p.connector.ClientID),
+		jwt.WithJSONNumber(),
+	); err != nil {
+		return nil, fmt.Errorf("ID token: %w", err)
+	}
+
+	audiences, _ := claims.GetAudience()
+	azp := claimString(claims, "azp")
+	if (len(audiences) > 1 || azp != "") && azp != p.connector.ClientID {
+		return nil, errors.New("ID token: it was issued to another client")
+	}
+	if subtle.ConstantTimeCompare([]byte(claimString(claims, "nonce")), []byte(nonce)) != 1 {
+		return nil, errors.New("ID token: the nonce does not match")
+	}
+	if claimString(claims, "sub") == "" {
+		return nil, errors.New("ID token: sub is missing")
+	}
+
+	return claims, nil
+}
+
+// profile maps claims to a profile through the connector's claim mapping,
+// falling back to defaults for the claims it does not name. Providers that
+// only share a full name have it split at the last space.
+func (p *Provider) profile(claims map[string]any, subject string, defaults ClaimMapping) (*Profile, error) {
+	if subject == "" {
+		return nil, errors.New("the provider did not identify the user")
+	}
+
+	mapping := p.connector.Claims
+	profile := &Profile{
+		Subject:   subject,
+		Email:     claimString(claims, claimName(mapping.Email, defaults.Email)),
+		FirstName: claimString(claims, claimName(mapping.FirstName, defaults.FirstName)),
+		LastName:  claimString(claims, claimName(mapping.LastName, defaults.LastName)),
+		Phone:     claimString(claims, claimName(mapping.Phone, defaults.Phone)),
+		PhotoURL:  claimString(claims, claimName(mapping.PhotoURL, defaults.PhotoURL)),
+		Locale:    claimString(claims, claimName(mapping.Locale, defaults.Locale)),
+	}
+	profile.EmailVerified = profile.Email != "" &&
+		(p.connector.TrustEmail || claimBool(claims, claimName(mapping.EmailVerified, defaults.EmailVerified)))
+
+	if profile.FirstName == "" && profile.LastName == "" {
+		name := claimString(claims, claimName(mapping.Name, defaults.Name))
+		if i := strings.LastIndex(name, " "); i > 0 {
+			profile.FirstName, profile.LastName = strings.TrimSpace(name[:i]), name[i+1:]
+		} else {
+			profile.FirstName = name
+		}
+	}
+
+	return profile, nil
+}
+
+func claimName(configured, standard string) string {
+	if configured != "" {
+		return configured
+	}
+	return standard
+}
+
+// claimString returns a string or number claim as a string. Numeric
+// subjects, as some OAuth 2.0 providers use, keep all their digits.
+func claimString(claims map[string]any, name string) string {
+	switch value := claims[name].(type) {
+	case string:
+		return strings.TrimSpace(value)
+	case json.Number:
+		return value.String()
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	default:
+		return ""
+	}
+}
+
+// claimBool reads a boolean claim, which some providers send as a string.
+func claimBool(claims map[string]any, name string) bool {
+	switch value := claims[name].(type) {
+	case bool:
+		return value
+	case string:
+		return value == "true"
+	default:
+		return false
+	}
+}
@@ -0,0 +1,278 @@
+package federation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/ssoydabas/auth-service/internal/oauth"
+	"github.com/ssoydabas/auth-service/internal/saml"
+)
+
+// maxResponseSize bounds the documents read from a provider.
+const maxResponseSize = 64 << 10
+
+// Metadata holds the endpoints of a provider, as configured or as read from
+// its discovery document (OpenID Connect Discovery 1.0 section 3).
+type Metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserInfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Tokens is the token response of a provider.
+type Tokens struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+}
+
+// Provider talks to an upstream identity provider. The discovery document
+// is fetched, or the SAML metadata parsed, when the provider is first used
+// and kept for the life of the process.
+type Provider struct {
+	connector Connector
+	client    *http.Client
+
+	mu       sync.Mutex
+	metadata *Metadata
+	idp      *saml.IdentityProvider
+}
+
+func NewProvider(connector Connector, client *http.Client) *Provider {
+	return &Provider{connector: connector, client: client}
+}
+
+func (p *Provider) ID() string {
+	return p.connector.ID
+}
+
+func (p *Provider) Name() string {
+	return p.connector.Name
+}
+
+// AuthCodeURL returns the URL that starts an authorization code flow at the
+// provider. The verifier is sent as its S256 challenge (RFC 7636) and nonce
+// is only sent to OpenID Connect providers.
+func (p *Provider) AuthCodeURL(ctx context.Context, redirectURI, state, nonce, verifier string) (string, error) {
+	metadata, err := p.Metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{
+		"response_type":         {oauth.ResponseTypeCode},
+		"client_id":             {p.connector.ClientID},
+		"redirect_uri":          {redirectURI},
+		"scope":                 {oauth.FormatScope(p.connector.Scopes)},
+		"state":                 {state},
+		"code_challenge":        {oauth.S256Challenge(verifier)},
+		"code_challenge_method": {oauth.MethodS256},
+	}
+	if p.connector.OpenID() {
+		params.Set("nonce", nonce)
+	}
+
+	return oauth.AppendQuery(metadata.AuthorizationEndpoint, params), nil
+}
+
+// Exchange redeems an authorization code at the token endpoint. Providers
+// configured with a secret are sent it with HTTP Basic; others are treated
+// as public clients.
+func (p *Provider) Exchange(ctx context.Context, code, redirectURI, verifier string) (*Tokens, error) {
+	metadata, err := p.Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {oauth.GrantAuthorizationCode},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {verifier},
+	}
+	if p.connector.ClientSecret == "" {
+		form.Set("client_id", p.connector.ClientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.connector.ClientSecret != "" {
+		// RFC 6749 section 2.3.1 form-encodes the credentials first.
+		req.SetBasicAuth(url.QueryEscape(p.connector.ClientID), url.QueryEscape(p.connector.ClientSecret))
+	}
+
+	var tokens Tokens
+	if err := p.do(req, &tokens); err != nil {
+		return nil, fmt.Errorf("token endpoint: %w", err)
+	}
+	if tokens.AccessToken == "" {
+		return nil, errors.New("token endpoint: the response has no access token")
+	}
+	if p.connector.OpenID() && tokens.IDToken == "" {
+		return nil, errors.New("token endpoint: the response has no ID token")
+	}
+
+	return &tokens, nil
+}
+
+// UserInfo fetches the claims of the user the access token was issued for.
+// Only JSON responses are understood, not signed userinfo.
+func (p *Provider) UserInfo(ctx context.Context, accessToken string) (map[string]any, error) {
+	metadata, err := p.Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if metadata.UserInfoEndpoint == "" {
+		return nil, errors.New("the provider has no userinfo endpoint")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadata.UserInfoEndpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", oauth.TokenTypeBearer+" "+accessToken)
+
+	claims := map[string]any{}
+	if err := p.do(req, &claims); err != nil {
+		return nil, fmt.Errorf("userinfo endpoint: %w", err)
+	}
+	return claims, nil
+}
+
+// Metadata returns the endpoints of the provider. Endpoints that are not
+// configured are read from the issuer's discovery document, whose issuer
+// must match the configured one exactly.
+func (p *Provider) Metadata(ctx context.Context) (*Metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	metadata := &Metadata{
+		Issuer:                p.connector.Issuer,
+		AuthorizationEndpoint: p.connector.AuthorizationEndpoint,
+		TokenEndpoint:         p.connector.TokenEndpoint,
+		UserInfoEndpoint:      p.connector.UserInfoEndpoint,
+		JWKSURI:               p.connector.JWKSURI,
+	}
+
+	if !p.connector.explicitEndpoints() {
+		discovered, err := p.discover(ctx)
+		if err != nil {
+			return nil, err
+		}
+		metadata.AuthorizationEndpoint = firstNonEmpty(metadata.AuthorizationEndpoint, discovered.AuthorizationEndpoint)
+		metadata.TokenEndpoint = firstNonEmpty(metadata.TokenEndpoint, discovered.TokenEndpoint)
+		metadata.UserInfoEndpoint = firstNonEmpty(metadata.UserInfoEndpoint, discovered.UserInfoEndpoint)
+		metadata.JWKSURI = firstNonEmpty(metadata.JWKSURI, discovered.JWKSURI)
+	}
+
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" {
+		return nil, errors.New("the provider has no authorization or token endpoint")
+	}
+	if p.connector.OpenID() && metadata.JWKSURI == "" {
+		return nil, errors.New("the provider publishes no keys to verify ID tokens with")
+	}
+	if !p.connector.OpenID() && metadata.UserInfoEndpoint == "" {
+		return nil, errors.New("the provider has no userinfo endpoint")
+	}
+
+	p.metadata = metadata
+	return metadata, nil
+}
+
+// discover fetches the discovery document of the issuer.
+func (p *Provider) discover(ctx context.Context) (*Metadata, error) {
+	uri := strings.TrimSuffix(p.connector.Issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	var discovered Metadata
+	if err := p.do(req, &discovered); err != nil {
+		return nil, fmt.Errorf("discovery: %w", err)
+	}
+	if discovered.Issuer != p.connector.Issuer {
+		return nil, fmt.Errorf("discovery: the document is for issuer %q", discovered.Issuer)
+	}
+
+	for kind, uri := range map[string]string{
+		"authorization_endpoint": discovered.AuthorizationEndpoint,
+		"token_endpoint":         discovered.TokenEndpoint,
+		"userinfo_endpoint":      discovered.UserInfoEndpoint,
+		"jwks_uri":               discovered.JWKSURI,
+	} {
+		if uri == "" {
+			continue
+		}
+		if err := oauth.ValidateServerURI(kind, uri); err != nil {
+			return nil, fmt.Errorf("discovery: %w", err)
+		}
+	}
+
+	return &discovered, nil
+}
+
+// do sends req and decodes the JSON response into v. Error responses are
+// returned with the OAuth error code the provider gave, if any.
+func (p *Provider) do(req *http.Request, v any) error {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize+1))
+	if err != nil {
+		return err
+	}
+	if len(body) > maxResponseSize {
+		return fmt.Errorf("the response exceeds %d bytes", maxResponseSize)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var oauthErr struct {
+			Error            string `json:"error"`
+			ErrorDescription string `json:"error_description"`
+		}
+		if json.Unmarshal(body, &oauthErr) == nil && oauthErr.Error != "" {
+			return fmt.Errorf("%s: %s %s", resp.Status, oauthErr.Error, oauthErr.ErrorDescription)
+		}
+		return fmt.Errorf("answered %s", resp.Status)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("the response is not valid JSON: %w", err)
+	}
+	return nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,30 @@
+package federation
+
+import "net/http"
+
+// Providers are the configured identity providers, in the order of the
+// configuration file.
+type Providers struct {
+	list []*Provider
+	byID map[string]*Provider
+}
+
+func NewProviders(connectors []Connector, client *http.Client) *Providers {
+	providers := &Providers{byID: map[string]*Provider{}}
+	for _, connector := range connectors {
+		provider := NewProvider(connector, client)
+		providers.list = append(providers.list, provider)
+		providers.byID[connector.ID] = provider
+	}
+	return providers
+}
+
+// Get returns the provider with the given connector ID.
+func (p *Providers) Get(id string) (*Provider, bool) {
+	provider, ok := p.byID[id]
+	return provider, ok
+}
+
+func (p *Providers) List() []*Provider {
+	return p.list
+}
@@ -0,0 +1,124 @@
+package federation
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ssoydabas/auth-service/internal/oauth"
+	"github.com/ssoydabas/auth-service/internal/saml"
+)
+
+// standardAttributes are the attributes that fill the fields of an account
+// from a SAML assertion unless the connector maps others: those of the
+// X.500/LDAP attribute profile (SAML Profiles section 8.2), which names
+// them by OID.
+var standardAttributes = ClaimMapping{
+	Email:     "urn:oid:0.9.2342.19200300.100.1.3",
+	FirstName: "urn:oid:2.5.4.42",
+	LastName:  "urn:oid:2.5.4.4",
+	Name:      "urn:oid:2.16.840.1.113730.3.1.241",
+	Phone:     "urn:oid:2.5.4.20",
+	Locale:    "urn:oid:2.16.840.1.113730.3.1.39",
+}
+
+// normalizeSAML reads the metadata of a SAML connector and checks it.
+func (c *Connector) normalizeSAML() error {
+	if c.SAMLMetadataFile != "" {
+		if c.SAMLMetadata != "" {
+			return fmt.Errorf("connector %q: saml_metadata and saml_metadata_file cannot both be given", c.ID)
+		}
+		data, err := os.ReadFile(c.SAMLMetadataFile)
+		if err != nil {
+			return fmt.Errorf("connector %q: %w", c.ID, err)
+		}
+		c.SAMLMetadata = string(data)
+	}
+	if c.SAMLMetadata == "" {
+		return fmt.Errorf("connector %q: saml_metadata or saml_metadata_file is required", c.ID)
+	}
+
+	idp, err := saml.ParseIdentityProviderMetadata([]byte(c.SAMLMetadata))
+	if err != nil {
+		return fmt.Errorf("connector %q: %w", c.ID, err)
+	}
+	if err := oauth.ValidateServerURI("SingleSignOnService", idp.SSOURL); err != nil {
+		return fmt.Errorf("connector %q: %w", c.ID, err)
+	}
+
+	return nil
+}
+
+// SAML reports whether the provider is a SAML 2.0 identity provider.
+func (p *Provider) SAML() bool {
+	return p.connector.Protocol == ProtocolSAML
+}
+
+// identityProvider returns the SAML metadata of the provider, parsed when
+// first used.
+func (p *Provider) identityProvider() (*saml.IdentityProvider, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.idp == nil {
+		idp, err := saml.ParseIdentityProviderMetadata([]byte(p.connector.SAMLMetadata))
+		if err != nil {
+			return nil, err
+		}
+		p.idp = idp
+	}
+	return p.idp, nil
+}
+
+// AuthnRequestURL returns the URL that sends the user to a SAML provider
+// with an AuthnRequest from sp, and the ID of the request.
+func (p *Provider) AuthnRequestURL(sp *saml.ServiceProvider, relayState string, now time.Time) (string, string, error) {
+	idp, err := p.identityProvider()
+	if err != nil {
+		return "", "", err
+	}
+	return sp.AuthnRequestURL(idp, relayState, now)
+}
+
+// AuthenticateSAML checks the response a SAML provider posted to sp for
+// the AuthnRequest requestID and identifies the user. The NameID is the
+// subject unless the connector maps it to an attribute, which it must for
+// providers that only send transient NameIDs. An email address NameID
+// stands in for a missing email attribute.
+func (p *Provider) AuthenticateSAML(sp *saml.ServiceProvider, samlResponse, requestID string, now time.Time) (*Profile, error) {
+	idp, err := p.identityProvider()
+	if err != nil {
+		return nil, err
+	}
+	assertion, err := sp.ParseResponse(idp, samlResponse, requestID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := map[string]any{}
+	for _, attribute := range assertion.Attributes {
+		if len(attribute.Values) == 0 {
+			continue
+		}
+		for _, name := range []string{attribute.Name, attribute.FriendlyName} {
+			if _, ok := claims[name]; name != "" && !ok {
+				claims[name] = attribute.Values[0]
+			}
+		}
+	}
+
+	subject := assertion.NameID
+	if p.connector.Claims.Subject != "" {
+		subject = claimString(claims, p.connector.Claims.Subject)
+	} else if assertion.NameIDFormat == saml.NameIDFormatTransient {
+		return nil, errors.New("a transient NameID does not identify the user")
+	}
+
+	email := claimName(p.connector.Claims.Email, standardAttributes.Email)
+	if claimString(claims, email) == "" && assertion.NameIDFormat == saml.NameIDFormatEmail {
+		claims[email] = assertion.NameID
+	}
+
+	return p.profile(claims, subject, standardAttributes)
+}
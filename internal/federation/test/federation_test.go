@@ -0,0 +1,308 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/ssoydabas/auth-service/internal/federation"
+	"github.com/ssoydabas/auth-service/internal/oauth"
+	"github.com/ssoydabas/auth-service/internal/saml"
+	"github.com/ssoydabas/auth-service/internal/saml/samltest"
+	"github.com/stretchr/testify/suite"
+)
+
+const testClientID = "auth-service"
+
+type FederationTestSuite struct {
+	suite.Suite
+	server   *httptest.Server
+	key      *oauth.SigningKey
+	issuer   string
+	userInfo map[string]any
+}
+
+func (suite *FederationTestSuite) SetupTest() {
+	key, err := oauth.GenerateSigningKey()
+	suite.Require().NoError(err)
+	suite.key = key
+	suite.userInfo = nil
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		metadata := map[string]string{
+			"issuer":                 suite.issuer,
+			"authorization_endpoint": suite.server.URL + "/authorize",
+			"token_endpoint":         suite.server.URL + "/token",
+			"jwks_uri":               suite.server.URL + "/jwks",
+		}
+		if suite.userInfo != nil {
+			metadata["userinfo_endpoint"] = suite.server.URL + "/userinfo"
+		}
+		writeJSON(w, metadata)
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, suite.key.JWKS())
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer upstream-access-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		writeJSON(w, suite.userInfo)
+	})
+	suite.server = httptest.NewServer(mux)
+	suite.issuer = suite.server.URL
+}
+
+func (suite *FederationTestSuite) TearDownTest() {
+	suite.server.Close()
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (suite *FederationTestSuite) provider(connector federation.Connector) *federation.Provider {
+	if connector.Issuer == "" && connector.AuthorizationEndpoint == "" {
+		connector.Issuer = suite.server.URL
+	}
+	connector.ClientID = testClientID
+	if connector.Scopes == nil {
+		connector.Scopes = []string{"openid", "email"}
+	}
+	return federation.NewProvider(connector, suite.server.Client())
+}
+
+func (suite *FederationTestSuite) idToken(claims jwt.MapClaims) string {
+	defaults := jwt.MapClaims{
+		"iss":   suite.server.URL,
+		"aud":   testClientID,
+		"sub":   "teacher-42",
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(5 * time.Minute).Unix(),
+		"nonce": "nonce",
+	}
+	for name, value := range claims {
+		if value == nil {
+			delete(defaults, name)
+			continue
+		}
+		defaults[name] = value
+	}
+	token, err := suite.key.Sign(defaults)
+	suite.Require().NoError(err)
+	return token
+}
+
+func (suite *FederationTestSuite) writeConnectors(content string) string {
+	path := filepath.Join(suite.T().TempDir(), "providers.json")
+	suite.Require().NoError(os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func (suite *FederationTestSuite) TestLoadConnectors() {
+	connectors, err := federation.LoadConnectors("")
+	suite.Require().NoError(err)
+	suite.Empty(connectors)
+
+	connectors, err = federation.LoadConnectors(suite.writeConnectors(`[
+		{"id": "lincoln-high", "issuer": "https://sso.lincoln.example.edu", "client_id": "auth-service"},
+		{"id": "forum", "name": "Forum", "client_id": "auth-service", "scopes": ["read:user"],
+		 "authorization_endpoint": "https://forum.example.com/oauth/authorize",
+		 "token_endpoint": "https://forum.example.com/oauth/token",
+		 "userinfo_endpoint": "https://forum.example.com/api/user",
+		 "claims": {"subject": "id", "name": "display_name"}}
+	]`))
+	suite.Require().NoError(err)
+	suite.Require().Len(connectors, 2)
+	suite.Equal("lincoln-high", connectors[0].Name)
+	suite.Equal([]string{"openid", "profile", "email"}, connectors[0].Scopes)
+	suite.True(connectors[0].OpenID())
+	suite.False(connectors[1].OpenID())
+	suite.Equal("id", connectors[1].Claims.Subject)
+}
+
+func (suite *FederationTestSuite) TestLoadConnectorsRejectsInvalidConnectors() {
+	invalid := map[string]string{
+		"not json":           `{`,
+		"bad id":             `[{"id": "Lincoln High", "issuer": "https://sso.example.edu", "client_id": "a"}]`,
+		"no client":          `[{"id": "lincoln", "issuer": "https://sso.example.edu"}]`,
+		"no issuer":          `[{"id": "lincoln", "client_id": "a"}]`,
+		"http issuer":        `[{"id": "lincoln", "issuer": "http://sso.example.edu", "client_id": "a"}]`,
+		"bad scope":          `[{"id": "lincoln", "issuer": "https://sso.example.edu", "client_id": "a", "scopes": ["open id"]}]`,
+		"oauth2 no userinfo": `[{"id": "forum", "client_id": "a", "scopes": ["read"], "authorization_endpoint": "https://f.example.com/a", "token_endpoint": "https://f.example.com/t"}]`,
+		"duplicate": `[{"id": "lincoln", "issuer": "https://sso.example.edu", "client_id": "a"},
+			{"id": "lincoln", "issuer": "https://sso.example.edu", "client_id": "b"}]`,
+	}
+	for name, content := range invalid {
+		_, err := federation.LoadConnectors(suite.writeConnectors(content))
+		suite.Error(err, name)
+	}
+
+	_, err := federation.LoadConnectors(filepath.Join(suite.T().TempDir(), "missing.json"))
+	suite.Error(err)
+}
+
+func (suite *FederationTestSuite) TestMetadataRequiresMatchingIssuer() {
+	metadata, err := suite.provider(federation.Connector{ID: "lincoln"}).Metadata(context.Background())
+	suite.Require().NoError(err)
+	suite.Equal(suite.server.URL+"/token", metadata.TokenEndpoint)
+
+	suite.issuer = "https://attacker.example.com"
+	_, err = suite.provider(federation.Connector{ID: "lincoln"}).Metadata(context.Background())
+	suite.Error(err)
+}
+
+func (suite *FederationTestSuite) TestVerifyIDToken() {
+	provider := suite.provider(federation.Connector{ID: "lincoln"})
+	now := time.Now()
+
+	claims, err := provider.VerifyIDToken(context.Background(), suite.idToken(nil), "nonce", now)
+	suite.Require().NoError(err)
+	suite.Equal("teacher-42", claims["sub"])
+
+	invalid := map[string]jwt.MapClaims{
+		"wrong audience":   {"aud": "another-client"},
+		"wrong issuer":     {"iss": "https://attacker.example.com"},
+		"expired":          {"exp": now.Add(-5 * time.Minute).Unix()},
+		"no expiry":        {"exp": nil},
+		"wrong nonce":      {"nonce": "replayed"},
+		"no subject":       {"sub": nil},
+		"other azp":        {"aud": []string{testClientID, "another-client"}, "azp": "another-client"},
+		"multiple aud":     {"aud": []string{testClientID, "another-client"}},
+		"issued in future": {"iat": now.Add(time.Hour).Unix()},
+	}
+	for name, override := range invalid {
+		_, err := provider.VerifyIDToken(context.Background(), suite.idToken(override), "nonce", now)
+		suite.Error(err, name)
+	}
+
+	other, err := oauth.GenerateSigningKey()
+	suite.Require().NoError(err)
+	forged, err := other.Sign(jwt.MapClaims{"iss": suite.server.URL, "aud": testClientID, "sub": "teacher-42",
+		"iat": now.Unix(), "exp": now.Add(time.Minute).Unix(), "nonce": "nonce"})
+	suite.Require().NoError(err)
+	_, err = provider.VerifyIDToken(context.Background(), forged, "nonce", now)
+	suite.Error(err, "signed by a key the provider does not publish")
+}
+
+func (suite *FederationTestSuite) TestAuthenticateMergesUserInfo() {
+	suite.userInfo = map[string]any{"sub": "teacher-42", "name": "Ada King Lovelace", "email": "other@example.com", "email_verified": "true"}
+	provider := suite.provider(federation.Connector{ID: "lincoln"})
+
+	profile, err := provider.Authenticate(context.Background(), &federation.Tokens{
+		AccessToken: "upstream-access-token",
+		IDToken:     suite.idToken(jwt.MapClaims{"email": "ada@lincoln.example.edu"}),
+	}, "nonce", time.Now())
+	suite.Require().NoError(err)
+
+	suite.Equal("teacher-42", profile.Subject)
+	suite.Equal("ada@lincoln.example.edu", profile.Email, "claims of the ID token win")
+	suite.True(profile.EmailVerified)
+	suite.Equal("Ada King", profile.FirstName)
+	suite.Equal("Lovelace", profile.LastName)
+
+	suite.userInfo["sub"] = "someone-else"
+	_, err = provider.Authenticate(context.Background(), &federation.Tokens{
+		AccessToken: "upstream-access-token",
+		IDToken:     suite.idToken(nil),
+	}, "nonce", time.Now())
+	suite.Error(err)
+}
+
+func (suite *FederationTestSuite) TestAuthenticateWithOAuth2Provider() {
+	suite.userInfo = map[string]any{"id": 1234, "display_name": "Ada", "mail": "ada@forum.example.com"}
+	provider := suite.provider(federation.Connector{
+		ID:                    "forum",
+		Scopes:                []string{"read:user"},
+		AuthorizationEndpoint: suite.server.URL + "/authorize",
+		TokenEndpoint:         suite.server.URL + "/token",
+		UserInfoEndpoint:      suite.server.URL + "/userinfo",
+		Claims:                federation.ClaimMapping{Subject: "id", Name: "display_name", Email: "mail"},
+	})
+
+	profile, err := provider.Authenticate(context.Background(), &federation.Tokens{AccessToken: "upstream-access-token"}, "", time.Now())
+	suite.Require().NoError(err)
+
+	suite.Equal("1234", profile.Subject)
+	suite.Equal("Ada", profile.FirstName)
+	suite.Equal("ada@forum.example.com", profile.Email)
+	suite.False(profile.EmailVerified, "without trust_email an address is not verified")
+}
+
+func (suite *FederationTestSuite) TestLoadSAMLConnectors() {
+	idp, err := samltest.NewIdentityProvider("https://idp.acme.example.com/saml", "https://idp.acme.example.com/saml/sso")
+	suite.Require().NoError(err)
+	metadataFile := filepath.Join(suite.T().TempDir(), "acme.xml")
+	suite.Require().NoError(os.WriteFile(metadataFile, idp.Metadata(), 0o600))
+
+	connectors, err := federation.LoadConnectors(suite.writeConnectors(`[{"id": "acme", "protocol": "saml", "saml_metadata_file": "` + metadataFile + `"}]`))
+	suite.Require().NoError(err)
+	suite.Require().Len(connectors, 1)
+	suite.Equal(string(idp.Metadata()), connectors[0].SAMLMetadata)
+
+	invalid := map[string]string{
+		"unknown protocol": `[{"id": "acme", "protocol": "ws-fed"}]`,
+		"no metadata":      `[{"id": "acme", "protocol": "saml"}]`,
+		"missing file":     `[{"id": "acme", "protocol": "saml", "saml_metadata_file": "/nonexistent.xml"}]`,
+		"bad metadata":     `[{"id": "acme", "protocol": "saml", "saml_metadata": "<EntityDescriptor/>"}]`,
+	}
+	for name, content := range invalid {
+		_, err := federation.LoadConnectors(suite.writeConnectors(content))
+		suite.Error(err, name)
+	}
+}
+
+func (suite *FederationTestSuite) TestAuthenticateSAML() {
+	idp, err := samltest.NewIdentityProvider("https://idp.acme.example.com/saml", "https://idp.acme.example.com/saml/sso")
+	suite.Require().NoError(err)
+	sp := &saml.ServiceProvider{EntityID: "https://auth.example.com/oauth/federated/acme/metadata", ACSURL: "https://auth.example.com/oauth/federated/acme/acs"}
+	response := func(nameID, format string, attributes map[string]string) string {
+		encoded, err := idp.EncodedResponse(samltest.Response{
+			InResponseTo: "_request-1", Destination: sp.ACSURL, Audience: sp.EntityID,
+			NameID: nameID, NameIDFormat: format, Attributes: attributes, SignAssertion: true,
+		})
+		suite.Require().NoError(err)
+		return encoded
+	}
+	connector := federation.Connector{ID: "acme", Name: "Acme", Protocol: federation.ProtocolSAML, SAMLMetadata: string(idp.Metadata())}
+
+	profile, err := federation.NewProvider(connector, nil).AuthenticateSAML(sp, response("ada@acme.example.com", saml.NameIDFormatEmail, map[string]string{
+		"urn:oid:2.16.840.1.113730.3.1.241": "Ada Lovelace",
+		"urn:oid:0.9.2342.19200300.100.1.3": "",
+	}), "_request-1", time.Now())
+	suite.Require().NoError(err)
+	suite.Equal("ada@acme.example.com", profile.Subject)
+	suite.Equal("ada@acme.example.com", profile.Email, "an email NameID stands in for the attribute")
+	suite.False(profile.EmailVerified, "without trust_email an address is not verified")
+	suite.Equal("Ada", profile.FirstName)
+	suite.Equal("Lovelace", profile.LastName)
+
+	_, err = federation.NewProvider(connector, nil).AuthenticateSAML(sp, response("_t-1", saml.NameIDFormatTransient, nil), "_request-1", time.Now())
+	suite.Error(err, "a transient NameID does not identify the user")
+
+	connector.Claims = federation.ClaimMapping{Subject: "employeeNumber", Email: "mail"}
+	connector.TrustEmail = true
+	profile, err = federation.NewProvider(connector, nil).AuthenticateSAML(sp, response("_t-2", saml.NameIDFormatTransient, map[string]string{
+		"employeeNumber": "E-42",
+		"mail":           "ada@acme.example.com",
+	}), "_request-1", time.Now())
+	suite.Require().NoError(err)
+	suite.Equal("E-42", profile.Subject)
+	suite.True(profile.EmailVerified)
+
+	_, err = federation.NewProvider(connector, nil).AuthenticateSAML(sp, response("u-1", "", nil), "_request-2", time.Now())
+	suite.Error(err, "a response to another request")
+}
+
+func TestFederationSuite(t *testing.T) {
+	suite.Run(t, new(FederationTestSuite))
+}
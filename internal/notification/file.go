@@ -0,0 +1,46 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// fileNotifier appends every message as a JSON line. It is meant for local
+// development, where reading the file replaces checking a mailbox.
+type fileNotifier struct {
+	mu   sync.Mutex
+	path string
+}
+
+type fileRecord struct {
+	SentAt time.Time `json:"sent_at"`
+	Message
+}
+
+func NewFileNotifier(path string) Notifier {
+	return &fileNotifier{
+		path: path,
+	}
+}
+
+func (n *fileNotifier) Send(ctx context.Context, msg Message) error {
+	line, err := json.Marshal(fileRecord{SentAt: time.Now().UTC(), Message: msg})
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	f, err := os.OpenFile(n.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
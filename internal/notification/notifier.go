@@ -0,0 +1,59 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ssoydabas/auth-service/pkg/config"
+)
+
+type MessageKind string
+
+const (
+	KindEmailVerification MessageKind = "email_verification"
+	KindPasswordReset     MessageKind = "password_reset"
+	KindMagicLink         MessageKind = "magic_link"
+	KindSecurityAlert     MessageKind = "security_alert"
+	KindDataExport        MessageKind = "data_export"
+)
+
+// Message is a single outgoing notification. HTML is optional; transports
+// that cannot render it fall back to Text.
+type Message struct {
+	Kind     MessageKind       `json:"kind"`
+	To       string            `json:"to"`
+	Subject  string            `json:"subject"`
+	Text     string            `json:"text"`
+	HTML     string            `json:"html,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+type Notifier interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+const (
+	TransportSMTP   = "smtp"
+	TransportFile   = "file"
+	TransportMemory = "memory"
+)
+
+// NewNotifier builds the transport selected by NOTIFIER_TRANSPORT.
+func NewNotifier(cfg config.Config) (Notifier, error) {
+	switch cfg.NotifierTransport {
+	case TransportSMTP:
+		return NewSMTPNotifier(SMTPConfig{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.SMTPFrom,
+		}), nil
+	case TransportFile:
+		return NewFileNotifier(cfg.NotifierFilePath), nil
+	case TransportMemory:
+		return NewMemoryNotifier(), nil
+	default:
+		return nil, fmt.Errorf("unknown notifier transport: %q", cfg.NotifierTransport)
+	}
+}
@@ -0,0 +1,329 @@
+package notification
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+
+	"golang.org/x/text/language"
+)
+
+//go:embed templates
+var embeddedTemplates embed.FS
+
+const DefaultLocale = "en"
+
+// TemplateData is the data every notification template is rendered with.
+// Fields that do not apply to a message kind are left empty.
+type TemplateData struct {
+	Brand     string
+	Locale    string
+	FirstName string
+	LastName  string
+	Email     string
+	Token     string
+	Link      string
+	Event     string
+	Expires   string
+}
+
+type Content struct {
+	Subject string `json:"subject"`
+	Text    string `json:"text"`
+	HTML    string `json:"html,omitempty"`
+}
+
+type templateKey struct {
+	kind   MessageKind
+	locale string
+}
+
+type templateSet struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// Renderer renders notification templates. Templates live under
+// <kind>/<locale>.txt and <kind>/<locale>.html; the text template must
+// define a "subject" block. Files in the override directory replace the
+// embedded defaults with the same path and may add new locales.
+type Renderer struct {
+	brand   string
+	sets    map[templateKey]templateSet
+	matcher language.Matcher
+	locales []string
+}
+
+func NewRenderer(overrideDir, brand string) (*Renderer, error) {
+	base, err := fs.Sub(embeddedTemplates, "templates")
+	if err != nil {
+		return nil, err
+	}
+
+	fsys := fs.FS(base)
+	if overrideDir != "" {
+		if _, err := os.Stat(overrideDir); err != nil {
+			return nil, fmt.Errorf("notification template directory: %w", err)
+		}
+		fsys = overlayFS{override: os.DirFS(overrideDir), base: base}
+	}
+
+	r := &Renderer{
+		brand: brand,
+		sets:  make(map[templateKey]templateSet),
+	}
+
+	keys, err := discoverTemplates(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	layout, err := fs.ReadFile(fsys, "layout.html")
+	if err != nil {
+		return nil, err
+	}
+
+	localeSeen := make(map[string]bool)
+	for _, key := range keys {
+		set, err := parseTemplateSet(fsys, key, string(layout))
+		if err != nil {
+			return nil, err
+		}
+		r.sets[key] = set
+		localeSeen[key.locale] = true
+	}
+
+	if !localeSeen[DefaultLocale] {
+		return nil, fmt.Errorf("notification templates: default locale %q is missing", DefaultLocale)
+	}
+
+	// The default locale goes first so the matcher falls back to it.
+	r.locales = append(r.locales, DefaultLocale)
+	for locale := range localeSeen {
+		if locale != DefaultLocale {
+			r.locales = append(r.locales, locale)
+		}
+	}
+	sort.Strings(r.locales[1:])
+
+	tags := make([]language.Tag, 0, len(r.locales))
+	for _, locale := range r.locales {
+		tags = append(tags, language.Make(locale))
+	}
+	r.matcher = language.NewMatcher(tags)
+
+	return r, nil
+}
+
+func discoverTemplates(fsys fs.FS) ([]templateKey, error) {
+	matches, err := fs.Glob(fsys, "*/*.txt")
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]templateKey, 0, len(matches))
+	for _, match := range matches {
+		kind, file := path.Split(match)
+		keys = append(keys, templateKey{
+			kind:   MessageKind(strings.TrimSuffix(kind, "/")),
+			locale: strings.TrimSuffix(file, ".txt"),
+		})
+	}
+	return keys, nil
+}
+
+func parseTemplateSet(fsys fs.FS, key templateKey, layout string) (templateSet, error) {
+	name := path.Join(string(key.kind), key.locale)
+
+	text, err := fs.ReadFile(fsys, name+".txt")
+	if err != nil {
+		return templateSet{}, err
+	}
+
+	var set templateSet
+	set.text, err = texttemplate.New("body").Parse(string(text))
+	if err != nil {
+		return templateSet{}, fmt.Errorf("%s.txt: %w", name, err)
+	}
+	if set.text.Lookup("subject") == nil {
+		return templateSet{}, fmt.Errorf("%s.txt: missing subject block", name)
+	}
+
+	html, err := fs.ReadFile(fsys, name+".html")
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return templateSet{}, err
+		}
+		return set, nil
+	}
+
+	set.html, err = htmltemplate.New("layout").Parse(layout)
+	if err != nil {
+		return templateSet{}, fmt.Errorf("layout.html: %w", err)
+	}
+	if _, err := set.html.New("content").Parse(string(html)); err != nil {
+		return templateSet{}, fmt.Errorf("%s.html: %w", name, err)
+	}
+
+	return set, nil
+}
+
+// HasKind reports whether templates exist for kind in the default locale.
+func (r *Renderer) HasKind(kind MessageKind) bool {
+	_, ok := r.sets[templateKey{kind: kind, locale: DefaultLocale}]
+	return ok
+}
+
+// Locales returns the locales that have at least one template, default first.
+func (r *Renderer) Locales() []string {
+	return append([]string(nil), r.locales...)
+}
+
+// ResolveLocale picks the best supported locale. Each candidate may be a
+// single tag or an Accept-Language header value; the first candidate that
+// yields a confident match wins.
+func (r *Renderer) ResolveLocale(candidates ...string) string {
+	for _, candidate := range candidates {
+		if candidate == "" {
+			continue
+		}
+
+		tags, _, err := language.ParseAcceptLanguage(candidate)
+		if err != nil || len(tags) == 0 {
+			continue
+		}
+
+		_, index, confidence := r.matcher.Match(tags...)
+		if confidence != language.No {
+			return r.locales[index]
+		}
+	}
+	return DefaultLocale
+}
+
+// Render renders the template for kind in locale, falling back to the
+// default locale when the kind has no translation.
+func (r *Renderer) Render(kind MessageKind, locale string, data TemplateData) (Content, error) {
+	set, ok := r.sets[templateKey{kind: kind, locale: locale}]
+	if !ok {
+		locale = DefaultLocale
+		set, ok = r.sets[templateKey{kind: kind, locale: locale}]
+		if !ok {
+			return Content{}, fmt.Errorf("no template for notification kind %q", kind)
+		}
+	}
+
+	data.Locale = locale
+	if data.Brand == "" {
+		data.Brand = r.brand
+	}
+
+	var subject, text bytes.Buffer
+	if err := set.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Content{}, err
+	}
+	if err := set.text.Execute(&text, data); err != nil {
+		return Content{}, err
+	}
+
+	content := Content{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+	}
+
+	if set.html != nil {
+		var html bytes.Buffer
+		if err := set.html.ExecuteTemplate(&html, "layout", data); err != nil {
+			return Content{}, err
+		}
+		content.HTML = html.String()
+	}
+
+	return content, nil
+}
+
+// Message renders kind and addresses the result to `to`.
+func (r *Renderer) Message(kind MessageKind, locale, to string, data TemplateData) (Message, error) {
+	content, err := r.Render(kind, locale, data)
+	if err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		Kind:     kind,
+		To:       to,
+		Subject:  content.Subject,
+		Text:     content.Text,
+		HTML:     content.HTML,
+		Metadata: map[string]string{"locale": locale},
+	}, nil
+}
+
+// SampleData returns placeholder data used to preview templates.
+func SampleData(kind MessageKind) TemplateData {
+	data := TemplateData{
+		FirstName: "Jane",
+		LastName:  "Doe",
+		Email:     "jane.doe@example.com",
+	}
+
+	switch kind {
+	case KindEmailVerification, KindPasswordReset:
+		data.Token = "00000000-0000-0000-0000-000000000000"
+	case KindMagicLink:
+		data.Link = "https://example.com/magic-link?token=sample"
+	case KindSecurityAlert:
+		data.Event = "password_changed"
+	case KindDataExport:
+		data.Link = "https://example.com/api/v1/exports/sample/download?expires=0&signature=sample"
+		data.Expires = "2025-01-02T15:04:05Z"
+	}
+
+	return data
+}
+
+// overlayFS serves files from override when present, otherwise from base.
+type overlayFS struct {
+	override fs.FS
+	base     fs.FS
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	f, err := o.override.Open(name)
+	if err == nil {
+		return f, nil
+	}
+	return o.base.Open(name)
+}
+
+func (o overlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries := make(map[string]fs.DirEntry)
+	for _, fsys := range []fs.FS{o.base, o.override} {
+		dirEntries, err := fs.ReadDir(fsys, name)
+		if err != nil {
+			continue
+		}
+		for _, entry := range dirEntries {
+			entries[entry.Name()] = entry
+		}
+	}
+
+	if len(entries) == 0 {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	result := make([]fs.DirEntry, 0, len(entries))
+	for _, entry := range entries {
+		result = append(result, entry)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name() < result[j].Name() })
+	return result, nil
+}
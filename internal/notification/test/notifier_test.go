@@ -0,0 +1,211 @@
+package notification
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ssoydabas/auth-service/internal/notification"
+	"github.com/stretchr/testify/suite"
+)
+
+// smtpServer is a minimal in-process SMTP server that records every
+// message it receives.
+type smtpServer struct {
+	listener net.Listener
+	mu       sync.Mutex
+	messages []receivedMail
+}
+
+type receivedMail struct {
+	from string
+	to   []string
+	data string
+}
+
+func newSMTPServer() (*smtpServer, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &smtpServer{listener: listener}
+	go s.serve()
+	return s, nil
+}
+
+func (s *smtpServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *smtpServer) handle(conn net.Conn) {
+	defer conn.Close()
+
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 localhost ESMTP test")
+
+	var mail receivedMail
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch verb {
+		case "EHLO", "HELO":
+			tp.PrintfLine("250 localhost")
+		case "MAIL":
+			mail.from = strings.TrimPrefix(line, "MAIL FROM:")
+			tp.PrintfLine("250 OK")
+		case "RCPT":
+			mail.to = append(mail.to, strings.TrimPrefix(line, "RCPT TO:"))
+			tp.PrintfLine("250 OK")
+		case "DATA":
+			tp.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			mail.data = string(data)
+			s.mu.Lock()
+			s.messages = append(s.messages, mail)
+			s.mu.Unlock()
+			mail = receivedMail{}
+			tp.PrintfLine("250 OK")
+		case "QUIT":
+			tp.PrintfLine("221 Bye")
+			return
+		default:
+			tp.PrintfLine("502 Command not implemented")
+		}
+	}
+}
+
+func (s *smtpServer) received() []receivedMail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]receivedMail(nil), s.messages...)
+}
+
+type NotifierTestSuite struct {
+	suite.Suite
+	ctx context.Context
+}
+
+func (suite *NotifierTestSuite) SetupTest() {
+	suite.ctx = context.Background()
+}
+
+func (suite *NotifierTestSuite) testMessage() notification.Message {
+	return notification.Message{
+		Kind:    notification.KindEmailVerification,
+		To:      "test@example.com",
+		Subject: "Verify your email address",
+		Text:    "token: abc123",
+		HTML:    "<p>token: <b>abc123</b></p>",
+	}
+}
+
+func (suite *NotifierTestSuite) TestSMTPNotifier() {
+	server, err := newSMTPServer()
+	suite.Require().NoError(err)
+	defer server.listener.Close()
+
+	host, portStr, err := net.SplitHostPort(server.listener.Addr().String())
+	suite.Require().NoError(err)
+	port, err := strconv.Atoi(portStr)
+	suite.Require().NoError(err)
+
+	notifier := notification.NewSMTPNotifier(notification.SMTPConfig{
+		Host: host,
+		Port: port,
+		From: "no-reply@example.com",
+	})
+
+	suite.Require().NoError(notifier.Send(suite.ctx, suite.testMessage()))
+
+	received := server.received()
+	suite.Require().Len(received, 1)
+	suite.Contains(received[0].from, "no-reply@example.com")
+	suite.Require().Len(received[0].to, 1)
+	suite.Contains(received[0].to[0], "test@example.com")
+	suite.Contains(received[0].data, "Subject: Verify your email address")
+	suite.Contains(received[0].data, "multipart/alternative")
+	suite.Contains(received[0].data, "token: abc123")
+	suite.Contains(received[0].data, "<b>abc123</b>")
+}
+
+func (suite *NotifierTestSuite) TestSMTPNotifierConnectionRefused() {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	suite.Require().NoError(err)
+	addr := listener.Addr().(*net.TCPAddr)
+	listener.Close()
+
+	notifier := notification.NewSMTPNotifier(notification.SMTPConfig{
+		Host: "127.0.0.1",
+		Port: addr.Port,
+		From: "no-reply@example.com",
+	})
+
+	suite.Error(notifier.Send(suite.ctx, suite.testMessage()))
+}
+
+func (suite *NotifierTestSuite) TestFileNotifier() {
+	path := filepath.Join(suite.T().TempDir(), "notifications.jsonl")
+	notifier := notification.NewFileNotifier(path)
+
+	suite.Require().NoError(notifier.Send(suite.ctx, suite.testMessage()))
+	suite.Require().NoError(notifier.Send(suite.ctx, suite.testMessage()))
+
+	f, err := os.Open(path)
+	suite.Require().NoError(err)
+	defer f.Close()
+
+	lines := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var msg notification.Message
+		suite.Require().NoError(json.Unmarshal(scanner.Bytes(), &msg))
+		suite.Equal(notification.KindEmailVerification, msg.Kind)
+		suite.Equal("test@example.com", msg.To)
+		lines++
+	}
+	suite.Equal(2, lines)
+}
+
+func (suite *NotifierTestSuite) TestMemoryNotifier() {
+	notifier := notification.NewMemoryNotifier()
+
+	_, ok := notifier.Last("test@example.com")
+	suite.False(ok)
+
+	suite.Require().NoError(notifier.Send(suite.ctx, suite.testMessage()))
+
+	msg, ok := notifier.Last("test@example.com")
+	suite.True(ok)
+	suite.Equal("abc123", strings.TrimPrefix(msg.Text, "token: "))
+	suite.Len(notifier.Messages(), 1)
+
+	notifier.Reset()
+	suite.Empty(notifier.Messages())
+}
+
+func TestNotifierSuite(t *testing.T) {
+	suite.Run(t, new(NotifierTestSuite))
+}
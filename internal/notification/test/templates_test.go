@@ -0,0 +1,129 @@
+package notification
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ssoydabas/auth-service/internal/notification"
+	"github.com/stretchr/testify/suite"
+)
+
+type TemplatesTestSuite struct {
+	suite.Suite
+	renderer *notification.Renderer
+}
+
+func (suite *TemplatesTestSuite) SetupTest() {
+	renderer, err := notification.NewRenderer("", "Acme")
+	suite.Require().NoError(err)
+	suite.renderer = renderer
+}
+
+func (suite *TemplatesTestSuite) TestEmbeddedTemplatesRender() {
+	kinds := []notification.MessageKind{
+		notification.KindEmailVerification,
+		notification.KindPasswordReset,
+		notification.KindMagicLink,
+		notification.KindSecurityAlert,
+		notification.KindDataExport,
+	}
+
+	for _, locale := range suite.renderer.Locales() {
+		for _, kind := range kinds {
+			suite.Run(string(kind)+"/"+locale, func() {
+				content, err := suite.renderer.Render(kind, locale, notification.SampleData(kind))
+				suite.Require().NoError(err)
+				suite.NotEmpty(content.Subject)
+				suite.Contains(content.Text, "Jane")
+				suite.Contains(content.Text, "Acme")
+				suite.Contains(content.HTML, "<html lang=\""+locale+"\">")
+				suite.Contains(content.HTML, "Acme")
+			})
+		}
+	}
+}
+
+func (suite *TemplatesTestSuite) TestHTMLIsEscaped() {
+	data := notification.SampleData(notification.KindSecurityAlert)
+	data.FirstName = "<script>alert(1)</script>"
+
+	content, err := suite.renderer.Render(notification.KindSecurityAlert, "en", data)
+	suite.Require().NoError(err)
+	suite.NotContains(content.HTML, "<script>")
+	suite.Contains(content.HTML, "&lt;script&gt;")
+}
+
+func (suite *TemplatesTestSuite) TestResolveLocale() {
+	tests := []struct {
+		name       string
+		candidates []string
+		expected   string
+	}{
+		{name: "no candidates", expected: "en"},
+		{name: "exact match", candidates: []string{"tr"}, expected: "tr"},
+		{name: "regional variant", candidates: []string{"tr-TR"}, expected: "tr"},
+		{name: "first candidate wins", candidates: []string{"en", "tr"}, expected: "en"},
+		{name: "empty candidate is skipped", candidates: []string{"", "tr"}, expected: "tr"},
+		{name: "accept-language header", candidates: []string{"de-DE,tr;q=0.8"}, expected: "tr"},
+		{name: "unsupported locale", candidates: []string{"ja"}, expected: "en"},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			suite.Equal(tt.expected, suite.renderer.ResolveLocale(tt.candidates...))
+		})
+	}
+}
+
+func (suite *TemplatesTestSuite) TestUnknownKind() {
+	suite.False(suite.renderer.HasKind("unknown"))
+
+	_, err := suite.renderer.Render("unknown", "en", notification.TemplateData{})
+	suite.Error(err)
+}
+
+func (suite *TemplatesTestSuite) TestOverrideDirectory() {
+	dir := suite.T().TempDir()
+	suite.Require().NoError(os.MkdirAll(filepath.Join(dir, "password_reset"), 0o755))
+	suite.Require().NoError(os.WriteFile(filepath.Join(dir, "password_reset", "en.txt"),
+		[]byte(`{{define "subject"}}Custom reset{{end}}Custom body {{.Token}}`), 0o644))
+	suite.Require().NoError(os.WriteFile(filepath.Join(dir, "password_reset", "de.txt"),
+		[]byte(`{{define "subject"}}Passwort zurücksetzen{{end}}Hallo {{.FirstName}}`), 0o644))
+
+	renderer, err := notification.NewRenderer(dir, "Acme")
+	suite.Require().NoError(err)
+
+	content, err := renderer.Render(notification.KindPasswordReset, "en", notification.TemplateData{Token: "abc"})
+	suite.Require().NoError(err)
+	suite.Equal("Custom reset", content.Subject)
+	suite.Equal("Custom body abc\n", content.Text)
+
+	suite.Equal("de", renderer.ResolveLocale("de-DE"))
+	content, err = renderer.Render(notification.KindPasswordReset, "de", notification.TemplateData{FirstName: "Jane"})
+	suite.Require().NoError(err)
+	suite.Equal("Passwort zurücksetzen", content.Subject)
+	suite.Empty(content.HTML)
+
+	// Kinds without a German template fall back to the default locale.
+	content, err = renderer.Render(notification.KindEmailVerification, "de", notification.SampleData(notification.KindEmailVerification))
+	suite.Require().NoError(err)
+	suite.Equal("Verify your email address", content.Subject)
+}
+
+func (suite *TemplatesTestSuite) TestInvalidOverride() {
+	dir := suite.T().TempDir()
+	suite.Require().NoError(os.MkdirAll(filepath.Join(dir, "password_reset"), 0o755))
+	suite.Require().NoError(os.WriteFile(filepath.Join(dir, "password_reset", "en.txt"),
+		[]byte(`no subject block`), 0o644))
+
+	_, err := notification.NewRenderer(dir, "Acme")
+	suite.Error(err)
+
+	_, err = notification.NewRenderer(filepath.Join(dir, "missing"), "Acme")
+	suite.Error(err)
+}
+
+func TestTemplatesSuite(t *testing.T) {
+	suite.Run(t, new(TemplatesTestSuite))
+}
@@ -0,0 +1,64 @@
+package oauth
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// MaxAssertionLifetime bounds how far in the future a client assertion may
+// expire. Used assertion IDs are remembered until then to stop replays.
+const MaxAssertionLifetime = 10 * time.Minute
+
+// ClientAssertion is a verified private_key_jwt client assertion (RFC 7523).
+type ClientAssertion struct {
+	ClientID  string
+	JTI       string
+	ExpiresAt time.Time
+}
+
+// AssertionClientID returns the client an assertion claims to come from,
+// without verifying it, so that the client's keys can be looked up.
+func AssertionClientID(assertion string) (string, error) {
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(assertion, claims); err != nil {
+		return "", err
+	}
+
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		return "", errors.New("assertion has no sub")
+	}
+	return sub, nil
+}
+
+// VerifyClientAssertion checks an assertion signed with one of keys. iss and
+// sub must both be the client ID, aud must name one of audiences, and jti
+// and a near exp are required.
+func VerifyClientAssertion(assertion, clientID string, keys JWKS, audiences []string, now time.Time) (*ClientAssertion, error) {
+	var claims jwt.RegisteredClaims
+	_, err := jwt.ParseWithClaims(assertion, &claims, keys.Keyfunc,
+		jwt.WithValidMethods([]string{SigningAlgorithm}),
+		jwt.WithTimeFunc(func() time.Time { return now }),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuer(clientID),
+		jwt.WithSubject(clientID),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if !slices.ContainsFunc(audiences, func(audience string) bool { return slices.Contains(claims.Audience, audience) }) {
+		return nil, errors.New("assertion is not intended for this server")
+	}
+	if claims.ID == "" {
+		return nil, errors.New("assertion has no jti")
+	}
+	if claims.ExpiresAt.Time.After(now.Add(MaxAssertionLifetime)) {
+		return nil, errors.New("assertion expires too far in the future")
+	}
+
+	return &ClientAssertion{ClientID: clientID, JTI: claims.ID, ExpiresAt: claims.ExpiresAt.Time}, nil
+}
@@ -0,0 +1,61 @@
+package oauth
+
+import (
+	"crypto/rand"
+	"math/big"
+	"strings"
+)
+
+// userCodeAlphabet leaves out vowels, so user codes never spell words, and
+// characters that are easily confused (RFC 8628 section 6.1).
+const userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+
+const userCodeLength = 8
+
+// NewUserCode returns a random user code of the device authorization grant
+// in its canonical form, such as "WDJBMJHT". Users are shown it split in
+// two halves by FormatUserCode.
+func NewUserCode() (string, error) {
+	max := big.NewInt(int64(len(userCodeAlphabet)))
+
+	var code strings.Builder
+	for range userCodeLength {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code.WriteByte(userCodeAlphabet[n.Int64()])
+	}
+	return code.String(), nil
+}
+
+// FormatUserCode splits a canonical user code for display, e.g.
+// "WDJB-MJHT".
+func FormatUserCode(code string) string {
+	if len(code) != userCodeLength {
+		return code
+	}
+	return code[:userCodeLength/2] + "-" + code[userCodeLength/2:]
+}
+
+// NormalizeUserCode turns what a user typed into the canonical form of a
+// user code. Case, dashes and spaces are ignored. It reports false when the
+// input cannot be a user code.
+func NormalizeUserCode(input string) (string, bool) {
+	var code strings.Builder
+	for _, r := range strings.ToUpper(input) {
+		switch {
+		case r == '-' || r == ' ':
+			continue
+		case strings.ContainsRune(userCodeAlphabet, r):
+			code.WriteRune(r)
+		default:
+			return "", false
+		}
+	}
+
+	if code.Len() != userCodeLength {
+		return "", false
+	}
+	return code.String(), true
+}
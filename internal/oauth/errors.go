@@ -0,0 +1,159 @@
+// Package oauth holds the protocol rules of the OAuth 2.0 authorization
+// server: error codes, PKCE, redirect URI matching and scopes. The flows
+// themselves live in the service package.
+package oauth
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// Error codes from RFC 6749 section 4.1.2.1 and 5.2, OpenID Connect Core
+// section 3.1.2.6, RFC 6750 section 3.1, RFC 8628 section 3.5, RFC 8693
+// section 2.2.2 and RFC 7591 section 3.2.2.
+const (
+	ErrorInvalidRequest          = "invalid_request"
+	ErrorInvalidClient           = "invalid_client"
+	ErrorInvalidGrant            = "invalid_grant"
+	ErrorUnauthorizedClient      = "unauthorized_client"
+	ErrorUnsupportedGrantType    = "unsupported_grant_type"
+	ErrorUnsupportedResponseType = "unsupported_response_type"
+	ErrorInvalidScope            = "invalid_scope"
+	ErrorAccessDenied            = "access_denied"
+	ErrorServerError             = "server_error"
+	ErrorLoginRequired           = "login_required"
+	ErrorConsentRequired         = "consent_required"
+	ErrorInvalidToken            = "invalid_token"
+	ErrorInsufficientScope       = "insufficient_scope"
+	ErrorAuthorizationPending    = "authorization_pending"
+	ErrorSlowDown                = "slow_down"
+	ErrorExpiredToken            = "expired_token"
+	ErrorInvalidTarget           = "invalid_target"
+	ErrorInvalidRedirectURI      = "invalid_redirect_uri"
+	ErrorInvalidClientMetadata   = "invalid_client_metadata"
+)
+
+// Error is an OAuth error response. When RedirectURI is set the error is
+// returned to the client by redirecting the browser there, carrying State;
+// otherwise it is shown to the user or, at the token endpoint, returned as
+// JSON with Status.
+type Error struct {
+	Code        string
+	Description string
+	Status      int
+	RedirectURI string
+	State       string
+}
+
+func (e *Error) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Description
+}
+
+// Redirectable reports whether the error is returned to the client through
+// its redirect URI.
+func (e *Error) Redirectable() bool {
+	return e.RedirectURI != ""
+}
+
+// WithRedirect returns a copy of e that is returned to redirectURI.
+func (e *Error) WithRedirect(redirectURI, state string) *Error {
+	redirected := *e
+	redirected.RedirectURI = redirectURI
+	redirected.State = state
+	return &redirected
+}
+
+func newError(code, description string, status int) *Error {
+	return &Error{Code: code, Description: description, Status: status}
+}
+
+func InvalidRequest(description string) *Error {
+	return newError(ErrorInvalidRequest, description, http.StatusBadRequest)
+}
+
+func InvalidClient(description string) *Error {
+	return newError(ErrorInvalidClient, description, http.StatusUnauthorized)
+}
+
+func InvalidGrant(description string) *Error {
+	return newError(ErrorInvalidGrant, description, http.StatusBadRequest)
+}
+
+func UnauthorizedClient(description string) *Error {
+	return newError(ErrorUnauthorizedClient, description, http.StatusBadRequest)
+}
+
+func UnsupportedGrantType(description string) *Error {
+	return newError(ErrorUnsupportedGrantType, description, http.StatusBadRequest)
+}
+
+func UnsupportedResponseType(description string) *Error {
+	return newError(ErrorUnsupportedResponseType, description, http.StatusBadRequest)
+}
+
+func InvalidScope(description string) *Error {
+	return newError(ErrorInvalidScope, description, http.StatusBadRequest)
+}
+
+func AccessDenied(description string) *Error {
+	return newError(ErrorAccessDenied, description, http.StatusBadRequest)
+}
+
+func LoginRequired(description string) *Error {
+	return newError(ErrorLoginRequired, description, http.StatusBadRequest)
+}
+
+func ConsentRequired(description string) *Error {
+	return newError(ErrorConsentRequired, description, http.StatusBadRequest)
+}
+
+func InvalidToken(description string) *Error {
+	return newError(ErrorInvalidToken, description, http.StatusUnauthorized)
+}
+
+func InsufficientScope(description string) *Error {
+	return newError(ErrorInsufficientScope, description, http.StatusForbidden)
+}
+
+func AuthorizationPending(description string) *Error {
+	return newError(ErrorAuthorizationPending, description, http.StatusBadRequest)
+}
+
+func SlowDown(description string) *Error {
+	return newError(ErrorSlowDown, description, http.StatusBadRequest)
+}
+
+func ExpiredToken(description string) *Error {
+	return newError(ErrorExpiredToken, description, http.StatusBadRequest)
+}
+
+func InvalidTarget(description string) *Error {
+	return newError(ErrorInvalidTarget, description, http.StatusBadRequest)
+}
+
+func InvalidRedirectURI(description string) *Error {
+	return newError(ErrorInvalidRedirectURI, description, http.StatusBadRequest)
+}
+
+func InvalidClientMetadata(description string) *Error {
+	return newError(ErrorInvalidClientMetadata, description, http.StatusBadRequest)
+}
+
+func ServerError(description string) *Error {
+	return newError(ErrorServerError, description, http.StatusInternalServerError)
+}
+
+// RedirectURL returns the URL that hands e to the client. issuer is added
+// as the iss parameter so the client can tell which authorization server
+// answered (RFC 9207).
+func (e *Error) RedirectURL(issuer string) string {
+	return AppendQuery(e.RedirectURI, url.Values{
+		"error":             {e.Code},
+		"error_description": {e.Description},
+		"state":             {e.State},
+		"iss":               {issuer},
+	})
+}
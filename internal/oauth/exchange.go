@@ -0,0 +1,37 @@
+package oauth
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ExchangePolicy lists, per client ID, the audiences the client may obtain
+// tokens for with the token exchange grant. Clients it does not name may not
+// exchange tokens at all.
+type ExchangePolicy map[string][]string
+
+// NewExchangePolicy builds a policy from configuration rules that map a
+// client ID to a space-separated list of audiences, e.g.
+// {"orders-service": "inventory-api billing-api"}.
+func NewExchangePolicy(rules map[string]string) (ExchangePolicy, error) {
+	policy := ExchangePolicy{}
+	for clientID, audiences := range rules {
+		clientID = strings.TrimSpace(clientID)
+		if clientID == "" {
+			return nil, fmt.Errorf("token exchange rule without a client ID")
+		}
+
+		fields := strings.Fields(audiences)
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("token exchange rule for %q lists no audiences", clientID)
+		}
+		policy[clientID] = fields
+	}
+	return policy, nil
+}
+
+// Allows reports whether clientID may exchange tokens for audience.
+func (p ExchangePolicy) Allows(clientID, audience string) bool {
+	return slices.Contains(p[clientID], audience)
+}
@@ -0,0 +1,56 @@
+package oauth
+
+// Grant and response types understood by the authorization server.
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantRefreshToken      = "refresh_token"
+	GrantClientCredentials = "client_credentials"
+	GrantDeviceCode        = "urn:ietf:params:oauth:grant-type:device_code"
+	GrantTokenExchange     = "urn:ietf:params:oauth:grant-type:token-exchange"
+
+	ResponseTypeCode = "code"
+
+	TokenTypeBearer = "Bearer"
+)
+
+// Scopes defined by OpenID Connect. openid asks for an ID token; the others
+// select the userinfo claims that are released.
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+	ScopePhone   = "phone"
+)
+
+// Authentication method references (RFC 8176) recorded in sessions and
+// ID tokens. AMRFederated marks a sign-in at an upstream identity provider,
+// whose own methods are not known.
+const (
+	AMRPassword  = "pwd"
+	AMRFederated = "fed"
+)
+
+// Values of the prompt parameter of OpenID Connect authorization requests.
+const (
+	PromptNone    = "none"
+	PromptLogin   = "login"
+	PromptConsent = "consent"
+)
+
+// Client authentication methods at the token endpoint (RFC 7591 section
+// 2). Clients registered with none are public; the others hold a secret or
+// a private key.
+const (
+	AuthMethodNone              = "none"
+	AuthMethodClientSecretBasic = "client_secret_basic"
+	AuthMethodClientSecretPost  = "client_secret_post"
+	AuthMethodPrivateKeyJWT     = "private_key_jwt"
+)
+
+// TokenTypeAccessToken identifies access tokens in token exchange requests
+// and responses (RFC 8693 section 3).
+const TokenTypeAccessToken = "urn:ietf:params:oauth:token-type:access_token"
+
+// ClientAssertionTypeJWTBearer is the client_assertion_type of private_key_jwt
+// authentication (RFC 7523 section 2.2).
+const ClientAssertionTypeJWTBearer = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
@@ -0,0 +1,201 @@
+package oauth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const SigningAlgorithm = "RS256"
+
+// SigningKey signs the tokens that other parties verify, such as ID tokens,
+// with an RSA key published in the JWKS document. Its key ID is the RFC 7638
+// thumbprint of the public key.
+type SigningKey struct {
+	key *rsa.PrivateKey
+	kid string
+}
+
+// JWK is a public key in the JWKS document (RFC 7517).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	Modulus   string `json:"n"`
+	Exponent  string `json:"e"`
+}
+
+// JWKS is a JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+func NewSigningKey(key *rsa.PrivateKey) *SigningKey {
+	return &SigningKey{key: key, kid: thumbprint(&key.PublicKey)}
+}
+
+// LoadSigningKey reads a PEM encoded RSA private key in PKCS #1 or PKCS #8
+// form.
+func LoadSigningKey(path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data", path)
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return NewSigningKey(key), nil
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New(path + ": not an RSA private key")
+	}
+	return NewSigningKey(key), nil
+}
+
+// GenerateSigningKey creates a new 2048-bit key. Tokens signed with it stop
+// verifying once the process exits, so it is only meant for development.
+func GenerateSigningKey() (*SigningKey, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	return NewSigningKey(key), nil
+}
+
+func (k *SigningKey) KeyID() string {
+	return k.kid
+}
+
+func (k *SigningKey) PublicKey() *rsa.PublicKey {
+	return &k.key.PublicKey
+}
+
+// Sign returns claims as a signed JWT carrying the key ID.
+func (k *SigningKey) Sign(claims jwt.Claims) (string, error) {
+	return k.SignType(claims, "JWT")
+}
+
+// SignType is Sign with the typ header set to typ, for tokens that must not
+// be mistaken for other JWTs signed with the same key.
+func (k *SigningKey) SignType(claims jwt.Claims, typ string) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = k.kid
+	token.Header["typ"] = typ
+	return token.SignedString(k.key)
+}
+
+// JWKS returns the key set that verifies tokens signed with k.
+func (k *SigningKey) JWKS() JWKS {
+	return JWKS{Keys: []JWK{{
+		KeyType:   "RSA",
+		Use:       "sig",
+		Algorithm: SigningAlgorithm,
+		KeyID:     k.kid,
+		Modulus:   base64.RawURLEncoding.EncodeToString(k.key.N.Bytes()),
+		Exponent:  base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.key.E)).Bytes()),
+	}}}
+}
+
+// RSAPublicKey decodes the key, which must be an RSA key.
+func (k JWK) RSAPublicKey() (*rsa.PublicKey, error) {
+	if k.KeyType != "RSA" {
+		return nil, fmt.Errorf("unsupported key type %q", k.KeyType)
+	}
+
+	n, err := base64.RawURLEncoding.DecodeString(k.Modulus)
+	if err != nil || len(n) == 0 {
+		return nil, errors.New("invalid RSA modulus")
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.Exponent)
+	if err != nil || len(e) == 0 || len(e) > 4 {
+		return nil, errors.New("invalid RSA exponent")
+	}
+
+	key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	if key.N.BitLen() < 2048 {
+		return nil, errors.New("RSA keys must be at least 2048 bits")
+	}
+	return key, nil
+}
+
+// Validate checks that the set holds at least one key and that every key
+// can verify signatures.
+func (s JWKS) Validate() error {
+	if len(s.Keys) == 0 {
+		return errors.New("the key set is empty")
+	}
+	for _, key := range s.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			return fmt.Errorf("key %q is not a signing key", key.KeyID)
+		}
+		if _, err := key.RSAPublicKey(); err != nil {
+			return fmt.Errorf("key %q: %w", key.KeyID, err)
+		}
+	}
+	return nil
+}
+
+// Keyfunc returns the keys of s that may have signed token, for
+// jwt.Parse. Without a kid header every key is tried.
+func (s JWKS) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	var set jwt.VerificationKeySet
+	for _, key := range s.Keys {
+		if kid != "" && key.KeyID != kid {
+			continue
+		}
+		if publicKey, err := key.RSAPublicKey(); err == nil {
+			set.Keys = append(set.Keys, publicKey)
+		}
+	}
+	if len(set.Keys) == 0 {
+		return nil, errors.New("unknown signing key")
+	}
+	return set, nil
+}
+
+// thumbprint computes the RFC 7638 JWK thumbprint of key.
+func thumbprint(key *rsa.PublicKey) string {
+	// The members must be in lexicographic order, which struct field order
+	// guarantees here.
+	canonical, _ := json.Marshal(struct {
+		E   string `json:"e"`
+		Kty string `json:"kty"`
+		N   string `json:"n"`
+	}{
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		Kty: "RSA",
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+	})
+
+	sum := sha256.Sum256(canonical)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// TokenHash computes the at_hash or c_hash of an ID token for a value: the
+// left half of its SHA-256, base64url encoded (OpenID Connect Core 3.1.3.6).
+func TokenHash(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2])
+}
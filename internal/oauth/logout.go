@@ -0,0 +1,41 @@
+package oauth
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Logout tokens are JWTs of their own type carrying the back-channel logout
+// event, so they cannot be mistaken for ID tokens (OpenID Connect
+// Back-Channel Logout 1.0 section 2.4).
+const (
+	LogoutTokenType        = "logout+jwt"
+	BackchannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+)
+
+// LogoutTokenTTL is how long a logout token is valid after it is signed.
+const LogoutTokenTTL = 2 * time.Minute
+
+// LogoutClaims returns the claims of a logout token telling clientID that
+// the session sessionID of subject has ended.
+func LogoutClaims(issuer, clientID, subject, sessionID string, now time.Time) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":    issuer,
+		"sub":    subject,
+		"aud":    clientID,
+		"iat":    now.Unix(),
+		"exp":    now.Add(LogoutTokenTTL).Unix(),
+		"jti":    uuid.New().String(),
+		"sid":    sessionID,
+		"events": map[string]any{BackchannelLogoutEvent: map[string]any{}},
+	}
+}
+
+// ValidateLogoutURI checks a back-channel logout URI before it is
+// registered for a client. It must be an absolute https URI without a
+// fragment; plain http is only allowed for loopback hosts.
+func ValidateLogoutURI(raw string) error {
+	return ValidateServerURI("logout URI", raw)
+}
@@ -0,0 +1,36 @@
+package oauth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"regexp"
+)
+
+const MethodS256 = "S256"
+
+// verifierPattern is the code_verifier grammar of RFC 7636 section 4.1.
+// The same grammar applies to the code_challenge, a base64url S256 digest.
+var verifierPattern = regexp.MustCompile(`^[A-Za-z0-9\-._~]{43,128}$`)
+
+// ValidChallenge reports whether challenge can be the S256 code_challenge
+// of a verifier.
+func ValidChallenge(challenge string) bool {
+	return len(challenge) == 43 && verifierPattern.MatchString(challenge)
+}
+
+// S256Challenge derives the code_challenge of verifier.
+func S256Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// VerifyPKCE reports whether verifier matches challenge under method. Only
+// S256 is accepted; the plain method offers no protection against an
+// intercepted authorization request.
+func VerifyPKCE(verifier, challenge, method string) bool {
+	if method != MethodS256 || !verifierPattern.MatchString(verifier) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(S256Challenge(verifier)), []byte(challenge)) == 1
+}
@@ -0,0 +1,122 @@
+package oauth
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// ValidateRedirectURI checks a redirect URI before it is registered for a
+// client. It must be absolute and carry no fragment. Plain http is only
+// allowed for loopback hosts; native apps may also use a private-use scheme
+// in reverse domain notation (RFC 8252 section 7.1).
+func ValidateRedirectURI(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || !u.IsAbs() {
+		return fmt.Errorf("redirect URI %q must be an absolute URI", raw)
+	}
+	if u.Fragment != "" || strings.Contains(raw, "#") {
+		return fmt.Errorf("redirect URI %q must not contain a fragment", raw)
+	}
+
+	switch u.Scheme {
+	case "https":
+		if u.Host == "" {
+			return fmt.Errorf("redirect URI %q has no host", raw)
+		}
+	case "http":
+		if !isLoopback(u.Hostname()) {
+			return fmt.Errorf("redirect URI %q must use https unless it points at a loopback address", raw)
+		}
+	default:
+		if !strings.Contains(u.Scheme, ".") {
+			return fmt.Errorf("redirect URI %q must use https or a private-use scheme such as com.example.app", raw)
+		}
+	}
+
+	return nil
+}
+
+// MatchRedirectURI returns the registered redirect URI that requested
+// matches. Matching is by exact string comparison, except that the port of
+// a registered loopback IP redirect URI is ignored, since native apps listen
+// on an ephemeral port (RFC 8252 section 7.3).
+func MatchRedirectURI(registered []string, requested string) (string, bool) {
+	for _, candidate := range registered {
+		if candidate == requested {
+			return requested, true
+		}
+	}
+
+	req, err := url.Parse(requested)
+	if err != nil || req.Scheme != "http" || !isLoopbackIP(req.Hostname()) {
+		return "", false
+	}
+
+	for _, candidate := range registered {
+		reg, err := url.Parse(candidate)
+		if err != nil || reg.Scheme != "http" || !isLoopbackIP(reg.Hostname()) {
+			continue
+		}
+		if reg.Hostname() == req.Hostname() && reg.Path == req.Path && reg.RawQuery == req.RawQuery {
+			return requested, true
+		}
+	}
+
+	return "", false
+}
+
+// ValidateServerURI checks a URI of another server that the service calls
+// itself, such as a client's logout or JWKS URI or an endpoint of an
+// upstream identity provider. kind names the URI in errors.
+func ValidateServerURI(kind, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || !u.IsAbs() || u.Host == "" {
+		return fmt.Errorf("%s %q must be an absolute URI", kind, raw)
+	}
+	if u.Fragment != "" || strings.Contains(raw, "#") {
+		return fmt.Errorf("%s %q must not contain a fragment", kind, raw)
+	}
+
+	switch u.Scheme {
+	case "https":
+	case "http":
+		if !isLoopback(u.Hostname()) {
+			return fmt.Errorf("%s %q must use https unless it points at a loopback address", kind, raw)
+		}
+	default:
+		return fmt.Errorf("%s %q must use https", kind, raw)
+	}
+
+	return nil
+}
+
+func isLoopback(host string) bool {
+	return host == "localhost" || isLoopbackIP(host)
+}
+
+func isLoopbackIP(host string) bool {
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// AppendQuery adds params to the query of rawURL, keeping the parameters it
+// already has.
+func AppendQuery(rawURL string, params url.Values) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	query := u.Query()
+	for key, values := range params {
+		for _, value := range values {
+			if value != "" {
+				query.Add(key, value)
+			}
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
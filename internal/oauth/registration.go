@@ -0,0 +1,58 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// MaxJWKSSize bounds the key set a client publishes at its JWKS URI.
+const MaxJWKSSize = 64 << 10
+
+// ValidateJWKSURI checks the URI a private_key_jwt client publishes its
+// keys at before it is registered. It must be an absolute https URI without
+// a fragment; plain http is only allowed for loopback hosts.
+func ValidateJWKSURI(raw string) error {
+	return ValidateServerURI("JWKS URI", raw)
+}
+
+// FetchJWKS downloads and validates the key set published at uri (RFC 7591
+// section 2). Keys are fetched whenever they are needed, so a client can
+// roll its keys over by publishing the new set.
+func FetchJWKS(ctx context.Context, client *http.Client, uri string) (JWKS, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return JWKS{}, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return JWKS{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return JWKS{}, fmt.Errorf("JWKS URI answered %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxJWKSSize+1))
+	if err != nil {
+		return JWKS{}, err
+	}
+	if len(body) > MaxJWKSSize {
+		return JWKS{}, fmt.Errorf("the key set exceeds %d bytes", MaxJWKSSize)
+	}
+
+	var keys JWKS
+	if err := json.Unmarshal(body, &keys); err != nil {
+		return JWKS{}, fmt.Errorf("the key set is not valid JSON: %w", err)
+	}
+	if err := keys.Validate(); err != nil {
+		return JWKS{}, err
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,47 @@
+package oauth
+
+import (
+	"regexp"
+	"strings"
+)
+
+// scopeTokenPattern is the scope-token grammar of RFC 6749 section 3.3.
+var scopeTokenPattern = regexp.MustCompile(`^[\x21\x23-\x5B\x5D-\x7E]+$`)
+
+// ParseScope splits a space-delimited scope parameter, dropping duplicates
+// and keeping the order of first appearance.
+func ParseScope(scope string) []string {
+	var scopes []string
+	seen := map[string]bool{}
+	for _, token := range strings.Fields(scope) {
+		if !seen[token] {
+			seen[token] = true
+			scopes = append(scopes, token)
+		}
+	}
+	return scopes
+}
+
+// FormatScope joins scopes into a scope parameter.
+func FormatScope(scopes []string) string {
+	return strings.Join(scopes, " ")
+}
+
+// ValidScopeToken reports whether token is a syntactically valid scope.
+func ValidScopeToken(token string) bool {
+	return scopeTokenPattern.MatchString(token)
+}
+
+// ScopeSubset reports whether every scope in requested is in allowed.
+func ScopeSubset(requested, allowed []string) bool {
+	permitted := make(map[string]bool, len(allowed))
+	for _, scope := range allowed {
+		permitted[scope] = true
+	}
+	for _, scope := range requested {
+		if !permitted[scope] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,280 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/ssoydabas/auth-service/internal/oauth"
+	"github.com/stretchr/testify/suite"
+)
+
+type OAuthTestSuite struct {
+	suite.Suite
+}
+
+func (suite *OAuthTestSuite) TestVerifyPKCE() {
+	verifier := "dBjftJeZ4CVP-mJ92K1s-DhgS5bE8f7eGW7gNZM0rUY"
+	sum := sha256.Sum256([]byte(verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	suite.Equal(challenge, oauth.S256Challenge(verifier))
+	suite.True(oauth.ValidChallenge(challenge))
+	suite.True(oauth.VerifyPKCE(verifier, challenge, oauth.MethodS256))
+	suite.False(oauth.VerifyPKCE(verifier+"x", challenge, oauth.MethodS256))
+	suite.False(oauth.VerifyPKCE(verifier, verifier, "plain"))
+	suite.False(oauth.VerifyPKCE("short", oauth.S256Challenge("short"), oauth.MethodS256))
+}
+
+func (suite *OAuthTestSuite) TestValidateRedirectURI() {
+	valid := []string{
+		"https://app.example.com/callback",
+		"http://127.0.0.1/callback",
+		"http://localhost:3000/callback",
+		"http://[::1]:8080/cb",
+		"com.example.app:/oauth2redirect",
+	}
+	for _, uri := range valid {
+		suite.NoError(oauth.ValidateRedirectURI(uri), uri)
+	}
+
+	invalid := []string{
+		"/callback",
+		"http://app.example.com/callback",
+		"https://app.example.com/callback#fragment",
+		"myapp:/callback",
+		"https:///callback",
+	}
+	for _, uri := range invalid {
+		suite.Error(oauth.ValidateRedirectURI(uri), uri)
+	}
+}
+
+func (suite *OAuthTestSuite) TestValidateLogoutURI() {
+	valid := []string{
+		"https://app.example.com/logout",
+		"https://app.example.com/logout?tenant=acme",
+		"http://127.0.0.1:8080/logout",
+	}
+	for _, uri := range valid {
+		suite.NoError(oauth.ValidateLogoutURI(uri), uri)
+	}
+
+	invalid := []string{
+		"/logout",
+		"http://app.example.com/logout",
+		"https://app.example.com/logout#fragment",
+		"com.example.app:/logout",
+	}
+	for _, uri := range invalid {
+		suite.Error(oauth.ValidateLogoutURI(uri), uri)
+	}
+}
+
+func (suite *OAuthTestSuite) TestValidateJWKSURI() {
+	suite.NoError(oauth.ValidateJWKSURI("https://app.example.com/.well-known/jwks.json"))
+	suite.NoError(oauth.ValidateJWKSURI("http://localhost:8080/jwks"))
+
+	for _, uri := range []string{"/jwks", "http://app.example.com/jwks", "https://app.example.com/jwks#keys"} {
+		suite.Error(oauth.ValidateJWKSURI(uri), uri)
+	}
+}
+
+func (suite *OAuthTestSuite) TestFetchJWKS() {
+	key, err := oauth.GenerateSigningKey()
+	suite.Require().NoError(err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/jwks":
+			suite.NoError(json.NewEncoder(w).Encode(key.JWKS()))
+		case "/empty":
+			_, _ = w.Write([]byte(`{"keys":[]}`))
+		case "/large":
+			_, _ = w.Write([]byte(`{"keys":[],"padding":"` + strings.Repeat("x", oauth.MaxJWKSSize) + `"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	keys, err := oauth.FetchJWKS(context.Background(), server.Client(), server.URL+"/jwks")
+	suite.Require().NoError(err)
+	suite.Equal(key.JWKS(), keys)
+
+	for _, path := range []string{"/empty", "/large", "/missing"} {
+		_, err := oauth.FetchJWKS(context.Background(), server.Client(), server.URL+path)
+		suite.Error(err, path)
+	}
+}
+
+func (suite *OAuthTestSuite) TestMatchRedirectURI() {
+	registered := []string{"https://app.example.com/callback", "http://127.0.0.1/native"}
+
+	matched, ok := oauth.MatchRedirectURI(registered, "https://app.example.com/callback")
+	suite.True(ok)
+	suite.Equal("https://app.example.com/callback", matched)
+
+	_, ok = oauth.MatchRedirectURI(registered, "https://app.example.com/callback/")
+	suite.False(ok, "matching is exact")
+	_, ok = oauth.MatchRedirectURI(registered, "https://app.example.com/callback?next=/admin")
+	suite.False(ok, "extra query parameters do not match")
+
+	matched, ok = oauth.MatchRedirectURI(registered, "http://127.0.0.1:51234/native")
+	suite.True(ok, "the port of a loopback redirect URI may vary")
+	suite.Equal("http://127.0.0.1:51234/native", matched)
+
+	_, ok = oauth.MatchRedirectURI(registered, "http://127.0.0.1:51234/other")
+	suite.False(ok)
+	_, ok = oauth.MatchRedirectURI([]string{"http://localhost/native"}, "http://localhost:51234/native")
+	suite.False(ok, "the port exception only applies to loopback IP literals")
+}
+
+func (suite *OAuthTestSuite) TestScope() {
+	suite.Equal([]string{"openid", "profile"}, oauth.ParseScope("  openid profile openid "))
+	suite.Empty(oauth.ParseScope(""))
+	suite.Equal("openid profile", oauth.FormatScope([]string{"openid", "profile"}))
+
+	suite.True(oauth.ScopeSubset([]string{"profile"}, []string{"openid", "profile"}))
+	suite.False(oauth.ScopeSubset([]string{"admin"}, []string{"openid", "profile"}))
+
+	suite.True(oauth.ValidScopeToken("accounts:read"))
+	suite.False(oauth.ValidScopeToken("has space"))
+	suite.False(oauth.ValidScopeToken(`quote"`))
+}
+
+func (suite *OAuthTestSuite) TestErrorRedirectURL() {
+	err := oauth.InvalidScope("Not allowed").WithRedirect("https://app.example.com/callback?tenant=1", "xyz")
+	suite.True(err.Redirectable())
+
+	u, parseErr := url.Parse(err.RedirectURL("https://auth.example.com"))
+	suite.Require().NoError(parseErr)
+	query := u.Query()
+	suite.Equal("1", query.Get("tenant"))
+	suite.Equal(oauth.ErrorInvalidScope, query.Get("error"))
+	suite.Equal("Not allowed", query.Get("error_description"))
+	suite.Equal("xyz", query.Get("state"))
+	suite.Equal("https://auth.example.com", query.Get("iss"))
+
+	suite.False(oauth.InvalidClient("Unknown client").Redirectable())
+}
+
+func (suite *OAuthTestSuite) TestNewToken() {
+	first, err := oauth.NewToken()
+	suite.Require().NoError(err)
+	second, err := oauth.NewToken()
+	suite.Require().NoError(err)
+
+	suite.Len(first, 43)
+	suite.NotEqual(first, second)
+	suite.False(strings.ContainsAny(first, "+/="))
+	suite.Len(oauth.HashToken(first), 64)
+	suite.Equal(oauth.HashToken(first), oauth.HashToken(first))
+}
+
+func (suite *OAuthTestSuite) TestTokenHash() {
+	// Example from OpenID Connect Core 1.0, appendix A.3.
+	suite.Equal("77QmUPtjPfzWtF2AnpK9RQ", oauth.TokenHash("jHkWEdUXMU1BwAsC4vtUsZwnNvTIxEl0z9K3vx5KF0Y"))
+}
+
+func (suite *OAuthTestSuite) TestSigningKey() {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	suite.Require().NoError(err)
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	suite.Require().NoError(err)
+	path := filepath.Join(suite.T().TempDir(), "signing.pem")
+	suite.Require().NoError(os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+
+	signingKey, err := oauth.LoadSigningKey(path)
+	suite.Require().NoError(err)
+	suite.Equal(oauth.NewSigningKey(key).KeyID(), signingKey.KeyID(), "the key ID depends only on the key")
+
+	jwks := signingKey.JWKS()
+	suite.Require().Len(jwks.Keys, 1)
+	suite.Equal(signingKey.KeyID(), jwks.Keys[0].KeyID)
+	suite.Equal(oauth.SigningAlgorithm, jwks.Keys[0].Algorithm)
+	suite.Equal("AQAB", jwks.Keys[0].Exponent)
+
+	signed, err := signingKey.Sign(jwt.MapClaims{"sub": "7"})
+	suite.Require().NoError(err)
+	token, err := jwt.Parse(signed, func(token *jwt.Token) (interface{}, error) {
+		return &key.PublicKey, nil
+	}, jwt.WithValidMethods([]string{oauth.SigningAlgorithm}))
+	suite.Require().NoError(err)
+	suite.Equal(signingKey.KeyID(), token.Header["kid"])
+
+	suite.Require().NoError(os.WriteFile(path, []byte("not a key"), 0o600))
+	_, err = oauth.LoadSigningKey(path)
+	suite.Error(err)
+}
+
+func (suite *OAuthTestSuite) TestJWKSValidate() {
+	key, err := oauth.GenerateSigningKey()
+	suite.Require().NoError(err)
+
+	jwks := key.JWKS()
+	suite.NoError(jwks.Validate())
+
+	publicKey, err := jwks.Keys[0].RSAPublicKey()
+	suite.Require().NoError(err)
+	suite.True(publicKey.Equal(key.PublicKey()))
+
+	suite.Error(oauth.JWKS{}.Validate())
+	suite.Error(oauth.JWKS{Keys: []oauth.JWK{{KeyType: "EC"}}}.Validate())
+
+	small, err := rsa.GenerateKey(rand.Reader, 1024)
+	suite.Require().NoError(err)
+	suite.Error(oauth.NewSigningKey(small).JWKS().Validate(), "keys shorter than 2048 bits are refused")
+}
+
+func (suite *OAuthTestSuite) TestUserCode() {
+	code, err := oauth.NewUserCode()
+	suite.Require().NoError(err)
+	suite.Len(code, 8)
+
+	formatted := oauth.FormatUserCode(code)
+	suite.Equal(code[:4]+"-"+code[4:], formatted)
+
+	for _, input := range []string{code, formatted, strings.ToLower(formatted), " " + code[:4] + " " + code[4:]} {
+		normalized, ok := oauth.NormalizeUserCode(input)
+		suite.True(ok, input)
+		suite.Equal(code, normalized)
+	}
+
+	for _, input := range []string{"", "BCDF-GHJ", "BCDF-GHJKL", "ABCD-EFGH", "BCDF-GHJ1"} {
+		_, ok := oauth.NormalizeUserCode(input)
+		suite.False(ok, input)
+	}
+}
+
+func (suite *OAuthTestSuite) TestExchangePolicy() {
+	policy, err := oauth.NewExchangePolicy(map[string]string{"orders-service": "inventory-api  billing-api"})
+	suite.Require().NoError(err)
+	suite.True(policy.Allows("orders-service", "inventory-api"))
+	suite.True(policy.Allows("orders-service", "billing-api"))
+	suite.False(policy.Allows("orders-service", "orders-service"))
+	suite.False(policy.Allows("billing-job", "inventory-api"), "clients without a rule may not exchange tokens")
+
+	_, err = oauth.NewExchangePolicy(map[string]string{"orders-service": " "})
+	suite.Error(err)
+	_, err = oauth.NewExchangePolicy(map[string]string{"": "inventory-api"})
+	suite.Error(err)
+}
+
+func TestOAuthSuite(t *testing.T) {
+	suite.Run(t, new(OAuthTestSuite))
+}
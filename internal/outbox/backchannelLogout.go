@@ -0,0 +1,75 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ssoydabas/auth-service/internal/oauth"
+	"github.com/ssoydabas/auth-service/models"
+)
+
+const TopicBackchannelLogout = "backchannel_logout"
+
+// backchannelLogoutTimeout bounds a single delivery, so a slow client cannot
+// hold up the rest of the batch.
+const backchannelLogoutTimeout = 10 * time.Second
+
+// BackchannelLogout tells a client that a session it signed a user in with
+// has ended.
+type BackchannelLogout struct {
+	ClientID  string `json:"client_id"`
+	LogoutURI string `json:"logout_uri"`
+	Subject   string `json:"sub"`
+	SessionID string `json:"sid"`
+}
+
+// NewBackchannelLogoutMessage schedules the delivery of a logout token.
+func NewBackchannelLogoutMessage(logout BackchannelLogout) (models.OutboxMessage, error) {
+	return NewMessage(TopicBackchannelLogout, logout)
+}
+
+// BackchannelLogoutHandler signs a logout token with signingKey and POSTs
+// it to the client's logout URI. The token is signed at every attempt, so
+// a retried delivery never carries an expired one. Any answer but a 2xx is
+// an error and the delivery is retried.
+func BackchannelLogoutHandler(signingKey *oauth.SigningKey, issuer string) Handler {
+	client := &http.Client{Timeout: backchannelLogoutTimeout}
+
+	return func(ctx context.Context, payload []byte) error {
+		var logout BackchannelLogout
+		if err := json.Unmarshal(payload, &logout); err != nil {
+			return err
+		}
+
+		claims := oauth.LogoutClaims(issuer, logout.ClientID, logout.Subject, logout.SessionID, time.Now())
+		token, err := signingKey.SignType(claims, oauth.LogoutTokenType)
+		if err != nil {
+			return err
+		}
+
+		form := url.Values{"logout_token": {token}}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, logout.LogoutURI, strings.NewReader(form.Encode()))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("logout URI of client %s answered %s", logout.ClientID, resp.Status)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,32 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/ssoydabas/auth-service/models"
+)
+
+const TopicDataExport = "data_export"
+
+type dataExportPayload struct {
+	ExportID string `json:"export_id"`
+}
+
+// NewDataExportMessage schedules the generation of the data export with the
+// given public ID.
+func NewDataExportMessage(exportID string) (models.OutboxMessage, error) {
+	return NewMessage(TopicDataExport, dataExportPayload{ExportID: exportID})
+}
+
+// DataExportHandler decodes data export payloads and passes the export ID to
+// generate.
+func DataExportHandler(generate func(ctx context.Context, exportID string) error) Handler {
+	return func(ctx context.Context, payload []byte) error {
+		var p dataExportPayload
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return err
+		}
+		return generate(ctx, p.ExportID)
+	}
+}
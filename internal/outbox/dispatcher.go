@@ -0,0 +1,144 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ssoydabas/auth-service/internal/repository"
+	"github.com/ssoydabas/auth-service/models"
+)
+
+// Handler delivers the payload of a single outbox message. Returning an
+// error schedules a retry.
+type Handler func(ctx context.Context, payload []byte) error
+
+type Config struct {
+	PollInterval   time.Duration
+	BatchSize      int
+	MaxAttempts    int
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+	ClaimLease     time.Duration
+}
+
+type Dispatcher struct {
+	outboxRepository repository.OutboxRepository
+	cfg              Config
+
+	mu       sync.RWMutex
+	handlers map[string]Handler
+}
+
+func NewDispatcher(outboxRepository repository.OutboxRepository, cfg Config) *Dispatcher {
+	return &Dispatcher{
+		outboxRepository: outboxRepository,
+		cfg:              cfg,
+		handlers:         make(map[string]Handler),
+	}
+}
+
+// Register routes messages with the given topic to handler.
+func (d *Dispatcher) Register(topic string, handler Handler) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.handlers[topic] = handler
+}
+
+// Run polls for due messages until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Keep draining while full batches come back.
+		for {
+			n, err := d.DispatchOnce(ctx)
+			if err != nil {
+				log.Printf("outbox: dispatch failed: %v", err)
+			}
+			if err != nil || n < d.cfg.BatchSize || ctx.Err() != nil {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchOnce claims one batch of due messages and delivers them. It
+// returns the number of messages claimed.
+func (d *Dispatcher) DispatchOnce(ctx context.Context) (int, error) {
+	messages, err := d.outboxRepository.ClaimOutboxMessages(ctx, d.cfg.BatchSize, d.cfg.ClaimLease)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, message := range messages {
+		if err := d.deliver(ctx, message); err != nil {
+			return len(messages), err
+		}
+	}
+
+	return len(messages), nil
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, message models.OutboxMessage) error {
+	d.mu.RLock()
+	handler, ok := d.handlers[message.Topic]
+	d.mu.RUnlock()
+
+	attempts := message.Attempts + 1
+	if !ok {
+		return d.outboxRepository.DeadLetterOutboxMessage(ctx, message.ID, attempts,
+			fmt.Sprintf("no handler registered for topic %q", message.Topic))
+	}
+
+	deliveryErr := handler(ctx, []byte(message.Payload))
+	if deliveryErr == nil {
+		return d.outboxRepository.MarkOutboxMessageDelivered(ctx, message.ID)
+	}
+
+	if attempts >= d.cfg.MaxAttempts {
+		log.Printf("outbox: message %d (%s) moved to dead letter after %d attempts: %v",
+			message.ID, message.Topic, attempts, deliveryErr)
+		return d.outboxRepository.DeadLetterOutboxMessage(ctx, message.ID, attempts, deliveryErr.Error())
+	}
+
+	next := time.Now().Add(Backoff(attempts, d.cfg.RetryBaseDelay, d.cfg.RetryMaxDelay))
+	return d.outboxRepository.RescheduleOutboxMessage(ctx, message.ID, attempts, next, deliveryErr.Error())
+}
+
+// Backoff returns the delay before retry number attempt: base doubled for
+// every earlier attempt, capped at max.
+func Backoff(attempt int, base, max time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= max {
+			return max
+		}
+	}
+	return delay
+}
+
+// NewMessage encodes payload as JSON into an outbox message for topic.
+func NewMessage(topic string, payload any) (models.OutboxMessage, error) {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return models.OutboxMessage{}, err
+	}
+
+	return models.OutboxMessage{
+		Topic:   topic,
+		Payload: string(encoded),
+	}, nil
+}
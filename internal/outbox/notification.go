@@ -0,0 +1,27 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/ssoydabas/auth-service/internal/notification"
+	"github.com/ssoydabas/auth-service/models"
+)
+
+const TopicNotification = "notification"
+
+// NewNotificationMessage wraps msg for delivery through the outbox.
+func NewNotificationMessage(msg notification.Message) (models.OutboxMessage, error) {
+	return NewMessage(TopicNotification, msg)
+}
+
+// NotificationHandler sends notification payloads through notifier.
+func NotificationHandler(notifier notification.Notifier) Handler {
+	return func(ctx context.Context, payload []byte) error {
+		var msg notification.Message
+		if err := json.Unmarshal(payload, &msg); err != nil {
+			return err
+		}
+		return notifier.Send(ctx, msg)
+	}
+}
@@ -0,0 +1,172 @@
+package outbox
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/ssoydabas/auth-service/internal/oauth"
+	"github.com/ssoydabas/auth-service/internal/outbox"
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+)
+
+const testIssuer = "https://auth.example.com"
+
+// logoutReceiver is a client's back-channel logout endpoint. It answers
+// with the queued statuses in turn, then with 200.
+type logoutReceiver struct {
+	mu       sync.Mutex
+	statuses []int
+	tokens   []string
+}
+
+func (r *logoutReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if req.Method != http.MethodPost || req.Header.Get("Content-Type") != "application/x-www-form-urlencoded" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	r.tokens = append(r.tokens, req.FormValue("logout_token"))
+
+	status := http.StatusOK
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (r *logoutReceiver) received() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.tokens...)
+}
+
+type BackchannelLogoutTestSuite struct {
+	suite.Suite
+	mockRepo   *MockOutboxRepository
+	signingKey *oauth.SigningKey
+	dispatcher *outbox.Dispatcher
+	receiver   *logoutReceiver
+	server     *httptest.Server
+}
+
+func (suite *BackchannelLogoutTestSuite) SetupTest() {
+	signingKey, err := oauth.GenerateSigningKey()
+	suite.Require().NoError(err)
+	suite.signingKey = signingKey
+
+	suite.mockRepo = new(MockOutboxRepository)
+	suite.dispatcher = outbox.NewDispatcher(suite.mockRepo, outbox.Config{
+		PollInterval:   time.Second,
+		BatchSize:      10,
+		MaxAttempts:    3,
+		RetryBaseDelay: time.Second,
+		RetryMaxDelay:  time.Minute,
+		ClaimLease:     time.Minute,
+	})
+	suite.dispatcher.Register(outbox.TopicBackchannelLogout, outbox.BackchannelLogoutHandler(signingKey, testIssuer))
+
+	suite.receiver = &logoutReceiver{}
+	suite.server = httptest.NewServer(suite.receiver)
+}
+
+func (suite *BackchannelLogoutTestSuite) TearDownTest() {
+	suite.server.Close()
+}
+
+func (suite *BackchannelLogoutTestSuite) logoutMessage(attempts int) models.OutboxMessage {
+	message, err := outbox.NewBackchannelLogoutMessage(outbox.BackchannelLogout{
+		ClientID:  "example-spa",
+		LogoutURI: suite.server.URL + "/logout",
+		Subject:   "7",
+		SessionID: "session-1",
+	})
+	suite.Require().NoError(err)
+	message.Model = gorm.Model{ID: 1}
+	message.Attempts = attempts
+	return message
+}
+
+func (suite *BackchannelLogoutTestSuite) parse(logoutToken string) (*jwt.Token, jwt.MapClaims) {
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(logoutToken, claims, func(*jwt.Token) (interface{}, error) {
+		return suite.signingKey.PublicKey(), nil
+	}, jwt.WithValidMethods([]string{oauth.SigningAlgorithm}), jwt.WithIssuer(testIssuer), jwt.WithAudience("example-spa"))
+	suite.Require().NoError(err)
+	return token, claims
+}
+
+func (suite *BackchannelLogoutTestSuite) TestDeliversSignedLogoutToken() {
+	suite.mockRepo.On("ClaimOutboxMessages", mock.Anything, 10, time.Minute).
+		Return([]models.OutboxMessage{suite.logoutMessage(0)}, nil)
+	suite.mockRepo.On("MarkOutboxMessageDelivered", mock.Anything, uint(1)).Return(nil)
+
+	_, err := suite.dispatcher.DispatchOnce(context.Background())
+	suite.Require().NoError(err)
+
+	tokens := suite.receiver.received()
+	suite.Require().Len(tokens, 1)
+	token, claims := suite.parse(tokens[0])
+	suite.Equal(oauth.LogoutTokenType, token.Header["typ"])
+	suite.Equal(suite.signingKey.KeyID(), token.Header["kid"])
+	suite.Equal("7", claims["sub"])
+	suite.Equal("session-1", claims["sid"])
+	suite.NotEmpty(claims["jti"])
+	suite.Equal(map[string]interface{}{oauth.BackchannelLogoutEvent: map[string]interface{}{}}, claims["events"])
+	suite.NotContains(claims, "nonce")
+
+	suite.mockRepo.AssertExpectations(suite.T())
+}
+
+func (suite *BackchannelLogoutTestSuite) TestRetriesRejectedDelivery() {
+	suite.receiver.statuses = []int{http.StatusServiceUnavailable}
+
+	suite.mockRepo.On("ClaimOutboxMessages", mock.Anything, 10, time.Minute).
+		Return([]models.OutboxMessage{suite.logoutMessage(0)}, nil).Once()
+	suite.mockRepo.On("RescheduleOutboxMessage", mock.Anything, uint(1), 1, mock.Anything,
+		"logout URI of client example-spa answered 503 Service Unavailable").Return(nil)
+
+	_, err := suite.dispatcher.DispatchOnce(context.Background())
+	suite.Require().NoError(err)
+
+	suite.mockRepo.On("ClaimOutboxMessages", mock.Anything, 10, time.Minute).
+		Return([]models.OutboxMessage{suite.logoutMessage(1)}, nil).Once()
+	suite.mockRepo.On("MarkOutboxMessageDelivered", mock.Anything, uint(1)).Return(nil)
+
+	_, err = suite.dispatcher.DispatchOnce(context.Background())
+	suite.Require().NoError(err)
+
+	tokens := suite.receiver.received()
+	suite.Require().Len(tokens, 2)
+	_, first := suite.parse(tokens[0])
+	_, retried := suite.parse(tokens[1])
+	suite.NotEqual(first["jti"], retried["jti"], "every attempt carries a freshly signed token")
+
+	suite.mockRepo.AssertExpectations(suite.T())
+}
+
+func (suite *BackchannelLogoutTestSuite) TestUnreachableReceiverIsRetried() {
+	suite.server.Close()
+
+	suite.mockRepo.On("ClaimOutboxMessages", mock.Anything, 10, time.Minute).
+		Return([]models.OutboxMessage{suite.logoutMessage(0)}, nil)
+	suite.mockRepo.On("RescheduleOutboxMessage", mock.Anything, uint(1), 1, mock.Anything, mock.Anything).Return(nil)
+
+	_, err := suite.dispatcher.DispatchOnce(context.Background())
+	suite.Require().NoError(err)
+
+	suite.mockRepo.AssertExpectations(suite.T())
+}
+
+func TestBackchannelLogoutSuite(t *testing.T) {
+	suite.Run(t, new(BackchannelLogoutTestSuite))
+}
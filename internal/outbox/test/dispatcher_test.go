@@ -0,0 +1,149 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ssoydabas/auth-service/internal/notification"
+	"github.com/ssoydabas/auth-service/internal/outbox"
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+)
+
+type DispatcherTestSuite struct {
+	suite.Suite
+	mockRepo   *MockOutboxRepository
+	notifier   *notification.MemoryNotifier
+	dispatcher *outbox.Dispatcher
+	cfg        outbox.Config
+}
+
+func (suite *DispatcherTestSuite) SetupTest() {
+	suite.mockRepo = new(MockOutboxRepository)
+	suite.notifier = notification.NewMemoryNotifier()
+	suite.cfg = outbox.Config{
+		PollInterval:   time.Second,
+		BatchSize:      10,
+		MaxAttempts:    3,
+		RetryBaseDelay: time.Second,
+		RetryMaxDelay:  time.Minute,
+		ClaimLease:     time.Minute,
+	}
+	suite.dispatcher = outbox.NewDispatcher(suite.mockRepo, suite.cfg)
+	suite.dispatcher.Register(outbox.TopicNotification, outbox.NotificationHandler(suite.notifier))
+}
+
+func (suite *DispatcherTestSuite) notificationMessage(id uint, attempts int) models.OutboxMessage {
+	message, err := outbox.NewNotificationMessage(notification.Message{
+		Kind:    notification.KindPasswordReset,
+		To:      "test@example.com",
+		Subject: "Reset your password",
+		Text:    "token",
+	})
+	suite.Require().NoError(err)
+	message.Model = gorm.Model{ID: id}
+	message.Attempts = attempts
+	return message
+}
+
+func (suite *DispatcherTestSuite) TestDeliversClaimedMessages() {
+	suite.mockRepo.On("ClaimOutboxMessages", mock.Anything, 10, time.Minute).
+		Return([]models.OutboxMessage{suite.notificationMessage(1, 0), suite.notificationMessage(2, 0)}, nil)
+	suite.mockRepo.On("MarkOutboxMessageDelivered", mock.Anything, uint(1)).Return(nil)
+	suite.mockRepo.On("MarkOutboxMessageDelivered", mock.Anything, uint(2)).Return(nil)
+
+	n, err := suite.dispatcher.DispatchOnce(context.Background())
+	suite.NoError(err)
+	suite.Equal(2, n)
+	suite.Len(suite.notifier.Messages(), 2)
+
+	suite.mockRepo.AssertExpectations(suite.T())
+}
+
+func (suite *DispatcherTestSuite) TestReschedulesFailedDelivery() {
+	suite.dispatcher.Register("failing", func(ctx context.Context, payload []byte) error {
+		return fmt.Errorf("smtp unavailable")
+	})
+
+	message := suite.notificationMessage(1, 1)
+	message.Topic = "failing"
+
+	suite.mockRepo.On("ClaimOutboxMessages", mock.Anything, 10, time.Minute).
+		Return([]models.OutboxMessage{message}, nil)
+	suite.mockRepo.On("RescheduleOutboxMessage", mock.Anything, uint(1), 2, mock.MatchedBy(func(next time.Time) bool {
+		delay := time.Until(next)
+		return delay > time.Second && delay <= 2*time.Second
+	}), "smtp unavailable").Return(nil)
+
+	_, err := suite.dispatcher.DispatchOnce(context.Background())
+	suite.NoError(err)
+
+	suite.mockRepo.AssertExpectations(suite.T())
+}
+
+func (suite *DispatcherTestSuite) TestDeadLettersAfterMaxAttempts() {
+	suite.dispatcher.Register("failing", func(ctx context.Context, payload []byte) error {
+		return fmt.Errorf("smtp unavailable")
+	})
+
+	message := suite.notificationMessage(1, 2)
+	message.Topic = "failing"
+
+	suite.mockRepo.On("ClaimOutboxMessages", mock.Anything, 10, time.Minute).
+		Return([]models.OutboxMessage{message}, nil)
+	suite.mockRepo.On("DeadLetterOutboxMessage", mock.Anything, uint(1), 3, "smtp unavailable").Return(nil)
+
+	_, err := suite.dispatcher.DispatchOnce(context.Background())
+	suite.NoError(err)
+
+	suite.mockRepo.AssertExpectations(suite.T())
+}
+
+func (suite *DispatcherTestSuite) TestDeadLettersUnknownTopic() {
+	message := suite.notificationMessage(1, 0)
+	message.Topic = "unknown"
+
+	suite.mockRepo.On("ClaimOutboxMessages", mock.Anything, 10, time.Minute).
+		Return([]models.OutboxMessage{message}, nil)
+	suite.mockRepo.On("DeadLetterOutboxMessage", mock.Anything, uint(1), 1, mock.Anything).Return(nil)
+
+	_, err := suite.dispatcher.DispatchOnce(context.Background())
+	suite.NoError(err)
+	suite.Empty(suite.notifier.Messages())
+
+	suite.mockRepo.AssertExpectations(suite.T())
+}
+
+func (suite *DispatcherTestSuite) TestClaimError() {
+	suite.mockRepo.On("ClaimOutboxMessages", mock.Anything, 10, time.Minute).
+		Return(nil, fmt.Errorf("db error"))
+
+	n, err := suite.dispatcher.DispatchOnce(context.Background())
+	suite.Error(err)
+	suite.Zero(n)
+}
+
+func (suite *DispatcherTestSuite) TestBackoff() {
+	tests := []struct {
+		attempt  int
+		expected time.Duration
+	}{
+		{attempt: 1, expected: time.Second},
+		{attempt: 2, expected: 2 * time.Second},
+		{attempt: 3, expected: 4 * time.Second},
+		{attempt: 7, expected: time.Minute},
+		{attempt: 50, expected: time.Minute},
+	}
+
+	for _, tt := range tests {
+		suite.Equal(tt.expected, outbox.Backoff(tt.attempt, time.Second, time.Minute), "attempt %d", tt.attempt)
+	}
+}
+
+func TestDispatcherSuite(t *testing.T) {
+	suite.Run(t, new(DispatcherTestSuite))
+}
@@ -0,0 +1,37 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockOutboxRepository is a mock implementation of OutboxRepository
+type MockOutboxRepository struct {
+	mock.Mock
+}
+
+func (m *MockOutboxRepository) ClaimOutboxMessages(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxMessage, error) {
+	args := m.Called(ctx, limit, lease)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.OutboxMessage), args.Error(1)
+}
+
+func (m *MockOutboxRepository) MarkOutboxMessageDelivered(ctx context.Context, id uint) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockOutboxRepository) RescheduleOutboxMessage(ctx context.Context, id uint, attempts int, availableAt time.Time, lastError string) error {
+	args := m.Called(ctx, id, attempts, availableAt, lastError)
+	return args.Error(0)
+}
+
+func (m *MockOutboxRepository) DeadLetterOutboxMessage(ctx context.Context, id uint, attempts int, lastError string) error {
+	args := m.Called(ctx, id, attempts, lastError)
+	return args.Error(0)
+}
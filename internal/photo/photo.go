@@ -0,0 +1,174 @@
+// Package photo validates uploaded profile photos and turns them into
+// resized, metadata-free variants.
+package photo
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+)
+
+var (
+	ErrTooLarge        = errors.New("photo exceeds the size limit")
+	ErrUnsupportedType = errors.New("photo must be a JPEG, PNG or GIF image")
+	ErrInvalidImage    = errors.New("photo could not be decoded")
+	ErrTooManyPixels   = errors.New("photo dimensions are too large")
+)
+
+const (
+	// maxDimension and maxPixels are checked against the image header
+	// before decoding, so a small file cannot expand into a huge bitmap.
+	maxDimension = 10000
+	maxPixels    = 40_000_000
+
+	jpegQuality = 85
+)
+
+// Variant is a named output size. The image is scaled down, never up, to fit
+// in a Size x Size box, keeping its aspect ratio.
+type Variant struct {
+	Name string
+	Size int
+}
+
+// DefaultVariants are the sizes stored for every profile photo.
+var DefaultVariants = []Variant{
+	{Name: "large", Size: 1024},
+	{Name: "medium", Size: 256},
+	{Name: "small", Size: 64},
+}
+
+// Image is an encoded variant.
+type Image struct {
+	Name        string
+	ContentType string
+	Extension   string
+	Width       int
+	Height      int
+	Data        []byte
+}
+
+type decoder func(io.Reader) (image.Image, error)
+
+var decoders = map[string]decoder{
+	"image/jpeg": jpeg.Decode,
+	"image/png":  png.Decode,
+	"image/gif":  gif.Decode,
+}
+
+// Process reads at most maxBytes from r, checks that the content really is a
+// supported image, applies the EXIF orientation and encodes every variant.
+// Re-encoding drops all metadata of the upload, EXIF included. JPEG uploads
+// produce JPEG variants; PNG and GIF uploads produce PNG variants.
+func Process(r io.Reader, maxBytes int64, variants []Variant) ([]Image, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, ErrTooLarge
+	}
+
+	contentType := http.DetectContentType(data)
+	decode, ok := decoders[contentType]
+	if !ok {
+		return nil, ErrUnsupportedType
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	if config.Width > maxDimension || config.Height > maxDimension || config.Width*config.Height > maxPixels {
+		return nil, ErrTooManyPixels
+	}
+
+	decoded, err := decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+
+	src := toRGBA(decoded)
+	if contentType == "image/jpeg" {
+		src = orient(src, exifOrientation(data))
+	}
+
+	images := make([]Image, 0, len(variants))
+	for _, variant := range variants {
+		scaled := fit(src, variant.Size)
+
+		var buf bytes.Buffer
+		out := Image{Name: variant.Name, Width: scaled.Bounds().Dx(), Height: scaled.Bounds().Dy()}
+		if contentType == "image/jpeg" {
+			out.ContentType, out.Extension = "image/jpeg", ".jpg"
+			err = jpeg.Encode(&buf, scaled, &jpeg.Options{Quality: jpegQuality})
+		} else {
+			out.ContentType, out.Extension = "image/png", ".png"
+			err = png.Encode(&buf, scaled)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		out.Data = buf.Bytes()
+		images = append(images, out)
+	}
+
+	return images, nil
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	bounds := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+	return rgba
+}
+
+// fit scales src down to fit in a size x size box. Each destination pixel is
+// the average of the source pixels it covers, which is what a box filter
+// does and is good enough for downscaling photos.
+func fit(src *image.RGBA, size int) *image.RGBA {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	if sw <= size && sh <= size {
+		return src
+	}
+
+	dw, dh := size, size
+	if sw > sh {
+		dh = max(1, sh*size/sw)
+	} else {
+		dw = max(1, sw*size/sh)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for dy := 0; dy < dh; dy++ {
+		y0, y1 := dy*sh/dh, max((dy+1)*sh/dh, dy*sh/dh+1)
+		for dx := 0; dx < dw; dx++ {
+			x0, x1 := dx*sw/dw, max((dx+1)*sw/dw, dx*sw/dw+1)
+
+			var r, g, b, a, n int
+			for y := y0; y < y1; y++ {
+				row := src.Pix[y*src.Stride:]
+				for x := x0; x < x1; x++ {
+					p := row[x*4 : x*4+4]
+					r += int(p[0])
+					g += int(p[1])
+					b += int(p[2])
+					a += int(p[3])
+					n++
+				}
+			}
+
+			q := dst.Pix[dy*dst.Stride+dx*4 : dy*dst.Stride+dx*4+4]
+			q[0], q[1], q[2], q[3] = uint8(r/n), uint8(g/n), uint8(b/n), uint8(a/n)
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,140 @@
+package photo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"testing"
+
+	"github.com/ssoydabas/auth-service/internal/photo"
+	"github.com/stretchr/testify/suite"
+)
+
+type PhotoTestSuite struct {
+	suite.Suite
+}
+
+// halves returns a w x h image whose left half is red and right half blue.
+func halves(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if x < w/2 {
+				img.Set(x, y, color.RGBA{R: 255, A: 255})
+			} else {
+				img.Set(x, y, color.RGBA{B: 255, A: 255})
+			}
+		}
+	}
+	return img
+}
+
+func encodePNG(img image.Image) []byte {
+	var buf bytes.Buffer
+	_ = png.Encode(&buf, img)
+	return buf.Bytes()
+}
+
+// jpegWithOrientation encodes img as JPEG and inserts an EXIF APP1 segment
+// carrying the orientation tag right after the SOI marker.
+func jpegWithOrientation(img image.Image, orientation uint16) []byte {
+	var encoded bytes.Buffer
+	_ = jpeg.Encode(&encoded, img, &jpeg.Options{Quality: 95})
+
+	var tiff bytes.Buffer
+	tiff.WriteString("MM")
+	_ = binary.Write(&tiff, binary.BigEndian, uint16(42))
+	_ = binary.Write(&tiff, binary.BigEndian, uint32(8))
+	_ = binary.Write(&tiff, binary.BigEndian, uint16(1))
+	_ = binary.Write(&tiff, binary.BigEndian, []uint16{0x0112, 3})
+	_ = binary.Write(&tiff, binary.BigEndian, uint32(1))
+	_ = binary.Write(&tiff, binary.BigEndian, []uint16{orientation, 0})
+	_ = binary.Write(&tiff, binary.BigEndian, uint32(0))
+
+	segment := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+
+	var out bytes.Buffer
+	out.Write(encoded.Bytes()[:2])
+	out.Write([]byte{0xFF, 0xE1})
+	_ = binary.Write(&out, binary.BigEndian, uint16(len(segment)+2))
+	out.Write(segment)
+	out.Write(encoded.Bytes()[2:])
+	return out.Bytes()
+}
+
+func (suite *PhotoTestSuite) TestVariantsKeepAspectRatioAndNeverUpscale() {
+	images, err := photo.Process(bytes.NewReader(encodePNG(halves(300, 150))), 1<<20, photo.DefaultVariants)
+	suite.Require().NoError(err)
+	suite.Require().Len(images, 3)
+
+	sizes := map[string][2]int{}
+	for _, img := range images {
+		suite.Equal("image/png", img.ContentType)
+		suite.Equal(".png", img.Extension)
+		suite.Equal("image/png", http.DetectContentType(img.Data))
+
+		config, err := png.DecodeConfig(bytes.NewReader(img.Data))
+		suite.Require().NoError(err)
+		sizes[img.Name] = [2]int{config.Width, config.Height}
+	}
+
+	suite.Equal([2]int{300, 150}, sizes["large"])
+	suite.Equal([2]int{256, 128}, sizes["medium"])
+	suite.Equal([2]int{64, 32}, sizes["small"])
+}
+
+func (suite *PhotoTestSuite) TestJPEGOrientationIsAppliedAndExifStripped() {
+	upload := jpegWithOrientation(halves(64, 32), 6)
+	suite.Require().True(bytes.Contains(upload, []byte("Exif")))
+
+	images, err := photo.Process(bytes.NewReader(upload), 1<<20, []photo.Variant{{Name: "large", Size: 1024}})
+	suite.Require().NoError(err)
+	suite.Require().Len(images, 1)
+
+	out := images[0]
+	suite.Equal("image/jpeg", out.ContentType)
+	suite.False(bytes.Contains(out.Data, []byte("Exif")))
+
+	decoded, err := jpeg.Decode(bytes.NewReader(out.Data))
+	suite.Require().NoError(err)
+	suite.Equal(32, decoded.Bounds().Dx())
+	suite.Equal(64, decoded.Bounds().Dy())
+
+	// Rotating 90 degrees clockwise moves the red left half to the top.
+	r, _, b, _ := decoded.At(16, 8).RGBA()
+	suite.Greater(r, b)
+	r, _, b, _ = decoded.At(16, 56).RGBA()
+	suite.Greater(b, r)
+}
+
+func (suite *PhotoTestSuite) TestRejectedUploads() {
+	valid := encodePNG(halves(10, 10))
+	corrupt := append([]byte(nil), valid[:40]...)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		limit   int64
+		wantErr error
+	}{
+		{name: "not an image", data: []byte("<html><body>hello</body></html>"), limit: 1 << 20, wantErr: photo.ErrUnsupportedType},
+		{name: "image header without data", data: []byte("GIF89a"), limit: 1 << 20, wantErr: photo.ErrInvalidImage},
+		{name: "over the size limit", data: valid, limit: int64(len(valid) - 1), wantErr: photo.ErrTooLarge},
+		{name: "truncated image", data: corrupt, limit: 1 << 20, wantErr: photo.ErrInvalidImage},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			_, err := photo.Process(bytes.NewReader(tt.data), tt.limit, photo.DefaultVariants)
+			suite.ErrorIs(err, tt.wantErr)
+		})
+	}
+}
+
+func TestPhotoSuite(t *testing.T) {
+	suite.Run(t, new(PhotoTestSuite))
+}
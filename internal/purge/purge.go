@@ -0,0 +1,99 @@
+package purge
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/ssoydabas/auth-service/internal/repository"
+	"github.com/ssoydabas/auth-service/internal/service"
+	"github.com/ssoydabas/auth-service/models"
+)
+
+type Config struct {
+	Interval    time.Duration
+	GracePeriod time.Duration
+	BatchSize   int
+}
+
+// Purger hard-deletes accounts whose deletion grace period has ended and
+// data exports whose download links have expired.
+type Purger struct {
+	accountRepository repository.AccountRepository
+	photoService      service.PhotoService
+	exportService     service.DataExportService
+	cfg               Config
+}
+
+func NewPurger(accountRepository repository.AccountRepository, photoService service.PhotoService, exportService service.DataExportService, cfg Config) *Purger {
+	return &Purger{
+		accountRepository: accountRepository,
+		photoService:      photoService,
+		exportService:     exportService,
+		cfg:               cfg,
+	}
+}
+
+// Run purges expired accounts and exports until ctx is cancelled.
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		p.drain(ctx, "accounts", p.PurgeOnce)
+		p.drain(ctx, "data exports", p.PurgeExportsOnce)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// drain keeps calling purge while it returns full batches.
+func (p *Purger) drain(ctx context.Context, what string, purge func(context.Context) (int, error)) {
+	for {
+		n, err := purge(ctx)
+		if err != nil {
+			log.Printf("purge: %s: %v", what, err)
+		}
+		if err != nil || n < p.cfg.BatchSize || ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// PurgeExportsOnce deletes one batch of expired data exports.
+func (p *Purger) PurgeExportsOnce(ctx context.Context) (int, error) {
+	return p.exportService.PurgeExpiredExports(ctx, p.cfg.BatchSize)
+}
+
+// PurgeOnce purges one batch of accounts deleted before the grace period and
+// removes their photos. It returns the number of accounts looked at.
+func (p *Purger) PurgeOnce(ctx context.Context) (int, error) {
+	deletedBefore := time.Now().Add(-p.cfg.GracePeriod)
+
+	accounts, err := p.accountRepository.ListPurgeableAccounts(ctx, deletedBefore, p.cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, account := range accounts {
+		accountID := account.ID
+		audit := models.AuditEvent{Action: models.AuditActionAccountPurged, TargetID: &accountID}
+
+		purged, err := p.accountRepository.PurgeAccount(ctx, account.ID, deletedBefore, audit)
+		if err != nil {
+			return len(accounts), err
+		}
+
+		// The blobs go only after the rows are gone; a crash in between
+		// leaves unreferenced blobs rather than a broken photo URL.
+		if purged {
+			p.photoService.DeletePhoto(ctx, account.PhotoUrl)
+		}
+	}
+
+	return len(accounts), nil
+}
@@ -0,0 +1,108 @@
+package purge
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ssoydabas/auth-service/internal/purge"
+	"github.com/ssoydabas/auth-service/internal/service"
+	servicetest "github.com/ssoydabas/auth-service/internal/service/test"
+	"github.com/ssoydabas/auth-service/internal/storage"
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/ssoydabas/auth-service/pkg/config"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+)
+
+const testPublicBaseURL = "https://auth.example.com"
+
+type PurgerTestSuite struct {
+	suite.Suite
+	mockRepo *servicetest.MockAccountRepository
+	store    storage.BlobStore
+	purger   *purge.Purger
+	cfg      purge.Config
+}
+
+func (suite *PurgerTestSuite) SetupTest() {
+	suite.mockRepo = new(servicetest.MockAccountRepository)
+	suite.store = storage.NewLocalStore(suite.T().TempDir())
+	photoService := service.NewPhotoService(suite.mockRepo, suite.store, config.Config{PublicBaseURL: testPublicBaseURL})
+	suite.cfg = purge.Config{Interval: time.Minute, GracePeriod: 30 * 24 * time.Hour, BatchSize: 10}
+	exportService := service.NewDataExportService(new(servicetest.MockDataExportRepository), suite.mockRepo, new(servicetest.MockAuditRepository), new(servicetest.MockOAuthRepository), suite.store, nil, config.Config{})
+	suite.purger = purge.NewPurger(suite.mockRepo, photoService, exportService, suite.cfg)
+}
+
+// storePhoto stores the variants of one upload for accountID and returns the
+// URL of the large variant.
+func (suite *PurgerTestSuite) storePhoto(accountID uint) string {
+	dir := fmt.Sprintf("photos/%d/version", accountID)
+	for _, variant := range []string{"large", "medium", "small"} {
+		data := []byte(variant)
+		suite.Require().NoError(suite.store.Put(context.Background(), dir+"/"+variant+".png", bytes.NewReader(data), int64(len(data)), "image/png"))
+	}
+	return testPublicBaseURL + service.PhotoRoutePrefix + fmt.Sprintf("%d/version/large.png", accountID)
+}
+
+func (suite *PurgerTestSuite) photoExists(accountID uint) bool {
+	body, _, err := suite.store.Get(context.Background(), fmt.Sprintf("photos/%d/version/large.png", accountID))
+	if err != nil {
+		return false
+	}
+	body.Close()
+	return true
+}
+
+func (suite *PurgerTestSuite) TestPurgeOnce() {
+	accounts := []models.Account{
+		{Model: gorm.Model{ID: 1}, PhotoUrl: suite.storePhoto(1)},
+		{Model: gorm.Model{ID: 2}, PhotoUrl: suite.storePhoto(2)},
+		{Model: gorm.Model{ID: 3}, PhotoUrl: "https://cdn.example.com/avatar.png"},
+	}
+
+	withinGrace := mock.MatchedBy(func(deletedBefore time.Time) bool {
+		expected := time.Now().Add(-suite.cfg.GracePeriod)
+		return deletedBefore.Sub(expected).Abs() < time.Minute
+	})
+	purged := mock.MatchedBy(func(event models.AuditEvent) bool {
+		return event.Action == models.AuditActionAccountPurged && event.ActorID == nil && event.TargetID != nil
+	})
+
+	suite.mockRepo.On("ListPurgeableAccounts", mock.Anything, withinGrace, 10).Return(accounts, nil)
+	suite.mockRepo.On("PurgeAccount", mock.Anything, uint(1), withinGrace, purged).Return(true, nil)
+	// Account 2 was restored after it was listed.
+	suite.mockRepo.On("PurgeAccount", mock.Anything, uint(2), withinGrace, purged).Return(false, nil)
+	suite.mockRepo.On("PurgeAccount", mock.Anything, uint(3), withinGrace, purged).Return(true, nil)
+
+	n, err := suite.purger.PurgeOnce(context.Background())
+
+	suite.Require().NoError(err)
+	suite.Equal(3, n)
+	suite.False(suite.photoExists(1))
+	suite.True(suite.photoExists(2))
+	suite.mockRepo.AssertExpectations(suite.T())
+}
+
+func (suite *PurgerTestSuite) TestPurgeOnceStopsOnError() {
+	accounts := []models.Account{
+		{Model: gorm.Model{ID: 1}, PhotoUrl: suite.storePhoto(1)},
+		{Model: gorm.Model{ID: 2}},
+	}
+
+	suite.mockRepo.On("ListPurgeableAccounts", mock.Anything, mock.Anything, 10).Return(accounts, nil)
+	suite.mockRepo.On("PurgeAccount", mock.Anything, uint(1), mock.Anything, mock.Anything).Return(false, fmt.Errorf("connection reset"))
+
+	_, err := suite.purger.PurgeOnce(context.Background())
+
+	suite.Error(err)
+	suite.True(suite.photoExists(1))
+	suite.mockRepo.AssertNotCalled(suite.T(), "PurgeAccount", mock.Anything, uint(2), mock.Anything, mock.Anything)
+}
+
+func TestPurgerTestSuite(t *testing.T) {
+	suite.Run(t, new(PurgerTestSuite))
+}
@@ -2,31 +2,48 @@ package repository
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/ssoydabas/auth-service/models"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type AccountRepository interface {
-	CreateAccount(ctx context.Context, model models.Account) error
+	CreateAccount(ctx context.Context, model models.Account, outbox ...models.OutboxMessage) error
 	GetAccountByID(ctx context.Context, id string, preloadTokens bool) (*models.Account, error)
 	GetAccountByEmail(ctx context.Context, email string) (*models.Account, error)
 	GetAccountByEmailOrPhone(ctx context.Context, email, phone string) (*models.Account, error)
 	GetAccountPasswordByAccountID(ctx context.Context, accountID uint) (*models.AccountPassword, error)
-	UpdateLastLoginAt(ctx context.Context, accountID uint, lastLoginAt *time.Time) error
+	UpdateLastLoginAt(ctx context.Context, accountID uint, lastLoginAt *time.Time, audit models.AuditEvent) error
 
 	ExistsByEmail(ctx context.Context, email string) bool
 	ExistsByPhone(ctx context.Context, phone string) bool
 
-	SetResetPasswordToken(ctx context.Context, accountID uint, token string) error
+	SetResetPasswordToken(ctx context.Context, accountID uint, token string, audit models.AuditEvent, outbox ...models.OutboxMessage) error
 	GetAccountByResetPasswordToken(ctx context.Context, token string) (*models.Account, error)
-	UpdateAccountPassword(ctx context.Context, accountID uint, password string) error
+	UpdateAccountPassword(ctx context.Context, accountID uint, password string, audit models.AuditEvent, outbox ...models.OutboxMessage) error
 
-	UpdateAccountVerificationStatus(ctx context.Context, accountID uint, status string) error
+	UpdateAccountVerificationStatus(ctx context.Context, accountID uint, status string, audit models.AuditEvent) error
 	GetAccountByEmailVerificationToken(ctx context.Context, token string) (*models.Account, error)
 	ClearEmailVerificationToken(ctx context.Context, accountID uint) error
+	ReissueEmailVerificationToken(ctx context.Context, accountID uint, previousSentAt *time.Time, tokens models.AccountToken, outbox ...models.OutboxMessage) (bool, error)
+
+	ListAccounts(ctx context.Context, params ListAccountsParams) ([]models.Account, int64, error)
+	UpdateAccountProfile(ctx context.Context, accountID uint, previousUpdatedAt time.Time, changes map[string]interface{}, audit models.AuditEvent) (bool, error)
+	UpdateAccountPhoto(ctx context.Context, accountID uint, photoURL string) error
+	UpdateAccountSuspension(ctx context.Context, accountID uint, changes map[string]interface{}, audit models.AuditEvent) error
+	SoftDeleteAccount(ctx context.Context, accountID uint, audit models.AuditEvent) error
+	GetDeletedAccountByID(ctx context.Context, id string) (*models.Account, error)
+	RestoreAccount(ctx context.Context, accountID uint, deletedAfter time.Time, audit models.AuditEvent) (bool, error)
+	ListPurgeableAccounts(ctx context.Context, deletedBefore time.Time, limit int) ([]models.Account, error)
+	PurgeAccount(ctx context.Context, accountID uint, deletedBefore time.Time, audit models.AuditEvent) (bool, error)
+	CreateImpersonationSession(ctx context.Context, session *models.ImpersonationSession, audit models.AuditEvent) error
+	GetImpersonationSession(ctx context.Context, publicID string) (*models.ImpersonationSession, error)
+	EndImpersonationSession(ctx context.Context, publicID string, endedAt time.Time, audit models.AuditEvent) (bool, error)
 }
 
 type accountRepository struct {
@@ -39,13 +56,19 @@ func NewAccountRepository(db *gorm.DB) AccountRepository {
 	}
 }
 
-func (r *accountRepository) CreateAccount(ctx context.Context, model models.Account) error {
-	return r.db.WithContext(ctx).Create(&model).Error
+func (r *accountRepository) CreateAccount(ctx context.Context, model models.Account, outbox ...models.OutboxMessage) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&model).Error; err != nil {
+			return err
+		}
+
+		return enqueueOutbox(tx, outbox)
+	})
 }
 
 func (r *accountRepository) GetAccountByID(ctx context.Context, id string, preloadTokens bool) (*models.Account, error) {
 	var account models.Account
-	query := r.db.WithContext(ctx)
+	query := r.db.WithContext(ctx).Preload("Roles")
 
 	if preloadTokens {
 		query = query.Preload("AccountTokens")
@@ -59,15 +82,30 @@ func (r *accountRepository) GetAccountByID(ctx context.Context, id string, prelo
 
 func (r *accountRepository) GetAccountByEmail(ctx context.Context, email string) (*models.Account, error) {
 	var account models.Account
-	if err := r.db.WithContext(ctx).Where("email = ?", email).First(&account).Error; err != nil {
+	if err := r.db.WithContext(ctx).Preload("Roles").Where("email = ?", email).First(&account).Error; err != nil {
 		return nil, err
 	}
 	return &account, nil
 }
 
+// GetAccountByEmailOrPhone looks an account up by whichever of email and
+// phone is given, never by an empty one: accounts created through federated
+// sign-in may have no phone.
 func (r *accountRepository) GetAccountByEmailOrPhone(ctx context.Context, email, phone string) (*models.Account, error) {
+	query := r.db.WithContext(ctx).Preload("Roles")
+	switch {
+	case email != "" && phone != "":
+		query = query.Where("email = ? OR phone = ?", email, phone)
+	case email != "":
+		query = query.Where("email = ?", email)
+	case phone != "":
+		query = query.Where("phone = ?", phone)
+	default:
+		return nil, gorm.ErrRecordNotFound
+	}
+
 	var account models.Account
-	if err := r.db.WithContext(ctx).Where("email = ? OR phone = ?", email, phone).First(&account).Error; err != nil {
+	if err := query.First(&account).Error; err != nil {
 		return nil, err
 	}
 	return &account, nil
@@ -84,6 +122,7 @@ func (r *accountRepository) GetAccountPasswordByAccountID(ctx context.Context, a
 func (r *accountRepository) ExistsByEmail(ctx context.Context, email string) bool {
 	exists := false
 	err := r.db.WithContext(ctx).
+		Unscoped().
 		Model(&models.Account{}).
 		Select("1").
 		Where("email = ?", email).
@@ -99,6 +138,7 @@ func (r *accountRepository) ExistsByEmail(ctx context.Context, email string) boo
 func (r *accountRepository) ExistsByPhone(ctx context.Context, phone string) bool {
 	exists := false
 	err := r.db.WithContext(ctx).
+		Unscoped().
 		Model(&models.Account{}).
 		Select("1").
 		Where("phone = ?", phone).
@@ -111,8 +151,20 @@ func (r *accountRepository) ExistsByPhone(ctx context.Context, phone string) boo
 	return exists
 }
 
-func (r *accountRepository) SetResetPasswordToken(ctx context.Context, accountID uint, token string) error {
-	return r.db.WithContext(ctx).Model(&models.AccountToken{}).Where("account_id = ?", accountID).Update("reset_password_token", token).Error
+func (r *accountRepository) SetResetPasswordToken(ctx context.Context, accountID uint, token string, audit models.AuditEvent, outbox ...models.OutboxMessage) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.AccountToken{}).
+			Where("account_id = ?", accountID).
+			Update("reset_password_token", token).Error; err != nil {
+			return err
+		}
+
+		if err := recordAudit(tx, audit); err != nil {
+			return err
+		}
+
+		return enqueueOutbox(tx, outbox)
+	})
 }
 
 func (r *accountRepository) GetAccountByResetPasswordToken(ctx context.Context, token string) (*models.Account, error) {
@@ -127,7 +179,7 @@ func (r *accountRepository) GetAccountByResetPasswordToken(ctx context.Context,
 	return &account, nil
 }
 
-func (r *accountRepository) UpdateAccountPassword(ctx context.Context, accountID uint, password string) error {
+func (r *accountRepository) UpdateAccountPassword(ctx context.Context, accountID uint, password string, audit models.AuditEvent, outbox ...models.OutboxMessage) error {
 	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		if err := tx.Model(&models.AccountPassword{}).
 			Where("account_id = ?", accountID).
@@ -141,7 +193,11 @@ func (r *accountRepository) UpdateAccountPassword(ctx context.Context, accountID
 			return err
 		}
 
-		return nil
+		if err := recordAudit(tx, audit); err != nil {
+			return err
+		}
+
+		return enqueueOutbox(tx, outbox)
 	})
 }
 
@@ -157,11 +213,16 @@ func (r *accountRepository) GetAccountByEmailVerificationToken(ctx context.Conte
 	return &account, nil
 }
 
-func (r *accountRepository) UpdateAccountVerificationStatus(ctx context.Context, accountID uint, status string) error {
-	return r.db.WithContext(ctx).
-		Model(&models.Account{}).
-		Where("id = ?", accountID).
-		Update("verification_status", status).Error
+func (r *accountRepository) UpdateAccountVerificationStatus(ctx context.Context, accountID uint, status string, audit models.AuditEvent) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Account{}).
+			Where("id = ?", accountID).
+			Update("verification_status", status).Error; err != nil {
+			return err
+		}
+
+		return recordAudit(tx, audit)
+	})
 }
 
 func (r *accountRepository) ClearEmailVerificationToken(ctx context.Context, accountID uint) error {
@@ -171,9 +232,394 @@ func (r *accountRepository) ClearEmailVerificationToken(ctx context.Context, acc
 		Update("email_verification_token", "").Error
 }
 
-func (r *accountRepository) UpdateLastLoginAt(ctx context.Context, accountID uint, lastLoginAt *time.Time) error {
+// UpdateLastLoginAt sets last_login_at and records the login in the audit
+// log, which doubles as the account's login history.
+func (r *accountRepository) UpdateLastLoginAt(ctx context.Context, accountID uint, lastLoginAt *time.Time, audit models.AuditEvent) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Account{}).
+			Where("id = ?", accountID).
+			Update("last_login_at", lastLoginAt).Error; err != nil {
+			return err
+		}
+
+		return recordAudit(tx, audit)
+	})
+}
+
+// ReissueEmailVerificationToken replaces the email verification token and its
+// send tracking columns. The update only applies while
+// email_verification_sent_at still equals previousSentAt, so two concurrent
+// resends cannot both pass the cooldown check. It reports whether the update
+// was applied; the outbox messages are only written when it was.
+func (r *accountRepository) ReissueEmailVerificationToken(ctx context.Context, accountID uint, previousSentAt *time.Time, tokens models.AccountToken, outbox ...models.OutboxMessage) (bool, error) {
+	applied := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		query := tx.Model(&models.AccountToken{}).Where("account_id = ?", accountID)
+		if previousSentAt == nil {
+			query = query.Where("email_verification_sent_at IS NULL")
+		} else {
+			query = query.Where("email_verification_sent_at = ?", *previousSentAt)
+		}
+
+		result := query.Updates(map[string]interface{}{
+			"email_verification_token":       tokens.EmailVerificationToken,
+			"email_verification_sent_at":     tokens.EmailVerificationSentAt,
+			"email_verification_window_from": tokens.EmailVerificationWindowFrom,
+			"email_verification_send_count":  tokens.EmailVerificationSendCount,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		applied = true
+		return enqueueOutbox(tx, outbox)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return applied, nil
+}
+
+// UpdateAccountProfile applies changes to the account columns. Like
+// ReissueEmailVerificationToken it only applies while updated_at still
+// equals previousUpdatedAt, so a concurrent update makes it report false
+// instead of being overwritten. changes should carry the new updated_at.
+func (r *accountRepository) UpdateAccountProfile(ctx context.Context, accountID uint, previousUpdatedAt time.Time, changes map[string]interface{}, audit models.AuditEvent) (bool, error) {
+	applied := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Account{}).
+			Where("id = ? AND updated_at = ?", accountID, previousUpdatedAt).
+			Updates(changes)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		applied = true
+		return recordAudit(tx, audit)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return applied, nil
+}
+
+func (r *accountRepository) UpdateAccountPhoto(ctx context.Context, accountID uint, photoURL string) error {
 	return r.db.WithContext(ctx).
 		Model(&models.Account{}).
 		Where("id = ?", accountID).
-		Update("last_login_at", lastLoginAt).Error
+		Update("photo_url", photoURL).Error
+}
+
+// UpdateAccountSuspension sets or clears the suspension columns of an account
+// and records audit in the same transaction.
+func (r *accountRepository) UpdateAccountSuspension(ctx context.Context, accountID uint, changes map[string]interface{}, audit models.AuditEvent) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Account{}).Where("id = ?", accountID).Updates(changes).Error; err != nil {
+			return err
+		}
+
+		return recordAudit(tx, audit)
+	})
+}
+
+// SoftDeleteAccount sets deleted_at on the account, which hides it from every
+// other query until it is restored or purged. Its password, tokens and roles
+// are kept so that a restore brings the account back unchanged.
+func (r *accountRepository) SoftDeleteAccount(ctx context.Context, accountID uint, audit models.AuditEvent) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.Account{}, accountID).Error; err != nil {
+			return err
+		}
+
+		return recordAudit(tx, audit)
+	})
+}
+
+func (r *accountRepository) GetDeletedAccountByID(ctx context.Context, id string) (*models.Account, error) {
+	var account models.Account
+	if err := r.db.WithContext(ctx).
+		Unscoped().
+		Preload("Roles").
+		Where("deleted_at IS NOT NULL").
+		First(&account, id).Error; err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// RestoreAccount clears deleted_at if the account was deleted after
+// deletedAfter. It reports false when the account is not deleted, was
+// deleted earlier or has already been purged.
+func (r *accountRepository) RestoreAccount(ctx context.Context, accountID uint, deletedAfter time.Time, audit models.AuditEvent) (bool, error) {
+	applied := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Unscoped().
+			Model(&models.Account{}).
+			Where("id = ? AND deleted_at > ?", accountID, deletedAfter).
+			Update("deleted_at", nil)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		applied = true
+		return recordAudit(tx, audit)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return applied, nil
+}
+
+// ListPurgeableAccounts returns up to limit accounts deleted before
+// deletedBefore, oldest deletion first.
+func (r *accountRepository) ListPurgeableAccounts(ctx context.Context, deletedBefore time.Time, limit int) ([]models.Account, error) {
+	var accounts []models.Account
+	err := r.db.WithContext(ctx).
+		Unscoped().
+		Where("deleted_at < ?", deletedBefore).
+		Order("deleted_at").
+		Limit(limit).
+		Find(&accounts).Error
+	return accounts, err
+}
+
+// PurgeAccount hard-deletes an account together with its password, tokens,
+// OAuth sessions and grants and role assignments, which frees its email and
+// phone for new registrations. The account row is locked first and the purge only applies
+// while it is still deleted before deletedBefore, so it cannot race with a
+// restore or with another instance purging the same account. Audit events
+// are kept.
+func (r *accountRepository) PurgeAccount(ctx context.Context, accountID uint, deletedBefore time.Time, audit models.AuditEvent) (bool, error) {
+	applied := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var account models.Account
+		result := tx.Unscoped().
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND deleted_at < ?", accountID, deletedBefore).
+			Limit(1).
+			Find(&account)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		if err := tx.Exec("DELETE FROM account_roles WHERE account_id = ?", accountID).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("account_id = ?", accountID).Delete(&models.AccountToken{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("account_id = ?", accountID).Delete(&models.AccountPassword{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("account_id = ?", accountID).Delete(&models.OAuthRefreshToken{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("account_id = ?", accountID).Delete(&models.OAuthAuthorizationCode{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("account_id = ?", accountID).Delete(&models.OAuthDeviceCode{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("account_id = ?", accountID).Delete(&models.OAuthGrant{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("account_id = ?", accountID).Delete(&models.Session{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("account_id = ?", accountID).Delete(&models.Identity{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Delete(&models.Account{}, accountID).Error; err != nil {
+			return err
+		}
+
+		applied = true
+		return recordAudit(tx, audit)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return applied, nil
+}
+
+func (r *accountRepository) CreateImpersonationSession(ctx context.Context, session *models.ImpersonationSession, audit models.AuditEvent) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(session).Error; err != nil {
+			return err
+		}
+
+		return recordAudit(tx, audit)
+	})
+}
+
+func (r *accountRepository) GetImpersonationSession(ctx context.Context, publicID string) (*models.ImpersonationSession, error) {
+	var session models.ImpersonationSession
+	if err := r.db.WithContext(ctx).Where("public_id = ?", publicID).First(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// EndImpersonationSession sets ended_at on a session that has not ended yet.
+// It reports false when the session was already ended.
+func (r *accountRepository) EndImpersonationSession(ctx context.Context, publicID string, endedAt time.Time, audit models.AuditEvent) (bool, error) {
+	applied := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.ImpersonationSession{}).
+			Where("public_id = ? AND ended_at IS NULL", publicID).
+			Update("ended_at", endedAt)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		applied = true
+		return recordAudit(tx, audit)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return applied, nil
+}
+
+// Sort fields accepted by ListAccounts.
+const (
+	AccountSortCreatedAt   = "created_at"
+	AccountSortLastLoginAt = "last_login_at"
+	AccountSortEmail       = "email"
+	AccountSortFirstName   = "first_name"
+	AccountSortLastName    = "last_name"
+)
+
+// accountSortColumns maps sort fields to the expression they order by. Each
+// expression is paired with accounts.id in an index, see
+// migrations/000008_add_account_listing_indexes. last_login_at is coalesced
+// to the zero time so accounts that never logged in still have a position
+// in keyset pagination.
+var accountSortColumns = map[string]string{
+	AccountSortCreatedAt:   "accounts.created_at",
+	AccountSortLastLoginAt: "COALESCE(accounts.last_login_at, '0001-01-01 00:00:00+00'::timestamptz)",
+	AccountSortEmail:       "accounts.email",
+	AccountSortFirstName:   "accounts.first_name",
+	AccountSortLastName:    "accounts.last_name",
+}
+
+// AccountCursor is the position of the last row of a page. Value holds the
+// sort field of that row: a time.Time for the time fields and a string for
+// the others.
+type AccountCursor struct {
+	Value interface{}
+	ID    uint
+}
+
+type ListAccountsParams struct {
+	Role               string
+	VerificationStatus string
+	CreatedFrom        *time.Time
+	CreatedTo          *time.Time
+	LastLoginFrom      *time.Time
+	LastLoginTo        *time.Time
+	Search             string
+
+	SortField string
+	SortDesc  bool
+
+	Limit  int
+	Offset int
+	After  *AccountCursor
+}
+
+// ListAccounts returns one page of accounts matching params together with
+// the number of accounts matching the filters. When params.After is set the
+// page starts right after that cursor and params.Offset is ignored.
+func (r *accountRepository) ListAccounts(ctx context.Context, params ListAccountsParams) ([]models.Account, int64, error) {
+	column, ok := accountSortColumns[params.SortField]
+	if !ok {
+		return nil, 0, fmt.Errorf("unknown sort field %q", params.SortField)
+	}
+
+	query := r.db.WithContext(ctx).Model(&models.Account{})
+
+	if params.Role != "" {
+		query = query.Where(`EXISTS (
+			SELECT 1 FROM account_roles
+			JOIN roles ON roles.id = account_roles.role_id
+			WHERE account_roles.account_id = accounts.id AND roles.name = ?
+		)`, params.Role)
+	}
+	if params.VerificationStatus != "" {
+		query = query.Where("accounts.verification_status = ?", params.VerificationStatus)
+	}
+	if params.CreatedFrom != nil {
+		query = query.Where("accounts.created_at >= ?", *params.CreatedFrom)
+	}
+	if params.CreatedTo != nil {
+		query = query.Where("accounts.created_at < ?", *params.CreatedTo)
+	}
+	if params.LastLoginFrom != nil {
+		query = query.Where("accounts.last_login_at >= ?", *params.LastLoginFrom)
+	}
+	if params.LastLoginTo != nil {
+		query = query.Where("accounts.last_login_at < ?", *params.LastLoginTo)
+	}
+	if params.Search != "" {
+		pattern := "%" + escapeLike(params.Search) + "%"
+		query = query.Where(
+			"(accounts.first_name ILIKE ? OR accounts.last_name ILIKE ? OR accounts.email ILIKE ? OR accounts.phone ILIKE ?)",
+			pattern, pattern, pattern, pattern,
+		)
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	direction, comparison := "ASC", ">"
+	if params.SortDesc {
+		direction, comparison = "DESC", "<"
+	}
+
+	page := query.Preload("Roles").
+		Order(fmt.Sprintf("%s %s, accounts.id %s", column, direction, direction)).
+		Limit(params.Limit)
+
+	if params.After != nil {
+		page = page.Where(fmt.Sprintf("(%s, accounts.id) %s (?, ?)", column, comparison), params.After.Value, params.After.ID)
+	} else if params.Offset > 0 {
+		page = page.Offset(params.Offset)
+	}
+
+	var accounts []models.Account
+	if err := page.Find(&accounts).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return accounts, total, nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// escapeLike escapes the LIKE wildcards in s so it is matched literally.
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
 }
@@ -0,0 +1,216 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/ssoydabas/auth-service/internal/audit"
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/ssoydabas/auth-service/pkg/clientinfo"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type AuditRepository interface {
+	RecordAuditEvent(ctx context.Context, event models.AuditEvent) error
+	ListAuditEvents(ctx context.Context, params ListAuditEventsParams) ([]models.AuditEvent, int64, error)
+	ListAuditEventsForAccount(ctx context.Context, accountID uint, actions ...string) ([]models.AuditEvent, error)
+	ListAuditChain(ctx context.Context, afterSequence uint64, afterID uint, limit int) ([]models.AuditEvent, error)
+	CountUnchainedAuditEvents(ctx context.Context) (int64, error)
+	ClaimAuditSinkCursor(ctx context.Context, name, owner string, lease time.Duration) (*models.AuditSinkCursor, error)
+	AdvanceAuditSinkCursor(ctx context.Context, name, owner string, sequence uint64, eventID uint) (bool, error)
+}
+
+type auditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) AuditRepository {
+	return &auditRepository{
+		db: db,
+	}
+}
+
+// recordAudit writes event using tx so it commits together with the change
+// it describes. The client IP and User-Agent are taken from the request
+// context when the event does not carry them. The event is appended to the
+// hash chain under an advisory lock held until tx ends, so events are
+// chained in commit order.
+func recordAudit(tx *gorm.DB, event models.AuditEvent) error {
+	if event.IP == "" && event.UserAgent == "" && tx.Statement.Context != nil {
+		client := clientinfo.FromContext(tx.Statement.Context)
+		event.IP = client.IP
+		event.UserAgent = client.UserAgent
+	}
+
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", audit.ChainLockKey).Error; err != nil {
+		return err
+	}
+
+	previous, err := lastChainedAuditEvent(tx)
+	if err != nil {
+		return err
+	}
+
+	event.CreatedAt = time.Now()
+	audit.Seal(&event, previous)
+
+	return tx.Create(&event).Error
+}
+
+// lastChainedAuditEvent returns the last event of the hash chain, or nil
+// when the chain is empty. The caller must hold the chain lock.
+func lastChainedAuditEvent(tx *gorm.DB) (*models.AuditEvent, error) {
+	var events []models.AuditEvent
+	if err := tx.Where("hash <> ''").Order("sequence DESC").Limit(1).Find(&events).Error; err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, nil
+	}
+	return &events[0], nil
+}
+
+// RecordAuditEvent writes an event that is not part of another change, such
+// as a rejected sign-in.
+func (r *auditRepository) RecordAuditEvent(ctx context.Context, event models.AuditEvent) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return recordAudit(tx, event)
+	})
+}
+
+// ListAuditEventsParams filters the audit log. Zero values do not filter.
+// From is inclusive and To exclusive.
+type ListAuditEventsParams struct {
+	Action   string
+	ActorID  uint
+	TargetID uint
+	Outcome  string
+	From     *time.Time
+	To       *time.Time
+	Limit    int
+	Offset   int
+}
+
+// ListAuditEvents returns one page of the audit log, newest first, and the
+// total number of matching events.
+func (r *auditRepository) ListAuditEvents(ctx context.Context, params ListAuditEventsParams) ([]models.AuditEvent, int64, error) {
+	query := r.db.WithContext(ctx).Model(&models.AuditEvent{})
+
+	if params.Action != "" {
+		query = query.Where("action = ?", params.Action)
+	}
+	if params.ActorID != 0 {
+		query = query.Where("actor_id = ?", params.ActorID)
+	}
+	if params.TargetID != 0 {
+		query = query.Where("target_id = ?", params.TargetID)
+	}
+	if params.Outcome != "" {
+		query = query.Where("outcome = ?", params.Outcome)
+	}
+	if params.From != nil {
+		query = query.Where("created_at >= ?", *params.From)
+	}
+	if params.To != nil {
+		query = query.Where("created_at < ?", *params.To)
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var events []models.AuditEvent
+	err := query.Order("sequence DESC, id DESC").Limit(params.Limit).Offset(params.Offset).Find(&events).Error
+	return events, total, err
+}
+
+// ListAuditEventsForAccount returns the events the account performed or was
+// the target of, oldest first. When actions are given only those actions
+// are returned.
+func (r *auditRepository) ListAuditEventsForAccount(ctx context.Context, accountID uint, actions ...string) ([]models.AuditEvent, error) {
+	query := r.db.WithContext(ctx).Where("actor_id = ? OR target_id = ?", accountID, accountID)
+	if len(actions) > 0 {
+		query = query.Where("action IN ?", actions)
+	}
+
+	var events []models.AuditEvent
+	err := query.Order("created_at, id").Find(&events).Error
+	return events, err
+}
+
+// ListAuditChain returns up to limit chained events that come after the
+// event identified by afterSequence and afterID, in chain order. Ties on
+// sequence, which only tampering can cause, are broken by id so no event is
+// skipped.
+func (r *auditRepository) ListAuditChain(ctx context.Context, afterSequence uint64, afterID uint, limit int) ([]models.AuditEvent, error) {
+	var events []models.AuditEvent
+	err := r.db.WithContext(ctx).
+		Where("hash <> '' AND (sequence, id) > (?, ?)", afterSequence, afterID).
+		Order("sequence, id").
+		Limit(limit).
+		Find(&events).Error
+	return events, err
+}
+
+// CountUnchainedAuditEvents counts events that are not part of the hash
+// chain. Only events written by a release that predates the chain can be
+// unchained; they are linked in on the next startup.
+func (r *auditRepository) CountUnchainedAuditEvents(ctx context.Context) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.AuditEvent{}).Where("hash = ''").Count(&count).Error
+	return count, err
+}
+
+// ClaimAuditSinkCursor takes or renews the lease on the named sink's cursor
+// for owner, creating the cursor at the start of the chain when it does not
+// exist yet. It returns nil when another owner holds an unexpired lease.
+func (r *auditRepository) ClaimAuditSinkCursor(ctx context.Context, name, owner string, lease time.Duration) (*models.AuditSinkCursor, error) {
+	var cursor *models.AuditSinkCursor
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.AuditSinkCursor{Name: name}).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		result := tx.Model(&models.AuditSinkCursor{}).
+			Where("name = ? AND (owner = ? OR lease_until IS NULL OR lease_until < ?)", name, owner, now).
+			Updates(map[string]interface{}{
+				"owner":       owner,
+				"lease_until": now.Add(lease),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		var claimed models.AuditSinkCursor
+		if err := tx.Where("name = ?", name).First(&claimed).Error; err != nil {
+			return err
+		}
+		cursor = &claimed
+		return nil
+	})
+
+	return cursor, err
+}
+
+// AdvanceAuditSinkCursor moves the named sink's cursor to the given event.
+// It only applies while owner still holds the lease, and reports whether it
+// did.
+func (r *auditRepository) AdvanceAuditSinkCursor(ctx context.Context, name, owner string, sequence uint64, eventID uint) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&models.AuditSinkCursor{}).
+		Where("name = ? AND owner = ?", name, owner).
+		Updates(map[string]interface{}{
+			"sequence": sequence,
+			"event_id": eventID,
+		})
+	return result.RowsAffected == 1, result.Error
+}
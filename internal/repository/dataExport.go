@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/ssoydabas/auth-service/models"
+
+	"gorm.io/gorm"
+)
+
+type DataExportRepository interface {
+	CreateDataExport(ctx context.Context, export *models.DataExport, audit models.AuditEvent, outbox ...models.OutboxMessage) error
+	GetDataExportByPublicID(ctx context.Context, publicID string) (*models.DataExport, error)
+	GetPendingDataExport(ctx context.Context, accountID uint) (*models.DataExport, error)
+	MarkDataExportReady(ctx context.Context, id uint, blobKey string, size int64, expiresAt time.Time, outbox ...models.OutboxMessage) error
+	ListExpiredDataExports(ctx context.Context, expiredBefore time.Time, limit int) ([]models.DataExport, error)
+	DeleteDataExport(ctx context.Context, id uint) error
+}
+
+type dataExportRepository struct {
+	db *gorm.DB
+}
+
+func NewDataExportRepository(db *gorm.DB) DataExportRepository {
+	return &dataExportRepository{
+		db: db,
+	}
+}
+
+// CreateDataExport stores export together with its audit event and the
+// outbox message that schedules its generation.
+func (r *dataExportRepository) CreateDataExport(ctx context.Context, export *models.DataExport, audit models.AuditEvent, outbox ...models.OutboxMessage) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(export).Error; err != nil {
+			return err
+		}
+
+		if err := recordAudit(tx, audit); err != nil {
+			return err
+		}
+
+		return enqueueOutbox(tx, outbox)
+	})
+}
+
+func (r *dataExportRepository) GetDataExportByPublicID(ctx context.Context, publicID string) (*models.DataExport, error) {
+	var export models.DataExport
+	if err := r.db.WithContext(ctx).Where("public_id = ?", publicID).First(&export).Error; err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
+
+func (r *dataExportRepository) GetPendingDataExport(ctx context.Context, accountID uint) (*models.DataExport, error) {
+	var export models.DataExport
+	if err := r.db.WithContext(ctx).
+		Where("account_id = ? AND status = ?", accountID, models.DataExportStatusPending).
+		First(&export).Error; err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
+
+// MarkDataExportReady records the generated archive and enqueues outbox,
+// typically the email with the download link, in the same transaction.
+func (r *dataExportRepository) MarkDataExportReady(ctx context.Context, id uint, blobKey string, size int64, expiresAt time.Time, outbox ...models.OutboxMessage) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.DataExport{}).
+			Where("id = ?", id).
+			Updates(map[string]interface{}{
+				"status":     models.DataExportStatusReady,
+				"blob_key":   blobKey,
+				"size":       size,
+				"expires_at": expiresAt,
+			}).Error; err != nil {
+			return err
+		}
+
+		return enqueueOutbox(tx, outbox)
+	})
+}
+
+func (r *dataExportRepository) ListExpiredDataExports(ctx context.Context, expiredBefore time.Time, limit int) ([]models.DataExport, error) {
+	var exports []models.DataExport
+	err := r.db.WithContext(ctx).
+		Where("expires_at < ?", expiredBefore).
+		Order("expires_at").
+		Limit(limit).
+		Find(&exports).Error
+	return exports, err
+}
+
+func (r *dataExportRepository) DeleteDataExport(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Unscoped().Delete(&models.DataExport{}, id).Error
+}
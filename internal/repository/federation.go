@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/ssoydabas/auth-service/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type FederationRepository interface {
+	CreateFederatedLogin(ctx context.Context, login *models.FederatedLogin) error
+	ConsumeFederatedLogin(ctx context.Context, stateHash string) (*models.FederatedLogin, error)
+	GetIdentity(ctx context.Context, provider, subject string) (*models.Identity, error)
+	RecordIdentityLogin(ctx context.Context, identity *models.Identity, loginAt time.Time, audit models.AuditEvent) error
+	LinkIdentity(ctx context.Context, identity *models.Identity, loginAt time.Time, audits ...models.AuditEvent) error
+	CreateFederatedAccount(ctx context.Context, account *models.Account, identity *models.Identity, audits ...models.AuditEvent) error
+}
+
+type federationRepository struct {
+	db *gorm.DB
+}
+
+func NewFederationRepository(db *gorm.DB) FederationRepository {
+	return &federationRepository{
+		db: db,
+	}
+}
+
+// CreateFederatedLogin stores a sign-in sent to a provider. Expired sign-ins
+// that never came back are removed on the way.
+func (r *federationRepository) CreateFederatedLogin(ctx context.Context, login *models.FederatedLogin) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("expires_at < ?", time.Now()).Delete(&models.FederatedLogin{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(login).Error
+	})
+}
+
+// ConsumeFederatedLogin deletes the sign-in with the given state hash and
+// returns it, so a callback can only be used once. It returns
+// gorm.ErrRecordNotFound when there is no such sign-in; expiry is left to
+// the caller.
+func (r *federationRepository) ConsumeFederatedLogin(ctx context.Context, stateHash string) (*models.FederatedLogin, error) {
+	var login models.FederatedLogin
+	result := r.db.WithContext(ctx).
+		Clauses(clause.Returning{}).
+		Where("state_hash = ?", stateHash).
+		Delete(&login)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &login, nil
+}
+
+func (r *federationRepository) GetIdentity(ctx context.Context, provider, subject string) (*models.Identity, error) {
+	var identity models.Identity
+	if err := r.db.WithContext(ctx).Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error; err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// RecordIdentityLogin records a sign-in through a linked identity on both
+// the identity, whose email is refreshed, and its account.
+func (r *federationRepository) RecordIdentityLogin(ctx context.Context, identity *models.Identity, loginAt time.Time, audit models.AuditEvent) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Identity{}).
+			Where("id = ?", identity.ID).
+			Updates(map[string]interface{}{"email": identity.Email, "last_login_at": loginAt}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Account{}).
+			Where("id = ?", identity.AccountID).
+			Update("last_login_at", loginAt).Error; err != nil {
+			return err
+		}
+
+		return recordAudit(tx, audit)
+	})
+}
+
+// LinkIdentity links identity to an existing account and records the
+// sign-in it was linked on.
+func (r *federationRepository) LinkIdentity(ctx context.Context, identity *models.Identity, loginAt time.Time, audits ...models.AuditEvent) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		identity.LastLoginAt = &loginAt
+		if err := tx.Create(identity).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Account{}).
+			Where("id = ?", identity.AccountID).
+			Update("last_login_at", loginAt).Error; err != nil {
+			return err
+		}
+
+		for _, audit := range audits {
+			if err := recordAudit(tx, audit); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// CreateFederatedAccount creates an account together with the identity it
+// was created for. The audit events are recorded with the new account as
+// both actor and target.
+func (r *federationRepository) CreateFederatedAccount(ctx context.Context, account *models.Account, identity *models.Identity, audits ...models.AuditEvent) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(account).Error; err != nil {
+			return err
+		}
+
+		identity.AccountID = account.ID
+		if err := tx.Create(identity).Error; err != nil {
+			return err
+		}
+
+		for _, audit := range audits {
+			audit.ActorID = &account.ID
+			audit.TargetID = &account.ID
+			if err := recordAudit(tx, audit); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,549 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/ssoydabas/auth-service/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type OAuthRepository interface {
+	CreateOAuthClient(ctx context.Context, client *models.OAuthClient, audit models.AuditEvent) error
+	GetOAuthClient(ctx context.Context, clientID string) (*models.OAuthClient, error)
+	ListOAuthClients(ctx context.Context) ([]models.OAuthClient, error)
+	UpdateOAuthClient(ctx context.Context, id uint, changes map[string]interface{}, audit models.AuditEvent) error
+	ReplaceOAuthClientMetadata(ctx context.Context, client *models.OAuthClient, audit models.AuditEvent) error
+	DisableOAuthClient(ctx context.Context, clientID string, disabledAt time.Time, audit models.AuditEvent) error
+	RecordClientAssertion(ctx context.Context, assertion *models.OAuthClientAssertion) (bool, error)
+	CreateInitialAccessToken(ctx context.Context, token *models.OAuthInitialAccessToken, audit models.AuditEvent) error
+	GetInitialAccessToken(ctx context.Context, tokenHash string) (*models.OAuthInitialAccessToken, error)
+	RegisterOAuthClient(ctx context.Context, client *models.OAuthClient, initialTokenHash string, registeredAt time.Time, audit models.AuditEvent) (bool, error)
+	CreateSession(ctx context.Context, session *models.Session) error
+	GetSessionByTokenHash(ctx context.Context, tokenHash string) (*models.Session, error)
+	GetSessionByPublicID(ctx context.Context, publicID string) (*models.Session, error)
+	ListSessions(ctx context.Context, accountID uint) ([]models.Session, error)
+	ListSessionClients(ctx context.Context, sessionID string) ([]models.OAuthClient, error)
+	RevokeSession(ctx context.Context, sessionID string, revokedAt time.Time, audit models.AuditEvent, outbox ...models.OutboxMessage) (bool, error)
+	CreateAuthorizationCode(ctx context.Context, code *models.OAuthAuthorizationCode, audit models.AuditEvent) error
+	ConsumeAuthorizationCode(ctx context.Context, codeHash string, usedAt time.Time) (*models.OAuthAuthorizationCode, bool, error)
+	CreateRefreshToken(ctx context.Context, token *models.OAuthRefreshToken) error
+	GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*models.OAuthRefreshToken, error)
+	RotateRefreshToken(ctx context.Context, id uint, next *models.OAuthRefreshToken, revokedAt time.Time) (bool, error)
+	RevokeRefreshTokenFamily(ctx context.Context, familyID string, revokedAt time.Time, audit models.AuditEvent) error
+	RevokeRefreshTokensByAuthorizationCode(ctx context.Context, codeID uint, revokedAt time.Time) error
+	CreateDeviceCode(ctx context.Context, code *models.OAuthDeviceCode) error
+	GetDeviceCodeByHash(ctx context.Context, deviceCodeHash string) (*models.OAuthDeviceCode, error)
+	GetDeviceCodeByUserCode(ctx context.Context, userCodeHash string) (*models.OAuthDeviceCode, error)
+	UpdateDeviceCodePoll(ctx context.Context, id uint, polledAt time.Time, pollInterval int) error
+	DecideDeviceCode(ctx context.Context, code *models.OAuthDeviceCode, grant *models.OAuthGrant, audit models.AuditEvent) (bool, error)
+	ConsumeDeviceCode(ctx context.Context, id uint, usedAt time.Time) (bool, error)
+	GetGrant(ctx context.Context, accountID uint, clientID string) (*models.OAuthGrant, error)
+	ListGrants(ctx context.Context, accountID uint) ([]models.OAuthGrant, error)
+	SaveGrant(ctx context.Context, grant *models.OAuthGrant, audit models.AuditEvent) error
+	RevokeGrant(ctx context.Context, accountID uint, clientID string, revokedAt time.Time, audit models.AuditEvent) (bool, error)
+}
+
+type oauthRepository struct {
+	db *gorm.DB
+}
+
+func NewOAuthRepository(db *gorm.DB) OAuthRepository {
+	return &oauthRepository{
+		db: db,
+	}
+}
+
+func (r *oauthRepository) CreateOAuthClient(ctx context.Context, client *models.OAuthClient, audit models.AuditEvent) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(client).Error; err != nil {
+			return err
+		}
+
+		return recordAudit(tx, audit)
+	})
+}
+
+func (r *oauthRepository) GetOAuthClient(ctx context.Context, clientID string) (*models.OAuthClient, error) {
+	var client models.OAuthClient
+	if err := r.db.WithContext(ctx).Where("client_id = ?", clientID).First(&client).Error; err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+func (r *oauthRepository) ListOAuthClients(ctx context.Context) ([]models.OAuthClient, error) {
+	var clients []models.OAuthClient
+	err := r.db.WithContext(ctx).Order("id").Find(&clients).Error
+	return clients, err
+}
+
+func (r *oauthRepository) UpdateOAuthClient(ctx context.Context, id uint, changes map[string]interface{}, audit models.AuditEvent) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.OAuthClient{}).Where("id = ?", id).Updates(changes).Error; err != nil {
+			return err
+		}
+
+		return recordAudit(tx, audit)
+	})
+}
+
+// oauthClientMetadata are the columns a client's registration sets, which
+// an update of the registration replaces as a whole.
+var oauthClientMetadata = []string{
+	"name", "redirect_uris", "post_logout_redirect_uris", "backchannel_logout_uri", "scopes",
+	"grant_types", "token_endpoint_auth_method", "secret_hash", "previous_secret_hash",
+	"previous_secret_expires_at", "jwks", "jwks_uri",
+}
+
+// ReplaceOAuthClientMetadata saves the registered metadata of client,
+// including zero values, so that fields left out of an update are cleared.
+func (r *oauthRepository) ReplaceOAuthClientMetadata(ctx context.Context, client *models.OAuthClient, audit models.AuditEvent) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(client).Select(oauthClientMetadata).Updates(client).Error; err != nil {
+			return err
+		}
+
+		return recordAudit(tx, audit)
+	})
+}
+
+// DisableOAuthClient stops the client from authenticating and revokes its
+// refresh tokens. Access tokens already issued stay valid until they expire.
+func (r *oauthRepository) DisableOAuthClient(ctx context.Context, clientID string, disabledAt time.Time, audit models.AuditEvent) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.OAuthClient{}).
+			Where("client_id = ? AND disabled_at IS NULL", clientID).
+			Update("disabled_at", disabledAt).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.OAuthRefreshToken{}).
+			Where("client_id = ? AND revoked_at IS NULL", clientID).
+			Update("revoked_at", disabledAt).Error; err != nil {
+			return err
+		}
+
+		return recordAudit(tx, audit)
+	})
+}
+
+func (r *oauthRepository) CreateInitialAccessToken(ctx context.Context, token *models.OAuthInitialAccessToken, audit models.AuditEvent) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(token).Error; err != nil {
+			return err
+		}
+
+		return recordAudit(tx, audit)
+	})
+}
+
+func (r *oauthRepository) GetInitialAccessToken(ctx context.Context, tokenHash string) (*models.OAuthInitialAccessToken, error) {
+	var token models.OAuthInitialAccessToken
+	if err := r.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// RegisterOAuthClient creates a client registered with the initial access
+// token whose hash is initialTokenHash, and uses the token up. It reports
+// false, creating nothing, when the token is unknown, expired or already
+// used, so concurrent registrations cannot share a token.
+func (r *oauthRepository) RegisterOAuthClient(ctx context.Context, client *models.OAuthClient, initialTokenHash string, registeredAt time.Time, audit models.AuditEvent) (bool, error) {
+	registered := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.OAuthInitialAccessToken{}).
+			Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", initialTokenHash, registeredAt).
+			Updates(map[string]interface{}{"used_at": registeredAt, "client_id": client.ClientID})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		if err := tx.Create(client).Error; err != nil {
+			return err
+		}
+
+		registered = true
+		return recordAudit(tx, audit)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return registered, nil
+}
+
+// RecordClientAssertion stores the ID of a client assertion. It reports
+// false when the client already used that ID, meaning the assertion is being
+// replayed. Expired records of the client are removed on the way.
+func (r *oauthRepository) RecordClientAssertion(ctx context.Context, assertion *models.OAuthClientAssertion) (bool, error) {
+	recorded := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("client_id = ? AND expires_at < ?", assertion.ClientID, time.Now()).
+			Delete(&models.OAuthClientAssertion{}).Error; err != nil {
+			return err
+		}
+
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(assertion)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		recorded = result.RowsAffected == 1
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return recorded, nil
+}
+
+func (r *oauthRepository) CreateSession(ctx context.Context, session *models.Session) error {
+	return r.db.WithContext(ctx).Create(session).Error
+}
+
+func (r *oauthRepository) GetSessionByTokenHash(ctx context.Context, tokenHash string) (*models.Session, error) {
+	var session models.Session
+	if err := r.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *oauthRepository) GetSessionByPublicID(ctx context.Context, publicID string) (*models.Session, error) {
+	var session models.Session
+	if err := r.db.WithContext(ctx).Where("public_id = ?", publicID).First(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *oauthRepository) ListSessions(ctx context.Context, accountID uint) ([]models.Session, error) {
+	var sessions []models.Session
+	err := r.db.WithContext(ctx).Where("account_id = ?", accountID).Order("id").Find(&sessions).Error
+	return sessions, err
+}
+
+// ListSessionClients returns the clients that signed a user in with the
+// session sessionID, through an authorization code or an approved device
+// code.
+func (r *oauthRepository) ListSessionClients(ctx context.Context, sessionID string) ([]models.OAuthClient, error) {
+	var clients []models.OAuthClient
+	err := r.db.WithContext(ctx).
+		Where("client_id IN (?) OR client_id IN (?)",
+			r.db.Model(&models.OAuthAuthorizationCode{}).Select("client_id").Where("session_id = ?", sessionID),
+			r.db.Model(&models.OAuthDeviceCode{}).Select("client_id").Where("session_id = ?", sessionID)).
+		Order("id").
+		Find(&clients).Error
+	return clients, err
+}
+
+// RevokeSession ends the session sessionID and revokes the refresh tokens
+// issued during it. outbox carries the logout notices for the clients the
+// session signed in to. It reports false, changing nothing, when the
+// session had already ended.
+func (r *oauthRepository) RevokeSession(ctx context.Context, sessionID string, revokedAt time.Time, audit models.AuditEvent, outbox ...models.OutboxMessage) (bool, error) {
+	revoked := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Session{}).
+			Where("public_id = ? AND revoked_at IS NULL", sessionID).
+			Update("revoked_at", revokedAt)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		if err := tx.Model(&models.OAuthRefreshToken{}).
+			Where("session_id = ? AND revoked_at IS NULL", sessionID).
+			Update("revoked_at", revokedAt).Error; err != nil {
+			return err
+		}
+
+		if err := recordAudit(tx, audit); err != nil {
+			return err
+		}
+
+		revoked = true
+		return enqueueOutbox(tx, outbox)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return revoked, nil
+}
+
+func (r *oauthRepository) CreateAuthorizationCode(ctx context.Context, code *models.OAuthAuthorizationCode, audit models.AuditEvent) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(code).Error; err != nil {
+			return err
+		}
+
+		return recordAudit(tx, audit)
+	})
+}
+
+// ConsumeAuthorizationCode marks the code with codeHash as used. It reports
+// false when the code had already been used, together with the code so the
+// caller can revoke what was issued for it. The code row is locked, so of
+// two concurrent redemptions only one succeeds. A code that does not
+// exist yields gorm.ErrRecordNotFound.
+func (r *oauthRepository) ConsumeAuthorizationCode(ctx context.Context, codeHash string, usedAt time.Time) (*models.OAuthAuthorizationCode, bool, error) {
+	var code models.OAuthAuthorizationCode
+	consumed := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("code_hash = ?", codeHash).
+			First(&code).Error; err != nil {
+			return err
+		}
+		if code.UsedAt != nil {
+			return nil
+		}
+
+		consumed = true
+		code.UsedAt = &usedAt
+		return tx.Model(&code).Update("used_at", usedAt).Error
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return &code, consumed, nil
+}
+
+func (r *oauthRepository) CreateRefreshToken(ctx context.Context, token *models.OAuthRefreshToken) error {
+	return r.db.WithContext(ctx).Create(token).Error
+}
+
+func (r *oauthRepository) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*models.OAuthRefreshToken, error) {
+	var token models.OAuthRefreshToken
+	if err := r.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// RotateRefreshToken revokes the refresh token id and stores next in its
+// place. It reports false, storing nothing, when id had already been
+// revoked, for example by a concurrent refresh with the same token.
+func (r *oauthRepository) RotateRefreshToken(ctx context.Context, id uint, next *models.OAuthRefreshToken, revokedAt time.Time) (bool, error) {
+	applied := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.OAuthRefreshToken{}).
+			Where("id = ? AND revoked_at IS NULL", id).
+			Update("revoked_at", revokedAt)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		applied = true
+		return tx.Create(next).Error
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return applied, nil
+}
+
+// RevokeRefreshTokenFamily revokes every refresh token derived from the same
+// authorization, used when a rotated token is presented again.
+func (r *oauthRepository) RevokeRefreshTokenFamily(ctx context.Context, familyID string, revokedAt time.Time, audit models.AuditEvent) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.OAuthRefreshToken{}).
+			Where("family_id = ? AND revoked_at IS NULL", familyID).
+			Update("revoked_at", revokedAt).Error; err != nil {
+			return err
+		}
+
+		return recordAudit(tx, audit)
+	})
+}
+
+// RevokeRefreshTokensByAuthorizationCode revokes the refresh tokens issued
+// for a code, and the tokens rotated from them, when the code is presented a
+// second time (RFC 6749 section 4.1.2).
+func (r *oauthRepository) RevokeRefreshTokensByAuthorizationCode(ctx context.Context, codeID uint, revokedAt time.Time) error {
+	var families []string
+	if err := r.db.WithContext(ctx).
+		Model(&models.OAuthRefreshToken{}).
+		Where("authorization_code_id = ?", codeID).
+		Distinct().
+		Pluck("family_id", &families).Error; err != nil {
+		return err
+	}
+	if len(families) == 0 {
+		return nil
+	}
+
+	return r.db.WithContext(ctx).
+		Model(&models.OAuthRefreshToken{}).
+		Where("family_id IN ? AND revoked_at IS NULL", families).
+		Update("revoked_at", revokedAt).Error
+}
+
+// CreateDeviceCode stores a device authorization. Expired ones are removed
+// first, so user codes, which are short, can be reused once they expire.
+func (r *oauthRepository) CreateDeviceCode(ctx context.Context, code *models.OAuthDeviceCode) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("expires_at < ?", time.Now()).
+			Delete(&models.OAuthDeviceCode{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(code).Error
+	})
+}
+
+func (r *oauthRepository) GetDeviceCodeByHash(ctx context.Context, deviceCodeHash string) (*models.OAuthDeviceCode, error) {
+	var code models.OAuthDeviceCode
+	if err := r.db.WithContext(ctx).Where("device_code_hash = ?", deviceCodeHash).First(&code).Error; err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+func (r *oauthRepository) GetDeviceCodeByUserCode(ctx context.Context, userCodeHash string) (*models.OAuthDeviceCode, error) {
+	var code models.OAuthDeviceCode
+	if err := r.db.WithContext(ctx).Where("user_code_hash = ?", userCodeHash).First(&code).Error; err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+// UpdateDeviceCodePoll records when the device last polled, and the
+// interval it must wait before the next poll.
+func (r *oauthRepository) UpdateDeviceCodePoll(ctx context.Context, id uint, polledAt time.Time, pollInterval int) error {
+	return r.db.WithContext(ctx).Model(&models.OAuthDeviceCode{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"last_polled_at": polledAt,
+			"poll_interval":  pollInterval,
+		}).Error
+}
+
+// DecideDeviceCode stores the user's approval or denial of a device
+// authorization: the decision fields of code, and on approval the account
+// and session it was made in. grant, if not nil, is saved with an
+// approval. It reports false, changing nothing, when the code was already
+// decided or has expired.
+func (r *oauthRepository) DecideDeviceCode(ctx context.Context, code *models.OAuthDeviceCode, grant *models.OAuthGrant, audit models.AuditEvent) (bool, error) {
+	applied := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.OAuthDeviceCode{}).
+			Where("id = ? AND approved_at IS NULL AND denied_at IS NULL AND expires_at > ?", code.ID, time.Now()).
+			Select("account_id", "session_id", "auth_time", "amr", "approved_at", "denied_at").
+			Updates(code)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		applied = true
+		if grant != nil {
+			if err := saveGrant(tx, grant); err != nil {
+				return err
+			}
+		}
+		return recordAudit(tx, audit)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return applied, nil
+}
+
+// ConsumeDeviceCode marks an approved device code as used. It reports
+// false when tokens were already issued for it, so of two concurrent polls
+// only one receives tokens.
+func (r *oauthRepository) ConsumeDeviceCode(ctx context.Context, id uint, usedAt time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.OAuthDeviceCode{}).
+		Where("id = ? AND approved_at IS NOT NULL AND used_at IS NULL", id).
+		Update("used_at", usedAt)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+func (r *oauthRepository) GetGrant(ctx context.Context, accountID uint, clientID string) (*models.OAuthGrant, error) {
+	var grant models.OAuthGrant
+	if err := r.db.WithContext(ctx).Where("account_id = ? AND client_id = ?", accountID, clientID).First(&grant).Error; err != nil {
+		return nil, err
+	}
+	return &grant, nil
+}
+
+func (r *oauthRepository) ListGrants(ctx context.Context, accountID uint) ([]models.OAuthGrant, error) {
+	var grants []models.OAuthGrant
+	err := r.db.WithContext(ctx).Where("account_id = ?", accountID).Order("id").Find(&grants).Error
+	return grants, err
+}
+
+// SaveGrant stores the scopes an account has granted a client, replacing
+// the scopes of an earlier grant.
+func (r *oauthRepository) SaveGrant(ctx context.Context, grant *models.OAuthGrant, audit models.AuditEvent) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := saveGrant(tx, grant); err != nil {
+			return err
+		}
+
+		return recordAudit(tx, audit)
+	})
+}
+
+// RevokeGrant removes the grant of a client and revokes the client's
+// refresh tokens for the account. It reports false when there was no
+// grant. Access tokens already issued stay valid until they expire.
+func (r *oauthRepository) RevokeGrant(ctx context.Context, accountID uint, clientID string, revokedAt time.Time, audit models.AuditEvent) (bool, error) {
+	revoked := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("account_id = ? AND client_id = ?", accountID, clientID).Delete(&models.OAuthGrant{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		if err := tx.Model(&models.OAuthRefreshToken{}).
+			Where("account_id = ? AND client_id = ? AND revoked_at IS NULL", accountID, clientID).
+			Update("revoked_at", revokedAt).Error; err != nil {
+			return err
+		}
+
+		revoked = true
+		return recordAudit(tx, audit)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return revoked, nil
+}
+
+// saveGrant inserts grant, or updates the scopes of the existing grant of
+// the same account and client.
+func saveGrant(tx *gorm.DB, grant *models.OAuthGrant) error {
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "account_id"}, {Name: "client_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"scopes", "updated_at"}),
+	}).Create(grant).Error
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/ssoydabas/auth-service/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type OutboxRepository interface {
+	ClaimOutboxMessages(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxMessage, error)
+	MarkOutboxMessageDelivered(ctx context.Context, id uint) error
+	RescheduleOutboxMessage(ctx context.Context, id uint, attempts int, availableAt time.Time, lastError string) error
+	DeadLetterOutboxMessage(ctx context.Context, id uint, attempts int, lastError string) error
+}
+
+type outboxRepository struct {
+	db *gorm.DB
+}
+
+func NewOutboxRepository(db *gorm.DB) OutboxRepository {
+	return &outboxRepository{
+		db: db,
+	}
+}
+
+// enqueueOutbox writes messages using tx so they commit or roll back
+// together with the change that produced them.
+func enqueueOutbox(tx *gorm.DB, messages []models.OutboxMessage) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	for i := range messages {
+		if messages[i].Status == "" {
+			messages[i].Status = models.OutboxStatusPending
+		}
+		if messages[i].AvailableAt.IsZero() {
+			messages[i].AvailableAt = now
+		}
+	}
+
+	return tx.Create(&messages).Error
+}
+
+// ClaimOutboxMessages locks up to limit due messages with FOR UPDATE SKIP
+// LOCKED and pushes their available_at forward by lease, so concurrent
+// dispatchers skip them. A dispatcher that crashes mid-delivery releases its
+// messages once the lease runs out.
+func (r *outboxRepository) ClaimOutboxMessages(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxMessage, error) {
+	var messages []models.OutboxMessage
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND available_at <= ?", models.OutboxStatusPending, now).
+			Order("available_at, id").
+			Limit(limit).
+			Find(&messages).Error; err != nil {
+			return err
+		}
+
+		if len(messages) == 0 {
+			return nil
+		}
+
+		ids := make([]uint, len(messages))
+		for i, message := range messages {
+			ids[i] = message.ID
+		}
+
+		return tx.Model(&models.OutboxMessage{}).
+			Where("id IN ?", ids).
+			Update("available_at", now.Add(lease)).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+func (r *outboxRepository) MarkOutboxMessageDelivered(ctx context.Context, id uint) error {
+	now := time.Now()
+	return r.db.WithContext(ctx).
+		Model(&models.OutboxMessage{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":       models.OutboxStatusDelivered,
+			"delivered_at": &now,
+			"last_error":   "",
+		}).Error
+}
+
+func (r *outboxRepository) RescheduleOutboxMessage(ctx context.Context, id uint, attempts int, availableAt time.Time, lastError string) error {
+	return r.db.WithContext(ctx).
+		Model(&models.OutboxMessage{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"attempts":     attempts,
+			"available_at": availableAt,
+			"last_error":   lastError,
+		}).Error
+}
+
+func (r *outboxRepository) DeadLetterOutboxMessage(ctx context.Context, id uint, attempts int, lastError string) error {
+	return r.db.WithContext(ctx).
+		Model(&models.OutboxMessage{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":     models.OutboxStatusDead,
+			"attempts":   attempts,
+			"last_error": lastError,
+		}).Error
+}
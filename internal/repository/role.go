@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/ssoydabas/auth-service/models"
+
+	"gorm.io/gorm"
+)
+
+type RoleRepository interface {
+	ListRoles(ctx context.Context) ([]models.Role, error)
+	GetRoleByID(ctx context.Context, id string) (*models.Role, error)
+	GetRoleByName(ctx context.Context, name string) (*models.Role, error)
+	GetRolesByNames(ctx context.Context, names []string) ([]models.Role, error)
+	CreateRole(ctx context.Context, role *models.Role, audit models.AuditEvent) error
+	UpdateRole(ctx context.Context, role *models.Role, audit models.AuditEvent) error
+	DeleteRole(ctx context.Context, roleID uint, audit models.AuditEvent) error
+
+	ListPermissions(ctx context.Context) ([]models.Permission, error)
+	GetPermissionByID(ctx context.Context, id string) (*models.Permission, error)
+	GetPermissionsByNames(ctx context.Context, names []string) ([]models.Permission, error)
+	CreatePermission(ctx context.Context, permission *models.Permission, audit models.AuditEvent) error
+	DeletePermission(ctx context.Context, permissionID uint, audit models.AuditEvent) error
+
+	ReplaceAccountRoles(ctx context.Context, accountID uint, roles []models.Role, audit models.AuditEvent) error
+	AccountHasPermission(ctx context.Context, accountID uint, permission string) (bool, error)
+	CountAccountsWithRole(ctx context.Context, name string) (int64, error)
+}
+
+type roleRepository struct {
+	db *gorm.DB
+}
+
+func NewRoleRepository(db *gorm.DB) RoleRepository {
+	return &roleRepository{
+		db: db,
+	}
+}
+
+func (r *roleRepository) ListRoles(ctx context.Context) ([]models.Role, error) {
+	var roles []models.Role
+	if err := r.db.WithContext(ctx).Preload("Permissions").Order("name").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+func (r *roleRepository) GetRoleByID(ctx context.Context, id string) (*models.Role, error) {
+	var role models.Role
+	if err := r.db.WithContext(ctx).Preload("Permissions").First(&role, id).Error; err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (r *roleRepository) GetRoleByName(ctx context.Context, name string) (*models.Role, error) {
+	var role models.Role
+	if err := r.db.WithContext(ctx).Where("name = ?", name).First(&role).Error; err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (r *roleRepository) GetRolesByNames(ctx context.Context, names []string) ([]models.Role, error) {
+	var roles []models.Role
+	if err := r.db.WithContext(ctx).Where("name IN ?", names).Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+func (r *roleRepository) CreateRole(ctx context.Context, role *models.Role, audit models.AuditEvent) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(role).Error; err != nil {
+			return err
+		}
+
+		audit.TargetID = &role.ID
+		return recordAudit(tx, audit)
+	})
+}
+
+// UpdateRole saves the role's name and description and replaces its
+// permissions with role.Permissions.
+func (r *roleRepository) UpdateRole(ctx context.Context, role *models.Role, audit models.AuditEvent) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(role).
+			Select("name", "description").
+			Updates(models.Role{Name: role.Name, Description: role.Description}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(role).Association("Permissions").Replace(role.Permissions); err != nil {
+			return err
+		}
+
+		return recordAudit(tx, audit)
+	})
+}
+
+// DeleteRole hard-deletes the role so its name can be reused, together with
+// its permission grants and account assignments.
+func (r *roleRepository) DeleteRole(ctx context.Context, roleID uint, audit models.AuditEvent) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM role_permissions WHERE role_id = ?", roleID).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Exec("DELETE FROM account_roles WHERE role_id = ?", roleID).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Delete(&models.Role{}, roleID).Error; err != nil {
+			return err
+		}
+
+		return recordAudit(tx, audit)
+	})
+}
+
+func (r *roleRepository) ListPermissions(ctx context.Context) ([]models.Permission, error) {
+	var permissions []models.Permission
+	if err := r.db.WithContext(ctx).Order("name").Find(&permissions).Error; err != nil {
+		return nil, err
+	}
+	return permissions, nil
+}
+
+func (r *roleRepository) GetPermissionByID(ctx context.Context, id string) (*models.Permission, error) {
+	var permission models.Permission
+	if err := r.db.WithContext(ctx).First(&permission, id).Error; err != nil {
+		return nil, err
+	}
+	return &permission, nil
+}
+
+func (r *roleRepository) GetPermissionsByNames(ctx context.Context, names []string) ([]models.Permission, error) {
+	var permissions []models.Permission
+	if err := r.db.WithContext(ctx).Where("name IN ?", names).Find(&permissions).Error; err != nil {
+		return nil, err
+	}
+	return permissions, nil
+}
+
+func (r *roleRepository) CreatePermission(ctx context.Context, permission *models.Permission, audit models.AuditEvent) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(permission).Error; err != nil {
+			return err
+		}
+
+		audit.TargetID = &permission.ID
+		return recordAudit(tx, audit)
+	})
+}
+
+func (r *roleRepository) DeletePermission(ctx context.Context, permissionID uint, audit models.AuditEvent) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM role_permissions WHERE permission_id = ?", permissionID).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Delete(&models.Permission{}, permissionID).Error; err != nil {
+			return err
+		}
+
+		return recordAudit(tx, audit)
+	})
+}
+
+func (r *roleRepository) ReplaceAccountRoles(ctx context.Context, accountID uint, roles []models.Role, audit models.AuditEvent) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		account := models.Account{Model: gorm.Model{ID: accountID}}
+		if err := tx.Model(&account).Association("Roles").Replace(roles); err != nil {
+			return err
+		}
+
+		return recordAudit(tx, audit)
+	})
+}
+
+func (r *roleRepository) AccountHasPermission(ctx context.Context, accountID uint, permission string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Table("account_roles").
+		Joins("JOIN role_permissions ON role_permissions.role_id = account_roles.role_id").
+		Joins("JOIN permissions ON permissions.id = role_permissions.permission_id").
+		Where("account_roles.account_id = ? AND permissions.name = ?", accountID, permission).
+		Limit(1).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *roleRepository) CountAccountsWithRole(ctx context.Context, name string) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Table("account_roles").
+		Joins("JOIN roles ON roles.id = account_roles.role_id").
+		Where("roles.name = ?", name).
+		Count(&count).Error
+	return count, err
+}
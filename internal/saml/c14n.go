@@ -0,0 +1,121 @@
+package saml
+
+import (
+	"bytes"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// AlgorithmExclusiveC14N is Exclusive XML Canonicalization 1.0 without
+// comments, the only canonicalization accepted in signatures.
+const AlgorithmExclusiveC14N = "http://www.w3.org/2001/10/xml-exc-c14n#"
+
+// Canonicalize serializes e and its descendants with Exclusive XML
+// Canonicalization 1.0 without comments. A namespace is declared on the
+// elements that use it; those named in inclusivePrefixes, with "#default"
+// for the default namespace, are declared as soon as they are in scope, as
+// the InclusiveNamespaces PrefixList of a transform asks.
+func Canonicalize(e *Element, inclusivePrefixes []string) []byte {
+	return canonicalize(e, inclusivePrefixes, nil)
+}
+
+// canonicalize is Canonicalize leaving out exclude and its descendants,
+// for the enveloped signature transform.
+func canonicalize(e *Element, inclusivePrefixes []string, exclude *Element) []byte {
+	c := canonicalizer{exclude: exclude}
+	for _, prefix := range inclusivePrefixes {
+		if prefix == "#default" {
+			prefix = ""
+		}
+		c.inclusive = append(c.inclusive, prefix)
+	}
+
+	c.element(e, map[string]string{})
+	return c.out.Bytes()
+}
+
+type canonicalizer struct {
+	out       bytes.Buffer
+	inclusive []string
+	exclude   *Element
+}
+
+// element writes e. rendered holds the namespaces declared by the elements
+// written around it, which e need not declare again.
+func (c *canonicalizer) element(e *Element, rendered map[string]string) {
+	prefixes := []string{e.Prefix}
+	for _, attr := range e.Attrs {
+		if attr.Prefix != "" && attr.Prefix != "xml" {
+			prefixes = append(prefixes, attr.Prefix)
+		}
+	}
+	for _, prefix := range c.inclusive {
+		if _, ok := e.lookup(prefix); ok {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	slices.Sort(prefixes)
+	prefixes = slices.Compact(prefixes)
+
+	name := qualifiedName(e.Prefix, e.Local)
+	c.out.WriteString("<" + name)
+
+	inherited, copied := rendered, false
+	for _, prefix := range prefixes {
+		space, _ := e.lookup(prefix)
+		previous, ok := inherited[prefix]
+		if ok && previous == space || !ok && prefix == "" && space == "" {
+			continue
+		}
+		if prefix == "" {
+			c.out.WriteString(` xmlns="`)
+		} else {
+			c.out.WriteString(" xmlns:" + prefix + `="`)
+		}
+		c.out.WriteString(escapeAttr(space) + `"`)
+
+		if !copied {
+			rendered, copied = maps.Clone(inherited), true
+		}
+		rendered[prefix] = space
+	}
+
+	attrs := slices.Clone(e.Attrs)
+	slices.SortFunc(attrs, func(a, b Attr) int {
+		if n := strings.Compare(a.Space, b.Space); n != 0 {
+			return n
+		}
+		return strings.Compare(a.Local, b.Local)
+	})
+	for _, attr := range attrs {
+		c.out.WriteString(" " + qualifiedName(attr.Prefix, attr.Local) + `="` + escapeAttr(attr.Value) + `"`)
+	}
+	c.out.WriteString(">")
+
+	for _, child := range e.Children {
+		switch child := child.(type) {
+		case Text:
+			c.out.WriteString(escapeText(string(child)))
+		case *Element:
+			if child != c.exclude {
+				c.element(child, rendered)
+			}
+		}
+	}
+
+	c.out.WriteString("</" + name + ">")
+}
+
+var (
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+)
+
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+func escapeAttr(s string) string {
+	return attrEscaper.Replace(s)
+}
@@ -0,0 +1,164 @@
+package saml
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// IdentityProvider is what the metadata of an identity provider tells the
+// service provider: its entity ID, where to send AuthnRequests and the
+// certificates it signs with. The certificates are trusted as configured,
+// whatever their issuer and validity period.
+type IdentityProvider struct {
+	EntityID     string
+	SSOURL       string
+	Certificates []*x509.Certificate
+}
+
+type entityDescriptor struct {
+	XMLName          xml.Name           `xml:"urn:oasis:names:tc:SAML:2.0:metadata EntityDescriptor"`
+	EntityID         string             `xml:"entityID,attr"`
+	IDPSSODescriptor []idpSSODescriptor `xml:"urn:oasis:names:tc:SAML:2.0:metadata IDPSSODescriptor"`
+}
+
+type idpSSODescriptor struct {
+	ProtocolSupportEnumeration string          `xml:"protocolSupportEnumeration,attr"`
+	KeyDescriptors             []keyDescriptor `xml:"urn:oasis:names:tc:SAML:2.0:metadata KeyDescriptor"`
+	SingleSignOnServices       []endpoint      `xml:"urn:oasis:names:tc:SAML:2.0:metadata SingleSignOnService"`
+}
+
+type keyDescriptor struct {
+	Use     string `xml:"use,attr"`
+	KeyInfo struct {
+		X509Data []struct {
+			X509Certificates []string `xml:"http://www.w3.org/2000/09/xmldsig# X509Certificate"`
+		} `xml:"http://www.w3.org/2000/09/xmldsig# X509Data"`
+	} `xml:"http://www.w3.org/2000/09/xmldsig# KeyInfo"`
+}
+
+type endpoint struct {
+	Binding  string `xml:"Binding,attr"`
+	Location string `xml:"Location,attr"`
+}
+
+// ParseIdentityProviderMetadata reads the EntityDescriptor of an identity
+// provider (SAML Metadata section 2.4.3). It must support SAML 2.0, take
+// AuthnRequests with the HTTP-Redirect binding and publish at least one
+// RSA signing certificate.
+func ParseIdentityProviderMetadata(data []byte) (*IdentityProvider, error) {
+	var descriptor entityDescriptor
+	if err := xml.Unmarshal(data, &descriptor); err != nil {
+		return nil, fmt.Errorf("metadata: %w", err)
+	}
+	if descriptor.EntityID == "" {
+		return nil, errors.New("metadata: entityID is missing")
+	}
+
+	var sso *idpSSODescriptor
+	for i, candidate := range descriptor.IDPSSODescriptor {
+		if slices.Contains(strings.Fields(candidate.ProtocolSupportEnumeration), NamespaceProtocol) {
+			sso = &descriptor.IDPSSODescriptor[i]
+			break
+		}
+	}
+	if sso == nil {
+		return nil, errors.New("metadata: there is no IDPSSODescriptor for SAML 2.0")
+	}
+
+	idp := &IdentityProvider{EntityID: descriptor.EntityID}
+	for _, service := range sso.SingleSignOnServices {
+		if service.Binding == BindingHTTPRedirect {
+			idp.SSOURL = service.Location
+			break
+		}
+	}
+	if idp.SSOURL == "" {
+		return nil, errors.New("metadata: there is no SingleSignOnService with the HTTP-Redirect binding")
+	}
+
+	for _, key := range sso.KeyDescriptors {
+		if key.Use != "" && key.Use != "signing" {
+			continue
+		}
+		for _, data := range key.KeyInfo.X509Data {
+			for _, encoded := range data.X509Certificates {
+				der, err := decodeBase64(encoded)
+				if err != nil {
+					return nil, fmt.Errorf("metadata: certificate: %w", err)
+				}
+				certificate, err := x509.ParseCertificate(der)
+				if err != nil {
+					return nil, fmt.Errorf("metadata: certificate: %w", err)
+				}
+				if _, ok := certificate.PublicKey.(*rsa.PublicKey); !ok {
+					return nil, errors.New("metadata: only RSA signing certificates are supported")
+				}
+				idp.Certificates = append(idp.Certificates, certificate)
+			}
+		}
+	}
+	if len(idp.Certificates) == 0 {
+		return nil, errors.New("metadata: there is no signing certificate")
+	}
+
+	return idp, nil
+}
+
+func (idp *IdentityProvider) keys() []*rsa.PublicKey {
+	keys := make([]*rsa.PublicKey, 0, len(idp.Certificates))
+	for _, certificate := range idp.Certificates {
+		if key, ok := certificate.PublicKey.(*rsa.PublicKey); ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+type spEntityDescriptor struct {
+	XMLName         xml.Name        `xml:"urn:oasis:names:tc:SAML:2.0:metadata EntityDescriptor"`
+	EntityID        string          `xml:"entityID,attr"`
+	SPSSODescriptor spSSODescriptor `xml:"SPSSODescriptor"`
+}
+
+type spSSODescriptor struct {
+	AuthnRequestsSigned        bool            `xml:"AuthnRequestsSigned,attr"`
+	WantAssertionsSigned       bool            `xml:"WantAssertionsSigned,attr"`
+	ProtocolSupportEnumeration string          `xml:"protocolSupportEnumeration,attr"`
+	NameIDFormats              []string        `xml:"NameIDFormat"`
+	AssertionConsumerService   indexedEndpoint `xml:"AssertionConsumerService"`
+}
+
+type indexedEndpoint struct {
+	Binding   string `xml:"Binding,attr"`
+	Location  string `xml:"Location,attr"`
+	Index     int    `xml:"index,attr"`
+	IsDefault bool   `xml:"isDefault,attr"`
+}
+
+// Metadata returns the EntityDescriptor of the service provider (SAML
+// Metadata section 2.4.4), to register it at the identity provider. Its
+// AuthnRequests are not signed and it wants assertions to be.
+func (sp *ServiceProvider) Metadata() []byte {
+	descriptor := spEntityDescriptor{
+		EntityID: sp.EntityID,
+		SPSSODescriptor: spSSODescriptor{
+			WantAssertionsSigned:       true,
+			ProtocolSupportEnumeration: NamespaceProtocol,
+			NameIDFormats:              []string{NameIDFormatPersistent, NameIDFormatEmail},
+			AssertionConsumerService: indexedEndpoint{
+				Binding:   BindingHTTPPost,
+				Location:  sp.ACSURL,
+				IsDefault: true,
+			},
+		},
+	}
+
+	// Marshalling these types cannot fail.
+	data, _ := xml.MarshalIndent(descriptor, "", "  ")
+	return append([]byte(xml.Header), data...)
+}
@@ -0,0 +1,302 @@
+package saml
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// maxResponseSize bounds the encoded responses accepted.
+const maxResponseSize = 256 << 10
+
+// Assertion is what an identity provider asserted about the user who
+// signed in.
+type Assertion struct {
+	NameID       string
+	NameIDFormat string
+	SessionIndex string
+	Attributes   []Attribute
+}
+
+// Attribute is an attribute of the user, named as the identity provider
+// names it.
+type Attribute struct {
+	Name         string
+	FriendlyName string
+	Values       []string
+}
+
+// ParseResponse checks a response posted to the assertion consumer service
+// with the HTTP-POST binding (SAML Profiles section 4.1.4.3) and returns
+// its assertion. The response must answer the AuthnRequest requestID and
+// be signed by idp, as a whole or in its assertion, which must be the only
+// one, be meant for sp and be valid at now. Encrypted assertions are not
+// supported.
+func (sp *ServiceProvider) ParseResponse(idp *IdentityProvider, encoded, requestID string, now time.Time) (*Assertion, error) {
+	if len(encoded) > maxResponseSize {
+		return nil, errors.New("the response is too large")
+	}
+	data, err := decodeBase64(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("the response is not base64: %w", err)
+	}
+	response, err := ParseXML(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if !response.is(NamespaceProtocol, "Response") {
+		return nil, errors.New("the document is not a response")
+	}
+	if response.Attr("Version") != "2.0" {
+		return nil, errors.New("the response is not SAML 2.0")
+	}
+	if err := uniqueIDs(response); err != nil {
+		return nil, err
+	}
+	if destination := response.Attr("Destination"); response.hasAttr("Destination") && destination != sp.ACSURL {
+		return nil, fmt.Errorf("the response is for %q", destination)
+	}
+	if requestID == "" || response.Attr("InResponseTo") != requestID {
+		return nil, errors.New("the response does not answer the sign-in request")
+	}
+	if err := checkIssuer(response, idp, false); err != nil {
+		return nil, err
+	}
+	if err := checkStatus(response); err != nil {
+		return nil, err
+	}
+
+	if len(response.children(NamespaceAssertion, "EncryptedAssertion")) != 0 {
+		return nil, errors.New("encrypted assertions are not supported")
+	}
+	assertion, err := response.child(NamespaceAssertion, "Assertion")
+	if err != nil {
+		return nil, err
+	}
+
+	keys := idp.keys()
+	signed := false
+	for _, element := range []*Element{response, assertion} {
+		switch err := verifySignature(element, keys); {
+		case err == nil:
+			signed = true
+		case !errors.Is(err, errUnsigned):
+			return nil, fmt.Errorf("%s signature: %w", element.Local, err)
+		}
+	}
+	if !signed {
+		return nil, errors.New("neither the response nor its assertion is signed")
+	}
+
+	return sp.checkAssertion(assertion, idp, requestID, now)
+}
+
+// checkAssertion checks the assertion of a response (SAML Profiles section
+// 4.1.4.3) and reads it.
+func (sp *ServiceProvider) checkAssertion(assertion *Element, idp *IdentityProvider, requestID string, now time.Time) (*Assertion, error) {
+	if assertion.Attr("Version") != "2.0" {
+		return nil, errors.New("the assertion is not SAML 2.0")
+	}
+	if err := checkIssuer(assertion, idp, true); err != nil {
+		return nil, err
+	}
+
+	subject, err := assertion.child(NamespaceAssertion, "Subject")
+	if err != nil {
+		return nil, err
+	}
+	nameID, err := subject.child(NamespaceAssertion, "NameID")
+	if err != nil {
+		return nil, err
+	}
+	if nameID.Text() == "" {
+		return nil, errors.New("the NameID is empty")
+	}
+	if err := sp.checkSubjectConfirmation(subject, requestID, now); err != nil {
+		return nil, err
+	}
+
+	conditions, err := assertion.child(NamespaceAssertion, "Conditions")
+	if err != nil {
+		return nil, err
+	}
+	if err := sp.checkConditions(conditions, now); err != nil {
+		return nil, err
+	}
+
+	statements := assertion.children(NamespaceAssertion, "AuthnStatement")
+	if len(statements) == 0 {
+		return nil, errors.New("the assertion has no AuthnStatement")
+	}
+	for _, statement := range statements {
+		if err := checkNotOnOrAfter(statement, "SessionNotOnOrAfter", now); err != nil {
+			return nil, fmt.Errorf("the session: %w", err)
+		}
+	}
+
+	result := &Assertion{
+		NameID:       nameID.Text(),
+		NameIDFormat: nameID.Attr("Format"),
+		SessionIndex: statements[0].Attr("SessionIndex"),
+	}
+	for _, statement := range assertion.children(NamespaceAssertion, "AttributeStatement") {
+		for _, attribute := range statement.children(NamespaceAssertion, "Attribute") {
+			values := []string{}
+			for _, value := range attribute.children(NamespaceAssertion, "AttributeValue") {
+				values = append(values, value.Text())
+			}
+			result.Attributes = append(result.Attributes, Attribute{
+				Name:         attribute.Attr("Name"),
+				FriendlyName: attribute.Attr("FriendlyName"),
+				Values:       values,
+			})
+		}
+	}
+
+	return result, nil
+}
+
+// checkSubjectConfirmation requires a bearer confirmation of the subject
+// for the assertion consumer service that has not expired.
+func (sp *ServiceProvider) checkSubjectConfirmation(subject *Element, requestID string, now time.Time) error {
+	err := errors.New("the subject has no bearer confirmation")
+	for _, confirmation := range subject.children(NamespaceAssertion, "SubjectConfirmation") {
+		if confirmation.Attr("Method") != subjectConfirmationBearer {
+			continue
+		}
+		data, dataErr := confirmation.child(NamespaceAssertion, "SubjectConfirmationData")
+		switch {
+		case dataErr != nil:
+			err = dataErr
+		case data.Attr("Recipient") != sp.ACSURL:
+			err = fmt.Errorf("the subject is confirmed for %q", data.Attr("Recipient"))
+		case data.hasAttr("InResponseTo") && data.Attr("InResponseTo") != requestID:
+			err = errors.New("the subject confirmation does not answer the sign-in request")
+		case data.hasAttr("NotBefore"):
+			err = errors.New("a bearer confirmation must not have NotBefore")
+		case !data.hasAttr("NotOnOrAfter"):
+			err = errors.New("the subject confirmation has no NotOnOrAfter")
+		default:
+			if err = checkNotOnOrAfter(data, "NotOnOrAfter", now); err == nil {
+				return nil
+			}
+			err = fmt.Errorf("the subject confirmation: %w", err)
+		}
+	}
+	return err
+}
+
+// checkConditions checks the validity period and audience of an
+// assertion. Every AudienceRestriction must name the service provider,
+// and there must be at least one.
+func (sp *ServiceProvider) checkConditions(conditions *Element, now time.Time) error {
+	if conditions.hasAttr("NotBefore") {
+		notBefore, err := parseTime(conditions.Attr("NotBefore"))
+		if err != nil {
+			return err
+		}
+		if now.Add(clockSkew).Before(notBefore) {
+			return errors.New("the assertion is not valid yet")
+		}
+	}
+	if err := checkNotOnOrAfter(conditions, "NotOnOrAfter", now); err != nil {
+		return fmt.Errorf("the assertion: %w", err)
+	}
+
+	restrictions := conditions.children(NamespaceAssertion, "AudienceRestriction")
+	if len(restrictions) == 0 {
+		return errors.New("the assertion has no audience")
+	}
+	for _, restriction := range restrictions {
+		found := false
+		for _, audience := range restriction.children(NamespaceAssertion, "Audience") {
+			found = found || audience.Text() == sp.EntityID
+		}
+		if !found {
+			return errors.New("the assertion is meant for another service provider")
+		}
+	}
+
+	return nil
+}
+
+// checkIssuer checks the Issuer of a response or assertion, which only
+// responses may leave out.
+func checkIssuer(e *Element, idp *IdentityProvider, required bool) error {
+	issuer, err := e.optionalChild(NamespaceAssertion, "Issuer")
+	if err != nil {
+		return err
+	}
+	if issuer == nil {
+		if required {
+			return fmt.Errorf("the %s has no issuer", e.Local)
+		}
+		return nil
+	}
+	if issuer.Text() != idp.EntityID {
+		return fmt.Errorf("the %s was issued by %q", e.Local, issuer.Text())
+	}
+	return nil
+}
+
+// checkStatus fails unless the response reports success.
+func checkStatus(response *Element) error {
+	status, err := response.child(NamespaceProtocol, "Status")
+	if err != nil {
+		return err
+	}
+	code, err := status.child(NamespaceProtocol, "StatusCode")
+	if err != nil {
+		return err
+	}
+	if code.Attr("Value") == statusSuccess {
+		return nil
+	}
+
+	value := code.Attr("Value")
+	if second, _ := code.optionalChild(NamespaceProtocol, "StatusCode"); second != nil {
+		value += " " + second.Attr("Value")
+	}
+	return fmt.Errorf("the identity provider answered %s", value)
+}
+
+// checkNotOnOrAfter fails if the time in attribute name of e has passed.
+// Without the attribute there is no limit.
+func checkNotOnOrAfter(e *Element, name string, now time.Time) error {
+	if !e.hasAttr(name) {
+		return nil
+	}
+	notOnOrAfter, err := parseTime(e.Attr(name))
+	if err != nil {
+		return err
+	}
+	if !now.Add(-clockSkew).Before(notOnOrAfter) {
+		return errors.New("it has expired")
+	}
+	return nil
+}
+
+// uniqueIDs fails if two elements of a document have the same ID, so a
+// signature can only reference one element.
+func uniqueIDs(root *Element) error {
+	seen := map[string]bool{}
+	var err error
+	root.walk(func(e *Element) {
+		if id := e.Attr("ID"); e.hasAttr("ID") {
+			if seen[id] {
+				err = fmt.Errorf("ID %q is used more than once", id)
+			}
+			seen[id] = true
+		}
+	})
+	return err
+}
+
+func parseTime(value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q", value)
+	}
+	return t, nil
+}
@@ -0,0 +1,47 @@
+// Package saml implements the service provider side of SAML 2.0 Web
+// Browser SSO: AuthnRequests sent with the HTTP-Redirect binding and
+// responses received at the assertion consumer service with the HTTP-POST
+// binding, whose signatures are checked against the certificates in the
+// identity provider's metadata.
+package saml
+
+import "time"
+
+// XML namespaces of SAML 2.0 and XML Signature.
+const (
+	NamespaceAssertion = "urn:oasis:names:tc:SAML:2.0:assertion"
+	NamespaceProtocol  = "urn:oasis:names:tc:SAML:2.0:protocol"
+	NamespaceMetadata  = "urn:oasis:names:tc:SAML:2.0:metadata"
+	NamespaceSignature = "http://www.w3.org/2000/09/xmldsig#"
+)
+
+// Bindings of SAML 2.0 (SAML Bindings section 3).
+const (
+	BindingHTTPRedirect = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect"
+	BindingHTTPPost     = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+)
+
+// Name identifier formats the service provider treats specially.
+const (
+	NameIDFormatEmail      = "urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress"
+	NameIDFormatPersistent = "urn:oasis:names:tc:SAML:2.0:nameid-format:persistent"
+	NameIDFormatTransient  = "urn:oasis:names:tc:SAML:2.0:nameid-format:transient"
+)
+
+const (
+	statusSuccess             = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	subjectConfirmationBearer = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+)
+
+// clockSkew is the leeway given to the validity periods of responses, since
+// the identity provider's clock may differ from ours.
+const clockSkew = 3 * time.Minute
+
+// ServiceProvider is this service as a SAML service provider to one
+// identity provider. EntityID names it in AuthnRequests and must be the
+// audience of the assertions it accepts; ACSURL is its assertion consumer
+// service, where responses are posted.
+type ServiceProvider struct {
+	EntityID string
+	ACSURL   string
+}
@@ -0,0 +1,236 @@
+// Package samltest provides a SAML identity provider for tests, with its
+// own key and certificate, that issues signed responses.
+package samltest
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/ssoydabas/auth-service/internal/saml"
+)
+
+// IdentityProvider issues responses as EntityID, signed with Key, whose
+// certificate is in its metadata.
+type IdentityProvider struct {
+	EntityID    string
+	SSOURL      string
+	Key         *rsa.PrivateKey
+	Certificate *x509.Certificate
+}
+
+// NewIdentityProvider generates the key and self-signed certificate of an
+// identity provider.
+func NewIdentityProvider(entityID, ssoURL string) (*IdentityProvider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	certificate, err := selfSigned(key)
+	if err != nil {
+		return nil, err
+	}
+	return &IdentityProvider{EntityID: entityID, SSOURL: ssoURL, Key: key, Certificate: certificate}, nil
+}
+
+func selfSigned(key *rsa.PrivateKey) (*x509.Certificate, error) {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "samltest"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(der)
+}
+
+// Metadata returns the EntityDescriptor of the identity provider.
+func (idp *IdentityProvider) Metadata() []byte {
+	return []byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<md:EntityDescriptor xmlns:md="%s" xmlns:ds="%s" entityID="%s">
+  <md:IDPSSODescriptor protocolSupportEnumeration="%s" WantAuthnRequestsSigned="false">
+    <md:KeyDescriptor use="signing">
+      <ds:KeyInfo><ds:X509Data><ds:X509Certificate>%s</ds:X509Certificate></ds:X509Data></ds:KeyInfo>
+    </md:KeyDescriptor>
+    <md:NameIDFormat>%s</md:NameIDFormat>
+    <md:SingleSignOnService Binding="%s" Location="%s"/>
+    <md:SingleSignOnService Binding="%s" Location="%s"/>
+  </md:IDPSSODescriptor>
+</md:EntityDescriptor>
+`, saml.NamespaceMetadata, saml.NamespaceSignature, idp.EntityID, saml.NamespaceProtocol,
+		base64.StdEncoding.EncodeToString(idp.Certificate.Raw), saml.NameIDFormatPersistent,
+		saml.BindingHTTPPost, idp.SSOURL, saml.BindingHTTPRedirect, idp.SSOURL))
+}
+
+// Response describes a response to an AuthnRequest. Zero times default to
+// the issue instant and validity periods of a fresh response.
+type Response struct {
+	InResponseTo string
+	Destination  string
+	Audience     string
+	NameID       string
+	NameIDFormat string
+	Attributes   map[string]string
+	IssueInstant time.Time
+	NotOnOrAfter time.Time
+	Status       string
+
+	SignResponse  bool
+	SignAssertion bool
+}
+
+// IDs of the elements of a response.
+const (
+	ResponseID  = "_response-1"
+	AssertionID = "_assertion-1"
+)
+
+var responseTemplate = template.Must(template.New("response").Parse(`<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="` + ResponseID + `" Version="2.0" IssueInstant="{{.IssueInstant}}" Destination="{{.Destination}}" InResponseTo="{{.InResponseTo}}">
+  <saml:Issuer>{{.Issuer}}</saml:Issuer>{{if .SignResponse}}<!--signature:` + ResponseID + `-->{{end}}
+  <samlp:Status><samlp:StatusCode Value="{{.Status}}"/></samlp:Status>
+  <saml:Assertion xmlns:xs="http://www.w3.org/2001/XMLSchema" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" ID="` + AssertionID + `" Version="2.0" IssueInstant="{{.IssueInstant}}">
+    <saml:Issuer>{{.Issuer}}</saml:Issuer>{{if .SignAssertion}}<!--signature:` + AssertionID + `-->{{end}}
+    <saml:Subject>
+      <saml:NameID Format="{{.NameIDFormat}}">{{.NameID}}</saml:NameID>
+      <saml:SubjectConfirmation Method="urn:oasis:names:tc:SAML:2.0:cm:bearer">
+        <saml:SubjectConfirmationData InResponseTo="{{.InResponseTo}}" NotOnOrAfter="{{.NotOnOrAfter}}" Recipient="{{.Destination}}"/>
+      </saml:SubjectConfirmation>
+    </saml:Subject>
+    <saml:Conditions NotBefore="{{.IssueInstant}}" NotOnOrAfter="{{.NotOnOrAfter}}">
+      <saml:AudienceRestriction><saml:Audience>{{.Audience}}</saml:Audience></saml:AudienceRestriction>
+    </saml:Conditions>
+    <saml:AuthnStatement AuthnInstant="{{.IssueInstant}}" SessionIndex="_session-1">
+      <saml:AuthnContext><saml:AuthnContextClassRef>urn:oasis:names:tc:SAML:2.0:ac:classes:PasswordProtectedTransport</saml:AuthnContextClassRef></saml:AuthnContext>
+    </saml:AuthnStatement>
+    <saml:AttributeStatement>{{range $name, $value := .Attributes}}
+      <saml:Attribute Name="{{$name}}" NameFormat="urn:oasis:names:tc:SAML:2.0:attrname-format:uri"><saml:AttributeValue xsi:type="xs:string">{{$value}}</saml:AttributeValue></saml:Attribute>{{end}}
+    </saml:AttributeStatement>
+  </saml:Assertion>
+</samlp:Response>`))
+
+// Response returns the XML of a response, signed as asked.
+func (idp *IdentityProvider) Response(r Response) (string, error) {
+	if r.IssueInstant.IsZero() {
+		r.IssueInstant = time.Now()
+	}
+	if r.NotOnOrAfter.IsZero() {
+		r.NotOnOrAfter = r.IssueInstant.Add(5 * time.Minute)
+	}
+	if r.NameIDFormat == "" {
+		r.NameIDFormat = saml.NameIDFormatPersistent
+	}
+	if r.Status == "" {
+		r.Status = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	}
+
+	var out bytes.Buffer
+	if err := responseTemplate.Execute(&out, map[string]any{
+		"Issuer":        idp.EntityID,
+		"InResponseTo":  r.InResponseTo,
+		"Destination":   r.Destination,
+		"Audience":      r.Audience,
+		"NameID":        r.NameID,
+		"NameIDFormat":  r.NameIDFormat,
+		"Attributes":    r.Attributes,
+		"IssueInstant":  r.IssueInstant.UTC().Format(time.RFC3339),
+		"NotOnOrAfter":  r.NotOnOrAfter.UTC().Format(time.RFC3339),
+		"Status":        r.Status,
+		"SignResponse":  r.SignResponse,
+		"SignAssertion": r.SignAssertion,
+	}); err != nil {
+		return "", err
+	}
+
+	doc := out.String()
+	// The assertion is signed first, since the response's signature covers
+	// it.
+	if r.SignAssertion {
+		signed, err := idp.Sign(doc, AssertionID)
+		if err != nil {
+			return "", err
+		}
+		doc = signed
+	}
+	if r.SignResponse {
+		signed, err := idp.Sign(doc, ResponseID)
+		if err != nil {
+			return "", err
+		}
+		doc = signed
+	}
+	return doc, nil
+}
+
+// EncodedResponse is Response encoded for the HTTP-POST binding.
+func (idp *IdentityProvider) EncodedResponse(r Response) (string, error) {
+	doc, err := idp.Response(r)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString([]byte(doc)), nil
+}
+
+// Sign signs the element of doc with the given ID with RSA-SHA256, putting
+// the signature in place of the comment <!--signature:ID-->.
+func (idp *IdentityProvider) Sign(doc, id string) (string, error) {
+	placeholder := "<!--signature:" + id + "-->"
+	if !strings.Contains(doc, placeholder) {
+		return "", fmt.Errorf("there is no place for the signature of %s", id)
+	}
+
+	root, err := saml.ParseXML([]byte(doc))
+	if err != nil {
+		return "", err
+	}
+	element := find(root, id)
+	if element == nil {
+		return "", fmt.Errorf("there is no element %s", id)
+	}
+	digest := sha256.Sum256(saml.Canonicalize(element, nil))
+
+	signedInfo := fmt.Sprintf(`<ds:SignedInfo xmlns:ds="%s"><ds:CanonicalizationMethod Algorithm="%s"/><ds:SignatureMethod Algorithm="%s"/><ds:Reference URI="#%s"><ds:Transforms><ds:Transform Algorithm="%s"/><ds:Transform Algorithm="%s"/></ds:Transforms><ds:DigestMethod Algorithm="%s"/><ds:DigestValue>%s</ds:DigestValue></ds:Reference></ds:SignedInfo>`,
+		saml.NamespaceSignature, saml.AlgorithmExclusiveC14N, saml.AlgorithmRSASHA256, id,
+		saml.AlgorithmEnvelopedSignature, saml.AlgorithmExclusiveC14N, saml.AlgorithmSHA256,
+		base64.StdEncoding.EncodeToString(digest[:]))
+	signedInfoElement, err := saml.ParseXML([]byte(signedInfo))
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(saml.Canonicalize(signedInfoElement, nil))
+	value, err := rsa.SignPKCS1v15(rand.Reader, idp.Key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+
+	signature := fmt.Sprintf(`<ds:Signature xmlns:ds="%s">%s<ds:SignatureValue>%s</ds:SignatureValue><ds:KeyInfo><ds:X509Data><ds:X509Certificate>%s</ds:X509Certificate></ds:X509Data></ds:KeyInfo></ds:Signature>`,
+		saml.NamespaceSignature, signedInfo, base64.StdEncoding.EncodeToString(value),
+		base64.StdEncoding.EncodeToString(idp.Certificate.Raw))
+	return strings.Replace(doc, placeholder, signature, 1), nil
+}
+
+func find(e *saml.Element, id string) *saml.Element {
+	if e.Attr("ID") == id {
+		return e
+	}
+	for _, child := range e.Children {
+		if child, ok := child.(*saml.Element); ok {
+			if found := find(child, id); found != nil {
+				return found
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package saml
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	// Register the hashes signatures may use.
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+// Algorithms of XML Signature the service provider accepts. SHA-1 is not
+// among them.
+const (
+	AlgorithmEnvelopedSignature = "http://www.w3.org/2000/09/xmldsig#enveloped-signature"
+	AlgorithmRSASHA256          = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	AlgorithmRSASHA512          = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha512"
+	AlgorithmSHA256             = "http://www.w3.org/2001/04/xmlenc#sha256"
+	AlgorithmSHA512             = "http://www.w3.org/2001/04/xmlenc#sha512"
+)
+
+var (
+	signatureHashes = map[string]crypto.Hash{AlgorithmRSASHA256: crypto.SHA256, AlgorithmRSASHA512: crypto.SHA512}
+	digestHashes    = map[string]crypto.Hash{AlgorithmSHA256: crypto.SHA256, AlgorithmSHA512: crypto.SHA512}
+)
+
+// errUnsigned is returned by verifySignature for an element without a
+// signature.
+var errUnsigned = errors.New("not signed")
+
+// verifySignature checks the enveloped signature of e (XML Signature
+// section 3.2) against keys. The signature must be a child of e and have a
+// single reference, to e by its ID, so that it covers e and nothing else;
+// only exclusive canonicalization and the enveloped signature transform
+// are accepted. The key info of the signature is ignored: only keys from
+// the identity provider's metadata are trusted.
+func verifySignature(e *Element, keys []*rsa.PublicKey) error {
+	signature, err := e.optionalChild(NamespaceSignature, "Signature")
+	if err != nil {
+		return err
+	}
+	if signature == nil {
+		return errUnsigned
+	}
+
+	signedInfo, err := signature.child(NamespaceSignature, "SignedInfo")
+	if err != nil {
+		return err
+	}
+	canonicalization, err := signedInfo.child(NamespaceSignature, "CanonicalizationMethod")
+	if err != nil {
+		return err
+	}
+	if algorithm := canonicalization.Attr("Algorithm"); algorithm != AlgorithmExclusiveC14N {
+		return fmt.Errorf("canonicalization %q is not supported", algorithm)
+	}
+	signatureMethod, err := signedInfo.child(NamespaceSignature, "SignatureMethod")
+	if err != nil {
+		return err
+	}
+	signatureHash, ok := signatureHashes[signatureMethod.Attr("Algorithm")]
+	if !ok {
+		return fmt.Errorf("signature algorithm %q is not supported", signatureMethod.Attr("Algorithm"))
+	}
+
+	reference, err := signedInfo.child(NamespaceSignature, "Reference")
+	if err != nil {
+		return err
+	}
+	if id := e.Attr("ID"); id == "" || reference.Attr("URI") != "#"+id {
+		return errors.New("the signature does not reference the signed element")
+	}
+	prefixes, err := referenceTransforms(reference)
+	if err != nil {
+		return err
+	}
+	digestMethod, err := reference.child(NamespaceSignature, "DigestMethod")
+	if err != nil {
+		return err
+	}
+	digestHash, ok := digestHashes[digestMethod.Attr("Algorithm")]
+	if !ok {
+		return fmt.Errorf("digest algorithm %q is not supported", digestMethod.Attr("Algorithm"))
+	}
+	digestValue, err := reference.child(NamespaceSignature, "DigestValue")
+	if err != nil {
+		return err
+	}
+	expected, err := decodeBase64(digestValue.Text())
+	if err != nil {
+		return fmt.Errorf("digest value: %w", err)
+	}
+
+	digest := digestHash.New()
+	digest.Write(canonicalize(e, prefixes, signature))
+	if subtle.ConstantTimeCompare(digest.Sum(nil), expected) != 1 {
+		return errors.New("the signed element has been modified")
+	}
+
+	signatureValue, err := signature.child(NamespaceSignature, "SignatureValue")
+	if err != nil {
+		return err
+	}
+	value, err := decodeBase64(signatureValue.Text())
+	if err != nil {
+		return fmt.Errorf("signature value: %w", err)
+	}
+
+	hash := signatureHash.New()
+	hash.Write(canonicalize(signedInfo, inclusivePrefixes(canonicalization), nil))
+	sum := hash.Sum(nil)
+	for _, key := range keys {
+		if rsa.VerifyPKCS1v15(key, signatureHash, sum, value) == nil {
+			return nil
+		}
+	}
+	return errors.New("the signature was not made with a key of the identity provider")
+}
+
+// referenceTransforms checks the transforms of a reference and returns the
+// inclusive prefixes of its canonicalization. Both the enveloped signature
+// transform and exclusive canonicalization are required.
+func referenceTransforms(reference *Element) ([]string, error) {
+	transforms, err := reference.child(NamespaceSignature, "Transforms")
+	if err != nil {
+		return nil, err
+	}
+
+	var prefixes []string
+	enveloped, canonicalized := false, false
+	for _, transform := range transforms.children(NamespaceSignature, "Transform") {
+		switch transform.Attr("Algorithm") {
+		case AlgorithmEnvelopedSignature:
+			enveloped = true
+		case AlgorithmExclusiveC14N:
+			canonicalized = true
+			prefixes = inclusivePrefixes(transform)
+		default:
+			return nil, fmt.Errorf("transform %q is not supported", transform.Attr("Algorithm"))
+		}
+	}
+	if !enveloped || !canonicalized {
+		return nil, errors.New("the signature must be enveloped and exclusively canonicalized")
+	}
+
+	return prefixes, nil
+}
+
+// inclusivePrefixes returns the InclusiveNamespaces PrefixList of an
+// exclusive canonicalization.
+func inclusivePrefixes(method *Element) []string {
+	var prefixes []string
+	for _, inclusive := range method.children(AlgorithmExclusiveC14N, "InclusiveNamespaces") {
+		prefixes = append(prefixes, strings.Fields(inclusive.Attr("PrefixList"))...)
+	}
+	return prefixes
+}
+
+// decodeBase64 decodes base64 that may be broken into lines.
+func decodeBase64(s string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(s), ""))
+}
@@ -0,0 +1,208 @@
+package saml
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ssoydabas/auth-service/internal/saml"
+	"github.com/ssoydabas/auth-service/internal/saml/samltest"
+	"github.com/stretchr/testify/suite"
+)
+
+const (
+	testEntityID  = "https://auth.example.com/oauth/federated/acme/metadata"
+	testACSURL    = "https://auth.example.com/oauth/federated/acme/acs"
+	testIdPEntity = "https://idp.acme.example.com/saml"
+	testRequestID = "_request-1"
+)
+
+type SAMLTestSuite struct {
+	suite.Suite
+	idp   *samltest.IdentityProvider
+	sp    *saml.ServiceProvider
+	idpMD *saml.IdentityProvider
+}
+
+func (suite *SAMLTestSuite) SetupSuite() {
+	idp, err := samltest.NewIdentityProvider(testIdPEntity, "https://idp.acme.example.com/saml/sso")
+	suite.Require().NoError(err)
+	suite.idp = idp
+
+	suite.idpMD, err = saml.ParseIdentityProviderMetadata(idp.Metadata())
+	suite.Require().NoError(err)
+	suite.sp = &saml.ServiceProvider{EntityID: testEntityID, ACSURL: testACSURL}
+}
+
+func (suite *SAMLTestSuite) response() samltest.Response {
+	return samltest.Response{
+		InResponseTo:  testRequestID,
+		Destination:   testACSURL,
+		Audience:      testEntityID,
+		NameID:        "u-1234",
+		Attributes:    map[string]string{"urn:oid:0.9.2342.19200300.100.1.3": "ada@acme.example.com", "urn:oid:2.5.4.42": "Ada"},
+		SignAssertion: true,
+	}
+}
+
+func (suite *SAMLTestSuite) parse(doc string) (*saml.Assertion, error) {
+	return suite.sp.ParseResponse(suite.idpMD, base64.StdEncoding.EncodeToString([]byte(doc)), testRequestID, time.Now())
+}
+
+func (suite *SAMLTestSuite) TestCanonicalize() {
+	// Exclusive XML Canonicalization 1.0 section 2.2: a namespace is only
+	// declared where it is used, and n3 moves down to its element.
+	root, err := saml.ParseXML([]byte(`<n0:local xmlns:n0="foo:bar" xmlns:n3="ftp://example.org"><n1:elem2 xmlns:n1="http://example.net" xml:lang="en"><n3:stuff xmlns:n3="ftp://example.org"/></n1:elem2></n0:local>`))
+	suite.Require().NoError(err)
+	elem2 := root.Children[0].(*saml.Element)
+	suite.Equal(`<n1:elem2 xmlns:n1="http://example.net" xml:lang="en"><n3:stuff xmlns:n3="ftp://example.org"></n3:stuff></n1:elem2>`,
+		string(saml.Canonicalize(elem2, nil)))
+	suite.Equal(`<n1:elem2 xmlns:n1="http://example.net" xmlns:n3="ftp://example.org" xml:lang="en"><n3:stuff></n3:stuff></n1:elem2>`,
+		string(saml.Canonicalize(elem2, []string{"n3"})))
+
+	root, err = saml.ParseXML([]byte("<?xml version=\"1.0\"?>\n<doc xmlns=\"urn:d\" b='2' xmlns:x=\"urn:x\" x:a=\"1\" a=\"&quot;&#9;\"><!-- dropped -->a &amp; b &gt; c\r\n<e/><y xmlns=\"\"/></doc>"))
+	suite.Require().NoError(err)
+	suite.Equal("<doc xmlns=\"urn:d\" xmlns:x=\"urn:x\" a=\"&quot;&#x9;\" b=\"2\" x:a=\"1\">a &amp; b &gt; c\n<e></e><y xmlns=\"\"></y></doc>",
+		string(saml.Canonicalize(root, nil)))
+}
+
+func (suite *SAMLTestSuite) TestParseXMLRejectsUnsafeDocuments() {
+	invalid := []string{
+		`<!DOCTYPE r [<!ENTITY a "aaaa">]><r>&a;</r>`,
+		`<r><a></b></r>`,
+		`<r><p:a/></r>`,
+		`<r/><r/>`,
+		`<r><?pi data?></r>`,
+		`<r a="1" a="2"/>`,
+		strings.Repeat("<a>", 100) + strings.Repeat("</a>", 100),
+	}
+	for _, doc := range invalid {
+		_, err := saml.ParseXML([]byte(doc))
+		suite.Error(err, doc)
+	}
+}
+
+func (suite *SAMLTestSuite) TestMetadata() {
+	suite.Equal(testIdPEntity, suite.idpMD.EntityID)
+	suite.Equal("https://idp.acme.example.com/saml/sso", suite.idpMD.SSOURL)
+	suite.Require().Len(suite.idpMD.Certificates, 1)
+	suite.Equal(suite.idp.Certificate.Raw, suite.idpMD.Certificates[0].Raw)
+
+	metadata := string(suite.sp.Metadata())
+	suite.Contains(metadata, `entityID="`+testEntityID+`"`)
+	suite.Contains(metadata, `WantAssertionsSigned="true"`)
+	suite.Contains(metadata, `Binding="`+saml.BindingHTTPPost+`" Location="`+testACSURL+`"`)
+
+	_, err := saml.ParseIdentityProviderMetadata([]byte(strings.Replace(string(suite.idp.Metadata()), saml.BindingHTTPRedirect, "urn:other", 1)))
+	suite.Error(err, "no HTTP-Redirect endpoint")
+	_, err = saml.ParseIdentityProviderMetadata([]byte(strings.Replace(string(suite.idp.Metadata()), `use="signing"`, `use="encryption"`, 1)))
+	suite.Error(err, "no signing certificate")
+}
+
+func (suite *SAMLTestSuite) TestAuthnRequestURL() {
+	redirectURL, id, err := suite.sp.AuthnRequestURL(suite.idpMD, "relay-state", time.Now())
+	suite.Require().NoError(err)
+
+	u, err := url.Parse(redirectURL)
+	suite.Require().NoError(err)
+	suite.Equal("idp.acme.example.com", u.Host)
+	suite.Equal("relay-state", u.Query().Get("RelayState"))
+
+	deflated, err := base64.StdEncoding.DecodeString(u.Query().Get("SAMLRequest"))
+	suite.Require().NoError(err)
+	request, err := io.ReadAll(flate.NewReader(bytes.NewReader(deflated)))
+	suite.Require().NoError(err)
+
+	root, err := saml.ParseXML(request)
+	suite.Require().NoError(err)
+	suite.Equal("AuthnRequest", root.Local)
+	suite.Equal(saml.NamespaceProtocol, root.Space)
+	suite.Equal(id, root.Attr("ID"))
+	suite.Equal(testACSURL, root.Attr("AssertionConsumerServiceURL"))
+	suite.Equal(saml.BindingHTTPPost, root.Attr("ProtocolBinding"))
+	suite.Equal("https://idp.acme.example.com/saml/sso", root.Attr("Destination"))
+	suite.Contains(string(request), testEntityID)
+}
+
+func (suite *SAMLTestSuite) TestParseResponse() {
+	for name, sign := range map[string][2]bool{"assertion": {false, true}, "response": {true, false}, "both": {true, true}} {
+		response := suite.response()
+		response.SignResponse, response.SignAssertion = sign[0], sign[1]
+		doc, err := suite.idp.Response(response)
+		suite.Require().NoError(err)
+
+		assertion, err := suite.parse(doc)
+		suite.Require().NoError(err, name)
+		suite.Equal("u-1234", assertion.NameID)
+		suite.Equal(saml.NameIDFormatPersistent, assertion.NameIDFormat)
+		suite.Equal("_session-1", assertion.SessionIndex)
+		suite.ElementsMatch([]saml.Attribute{
+			{Name: "urn:oid:0.9.2342.19200300.100.1.3", Values: []string{"ada@acme.example.com"}},
+			{Name: "urn:oid:2.5.4.42", Values: []string{"Ada"}},
+		}, assertion.Attributes)
+	}
+}
+
+func (suite *SAMLTestSuite) TestParseResponseRejectsInvalidResponses() {
+	cases := map[string]func(*samltest.Response){
+		"unsigned":          func(r *samltest.Response) { r.SignAssertion = false },
+		"other request":     func(r *samltest.Response) { r.InResponseTo = "_other" },
+		"other destination": func(r *samltest.Response) { r.Destination = "https://evil.example.com/acs" },
+		"other audience":    func(r *samltest.Response) { r.Audience = "https://other-sp.example.com" },
+		"expired":           func(r *samltest.Response) { r.IssueInstant = time.Now().Add(-time.Hour) },
+		"not yet valid":     func(r *samltest.Response) { r.IssueInstant = time.Now().Add(time.Hour) },
+		"failed":            func(r *samltest.Response) { r.Status = "urn:oasis:names:tc:SAML:2.0:status:Responder" },
+	}
+	for name, modify := range cases {
+		response := suite.response()
+		modify(&response)
+		doc, err := suite.idp.Response(response)
+		suite.Require().NoError(err)
+
+		_, err = suite.parse(doc)
+		suite.Error(err, name)
+	}
+}
+
+func (suite *SAMLTestSuite) TestParseResponseRejectsForgeries() {
+	doc, err := suite.idp.Response(suite.response())
+	suite.Require().NoError(err)
+
+	other, err := samltest.NewIdentityProvider(testIdPEntity, "https://idp.acme.example.com/saml/sso")
+	suite.Require().NoError(err)
+	forged, err := other.Response(suite.response())
+	suite.Require().NoError(err)
+	_, err = suite.parse(forged)
+	suite.Error(err, "signed with another key")
+
+	_, err = suite.parse(strings.Replace(doc, "u-1234", "admin", 1))
+	suite.Error(err, "modified after signing")
+
+	_, err = suite.parse(strings.Replace(doc, "ada@acme.example.com", "ada@acme.example.com<!---->.evil.example.com", 1))
+	suite.Error(err, "comments do not hide text from the signature")
+
+	// A signed assertion moved aside and replaced by an unsigned one with
+	// the same ID.
+	start, end := strings.Index(doc, "<saml:Assertion"), strings.Index(doc, "</saml:Assertion>")+len("</saml:Assertion>")
+	signed := doc[start:end]
+	unsigned, err := suite.idp.Response(samltest.Response{
+		InResponseTo: testRequestID, Destination: testACSURL, Audience: testEntityID, NameID: "admin",
+	})
+	suite.Require().NoError(err)
+	evil := unsigned[strings.Index(unsigned, "<saml:Assertion") : strings.Index(unsigned, "</saml:Assertion>")+len("</saml:Assertion>")]
+	wrapped := doc[:start] + "<samlp:Extensions>" + signed + "</samlp:Extensions>" + evil + doc[end:]
+	_, err = suite.parse(wrapped)
+	suite.Error(err, "signature wrapping")
+
+	_, err = suite.parse(doc[:end] + evil + doc[end:])
+	suite.Error(err, "second assertion")
+}
+
+func TestSAMLSuite(t *testing.T) {
+	suite.Run(t, new(SAMLTestSuite))
+}
@@ -0,0 +1,278 @@
+package saml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// xmlNamespace is the namespace the xml prefix is always bound to.
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+
+// maxDepth bounds the nesting of elements in a parsed document.
+const maxDepth = 64
+
+// Node is an *Element or a Text of a parsed document.
+type Node interface {
+	node()
+}
+
+// Text is character data, with references resolved and line endings
+// normalized.
+type Text string
+
+func (Text) node() {}
+
+// Attr is an attribute as written. Space is the namespace its prefix is
+// bound to; attributes without a prefix have no namespace.
+type Attr struct {
+	Prefix string
+	Local  string
+	Space  string
+	Value  string
+}
+
+// Element is an element of a parsed document. Names keep the prefixes they
+// were written with, which canonicalization needs, and Space is the
+// namespace the prefix is bound to. Namespace declarations are not among
+// Attrs.
+type Element struct {
+	Prefix   string
+	Local    string
+	Space    string
+	Attrs    []Attr
+	Children []Node
+
+	namespaces map[string]string
+	parent     *Element
+}
+
+func (*Element) node() {}
+
+// ParseXML parses a document into a tree. Comments are dropped, as
+// canonicalization without comments does, and adjacent text is merged, so
+// a comment cannot split the text of an element. Document type
+// declarations and processing instructions inside the document are
+// rejected.
+func ParseXML(data []byte) (*Element, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+
+	var root, current *Element
+	depth := 0
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			if root != nil && current == nil {
+				return nil, errors.New("xml: the document has more than one root element")
+			}
+			if depth++; depth > maxDepth {
+				return nil, errors.New("xml: the document is nested too deeply")
+			}
+			element, err := newElement(token, current)
+			if err != nil {
+				return nil, err
+			}
+			if current == nil {
+				root = element
+			} else {
+				current.Children = append(current.Children, element)
+			}
+			current = element
+		case xml.EndElement:
+			if current == nil || token.Name.Space != current.Prefix || token.Name.Local != current.Local {
+				return nil, fmt.Errorf("xml: unexpected end element </%s>", qualifiedName(token.Name.Space, token.Name.Local))
+			}
+			current = current.parent
+			depth--
+		case xml.CharData:
+			if current == nil {
+				if len(bytes.TrimSpace(token)) != 0 {
+					return nil, errors.New("xml: text outside the root element")
+				}
+				continue
+			}
+			current.appendText(string(token))
+		case xml.ProcInst:
+			if root != nil {
+				return nil, errors.New("xml: processing instructions are not supported")
+			}
+		case xml.Directive:
+			return nil, errors.New("xml: document type declarations are not allowed")
+		}
+	}
+
+	if root == nil || current != nil {
+		return nil, errors.New("xml: the document is incomplete")
+	}
+	return root, nil
+}
+
+func newElement(token xml.StartElement, parent *Element) (*Element, error) {
+	element := &Element{Prefix: token.Name.Space, Local: token.Name.Local, parent: parent}
+
+	for _, attr := range token.Attr {
+		switch {
+		case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+			element.declare("", attr.Value)
+		case attr.Name.Space == "xmlns":
+			if attr.Value == "" || attr.Name.Local == "xml" || attr.Name.Local == "xmlns" {
+				return nil, fmt.Errorf("xml: invalid declaration of prefix %q", attr.Name.Local)
+			}
+			element.declare(attr.Name.Local, attr.Value)
+		default:
+			element.Attrs = append(element.Attrs, Attr{Prefix: attr.Name.Space, Local: attr.Name.Local, Value: attr.Value})
+		}
+	}
+
+	space, ok := element.lookup(element.Prefix)
+	if !ok {
+		return nil, fmt.Errorf("xml: prefix %q is not declared", element.Prefix)
+	}
+	element.Space = space
+
+	seen := map[[2]string]bool{}
+	for i := range element.Attrs {
+		attr := &element.Attrs[i]
+		if attr.Prefix != "" {
+			space, ok := element.lookup(attr.Prefix)
+			if !ok {
+				return nil, fmt.Errorf("xml: prefix %q is not declared", attr.Prefix)
+			}
+			attr.Space = space
+		}
+		key := [2]string{attr.Space, attr.Local}
+		if seen[key] {
+			return nil, fmt.Errorf("xml: attribute %s is repeated", qualifiedName(attr.Prefix, attr.Local))
+		}
+		seen[key] = true
+	}
+
+	return element, nil
+}
+
+func (e *Element) declare(prefix, space string) {
+	if e.namespaces == nil {
+		e.namespaces = map[string]string{}
+	}
+	e.namespaces[prefix] = space
+}
+
+// lookup returns the namespace prefix is bound to at e. The default
+// namespace, prefix "", is always bound, to no namespace if undeclared.
+func (e *Element) lookup(prefix string) (string, bool) {
+	if prefix == "xml" {
+		return xmlNamespace, true
+	}
+	for element := e; element != nil; element = element.parent {
+		if space, ok := element.namespaces[prefix]; ok {
+			return space, true
+		}
+	}
+	return "", prefix == ""
+}
+
+func (e *Element) appendText(text string) {
+	if last := len(e.Children) - 1; last >= 0 {
+		if previous, ok := e.Children[last].(Text); ok {
+			e.Children[last] = previous + Text(text)
+			return
+		}
+	}
+	e.Children = append(e.Children, Text(text))
+}
+
+// is reports whether e is the element local in namespace space.
+func (e *Element) is(space, local string) bool {
+	return e.Space == space && e.Local == local
+}
+
+// Attr returns the value of the attribute local without a namespace.
+func (e *Element) Attr(local string) string {
+	for _, attr := range e.Attrs {
+		if attr.Space == "" && attr.Local == local {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+func (e *Element) hasAttr(local string) bool {
+	for _, attr := range e.Attrs {
+		if attr.Space == "" && attr.Local == local {
+			return true
+		}
+	}
+	return false
+}
+
+// Text returns the text directly inside e.
+func (e *Element) Text() string {
+	var text strings.Builder
+	for _, child := range e.Children {
+		if child, ok := child.(Text); ok {
+			text.WriteString(string(child))
+		}
+	}
+	return text.String()
+}
+
+// children returns the child elements of e named local in namespace space.
+func (e *Element) children(space, local string) []*Element {
+	var children []*Element
+	for _, child := range e.Children {
+		if child, ok := child.(*Element); ok && child.is(space, local) {
+			children = append(children, child)
+		}
+	}
+	return children
+}
+
+// child returns the only child element of e named local in namespace
+// space, failing if there is none or more than one.
+func (e *Element) child(space, local string) (*Element, error) {
+	children := e.children(space, local)
+	if len(children) != 1 {
+		return nil, fmt.Errorf("%s must have exactly one %s", e.Local, local)
+	}
+	return children[0], nil
+}
+
+// optionalChild is like child, but there may be none.
+func (e *Element) optionalChild(space, local string) (*Element, error) {
+	children := e.children(space, local)
+	if len(children) > 1 {
+		return nil, fmt.Errorf("%s must have at most one %s", e.Local, local)
+	}
+	if len(children) == 0 {
+		return nil, nil
+	}
+	return children[0], nil
+}
+
+// walk calls fn for e and its descendants in document order.
+func (e *Element) walk(fn func(*Element)) {
+	fn(e)
+	for _, child := range e.Children {
+		if child, ok := child.(*Element); ok {
+			child.walk(fn)
+		}
+	}
+}
+
+func qualifiedName(prefix, local string) string {
+	if prefix == "" {
+		return local
+	}
+	return prefix + ":" + local
+}
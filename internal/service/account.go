@@ -3,11 +3,13 @@ package service
 import (
 	"context"
 	"fmt"
-	"os"
+	"strconv"
 	"time"
 
 	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/notification"
 	"github.com/ssoydabas/auth-service/internal/repository"
+	"github.com/ssoydabas/auth-service/pkg/config"
 	"github.com/ssoydabas/auth-service/pkg/errors"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -16,92 +18,161 @@ import (
 )
 
 type AccountService interface {
-	CreateAccount(ctx context.Context, req dto.CreateAccountRequest) (string, error)
+	CreateAccount(ctx context.Context, req dto.CreateAccountRequest) error
 	AuthenticateAccount(ctx context.Context, req dto.AuthenticateAccountRequest) (string, error)
+	VerifyCredentials(ctx context.Context, req dto.AuthenticateAccountRequest) (*dto.AccountResponse, error)
 	GetAccountByID(ctx context.Context, id string) (*dto.AccountResponse, error)
 	GetAccountByEmail(ctx context.Context, email string) (*dto.AccountResponse, error)
 	GetAccountByToken(ctx context.Context, token string) (*dto.AccountResponse, error)
-	SetResetPasswordToken(ctx context.Context, req dto.SetResetPasswordTokenRequest) (string, error)
+	SetResetPasswordToken(ctx context.Context, req dto.SetResetPasswordTokenRequest) error
 	ResetPassword(ctx context.Context, req dto.ResetPasswordRequest) error
 	GetAccountEmailVerificationTokenByID(ctx context.Context, id string) (string, error)
 	VerifyAccountEmail(ctx context.Context, req dto.VerifyAccountRequest) error
+	ResendEmailVerification(ctx context.Context, req dto.ResendEmailVerificationRequest) error
+	ListAccounts(ctx context.Context, req dto.ListAccountsRequest) (*dto.PaginatedResponse, error)
+	UpdateAccount(ctx context.Context, actorID uint, id string, ifMatch string, patch []byte) (*dto.AccountResponse, error)
+	SuspendAccount(ctx context.Context, actorID uint, id string, req dto.SuspendAccountRequest) (*dto.AccountResponse, error)
+	ReinstateAccount(ctx context.Context, actorID uint, id string) (*dto.AccountResponse, error)
+	DeleteAccount(ctx context.Context, accountID uint, req dto.DeleteAccountRequest) (*dto.AccountDeletionResponse, error)
+	RestoreAccount(ctx context.Context, actorID uint, id string) (*dto.AccountResponse, error)
+	StartImpersonation(ctx context.Context, adminID uint, id string, req dto.StartImpersonationRequest) (*dto.ImpersonationResponse, error)
+	StopImpersonation(ctx context.Context, token string) error
 }
 
 type accountService struct {
 	accountRepository repository.AccountRepository
+	roleRepository    repository.RoleRepository
+	auditRepository   repository.AuditRepository
+	templates         *notification.Renderer
+	cfg               config.Config
 }
 
-func NewAccountService(accountRepository repository.AccountRepository) AccountService {
+func NewAccountService(accountRepository repository.AccountRepository, roleRepository repository.RoleRepository, auditRepository repository.AuditRepository, templates *notification.Renderer, cfg config.Config) AccountService {
 	return &accountService{
 		accountRepository: accountRepository,
+		roleRepository:    roleRepository,
+		auditRepository:   auditRepository,
+		templates:         templates,
+		cfg:               cfg,
 	}
 }
 
-func (s *accountService) CreateAccount(ctx context.Context, req dto.CreateAccountRequest) (string, error) {
+// CreateAccount creates a pending account and emails it the token that
+// verifies its address. The token is never returned to the caller.
+func (s *accountService) CreateAccount(ctx context.Context, req dto.CreateAccountRequest) error {
 	emailExists := s.accountRepository.ExistsByEmail(ctx, req.Email)
 	if emailExists {
-		return "", errors.ConflictError("email already in use")
+		return errors.ConflictError("email already in use")
 	}
 
 	phoneExists := s.accountRepository.ExistsByPhone(ctx, req.Phone)
 	if phoneExists {
-		return "", errors.ConflictError("phone number already in use")
+		return errors.ConflictError("phone number already in use")
+	}
+
+	defaultRole, err := s.roleRepository.GetRoleByName(ctx, s.cfg.DefaultRole)
+	if err != nil {
+		return errors.InternalError(fmt.Errorf("default role %q: %w", s.cfg.DefaultRole, err))
 	}
 
 	emailVerificationToken := uuid.New().String()
+	now := time.Now()
 	account := models.Account{
 		FirstName:          req.FirstName,
 		LastName:           req.LastName,
 		Email:              req.Email,
 		Phone:              req.Phone,
 		VerificationStatus: "pending",
+		Locale:             req.Locale,
 		AccountPassword: models.AccountPassword{
 			Password: HashPassword(req.Password),
 		},
+		Roles: []models.Role{*defaultRole},
 		AccountTokens: models.AccountToken{
-			EmailVerificationToken: emailVerificationToken,
-			PhoneVerificationToken: uuid.New().String(),
+			EmailVerificationToken:      emailVerificationToken,
+			PhoneVerificationToken:      uuid.New().String(),
+			EmailVerificationSentAt:     &now,
+			EmailVerificationWindowFrom: &now,
+			EmailVerificationSendCount:  1,
 		},
 	}
 
-	if err := s.accountRepository.CreateAccount(ctx, account); err != nil {
-		return "", err
+	message, err := s.notification(ctx, notification.KindEmailVerification, account, notification.TemplateData{Token: emailVerificationToken})
+	if err != nil {
+		return errors.InternalError(err)
 	}
 
-	return emailVerificationToken, nil
+	if err := s.accountRepository.CreateAccount(ctx, account, message); err != nil {
+		return err
+	}
+
+	return nil
 }
 
 func (s *accountService) AuthenticateAccount(ctx context.Context, req dto.AuthenticateAccountRequest) (string, error) {
+	account, err := s.verifyCredentials(ctx, req)
+	if err != nil {
+		return "", err
+	}
+
+	token, err := signToken(jwt.MapClaims{
+		"sub": account.ID,
+		"exp": time.Now().Add(time.Hour * 24).Unix(),
+	})
+
+	if err != nil {
+		return "", errors.InternalError(err)
+	}
+
+	return token, nil
+}
+
+// VerifyCredentials checks an email or phone and password the way
+// AuthenticateAccount does, recording the sign-in, but returns the account
+// instead of a token. Sign-in pages use it to start a session.
+func (s *accountService) VerifyCredentials(ctx context.Context, req dto.AuthenticateAccountRequest) (*dto.AccountResponse, error) {
+	account, err := s.verifyCredentials(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return mapAccountModelToResponse(account), nil
+}
+
+// verifyCredentials looks up the account req names, checks its password and
+// suspension and records the sign-in. Rejected attempts are audited.
+func (s *accountService) verifyCredentials(ctx context.Context, req dto.AuthenticateAccountRequest) (*models.Account, error) {
 	account, err := s.accountRepository.GetAccountByEmailOrPhone(ctx, req.Email, req.Phone)
 	if err != nil {
-		return "", errors.NotFoundError("Account not found")
+		recordAuditEvent(ctx, s.auditRepository, newFailedAuditEvent(models.AuditActionAccountLogin, 0, 0, auditReasonUnknownAccount, identifierMetadata(req.Email, req.Phone)))
+		return nil, errors.NotFoundError("Account not found")
 	}
 
 	accountPassword, err := s.accountRepository.GetAccountPasswordByAccountID(ctx, account.ID)
 	if err != nil {
-		return "", errors.NotFoundError("Account not found")
+		recordAuditEvent(ctx, s.auditRepository, newFailedAuditEvent(models.AuditActionAccountLogin, 0, account.ID, auditReasonUnknownAccount, nil))
+		return nil, errors.NotFoundError("Account not found")
 	}
 
 	if !verifyPassword(req.Password, accountPassword.Password) {
-		return "", errors.AuthError("Invalid credentials")
+		recordAuditEvent(ctx, s.auditRepository, newFailedAuditEvent(models.AuditActionAccountLogin, 0, account.ID, auditReasonInvalidCredentials, nil))
+		return nil, errors.AuthError("Invalid credentials")
 	}
 
-	// Update last login time
-	now := time.Now()
-	if err := s.accountRepository.UpdateLastLoginAt(ctx, account.ID, &now); err != nil {
-		return "", errors.InternalError(err)
+	if account.IsSuspended(time.Now()) {
+		recordAuditEvent(ctx, s.auditRepository, newFailedAuditEvent(models.AuditActionAccountLogin, account.ID, account.ID, auditReasonSuspended, nil))
+		return nil, suspendedError(account)
 	}
 
-	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub": account.ID,
-		"exp": time.Now().Add(time.Hour * 24).Unix(),
-	}).SignedString([]byte(os.Getenv("JWT_SECRET")))
-
-	if err != nil {
-		return "", errors.InternalError(err)
+	// Update last login time
+	now := time.Now()
+	audit := newAuditEvent(models.AuditActionAccountLogin, account.ID, account.ID, nil)
+	if err := s.accountRepository.UpdateLastLoginAt(ctx, account.ID, &now, audit); err != nil {
+		return nil, errors.InternalError(err)
 	}
+	account.LastLoginAt = &now
 
-	return token, nil
+	return account, nil
 }
 
 func (s *accountService) GetAccountByID(ctx context.Context, id string) (*dto.AccountResponse, error) {
@@ -110,25 +181,7 @@ func (s *accountService) GetAccountByID(ctx context.Context, id string) (*dto.Ac
 		return nil, errors.NotFoundError("Account not found")
 	}
 
-	response := dto.AccountResponse{
-		ID:                 account.ID,
-		FirstName:          account.FirstName,
-		LastName:           account.LastName,
-		Email:              account.Email,
-		Phone:              account.Phone,
-		PhotoUrl:           account.PhotoUrl,
-		VerificationStatus: account.VerificationStatus,
-		Role:               account.Role,
-		CreatedAt:          account.CreatedAt.Format(time.RFC3339),
-		UpdatedAt:          account.UpdatedAt.Format(time.RFC3339),
-	}
-
-	if account.LastLoginAt != nil {
-		lastLoginAt := account.LastLoginAt.Format(time.RFC3339)
-		response.LastLoginAt = &lastLoginAt
-	}
-
-	return &response, nil
+	return mapAccountModelToResponse(account), nil
 }
 
 func (s *accountService) GetAccountByEmail(ctx context.Context, email string) (*dto.AccountResponse, error) {
@@ -137,42 +190,13 @@ func (s *accountService) GetAccountByEmail(ctx context.Context, email string) (*
 		return nil, errors.NotFoundError("Account not found")
 	}
 
-	response := dto.AccountResponse{
-		ID:                 account.ID,
-		FirstName:          account.FirstName,
-		LastName:           account.LastName,
-		Email:              account.Email,
-		Phone:              account.Phone,
-		PhotoUrl:           account.PhotoUrl,
-		VerificationStatus: account.VerificationStatus,
-		Role:               account.Role,
-		CreatedAt:          account.CreatedAt.Format(time.RFC3339),
-		UpdatedAt:          account.UpdatedAt.Format(time.RFC3339),
-	}
-
-	if account.LastLoginAt != nil {
-		lastLoginAt := account.LastLoginAt.Format(time.RFC3339)
-		response.LastLoginAt = &lastLoginAt
-	}
-
-	return &response, nil
+	return mapAccountModelToResponse(account), nil
 }
 
 func (s *accountService) GetAccountByToken(ctx context.Context, tokenString string) (*dto.AccountResponse, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.InternalError(fmt.Errorf("unexpected signing method: %v", token.Header["alg"]))
-		}
-		return []byte(os.Getenv("JWT_SECRET")), nil
-	})
-
+	claims, err := parseToken(tokenString)
 	if err != nil {
-		return nil, errors.BadRequestError("Invalid token")
-	}
-
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok || !token.Valid {
-		return nil, errors.BadRequestError("Invalid token")
+		return nil, err
 	}
 
 	userID := fmt.Sprintf("%.0f", claims["sub"].(float64))
@@ -182,41 +206,57 @@ func (s *accountService) GetAccountByToken(ctx context.Context, tokenString stri
 		return nil, errors.NotFoundError("Account not found")
 	}
 
-	return &dto.AccountResponse{
-		ID:                 account.ID,
-		FirstName:          account.FirstName,
-		LastName:           account.LastName,
-		Email:              account.Email,
-		Phone:              account.Phone,
-		PhotoUrl:           account.PhotoUrl,
-		CreatedAt:          account.CreatedAt.Format(time.RFC3339),
-		UpdatedAt:          account.UpdatedAt.Format(time.RFC3339),
-		VerificationStatus: account.VerificationStatus,
-	}, nil
+	if account.IsSuspended(time.Now()) {
+		return nil, suspendedError(account)
+	}
+
+	response := mapAccountModelToResponse(account)
+
+	if adminID, ok := tokenActor(claims); ok {
+		if err := s.checkImpersonationSession(ctx, claims, adminID, account.ID); err != nil {
+			return nil, err
+		}
+		response.ImpersonatedBy = &adminID
+	}
+
+	return response, nil
 }
 
-func (s *accountService) SetResetPasswordToken(ctx context.Context, req dto.SetResetPasswordTokenRequest) (string, error) {
+// SetResetPasswordToken emails a password reset token to the account req
+// names. It reports no error for unknown accounts, so callers cannot tell
+// which accounts exist, and never returns the token.
+func (s *accountService) SetResetPasswordToken(ctx context.Context, req dto.SetResetPasswordTokenRequest) error {
 	account, err := s.accountRepository.GetAccountByEmailOrPhone(ctx, req.Email, req.Phone)
 	if err != nil {
-		return "", errors.NotFoundError("Account not found")
+		recordAuditEvent(ctx, s.auditRepository, newFailedAuditEvent(models.AuditActionPasswordResetRequested, 0, 0, auditReasonUnknownAccount, identifierMetadata(req.Email, req.Phone)))
+		return nil
 	}
 
 	token := uuid.New().String()
 
-	if err := s.accountRepository.SetResetPasswordToken(ctx, account.ID, token); err != nil {
-		return "", err
+	message, err := s.notification(ctx, notification.KindPasswordReset, *account, notification.TemplateData{Token: token})
+	if err != nil {
+		return errors.InternalError(err)
 	}
 
-	return token, nil
+	audit := newAuditEvent(models.AuditActionPasswordResetRequested, 0, account.ID, nil)
+	return s.accountRepository.SetResetPasswordToken(ctx, account.ID, token, audit, message)
 }
 
 func (s *accountService) ResetPassword(ctx context.Context, req dto.ResetPasswordRequest) error {
 	account, err := s.accountRepository.GetAccountByResetPasswordToken(ctx, req.Token)
 	if err != nil {
+		recordAuditEvent(ctx, s.auditRepository, newFailedAuditEvent(models.AuditActionPasswordReset, 0, 0, auditReasonInvalidToken, nil))
 		return errors.NotFoundError("Account not found")
 	}
 
-	if err := s.accountRepository.UpdateAccountPassword(ctx, account.ID, HashPassword(req.Password)); err != nil {
+	message, err := s.notification(ctx, notification.KindSecurityAlert, *account, notification.TemplateData{Event: securityEventPasswordChanged})
+	if err != nil {
+		return errors.InternalError(err)
+	}
+
+	audit := newAuditEvent(models.AuditActionPasswordReset, account.ID, account.ID, nil)
+	if err := s.accountRepository.UpdateAccountPassword(ctx, account.ID, HashPassword(req.Password), audit, message); err != nil {
 		return err
 	}
 
@@ -239,10 +279,12 @@ func (s *accountService) GetAccountEmailVerificationTokenByID(ctx context.Contex
 func (s *accountService) VerifyAccountEmail(ctx context.Context, req dto.VerifyAccountRequest) error {
 	account, err := s.accountRepository.GetAccountByEmailVerificationToken(ctx, req.Token)
 	if err != nil {
+		recordAuditEvent(ctx, s.auditRepository, newFailedAuditEvent(models.AuditActionEmailVerified, 0, 0, auditReasonInvalidToken, nil))
 		return errors.NotFoundError("Account not found")
 	}
 
-	if err := s.accountRepository.UpdateAccountVerificationStatus(ctx, account.ID, "verified"); err != nil {
+	audit := newAuditEvent(models.AuditActionEmailVerified, account.ID, account.ID, nil)
+	if err := s.accountRepository.UpdateAccountVerificationStatus(ctx, account.ID, "verified", audit); err != nil {
 		return err
 	}
 
@@ -252,3 +294,57 @@ func (s *accountService) VerifyAccountEmail(ctx context.Context, req dto.VerifyA
 
 	return nil
 }
+
+// ResendEmailVerification issues a fresh verification token for a pending
+// account and queues it for delivery, invalidating the previous token. Sends
+// are limited by a per-account cooldown and a cap per rolling 24 hours. To
+// avoid revealing which emails are registered, it returns nil whether or not
+// anything was sent.
+func (s *accountService) ResendEmailVerification(ctx context.Context, req dto.ResendEmailVerificationRequest) error {
+	found, err := s.accountRepository.GetAccountByEmail(ctx, req.Email)
+	if err != nil {
+		return nil
+	}
+
+	account, err := s.accountRepository.GetAccountByID(ctx, strconv.FormatUint(uint64(found.ID), 10), true)
+	if err != nil {
+		return nil
+	}
+
+	if account.VerificationStatus != "pending" {
+		return nil
+	}
+
+	now := time.Now()
+	tokens := account.AccountTokens
+
+	if tokens.EmailVerificationSentAt != nil && now.Sub(*tokens.EmailVerificationSentAt) < s.cfg.VerificationResendCooldown {
+		return nil
+	}
+
+	windowFrom, sendCount := tokens.EmailVerificationWindowFrom, tokens.EmailVerificationSendCount
+	if windowFrom == nil || now.Sub(*windowFrom) >= 24*time.Hour {
+		windowFrom, sendCount = &now, 0
+	}
+
+	if sendCount >= s.cfg.VerificationResendDailyCap {
+		return nil
+	}
+
+	token := uuid.New().String()
+	message, err := s.notification(ctx, notification.KindEmailVerification, *account, notification.TemplateData{Token: token})
+	if err != nil {
+		return errors.InternalError(err)
+	}
+
+	if _, err := s.accountRepository.ReissueEmailVerificationToken(ctx, account.ID, tokens.EmailVerificationSentAt, models.AccountToken{
+		EmailVerificationToken:      token,
+		EmailVerificationSentAt:     &now,
+		EmailVerificationWindowFrom: windowFrom,
+		EmailVerificationSendCount:  sendCount + 1,
+	}, message); err != nil {
+		return err
+	}
+
+	return nil
+}
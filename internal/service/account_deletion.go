@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/ssoydabas/auth-service/pkg/errors"
+	"github.com/ssoydabas/auth-service/pkg/validator"
+)
+
+// DeleteAccount soft-deletes the account after checking its password. The
+// account can be restored by an administrator until the grace period ends,
+// after which the purge job removes it for good.
+func (s *accountService) DeleteAccount(ctx context.Context, accountID uint, req dto.DeleteAccountRequest) (*dto.AccountDeletionResponse, error) {
+	if err := req.Validate(); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			return nil, errors.ValidationError("Validation failed", validationErrors)
+		}
+		return nil, errors.BadRequestError(err.Error())
+	}
+
+	account, err := s.accountRepository.GetAccountByID(ctx, strconv.FormatUint(uint64(accountID), 10), false)
+	if err != nil {
+		return nil, errors.NotFoundError("Account not found")
+	}
+
+	accountPassword, err := s.accountRepository.GetAccountPasswordByAccountID(ctx, account.ID)
+	if err != nil {
+		return nil, errors.NotFoundError("Account not found")
+	}
+
+	if !verifyPassword(req.Password, accountPassword.Password) {
+		return nil, errors.AuthError("Invalid credentials")
+	}
+
+	audit := newAuditEvent(models.AuditActionAccountDeleted, account.ID, account.ID, nil)
+	deletedAt := time.Now()
+
+	if err := s.accountRepository.SoftDeleteAccount(ctx, account.ID, audit); err != nil {
+		return nil, errors.InternalError(err)
+	}
+
+	return &dto.AccountDeletionResponse{
+		PurgeAfter: deletedAt.Add(s.cfg.AccountDeletionGracePeriod).Format(time.RFC3339),
+	}, nil
+}
+
+func (s *accountService) RestoreAccount(ctx context.Context, actorID uint, id string) (*dto.AccountResponse, error) {
+	account, err := s.accountRepository.GetDeletedAccountByID(ctx, id)
+	if err != nil {
+		return nil, errors.NotFoundError("Account not found")
+	}
+
+	deletedAfter := time.Now().Add(-s.cfg.AccountDeletionGracePeriod)
+	if !account.DeletedAt.Time.After(deletedAfter) {
+		return nil, errors.ConflictError("The grace period for restoring this account has ended")
+	}
+
+	audit := newAuditEvent(models.AuditActionAccountRestored, actorID, account.ID, map[string]any{
+		"deleted_at": account.DeletedAt.Time.Format(time.RFC3339),
+	})
+
+	applied, err := s.accountRepository.RestoreAccount(ctx, account.ID, deletedAfter, audit)
+	if err != nil {
+		return nil, errors.InternalError(err)
+	}
+	if !applied {
+		return nil, errors.NotFoundError("Account not found")
+	}
+
+	account.DeletedAt.Valid = false
+	return mapAccountModelToResponse(account), nil
+}
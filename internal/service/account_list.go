@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/repository"
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/ssoydabas/auth-service/pkg/errors"
+)
+
+const (
+	defaultAccountPageSize = 20
+	defaultAccountSort     = "-" + repository.AccountSortCreatedAt
+)
+
+// accountCursor is the JSON form of a keyset cursor. Sort records the order
+// the cursor was issued for, so it cannot be replayed against another one.
+type accountCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    uint   `json:"id"`
+}
+
+func (s *accountService) ListAccounts(ctx context.Context, req dto.ListAccountsRequest) (*dto.PaginatedResponse, error) {
+	params := repository.ListAccountsParams{
+		Role:               req.Role,
+		VerificationStatus: req.VerificationStatus,
+		Search:             strings.TrimSpace(req.Search),
+		Limit:              req.PageSize,
+	}
+
+	if params.Limit == 0 {
+		params.Limit = defaultAccountPageSize
+	}
+
+	sort := req.Sort
+	if sort == "" {
+		sort = defaultAccountSort
+	}
+	params.SortField = strings.TrimPrefix(sort, "-")
+	params.SortDesc = strings.HasPrefix(sort, "-")
+
+	for _, bound := range []struct {
+		value string
+		dest  **time.Time
+	}{
+		{req.CreatedFrom, &params.CreatedFrom},
+		{req.CreatedTo, &params.CreatedTo},
+		{req.LastLoginFrom, &params.LastLoginFrom},
+		{req.LastLoginTo, &params.LastLoginTo},
+	} {
+		if bound.value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, bound.value)
+		if err != nil {
+			return nil, errors.BadRequestError("Invalid timestamp: " + bound.value)
+		}
+		*bound.dest = &t
+	}
+
+	page := req.Page
+	if req.Cursor != "" {
+		after, err := decodeAccountCursor(req.Cursor, sort)
+		if err != nil {
+			return nil, err
+		}
+		params.After = after
+	} else {
+		if page == 0 {
+			page = 1
+		}
+		params.Offset = (page - 1) * params.Limit
+	}
+
+	accounts, total, err := s.accountRepository.ListAccounts(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]dto.AccountResponse, 0, len(accounts))
+	for i := range accounts {
+		data = append(data, *mapAccountModelToResponse(&accounts[i]))
+	}
+
+	response := &dto.PaginatedResponse{
+		Data:        data,
+		CurrentPage: page,
+		PageSize:    params.Limit,
+		TotalItems:  total,
+		TotalPages:  int((total + int64(params.Limit) - 1) / int64(params.Limit)),
+	}
+
+	if len(accounts) == params.Limit {
+		response.NextCursor = encodeAccountCursor(&accounts[len(accounts)-1], sort, params.SortField)
+	}
+
+	return response, nil
+}
+
+func encodeAccountCursor(account *models.Account, sort, field string) string {
+	cursor := accountCursor{Sort: sort, ID: account.ID}
+
+	switch field {
+	case repository.AccountSortCreatedAt:
+		cursor.Value = account.CreatedAt.Format(time.RFC3339Nano)
+	case repository.AccountSortLastLoginAt:
+		var lastLoginAt time.Time
+		if account.LastLoginAt != nil {
+			lastLoginAt = *account.LastLoginAt
+		}
+		cursor.Value = lastLoginAt.Format(time.RFC3339Nano)
+	case repository.AccountSortEmail:
+		cursor.Value = account.Email
+	case repository.AccountSortFirstName:
+		cursor.Value = account.FirstName
+	case repository.AccountSortLastName:
+		cursor.Value = account.LastName
+	}
+
+	payload, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(payload)
+}
+
+func decodeAccountCursor(encoded, sort string) (*repository.AccountCursor, error) {
+	invalid := errors.BadRequestError("Invalid cursor")
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, invalid
+	}
+
+	var cursor accountCursor
+	if err := json.Unmarshal(payload, &cursor); err != nil || cursor.ID == 0 {
+		return nil, invalid
+	}
+
+	if cursor.Sort != sort {
+		return nil, errors.BadRequestError("Cursor was issued for a different sort order")
+	}
+
+	after := &repository.AccountCursor{Value: cursor.Value, ID: cursor.ID}
+
+	switch strings.TrimPrefix(sort, "-") {
+	case repository.AccountSortCreatedAt, repository.AccountSortLastLoginAt:
+		t, err := time.Parse(time.RFC3339Nano, cursor.Value)
+		if err != nil {
+			return nil, invalid
+		}
+		after.Value = t
+	}
+
+	return after, nil
+}
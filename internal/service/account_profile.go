@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/ssoydabas/auth-service/pkg/errors"
+	"github.com/ssoydabas/auth-service/pkg/validator"
+)
+
+// accountETag derives the entity tag of an account from UpdatedAt. Postgres
+// stores timestamps with microsecond precision, so that is the precision
+// used here.
+func accountETag(updatedAt time.Time) string {
+	return fmt.Sprintf(`"%x"`, updatedAt.UnixMicro())
+}
+
+// etagMatches implements the strong comparison If-Match uses: "*" matches
+// any version and weak tags never match.
+func etagMatches(ifMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *accountService) UpdateAccount(ctx context.Context, actorID uint, id string, ifMatch string, patch []byte) (*dto.AccountResponse, error) {
+	account, err := s.accountRepository.GetAccountByID(ctx, id, false)
+	if err != nil {
+		return nil, errors.NotFoundError("Account not found")
+	}
+
+	if ifMatch == "" {
+		return nil, errors.PreconditionRequiredError("If-Match header is required")
+	}
+
+	if !etagMatches(ifMatch, accountETag(account.UpdatedAt)) {
+		return nil, errors.PreconditionFailedError("Account has been modified since it was fetched")
+	}
+
+	req := dto.UpdateAccountRequest{
+		FirstName: account.FirstName,
+		LastName:  account.LastName,
+		PhotoUrl:  account.PhotoUrl,
+		Locale:    account.Locale,
+	}
+
+	if err := req.ApplyMergePatch(patch); err != nil {
+		return nil, errors.BadRequestError(err.Error())
+	}
+
+	if err := req.Validate(); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			return nil, errors.ValidationError("Validation failed", validationErrors)
+		}
+		return nil, errors.BadRequestError(err.Error())
+	}
+
+	changes := map[string]interface{}{}
+	changed := []string{}
+	for _, field := range []struct {
+		column  string
+		current *string
+		value   string
+	}{
+		{"first_name", &account.FirstName, req.FirstName},
+		{"last_name", &account.LastName, req.LastName},
+		{"photo_url", &account.PhotoUrl, req.PhotoUrl},
+		{"locale", &account.Locale, req.Locale},
+	} {
+		if *field.current != field.value {
+			changes[field.column] = field.value
+			changed = append(changed, field.column)
+			*field.current = field.value
+		}
+	}
+
+	if len(changes) == 0 {
+		return mapAccountModelToResponse(account), nil
+	}
+
+	previousUpdatedAt := account.UpdatedAt
+	account.UpdatedAt = time.Now().Truncate(time.Microsecond)
+	changes["updated_at"] = account.UpdatedAt
+
+	audit := newAuditEvent(models.AuditActionAccountUpdated, actorID, account.ID, map[string]any{
+		"fields": changed,
+	})
+
+	applied, err := s.accountRepository.UpdateAccountProfile(ctx, account.ID, previousUpdatedAt, changes, audit)
+	if err != nil {
+		return nil, err
+	}
+	if !applied {
+		return nil, errors.PreconditionFailedError("Account has been modified since it was fetched")
+	}
+
+	return mapAccountModelToResponse(account), nil
+}
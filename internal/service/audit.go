@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"log"
+	"time"
+
+	"github.com/ssoydabas/auth-service/internal/audit"
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/repository"
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/ssoydabas/auth-service/pkg/errors"
+)
+
+const (
+	defaultAuditPageSize = 50
+	auditVerifyBatchSize = 500
+)
+
+// Reasons recorded in the metadata of failed audit events.
+const (
+	auditReasonUnknownAccount     = "unknown_account"
+	auditReasonInvalidCredentials = "invalid_credentials"
+	auditReasonSuspended          = "suspended"
+	auditReasonInvalidToken       = "invalid_token"
+	auditReasonTokenReused        = "token_reused"
+	auditReasonCodeReused         = "code_reused"
+	auditReasonUserDenied         = "user_denied"
+)
+
+// newAuditEvent builds an audit event. actorID and targetID may be zero when
+// there is no actor or target.
+func newAuditEvent(action string, actorID, targetID uint, metadata map[string]any) models.AuditEvent {
+	event := models.AuditEvent{Action: action, Outcome: models.AuditOutcomeSuccess, Metadata: "{}"}
+
+	if actorID != 0 {
+		event.ActorID = &actorID
+	}
+	if targetID != 0 {
+		event.TargetID = &targetID
+	}
+
+	if len(metadata) > 0 {
+		if encoded, err := json.Marshal(metadata); err == nil {
+			event.Metadata = string(encoded)
+		}
+	}
+
+	return event
+}
+
+// newFailedAuditEvent builds an audit event for an attempt that was
+// rejected. reason is stored in the metadata.
+func newFailedAuditEvent(action string, actorID, targetID uint, reason string, metadata map[string]any) models.AuditEvent {
+	if metadata == nil {
+		metadata = map[string]any{}
+	}
+	metadata["reason"] = reason
+
+	event := newAuditEvent(action, actorID, targetID, metadata)
+	event.Outcome = models.AuditOutcomeFailure
+	return event
+}
+
+// identifierMetadata records the email or phone a rejected request named
+// when no account matched it.
+func identifierMetadata(email, phone string) map[string]any {
+	metadata := map[string]any{}
+	if email != "" {
+		metadata["email"] = email
+	}
+	if phone != "" {
+		metadata["phone"] = phone
+	}
+	return metadata
+}
+
+// recordAuditEvent writes an event that does not belong to a repository
+// change, such as a rejected sign-in. Failing to write it is logged rather
+// than returned, so it never changes the response the caller gets.
+func recordAuditEvent(ctx context.Context, auditRepository repository.AuditRepository, event models.AuditEvent) {
+	if err := auditRepository.RecordAuditEvent(ctx, event); err != nil {
+		log.Printf("audit: failed to record %s event: %v", event.Action, err)
+	}
+}
+
+type AuditService interface {
+	ListAuditEvents(ctx context.Context, req dto.ListAuditEventsRequest) (*dto.PaginatedResponse, error)
+	VerifyAuditChain(ctx context.Context) (*dto.AuditVerificationResponse, error)
+}
+
+type auditService struct {
+	auditRepository repository.AuditRepository
+}
+
+func NewAuditService(auditRepository repository.AuditRepository) AuditService {
+	return &auditService{
+		auditRepository: auditRepository,
+	}
+}
+
+func (s *auditService) ListAuditEvents(ctx context.Context, req dto.ListAuditEventsRequest) (*dto.PaginatedResponse, error) {
+	params := repository.ListAuditEventsParams{
+		Action:   req.Action,
+		ActorID:  req.ActorID,
+		TargetID: req.TargetID,
+		Outcome:  req.Outcome,
+		Limit:    req.PageSize,
+	}
+
+	if params.Limit == 0 {
+		params.Limit = defaultAuditPageSize
+	}
+
+	for _, bound := range []struct {
+		value string
+		dest  **time.Time
+	}{
+		{req.From, &params.From},
+		{req.To, &params.To},
+	} {
+		if bound.value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, bound.value)
+		if err != nil {
+			return nil, errors.BadRequestError("Invalid timestamp: " + bound.value)
+		}
+		*bound.dest = &t
+	}
+
+	page := req.Page
+	if page == 0 {
+		page = 1
+	}
+	params.Offset = (page - 1) * params.Limit
+
+	events, total, err := s.auditRepository.ListAuditEvents(ctx, params)
+	if err != nil {
+		return nil, errors.InternalError(err)
+	}
+
+	data := make([]dto.AuditEventResponse, 0, len(events))
+	for i := range events {
+		data = append(data, mapAuditEventModelToResponse(&events[i]))
+	}
+
+	return &dto.PaginatedResponse{
+		Data:        data,
+		CurrentPage: page,
+		PageSize:    params.Limit,
+		TotalItems:  total,
+		TotalPages:  int((total + int64(params.Limit) - 1) / int64(params.Limit)),
+	}, nil
+}
+
+// VerifyAuditChain walks the whole hash chain and reports the first event
+// that does not verify. A broken chain is a result, not an error.
+func (s *auditService) VerifyAuditChain(ctx context.Context) (*dto.AuditVerificationResponse, error) {
+	unchained, err := s.auditRepository.CountUnchainedAuditEvents(ctx)
+	if err != nil {
+		return nil, errors.InternalError(err)
+	}
+
+	response := &dto.AuditVerificationResponse{Valid: true, Unchained: unchained}
+
+	var verifier audit.Verifier
+	var afterSequence uint64
+	var afterID uint
+	for {
+		events, err := s.auditRepository.ListAuditChain(ctx, afterSequence, afterID, auditVerifyBatchSize)
+		if err != nil {
+			return nil, errors.InternalError(err)
+		}
+
+		for _, event := range events {
+			if err := verifier.Verify(event); err != nil {
+				var chainErr *audit.ChainError
+				if !stderrors.As(err, &chainErr) {
+					return nil, errors.InternalError(err)
+				}
+				response.Valid = false
+				response.BrokenAt = &dto.AuditChainBreakResponse{
+					EventID:  chainErr.EventID,
+					Sequence: chainErr.Sequence,
+					Reason:   chainErr.Reason,
+				}
+				response.Checked = verifier.Checked()
+				response.LastHash = verifier.LastHash()
+				return response, nil
+			}
+		}
+
+		if len(events) < auditVerifyBatchSize {
+			break
+		}
+		afterSequence, afterID = events[len(events)-1].Sequence, events[len(events)-1].ID
+	}
+
+	response.Checked = verifier.Checked()
+	response.LastHash = verifier.LastHash()
+	return response, nil
+}
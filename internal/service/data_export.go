@@ -0,0 +1,310 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/export"
+	"github.com/ssoydabas/auth-service/internal/notification"
+	"github.com/ssoydabas/auth-service/internal/outbox"
+	"github.com/ssoydabas/auth-service/internal/repository"
+	"github.com/ssoydabas/auth-service/internal/storage"
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/ssoydabas/auth-service/pkg/config"
+	"github.com/ssoydabas/auth-service/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// DataExportRoutePrefix is the path under which export archives are
+// downloaded: DataExportRoutePrefix + "<id>/download".
+const DataExportRoutePrefix = "/api/v1/exports/"
+
+const dataExportKeyPrefix = "exports/"
+
+// Sections every data export contains. Further sections are added with
+// RegisterSection.
+const (
+	ExportSectionProfile      = "profile"
+	ExportSectionLoginHistory = "login_history"
+	ExportSectionAuditEvents  = "audit_events"
+	ExportSectionSessions     = "sessions"
+	ExportSectionConsents     = "consents"
+)
+
+type DataExportService interface {
+	RequestExport(ctx context.Context, accountID uint) (*dto.DataExportResponse, error)
+	GetExport(ctx context.Context, accountID uint, id string) (*dto.DataExportResponse, error)
+	GenerateExport(ctx context.Context, id string) error
+	OpenDownload(ctx context.Context, id, expires, signature string) (io.ReadCloser, storage.BlobInfo, error)
+	PurgeExpiredExports(ctx context.Context, limit int) (int, error)
+	RegisterSection(name string, collect export.Collector)
+}
+
+type dataExportService struct {
+	dataExportRepository repository.DataExportRepository
+	accountRepository    repository.AccountRepository
+	auditRepository      repository.AuditRepository
+	oauthRepository      repository.OAuthRepository
+	store                storage.BlobStore
+	templates            *notification.Renderer
+	builder              *export.Builder
+	cfg                  config.Config
+}
+
+func NewDataExportService(dataExportRepository repository.DataExportRepository, accountRepository repository.AccountRepository, auditRepository repository.AuditRepository, oauthRepository repository.OAuthRepository, store storage.BlobStore, templates *notification.Renderer, cfg config.Config) DataExportService {
+	s := &dataExportService{
+		dataExportRepository: dataExportRepository,
+		accountRepository:    accountRepository,
+		auditRepository:      auditRepository,
+		oauthRepository:      oauthRepository,
+		store:                store,
+		templates:            templates,
+		builder:              export.NewBuilder(),
+		cfg:                  cfg,
+	}
+
+	s.builder.Register(ExportSectionProfile, s.collectProfile)
+	s.builder.Register(ExportSectionLoginHistory, s.collectLoginHistory)
+	s.builder.Register(ExportSectionAuditEvents, s.collectAuditEvents)
+	s.builder.Register(ExportSectionSessions, s.collectSessions)
+	s.builder.Register(ExportSectionConsents, s.collectConsents)
+
+	return s
+}
+
+func (s *dataExportService) RegisterSection(name string, collect export.Collector) {
+	s.builder.Register(name, collect)
+}
+
+// RequestExport schedules a new export, or returns the one that is still
+// being generated so that repeated requests do not queue more work.
+func (s *dataExportService) RequestExport(ctx context.Context, accountID uint) (*dto.DataExportResponse, error) {
+	pending, err := s.dataExportRepository.GetPendingDataExport(ctx, accountID)
+	if err == nil {
+		return mapDataExportModelToResponse(pending), nil
+	}
+	if !stderrors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.InternalError(err)
+	}
+
+	dataExport := &models.DataExport{
+		PublicID:  uuid.New().String(),
+		AccountID: accountID,
+		Status:    models.DataExportStatusPending,
+	}
+
+	message, err := outbox.NewDataExportMessage(dataExport.PublicID)
+	if err != nil {
+		return nil, errors.InternalError(err)
+	}
+
+	audit := newAuditEvent(models.AuditActionDataExported, accountID, accountID, map[string]any{
+		"export_id": dataExport.PublicID,
+	})
+
+	if err := s.dataExportRepository.CreateDataExport(ctx, dataExport, audit, message); err != nil {
+		return nil, errors.InternalError(err)
+	}
+
+	return mapDataExportModelToResponse(dataExport), nil
+}
+
+func (s *dataExportService) GetExport(ctx context.Context, accountID uint, id string) (*dto.DataExportResponse, error) {
+	dataExport, err := s.dataExportRepository.GetDataExportByPublicID(ctx, id)
+	if err != nil || dataExport.AccountID != accountID {
+		return nil, errors.NotFoundError("Export not found")
+	}
+
+	response := mapDataExportModelToResponse(dataExport)
+	if dataExport.Status == models.DataExportStatusReady && dataExport.ExpiresAt.After(time.Now()) {
+		response.DownloadURL = s.downloadURL(dataExport)
+	}
+
+	return response, nil
+}
+
+// GenerateExport builds and stores the archive of a pending export and
+// emails the download link. It is run by the outbox dispatcher, so
+// returning an error retries it later.
+func (s *dataExportService) GenerateExport(ctx context.Context, id string) error {
+	dataExport, err := s.dataExportRepository.GetDataExportByPublicID(ctx, id)
+	if stderrors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if dataExport.Status != models.DataExportStatusPending {
+		return nil
+	}
+
+	account, err := s.accountRepository.GetAccountByID(ctx, strconv.FormatUint(uint64(dataExport.AccountID), 10), false)
+	if stderrors.Is(err, gorm.ErrRecordNotFound) {
+		// The account was deleted in the meantime; there is nobody left to
+		// send the export to.
+		log.Printf("data export %s: account %d no longer exists", id, dataExport.AccountID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	archive, err := s.builder.Build(ctx, account.ID, now)
+	if err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("%s%d/%s.zip", dataExportKeyPrefix, account.ID, dataExport.PublicID)
+	if err := s.store.Put(ctx, key, bytes.NewReader(archive), int64(len(archive)), "application/zip"); err != nil {
+		return err
+	}
+
+	expiresAt := now.Add(s.cfg.DataExportTTL).Truncate(time.Second)
+	dataExport.ExpiresAt = &expiresAt
+
+	data := notification.TemplateData{
+		FirstName: account.FirstName,
+		LastName:  account.LastName,
+		Email:     account.Email,
+		Link:      s.downloadURL(dataExport),
+		Expires:   expiresAt.UTC().Format(time.RFC1123),
+	}
+	msg, err := s.templates.Message(notification.KindDataExport, s.templates.ResolveLocale(account.Locale, ""), account.Email, data)
+	if err != nil {
+		return err
+	}
+	message, err := outbox.NewNotificationMessage(msg)
+	if err != nil {
+		return err
+	}
+
+	return s.dataExportRepository.MarkDataExportReady(ctx, dataExport.ID, key, int64(len(archive)), expiresAt, message)
+}
+
+// OpenDownload checks the signature and expiry of a download link and opens
+// the archive it points at.
+func (s *dataExportService) OpenDownload(ctx context.Context, id, expires, signature string) (io.ReadCloser, storage.BlobInfo, error) {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || !hmac.Equal([]byte(signature), []byte(s.sign(id, expiresAt))) {
+		return nil, storage.BlobInfo{}, errors.ForbiddenError("Invalid download link")
+	}
+	if time.Now().Unix() >= expiresAt {
+		return nil, storage.BlobInfo{}, errors.ForbiddenError("Download link has expired")
+	}
+
+	dataExport, err := s.dataExportRepository.GetDataExportByPublicID(ctx, id)
+	if err != nil || dataExport.Status != models.DataExportStatusReady {
+		return nil, storage.BlobInfo{}, errors.NotFoundError("Export not found")
+	}
+
+	body, info, err := s.store.Get(ctx, dataExport.BlobKey)
+	if err != nil {
+		if stderrors.Is(err, storage.ErrNotFound) {
+			return nil, storage.BlobInfo{}, errors.NotFoundError("Export not found")
+		}
+		return nil, storage.BlobInfo{}, errors.InternalError(err)
+	}
+	return body, info, nil
+}
+
+// PurgeExpiredExports deletes up to limit exports whose links have expired,
+// together with their archives. It returns the number of exports looked at.
+func (s *dataExportService) PurgeExpiredExports(ctx context.Context, limit int) (int, error) {
+	exports, err := s.dataExportRepository.ListExpiredDataExports(ctx, time.Now(), limit)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, dataExport := range exports {
+		if err := s.store.Delete(ctx, dataExport.BlobKey); err != nil && !stderrors.Is(err, storage.ErrNotFound) {
+			return len(exports), err
+		}
+		if err := s.dataExportRepository.DeleteDataExport(ctx, dataExport.ID); err != nil {
+			return len(exports), err
+		}
+	}
+
+	return len(exports), nil
+}
+
+// downloadURL returns the signed link to a ready export. The link carries
+// its own expiry, so it works without authentication until then.
+func (s *dataExportService) downloadURL(dataExport *models.DataExport) string {
+	expires := dataExport.ExpiresAt.Unix()
+	query := url.Values{
+		"expires":   {strconv.FormatInt(expires, 10)},
+		"signature": {s.sign(dataExport.PublicID, expires)},
+	}
+	return strings.TrimSuffix(s.cfg.PublicBaseURL, "/") + DataExportRoutePrefix + dataExport.PublicID + "/download?" + query.Encode()
+}
+
+func (s *dataExportService) sign(id string, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(s.cfg.JWTSecret))
+	fmt.Fprintf(mac, "data-export:%s:%d", id, expires)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (s *dataExportService) collectProfile(ctx context.Context, accountID uint) (any, error) {
+	account, err := s.accountRepository.GetAccountByID(ctx, strconv.FormatUint(uint64(accountID), 10), false)
+	if err != nil {
+		return nil, err
+	}
+	return mapAccountModelToResponse(account), nil
+}
+
+func (s *dataExportService) collectLoginHistory(ctx context.Context, accountID uint) (any, error) {
+	events, err := s.auditRepository.ListAuditEventsForAccount(ctx, accountID, models.AuditActionAccountLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	logins := make([]dto.AuditEventResponse, 0, len(events))
+	for i := range events {
+		logins = append(logins, mapAuditEventModelToResponse(&events[i]))
+	}
+	return logins, nil
+}
+
+func (s *dataExportService) collectAuditEvents(ctx context.Context, accountID uint) (any, error) {
+	events, err := s.auditRepository.ListAuditEventsForAccount(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]dto.AuditEventResponse, 0, len(events))
+	for i := range events {
+		responses = append(responses, mapAuditEventModelToResponse(&events[i]))
+	}
+	return responses, nil
+}
+
+func (s *dataExportService) collectSessions(ctx context.Context, accountID uint) (any, error) {
+	sessions, err := s.oauthRepository.ListSessions(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]dto.SessionResponse, 0, len(sessions))
+	for i := range sessions {
+		responses = append(responses, *mapSessionModelToResponse(&sessions[i]))
+	}
+	return responses, nil
+}
+
+func (s *dataExportService) collectConsents(ctx context.Context, accountID uint) (any, error) {
+	return listGrants(ctx, s.oauthRepository, accountID)
+}
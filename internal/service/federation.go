@@ -0,0 +1,488 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	stderrors "errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/federation"
+	"github.com/ssoydabas/auth-service/internal/oauth"
+	"github.com/ssoydabas/auth-service/internal/repository"
+	"github.com/ssoydabas/auth-service/internal/saml"
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/ssoydabas/auth-service/pkg/config"
+	"github.com/ssoydabas/auth-service/pkg/validator"
+	"gorm.io/gorm"
+)
+
+// Audit reasons of rejected federated sign-ins.
+const (
+	auditReasonProviderError   = "provider_error"
+	auditReasonEmailUnverified = "email_unverified"
+)
+
+// maxNameLength is the longest first or last name an account can have.
+const maxNameLength = 50
+
+type FederationService interface {
+	Providers() []FederationProvider
+	StartLogin(ctx context.Context, providerID, returnTo string) (*FederatedLoginStart, error)
+	FinishLogin(ctx context.Context, providerID, browserState string, req dto.FederationCallbackRequest) (*FederatedLoginResult, error)
+	FinishSAMLLogin(ctx context.Context, providerID, browserState string, req dto.SAMLResponseRequest) (*FederatedLoginResult, error)
+	SAMLMetadata(providerID string) ([]byte, error)
+}
+
+// FederationProvider names an upstream identity provider on the sign-in
+// pages.
+type FederationProvider struct {
+	ID   string
+	Name string
+}
+
+// FederatedLoginStart sends the browser to RedirectURL at the provider. The
+// caller must keep State in the browser until ExpiresAt and hand it back to
+// FinishLogin, or FinishSAMLLogin, which only accept the answer in the same
+// browser. CrossSitePost tells that the answer is a form the provider's
+// site posts, which a browser only sends cookies along with if they allow
+// it.
+type FederatedLoginStart struct {
+	RedirectURL   string
+	State         string
+	ExpiresAt     time.Time
+	CrossSitePost bool
+}
+
+// FederatedLoginResult tells the callback to set the cookie of the session
+// that was started and send the browser on to ReturnTo.
+type FederatedLoginResult struct {
+	ReturnTo         string
+	SessionToken     string
+	SessionExpiresAt time.Time
+}
+
+type federationService struct {
+	federationRepository repository.FederationRepository
+	oauthRepository      repository.OAuthRepository
+	accountRepository    repository.AccountRepository
+	roleRepository       repository.RoleRepository
+	auditRepository      repository.AuditRepository
+	providers            *federation.Providers
+	cfg                  config.Config
+}
+
+// NewFederationService signs users in with the upstream identity providers
+// connectors configure. A sign-in starts a session at the authorization
+// server like a password sign-in does.
+func NewFederationService(federationRepository repository.FederationRepository, oauthRepository repository.OAuthRepository, accountRepository repository.AccountRepository, roleRepository repository.RoleRepository, auditRepository repository.AuditRepository, connectors []federation.Connector, cfg config.Config) FederationService {
+	return &federationService{
+		federationRepository: federationRepository,
+		oauthRepository:      oauthRepository,
+		accountRepository:    accountRepository,
+		roleRepository:       roleRepository,
+		auditRepository:      auditRepository,
+		providers:            federation.NewProviders(connectors, &http.Client{Timeout: clientRequestTimeout}),
+		cfg:                  cfg,
+	}
+}
+
+func (s *federationService) Providers() []FederationProvider {
+	var providers []FederationProvider
+	for _, provider := range s.providers.List() {
+		providers = append(providers, FederationProvider{ID: provider.ID(), Name: provider.Name()})
+	}
+	return providers
+}
+
+// SAMLMetadata returns the metadata to register the service at a SAML
+// provider with.
+func (s *federationService) SAMLMetadata(providerID string) ([]byte, error) {
+	provider, err := s.provider(providerID, true)
+	if err != nil {
+		return nil, err
+	}
+	return s.serviceProvider(provider.ID()).Metadata(), nil
+}
+
+// StartLogin sends the user to a provider to sign in, with a fresh state,
+// nonce and PKCE verifier, or a fresh AuthnRequest for a SAML provider,
+// with the state as its relay state. returnTo is the page of the
+// authorization server the user comes back to once signed in.
+func (s *federationService) StartLogin(ctx context.Context, providerID, returnTo string) (*FederatedLoginStart, error) {
+	provider, ok := s.providers.Get(providerID)
+	if !ok {
+		return nil, oauth.InvalidRequest("Unknown identity provider")
+	}
+	if !validReturnTo(returnTo) {
+		return nil, oauth.InvalidRequest("return_to must be a page of the authorization server")
+	}
+
+	var values [3]string
+	for i := range values {
+		value, err := oauth.NewToken()
+		if err != nil {
+			return nil, oauth.ServerError(err.Error())
+		}
+		values[i] = value
+	}
+	state, nonce, verifier := values[0], values[1], values[2]
+
+	var redirectURL string
+	var err error
+	if provider.SAML() {
+		// The ID of the AuthnRequest takes the place of the nonce: the
+		// response must be in response to it.
+		redirectURL, nonce, err = provider.AuthnRequestURL(s.serviceProvider(provider.ID()), state, time.Now())
+		verifier = ""
+	} else {
+		redirectURL, err = provider.AuthCodeURL(ctx, s.callbackURI(provider.ID()), state, nonce, verifier)
+	}
+	if err != nil {
+		return nil, oauth.ServerError(provider.Name() + " is unavailable. Please try again later.")
+	}
+
+	login := &models.FederatedLogin{
+		StateHash:    oauth.HashToken(state),
+		Provider:     provider.ID(),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ReturnTo:     returnTo,
+		ExpiresAt:    time.Now().Add(s.cfg.FederationLoginTTL),
+	}
+	if err := s.federationRepository.CreateFederatedLogin(ctx, login); err != nil {
+		return nil, oauth.ServerError(err.Error())
+	}
+
+	return &FederatedLoginStart{RedirectURL: redirectURL, State: state, ExpiresAt: login.ExpiresAt, CrossSitePost: provider.SAML()}, nil
+}
+
+// FinishLogin handles an OAuth provider sending the user back. The state must
+// match the one the browser kept and name a sign-in that has not been used
+// or expired. The code is exchanged, the user identified and signed in to
+// the account linked to their identity. An identity seen for the first
+// time is linked to the account with the same verified email address, or
+// gets a new account.
+func (s *federationService) FinishLogin(ctx context.Context, providerID, browserState string, req dto.FederationCallbackRequest) (*FederatedLoginResult, error) {
+	provider, err := s.provider(providerID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	login, err := s.consumeLogin(ctx, provider, req.State, browserState, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Error != "" {
+		return nil, oauth.AccessDenied(provider.Name() + " did not sign you in.")
+	}
+
+	metadata, err := provider.Metadata(ctx)
+	if err != nil {
+		return nil, oauth.ServerError(provider.Name() + " is unavailable. Please try again later.")
+	}
+	// A provider that names itself must be the one the sign-in was sent to
+	// (RFC 9207 section 2.4).
+	if req.Issuer != "" && req.Issuer != metadata.Issuer {
+		return nil, oauth.InvalidRequest("The response came from another identity provider")
+	}
+	if req.Code == "" {
+		return nil, oauth.InvalidRequest("code is required")
+	}
+
+	profile, err := s.authenticate(ctx, provider, req.Code, login)
+	if err != nil {
+		return nil, s.unverifiedAnswer(ctx, provider, err)
+	}
+
+	return s.signIn(ctx, provider, profile, login, now)
+}
+
+// FinishSAMLLogin handles the response a SAML provider posts to the
+// assertion consumer service. The relay state must match the state the
+// browser kept, like the state of FinishLogin, and the response answer the
+// AuthnRequest of the sign-in. The user is then signed in like with
+// FinishLogin.
+func (s *federationService) FinishSAMLLogin(ctx context.Context, providerID, browserState string, req dto.SAMLResponseRequest) (*FederatedLoginResult, error) {
+	provider, err := s.provider(providerID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	login, err := s.consumeLogin(ctx, provider, req.RelayState, browserState, now)
+	if err != nil {
+		return nil, err
+	}
+	if req.SAMLResponse == "" {
+		return nil, oauth.InvalidRequest("SAMLResponse is required")
+	}
+
+	profile, err := provider.AuthenticateSAML(s.serviceProvider(provider.ID()), req.SAMLResponse, login.Nonce, now)
+	if err != nil {
+		return nil, s.unverifiedAnswer(ctx, provider, err)
+	}
+
+	return s.signIn(ctx, provider, profile, login, now)
+}
+
+// provider returns the provider with the given ID, which must be a SAML
+// provider or not as samlProvider says.
+func (s *federationService) provider(providerID string, samlProvider bool) (*federation.Provider, error) {
+	provider, ok := s.providers.Get(providerID)
+	if !ok || provider.SAML() != samlProvider {
+		return nil, oauth.InvalidRequest("Unknown identity provider")
+	}
+	return provider, nil
+}
+
+// consumeLogin uses up the sign-in with the given state, which must be the
+// state the browser kept.
+func (s *federationService) consumeLogin(ctx context.Context, provider *federation.Provider, state, browserState string, now time.Time) (*models.FederatedLogin, error) {
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(browserState)) != 1 {
+		return nil, staleFederatedLogin()
+	}
+
+	login, err := s.federationRepository.ConsumeFederatedLogin(ctx, oauth.HashToken(state))
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, staleFederatedLogin()
+		}
+		return nil, oauth.ServerError(err.Error())
+	}
+	if login.Provider != provider.ID() || !now.Before(login.ExpiresAt) {
+		return nil, staleFederatedLogin()
+	}
+
+	return login, nil
+}
+
+// unverifiedAnswer records and reports an answer of a provider that could
+// not be verified.
+func (s *federationService) unverifiedAnswer(ctx context.Context, provider *federation.Provider, err error) error {
+	recordAuditEvent(ctx, s.auditRepository, newFailedAuditEvent(models.AuditActionAccountLogin, 0, 0, auditReasonProviderError, map[string]any{
+		"provider": provider.ID(),
+		"error":    err.Error(),
+	}))
+	return oauth.AccessDenied("The answer of " + provider.Name() + " could not be verified. Please try again.")
+}
+
+// signIn starts a session for the account profile signs in to.
+func (s *federationService) signIn(ctx context.Context, provider *federation.Provider, profile *federation.Profile, login *models.FederatedLogin, now time.Time) (*FederatedLoginResult, error) {
+	account, err := s.resolveAccount(ctx, provider, profile, now)
+	if err != nil {
+		return nil, err
+	}
+
+	session, token, err := createSession(ctx, s.oauthRepository, account.ID, oauth.AMRFederated, s.cfg.SessionTTL)
+	if err != nil {
+		return nil, oauth.ServerError(err.Error())
+	}
+
+	return &FederatedLoginResult{
+		ReturnTo:         login.ReturnTo,
+		SessionToken:     token,
+		SessionExpiresAt: session.ExpiresAt,
+	}, nil
+}
+
+// authenticate exchanges the code of a sign-in and identifies the user.
+func (s *federationService) authenticate(ctx context.Context, provider *federation.Provider, code string, login *models.FederatedLogin) (*federation.Profile, error) {
+	tokens, err := provider.Exchange(ctx, code, s.callbackURI(provider.ID()), login.CodeVerifier)
+	if err != nil {
+		return nil, err
+	}
+
+	return provider.Authenticate(ctx, tokens, login.Nonce, time.Now())
+}
+
+// resolveAccount returns the account profile signs in to, linking or
+// creating it on the first sign-in with the identity. Only an email address
+// both the provider and the account have verified links an existing
+// account, so that neither side can claim an address it does not own.
+func (s *federationService) resolveAccount(ctx context.Context, provider *federation.Provider, profile *federation.Profile, now time.Time) (*models.Account, error) {
+	identity, err := s.federationRepository.GetIdentity(ctx, provider.ID(), profile.Subject)
+	if err == nil {
+		return s.identityAccount(ctx, provider, identity, profile, now)
+	}
+	if !stderrors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, oauth.ServerError(err.Error())
+	}
+
+	metadata := map[string]any{"provider": provider.ID(), "subject": profile.Subject}
+	if profile.Email == "" || !profile.EmailVerified {
+		recordAuditEvent(ctx, s.auditRepository, newFailedAuditEvent(models.AuditActionAccountLogin, 0, 0, auditReasonEmailUnverified, metadata))
+		return nil, oauth.AccessDenied(provider.Name() + " did not share a verified email address, which is needed to set up your account.")
+	}
+
+	identity = &models.Identity{Provider: provider.ID(), Subject: profile.Subject, Email: profile.Email}
+
+	account, err := s.accountRepository.GetAccountByEmail(ctx, profile.Email)
+	if err == nil {
+		if account.VerificationStatus != "verified" {
+			recordAuditEvent(ctx, s.auditRepository, newFailedAuditEvent(models.AuditActionAccountLogin, 0, account.ID, auditReasonEmailUnverified, metadata))
+			return nil, oauth.AccessDenied("An account with your email address exists but the address has not been verified. Verify it, then sign in with " + provider.Name() + " again.")
+		}
+		if account.IsSuspended(now) {
+			recordAuditEvent(ctx, s.auditRepository, newFailedAuditEvent(models.AuditActionAccountLogin, account.ID, account.ID, auditReasonSuspended, metadata))
+			return nil, oauth.AccessDenied("Your account is suspended.")
+		}
+
+		identity.AccountID = account.ID
+		if err := s.federationRepository.LinkIdentity(ctx, identity, now,
+			newAuditEvent(models.AuditActionIdentityLinked, account.ID, account.ID, metadata),
+			newAuditEvent(models.AuditActionAccountLogin, account.ID, account.ID, map[string]any{"provider": provider.ID()}),
+		); err != nil {
+			return nil, oauth.ServerError(err.Error())
+		}
+		account.LastLoginAt = &now
+		return account, nil
+	}
+	if !stderrors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, oauth.ServerError(err.Error())
+	}
+
+	account, err = s.newAccount(ctx, profile, now)
+	if err != nil {
+		return nil, err
+	}
+
+	identity.LastLoginAt = &now
+	if err := s.federationRepository.CreateFederatedAccount(ctx, account, identity,
+		newAuditEvent(models.AuditActionAccountCreated, 0, 0, metadata),
+		newAuditEvent(models.AuditActionIdentityLinked, 0, 0, metadata),
+		newAuditEvent(models.AuditActionAccountLogin, 0, 0, map[string]any{"provider": provider.ID()}),
+	); err != nil {
+		return nil, oauth.ServerError(err.Error())
+	}
+
+	return account, nil
+}
+
+// identityAccount signs in to the account an identity is linked to.
+func (s *federationService) identityAccount(ctx context.Context, provider *federation.Provider, identity *models.Identity, profile *federation.Profile, now time.Time) (*models.Account, error) {
+	account, err := s.accountRepository.GetAccountByID(ctx, strconv.FormatUint(uint64(identity.AccountID), 10), false)
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			recordAuditEvent(ctx, s.auditRepository, newFailedAuditEvent(models.AuditActionAccountLogin, 0, identity.AccountID, auditReasonUnknownAccount, map[string]any{"provider": provider.ID()}))
+			return nil, oauth.AccessDenied("The account linked to your " + provider.Name() + " identity has been deleted.")
+		}
+		return nil, oauth.ServerError(err.Error())
+	}
+
+	if account.IsSuspended(now) {
+		recordAuditEvent(ctx, s.auditRepository, newFailedAuditEvent(models.AuditActionAccountLogin, account.ID, account.ID, auditReasonSuspended, map[string]any{"provider": provider.ID()}))
+		return nil, oauth.AccessDenied("Your account is suspended.")
+	}
+
+	identity.Email = profile.Email
+	audit := newAuditEvent(models.AuditActionAccountLogin, account.ID, account.ID, map[string]any{"provider": provider.ID()})
+	if err := s.federationRepository.RecordIdentityLogin(ctx, identity, now, audit); err != nil {
+		return nil, oauth.ServerError(err.Error())
+	}
+	account.LastLoginAt = &now
+
+	return account, nil
+}
+
+// newAccount builds the account of a user signing in for the first time.
+// The provider verified the email address, so the account starts verified.
+// Its password is empty, which matches no password, until the user resets
+// it. Optional fields that are invalid, or a phone number another account
+// uses, are left out.
+func (s *federationService) newAccount(ctx context.Context, profile *federation.Profile, now time.Time) (*models.Account, error) {
+	defaultRole, err := s.roleRepository.GetRoleByName(ctx, s.cfg.DefaultRole)
+	if err != nil {
+		return nil, oauth.ServerError(err.Error())
+	}
+
+	optional := struct {
+		Phone    string `validate:"omitempty,e164"`
+		PhotoUrl string `validate:"omitempty,url"`
+		Locale   string `validate:"omitempty,bcp47_language_tag"`
+	}{profile.Phone, profile.PhotoURL, profile.Locale}
+	if err := validator.ValidateStruct(optional); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			for _, validationError := range validationErrors {
+				switch validationError.Field {
+				case "Phone":
+					optional.Phone = ""
+				case "PhotoUrl":
+					optional.PhotoUrl = ""
+				case "Locale":
+					optional.Locale = ""
+				}
+			}
+		}
+	}
+	if optional.Phone != "" && s.accountRepository.ExistsByPhone(ctx, optional.Phone) {
+		optional.Phone = ""
+	}
+
+	firstName, lastName := clipName(profile.FirstName), clipName(profile.LastName)
+	if firstName == "" {
+		firstName, _, _ = strings.Cut(profile.Email, "@")
+		firstName = clipName(firstName)
+	}
+
+	return &models.Account{
+		FirstName:          firstName,
+		LastName:           lastName,
+		Email:              profile.Email,
+		Phone:              optional.Phone,
+		PhotoUrl:           optional.PhotoUrl,
+		VerificationStatus: "verified",
+		LastLoginAt:        &now,
+		Locale:             optional.Locale,
+		AccountPassword:    models.AccountPassword{},
+		Roles:              []models.Role{*defaultRole},
+		AccountTokens: models.AccountToken{
+			EmailVerificationToken: uuid.New().String(),
+			PhoneVerificationToken: uuid.New().String(),
+		},
+	}, nil
+}
+
+// staleFederatedLogin is the error for a callback without a matching
+// sign-in, whether it expired, was used or came to another browser.
+func staleFederatedLogin() error {
+	return oauth.InvalidRequest("The sign-in was started in another browser or has expired. Please start again.")
+}
+
+func (s *federationService) callbackURI(providerID string) string {
+	return s.cfg.PublicBaseURL + "/oauth/federated/" + providerID + "/callback"
+}
+
+// serviceProvider is the service as a SAML service provider to a provider.
+// Its entity ID is the URL of its metadata.
+func (s *federationService) serviceProvider(providerID string) *saml.ServiceProvider {
+	return &saml.ServiceProvider{
+		EntityID: s.cfg.PublicBaseURL + "/oauth/federated/" + providerID + "/metadata",
+		ACSURL:   s.cfg.PublicBaseURL + "/oauth/federated/" + providerID + "/acs",
+	}
+}
+
+// validReturnTo reports whether returnTo is a page of the authorization
+// server, so a sign-in cannot be used to send the user elsewhere.
+func validReturnTo(returnTo string) bool {
+	if !strings.HasPrefix(returnTo, "/oauth/") || strings.ContainsAny(returnTo, "\\\r\n") {
+		return false
+	}
+
+	u, err := url.Parse(returnTo)
+	return err == nil && u.Scheme == "" && u.Host == ""
+}
+
+func clipName(name string) string {
+	if runes := []rune(name); len(runes) > maxNameLength {
+		return strings.TrimSpace(string(runes[:maxNameLength]))
+	}
+	return name
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/ssoydabas/auth-service/pkg/errors"
+	"github.com/ssoydabas/auth-service/pkg/validator"
+)
+
+// StartImpersonation opens an impersonation session and issues a token for
+// it. The token's sub is the impersonated account and its act claim the
+// administrator. It expires after cfg.ImpersonationTTL and stops working
+// earlier when the session is stopped.
+func (s *accountService) StartImpersonation(ctx context.Context, adminID uint, id string, req dto.StartImpersonationRequest) (*dto.ImpersonationResponse, error) {
+	if err := req.Validate(); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			return nil, errors.ValidationError("Validation failed", validationErrors)
+		}
+		return nil, errors.BadRequestError(err.Error())
+	}
+
+	account, err := s.accountRepository.GetAccountByID(ctx, id, false)
+	if err != nil {
+		return nil, errors.NotFoundError("Account not found")
+	}
+
+	if account.ID == adminID {
+		return nil, errors.ForbiddenError("You cannot impersonate yourself")
+	}
+
+	// Impersonating another administrator would hand out their permissions.
+	privileged, err := s.roleRepository.AccountHasPermission(ctx, account.ID, models.PermissionAccountsImpersonate)
+	if err != nil {
+		return nil, errors.InternalError(err)
+	}
+	if privileged {
+		return nil, errors.ForbiddenError("Accounts that can impersonate others cannot be impersonated")
+	}
+
+	now := time.Now()
+	if account.IsSuspended(now) {
+		return nil, errors.ConflictError("Suspended accounts cannot be impersonated")
+	}
+
+	session := &models.ImpersonationSession{
+		PublicID:  uuid.New().String(),
+		AdminID:   adminID,
+		AccountID: account.ID,
+		Reason:    req.Reason,
+		ExpiresAt: now.Add(s.cfg.ImpersonationTTL).Truncate(time.Second),
+	}
+
+	audit := newAuditEvent(models.AuditActionImpersonationStarted, adminID, account.ID, map[string]any{
+		"session_id": session.PublicID,
+		"reason":     req.Reason,
+		"expires_at": session.ExpiresAt.Format(time.RFC3339),
+	})
+
+	if err := s.accountRepository.CreateImpersonationSession(ctx, session, audit); err != nil {
+		return nil, errors.InternalError(err)
+	}
+
+	token, err := signToken(jwt.MapClaims{
+		"sub":        account.ID,
+		claimActor:   map[string]interface{}{"sub": adminID},
+		claimTokenID: session.PublicID,
+		"iat":        now.Unix(),
+		"exp":        session.ExpiresAt.Unix(),
+	})
+	if err != nil {
+		return nil, errors.InternalError(err)
+	}
+
+	return &dto.ImpersonationResponse{
+		SessionID: session.PublicID,
+		Token:     token,
+		ExpiresAt: session.ExpiresAt.Format(time.RFC3339),
+	}, nil
+}
+
+// StopImpersonation ends the session of an impersonation token, which
+// revokes the token.
+func (s *accountService) StopImpersonation(ctx context.Context, tokenString string) error {
+	claims, err := parseToken(tokenString)
+	if err != nil {
+		return err
+	}
+
+	adminID, ok := tokenActor(claims)
+	if !ok {
+		return errors.BadRequestError("Not an impersonation token")
+	}
+
+	sessionID, _ := claims[claimTokenID].(string)
+	accountID := uint(claims["sub"].(float64))
+
+	audit := newAuditEvent(models.AuditActionImpersonationStopped, adminID, accountID, map[string]any{
+		"session_id": sessionID,
+	})
+
+	ended, err := s.accountRepository.EndImpersonationSession(ctx, sessionID, time.Now(), audit)
+	if err != nil {
+		return errors.InternalError(err)
+	}
+	if !ended {
+		return errors.AuthError("Impersonation session has ended")
+	}
+
+	return nil
+}
+
+// checkImpersonationSession rejects impersonation tokens whose session has
+// been stopped or does not match the token.
+func (s *accountService) checkImpersonationSession(ctx context.Context, claims jwt.MapClaims, adminID, accountID uint) error {
+	sessionID, _ := claims[claimTokenID].(string)
+	if sessionID == "" {
+		return errors.BadRequestError("Invalid token")
+	}
+
+	session, err := s.accountRepository.GetImpersonationSession(ctx, sessionID)
+	if err != nil || session.AdminID != adminID || session.AccountID != accountID || !session.IsActive(time.Now()) {
+		return errors.AuthError("Impersonation session has ended")
+	}
+
+	return nil
+}
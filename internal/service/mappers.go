@@ -0,0 +1,203 @@
+package service
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/oauth"
+	"github.com/ssoydabas/auth-service/models"
+)
+
+func mapAccountModelToResponse(account *models.Account) *dto.AccountResponse {
+	response := dto.AccountResponse{
+		ID:                 account.ID,
+		FirstName:          account.FirstName,
+		LastName:           account.LastName,
+		Email:              account.Email,
+		Phone:              account.Phone,
+		PhotoUrl:           account.PhotoUrl,
+		VerificationStatus: account.VerificationStatus,
+		Roles:              roleNames(account.Roles),
+		Locale:             account.Locale,
+		CreatedAt:          account.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:          account.UpdatedAt.Format(time.RFC3339),
+		ETag:               accountETag(account.UpdatedAt),
+	}
+
+	if account.LastLoginAt != nil {
+		lastLoginAt := account.LastLoginAt.Format(time.RFC3339)
+		response.LastLoginAt = &lastLoginAt
+	}
+
+	if account.IsSuspended(time.Now()) {
+		suspension := dto.SuspensionResponse{
+			Reason:      account.SuspensionReason,
+			SuspendedAt: account.SuspendedAt.Format(time.RFC3339),
+			SuspendedBy: account.SuspendedByID,
+		}
+		if account.SuspendedUntil != nil {
+			until := account.SuspendedUntil.Format(time.RFC3339)
+			suspension.Until = &until
+		}
+		response.Suspension = &suspension
+	}
+
+	return &response
+}
+
+func roleNames(roles []models.Role) []string {
+	names := make([]string, 0, len(roles))
+	for _, role := range roles {
+		names = append(names, role.Name)
+	}
+	return names
+}
+
+func mapRoleModelToResponse(role *models.Role) *dto.RoleResponse {
+	permissions := make([]string, 0, len(role.Permissions))
+	for _, permission := range role.Permissions {
+		permissions = append(permissions, permission.Name)
+	}
+
+	return &dto.RoleResponse{
+		ID:          role.ID,
+		Name:        role.Name,
+		Description: role.Description,
+		System:      role.System,
+		Permissions: permissions,
+	}
+}
+
+func mapPermissionModelToResponse(permission *models.Permission) *dto.PermissionResponse {
+	return &dto.PermissionResponse{
+		ID:          permission.ID,
+		Name:        permission.Name,
+		Description: permission.Description,
+		System:      permission.System,
+	}
+}
+
+func mapAuditEventModelToResponse(event *models.AuditEvent) dto.AuditEventResponse {
+	response := dto.AuditEventResponse{
+		ID:        event.ID,
+		Sequence:  event.Sequence,
+		CreatedAt: event.CreatedAt.Format(time.RFC3339Nano),
+		Action:    event.Action,
+		Outcome:   event.Outcome,
+		ActorID:   event.ActorID,
+		TargetID:  event.TargetID,
+		IP:        event.IP,
+		UserAgent: event.UserAgent,
+		PrevHash:  event.PrevHash,
+		Hash:      event.Hash,
+	}
+
+	if event.Metadata != "" && event.Metadata != "{}" {
+		response.Metadata = json.RawMessage(event.Metadata)
+	}
+
+	return response
+}
+
+func mapDataExportModelToResponse(export *models.DataExport) *dto.DataExportResponse {
+	response := dto.DataExportResponse{
+		ID:        export.PublicID,
+		Status:    export.Status,
+		CreatedAt: export.CreatedAt.Format(time.RFC3339),
+	}
+
+	if export.ExpiresAt != nil {
+		expiresAt := export.ExpiresAt.Format(time.RFC3339)
+		response.ExpiresAt = &expiresAt
+	}
+
+	return &response
+}
+
+func mapOAuthGrantModelToResponse(grant *models.OAuthGrant, clientName string) *dto.OAuthGrantResponse {
+	return &dto.OAuthGrantResponse{
+		ClientID:   grant.ClientID,
+		ClientName: clientName,
+		Scopes:     grant.Scopes,
+		CreatedAt:  grant.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:  grant.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+func mapSessionModelToResponse(session *models.Session) *dto.SessionResponse {
+	response := dto.SessionResponse{
+		ID:        session.PublicID,
+		AuthTime:  session.AuthTime.Format(time.RFC3339),
+		AMR:       session.AMR,
+		IP:        session.IP,
+		UserAgent: session.UserAgent,
+		ExpiresAt: session.ExpiresAt.Format(time.RFC3339),
+	}
+	if session.RevokedAt != nil {
+		revokedAt := session.RevokedAt.Format(time.RFC3339)
+		response.RevokedAt = &revokedAt
+	}
+	return &response
+}
+
+func mapOAuthClientModelToResponse(client *models.OAuthClient) *dto.OAuthClientResponse {
+	response := dto.OAuthClientResponse{
+		ClientID:                client.ClientID,
+		Name:                    client.Name,
+		RedirectURIs:            client.RedirectURIs,
+		Scopes:                  client.Scopes,
+		GrantTypes:              client.GrantTypes,
+		TokenEndpointAuthMethod: client.TokenEndpointAuthMethod,
+		PostLogoutRedirectURIs:  client.PostLogoutRedirectURIs,
+		BackchannelLogoutURI:    client.BackchannelLogoutURI,
+		JWKSURI:                 client.JWKSURI,
+		CreatedAt:               client.CreatedAt.Format(time.RFC3339),
+	}
+
+	if client.SecretRotatedAt != nil {
+		rotatedAt := client.SecretRotatedAt.Format(time.RFC3339)
+		response.SecretRotatedAt = &rotatedAt
+	}
+
+	if client.DisabledAt != nil {
+		disabledAt := client.DisabledAt.Format(time.RFC3339)
+		response.DisabledAt = &disabledAt
+	}
+
+	return &response
+}
+
+func mapClientRegistrationResponse(client *models.OAuthClient, registrationToken, registrationURI string) *dto.ClientRegistrationResponse {
+	response := dto.ClientRegistrationResponse{
+		ClientID:                client.ClientID,
+		ClientIDIssuedAt:        client.CreatedAt.Unix(),
+		RegistrationAccessToken: registrationToken,
+		RegistrationClientURI:   registrationURI,
+		ClientName:              client.Name,
+		RedirectURIs:            client.RedirectURIs,
+		GrantTypes:              client.GrantTypes,
+		ResponseTypes:           []string{},
+		TokenEndpointAuthMethod: client.TokenEndpointAuthMethod,
+		Scope:                   oauth.FormatScope(client.Scopes),
+		JWKSURI:                 client.JWKSURI,
+		PostLogoutRedirectURIs:  client.PostLogoutRedirectURIs,
+		BackchannelLogoutURI:    client.BackchannelLogoutURI,
+	}
+
+	if client.AllowsGrant(oauth.GrantAuthorizationCode) {
+		response.ResponseTypes = []string{oauth.ResponseTypeCode}
+	}
+
+	if usesSecret(client.TokenEndpointAuthMethod) {
+		var neverExpires int64
+		response.ClientSecretExpiresAt = &neverExpires
+	}
+
+	var keys oauth.JWKS
+	if len(client.JWKS) > 0 && json.Unmarshal(client.JWKS, &keys) == nil && len(keys.Keys) > 0 {
+		response.JWKS = &keys
+	}
+
+	return &response
+}
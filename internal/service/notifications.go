@@ -0,0 +1,35 @@
+package service
+
+import (
+	"context"
+
+	"github.com/ssoydabas/auth-service/internal/notification"
+	"github.com/ssoydabas/auth-service/internal/outbox"
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/ssoydabas/auth-service/pkg/locale"
+)
+
+const securityEventPasswordChanged = "password_changed"
+
+// accountLocale prefers the account's stored locale and falls back to the
+// Accept-Language header of the current request.
+func (s *accountService) accountLocale(ctx context.Context, account models.Account) string {
+	return s.templates.ResolveLocale(account.Locale, locale.AcceptLanguage(ctx))
+}
+
+// notification renders a message of the given kind for account as an outbox
+// message. The repository stores it in the same transaction as the account
+// change, and the outbox dispatcher delivers it. The account fields of data
+// are filled in from account.
+func (s *accountService) notification(ctx context.Context, kind notification.MessageKind, account models.Account, data notification.TemplateData) (models.OutboxMessage, error) {
+	data.FirstName = account.FirstName
+	data.LastName = account.LastName
+	data.Email = account.Email
+
+	msg, err := s.templates.Message(kind, s.accountLocale(ctx, account), account.Email, data)
+	if err != nil {
+		return models.OutboxMessage{}, err
+	}
+
+	return outbox.NewNotificationMessage(msg)
+}
@@ -0,0 +1,674 @@
+package service
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/oauth"
+	"github.com/ssoydabas/auth-service/internal/repository"
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/ssoydabas/auth-service/pkg/clientinfo"
+	"github.com/ssoydabas/auth-service/pkg/config"
+	"github.com/ssoydabas/auth-service/pkg/errors"
+	"github.com/ssoydabas/auth-service/pkg/validator"
+	"gorm.io/gorm"
+)
+
+// Claims of OAuth access tokens beyond those of the service's own tokens.
+const (
+	claimClientID  = "client_id"
+	claimScope     = "scope"
+	claimSessionID = "sid"
+)
+
+type OAuthService interface {
+	Authorize(ctx context.Context, req dto.AuthorizeRequest, sessionToken string) (*AuthorizeResult, error)
+	Login(ctx context.Context, req dto.AuthorizeRequest, credentials dto.AuthenticateAccountRequest) (*AuthorizeResult, error)
+	Consent(ctx context.Context, req dto.AuthorizeRequest, sessionToken string, approve bool) (*AuthorizeResult, error)
+	EndSession(ctx context.Context, req dto.EndSessionRequest, sessionToken string, confirmed bool) (*LogoutResult, error)
+	Token(ctx context.Context, req dto.TokenRequest) (*dto.OAuthTokenResponse, error)
+	CreateClient(ctx context.Context, actorID uint, req dto.CreateOAuthClientRequest) (*dto.OAuthClientResponse, error)
+	ListClients(ctx context.Context) ([]dto.OAuthClientResponse, error)
+	RotateClientSecret(ctx context.Context, actorID uint, clientID string) (*dto.OAuthClientResponse, error)
+	DisableClient(ctx context.Context, actorID uint, clientID string) (*dto.OAuthClientResponse, error)
+	IssueInitialAccessToken(ctx context.Context, actorID uint, req dto.CreateInitialAccessTokenRequest) (*dto.InitialAccessTokenResponse, error)
+	RegisterClient(ctx context.Context, initialAccessToken string, req dto.ClientRegistrationRequest) (*dto.ClientRegistrationResponse, error)
+	GetClientRegistration(ctx context.Context, clientID, registrationToken string) (*dto.ClientRegistrationResponse, error)
+	UpdateClientRegistration(ctx context.Context, clientID, registrationToken string, req dto.ClientRegistrationRequest) (*dto.ClientRegistrationResponse, error)
+	DeleteClientRegistration(ctx context.Context, clientID, registrationToken string) error
+	ListGrants(ctx context.Context, accountID uint) ([]dto.OAuthGrantResponse, error)
+	RevokeGrant(ctx context.Context, accountID uint, clientID string) error
+	DeviceAuthorization(ctx context.Context, req dto.DeviceAuthorizationRequest) (*dto.DeviceAuthorizationResponse, error)
+	SignIn(ctx context.Context, credentials dto.AuthenticateAccountRequest) (*AuthorizeResult, error)
+	VerifyDeviceCode(ctx context.Context, sessionToken, userCode string) (*DeviceVerification, error)
+	DecideDeviceCode(ctx context.Context, sessionToken, userCode string, approve bool) (*DeviceVerification, error)
+	UserInfo(ctx context.Context, accessToken string) (map[string]any, error)
+	Discovery() *dto.OpenIDConfigurationResponse
+	JWKS() oauth.JWKS
+}
+
+// AuthorizeResult tells the authorization endpoint how to answer. Either
+// RedirectURL is set and the browser is sent back to the client,
+// LoginRequired is set and the sign-in page is shown for ClientName, or
+// ConsentRequired is set and the user is asked to allow ClientName the
+// Scopes it has not been granted yet. When SessionToken is set a session
+// was started and its cookie must be set; SignIn only sets the session
+// fields.
+type AuthorizeResult struct {
+	RedirectURL      string
+	LoginRequired    bool
+	ConsentRequired  bool
+	ClientName       string
+	Scopes           []string
+	SessionToken     string
+	SessionExpiresAt time.Time
+}
+
+type oauthService struct {
+	oauthRepository   repository.OAuthRepository
+	accountRepository repository.AccountRepository
+	auditRepository   repository.AuditRepository
+	accountService    AccountService
+	signingKey        *oauth.SigningKey
+	httpClient        *http.Client
+	cfg               config.Config
+}
+
+// clientRequestTimeout bounds requests the service makes to clients' servers,
+// such as fetching keys from a JWKS URI.
+const clientRequestTimeout = 10 * time.Second
+
+// NewOAuthService creates the authorization server. signingKey signs ID
+// tokens; access tokens are signed with the service's own secret.
+func NewOAuthService(oauthRepository repository.OAuthRepository, accountRepository repository.AccountRepository, auditRepository repository.AuditRepository, accountService AccountService, signingKey *oauth.SigningKey, cfg config.Config) OAuthService {
+	return &oauthService{
+		oauthRepository:   oauthRepository,
+		accountRepository: accountRepository,
+		auditRepository:   auditRepository,
+		accountService:    accountService,
+		signingKey:        signingKey,
+		httpClient:        &http.Client{Timeout: clientRequestTimeout},
+		cfg:               cfg,
+	}
+}
+
+// authorization is an authorization request that passed validation. maxAge
+// is negative when the request did not limit the age of the sign-in.
+type authorization struct {
+	client      *models.OAuthClient
+	redirectURI string
+	scope       string
+	maxAge      time.Duration
+	request     dto.AuthorizeRequest
+}
+
+// acceptsSession reports whether session can complete the request without
+// signing the user in again, which prompt=login and max_age can demand.
+func (a *authorization) acceptsSession(session *models.Session, now time.Time) bool {
+	if a.request.Prompt == oauth.PromptLogin {
+		return false
+	}
+	return a.maxAge < 0 || !now.After(session.AuthTime.Add(a.maxAge))
+}
+
+// Authorize handles an authorization request. With an active session the
+// code is issued at once, unless the user still has to allow the client
+// some of the scopes; without one the caller has to sign the user in.
+func (s *oauthService) Authorize(ctx context.Context, req dto.AuthorizeRequest, sessionToken string) (*AuthorizeResult, error) {
+	authz, err := s.validateAuthorization(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := s.activeSession(ctx, sessionToken)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil || !authz.acceptsSession(session, time.Now()) {
+		if authz.request.Prompt == oauth.PromptNone {
+			return nil, oauth.LoginRequired("The user is not signed in").WithRedirect(authz.redirectURI, req.State)
+		}
+		return &AuthorizeResult{LoginRequired: true, ClientName: authz.client.Name}, nil
+	}
+
+	return s.completeAuthorization(ctx, authz, session)
+}
+
+// Login signs the user in with the same credential check as
+// AuthenticateAccount, starts a session and completes the authorization
+// request, or asks for consent first. Wrong credentials are returned as an *errors.AppError so the
+// sign-in page can be shown again.
+func (s *oauthService) Login(ctx context.Context, req dto.AuthorizeRequest, credentials dto.AuthenticateAccountRequest) (*AuthorizeResult, error) {
+	authz, err := s.validateAuthorization(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	session, token, err := s.startSession(ctx, credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.completeAuthorization(ctx, authz, session)
+	if err != nil {
+		return nil, err
+	}
+
+	result.SessionToken = token
+	result.SessionExpiresAt = session.ExpiresAt
+	return result, nil
+}
+
+// SignIn signs the user in and starts a session without completing an
+// authorization request, as the device verification page does.
+func (s *oauthService) SignIn(ctx context.Context, credentials dto.AuthenticateAccountRequest) (*AuthorizeResult, error) {
+	session, token, err := s.startSession(ctx, credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthorizeResult{SessionToken: token, SessionExpiresAt: session.ExpiresAt}, nil
+}
+
+// startSession checks credentials and starts a browser session for the
+// account. It returns the session and the token for its cookie.
+func (s *oauthService) startSession(ctx context.Context, credentials dto.AuthenticateAccountRequest) (*models.Session, string, error) {
+	if credentials.Email == "" && credentials.Phone == "" {
+		return nil, "", errors.BadRequestError("Email or phone is required")
+	}
+	if err := credentials.Validate(); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			return nil, "", errors.ValidationError("Validation failed", validationErrors)
+		}
+		return nil, "", errors.BadRequestError(err.Error())
+	}
+
+	account, err := s.accountService.VerifyCredentials(ctx, credentials)
+	if err != nil {
+		return nil, "", err
+	}
+
+	session, token, err := createSession(ctx, s.oauthRepository, account.ID, oauth.AMRPassword, s.cfg.SessionTTL)
+	if err != nil {
+		return nil, "", errors.InternalError(err)
+	}
+
+	return session, token, nil
+}
+
+// createSession starts a browser session of ttl for an account that signed
+// in with amr. It returns the session and the token for its cookie.
+func createSession(ctx context.Context, oauthRepository repository.OAuthRepository, accountID uint, amr string, ttl time.Duration) (*models.Session, string, error) {
+	token, err := oauth.NewToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	client := clientinfo.FromContext(ctx)
+	session := &models.Session{
+		PublicID:  uuid.New().String(),
+		TokenHash: oauth.HashToken(token),
+		AccountID: accountID,
+		AuthTime:  now.Truncate(time.Second),
+		AMR:       []string{amr},
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+		ExpiresAt: now.Add(ttl).Truncate(time.Second),
+	}
+	if err := oauthRepository.CreateSession(ctx, session); err != nil {
+		return nil, "", err
+	}
+
+	return session, token, nil
+}
+
+// validateAuthorization checks an authorization request. Problems with the
+// client or redirect URI are not redirectable, since the redirect target
+// cannot be trusted; every later problem is returned to the client.
+func (s *oauthService) validateAuthorization(ctx context.Context, req dto.AuthorizeRequest) (*authorization, error) {
+	if req.ClientID == "" {
+		return nil, oauth.InvalidRequest("client_id is required")
+	}
+
+	client, err := s.oauthRepository.GetOAuthClient(ctx, req.ClientID)
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, oauth.InvalidClient("Unknown client")
+		}
+		return nil, oauth.ServerError(err.Error())
+	}
+	if client.DisabledAt != nil {
+		return nil, oauth.InvalidClient("Client is disabled")
+	}
+
+	redirectURI := req.RedirectURI
+	if redirectURI == "" {
+		if len(client.RedirectURIs) != 1 {
+			return nil, oauth.InvalidRequest("redirect_uri is required")
+		}
+		redirectURI = client.RedirectURIs[0]
+	} else if matched, ok := oauth.MatchRedirectURI(client.RedirectURIs, redirectURI); ok {
+		redirectURI = matched
+	} else {
+		return nil, oauth.InvalidRequest("redirect_uri is not registered for this client")
+	}
+
+	redirectable := func(e *oauth.Error) error {
+		return e.WithRedirect(redirectURI, req.State)
+	}
+
+	if req.ResponseType != oauth.ResponseTypeCode {
+		return nil, redirectable(oauth.UnsupportedResponseType("response_type must be code"))
+	}
+	if !client.AllowsGrant(oauth.GrantAuthorizationCode) {
+		return nil, redirectable(oauth.UnauthorizedClient("The client may not use the authorization code grant"))
+	}
+
+	if req.CodeChallenge == "" {
+		return nil, redirectable(oauth.InvalidRequest("code_challenge is required"))
+	}
+	if req.CodeChallengeMethod != oauth.MethodS256 {
+		return nil, redirectable(oauth.InvalidRequest("code_challenge_method must be S256"))
+	}
+	if !oauth.ValidChallenge(req.CodeChallenge) {
+		return nil, redirectable(oauth.InvalidRequest("code_challenge is malformed"))
+	}
+
+	scopes := oauth.ParseScope(req.Scope)
+	if len(scopes) == 0 {
+		scopes = client.Scopes
+	}
+	if !oauth.ScopeSubset(scopes, client.Scopes) {
+		return nil, redirectable(oauth.InvalidScope("The requested scope is not allowed for this client"))
+	}
+
+	switch req.Prompt {
+	case "", oauth.PromptNone, oauth.PromptLogin, oauth.PromptConsent:
+	default:
+		return nil, redirectable(oauth.InvalidRequest(fmt.Sprintf("Unsupported prompt %q", req.Prompt)))
+	}
+
+	maxAge := time.Duration(-1)
+	if req.MaxAge != "" {
+		seconds, err := strconv.ParseUint(req.MaxAge, 10, 32)
+		if err != nil {
+			return nil, redirectable(oauth.InvalidRequest("max_age must be a number of seconds"))
+		}
+		maxAge = time.Duration(seconds) * time.Second
+	}
+
+	if len(req.Nonce) > 255 {
+		return nil, redirectable(oauth.InvalidRequest("nonce is too long"))
+	}
+
+	return &authorization{
+		client:      client,
+		redirectURI: redirectURI,
+		scope:       oauth.FormatScope(scopes),
+		maxAge:      maxAge,
+		request:     req,
+	}, nil
+}
+
+// activeSession returns the session of sessionToken, or nil when there is
+// none or it can no longer be used.
+func (s *oauthService) activeSession(ctx context.Context, sessionToken string) (*models.Session, error) {
+	if sessionToken == "" {
+		return nil, nil
+	}
+
+	session, err := s.oauthRepository.GetSessionByTokenHash(ctx, oauth.HashToken(sessionToken))
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, oauth.ServerError(err.Error())
+	}
+
+	now := time.Now()
+	if !session.IsActive(now) {
+		return nil, nil
+	}
+
+	account, err := s.accountRepository.GetAccountByID(ctx, strconv.FormatUint(uint64(session.AccountID), 10), false)
+	if err != nil || account.IsSuspended(now) {
+		return nil, nil
+	}
+
+	return session, nil
+}
+
+// issueCode stores a new authorization code for the session and returns
+// the URL that hands it to the client. The iss parameter lets the client
+// tell which authorization server answered (RFC 9207).
+func (s *oauthService) issueCode(ctx context.Context, authz *authorization, session *models.Session) (string, error) {
+	code, err := oauth.NewToken()
+	if err != nil {
+		return "", oauth.ServerError(err.Error()).WithRedirect(authz.redirectURI, authz.request.State)
+	}
+
+	record := &models.OAuthAuthorizationCode{
+		CodeHash:            oauth.HashToken(code),
+		ClientID:            authz.client.ClientID,
+		AccountID:           session.AccountID,
+		SessionID:           session.PublicID,
+		RedirectURI:         authz.request.RedirectURI,
+		Scope:               authz.scope,
+		CodeChallenge:       authz.request.CodeChallenge,
+		CodeChallengeMethod: authz.request.CodeChallengeMethod,
+		Nonce:               authz.request.Nonce,
+		AuthTime:            session.AuthTime,
+		AMR:                 session.AMR,
+		ExpiresAt:           time.Now().Add(s.cfg.OAuthCodeTTL),
+	}
+
+	audit := newAuditEvent(models.AuditActionOAuthAuthorized, session.AccountID, session.AccountID, map[string]any{
+		"client_id":  authz.client.ClientID,
+		"scope":      authz.scope,
+		"session_id": session.PublicID,
+	})
+
+	if err := s.oauthRepository.CreateAuthorizationCode(ctx, record, audit); err != nil {
+		return "", oauth.ServerError(err.Error()).WithRedirect(authz.redirectURI, authz.request.State)
+	}
+
+	return oauth.AppendQuery(authz.redirectURI, url.Values{
+		"code":  {code},
+		"state": {authz.request.State},
+		"iss":   {s.cfg.PublicBaseURL},
+	}), nil
+}
+
+// Token handles a request to the token endpoint.
+func (s *oauthService) Token(ctx context.Context, req dto.TokenRequest) (*dto.OAuthTokenResponse, error) {
+	if req.GrantType == "" {
+		return nil, oauth.InvalidRequest("grant_type is required")
+	}
+
+	switch req.GrantType {
+	case oauth.GrantAuthorizationCode, oauth.GrantRefreshToken, oauth.GrantClientCredentials, oauth.GrantDeviceCode, oauth.GrantTokenExchange:
+	default:
+		return nil, oauth.UnsupportedGrantType(fmt.Sprintf("Unsupported grant_type %q", req.GrantType))
+	}
+
+	client, err := s.authenticateClient(ctx, req.Client())
+	if err != nil {
+		return nil, err
+	}
+	if !client.AllowsGrant(req.GrantType) {
+		return nil, oauth.UnauthorizedClient(fmt.Sprintf("The client may not use the %s grant", req.GrantType))
+	}
+
+	switch req.GrantType {
+	case oauth.GrantAuthorizationCode:
+		return s.exchangeAuthorizationCode(ctx, client, req)
+	case oauth.GrantRefreshToken:
+		return s.refresh(ctx, client, req)
+	case oauth.GrantDeviceCode:
+		return s.exchangeDeviceCode(ctx, client, req)
+	case oauth.GrantTokenExchange:
+		return s.tokenExchange(ctx, client, req)
+	default:
+		return s.clientCredentials(client, req)
+	}
+}
+
+func (s *oauthService) exchangeAuthorizationCode(ctx context.Context, client *models.OAuthClient, req dto.TokenRequest) (*dto.OAuthTokenResponse, error) {
+	if req.Code == "" {
+		return nil, oauth.InvalidRequest("code is required")
+	}
+	if req.CodeVerifier == "" {
+		return nil, oauth.InvalidRequest("code_verifier is required")
+	}
+
+	now := time.Now()
+	code, consumed, err := s.oauthRepository.ConsumeAuthorizationCode(ctx, oauth.HashToken(req.Code), now)
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, oauth.InvalidGrant("Invalid authorization code")
+		}
+		return nil, oauth.ServerError(err.Error())
+	}
+
+	if !consumed {
+		// A code presented twice may have been stolen; whatever was issued
+		// for it is revoked.
+		if err := s.oauthRepository.RevokeRefreshTokensByAuthorizationCode(ctx, code.ID, now); err != nil {
+			return nil, oauth.ServerError(err.Error())
+		}
+		recordAuditEvent(ctx, s.auditRepository, newFailedAuditEvent(models.AuditActionOAuthTokenReused, 0, code.AccountID, auditReasonCodeReused, map[string]any{
+			"client_id": code.ClientID,
+		}))
+		return nil, oauth.InvalidGrant("Authorization code has already been used")
+	}
+
+	if code.ClientID != client.ClientID {
+		return nil, oauth.InvalidGrant("Authorization code was issued to another client")
+	}
+	if !now.Before(code.ExpiresAt) {
+		return nil, oauth.InvalidGrant("Authorization code has expired")
+	}
+	if code.RedirectURI != "" && code.RedirectURI != req.RedirectURI {
+		return nil, oauth.InvalidGrant("redirect_uri does not match the authorization request")
+	}
+	if !oauth.VerifyPKCE(req.CodeVerifier, code.CodeChallenge, code.CodeChallengeMethod) {
+		return nil, oauth.InvalidGrant("code_verifier does not match the code challenge")
+	}
+
+	if err := s.checkAccount(ctx, code.AccountID, now); err != nil {
+		return nil, err
+	}
+
+	g := grant{
+		clientID:  client.ClientID,
+		accountID: code.AccountID,
+		sessionID: code.SessionID,
+		scope:     code.Scope,
+		authTime:  code.AuthTime,
+		amr:       code.AMR,
+		nonce:     code.Nonce,
+	}
+
+	refreshToken, record, err := s.newRefreshToken(g, uuid.New().String(), now)
+	if err != nil {
+		return nil, oauth.ServerError(err.Error())
+	}
+	record.AuthorizationCodeID = &code.ID
+
+	if err := s.oauthRepository.CreateRefreshToken(ctx, record); err != nil {
+		return nil, oauth.ServerError(err.Error())
+	}
+
+	return s.tokenResponse(g, refreshToken, now)
+}
+
+// refresh redeems a refresh token. Tokens are rotated: the presented token
+// is revoked and a new one issued in its place. A revoked token that is
+// presented again means the token leaked, so its whole family is revoked.
+// Tokens issued during a session stop working when the session is ended by
+// a logout; they outlive a session that merely expires.
+func (s *oauthService) refresh(ctx context.Context, client *models.OAuthClient, req dto.TokenRequest) (*dto.OAuthTokenResponse, error) {
+	if req.RefreshToken == "" {
+		return nil, oauth.InvalidRequest("refresh_token is required")
+	}
+
+	current, err := s.oauthRepository.GetRefreshTokenByHash(ctx, oauth.HashToken(req.RefreshToken))
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, oauth.InvalidGrant("Invalid refresh token")
+		}
+		return nil, oauth.ServerError(err.Error())
+	}
+
+	if current.ClientID != client.ClientID {
+		return nil, oauth.InvalidGrant("Refresh token was issued to another client")
+	}
+
+	if current.SessionID != "" {
+		session, err := s.oauthRepository.GetSessionByPublicID(ctx, current.SessionID)
+		if err != nil && !stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, oauth.ServerError(err.Error())
+		}
+		if session == nil || session.RevokedAt != nil {
+			return nil, oauth.InvalidGrant("The session of the refresh token has ended")
+		}
+	}
+
+	now := time.Now()
+	if current.RevokedAt != nil {
+		return nil, s.revokeReusedFamily(ctx, current, now)
+	}
+	if !current.IsActive(now) {
+		return nil, oauth.InvalidGrant("Refresh token has expired")
+	}
+
+	scope := current.Scope
+	if req.Scope != "" {
+		requested := oauth.ParseScope(req.Scope)
+		if !oauth.ScopeSubset(requested, oauth.ParseScope(current.Scope)) {
+			return nil, oauth.InvalidScope("The requested scope exceeds the scope originally granted")
+		}
+		scope = oauth.FormatScope(requested)
+	}
+
+	if err := s.checkAccount(ctx, current.AccountID, now); err != nil {
+		return nil, err
+	}
+
+	g := grant{
+		clientID:  client.ClientID,
+		accountID: current.AccountID,
+		sessionID: current.SessionID,
+		scope:     current.Scope,
+		authTime:  current.AuthTime,
+		amr:       current.AMR,
+	}
+
+	// The family keeps the originally granted scope, so a narrower access
+	// token does not narrow later refreshes.
+	refreshToken, next, err := s.newRefreshToken(g, current.FamilyID, now)
+	if err != nil {
+		return nil, oauth.ServerError(err.Error())
+	}
+	next.AuthorizationCodeID = current.AuthorizationCodeID
+
+	rotated, err := s.oauthRepository.RotateRefreshToken(ctx, current.ID, next, now)
+	if err != nil {
+		return nil, oauth.ServerError(err.Error())
+	}
+	if !rotated {
+		return nil, s.revokeReusedFamily(ctx, current, now)
+	}
+
+	g.scope = scope
+	return s.tokenResponse(g, refreshToken, now)
+}
+
+func (s *oauthService) revokeReusedFamily(ctx context.Context, token *models.OAuthRefreshToken, now time.Time) error {
+	audit := newFailedAuditEvent(models.AuditActionOAuthTokenReused, 0, token.AccountID, auditReasonTokenReused, map[string]any{
+		"client_id": token.ClientID,
+		"family_id": token.FamilyID,
+	})
+
+	if err := s.oauthRepository.RevokeRefreshTokenFamily(ctx, token.FamilyID, now, audit); err != nil {
+		return oauth.ServerError(err.Error())
+	}
+
+	return oauth.InvalidGrant("Refresh token has been revoked")
+}
+
+// checkAccount rejects token requests for accounts that were deleted or
+// suspended after the grant.
+func (s *oauthService) checkAccount(ctx context.Context, accountID uint, now time.Time) error {
+	account, err := s.accountRepository.GetAccountByID(ctx, strconv.FormatUint(uint64(accountID), 10), false)
+	if err != nil {
+		return oauth.InvalidGrant("Account not found")
+	}
+	if account.IsSuspended(now) {
+		return oauth.InvalidGrant("Account is suspended")
+	}
+	return nil
+}
+
+// grant describes what a token response is issued for.
+type grant struct {
+	clientID  string
+	accountID uint
+	sessionID string
+	scope     string
+	authTime  time.Time
+	amr       []string
+	nonce     string
+}
+
+func (s *oauthService) newRefreshToken(g grant, familyID string, now time.Time) (string, *models.OAuthRefreshToken, error) {
+	token, err := oauth.NewToken()
+	if err != nil {
+		return "", nil, err
+	}
+
+	return token, &models.OAuthRefreshToken{
+		TokenHash: oauth.HashToken(token),
+		FamilyID:  familyID,
+		ClientID:  g.clientID,
+		AccountID: g.accountID,
+		SessionID: g.sessionID,
+		Scope:     g.scope,
+		AuthTime:  g.authTime,
+		AMR:       g.amr,
+		ExpiresAt: now.Add(s.cfg.OAuthRefreshTokenTTL),
+	}, nil
+}
+
+// tokenResponse signs an access token for the grant, and an ID token when
+// the openid scope was granted. The access token's sub is the numeric
+// account ID, but its typ keeps the service's own endpoints from accepting
+// it in place of a token of the account.
+func (s *oauthService) tokenResponse(g grant, refreshToken string, now time.Time) (*dto.OAuthTokenResponse, error) {
+	expiresAt := now.Add(s.cfg.OAuthAccessTokenTTL)
+
+	claims := jwt.MapClaims{
+		"iss":          s.cfg.PublicBaseURL,
+		"sub":          g.accountID,
+		claimClientID:  g.clientID,
+		claimTokenID:   uuid.New().String(),
+		"iat":          now.Unix(),
+		"exp":          expiresAt.Unix(),
+		claimSessionID: g.sessionID,
+	}
+	if g.scope != "" {
+		claims[claimScope] = g.scope
+	}
+
+	accessToken, err := signAccessToken(claims)
+	if err != nil {
+		return nil, oauth.ServerError(err.Error())
+	}
+
+	response := &dto.OAuthTokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    oauth.TokenTypeBearer,
+		ExpiresIn:    int64(s.cfg.OAuthAccessTokenTTL / time.Second),
+		RefreshToken: refreshToken,
+		Scope:        g.scope,
+	}
+
+	if hasScope(g.scope, oauth.ScopeOpenID) {
+		idToken, err := s.idToken(g, accessToken, now)
+		if err != nil {
+			return nil, oauth.ServerError(err.Error())
+		}
+		response.IDToken = idToken
+	}
+
+	return response, nil
+}
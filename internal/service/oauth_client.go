@@ -0,0 +1,377 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	stderrors "errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/oauth"
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/ssoydabas/auth-service/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// CreateClient registers a client. Its client ID is generated, and so is
+// its secret when it authenticates with one; the secret is only returned
+// here.
+func (s *oauthService) CreateClient(ctx context.Context, actorID uint, req dto.CreateOAuthClientRequest) (*dto.OAuthClientResponse, error) {
+	client := &models.OAuthClient{ClientID: uuid.New().String()}
+	if err := setClientMetadata(client, req); err != nil {
+		return nil, errors.InternalError(err)
+	}
+
+	var secret string
+	if usesSecret(client.TokenEndpointAuthMethod) {
+		var err error
+		if secret, err = issueClientSecret(client); err != nil {
+			return nil, errors.InternalError(err)
+		}
+	}
+
+	audit := newAuditEvent(models.AuditActionOAuthClientCreated, actorID, 0, clientAuditMetadata(client))
+
+	if err := s.oauthRepository.CreateOAuthClient(ctx, client, audit); err != nil {
+		return nil, errors.InternalError(err)
+	}
+
+	response := mapOAuthClientModelToResponse(client)
+	response.ClientSecret = secret
+	return response, nil
+}
+
+// setClientMetadata sets the registered metadata of client from a validated
+// request.
+func setClientMetadata(client *models.OAuthClient, req dto.CreateOAuthClientRequest) error {
+	client.Name = req.Name
+	client.RedirectURIs = req.RedirectURIs
+	client.Scopes = oauth.ParseScope(oauth.FormatScope(req.Scopes))
+	client.GrantTypes = req.GrantTypesOrDefault()
+	client.TokenEndpointAuthMethod = req.AuthMethodOrDefault()
+	client.PostLogoutRedirectURIs = req.PostLogoutRedirectURIs
+	client.BackchannelLogoutURI = req.BackchannelLogoutURI
+	client.JWKSURI = req.JWKSURI
+	client.JWKS = nil
+	if client.RedirectURIs == nil {
+		client.RedirectURIs = []string{}
+	}
+
+	if req.JWKS != nil {
+		keys, err := json.Marshal(req.JWKS)
+		if err != nil {
+			return err
+		}
+		client.JWKS = keys
+	}
+
+	return nil
+}
+
+// issueClientSecret generates a secret for client and stores its hash.
+func issueClientSecret(client *models.OAuthClient) (string, error) {
+	secret, err := oauth.NewToken()
+	if err != nil {
+		return "", err
+	}
+	client.SecretHash = oauth.HashToken(secret)
+	return secret, nil
+}
+
+// clientAuditMetadata describes the registered metadata of client in audit
+// events.
+func clientAuditMetadata(client *models.OAuthClient) map[string]any {
+	return map[string]any{
+		"client_id":                  client.ClientID,
+		"name":                       client.Name,
+		"redirect_uris":              client.RedirectURIs,
+		"scopes":                     client.Scopes,
+		"grant_types":                client.GrantTypes,
+		"token_endpoint_auth_method": client.TokenEndpointAuthMethod,
+		"jwks_uri":                   client.JWKSURI,
+		"post_logout_redirect_uris":  client.PostLogoutRedirectURIs,
+		"backchannel_logout_uri":     client.BackchannelLogoutURI,
+	}
+}
+
+func (s *oauthService) ListClients(ctx context.Context) ([]dto.OAuthClientResponse, error) {
+	clients, err := s.oauthRepository.ListOAuthClients(ctx)
+	if err != nil {
+		return nil, errors.InternalError(err)
+	}
+
+	responses := make([]dto.OAuthClientResponse, 0, len(clients))
+	for i := range clients {
+		responses = append(responses, *mapOAuthClientModelToResponse(&clients[i]))
+	}
+
+	return responses, nil
+}
+
+// RotateClientSecret replaces the secret of a client that authenticates
+// with one. The previous secret keeps working for
+// OAuthClientSecretGracePeriod so the new one can be rolled out first.
+func (s *oauthService) RotateClientSecret(ctx context.Context, actorID uint, clientID string) (*dto.OAuthClientResponse, error) {
+	client, err := s.getClient(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	if client.DisabledAt != nil {
+		return nil, errors.ConflictError("Client is disabled")
+	}
+	if !usesSecret(client.TokenEndpointAuthMethod) {
+		return nil, errors.BadRequestError("Client does not authenticate with a secret")
+	}
+
+	secret, err := oauth.NewToken()
+	if err != nil {
+		return nil, errors.InternalError(err)
+	}
+
+	now := time.Now()
+	previousExpiresAt := now.Add(s.cfg.OAuthClientSecretGracePeriod)
+	changes := map[string]interface{}{
+		"secret_hash":                oauth.HashToken(secret),
+		"previous_secret_hash":       client.SecretHash,
+		"previous_secret_expires_at": previousExpiresAt,
+		"secret_rotated_at":          now,
+	}
+
+	audit := newAuditEvent(models.AuditActionOAuthClientSecretRotated, actorID, 0, map[string]any{
+		"client_id":                  client.ClientID,
+		"previous_secret_expires_at": previousExpiresAt.Format(time.RFC3339),
+	})
+
+	if err := s.oauthRepository.UpdateOAuthClient(ctx, client.ID, changes, audit); err != nil {
+		return nil, errors.InternalError(err)
+	}
+
+	client.SecretRotatedAt = &now
+	response := mapOAuthClientModelToResponse(client)
+	response.ClientSecret = secret
+	return response, nil
+}
+
+// DisableClient stops a client from signing users in or obtaining tokens,
+// and revokes its refresh tokens. Disabling is permanent; register a new
+// client to take its place.
+func (s *oauthService) DisableClient(ctx context.Context, actorID uint, clientID string) (*dto.OAuthClientResponse, error) {
+	client, err := s.getClient(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	if client.DisabledAt != nil {
+		return nil, errors.ConflictError("Client is already disabled")
+	}
+
+	now := time.Now()
+	audit := newAuditEvent(models.AuditActionOAuthClientDisabled, actorID, 0, map[string]any{
+		"client_id": client.ClientID,
+	})
+
+	if err := s.oauthRepository.DisableOAuthClient(ctx, client.ClientID, now, audit); err != nil {
+		return nil, errors.InternalError(err)
+	}
+
+	client.DisabledAt = &now
+	return mapOAuthClientModelToResponse(client), nil
+}
+
+func (s *oauthService) getClient(ctx context.Context, clientID string) (*models.OAuthClient, error) {
+	client, err := s.oauthRepository.GetOAuthClient(ctx, clientID)
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.NotFoundError("Client not found")
+		}
+		return nil, errors.InternalError(err)
+	}
+	return client, nil
+}
+
+// authenticateClient identifies the client making a token request and
+// checks its credentials with the method it registered. Public clients only
+// name themselves; PKCE binds their codes to the instance that asked for
+// them.
+func (s *oauthService) authenticateClient(ctx context.Context, req dto.ClientAuthentication) (*models.OAuthClient, error) {
+	method, clientID, err := tokenAuthMethod(req)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := s.oauthRepository.GetOAuthClient(ctx, clientID)
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, oauth.InvalidClient("Unknown client")
+		}
+		return nil, oauth.ServerError(err.Error())
+	}
+	if client.DisabledAt != nil {
+		return nil, oauth.InvalidClient("Client is disabled")
+	}
+	if client.TokenEndpointAuthMethod != method {
+		return nil, oauth.InvalidClient("The client must authenticate with " + client.TokenEndpointAuthMethod)
+	}
+
+	switch method {
+	case oauth.AuthMethodClientSecretBasic, oauth.AuthMethodClientSecretPost:
+		if !verifyClientSecret(client, req.ClientSecret, time.Now()) {
+			return nil, oauth.InvalidClient("Invalid client credentials")
+		}
+	case oauth.AuthMethodPrivateKeyJWT:
+		if err := s.verifyClientAssertion(ctx, client, req.ClientAssertion); err != nil {
+			return nil, err
+		}
+	}
+
+	return client, nil
+}
+
+// tokenAuthMethod tells from the credentials of a request how the client
+// authenticates, and which client it claims to be. A request may only use
+// one method.
+func tokenAuthMethod(req dto.ClientAuthentication) (string, string, error) {
+	method := oauth.AuthMethodNone
+	clientID := req.ClientID
+
+	switch {
+	case req.ClientAssertion != "" || req.ClientAssertionType != "":
+		if req.BasicAuth || req.ClientSecret != "" {
+			return "", "", oauth.InvalidRequest("Only one client authentication method may be used")
+		}
+		if req.ClientAssertionType != oauth.ClientAssertionTypeJWTBearer {
+			return "", "", oauth.InvalidClient("Unsupported client_assertion_type")
+		}
+		subject, err := oauth.AssertionClientID(req.ClientAssertion)
+		if err != nil {
+			return "", "", oauth.InvalidClient("Malformed client assertion")
+		}
+		if clientID != "" && clientID != subject {
+			return "", "", oauth.InvalidClient("client_id does not match the client assertion")
+		}
+		method, clientID = oauth.AuthMethodPrivateKeyJWT, subject
+	case req.BasicAuth:
+		method = oauth.AuthMethodClientSecretBasic
+	case req.ClientSecret != "":
+		method = oauth.AuthMethodClientSecretPost
+	}
+
+	if clientID == "" {
+		return "", "", oauth.InvalidClient("client_id is required")
+	}
+
+	return method, clientID, nil
+}
+
+// verifyClientSecret compares secret with the client's current secret, and
+// with the previous one while its grace period lasts.
+func verifyClientSecret(client *models.OAuthClient, secret string, now time.Time) bool {
+	if secret == "" || client.SecretHash == "" {
+		return false
+	}
+
+	hash := []byte(oauth.HashToken(secret))
+	if subtle.ConstantTimeCompare(hash, []byte(client.SecretHash)) == 1 {
+		return true
+	}
+
+	return client.PreviousSecretHash != "" &&
+		client.PreviousSecretExpiresAt != nil && now.Before(*client.PreviousSecretExpiresAt) &&
+		subtle.ConstantTimeCompare(hash, []byte(client.PreviousSecretHash)) == 1
+}
+
+// verifyClientAssertion checks a private_key_jwt assertion against the
+// client's registered keys, or those published at its JWKS URI. Its
+// audience must be the token endpoint or the issuer, and each assertion is
+// accepted only once.
+func (s *oauthService) verifyClientAssertion(ctx context.Context, client *models.OAuthClient, assertion string) error {
+	var keys oauth.JWKS
+	if client.JWKSURI != "" {
+		var err error
+		if keys, err = oauth.FetchJWKS(ctx, s.httpClient, client.JWKSURI); err != nil {
+			return oauth.InvalidClient("The client keys could not be fetched from its JWKS URI")
+		}
+	} else if err := json.Unmarshal(client.JWKS, &keys); err != nil {
+		return oauth.ServerError("Client keys are unreadable")
+	}
+
+	verified, err := oauth.VerifyClientAssertion(assertion, client.ClientID, keys,
+		[]string{s.tokenEndpoint(), s.cfg.PublicBaseURL}, time.Now())
+	if err != nil {
+		return oauth.InvalidClient("Invalid client assertion")
+	}
+
+	recorded, err := s.oauthRepository.RecordClientAssertion(ctx, &models.OAuthClientAssertion{
+		ClientID:  client.ClientID,
+		JTI:       verified.JTI,
+		ExpiresAt: verified.ExpiresAt,
+	})
+	if err != nil {
+		return oauth.ServerError(err.Error())
+	}
+	if !recorded {
+		return oauth.InvalidClient("The client assertion has already been used")
+	}
+
+	return nil
+}
+
+// clientCredentials issues an access token to a confidential client acting
+// on its own behalf (RFC 6749 section 4.4). There is no user, so no refresh
+// token is issued, and sub is the client ID. Endpoints that act on an
+// account do not accept such tokens, since they require a numeric sub.
+func (s *oauthService) clientCredentials(client *models.OAuthClient, req dto.TokenRequest) (*dto.OAuthTokenResponse, error) {
+	scope, err := clientScope(client, req.Scope)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":         s.cfg.PublicBaseURL,
+		"sub":         client.ClientID,
+		claimClientID: client.ClientID,
+		claimTokenID:  uuid.New().String(),
+		"iat":         now.Unix(),
+		"exp":         now.Add(s.cfg.OAuthAccessTokenTTL).Unix(),
+	}
+	if scope != "" {
+		claims[claimScope] = scope
+	}
+
+	accessToken, err := signAccessToken(claims)
+	if err != nil {
+		return nil, oauth.ServerError(err.Error())
+	}
+
+	return &dto.OAuthTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   oauth.TokenTypeBearer,
+		ExpiresIn:   int64(s.cfg.OAuthAccessTokenTTL / time.Second),
+		Scope:       scope,
+	}, nil
+}
+
+// clientScope returns the scope a client is granted when it requests scope
+// at the token or device authorization endpoint. An empty request grants
+// every scope of the client.
+func clientScope(client *models.OAuthClient, scope string) (string, error) {
+	scopes := oauth.ParseScope(scope)
+	if len(scopes) == 0 {
+		scopes = client.Scopes
+	}
+	if !oauth.ScopeSubset(scopes, client.Scopes) {
+		return "", oauth.InvalidScope("The requested scope is not allowed for this client")
+	}
+	return oauth.FormatScope(scopes), nil
+}
+
+func (s *oauthService) tokenEndpoint() string {
+	return s.cfg.PublicBaseURL + "/oauth/token"
+}
+
+func usesSecret(method string) bool {
+	return method == oauth.AuthMethodClientSecretBasic || method == oauth.AuthMethodClientSecretPost
+}
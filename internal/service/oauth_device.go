@@ -0,0 +1,284 @@
+package service
+
+import (
+	"context"
+	stderrors "errors"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/oauth"
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/ssoydabas/auth-service/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// deviceSlowDownStep is how much longer a device must wait between polls
+// each time it polls too fast (RFC 8628 section 3.5).
+const deviceSlowDownStep = 5
+
+// DeviceVerification tells the device verification page what to show. With
+// LoginRequired the user has to sign in first. Otherwise, when UserCode is
+// set, the user is asked to approve ClientName's request for Scopes; when
+// it is empty, to enter the code shown on the device.
+type DeviceVerification struct {
+	LoginRequired bool
+	UserCode      string
+	ClientName    string
+	Scopes        []string
+}
+
+// DeviceAuthorization starts the device authorization grant (RFC 8628) for
+// a client on a device that cannot show a browser. The device shows the
+// user code and verification URI to the user and polls the token endpoint
+// with the device code until the user has decided.
+func (s *oauthService) DeviceAuthorization(ctx context.Context, req dto.DeviceAuthorizationRequest) (*dto.DeviceAuthorizationResponse, error) {
+	client, err := s.authenticateClient(ctx, req.Client())
+	if err != nil {
+		return nil, err
+	}
+	if !client.AllowsGrant(oauth.GrantDeviceCode) {
+		return nil, oauth.UnauthorizedClient("The client may not use the device authorization grant")
+	}
+
+	scope, err := clientScope(client, req.Scope)
+	if err != nil {
+		return nil, err
+	}
+
+	deviceCode, err := oauth.NewToken()
+	if err != nil {
+		return nil, oauth.ServerError(err.Error())
+	}
+	userCode, err := oauth.NewUserCode()
+	if err != nil {
+		return nil, oauth.ServerError(err.Error())
+	}
+
+	interval := int(s.cfg.OAuthDevicePollInterval / time.Second)
+	record := &models.OAuthDeviceCode{
+		DeviceCodeHash: oauth.HashToken(deviceCode),
+		UserCodeHash:   oauth.HashToken(userCode),
+		ClientID:       client.ClientID,
+		Scope:          scope,
+		PollInterval:   interval,
+		ExpiresAt:      time.Now().Add(s.cfg.OAuthDeviceCodeTTL),
+	}
+	if err := s.oauthRepository.CreateDeviceCode(ctx, record); err != nil {
+		return nil, oauth.ServerError(err.Error())
+	}
+
+	verificationURI := s.cfg.PublicBaseURL + "/oauth/device"
+	displayed := oauth.FormatUserCode(userCode)
+
+	return &dto.DeviceAuthorizationResponse{
+		DeviceCode:              deviceCode,
+		UserCode:                displayed,
+		VerificationURI:         verificationURI,
+		VerificationURIComplete: verificationURI + "?" + url.Values{"user_code": {displayed}}.Encode(),
+		ExpiresIn:               int64(s.cfg.OAuthDeviceCodeTTL / time.Second),
+		Interval:                interval,
+	}, nil
+}
+
+// VerifyDeviceCode looks up the request behind a user code for the signed-in
+// user to approve. An unknown, expired or already decided code is returned
+// as an *errors.AppError so the page can ask for the code again.
+func (s *oauthService) VerifyDeviceCode(ctx context.Context, sessionToken, userCode string) (*DeviceVerification, error) {
+	session, err := s.activeSession(ctx, sessionToken)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		return &DeviceVerification{LoginRequired: true}, nil
+	}
+	if userCode == "" {
+		return &DeviceVerification{}, nil
+	}
+
+	code, client, err := s.pendingDeviceCode(ctx, userCode)
+	if err != nil {
+		return nil, err
+	}
+
+	return deviceVerification(code, client, userCode), nil
+}
+
+// DecideDeviceCode records the signed-in user's approval or denial of the
+// request behind a user code. Approval binds the code to the user's account
+// and session, which the tokens issued for it then describe, and adds its
+// scopes to the client's grant.
+func (s *oauthService) DecideDeviceCode(ctx context.Context, sessionToken, userCode string, approve bool) (*DeviceVerification, error) {
+	session, err := s.activeSession(ctx, sessionToken)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		return &DeviceVerification{LoginRequired: true}, nil
+	}
+
+	code, client, err := s.pendingDeviceCode(ctx, userCode)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	metadata := map[string]any{
+		"client_id":  client.ClientID,
+		"scope":      code.Scope,
+		"session_id": session.PublicID,
+	}
+
+	var audit models.AuditEvent
+	var grant *models.OAuthGrant
+	if approve {
+		if grant, err = s.extendedGrant(ctx, session.AccountID, client.ClientID, oauth.ParseScope(code.Scope)); err != nil {
+			return nil, errors.InternalError(err)
+		}
+		code.AccountID = &session.AccountID
+		code.SessionID = session.PublicID
+		code.AuthTime = &session.AuthTime
+		code.AMR = session.AMR
+		code.ApprovedAt = &now
+		audit = newAuditEvent(models.AuditActionOAuthDeviceAuthorized, session.AccountID, session.AccountID, metadata)
+	} else {
+		code.DeniedAt = &now
+		audit = newFailedAuditEvent(models.AuditActionOAuthDeviceAuthorized, session.AccountID, session.AccountID, auditReasonUserDenied, metadata)
+	}
+
+	decided, err := s.oauthRepository.DecideDeviceCode(ctx, code, grant, audit)
+	if err != nil {
+		return nil, errors.InternalError(err)
+	}
+	if !decided {
+		return nil, errors.NotFoundError("This code is not valid or has expired.")
+	}
+
+	return deviceVerification(code, client, userCode), nil
+}
+
+// pendingDeviceCode returns the device code a user entered, and its client,
+// if the user can still decide on it.
+func (s *oauthService) pendingDeviceCode(ctx context.Context, userCode string) (*models.OAuthDeviceCode, *models.OAuthClient, error) {
+	normalized, ok := oauth.NormalizeUserCode(userCode)
+	if !ok {
+		return nil, nil, errors.BadRequestError("This code is not valid. Check the code shown on your device.")
+	}
+
+	code, err := s.oauthRepository.GetDeviceCodeByUserCode(ctx, oauth.HashToken(normalized))
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, errors.NotFoundError("This code is not valid or has expired.")
+		}
+		return nil, nil, errors.InternalError(err)
+	}
+	if !code.IsPending(time.Now()) {
+		return nil, nil, errors.NotFoundError("This code is not valid or has expired.")
+	}
+
+	client, err := s.getClient(ctx, code.ClientID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if client.DisabledAt != nil {
+		return nil, nil, errors.NotFoundError("This code is not valid or has expired.")
+	}
+
+	return code, client, nil
+}
+
+func deviceVerification(code *models.OAuthDeviceCode, client *models.OAuthClient, userCode string) *DeviceVerification {
+	normalized, _ := oauth.NormalizeUserCode(userCode)
+	return &DeviceVerification{
+		UserCode:   oauth.FormatUserCode(normalized),
+		ClientName: client.Name,
+		Scopes:     oauth.ParseScope(code.Scope),
+	}
+}
+
+// exchangeDeviceCode answers a device polling the token endpoint. Until the
+// user has decided it is told authorization_pending, and slow_down when it
+// polls more often than its interval allows, which also lengthens the
+// interval. Tokens are issued once for an approved code.
+func (s *oauthService) exchangeDeviceCode(ctx context.Context, client *models.OAuthClient, req dto.TokenRequest) (*dto.OAuthTokenResponse, error) {
+	if req.DeviceCode == "" {
+		return nil, oauth.InvalidRequest("device_code is required")
+	}
+
+	code, err := s.oauthRepository.GetDeviceCodeByHash(ctx, oauth.HashToken(req.DeviceCode))
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, oauth.InvalidGrant("Invalid device code")
+		}
+		return nil, oauth.ServerError(err.Error())
+	}
+
+	if code.ClientID != client.ClientID {
+		return nil, oauth.InvalidGrant("Device code was issued to another client")
+	}
+	if code.UsedAt != nil {
+		return nil, oauth.InvalidGrant("Device code has already been used")
+	}
+
+	now := time.Now()
+	if !now.Before(code.ExpiresAt) {
+		return nil, oauth.ExpiredToken("Device code has expired")
+	}
+
+	interval := code.PollInterval
+	tooFast := code.LastPolledAt != nil && now.Before(code.LastPolledAt.Add(time.Duration(interval)*time.Second))
+	if tooFast {
+		interval += deviceSlowDownStep
+	}
+	if err := s.oauthRepository.UpdateDeviceCodePoll(ctx, code.ID, now, interval); err != nil {
+		return nil, oauth.ServerError(err.Error())
+	}
+	if tooFast {
+		return nil, oauth.SlowDown("Polling too frequently")
+	}
+
+	if code.DeniedAt != nil {
+		return nil, oauth.AccessDenied("The user denied the request")
+	}
+	if code.ApprovedAt == nil || code.AccountID == nil {
+		return nil, oauth.AuthorizationPending("The user has not approved the request yet")
+	}
+
+	consumed, err := s.oauthRepository.ConsumeDeviceCode(ctx, code.ID, now)
+	if err != nil {
+		return nil, oauth.ServerError(err.Error())
+	}
+	if !consumed {
+		return nil, oauth.InvalidGrant("Device code has already been used")
+	}
+
+	if err := s.checkAccount(ctx, *code.AccountID, now); err != nil {
+		return nil, err
+	}
+
+	g := grant{
+		clientID:  client.ClientID,
+		accountID: *code.AccountID,
+		sessionID: code.SessionID,
+		scope:     code.Scope,
+		amr:       code.AMR,
+	}
+	if code.AuthTime != nil {
+		g.authTime = *code.AuthTime
+	}
+
+	var refreshToken string
+	if client.AllowsGrant(oauth.GrantRefreshToken) {
+		var record *models.OAuthRefreshToken
+		refreshToken, record, err = s.newRefreshToken(g, uuid.New().String(), now)
+		if err != nil {
+			return nil, oauth.ServerError(err.Error())
+		}
+		if err := s.oauthRepository.CreateRefreshToken(ctx, record); err != nil {
+			return nil, oauth.ServerError(err.Error())
+		}
+	}
+
+	return s.tokenResponse(g, refreshToken, now)
+}
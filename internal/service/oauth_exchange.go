@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/oauth"
+	"github.com/ssoydabas/auth-service/models"
+)
+
+// tokenExchange lets a service that received a user's token obtain a token
+// to call another service on the user's behalf (RFC 8693). The new token is
+// restricted to the requested audiences, which the exchange policy must
+// allow for the client, carries at most the scope of the subject token, and
+// never outlives it. Its act claim names the client, or the subject of the
+// actor token, with any earlier actors of the subject token nested inside.
+//
+// Exchanged tokens are signed with the key published at the JWKS endpoint,
+// so the services in their audience can verify them without holding the
+// secret of the service's own tokens, which do not accept them.
+func (s *oauthService) tokenExchange(ctx context.Context, client *models.OAuthClient, req dto.TokenRequest) (*dto.OAuthTokenResponse, error) {
+	if req.SubjectToken == "" {
+		return nil, oauth.InvalidRequest("subject_token is required")
+	}
+	if req.SubjectTokenType != oauth.TokenTypeAccessToken {
+		return nil, oauth.InvalidRequest("subject_token_type must be " + oauth.TokenTypeAccessToken)
+	}
+	if req.ActorToken == "" && req.ActorTokenType != "" {
+		return nil, oauth.InvalidRequest("actor_token_type requires actor_token")
+	}
+	if req.ActorToken != "" && req.ActorTokenType != oauth.TokenTypeAccessToken {
+		return nil, oauth.InvalidRequest("actor_token_type must be " + oauth.TokenTypeAccessToken)
+	}
+	if req.RequestedTokenType != "" && req.RequestedTokenType != oauth.TokenTypeAccessToken {
+		return nil, oauth.InvalidRequest("Only access tokens can be requested")
+	}
+
+	audiences, err := s.exchangeAudiences(client, req)
+	if err != nil {
+		return nil, err
+	}
+
+	subject, err := s.verifyExchangeToken(req.SubjectToken)
+	if err != nil {
+		return nil, oauth.InvalidGrant("Invalid subject token")
+	}
+	sub, ok := subject["sub"].(float64)
+	if !ok {
+		return nil, oauth.InvalidGrant("The subject token must be issued for a user")
+	}
+	if _, ok := tokenActor(subject); ok {
+		return nil, oauth.InvalidGrant("Impersonation tokens cannot be exchanged")
+	}
+	// A token already restricted by an earlier exchange can only be
+	// exchanged again by a service it was issued for.
+	if _, restricted := subject["aud"]; restricted {
+		subjectAudiences, err := subject.GetAudience()
+		if err != nil || !slices.Contains(subjectAudiences, client.ClientID) {
+			return nil, oauth.InvalidGrant("The subject token was not issued for this client")
+		}
+	}
+
+	accountID := uint(sub)
+	now := time.Now()
+	if err := s.checkAccount(ctx, accountID, now); err != nil {
+		return nil, err
+	}
+
+	scope, err := exchangeScope(client, subject, req.Scope)
+	if err != nil {
+		return nil, err
+	}
+
+	act := map[string]interface{}{"sub": client.ClientID}
+	if req.ActorToken != "" {
+		actor, err := s.verifyExchangeToken(req.ActorToken)
+		if err != nil {
+			return nil, oauth.InvalidGrant("Invalid actor token")
+		}
+		if actor[claimClientID] != client.ClientID {
+			return nil, oauth.InvalidGrant("The actor token was not issued to this client")
+		}
+		actorSubject, ok := tokenSubject(actor)
+		if !ok {
+			return nil, oauth.InvalidGrant("Invalid actor token")
+		}
+		act["sub"] = actorSubject
+	}
+	if previous, ok := subject[claimActor].(map[string]interface{}); ok {
+		act[claimActor] = previous
+	}
+
+	expiresAt := now.Add(s.cfg.OAuthAccessTokenTTL)
+	if subjectExpiry, err := subject.GetExpirationTime(); err == nil && subjectExpiry != nil && subjectExpiry.Before(expiresAt) {
+		expiresAt = subjectExpiry.Time
+	}
+
+	claims := jwt.MapClaims{
+		"iss":         s.cfg.PublicBaseURL,
+		"sub":         accountID,
+		"aud":         audiences,
+		claimClientID: client.ClientID,
+		claimTokenID:  uuid.New().String(),
+		claimActor:    act,
+		"iat":         now.Unix(),
+		"exp":         expiresAt.Unix(),
+	}
+	if scope != "" {
+		claims[claimScope] = scope
+	}
+	if sessionID, ok := subject[claimSessionID].(string); ok {
+		claims[claimSessionID] = sessionID
+	}
+
+	accessToken, err := s.signingKey.SignType(claims, typeAccessToken)
+	if err != nil {
+		return nil, oauth.ServerError(err.Error())
+	}
+
+	recordAuditEvent(ctx, s.auditRepository, newAuditEvent(models.AuditActionOAuthTokenExchanged, 0, accountID, map[string]any{
+		"client_id": client.ClientID,
+		"audience":  audiences,
+		"scope":     scope,
+		"actor":     act["sub"],
+	}))
+
+	return &dto.OAuthTokenResponse{
+		AccessToken:     accessToken,
+		TokenType:       oauth.TokenTypeBearer,
+		ExpiresIn:       int64(expiresAt.Sub(now) / time.Second),
+		Scope:           scope,
+		IssuedTokenType: oauth.TokenTypeAccessToken,
+	}, nil
+}
+
+// exchangeAudiences returns the audiences of a token exchange request, from
+// both its audience and resource parameters, after checking each against
+// the exchange policy.
+func (s *oauthService) exchangeAudiences(client *models.OAuthClient, req dto.TokenRequest) ([]string, error) {
+	var audiences []string
+	for _, audience := range append(slices.Clone(req.Audience), req.Resource...) {
+		if audience != "" && !slices.Contains(audiences, audience) {
+			audiences = append(audiences, audience)
+		}
+	}
+	if len(audiences) == 0 {
+		return nil, oauth.InvalidRequest("audience is required")
+	}
+
+	policy, err := oauth.NewExchangePolicy(s.cfg.OAuthTokenExchangePolicy)
+	if err != nil {
+		return nil, oauth.ServerError(err.Error())
+	}
+	for _, audience := range audiences {
+		if !policy.Allows(client.ClientID, audience) {
+			return nil, oauth.InvalidTarget(fmt.Sprintf("The client may not obtain tokens for %q", audience))
+		}
+	}
+
+	return audiences, nil
+}
+
+// exchangeScope returns the scope of an exchanged token: the requested
+// scope, or by default all of what the client may pass on, which is the
+// subject token's scope within the client's scopes. Tokens of the service's
+// own sign-in carry no scope; the client may request any of its scopes for
+// them, but gets none by default.
+func exchangeScope(client *models.OAuthClient, subject jwt.MapClaims, requested string) (string, error) {
+	scopes := oauth.ParseScope(requested)
+
+	subjectScope, scoped := subject[claimScope].(string)
+	if !scoped {
+		if !oauth.ScopeSubset(scopes, client.Scopes) {
+			return "", oauth.InvalidScope("The requested scope is not allowed for this client")
+		}
+		return oauth.FormatScope(scopes), nil
+	}
+
+	var allowed []string
+	for _, scope := range oauth.ParseScope(subjectScope) {
+		if slices.Contains(client.Scopes, scope) {
+			allowed = append(allowed, scope)
+		}
+	}
+
+	if len(scopes) == 0 {
+		return oauth.FormatScope(allowed), nil
+	}
+	if !oauth.ScopeSubset(scopes, allowed) {
+		return "", oauth.InvalidScope("The requested scope exceeds what the subject token allows this client")
+	}
+	return oauth.FormatScope(scopes), nil
+}
+
+// verifyExchangeToken checks a subject or actor token of a token exchange.
+// Besides the tokens signed with the service's secret, it takes the tokens
+// of earlier exchanges, which are signed with the published key.
+func (s *oauthService) verifyExchangeToken(tokenString string) (jwt.MapClaims, error) {
+	claims, _, err := verifyToken(tokenString)
+	if err == nil {
+		return claims, nil
+	}
+
+	claims = jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return s.signingKey.PublicKey(), nil
+	}, jwt.WithValidMethods([]string{oauth.SigningAlgorithm}), jwt.WithExpirationRequired())
+	if err != nil || token.Header["typ"] != typeAccessToken {
+		return nil, oauth.InvalidGrant("Invalid token")
+	}
+	return claims, nil
+}
+
+// tokenSubject returns the sub claim of a token as a string, whether it
+// names an account or a client.
+func tokenSubject(claims jwt.MapClaims) (string, bool) {
+	switch sub := claims["sub"].(type) {
+	case float64:
+		return strconv.FormatUint(uint64(sub), 10), true
+	case string:
+		return sub, sub != ""
+	default:
+		return "", false
+	}
+}
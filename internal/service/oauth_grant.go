@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	stderrors "errors"
+	"slices"
+	"time"
+
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/oauth"
+	"github.com/ssoydabas/auth-service/internal/repository"
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/ssoydabas/auth-service/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// completeAuthorization issues the code of an authorization request the
+// session may complete, or asks for consent when the account has not
+// granted the client every requested scope. prompt=none turns the question
+// into a consent_required error for the client.
+func (s *oauthService) completeAuthorization(ctx context.Context, authz *authorization, session *models.Session) (*AuthorizeResult, error) {
+	scopes, err := s.consentScopes(ctx, authz, session.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	if len(scopes) > 0 {
+		if authz.request.Prompt == oauth.PromptNone {
+			return nil, oauth.ConsentRequired("The user has not allowed the requested scopes").WithRedirect(authz.redirectURI, authz.request.State)
+		}
+		return &AuthorizeResult{ConsentRequired: true, ClientName: authz.client.Name, Scopes: scopes}, nil
+	}
+
+	redirectURL, err := s.issueCode(ctx, authz, session)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthorizeResult{RedirectURL: redirectURL}, nil
+}
+
+// consentScopes returns the requested scopes the account has not granted
+// the client yet, or all of them with prompt=consent.
+func (s *oauthService) consentScopes(ctx context.Context, authz *authorization, accountID uint) ([]string, error) {
+	requested := oauth.ParseScope(authz.scope)
+	if authz.request.Prompt == oauth.PromptConsent {
+		return requested, nil
+	}
+
+	grant, err := s.oauthRepository.GetGrant(ctx, accountID, authz.client.ClientID)
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return requested, nil
+		}
+		return nil, oauth.ServerError(err.Error()).WithRedirect(authz.redirectURI, authz.request.State)
+	}
+
+	var missing []string
+	for _, scope := range requested {
+		if !slices.Contains(grant.Scopes, scope) {
+			missing = append(missing, scope)
+		}
+	}
+	return missing, nil
+}
+
+// Consent records the user's answer on the consent page. Allowing adds the
+// requested scopes to the client's grant and completes the authorization
+// request; denying returns access_denied to the client. Without a session
+// the user has to sign in again.
+func (s *oauthService) Consent(ctx context.Context, req dto.AuthorizeRequest, sessionToken string, approve bool) (*AuthorizeResult, error) {
+	authz, err := s.validateAuthorization(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := s.activeSession(ctx, sessionToken)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		return &AuthorizeResult{LoginRequired: true, ClientName: authz.client.Name}, nil
+	}
+
+	metadata := map[string]any{
+		"client_id":  authz.client.ClientID,
+		"scope":      authz.scope,
+		"session_id": session.PublicID,
+	}
+
+	if !approve {
+		recordAuditEvent(ctx, s.auditRepository, newFailedAuditEvent(models.AuditActionOAuthConsentGranted, session.AccountID, session.AccountID, auditReasonUserDenied, metadata))
+		return nil, oauth.AccessDenied("The user denied the request").WithRedirect(authz.redirectURI, req.State)
+	}
+
+	grant, err := s.extendedGrant(ctx, session.AccountID, authz.client.ClientID, oauth.ParseScope(authz.scope))
+	if err == nil {
+		err = s.oauthRepository.SaveGrant(ctx, grant, newAuditEvent(models.AuditActionOAuthConsentGranted, session.AccountID, session.AccountID, metadata))
+	}
+	if err != nil {
+		return nil, oauth.ServerError(err.Error()).WithRedirect(authz.redirectURI, req.State)
+	}
+
+	redirectURL, err := s.issueCode(ctx, authz, session)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthorizeResult{RedirectURL: redirectURL}, nil
+}
+
+// extendedGrant returns the grant of a client with scopes added to what the
+// account granted it before.
+func (s *oauthService) extendedGrant(ctx context.Context, accountID uint, clientID string, scopes []string) (*models.OAuthGrant, error) {
+	grant := &models.OAuthGrant{AccountID: accountID, ClientID: clientID, Scopes: scopes}
+
+	existing, err := s.oauthRepository.GetGrant(ctx, accountID, clientID)
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return grant, nil
+		}
+		return nil, err
+	}
+
+	grant.Scopes = slices.Clone(existing.Scopes)
+	for _, scope := range scopes {
+		if !slices.Contains(grant.Scopes, scope) {
+			grant.Scopes = append(grant.Scopes, scope)
+		}
+	}
+	return grant, nil
+}
+
+// ListGrants returns the clients an account has granted access to, with
+// the scopes of each.
+func (s *oauthService) ListGrants(ctx context.Context, accountID uint) ([]dto.OAuthGrantResponse, error) {
+	return listGrants(ctx, s.oauthRepository, accountID)
+}
+
+// listGrants maps the grants of an account to responses named after their
+// clients. It is shared with the data export.
+func listGrants(ctx context.Context, oauthRepository repository.OAuthRepository, accountID uint) ([]dto.OAuthGrantResponse, error) {
+	grants, err := oauthRepository.ListGrants(ctx, accountID)
+	if err != nil {
+		return nil, errors.InternalError(err)
+	}
+
+	responses := make([]dto.OAuthGrantResponse, 0, len(grants))
+	for i := range grants {
+		var clientName string
+		client, err := oauthRepository.GetOAuthClient(ctx, grants[i].ClientID)
+		if err != nil && !stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.InternalError(err)
+		}
+		if client != nil {
+			clientName = client.Name
+		}
+		responses = append(responses, *mapOAuthGrantModelToResponse(&grants[i], clientName))
+	}
+
+	return responses, nil
+}
+
+// RevokeGrant withdraws the access an account granted a client. The
+// client's refresh tokens for the account are revoked, and the next
+// authorization request asks for consent again.
+func (s *oauthService) RevokeGrant(ctx context.Context, accountID uint, clientID string) error {
+	audit := newAuditEvent(models.AuditActionOAuthGrantRevoked, accountID, accountID, map[string]any{
+		"client_id": clientID,
+	})
+
+	revoked, err := s.oauthRepository.RevokeGrant(ctx, accountID, clientID, time.Now(), audit)
+	if err != nil {
+		return errors.InternalError(err)
+	}
+	if !revoked {
+		return errors.NotFoundError("Grant not found")
+	}
+
+	return nil
+}
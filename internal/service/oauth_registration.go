@@ -0,0 +1,272 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	stderrors "errors"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/oauth"
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/ssoydabas/auth-service/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// registrableGrantTypes are the grants a client may register for itself.
+// Token exchange stays with administrators, since its policy names the
+// clients allowed to use it.
+var registrableGrantTypes = []string{
+	oauth.GrantAuthorizationCode, oauth.GrantRefreshToken, oauth.GrantClientCredentials, oauth.GrantDeviceCode,
+}
+
+// IssueInitialAccessToken issues a token that lets a platform team register
+// one client through the registration endpoint. It expires after
+// OAuthInitialAccessTokenTTL and is only returned here.
+func (s *oauthService) IssueInitialAccessToken(ctx context.Context, actorID uint, req dto.CreateInitialAccessTokenRequest) (*dto.InitialAccessTokenResponse, error) {
+	token, err := oauth.NewToken()
+	if err != nil {
+		return nil, errors.InternalError(err)
+	}
+
+	initialToken := &models.OAuthInitialAccessToken{
+		TokenHash:   oauth.HashToken(token),
+		Description: req.Description,
+		CreatedBy:   actorID,
+		ExpiresAt:   time.Now().Add(s.cfg.OAuthInitialAccessTokenTTL),
+	}
+
+	audit := newAuditEvent(models.AuditActionOAuthInitialTokenIssued, actorID, 0, map[string]any{
+		"description": req.Description,
+		"expires_at":  initialToken.ExpiresAt.Format(time.RFC3339),
+	})
+
+	if err := s.oauthRepository.CreateInitialAccessToken(ctx, initialToken, audit); err != nil {
+		return nil, errors.InternalError(err)
+	}
+
+	return &dto.InitialAccessTokenResponse{
+		Token:       token,
+		Description: initialToken.Description,
+		ExpiresAt:   initialToken.ExpiresAt.Format(time.RFC3339),
+	}, nil
+}
+
+// RegisterClient registers a client with the metadata it sent (RFC 7591).
+// The request must carry an unused initial access token, which is used up.
+// The response holds the client's secret, if it authenticates with one, and
+// the registration access token that manages the registration; neither can
+// be retrieved later.
+func (s *oauthService) RegisterClient(ctx context.Context, initialAccessToken string, req dto.ClientRegistrationRequest) (*dto.ClientRegistrationResponse, error) {
+	if initialAccessToken == "" {
+		return nil, oauth.InvalidToken("An initial access token is required")
+	}
+
+	now := time.Now()
+	tokenHash := oauth.HashToken(initialAccessToken)
+	initialToken, err := s.oauthRepository.GetInitialAccessToken(ctx, tokenHash)
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, oauth.InvalidToken("Invalid initial access token")
+		}
+		return nil, oauth.ServerError(err.Error())
+	}
+	if initialToken.UsedAt != nil || !now.Before(initialToken.ExpiresAt) {
+		return nil, oauth.InvalidToken("The initial access token has expired or has already been used")
+	}
+
+	clientReq, err := s.validateRegistration(req)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &models.OAuthClient{ClientID: uuid.New().String()}
+	if err := setClientMetadata(client, clientReq); err != nil {
+		return nil, oauth.ServerError(err.Error())
+	}
+
+	var secret string
+	if usesSecret(client.TokenEndpointAuthMethod) {
+		if secret, err = issueClientSecret(client); err != nil {
+			return nil, oauth.ServerError(err.Error())
+		}
+	}
+
+	registrationToken, err := oauth.NewToken()
+	if err != nil {
+		return nil, oauth.ServerError(err.Error())
+	}
+	client.RegistrationTokenHash = oauth.HashToken(registrationToken)
+
+	metadata := clientAuditMetadata(client)
+	metadata["via"] = "registration"
+	audit := newAuditEvent(models.AuditActionOAuthClientCreated, initialToken.CreatedBy, 0, metadata)
+
+	registered, err := s.oauthRepository.RegisterOAuthClient(ctx, client, tokenHash, now, audit)
+	if err != nil {
+		return nil, oauth.ServerError(err.Error())
+	}
+	if !registered {
+		return nil, oauth.InvalidToken("The initial access token has expired or has already been used")
+	}
+
+	response := mapClientRegistrationResponse(client, registrationToken, s.registrationClientURI(client.ClientID))
+	response.ClientSecret = secret
+	return response, nil
+}
+
+// GetClientRegistration returns the registration of a client to the holder
+// of its registration access token (RFC 7592 section 2.1).
+func (s *oauthService) GetClientRegistration(ctx context.Context, clientID, registrationToken string) (*dto.ClientRegistrationResponse, error) {
+	client, err := s.authenticateRegistration(ctx, clientID, registrationToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return mapClientRegistrationResponse(client, registrationToken, s.registrationClientURI(client.ClientID)), nil
+}
+
+// UpdateClientRegistration replaces the metadata of a client with req
+// (RFC 7592 section 2.2); fields left out are cleared or take their
+// defaults. The client keeps its ID and secret. A client that switches to
+// authenticating with a secret gets one, returned only here, and a client
+// that stops doing so loses it.
+func (s *oauthService) UpdateClientRegistration(ctx context.Context, clientID, registrationToken string, req dto.ClientRegistrationRequest) (*dto.ClientRegistrationResponse, error) {
+	client, err := s.authenticateRegistration(ctx, clientID, registrationToken)
+	if err != nil {
+		return nil, err
+	}
+	if req.ClientID != client.ClientID {
+		return nil, oauth.InvalidRequest("client_id must match the registration being updated")
+	}
+	if req.ClientSecret != "" && !verifyClientSecret(client, req.ClientSecret, time.Now()) {
+		return nil, oauth.InvalidRequest("client_secret does not match the client's secret")
+	}
+
+	clientReq, err := s.validateRegistration(req)
+	if err != nil {
+		return nil, err
+	}
+
+	hadSecret := usesSecret(client.TokenEndpointAuthMethod)
+	if err := setClientMetadata(client, clientReq); err != nil {
+		return nil, oauth.ServerError(err.Error())
+	}
+
+	var secret string
+	switch {
+	case usesSecret(client.TokenEndpointAuthMethod) && !hadSecret:
+		if secret, err = issueClientSecret(client); err != nil {
+			return nil, oauth.ServerError(err.Error())
+		}
+	case !usesSecret(client.TokenEndpointAuthMethod):
+		client.SecretHash = ""
+		client.PreviousSecretHash = ""
+		client.PreviousSecretExpiresAt = nil
+	}
+
+	metadata := clientAuditMetadata(client)
+	metadata["via"] = "registration"
+	audit := newAuditEvent(models.AuditActionOAuthClientUpdated, 0, 0, metadata)
+
+	if err := s.oauthRepository.ReplaceOAuthClientMetadata(ctx, client, audit); err != nil {
+		return nil, oauth.ServerError(err.Error())
+	}
+
+	response := mapClientRegistrationResponse(client, registrationToken, s.registrationClientURI(client.ClientID))
+	response.ClientSecret = secret
+	return response, nil
+}
+
+// DeleteClientRegistration deletes a client at the request of the holder of
+// its registration access token (RFC 7592 section 2.3). The client is
+// disabled like an administrator would, which revokes its refresh tokens
+// and its registration access token with it.
+func (s *oauthService) DeleteClientRegistration(ctx context.Context, clientID, registrationToken string) error {
+	client, err := s.authenticateRegistration(ctx, clientID, registrationToken)
+	if err != nil {
+		return err
+	}
+
+	audit := newAuditEvent(models.AuditActionOAuthClientDisabled, 0, 0, map[string]any{
+		"client_id": client.ClientID,
+		"via":       "registration",
+	})
+
+	if err := s.oauthRepository.DisableOAuthClient(ctx, client.ClientID, time.Now(), audit); err != nil {
+		return oauth.ServerError(err.Error())
+	}
+
+	return nil
+}
+
+// authenticateRegistration returns the client whose registration
+// registrationToken manages. Unknown and disabled clients are answered like
+// a wrong token, so the endpoint does not reveal which clients exist.
+func (s *oauthService) authenticateRegistration(ctx context.Context, clientID, registrationToken string) (*models.OAuthClient, error) {
+	if registrationToken == "" {
+		return nil, oauth.InvalidToken("A registration access token is required")
+	}
+
+	client, err := s.oauthRepository.GetOAuthClient(ctx, clientID)
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, oauth.InvalidToken("Invalid registration access token")
+		}
+		return nil, oauth.ServerError(err.Error())
+	}
+
+	if client.DisabledAt != nil || client.RegistrationTokenHash == "" ||
+		subtle.ConstantTimeCompare([]byte(oauth.HashToken(registrationToken)), []byte(client.RegistrationTokenHash)) != 1 {
+		return nil, oauth.InvalidToken("Invalid registration access token")
+	}
+
+	return client, nil
+}
+
+// validateRegistration checks client metadata sent to the registration
+// endpoint and returns the equivalent administrator request. On top of the
+// rules for every client, a registered client may only ask for
+// OAuthRegistrationScopes and the registrable grants.
+func (s *oauthService) validateRegistration(req dto.ClientRegistrationRequest) (dto.CreateOAuthClientRequest, error) {
+	for _, uri := range req.RedirectURIs {
+		if err := oauth.ValidateRedirectURI(uri); err != nil {
+			return dto.CreateOAuthClientRequest{}, oauth.InvalidRedirectURI(err.Error())
+		}
+	}
+
+	clientReq := req.ClientRequest(s.cfg.OAuthRegistrationScopes)
+
+	for _, grantType := range clientReq.GrantTypes {
+		if !slices.Contains(registrableGrantTypes, grantType) {
+			return dto.CreateOAuthClientRequest{}, oauth.InvalidClientMetadata("The grant type " + grantType + " cannot be registered")
+		}
+	}
+	for _, responseType := range req.ResponseTypes {
+		if responseType != oauth.ResponseTypeCode {
+			return dto.CreateOAuthClientRequest{}, oauth.InvalidClientMetadata("Only the code response type is supported")
+		}
+		if !slices.Contains(clientReq.GrantTypes, oauth.GrantAuthorizationCode) {
+			return dto.CreateOAuthClientRequest{}, oauth.InvalidClientMetadata("The code response type requires the authorization_code grant")
+		}
+	}
+	if !oauth.ScopeSubset(clientReq.Scopes, s.cfg.OAuthRegistrationScopes) {
+		return dto.CreateOAuthClientRequest{}, oauth.InvalidClientMetadata("scope may only contain " + oauth.FormatScope(s.cfg.OAuthRegistrationScopes))
+	}
+
+	if err := clientReq.Validate(); err != nil {
+		return dto.CreateOAuthClientRequest{}, oauth.InvalidClientMetadata(err.Error())
+	}
+
+	return clientReq, nil
+}
+
+func (s *oauthService) registrationEndpoint() string {
+	return s.cfg.PublicBaseURL + "/oauth/register"
+}
+
+func (s *oauthService) registrationClientURI(clientID string) string {
+	return s.registrationEndpoint() + "/" + clientID
+}
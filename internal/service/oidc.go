@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/oauth"
+)
+
+// idToken signs the OpenID Connect ID token for g. Unlike access tokens it
+// is meant for the client to verify, so it is signed with the published RSA
+// key and its sub is a string as the specification requires.
+func (s *oauthService) idToken(g grant, accessToken string, now time.Time) (string, error) {
+	claims := jwt.MapClaims{
+		"iss":          s.cfg.PublicBaseURL,
+		"sub":          strconv.FormatUint(uint64(g.accountID), 10),
+		"aud":          g.clientID,
+		"iat":          now.Unix(),
+		"exp":          now.Add(s.cfg.OAuthAccessTokenTTL).Unix(),
+		"at_hash":      oauth.TokenHash(accessToken),
+		claimSessionID: g.sessionID,
+	}
+	if !g.authTime.IsZero() {
+		claims["auth_time"] = g.authTime.Unix()
+	}
+	if len(g.amr) > 0 {
+		claims["amr"] = g.amr
+	}
+	if g.nonce != "" {
+		claims["nonce"] = g.nonce
+	}
+
+	return s.signingKey.Sign(claims)
+}
+
+// UserInfo returns the claims about the owner of an OAuth access token that
+// its scope allows the client to see.
+func (s *oauthService) UserInfo(ctx context.Context, accessToken string) (map[string]any, error) {
+	claims, err := parseAccessToken(accessToken)
+	if err != nil {
+		return nil, oauth.InvalidToken("The access token is invalid or expired")
+	}
+
+	scope, _ := claims[claimScope].(string)
+	if !hasScope(scope, oauth.ScopeOpenID) {
+		return nil, oauth.InsufficientScope("The access token does not have the openid scope")
+	}
+
+	accountID := strconv.FormatFloat(claims["sub"].(float64), 'f', 0, 64)
+	model, err := s.accountRepository.GetAccountByID(ctx, accountID, false)
+	if err != nil || model.IsSuspended(time.Now()) {
+		return nil, oauth.InvalidToken("The access token is invalid or expired")
+	}
+	account := mapAccountModelToResponse(model)
+
+	info := map[string]any{
+		"sub": strconv.FormatUint(uint64(account.ID), 10),
+	}
+
+	if hasScope(scope, oauth.ScopeProfile) {
+		info["given_name"] = account.FirstName
+		info["family_name"] = account.LastName
+		info["name"] = strings.TrimSpace(account.FirstName + " " + account.LastName)
+		if account.PhotoUrl != "" {
+			info["picture"] = account.PhotoUrl
+		}
+		if account.Locale != "" {
+			info["locale"] = account.Locale
+		}
+		if updatedAt, err := time.Parse(time.RFC3339, account.UpdatedAt); err == nil {
+			info["updated_at"] = updatedAt.Unix()
+		}
+	}
+
+	if hasScope(scope, oauth.ScopeEmail) && account.Email != "" {
+		info["email"] = account.Email
+		info["email_verified"] = account.VerificationStatus == "verified"
+	}
+
+	if hasScope(scope, oauth.ScopePhone) && account.Phone != "" {
+		info["phone_number"] = account.Phone
+	}
+
+	return info, nil
+}
+
+// Discovery describes the provider for OpenID Connect Discovery 1.0.
+func (s *oauthService) Discovery() *dto.OpenIDConfigurationResponse {
+	issuer := s.cfg.PublicBaseURL
+
+	return &dto.OpenIDConfigurationResponse{
+		Issuer:                           issuer,
+		AuthorizationEndpoint:            issuer + "/oauth/authorize",
+		TokenEndpoint:                    s.tokenEndpoint(),
+		DeviceAuthorizationEndpoint:      issuer + "/oauth/device_authorization",
+		UserinfoEndpoint:                 issuer + "/oauth/userinfo",
+		EndSessionEndpoint:               issuer + "/oauth/logout",
+		RegistrationEndpoint:             s.registrationEndpoint(),
+		JWKSURI:                          issuer + "/oauth/jwks",
+		ScopesSupported:                  []string{oauth.ScopeOpenID, oauth.ScopeProfile, oauth.ScopeEmail, oauth.ScopePhone},
+		ResponseTypesSupported:           []string{oauth.ResponseTypeCode},
+		ResponseModesSupported:           []string{"query"},
+		GrantTypesSupported:              []string{oauth.GrantAuthorizationCode, oauth.GrantRefreshToken, oauth.GrantClientCredentials, oauth.GrantDeviceCode, oauth.GrantTokenExchange},
+		SubjectTypesSupported:            []string{"public"},
+		IDTokenSigningAlgValuesSupported: []string{oauth.SigningAlgorithm},
+		TokenEndpointAuthMethodsSupported: []string{
+			oauth.AuthMethodNone, oauth.AuthMethodClientSecretBasic, oauth.AuthMethodClientSecretPost, oauth.AuthMethodPrivateKeyJWT,
+		},
+		TokenEndpointAuthSigningAlgValuesSupported: []string{oauth.SigningAlgorithm},
+		CodeChallengeMethodsSupported:              []string{oauth.MethodS256},
+		ClaimsSupported: []string{
+			"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "amr", "sid", "at_hash",
+			"name", "given_name", "family_name", "picture", "locale", "updated_at",
+			"email", "email_verified", "phone_number",
+		},
+		PromptValuesSupported:                      []string{oauth.PromptNone, oauth.PromptLogin},
+		AuthorizationResponseIssParameterSupported: true,
+		BackchannelLogoutSupported:                 true,
+		BackchannelLogoutSessionSupported:          true,
+	}
+}
+
+// JWKS returns the public keys that verify ID tokens.
+func (s *oauthService) JWKS() oauth.JWKS {
+	return s.signingKey.JWKS()
+}
+
+func hasScope(scope, token string) bool {
+	for _, granted := range oauth.ParseScope(scope) {
+		if granted == token {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	stderrors "errors"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/oauth"
+	"github.com/ssoydabas/auth-service/internal/outbox"
+	"github.com/ssoydabas/auth-service/models"
+	"gorm.io/gorm"
+)
+
+// LogoutResult tells the end session endpoint how to answer. When
+// ConfirmationRequired is set the user is asked whether to sign out of the
+// session; otherwise the session has ended and the browser is sent to
+// RedirectURL, or shown a notice when it is empty. ClientName names the
+// client that asked, if it is known.
+type LogoutResult struct {
+	ConfirmationRequired bool
+	ClientName           string
+	RedirectURL          string
+}
+
+// logout is an end session request that passed validation. hint holds the
+// claims of the ID token hint, if one was given.
+type logout struct {
+	client      *models.OAuthClient
+	redirectURL string
+	hint        jwt.MapClaims
+}
+
+// vouchesFor reports whether the ID token hint was issued during session
+// to its account.
+func (l *logout) vouchesFor(session *models.Session) bool {
+	if l.hint == nil {
+		return false
+	}
+	sid, _ := l.hint[claimSessionID].(string)
+	sub, _ := l.hint["sub"].(string)
+	return sid == session.PublicID && sub == strconv.FormatUint(uint64(session.AccountID), 10)
+}
+
+// EndSession handles an RP-initiated logout request (OpenID Connect
+// RP-Initiated Logout 1.0). A request carrying an ID token of the browser's
+// session ends it at once; any other request ends it only once the user
+// has confirmed, so a link on another site cannot sign the user out.
+// Without an active session there is nothing to end and the browser is
+// sent on right away.
+func (s *oauthService) EndSession(ctx context.Context, req dto.EndSessionRequest, sessionToken string, confirmed bool) (*LogoutResult, error) {
+	l, err := s.validateLogout(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &LogoutResult{RedirectURL: l.redirectURL}
+	if l.client != nil {
+		result.ClientName = l.client.Name
+	}
+
+	session, err := s.activeSession(ctx, sessionToken)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		return result, nil
+	}
+
+	if !confirmed && !l.vouchesFor(session) {
+		result.ConfirmationRequired = true
+		return result, nil
+	}
+
+	if err := s.endSession(ctx, session, l.client); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// validateLogout checks an end session request. The client is named by
+// client_id or the audience of the ID token hint, and must be known for the
+// browser to be sent to a post_logout_redirect_uri, which must be
+// registered for it.
+func (s *oauthService) validateLogout(ctx context.Context, req dto.EndSessionRequest) (*logout, error) {
+	l := &logout{}
+	clientID := req.ClientID
+
+	if req.IDTokenHint != "" {
+		hint, err := s.parseIDTokenHint(req.IDTokenHint)
+		if err != nil {
+			return nil, oauth.InvalidRequest("id_token_hint is not a valid ID token")
+		}
+		audiences, err := hint.GetAudience()
+		if err != nil || len(audiences) == 0 {
+			return nil, oauth.InvalidRequest("id_token_hint is not a valid ID token")
+		}
+		if clientID == "" {
+			clientID = audiences[0]
+		} else if !slices.Contains(audiences, clientID) {
+			return nil, oauth.InvalidRequest("id_token_hint was not issued to client_id")
+		}
+		l.hint = hint
+	}
+
+	if clientID == "" {
+		if req.PostLogoutRedirectURI != "" {
+			return nil, oauth.InvalidRequest("post_logout_redirect_uri requires client_id or id_token_hint")
+		}
+		return l, nil
+	}
+
+	client, err := s.oauthRepository.GetOAuthClient(ctx, clientID)
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, oauth.InvalidClient("Unknown client")
+		}
+		return nil, oauth.ServerError(err.Error())
+	}
+	l.client = client
+
+	if req.PostLogoutRedirectURI != "" {
+		if client.DisabledAt != nil {
+			return nil, oauth.InvalidClient("Client is disabled")
+		}
+		if !slices.Contains(client.PostLogoutRedirectURIs, req.PostLogoutRedirectURI) {
+			return nil, oauth.InvalidRequest("post_logout_redirect_uri is not registered for this client")
+		}
+		l.redirectURL = oauth.AppendQuery(req.PostLogoutRedirectURI, url.Values{"state": {req.State}})
+	}
+
+	return l, nil
+}
+
+// parseIDTokenHint verifies an ID token the service issued. Expired tokens
+// are accepted, since the hint only tells which session to end.
+func (s *oauthService) parseIDTokenHint(idToken string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(idToken, claims, func(*jwt.Token) (interface{}, error) {
+		return s.signingKey.PublicKey(), nil
+	}, jwt.WithValidMethods([]string{oauth.SigningAlgorithm}), jwt.WithoutClaimsValidation())
+	if err != nil {
+		return nil, err
+	}
+	if token.Header["typ"] == oauth.LogoutTokenType {
+		return nil, stderrors.New("not an ID token")
+	}
+	if issuer, err := claims.GetIssuer(); err != nil || issuer != s.cfg.PublicBaseURL {
+		return nil, stderrors.New("ID token was issued by another provider")
+	}
+	return claims, nil
+}
+
+// endSession revokes session together with the refresh tokens issued
+// during it, and schedules a logout token for every client that signed in
+// with the session and registered a back-channel logout URI. requester is
+// the client that asked for the logout, if known.
+func (s *oauthService) endSession(ctx context.Context, session *models.Session, requester *models.OAuthClient) error {
+	clients, err := s.oauthRepository.ListSessionClients(ctx, session.PublicID)
+	if err != nil {
+		return oauth.ServerError(err.Error())
+	}
+
+	subject := strconv.FormatUint(uint64(session.AccountID), 10)
+	var messages []models.OutboxMessage
+	notified := []string{}
+	for _, client := range clients {
+		if client.BackchannelLogoutURI == "" {
+			continue
+		}
+		message, err := outbox.NewBackchannelLogoutMessage(outbox.BackchannelLogout{
+			ClientID:  client.ClientID,
+			LogoutURI: client.BackchannelLogoutURI,
+			Subject:   subject,
+			SessionID: session.PublicID,
+		})
+		if err != nil {
+			return oauth.ServerError(err.Error())
+		}
+		messages = append(messages, message)
+		notified = append(notified, client.ClientID)
+	}
+
+	metadata := map[string]any{
+		"session_id":       session.PublicID,
+		"notified_clients": notified,
+	}
+	if requester != nil {
+		metadata["client_id"] = requester.ClientID
+	}
+
+	audit := newAuditEvent(models.AuditActionOAuthLogout, session.AccountID, session.AccountID, metadata)
+	if _, err := s.oauthRepository.RevokeSession(ctx, session.PublicID, time.Now(), audit, messages...); err != nil {
+		return oauth.ServerError(err.Error())
+	}
+
+	return nil
+}
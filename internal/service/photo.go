@@ -0,0 +1,139 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/photo"
+	"github.com/ssoydabas/auth-service/internal/repository"
+	"github.com/ssoydabas/auth-service/internal/storage"
+	"github.com/ssoydabas/auth-service/pkg/config"
+	"github.com/ssoydabas/auth-service/pkg/errors"
+)
+
+// PhotoRoutePrefix is the path under which stored photos are served. A blob
+// key "photos/<key>" is served at PhotoRoutePrefix + "<key>".
+const PhotoRoutePrefix = "/api/v1/photos/"
+
+const photoKeyPrefix = "photos/"
+
+type PhotoService interface {
+	UploadAccountPhoto(ctx context.Context, accountID uint, file io.Reader) (*dto.PhotoResponse, error)
+	GetPhoto(ctx context.Context, key string) (io.ReadCloser, storage.BlobInfo, error)
+	DeletePhoto(ctx context.Context, photoURL string)
+}
+
+type photoService struct {
+	accountRepository repository.AccountRepository
+	store             storage.BlobStore
+	cfg               config.Config
+}
+
+func NewPhotoService(accountRepository repository.AccountRepository, store storage.BlobStore, cfg config.Config) PhotoService {
+	return &photoService{
+		accountRepository: accountRepository,
+		store:             store,
+		cfg:               cfg,
+	}
+}
+
+// UploadAccountPhoto stores every variant of the uploaded photo under a new
+// version directory, points the account's PhotoUrl at the largest one and
+// then removes the previous version.
+func (s *photoService) UploadAccountPhoto(ctx context.Context, accountID uint, file io.Reader) (*dto.PhotoResponse, error) {
+	account, err := s.accountRepository.GetAccountByID(ctx, fmt.Sprintf("%d", accountID), false)
+	if err != nil {
+		return nil, errors.NotFoundError("Account not found")
+	}
+
+	previousURL := account.PhotoUrl
+
+	images, err := photo.Process(file, s.cfg.PhotoMaxBytes, photo.DefaultVariants)
+	switch {
+	case stderrors.Is(err, photo.ErrTooLarge):
+		return nil, errors.PayloadTooLargeError(fmt.Sprintf("Photo cannot exceed %d bytes", s.cfg.PhotoMaxBytes))
+	case stderrors.Is(err, photo.ErrUnsupportedType):
+		return nil, errors.UnsupportedMediaTypeError(err.Error())
+	case stderrors.Is(err, photo.ErrInvalidImage), stderrors.Is(err, photo.ErrTooManyPixels):
+		return nil, errors.BadRequestError(err.Error())
+	case err != nil:
+		return nil, errors.InternalError(err)
+	}
+
+	dir := fmt.Sprintf("%s%d/%s", photoKeyPrefix, accountID, uuid.New().String())
+	response := &dto.PhotoResponse{Variants: make(map[string]string, len(images))}
+
+	for _, image := range images {
+		key := dir + "/" + image.Name + image.Extension
+		if err := s.store.Put(ctx, key, bytes.NewReader(image.Data), int64(len(image.Data)), image.ContentType); err != nil {
+			s.deleteVersion(ctx, dir, image.Extension)
+			return nil, errors.InternalError(err)
+		}
+		response.Variants[image.Name] = s.photoURL(key)
+	}
+
+	response.PhotoUrl = response.Variants[photo.DefaultVariants[0].Name]
+
+	if err := s.accountRepository.UpdateAccountPhoto(ctx, accountID, response.PhotoUrl); err != nil {
+		s.deleteVersion(ctx, dir, images[0].Extension)
+		return nil, errors.InternalError(err)
+	}
+
+	s.DeletePhoto(ctx, previousURL)
+
+	return response, nil
+}
+
+func (s *photoService) GetPhoto(ctx context.Context, key string) (io.ReadCloser, storage.BlobInfo, error) {
+	body, info, err := s.store.Get(ctx, photoKeyPrefix+key)
+	if err != nil {
+		if stderrors.Is(err, storage.ErrNotFound) {
+			return nil, storage.BlobInfo{}, errors.NotFoundError("Photo not found")
+		}
+		return nil, storage.BlobInfo{}, errors.InternalError(err)
+	}
+	return body, info, nil
+}
+
+// DeletePhoto removes every variant of the upload photoURL points at. URLs
+// that were not produced by UploadAccountPhoto are ignored.
+func (s *photoService) DeletePhoto(ctx context.Context, photoURL string) {
+	if key, ok := s.photoKey(photoURL); ok {
+		s.deleteVersion(ctx, path.Dir(key), path.Ext(key))
+	}
+}
+
+func (s *photoService) photoURL(key string) string {
+	return strings.TrimSuffix(s.cfg.PublicBaseURL, "/") + PhotoRoutePrefix + strings.TrimPrefix(key, photoKeyPrefix)
+}
+
+// photoKey returns the blob key of a URL produced by photoURL. URLs set by
+// other means, such as an external avatar, are not ours to delete.
+func (s *photoService) photoKey(url string) (string, bool) {
+	prefix := strings.TrimSuffix(s.cfg.PublicBaseURL, "/") + PhotoRoutePrefix
+	if url == "" || !strings.HasPrefix(url, prefix) {
+		return "", false
+	}
+
+	key := photoKeyPrefix + strings.TrimPrefix(url, prefix)
+	return key, storage.ValidKey(key)
+}
+
+// deleteVersion removes the variants of one upload. Failures only leave
+// unreferenced blobs behind, so they are logged rather than returned.
+func (s *photoService) deleteVersion(ctx context.Context, dir, extension string) {
+	for _, variant := range photo.DefaultVariants {
+		key := dir + "/" + variant.Name + extension
+		if err := s.store.Delete(ctx, key); err != nil {
+			log.Printf("failed to delete photo %s: %v", key, err)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/repository"
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/ssoydabas/auth-service/pkg/config"
+	"github.com/ssoydabas/auth-service/pkg/errors"
+)
+
+type RoleService interface {
+	ListRoles(ctx context.Context) ([]dto.RoleResponse, error)
+	GetRole(ctx context.Context, id string) (*dto.RoleResponse, error)
+	CreateRole(ctx context.Context, actorID uint, req dto.CreateRoleRequest) (*dto.RoleResponse, error)
+	UpdateRole(ctx context.Context, actorID uint, id string, req dto.UpdateRoleRequest) (*dto.RoleResponse, error)
+	DeleteRole(ctx context.Context, actorID uint, id string) error
+
+	ListPermissions(ctx context.Context) ([]dto.PermissionResponse, error)
+	CreatePermission(ctx context.Context, actorID uint, req dto.CreatePermissionRequest) (*dto.PermissionResponse, error)
+	DeletePermission(ctx context.Context, actorID uint, id string) error
+
+	AssignRoles(ctx context.Context, actorID uint, accountID string, req dto.AssignRolesRequest) (*dto.AccountResponse, error)
+	HasPermission(ctx context.Context, account *dto.AccountResponse, permission string) (bool, error)
+	BootstrapAdmin(ctx context.Context) (bool, error)
+}
+
+type roleService struct {
+	roleRepository    repository.RoleRepository
+	accountRepository repository.AccountRepository
+	cfg               config.Config
+}
+
+func NewRoleService(roleRepository repository.RoleRepository, accountRepository repository.AccountRepository, cfg config.Config) RoleService {
+	return &roleService{
+		roleRepository:    roleRepository,
+		accountRepository: accountRepository,
+		cfg:               cfg,
+	}
+}
+
+func (s *roleService) ListRoles(ctx context.Context) ([]dto.RoleResponse, error) {
+	roles, err := s.roleRepository.ListRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]dto.RoleResponse, 0, len(roles))
+	for i := range roles {
+		response = append(response, *mapRoleModelToResponse(&roles[i]))
+	}
+	return response, nil
+}
+
+func (s *roleService) GetRole(ctx context.Context, id string) (*dto.RoleResponse, error) {
+	role, err := s.roleRepository.GetRoleByID(ctx, id)
+	if err != nil {
+		return nil, errors.NotFoundError("Role not found")
+	}
+
+	return mapRoleModelToResponse(role), nil
+}
+
+func (s *roleService) CreateRole(ctx context.Context, actorID uint, req dto.CreateRoleRequest) (*dto.RoleResponse, error) {
+	if _, err := s.roleRepository.GetRoleByName(ctx, req.Name); err == nil {
+		return nil, errors.ConflictError("role name already in use")
+	}
+
+	permissions, err := s.resolvePermissions(ctx, req.Permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	role := models.Role{
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: permissions,
+	}
+
+	audit := newAuditEvent(models.AuditActionRoleCreated, actorID, 0, map[string]any{
+		"name":        req.Name,
+		"permissions": permissionNames(permissions),
+	})
+
+	if err := s.roleRepository.CreateRole(ctx, &role, audit); err != nil {
+		return nil, err
+	}
+
+	return mapRoleModelToResponse(&role), nil
+}
+
+func (s *roleService) UpdateRole(ctx context.Context, actorID uint, id string, req dto.UpdateRoleRequest) (*dto.RoleResponse, error) {
+	role, err := s.roleRepository.GetRoleByID(ctx, id)
+	if err != nil {
+		return nil, errors.NotFoundError("Role not found")
+	}
+
+	if req.Name != role.Name {
+		if role.System {
+			return nil, errors.BadRequestError("Built-in roles cannot be renamed")
+		}
+		if _, err := s.roleRepository.GetRoleByName(ctx, req.Name); err == nil {
+			return nil, errors.ConflictError("role name already in use")
+		}
+	}
+
+	permissions, err := s.resolvePermissions(ctx, req.Permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	audit := newAuditEvent(models.AuditActionRoleUpdated, actorID, role.ID, map[string]any{
+		"from": map[string]any{"name": role.Name, "permissions": permissionNames(role.Permissions)},
+		"to":   map[string]any{"name": req.Name, "permissions": permissionNames(permissions)},
+	})
+
+	role.Name = req.Name
+	role.Description = req.Description
+	role.Permissions = permissions
+
+	if err := s.roleRepository.UpdateRole(ctx, role, audit); err != nil {
+		return nil, err
+	}
+
+	return mapRoleModelToResponse(role), nil
+}
+
+func (s *roleService) DeleteRole(ctx context.Context, actorID uint, id string) error {
+	role, err := s.roleRepository.GetRoleByID(ctx, id)
+	if err != nil {
+		return errors.NotFoundError("Role not found")
+	}
+
+	if role.System {
+		return errors.BadRequestError("Built-in roles cannot be deleted")
+	}
+
+	if role.Name == s.cfg.DefaultRole {
+		return errors.BadRequestError("The default role cannot be deleted")
+	}
+
+	audit := newAuditEvent(models.AuditActionRoleDeleted, actorID, role.ID, map[string]any{
+		"name": role.Name,
+	})
+
+	return s.roleRepository.DeleteRole(ctx, role.ID, audit)
+}
+
+func (s *roleService) ListPermissions(ctx context.Context) ([]dto.PermissionResponse, error) {
+	permissions, err := s.roleRepository.ListPermissions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]dto.PermissionResponse, 0, len(permissions))
+	for i := range permissions {
+		response = append(response, *mapPermissionModelToResponse(&permissions[i]))
+	}
+	return response, nil
+}
+
+func (s *roleService) CreatePermission(ctx context.Context, actorID uint, req dto.CreatePermissionRequest) (*dto.PermissionResponse, error) {
+	existing, err := s.roleRepository.GetPermissionsByNames(ctx, []string{req.Name})
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) > 0 {
+		return nil, errors.ConflictError("permission name already in use")
+	}
+
+	permission := models.Permission{
+		Name:        req.Name,
+		Description: req.Description,
+	}
+
+	audit := newAuditEvent(models.AuditActionPermissionCreated, actorID, 0, map[string]any{
+		"name": req.Name,
+	})
+
+	if err := s.roleRepository.CreatePermission(ctx, &permission, audit); err != nil {
+		return nil, err
+	}
+
+	return mapPermissionModelToResponse(&permission), nil
+}
+
+func (s *roleService) DeletePermission(ctx context.Context, actorID uint, id string) error {
+	permission, err := s.roleRepository.GetPermissionByID(ctx, id)
+	if err != nil {
+		return errors.NotFoundError("Permission not found")
+	}
+
+	if permission.System {
+		return errors.BadRequestError("Built-in permissions cannot be deleted")
+	}
+
+	audit := newAuditEvent(models.AuditActionPermissionDeleted, actorID, permission.ID, map[string]any{
+		"name": permission.Name,
+	})
+
+	return s.roleRepository.DeletePermission(ctx, permission.ID, audit)
+}
+
+func (s *roleService) AssignRoles(ctx context.Context, actorID uint, accountID string, req dto.AssignRolesRequest) (*dto.AccountResponse, error) {
+	account, err := s.accountRepository.GetAccountByID(ctx, accountID, false)
+	if err != nil {
+		return nil, errors.NotFoundError("Account not found")
+	}
+
+	if account.ID == actorID {
+		return nil, errors.BadRequestError("You cannot change your own roles")
+	}
+
+	names := uniqueSorted(req.Roles)
+	roles, err := s.roleRepository.GetRolesByNames(ctx, names)
+	if err != nil {
+		return nil, err
+	}
+	if missing := missingNames(names, roleNames(roles)); len(missing) > 0 {
+		return nil, errors.BadRequestError(fmt.Sprintf("Unknown role: %s", missing[0]))
+	}
+
+	previous := uniqueSorted(roleNames(account.Roles))
+	if slices.Equal(previous, names) {
+		return mapAccountModelToResponse(account), nil
+	}
+
+	audit := newAuditEvent(models.AuditActionRolesChanged, actorID, account.ID, map[string]any{
+		"from": previous,
+		"to":   names,
+	})
+
+	if err := s.roleRepository.ReplaceAccountRoles(ctx, account.ID, roles, audit); err != nil {
+		return nil, err
+	}
+
+	account.Roles = roles
+	return mapAccountModelToResponse(account), nil
+}
+
+func (s *roleService) HasPermission(ctx context.Context, account *dto.AccountResponse, permission string) (bool, error) {
+	return s.roleRepository.AccountHasPermission(ctx, account.ID, permission)
+}
+
+// BootstrapAdmin grants the admin role to the account named by
+// INITIAL_ADMIN_EMAIL while no account holds it yet, so a fresh deployment
+// has someone who can assign roles. It reports whether the role was granted.
+func (s *roleService) BootstrapAdmin(ctx context.Context) (bool, error) {
+	if s.cfg.InitialAdminEmail == "" {
+		return false, nil
+	}
+
+	admins, err := s.roleRepository.CountAccountsWithRole(ctx, models.RoleAdmin)
+	if err != nil {
+		return false, err
+	}
+	if admins > 0 {
+		return false, nil
+	}
+
+	account, err := s.accountRepository.GetAccountByEmail(ctx, s.cfg.InitialAdminEmail)
+	if err != nil {
+		return false, errors.NotFoundError("Initial admin account not found")
+	}
+
+	admin, err := s.roleRepository.GetRoleByName(ctx, models.RoleAdmin)
+	if err != nil {
+		return false, err
+	}
+
+	previous := uniqueSorted(roleNames(account.Roles))
+	roles := append(slices.Clone(account.Roles), *admin)
+	audit := newAuditEvent(models.AuditActionRolesChanged, 0, account.ID, map[string]any{
+		"from":   previous,
+		"to":     uniqueSorted(roleNames(roles)),
+		"reason": "initial_admin",
+	})
+
+	if err := s.roleRepository.ReplaceAccountRoles(ctx, account.ID, roles, audit); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// resolvePermissions loads the named permissions and fails when any of them
+// does not exist.
+func (s *roleService) resolvePermissions(ctx context.Context, names []string) ([]models.Permission, error) {
+	names = uniqueSorted(names)
+	if len(names) == 0 {
+		return []models.Permission{}, nil
+	}
+
+	permissions, err := s.roleRepository.GetPermissionsByNames(ctx, names)
+	if err != nil {
+		return nil, err
+	}
+
+	if missing := missingNames(names, permissionNames(permissions)); len(missing) > 0 {
+		return nil, errors.BadRequestError(fmt.Sprintf("Unknown permission: %s", missing[0]))
+	}
+
+	return permissions, nil
+}
+
+func permissionNames(permissions []models.Permission) []string {
+	names := make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		names = append(names, permission.Name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func uniqueSorted(names []string) []string {
+	sorted := slices.Clone(names)
+	slices.Sort(sorted)
+	return slices.Compact(sorted)
+}
+
+func missingNames(wanted, found []string) []string {
+	var missing []string
+	for _, name := range wanted {
+		if !slices.Contains(found, name) {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
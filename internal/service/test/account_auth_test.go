@@ -57,7 +57,10 @@ func (suite *AccountServiceTestSuite) TestAuthenticateAccount() {
 					Return(&models.AccountPassword{
 						Password: service.HashPassword("correctpassword"),
 					}, nil)
-				suite.mockRepo.On("UpdateLastLoginAt", mock.Anything, uint(1), mock.Anything).
+				suite.mockRepo.On("UpdateLastLoginAt", mock.Anything, uint(1), mock.Anything,
+					mock.MatchedBy(func(event models.AuditEvent) bool {
+						return event.Action == models.AuditActionAccountLogin && *event.TargetID == 1
+					})).
 					Return(nil)
 			},
 			wantToken: true,
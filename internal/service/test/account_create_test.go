@@ -6,6 +6,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/notification"
 	"github.com/ssoydabas/auth-service/models"
 	"github.com/ssoydabas/auth-service/pkg/errors"
 	"github.com/stretchr/testify/mock"
@@ -45,7 +46,7 @@ func (suite *AccountServiceTestSuite) TestCreateAccount() {
 			setupMocks: func() {
 				suite.mockRepo.On("ExistsByEmail", mock.Anything, "test@example.com").Return(false)
 				suite.mockRepo.On("ExistsByPhone", mock.Anything, "+1234567890").Return(false)
-				suite.mockRepo.On("CreateAccount", mock.Anything, mock.Anything).Return(fmt.Errorf("db error"))
+				suite.mockRepo.On("CreateAccount", mock.Anything, mock.Anything, mock.Anything).Return(fmt.Errorf("db error"))
 			},
 			wantErr:       true,
 			expectedError: fmt.Errorf("db error"),
@@ -60,9 +61,12 @@ func (suite *AccountServiceTestSuite) TestCreateAccount() {
 					return acc.Email == "test@example.com" &&
 						acc.Phone == "+1234567890" &&
 						acc.VerificationStatus == "pending" &&
+						len(acc.Roles) == 1 && acc.Roles[0].Name == models.RoleCommon &&
 						acc.AccountTokens.EmailVerificationToken != "" &&
-						acc.AccountTokens.PhoneVerificationToken != ""
-				})).Return(nil)
+						acc.AccountTokens.PhoneVerificationToken != "" &&
+						acc.AccountTokens.EmailVerificationSentAt != nil &&
+						acc.AccountTokens.EmailVerificationSendCount == 1
+				}), mock.Anything).Return(nil)
 			},
 			wantToken: true,
 			wantErr:   false,
@@ -74,7 +78,7 @@ func (suite *AccountServiceTestSuite) TestCreateAccount() {
 			suite.mockRepo.ExpectedCalls = nil
 			tt.setupMocks()
 
-			token, err := suite.service.CreateAccount(context.Background(), tt.req)
+			err := suite.service.CreateAccount(context.Background(), tt.req)
 
 			if tt.wantErr {
 				suite.Error(err)
@@ -84,9 +88,16 @@ func (suite *AccountServiceTestSuite) TestCreateAccount() {
 			} else {
 				suite.NoError(err)
 				if tt.wantToken {
-					suite.NotEmpty(token)
+					call := suite.mockRepo.Calls[len(suite.mockRepo.Calls)-1]
+					suite.Equal("CreateAccount", call.Method)
+					token := call.Arguments.Get(1).(models.Account).AccountTokens.EmailVerificationToken
 					_, err := uuid.Parse(token)
 					suite.NoError(err)
+
+					msg := suite.outboxNotification(call.Arguments.Get(2).([]models.OutboxMessage))
+					suite.Equal(notification.KindEmailVerification, msg.Kind)
+					suite.Equal(tt.req.Email, msg.To)
+					suite.Contains(msg.Text, token)
 				}
 			}
 
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/service"
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/ssoydabas/auth-service/pkg/errors"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+func (suite *AccountServiceTestSuite) TestDeleteAccount() {
+	tests := []struct {
+		name       string
+		req        dto.DeleteAccountRequest
+		setupMocks func()
+		wantStatus int
+	}{
+		{
+			name:       "password is required",
+			req:        dto.DeleteAccountRequest{},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "wrong password",
+			req:  dto.DeleteAccountRequest{Password: "wrongpassword"},
+			setupMocks: func() {
+				suite.mockRepo.On("GetAccountByID", mock.Anything, "2", false).
+					Return(suite.createTestAccount(2, "test@example.com", "+1234567890"), nil)
+				suite.mockRepo.On("GetAccountPasswordByAccountID", mock.Anything, uint(2)).
+					Return(&models.AccountPassword{Password: service.HashPassword("correctpassword")}, nil)
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "account is soft-deleted",
+			req:  dto.DeleteAccountRequest{Password: "correctpassword"},
+			setupMocks: func() {
+				suite.mockRepo.On("GetAccountByID", mock.Anything, "2", false).
+					Return(suite.createTestAccount(2, "test@example.com", "+1234567890"), nil)
+				suite.mockRepo.On("GetAccountPasswordByAccountID", mock.Anything, uint(2)).
+					Return(&models.AccountPassword{Password: service.HashPassword("correctpassword")}, nil)
+				suite.mockRepo.On("SoftDeleteAccount", mock.Anything, uint(2),
+					mock.MatchedBy(func(event models.AuditEvent) bool {
+						return event.Action == models.AuditActionAccountDeleted && *event.ActorID == 2 && *event.TargetID == 2
+					})).Return(nil)
+			},
+			wantStatus: http.StatusAccepted,
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			suite.mockRepo.ExpectedCalls = nil
+			if tt.setupMocks != nil {
+				tt.setupMocks()
+			}
+
+			deletion, err := suite.service.DeleteAccount(context.Background(), 2, tt.req)
+
+			if tt.wantStatus != http.StatusAccepted {
+				suite.Require().Error(err)
+				suite.Equal(tt.wantStatus, err.(*errors.AppError).Code)
+				suite.mockRepo.AssertNotCalled(suite.T(), "SoftDeleteAccount", mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+
+			suite.Require().NoError(err)
+			purgeAfter, err := time.Parse(time.RFC3339, deletion.PurgeAfter)
+			suite.Require().NoError(err)
+			suite.WithinDuration(time.Now().Add(30*24*time.Hour), purgeAfter, time.Minute)
+			suite.mockRepo.AssertExpectations(suite.T())
+		})
+	}
+}
+
+func (suite *AccountServiceTestSuite) TestRestoreAccount() {
+	deletedAccount := func(deletedAt time.Time) *models.Account {
+		account := suite.createTestAccount(2, "test@example.com", "+1234567890")
+		account.DeletedAt = gorm.DeletedAt{Time: deletedAt, Valid: true}
+		return account
+	}
+
+	tests := []struct {
+		name       string
+		setupMocks func()
+		wantStatus int
+	}{
+		{
+			name: "account is not deleted",
+			setupMocks: func() {
+				suite.mockRepo.On("GetDeletedAccountByID", mock.Anything, "2").
+					Return(nil, gorm.ErrRecordNotFound)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "grace period has ended",
+			setupMocks: func() {
+				suite.mockRepo.On("GetDeletedAccountByID", mock.Anything, "2").
+					Return(deletedAccount(time.Now().Add(-31*24*time.Hour)), nil)
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name: "purged while restoring",
+			setupMocks: func() {
+				suite.mockRepo.On("GetDeletedAccountByID", mock.Anything, "2").
+					Return(deletedAccount(time.Now().Add(-time.Hour)), nil)
+				suite.mockRepo.On("RestoreAccount", mock.Anything, uint(2), mock.Anything, mock.Anything).
+					Return(false, nil)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "restored within the grace period",
+			setupMocks: func() {
+				suite.mockRepo.On("GetDeletedAccountByID", mock.Anything, "2").
+					Return(deletedAccount(time.Now().Add(-time.Hour)), nil)
+				suite.mockRepo.On("RestoreAccount", mock.Anything, uint(2),
+					mock.MatchedBy(func(deletedAfter time.Time) bool {
+						return deletedAfter.Sub(time.Now().Add(-30*24*time.Hour)).Abs() < time.Minute
+					}),
+					mock.MatchedBy(func(event models.AuditEvent) bool {
+						return event.Action == models.AuditActionAccountRestored && *event.ActorID == 1
+					})).Return(true, nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			suite.mockRepo.ExpectedCalls = nil
+			tt.setupMocks()
+
+			account, err := suite.service.RestoreAccount(context.Background(), 1, "2")
+
+			if tt.wantStatus != http.StatusOK {
+				suite.Require().Error(err)
+				suite.Equal(tt.wantStatus, err.(*errors.AppError).Code)
+				return
+			}
+
+			suite.Require().NoError(err)
+			suite.Equal(uint(2), account.ID)
+			suite.mockRepo.AssertExpectations(suite.T())
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/repository"
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/ssoydabas/auth-service/pkg/errors"
+	"github.com/stretchr/testify/mock"
+)
+
+func (suite *AccountServiceTestSuite) TestListAccounts() {
+	createdFrom := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		req           dto.ListAccountsRequest
+		matchParams   func(params repository.ListAccountsParams) bool
+		accounts      []models.Account
+		total         int64
+		wantPage      int
+		wantPages     int
+		wantCursor    bool
+		wantErr       bool
+		expectedError error
+	}{
+		{
+			name: "defaults",
+			req:  dto.ListAccountsRequest{},
+			matchParams: func(params repository.ListAccountsParams) bool {
+				return params.SortField == repository.AccountSortCreatedAt && params.SortDesc &&
+					params.Limit == 20 && params.Offset == 0 && params.After == nil
+			},
+			accounts:  []models.Account{*suite.createTestAccount(1, "test@example.com", "+1234567890")},
+			total:     1,
+			wantPage:  1,
+			wantPages: 1,
+		},
+		{
+			name: "filters and page number",
+			req: dto.ListAccountsRequest{
+				Role:               models.RoleTeacher,
+				VerificationStatus: "verified",
+				CreatedFrom:        createdFrom.Format(time.RFC3339),
+				Search:             "  doe ",
+				Sort:               "email",
+				Page:               3,
+				PageSize:           1,
+			},
+			matchParams: func(params repository.ListAccountsParams) bool {
+				return params.Role == models.RoleTeacher && params.VerificationStatus == "verified" &&
+					params.CreatedFrom != nil && params.CreatedFrom.Equal(createdFrom) && params.CreatedTo == nil &&
+					params.Search == "doe" && params.SortField == repository.AccountSortEmail && !params.SortDesc &&
+					params.Limit == 1 && params.Offset == 2
+			},
+			accounts:   []models.Account{*suite.createTestAccount(3, "c@example.com", "+1234567893")},
+			total:      5,
+			wantPage:   3,
+			wantPages:  5,
+			wantCursor: true,
+		},
+		{
+			name:          "malformed cursor",
+			req:           dto.ListAccountsRequest{Cursor: "not a cursor"},
+			wantErr:       true,
+			expectedError: errors.BadRequestError("Invalid cursor"),
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			suite.mockRepo.ExpectedCalls = nil
+			if tt.matchParams != nil {
+				suite.mockRepo.On("ListAccounts", mock.Anything, mock.MatchedBy(tt.matchParams)).
+					Return(tt.accounts, tt.total, nil)
+			}
+
+			response, err := suite.service.ListAccounts(context.Background(), tt.req)
+			if tt.wantErr {
+				suite.Error(err)
+				suite.Equal(tt.expectedError.Error(), err.Error())
+				return
+			}
+
+			suite.Require().NoError(err)
+			suite.Len(response.Data, len(tt.accounts))
+			suite.Equal(tt.wantPage, response.CurrentPage)
+			suite.Equal(tt.wantPages, response.TotalPages)
+			suite.Equal(tt.total, response.TotalItems)
+			suite.Equal(tt.wantCursor, response.NextCursor != "")
+			suite.mockRepo.AssertExpectations(suite.T())
+		})
+	}
+}
+
+func (suite *AccountServiceTestSuite) TestListAccountsCursor() {
+	first := suite.createTestAccount(7, "a@example.com", "+1234567890")
+	first.CreatedAt = time.Date(2025, 3, 4, 5, 6, 7, 123456000, time.UTC)
+
+	suite.mockRepo.On("ListAccounts", mock.Anything, mock.MatchedBy(func(params repository.ListAccountsParams) bool {
+		return params.After == nil
+	})).Return([]models.Account{*first}, int64(2), nil).Once()
+
+	response, err := suite.service.ListAccounts(context.Background(), dto.ListAccountsRequest{PageSize: 1})
+	suite.Require().NoError(err)
+	suite.Require().NotEmpty(response.NextCursor)
+	cursor := response.NextCursor
+
+	suite.mockRepo.On("ListAccounts", mock.Anything, mock.MatchedBy(func(params repository.ListAccountsParams) bool {
+		if params.After == nil || params.After.ID != 7 || params.Offset != 0 {
+			return false
+		}
+		value, ok := params.After.Value.(time.Time)
+		return ok && value.Equal(first.CreatedAt)
+	})).Return([]models.Account{}, int64(2), nil).Once()
+
+	response, err = suite.service.ListAccounts(context.Background(), dto.ListAccountsRequest{PageSize: 1, Cursor: cursor})
+	suite.Require().NoError(err)
+	suite.Empty(response.NextCursor)
+	suite.Equal(0, response.CurrentPage)
+
+	_, err = suite.service.ListAccounts(context.Background(), dto.ListAccountsRequest{PageSize: 1, Cursor: cursor, Sort: "email"})
+	suite.Error(err)
+	suite.Equal(errors.BadRequestError("Cursor was issued for a different sort order").Error(), err.Error())
+
+	suite.mockRepo.AssertExpectations(suite.T())
+}
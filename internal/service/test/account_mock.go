@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/repository"
 	"github.com/ssoydabas/auth-service/models"
 	"github.com/stretchr/testify/mock"
 )
@@ -14,8 +15,8 @@ type MockAccountRepository struct {
 	mock.Mock
 }
 
-func (m *MockAccountRepository) CreateAccount(ctx context.Context, model models.Account) error {
-	args := m.Called(ctx, model)
+func (m *MockAccountRepository) CreateAccount(ctx context.Context, model models.Account, outbox ...models.OutboxMessage) error {
+	args := m.Called(ctx, model, outbox)
 	return args.Error(0)
 }
 
@@ -69,8 +70,8 @@ func (m *MockAccountRepository) ExistsByPhone(ctx context.Context, phone string)
 	return args.Bool(0)
 }
 
-func (m *MockAccountRepository) SetResetPasswordToken(ctx context.Context, accountID uint, token string) error {
-	args := m.Called(ctx, accountID, token)
+func (m *MockAccountRepository) SetResetPasswordToken(ctx context.Context, accountID uint, token string, audit models.AuditEvent, outbox ...models.OutboxMessage) error {
+	args := m.Called(ctx, accountID, token, audit, outbox)
 	return args.Error(0)
 }
 
@@ -82,13 +83,13 @@ func (m *MockAccountRepository) GetAccountByResetPasswordToken(ctx context.Conte
 	return args.Get(0).(*models.Account), args.Error(1)
 }
 
-func (m *MockAccountRepository) UpdateAccountPassword(ctx context.Context, accountID uint, password string) error {
-	args := m.Called(ctx, accountID, password)
+func (m *MockAccountRepository) UpdateAccountPassword(ctx context.Context, accountID uint, password string, audit models.AuditEvent, outbox ...models.OutboxMessage) error {
+	args := m.Called(ctx, accountID, password, audit, outbox)
 	return args.Error(0)
 }
 
-func (m *MockAccountRepository) UpdateAccountVerificationStatus(ctx context.Context, accountID uint, status string) error {
-	args := m.Called(ctx, accountID, status)
+func (m *MockAccountRepository) UpdateAccountVerificationStatus(ctx context.Context, accountID uint, status string, audit models.AuditEvent) error {
+	args := m.Called(ctx, accountID, status, audit)
 	return args.Error(0)
 }
 
@@ -105,7 +106,84 @@ func (m *MockAccountRepository) ClearEmailVerificationToken(ctx context.Context,
 	return args.Error(0)
 }
 
-func (m *MockAccountRepository) UpdateLastLoginAt(ctx context.Context, accountID uint, lastLoginAt *time.Time) error {
-	args := m.Called(ctx, accountID, lastLoginAt)
+func (m *MockAccountRepository) UpdateLastLoginAt(ctx context.Context, accountID uint, lastLoginAt *time.Time, audit models.AuditEvent) error {
+	args := m.Called(ctx, accountID, lastLoginAt, audit)
+	return args.Error(0)
+}
+
+func (m *MockAccountRepository) ReissueEmailVerificationToken(ctx context.Context, accountID uint, previousSentAt *time.Time, tokens models.AccountToken, outbox ...models.OutboxMessage) (bool, error) {
+	args := m.Called(ctx, accountID, previousSentAt, tokens, outbox)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockAccountRepository) ListAccounts(ctx context.Context, params repository.ListAccountsParams) ([]models.Account, int64, error) {
+	args := m.Called(ctx, params)
+	if args.Get(0) == nil {
+		return nil, 0, args.Error(2)
+	}
+	return args.Get(0).([]models.Account), args.Get(1).(int64), args.Error(2)
+}
+
+func (m *MockAccountRepository) UpdateAccountProfile(ctx context.Context, accountID uint, previousUpdatedAt time.Time, changes map[string]interface{}, audit models.AuditEvent) (bool, error) {
+	args := m.Called(ctx, accountID, previousUpdatedAt, changes, audit)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockAccountRepository) UpdateAccountPhoto(ctx context.Context, accountID uint, photoURL string) error {
+	args := m.Called(ctx, accountID, photoURL)
+	return args.Error(0)
+}
+
+func (m *MockAccountRepository) UpdateAccountSuspension(ctx context.Context, accountID uint, changes map[string]interface{}, audit models.AuditEvent) error {
+	args := m.Called(ctx, accountID, changes, audit)
 	return args.Error(0)
 }
+
+func (m *MockAccountRepository) SoftDeleteAccount(ctx context.Context, accountID uint, audit models.AuditEvent) error {
+	args := m.Called(ctx, accountID, audit)
+	return args.Error(0)
+}
+
+func (m *MockAccountRepository) GetDeletedAccountByID(ctx context.Context, id string) (*models.Account, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Account), args.Error(1)
+}
+
+func (m *MockAccountRepository) RestoreAccount(ctx context.Context, accountID uint, deletedAfter time.Time, audit models.AuditEvent) (bool, error) {
+	args := m.Called(ctx, accountID, deletedAfter, audit)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockAccountRepository) ListPurgeableAccounts(ctx context.Context, deletedBefore time.Time, limit int) ([]models.Account, error) {
+	args := m.Called(ctx, deletedBefore, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Account), args.Error(1)
+}
+
+func (m *MockAccountRepository) PurgeAccount(ctx context.Context, accountID uint, deletedBefore time.Time, audit models.AuditEvent) (bool, error) {
+	args := m.Called(ctx, accountID, deletedBefore, audit)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockAccountRepository) CreateImpersonationSession(ctx context.Context, session *models.ImpersonationSession, audit models.AuditEvent) error {
+	args := m.Called(ctx, session, audit)
+	return args.Error(0)
+}
+
+func (m *MockAccountRepository) GetImpersonationSession(ctx context.Context, publicID string) (*models.ImpersonationSession, error) {
+	args := m.Called(ctx, publicID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.ImpersonationSession), args.Error(1)
+}
+
+func (m *MockAccountRepository) EndImpersonationSession(ctx context.Context, publicID string, endedAt time.Time, audit models.AuditEvent) (bool, error) {
+	args := m.Called(ctx, publicID, endedAt, audit)
+	return args.Bool(0), args.Error(1)
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/ssoydabas/auth-service/pkg/errors"
+	"github.com/stretchr/testify/mock"
+)
+
+func etagOf(account *models.Account) string {
+	return fmt.Sprintf(`"%x"`, account.UpdatedAt.UnixMicro())
+}
+
+func (suite *AccountServiceTestSuite) TestUpdateAccount() {
+	newAccount := func() *models.Account {
+		account := suite.createTestAccount(2, "test@example.com", "+1234567890")
+		account.UpdatedAt = time.Date(2025, 5, 6, 7, 8, 9, 123456000, time.UTC)
+		return account
+	}
+	current := etagOf(newAccount())
+
+	tests := []struct {
+		name       string
+		ifMatch    string
+		patch      string
+		setupMocks func()
+		wantStatus int
+		wantFirst  string
+		wantPhoto  string
+		wantNewTag bool
+	}{
+		{
+			name:       "missing If-Match",
+			patch:      `{"first_name":"Jane"}`,
+			wantStatus: http.StatusPreconditionRequired,
+		},
+		{
+			name:       "stale If-Match",
+			ifMatch:    `"1"`,
+			patch:      `{"first_name":"Jane"}`,
+			wantStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:       "weak ETag does not match",
+			ifMatch:    "W/" + current,
+			patch:      `{"first_name":"Jane"}`,
+			wantStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:       "field that cannot be updated",
+			ifMatch:    current,
+			patch:      `{"email":"other@example.com"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "patch is not an object",
+			ifMatch:    current,
+			patch:      `["first_name"]`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "null on a required field fails validation",
+			ifMatch:    current,
+			patch:      `{"first_name":null}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid photo URL",
+			ifMatch:    current,
+			patch:      `{"photo_url":"not a url"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:      "unchanged values are a no-op",
+			ifMatch:   current,
+			patch:     `{"first_name":"John"}`,
+			wantFirst: "John",
+		},
+		{
+			name:    "concurrent update wins",
+			ifMatch: current,
+			patch:   `{"first_name":"Jane"}`,
+			setupMocks: func() {
+				suite.mockRepo.On("UpdateAccountProfile", mock.Anything, uint(2), mock.Anything, mock.Anything, mock.Anything).
+					Return(false, nil)
+			},
+			wantStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:    "successful update",
+			ifMatch: `"0", ` + current,
+			patch:   `{"first_name":"Jane","photo_url":"https://example.com/jane.png","locale":null}`,
+			setupMocks: func() {
+				suite.mockRepo.On("UpdateAccountProfile", mock.Anything, uint(2), newAccount().UpdatedAt,
+					mock.MatchedBy(func(changes map[string]interface{}) bool {
+						_, hasUpdatedAt := changes["updated_at"]
+						_, hasLocale := changes["locale"]
+						return len(changes) == 3 && hasUpdatedAt && !hasLocale &&
+							changes["first_name"] == "Jane" && changes["photo_url"] == "https://example.com/jane.png"
+					}),
+					mock.MatchedBy(func(event models.AuditEvent) bool {
+						return event.Action == models.AuditActionAccountUpdated && *event.ActorID == 1 && *event.TargetID == 2
+					})).Return(true, nil)
+			},
+			wantFirst:  "Jane",
+			wantPhoto:  "https://example.com/jane.png",
+			wantNewTag: true,
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			suite.mockRepo.ExpectedCalls = nil
+			suite.mockRepo.Calls = nil
+			suite.mockRepo.On("GetAccountByID", mock.Anything, "2", false).Return(newAccount(), nil)
+			if tt.setupMocks != nil {
+				tt.setupMocks()
+			}
+
+			account, err := suite.service.UpdateAccount(context.Background(), 1, "2", tt.ifMatch, []byte(tt.patch))
+			if tt.wantStatus != 0 {
+				suite.Require().Error(err)
+				suite.Equal(tt.wantStatus, err.(*errors.AppError).Code)
+				return
+			}
+
+			suite.Require().NoError(err)
+			suite.Equal(tt.wantFirst, account.FirstName)
+			suite.Equal(tt.wantPhoto, account.PhotoUrl)
+			suite.Equal(tt.wantNewTag, account.ETag != current)
+			if !tt.wantNewTag {
+				suite.mockRepo.AssertNotCalled(suite.T(), "UpdateAccountProfile", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			}
+			suite.mockRepo.AssertExpectations(suite.T())
+		})
+	}
+}
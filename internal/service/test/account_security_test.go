@@ -3,10 +3,13 @@ package service
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/notification"
 	"github.com/ssoydabas/auth-service/models"
 	"github.com/ssoydabas/auth-service/pkg/errors"
+	"github.com/ssoydabas/auth-service/pkg/locale"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -25,12 +28,12 @@ func (suite *AccountServiceTestSuite) TestPasswordResetFlow() {
 				mockAccount := suite.createTestAccount(1, "test@example.com", "+1234567890")
 				suite.mockRepo.On("GetAccountByEmailOrPhone", mock.Anything, "test@example.com", "+1234567890").
 					Return(mockAccount, nil)
-				suite.mockRepo.On("SetResetPasswordToken", mock.Anything, uint(1), mock.Anything).
+				suite.mockRepo.On("SetResetPasswordToken", mock.Anything, uint(1), mock.Anything, mock.Anything, mock.Anything).
 					Return(nil)
 
 				suite.mockRepo.On("GetAccountByResetPasswordToken", mock.Anything, mock.Anything).
 					Return(mockAccount, nil)
-				suite.mockRepo.On("UpdateAccountPassword", mock.Anything, uint(1), mock.Anything).
+				suite.mockRepo.On("UpdateAccountPassword", mock.Anything, uint(1), mock.Anything, mock.Anything, mock.Anything).
 					Return(nil)
 			},
 			req: dto.SetResetPasswordTokenRequest{
@@ -53,8 +56,8 @@ func (suite *AccountServiceTestSuite) TestPasswordResetFlow() {
 				Email: "nonexistent@example.com",
 				Phone: "+1234567890",
 			},
-			wantErr:       true,
-			expectedError: errors.NotFoundError("Account not found"),
+			// The answer must not tell whether the account exists.
+			wantErr: false,
 		},
 		{
 			name: "invalid reset token",
@@ -75,7 +78,7 @@ func (suite *AccountServiceTestSuite) TestPasswordResetFlow() {
 				mockAccount := suite.createTestAccount(1, "test@example.com", "+1234567890")
 				suite.mockRepo.On("GetAccountByResetPasswordToken", mock.Anything, "valid-token").
 					Return(mockAccount, nil)
-				suite.mockRepo.On("UpdateAccountPassword", mock.Anything, uint(1), mock.Anything).
+				suite.mockRepo.On("UpdateAccountPassword", mock.Anything, uint(1), mock.Anything, mock.Anything, mock.Anything).
 					Return(errors.InternalError(fmt.Errorf("database error")))
 			},
 			resetReq: dto.ResetPasswordRequest{
@@ -93,7 +96,7 @@ func (suite *AccountServiceTestSuite) TestPasswordResetFlow() {
 			tt.setupMocks()
 
 			if tt.req.Email != "" {
-				token, err := suite.service.SetResetPasswordToken(context.Background(), tt.req)
+				err := suite.service.SetResetPasswordToken(context.Background(), tt.req)
 				if tt.wantErr {
 					suite.Error(err)
 					if tt.expectedError != nil {
@@ -102,7 +105,6 @@ func (suite *AccountServiceTestSuite) TestPasswordResetFlow() {
 					return
 				}
 				suite.NoError(err)
-				suite.NotEmpty(token)
 			}
 
 			if tt.resetReq.Token != "" {
@@ -122,6 +124,67 @@ func (suite *AccountServiceTestSuite) TestPasswordResetFlow() {
 	}
 }
 
+func (suite *AccountServiceTestSuite) TestPasswordResetNotificationLocale() {
+	tests := []struct {
+		name            string
+		accountLocale   string
+		acceptLanguage  string
+		expectedLocale  string
+		expectedSubject string
+	}{
+		{
+			name:            "account locale wins over accept-language",
+			accountLocale:   "tr",
+			acceptLanguage:  "en-US,en;q=0.9",
+			expectedLocale:  "tr",
+			expectedSubject: "Şifrenizi sıfırlayın",
+		},
+		{
+			name:            "falls back to accept-language",
+			acceptLanguage:  "tr-TR,tr;q=0.9,en;q=0.8",
+			expectedLocale:  "tr",
+			expectedSubject: "Şifrenizi sıfırlayın",
+		},
+		{
+			name:            "falls back to default locale",
+			acceptLanguage:  "de-DE",
+			expectedLocale:  "en",
+			expectedSubject: "Reset your password",
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			suite.mockRepo.ExpectedCalls = nil
+			suite.mockRepo.Calls = nil
+
+			mockAccount := suite.createTestAccount(1, "test@example.com", "+1234567890")
+			mockAccount.Locale = tt.accountLocale
+			suite.mockRepo.On("GetAccountByEmailOrPhone", mock.Anything, "test@example.com", "").
+				Return(mockAccount, nil)
+			suite.mockRepo.On("SetResetPasswordToken", mock.Anything, uint(1), mock.Anything, mock.Anything, mock.Anything).
+				Return(nil)
+
+			ctx := locale.WithAcceptLanguage(context.Background(), tt.acceptLanguage)
+			err := suite.service.SetResetPasswordToken(ctx, dto.SetResetPasswordTokenRequest{Email: "test@example.com"})
+			suite.Require().NoError(err)
+
+			call := suite.mockRepo.Calls[len(suite.mockRepo.Calls)-1]
+			suite.Equal("SetResetPasswordToken", call.Method)
+			token := call.Arguments.String(2)
+			suite.NotEmpty(token)
+			msg := suite.outboxNotification(call.Arguments.Get(4).([]models.OutboxMessage))
+			suite.Equal(notification.KindPasswordReset, msg.Kind)
+			suite.Equal(tt.expectedLocale, msg.Metadata["locale"])
+			suite.Equal(tt.expectedSubject, msg.Subject)
+			suite.Contains(msg.Text, token)
+			suite.Contains(msg.HTML, token)
+
+			suite.mockRepo.AssertExpectations(suite.T())
+		})
+	}
+}
+
 func (suite *AccountServiceTestSuite) TestEmailVerificationFlow() {
 	tests := []struct {
 		name          string
@@ -137,7 +200,7 @@ func (suite *AccountServiceTestSuite) TestEmailVerificationFlow() {
 				mockAccount.VerificationStatus = "pending"
 				suite.mockRepo.On("GetAccountByEmailVerificationToken", mock.Anything, "valid-token").
 					Return(mockAccount, nil)
-				suite.mockRepo.On("UpdateAccountVerificationStatus", mock.Anything, uint(1), "verified").
+				suite.mockRepo.On("UpdateAccountVerificationStatus", mock.Anything, uint(1), "verified", mock.Anything).
 					Return(nil)
 				suite.mockRepo.On("ClearEmailVerificationToken", mock.Anything, uint(1)).
 					Return(nil)
@@ -166,7 +229,7 @@ func (suite *AccountServiceTestSuite) TestEmailVerificationFlow() {
 				mockAccount.VerificationStatus = "verified"
 				suite.mockRepo.On("GetAccountByEmailVerificationToken", mock.Anything, "valid-token").
 					Return(mockAccount, nil)
-				suite.mockRepo.On("UpdateAccountVerificationStatus", mock.Anything, uint(1), "verified").
+				suite.mockRepo.On("UpdateAccountVerificationStatus", mock.Anything, uint(1), "verified", mock.Anything).
 					Return(errors.BadRequestError("Account already verified"))
 			},
 			req: dto.VerifyAccountRequest{
@@ -182,7 +245,7 @@ func (suite *AccountServiceTestSuite) TestEmailVerificationFlow() {
 				mockAccount.VerificationStatus = "pending"
 				suite.mockRepo.On("GetAccountByEmailVerificationToken", mock.Anything, "valid-token").
 					Return(mockAccount, nil)
-				suite.mockRepo.On("UpdateAccountVerificationStatus", mock.Anything, uint(1), "verified").
+				suite.mockRepo.On("UpdateAccountVerificationStatus", mock.Anything, uint(1), "verified", mock.Anything).
 					Return(errors.InternalError(fmt.Errorf("database error")))
 			},
 			req: dto.VerifyAccountRequest{
@@ -279,3 +342,114 @@ func (suite *AccountServiceTestSuite) TestGetEmailVerificationToken() {
 		})
 	}
 }
+
+func (suite *AccountServiceTestSuite) TestResendEmailVerification() {
+	ago := func(d time.Duration) *time.Time {
+		t := time.Now().Add(-d)
+		return &t
+	}
+
+	tests := []struct {
+		name          string
+		status        string
+		tokens        models.AccountToken
+		accountExists bool
+		wantReissue   bool
+		wantSendCount int
+	}{
+		{
+			name:          "unknown email",
+			accountExists: false,
+		},
+		{
+			name:          "already verified",
+			status:        "verified",
+			accountExists: true,
+		},
+		{
+			name:   "within cooldown",
+			status: "pending",
+			tokens: models.AccountToken{
+				EmailVerificationSentAt:     ago(10 * time.Second),
+				EmailVerificationWindowFrom: ago(10 * time.Second),
+				EmailVerificationSendCount:  1,
+			},
+			accountExists: true,
+		},
+		{
+			name:   "daily cap reached",
+			status: "pending",
+			tokens: models.AccountToken{
+				EmailVerificationSentAt:     ago(time.Hour),
+				EmailVerificationWindowFrom: ago(5 * time.Hour),
+				EmailVerificationSendCount:  3,
+			},
+			accountExists: true,
+		},
+		{
+			name:   "daily window expired",
+			status: "pending",
+			tokens: models.AccountToken{
+				EmailVerificationSentAt:     ago(2 * time.Hour),
+				EmailVerificationWindowFrom: ago(25 * time.Hour),
+				EmailVerificationSendCount:  3,
+			},
+			accountExists: true,
+			wantReissue:   true,
+			wantSendCount: 1,
+		},
+		{
+			name:   "successful resend",
+			status: "pending",
+			tokens: models.AccountToken{
+				EmailVerificationToken:      "old-token",
+				EmailVerificationSentAt:     ago(2 * time.Minute),
+				EmailVerificationWindowFrom: ago(time.Hour),
+				EmailVerificationSendCount:  1,
+			},
+			accountExists: true,
+			wantReissue:   true,
+			wantSendCount: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			suite.mockRepo.ExpectedCalls = nil
+			suite.mockRepo.Calls = nil
+
+			if !tt.accountExists {
+				suite.mockRepo.On("GetAccountByEmail", mock.Anything, "test@example.com").
+					Return(nil, errors.NotFoundError("Account not found"))
+			} else {
+				mockAccount := suite.createTestAccount(1, "test@example.com", "+1234567890")
+				mockAccount.VerificationStatus = tt.status
+				mockAccount.AccountTokens = tt.tokens
+				suite.mockRepo.On("GetAccountByEmail", mock.Anything, "test@example.com").Return(mockAccount, nil)
+				suite.mockRepo.On("GetAccountByID", mock.Anything, "1", true).Return(mockAccount, nil)
+			}
+
+			if tt.wantReissue {
+				suite.mockRepo.On("ReissueEmailVerificationToken", mock.Anything, uint(1), tt.tokens.EmailVerificationSentAt,
+					mock.MatchedBy(func(tokens models.AccountToken) bool {
+						return tokens.EmailVerificationToken != "" &&
+							tokens.EmailVerificationToken != tt.tokens.EmailVerificationToken &&
+							tokens.EmailVerificationSendCount == tt.wantSendCount
+					}), mock.Anything).Return(true, nil)
+			}
+
+			err := suite.service.ResendEmailVerification(context.Background(), dto.ResendEmailVerificationRequest{Email: "test@example.com"})
+			suite.NoError(err)
+
+			if tt.wantReissue {
+				call := suite.mockRepo.Calls[len(suite.mockRepo.Calls)-1]
+				tokens := call.Arguments.Get(3).(models.AccountToken)
+				msg := suite.outboxNotification(call.Arguments.Get(4).([]models.OutboxMessage))
+				suite.Equal(notification.KindEmailVerification, msg.Kind)
+				suite.Contains(msg.Text, tokens.EmailVerificationToken)
+			}
+
+			suite.mockRepo.AssertExpectations(suite.T())
+		})
+	}
+}
@@ -1,31 +1,53 @@
 package service
 
 import (
+	"encoding/json"
 	"os"
 	"testing"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/notification"
+	internaloutbox "github.com/ssoydabas/auth-service/internal/outbox"
 	"github.com/ssoydabas/auth-service/internal/service"
 	"github.com/ssoydabas/auth-service/models"
+	"github.com/ssoydabas/auth-service/pkg/config"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 	"gorm.io/gorm"
 )
 
 type AccountServiceTestSuite struct {
 	suite.Suite
-	mockRepo *MockAccountRepository
-	service  service.AccountService
+	mockRepo      *MockAccountRepository
+	mockRoleRepo  *MockRoleRepository
+	mockAuditRepo *MockAuditRepository
+	service       service.AccountService
 }
 
 func (suite *AccountServiceTestSuite) SetupTest() {
 	suite.mockRepo = new(MockAccountRepository)
-	suite.service = service.NewAccountService(suite.mockRepo)
+	suite.mockRoleRepo = new(MockRoleRepository)
+	suite.mockAuditRepo = new(MockAuditRepository)
+	suite.mockAuditRepo.On("RecordAuditEvent", mock.Anything, mock.Anything).Return(nil).Maybe()
+	suite.mockRoleRepo.On("GetRoleByName", mock.Anything, models.RoleCommon).
+		Return(&models.Role{Model: gorm.Model{ID: 1}, Name: models.RoleCommon, System: true}, nil).Maybe()
+
+	templates, err := notification.NewRenderer("", "Auth Service")
+	suite.Require().NoError(err)
+	suite.service = service.NewAccountService(suite.mockRepo, suite.mockRoleRepo, suite.mockAuditRepo, templates, config.Config{
+		DefaultRole:                models.RoleCommon,
+		VerificationResendCooldown: time.Minute,
+		VerificationResendDailyCap: 3,
+		AccountDeletionGracePeriod: 30 * 24 * time.Hour,
+		ImpersonationTTL:           15 * time.Minute,
+	})
 }
 
 func (suite *AccountServiceTestSuite) TearDownTest() {
 	suite.mockRepo.ExpectedCalls = nil
+	suite.mockAuditRepo.ExpectedCalls = nil
 }
 
 func (suite *AccountServiceTestSuite) createTestAccount(id uint, email, phone string) *models.Account {
@@ -69,6 +91,17 @@ func (suite *AccountServiceTestSuite) generateTestToken(userID uint) string {
 	return token
 }
 
+// outboxNotification decodes the single notification the service handed to
+// the repository.
+func (suite *AccountServiceTestSuite) outboxNotification(outbox []models.OutboxMessage) notification.Message {
+	suite.Require().Len(outbox, 1)
+	suite.Require().Equal(internaloutbox.TopicNotification, outbox[0].Topic)
+
+	var msg notification.Message
+	suite.Require().NoError(json.Unmarshal([]byte(outbox[0].Payload), &msg))
+	return msg
+}
+
 func TestAccountServiceSuite(t *testing.T) {
 	suite.Run(t, new(AccountServiceTestSuite))
 }
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/service"
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/ssoydabas/auth-service/pkg/errors"
+	"github.com/stretchr/testify/mock"
+)
+
+func (suite *AccountServiceTestSuite) suspendedAccount(until *time.Time) *models.Account {
+	account := suite.createTestAccount(2, "test@example.com", "+1234567890")
+	suspendedAt := time.Now().Add(-time.Hour)
+	actorID := uint(1)
+	account.SuspendedAt = &suspendedAt
+	account.SuspendedUntil = until
+	account.SuspensionReason = "spam"
+	account.SuspendedByID = &actorID
+	return account
+}
+
+func (suite *AccountServiceTestSuite) TestSuspendAccount() {
+	future := time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339)
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+
+	tests := []struct {
+		name       string
+		actorID    uint
+		req        dto.SuspendAccountRequest
+		setupMocks func()
+		wantStatus int
+		wantUntil  bool
+	}{
+		{
+			name:       "reason is required",
+			actorID:    1,
+			req:        dto.SuspendAccountRequest{},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "until must be in the future",
+			actorID:    1,
+			req:        dto.SuspendAccountRequest{Reason: "spam", Until: past},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:    "account not found",
+			actorID: 1,
+			req:     dto.SuspendAccountRequest{Reason: "spam"},
+			setupMocks: func() {
+				suite.mockRepo.On("GetAccountByID", mock.Anything, "2", false).
+					Return(nil, errors.NotFoundError("Account not found"))
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:    "cannot suspend yourself",
+			actorID: 2,
+			req:     dto.SuspendAccountRequest{Reason: "spam"},
+			setupMocks: func() {
+				suite.mockRepo.On("GetAccountByID", mock.Anything, "2", false).
+					Return(suite.createTestAccount(2, "test@example.com", "+1234567890"), nil)
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:    "indefinite suspension",
+			actorID: 1,
+			req:     dto.SuspendAccountRequest{Reason: "spam"},
+			setupMocks: func() {
+				suite.mockRepo.On("GetAccountByID", mock.Anything, "2", false).
+					Return(suite.createTestAccount(2, "test@example.com", "+1234567890"), nil)
+				suite.mockRepo.On("UpdateAccountSuspension", mock.Anything, uint(2),
+					mock.MatchedBy(func(changes map[string]interface{}) bool {
+						return changes["suspension_reason"] == "spam" && changes["suspended_until"] == (*time.Time)(nil)
+					}),
+					mock.MatchedBy(func(event models.AuditEvent) bool {
+						return event.Action == models.AuditActionAccountSuspended && *event.ActorID == 1 && *event.TargetID == 2
+					})).Return(nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:    "suspension with an end time",
+			actorID: 1,
+			req:     dto.SuspendAccountRequest{Reason: "spam", Until: future},
+			setupMocks: func() {
+				suite.mockRepo.On("GetAccountByID", mock.Anything, "2", false).
+					Return(suite.createTestAccount(2, "test@example.com", "+1234567890"), nil)
+				suite.mockRepo.On("UpdateAccountSuspension", mock.Anything, uint(2), mock.Anything, mock.Anything).
+					Return(nil)
+			},
+			wantStatus: http.StatusOK,
+			wantUntil:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			suite.mockRepo.ExpectedCalls = nil
+			if tt.setupMocks != nil {
+				tt.setupMocks()
+			}
+
+			account, err := suite.service.SuspendAccount(context.Background(), tt.actorID, "2", tt.req)
+
+			if tt.wantStatus != http.StatusOK {
+				suite.Require().Error(err)
+				suite.Equal(tt.wantStatus, err.(*errors.AppError).Code)
+				suite.mockRepo.AssertNotCalled(suite.T(), "UpdateAccountSuspension", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+
+			suite.Require().NoError(err)
+			suite.Require().NotNil(account.Suspension)
+			suite.Equal("spam", account.Suspension.Reason)
+			suite.Equal(tt.wantUntil, account.Suspension.Until != nil)
+			suite.mockRepo.AssertExpectations(suite.T())
+		})
+	}
+}
+
+func (suite *AccountServiceTestSuite) TestReinstateAccount() {
+	expired := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name       string
+		account    *models.Account
+		wantStatus int
+	}{
+		{
+			name:       "account is not suspended",
+			account:    suite.createTestAccount(2, "test@example.com", "+1234567890"),
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "suspension has expired",
+			account:    suite.suspendedAccount(&expired),
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "suspended account",
+			account:    suite.suspendedAccount(nil),
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			suite.mockRepo.ExpectedCalls = nil
+			suite.mockRepo.On("GetAccountByID", mock.Anything, "2", false).Return(tt.account, nil)
+			suite.mockRepo.On("UpdateAccountSuspension", mock.Anything, uint(2),
+				mock.MatchedBy(func(changes map[string]interface{}) bool {
+					return changes["suspended_at"] == nil
+				}),
+				mock.MatchedBy(func(event models.AuditEvent) bool {
+					return event.Action == models.AuditActionAccountReinstated
+				})).Return(nil).Maybe()
+
+			account, err := suite.service.ReinstateAccount(context.Background(), 1, "2")
+
+			if tt.wantStatus != http.StatusOK {
+				suite.Require().Error(err)
+				suite.Equal(tt.wantStatus, err.(*errors.AppError).Code)
+				return
+			}
+
+			suite.Require().NoError(err)
+			suite.Nil(account.Suspension)
+			suite.mockRepo.AssertCalled(suite.T(), "UpdateAccountSuspension", mock.Anything, uint(2), mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func (suite *AccountServiceTestSuite) TestSuspendedAccountIsRejected() {
+	until := time.Now().Add(time.Hour)
+
+	suite.Run("authentication", func() {
+		suite.mockRepo.ExpectedCalls = nil
+		suite.mockRepo.On("GetAccountByEmailOrPhone", mock.Anything, "test@example.com", "").
+			Return(suite.suspendedAccount(&until), nil)
+		suite.mockRepo.On("GetAccountPasswordByAccountID", mock.Anything, uint(2)).
+			Return(&models.AccountPassword{Password: service.HashPassword("correctpassword")}, nil)
+
+		_, err := suite.service.AuthenticateAccount(context.Background(), suite.createTestAuthRequest("test@example.com", "correctpassword", ""))
+
+		suite.Require().Error(err)
+		appErr := err.(*errors.AppError)
+		suite.Equal(errors.ErrorTypeAccountSuspended, appErr.Type)
+		suite.Equal(http.StatusForbidden, appErr.Code)
+		suite.mockRepo.AssertNotCalled(suite.T(), "UpdateLastLoginAt", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	suite.Run("existing token", func() {
+		suite.mockRepo.ExpectedCalls = nil
+		suite.mockRepo.On("GetAccountByID", mock.Anything, "2", false).Return(suite.suspendedAccount(nil), nil)
+
+		_, err := suite.service.GetAccountByToken(context.Background(), suite.generateTestToken(2))
+
+		suite.Require().Error(err)
+		suite.Equal(errors.ErrorTypeAccountSuspended, err.(*errors.AppError).Type)
+	})
+
+	suite.Run("expired suspension", func() {
+		suite.mockRepo.ExpectedCalls = nil
+		expired := time.Now().Add(-time.Minute)
+		suite.mockRepo.On("GetAccountByID", mock.Anything, "2", false).Return(suite.suspendedAccount(&expired), nil)
+
+		account, err := suite.service.GetAccountByToken(context.Background(), suite.generateTestToken(2))
+
+		suite.Require().NoError(err)
+		suite.Nil(account.Suspension)
+	})
+}
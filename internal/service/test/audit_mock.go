@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/ssoydabas/auth-service/internal/repository"
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockAuditRepository is a mock implementation of AuditRepository
+type MockAuditRepository struct {
+	mock.Mock
+}
+
+func (m *MockAuditRepository) RecordAuditEvent(ctx context.Context, event models.AuditEvent) error {
+	args := m.Called(ctx, event)
+	return args.Error(0)
+}
+
+func (m *MockAuditRepository) ListAuditEvents(ctx context.Context, params repository.ListAuditEventsParams) ([]models.AuditEvent, int64, error) {
+	args := m.Called(ctx, params)
+	if args.Get(0) == nil {
+		return nil, 0, args.Error(2)
+	}
+	return args.Get(0).([]models.AuditEvent), args.Get(1).(int64), args.Error(2)
+}
+
+func (m *MockAuditRepository) ListAuditEventsForAccount(ctx context.Context, accountID uint, actions ...string) ([]models.AuditEvent, error) {
+	args := m.Called(ctx, accountID, actions)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.AuditEvent), args.Error(1)
+}
+
+func (m *MockAuditRepository) ListAuditChain(ctx context.Context, afterSequence uint64, afterID uint, limit int) ([]models.AuditEvent, error) {
+	args := m.Called(ctx, afterSequence, afterID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.AuditEvent), args.Error(1)
+}
+
+func (m *MockAuditRepository) CountUnchainedAuditEvents(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockAuditRepository) ClaimAuditSinkCursor(ctx context.Context, name, owner string, lease time.Duration) (*models.AuditSinkCursor, error) {
+	args := m.Called(ctx, name, owner, lease)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.AuditSinkCursor), args.Error(1)
+}
+
+func (m *MockAuditRepository) AdvanceAuditSinkCursor(ctx context.Context, name, owner string, sequence uint64, eventID uint) (bool, error) {
+	args := m.Called(ctx, name, owner, sequence, eventID)
+	return args.Bool(0), args.Error(1)
+}
@@ -0,0 +1,222 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/ssoydabas/auth-service/internal/audit"
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/repository"
+	"github.com/ssoydabas/auth-service/internal/service"
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/ssoydabas/auth-service/pkg/errors"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+// recordedAuditEvents returns the events the service wrote on their own,
+// outside a repository change.
+func (suite *AccountServiceTestSuite) recordedAuditEvents() []models.AuditEvent {
+	var events []models.AuditEvent
+	for _, call := range suite.mockAuditRepo.Calls {
+		if call.Method == "RecordAuditEvent" {
+			events = append(events, call.Arguments.Get(1).(models.AuditEvent))
+		}
+	}
+	return events
+}
+
+func (suite *AccountServiceTestSuite) TestRejectedAttemptsAreAudited() {
+	tests := []struct {
+		name     string
+		run      func() error
+		action   string
+		targetID *uint
+		reason   string
+	}{
+		{
+			name: "unknown account",
+			run: func() error {
+				suite.mockRepo.On("GetAccountByEmailOrPhone", mock.Anything, "nobody@example.com", "").
+					Return(nil, errors.NotFoundError("Account not found"))
+				_, err := suite.service.AuthenticateAccount(context.Background(), suite.createTestAuthRequest("nobody@example.com", "password123", ""))
+				return err
+			},
+			action: models.AuditActionAccountLogin,
+			reason: "unknown_account",
+		},
+		{
+			name: "wrong password",
+			run: func() error {
+				suite.mockRepo.On("GetAccountByEmailOrPhone", mock.Anything, "test@example.com", "").
+					Return(suite.createTestAccount(1, "test@example.com", "+1234567890"), nil)
+				suite.mockRepo.On("GetAccountPasswordByAccountID", mock.Anything, uint(1)).
+					Return(&models.AccountPassword{Password: service.HashPassword("correctpassword")}, nil)
+				_, err := suite.service.AuthenticateAccount(context.Background(), suite.createTestAuthRequest("test@example.com", "wrongpassword", ""))
+				return err
+			},
+			action:   models.AuditActionAccountLogin,
+			targetID: uintPtr(1),
+			reason:   "invalid_credentials",
+		},
+		{
+			name: "invalid reset token",
+			run: func() error {
+				suite.mockRepo.On("GetAccountByResetPasswordToken", mock.Anything, "invalid-token").
+					Return(nil, errors.NotFoundError("Account not found"))
+				return suite.service.ResetPassword(context.Background(), dto.ResetPasswordRequest{Token: "invalid-token", Password: "newSecurePassword123!"})
+			},
+			action: models.AuditActionPasswordReset,
+			reason: "invalid_token",
+		},
+		{
+			name: "invalid verification token",
+			run: func() error {
+				suite.mockRepo.On("GetAccountByEmailVerificationToken", mock.Anything, "invalid-token").
+					Return(nil, errors.NotFoundError("Account not found"))
+				return suite.service.VerifyAccountEmail(context.Background(), dto.VerifyAccountRequest{Token: "invalid-token"})
+			},
+			action: models.AuditActionEmailVerified,
+			reason: "invalid_token",
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			suite.mockRepo.ExpectedCalls = nil
+			suite.mockAuditRepo.Calls = nil
+
+			suite.Error(tt.run())
+
+			events := suite.recordedAuditEvents()
+			suite.Require().Len(events, 1)
+			suite.Equal(tt.action, events[0].Action)
+			suite.Equal(models.AuditOutcomeFailure, events[0].Outcome)
+			suite.Equal(tt.targetID, events[0].TargetID)
+
+			var metadata map[string]any
+			suite.Require().NoError(json.Unmarshal([]byte(events[0].Metadata), &metadata))
+			suite.Equal(tt.reason, metadata["reason"])
+		})
+	}
+}
+
+func (suite *AccountServiceTestSuite) TestSuccessfulResetAndVerificationAreAudited() {
+	mockAccount := suite.createTestAccount(1, "test@example.com", "+1234567890")
+	suite.mockRepo.On("GetAccountByResetPasswordToken", mock.Anything, "reset-token").Return(mockAccount, nil)
+	suite.mockRepo.On("UpdateAccountPassword", mock.Anything, uint(1), mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	suite.mockRepo.On("GetAccountByEmailVerificationToken", mock.Anything, "verify-token").Return(mockAccount, nil)
+	suite.mockRepo.On("UpdateAccountVerificationStatus", mock.Anything, uint(1), "verified", mock.Anything).Return(nil)
+	suite.mockRepo.On("ClearEmailVerificationToken", mock.Anything, uint(1)).Return(nil)
+
+	suite.Require().NoError(suite.service.ResetPassword(context.Background(), dto.ResetPasswordRequest{Token: "reset-token", Password: "newSecurePassword123!"}))
+	suite.Require().NoError(suite.service.VerifyAccountEmail(context.Background(), dto.VerifyAccountRequest{Token: "verify-token"}))
+
+	for method, action := range map[string]string{
+		"UpdateAccountPassword":           models.AuditActionPasswordReset,
+		"UpdateAccountVerificationStatus": models.AuditActionEmailVerified,
+	} {
+		for _, call := range suite.mockRepo.Calls {
+			if call.Method != method {
+				continue
+			}
+			event := call.Arguments.Get(3).(models.AuditEvent)
+			suite.Equal(action, event.Action)
+			suite.Equal(models.AuditOutcomeSuccess, event.Outcome)
+			suite.Equal(uint(1), *event.ActorID)
+			suite.Equal(uint(1), *event.TargetID)
+		}
+	}
+
+	suite.Empty(suite.recordedAuditEvents())
+}
+
+func uintPtr(v uint) *uint {
+	return &v
+}
+
+type AuditServiceTestSuite struct {
+	suite.Suite
+	mockAuditRepo *MockAuditRepository
+	service       service.AuditService
+}
+
+func (suite *AuditServiceTestSuite) SetupTest() {
+	suite.mockAuditRepo = new(MockAuditRepository)
+	suite.service = service.NewAuditService(suite.mockAuditRepo)
+}
+
+// chain seals n events in order, as the repository does.
+func (suite *AuditServiceTestSuite) chain(n int) []models.AuditEvent {
+	events := make([]models.AuditEvent, n)
+	var previous *models.AuditEvent
+	for i := range events {
+		events[i] = models.AuditEvent{ID: uint(i + 1), Action: models.AuditActionRoleCreated, Metadata: "{}"}
+		audit.Seal(&events[i], previous)
+		previous = &events[i]
+	}
+	return events
+}
+
+func (suite *AuditServiceTestSuite) TestListAuditEvents() {
+	suite.mockAuditRepo.On("ListAuditEvents", mock.Anything, mock.MatchedBy(func(params repository.ListAuditEventsParams) bool {
+		return params.Outcome == models.AuditOutcomeFailure && params.ActorID == 7 &&
+			params.Limit == 10 && params.Offset == 20 && params.From != nil && params.To == nil
+	})).Return(suite.chain(2), int64(25), nil)
+
+	page, err := suite.service.ListAuditEvents(context.Background(), dto.ListAuditEventsRequest{
+		Outcome:  models.AuditOutcomeFailure,
+		ActorID:  7,
+		From:     "2026-01-01T00:00:00Z",
+		Page:     3,
+		PageSize: 10,
+	})
+	suite.Require().NoError(err)
+	suite.Equal(3, page.CurrentPage)
+	suite.Equal(3, page.TotalPages)
+	suite.Equal(int64(25), page.TotalItems)
+
+	data := page.Data.([]dto.AuditEventResponse)
+	suite.Require().Len(data, 2)
+	suite.Equal(uint64(2), data[1].Sequence)
+	suite.Equal(data[0].Hash, data[1].PrevHash)
+	suite.Nil(data[0].Metadata)
+}
+
+func (suite *AuditServiceTestSuite) TestVerifyAuditChain() {
+	suite.Run("intact chain", func() {
+		suite.SetupTest()
+		events := suite.chain(3)
+		suite.mockAuditRepo.On("CountUnchainedAuditEvents", mock.Anything).Return(int64(0), nil)
+		suite.mockAuditRepo.On("ListAuditChain", mock.Anything, uint64(0), uint(0), mock.Anything).Return(events, nil)
+
+		result, err := suite.service.VerifyAuditChain(context.Background())
+		suite.Require().NoError(err)
+		suite.True(result.Valid)
+		suite.Equal(int64(3), result.Checked)
+		suite.Equal(events[2].Hash, result.LastHash)
+		suite.Nil(result.BrokenAt)
+	})
+
+	suite.Run("tampered event", func() {
+		suite.SetupTest()
+		events := suite.chain(3)
+		events[1].Metadata = `{"name":"changed"}`
+		suite.mockAuditRepo.On("CountUnchainedAuditEvents", mock.Anything).Return(int64(1), nil)
+		suite.mockAuditRepo.On("ListAuditChain", mock.Anything, uint64(0), uint(0), mock.Anything).Return(events, nil)
+
+		result, err := suite.service.VerifyAuditChain(context.Background())
+		suite.Require().NoError(err)
+		suite.False(result.Valid)
+		suite.Equal(int64(1), result.Checked)
+		suite.Equal(int64(1), result.Unchained)
+		suite.Require().NotNil(result.BrokenAt)
+		suite.Equal(uint(2), result.BrokenAt.EventID)
+		suite.Equal(uint64(2), result.BrokenAt.Sequence)
+	})
+}
+
+func TestAuditServiceSuite(t *testing.T) {
+	suite.Run(t, new(AuditServiceTestSuite))
+}
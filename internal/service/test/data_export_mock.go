@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockDataExportRepository is a mock implementation of DataExportRepository
+type MockDataExportRepository struct {
+	mock.Mock
+}
+
+func (m *MockDataExportRepository) CreateDataExport(ctx context.Context, export *models.DataExport, audit models.AuditEvent, outbox ...models.OutboxMessage) error {
+	args := m.Called(ctx, export, audit, outbox)
+	return args.Error(0)
+}
+
+func (m *MockDataExportRepository) GetDataExportByPublicID(ctx context.Context, publicID string) (*models.DataExport, error) {
+	args := m.Called(ctx, publicID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.DataExport), args.Error(1)
+}
+
+func (m *MockDataExportRepository) GetPendingDataExport(ctx context.Context, accountID uint) (*models.DataExport, error) {
+	args := m.Called(ctx, accountID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.DataExport), args.Error(1)
+}
+
+func (m *MockDataExportRepository) MarkDataExportReady(ctx context.Context, id uint, blobKey string, size int64, expiresAt time.Time, outbox ...models.OutboxMessage) error {
+	args := m.Called(ctx, id, blobKey, size, expiresAt, outbox)
+	return args.Error(0)
+}
+
+func (m *MockDataExportRepository) ListExpiredDataExports(ctx context.Context, expiredBefore time.Time, limit int) ([]models.DataExport, error) {
+	args := m.Called(ctx, expiredBefore, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.DataExport), args.Error(1)
+}
+
+func (m *MockDataExportRepository) DeleteDataExport(ctx context.Context, id uint) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
@@ -0,0 +1,232 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ssoydabas/auth-service/internal/notification"
+	"github.com/ssoydabas/auth-service/internal/outbox"
+	"github.com/ssoydabas/auth-service/internal/service"
+	"github.com/ssoydabas/auth-service/internal/storage"
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/ssoydabas/auth-service/pkg/config"
+	"github.com/ssoydabas/auth-service/pkg/errors"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+)
+
+type DataExportServiceTestSuite struct {
+	suite.Suite
+	mockExportRepo  *MockDataExportRepository
+	mockAccountRepo *MockAccountRepository
+	mockAuditRepo   *MockAuditRepository
+	mockOAuthRepo   *MockOAuthRepository
+	store           storage.BlobStore
+	exportService   service.DataExportService
+}
+
+func (suite *DataExportServiceTestSuite) SetupTest() {
+	suite.mockExportRepo = new(MockDataExportRepository)
+	suite.mockAccountRepo = new(MockAccountRepository)
+	suite.mockAuditRepo = new(MockAuditRepository)
+	suite.mockOAuthRepo = new(MockOAuthRepository)
+	suite.store = storage.NewLocalStore(suite.T().TempDir())
+
+	templates, err := notification.NewRenderer("", "Auth Service")
+	suite.Require().NoError(err)
+	suite.exportService = service.NewDataExportService(suite.mockExportRepo, suite.mockAccountRepo, suite.mockAuditRepo, suite.mockOAuthRepo, suite.store, templates, config.Config{
+		PublicBaseURL: testPublicBaseURL,
+		JWTSecret:     "secret",
+		DataExportTTL: time.Hour,
+	})
+}
+
+func (suite *DataExportServiceTestSuite) TestRequestExportReusesPendingExport() {
+	pending := &models.DataExport{Model: gorm.Model{ID: 3}, PublicID: "pending-id", AccountID: 5, Status: models.DataExportStatusPending}
+	suite.mockExportRepo.On("GetPendingDataExport", mock.Anything, uint(5)).Return(pending, nil)
+
+	export, err := suite.exportService.RequestExport(context.Background(), 5)
+
+	suite.Require().NoError(err)
+	suite.Equal("pending-id", export.ID)
+	suite.mockExportRepo.AssertNotCalled(suite.T(), "CreateDataExport", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *DataExportServiceTestSuite) TestRequestExportSchedulesGeneration() {
+	suite.mockExportRepo.On("GetPendingDataExport", mock.Anything, uint(5)).Return(nil, gorm.ErrRecordNotFound)
+
+	var created *models.DataExport
+	var messages []models.OutboxMessage
+	suite.mockExportRepo.On("CreateDataExport", mock.Anything, mock.Anything,
+		mock.MatchedBy(func(event models.AuditEvent) bool {
+			return event.Action == models.AuditActionDataExported && *event.ActorID == 5
+		}), mock.Anything).
+		Run(func(args mock.Arguments) {
+			created = args.Get(1).(*models.DataExport)
+			messages = args.Get(3).([]models.OutboxMessage)
+		}).Return(nil)
+
+	export, err := suite.exportService.RequestExport(context.Background(), 5)
+
+	suite.Require().NoError(err)
+	suite.Equal(models.DataExportStatusPending, export.Status)
+	suite.Equal(created.PublicID, export.ID)
+	suite.Equal(uint(5), created.AccountID)
+	suite.Require().Len(messages, 1)
+	suite.Equal(outbox.TopicDataExport, messages[0].Topic)
+	suite.Contains(messages[0].Payload, created.PublicID)
+}
+
+func (suite *DataExportServiceTestSuite) TestGetExportOfAnotherAccount() {
+	suite.mockExportRepo.On("GetDataExportByPublicID", mock.Anything, "export-id").
+		Return(&models.DataExport{PublicID: "export-id", AccountID: 6, Status: models.DataExportStatusPending}, nil)
+
+	_, err := suite.exportService.GetExport(context.Background(), 5, "export-id")
+
+	suite.Require().Error(err)
+	suite.Equal(http.StatusNotFound, err.(*errors.AppError).Code)
+}
+
+var linkPattern = regexp.MustCompile(`https://\S+/download\?\S+`)
+
+func (suite *DataExportServiceTestSuite) TestGenerateAndDownloadExport() {
+	pending := &models.DataExport{Model: gorm.Model{ID: 3}, PublicID: "export-id", AccountID: 5, Status: models.DataExportStatusPending}
+	suite.mockExportRepo.On("GetDataExportByPublicID", mock.Anything, "export-id").Return(pending, nil).Once()
+
+	account := (&AccountServiceTestSuite{}).createTestAccount(5, "test@example.com", "+1234567890")
+	suite.mockAccountRepo.On("GetAccountByID", mock.Anything, "5", false).Return(account, nil)
+
+	actor := uint(5)
+	login := models.AuditEvent{ID: 1, CreatedAt: time.Now(), Action: models.AuditActionAccountLogin, ActorID: &actor, TargetID: &actor}
+	update := models.AuditEvent{ID: 2, CreatedAt: time.Now(), Action: models.AuditActionAccountUpdated, ActorID: &actor, TargetID: &actor, Metadata: `{"fields":["first_name"]}`}
+	suite.mockAuditRepo.On("ListAuditEventsForAccount", mock.Anything, uint(5), []string{models.AuditActionAccountLogin}).
+		Return([]models.AuditEvent{login}, nil)
+	suite.mockAuditRepo.On("ListAuditEventsForAccount", mock.Anything, uint(5), []string(nil)).
+		Return([]models.AuditEvent{login, update}, nil)
+
+	revokedAt := time.Now()
+	session := models.Session{PublicID: "session-id", AccountID: 5, AuthTime: time.Now(), AMR: []string{"pwd"}, ExpiresAt: time.Now().Add(time.Hour), RevokedAt: &revokedAt}
+	suite.mockOAuthRepo.On("ListSessions", mock.Anything, uint(5)).Return([]models.Session{session}, nil)
+	grant := models.OAuthGrant{AccountID: 5, ClientID: "client-id", Scopes: []string{"openid", "email"}}
+	suite.mockOAuthRepo.On("ListGrants", mock.Anything, uint(5)).Return([]models.OAuthGrant{grant}, nil)
+	suite.mockOAuthRepo.On("GetOAuthClient", mock.Anything, "client-id").Return(&models.OAuthClient{ClientID: "client-id", Name: "Example App"}, nil)
+
+	var blobKey string
+	var expiresAt time.Time
+	var messages []models.OutboxMessage
+	suite.mockExportRepo.On("MarkDataExportReady", mock.Anything, uint(3), mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			blobKey = args.String(2)
+			expiresAt = args.Get(4).(time.Time)
+			messages = args.Get(5).([]models.OutboxMessage)
+		}).Return(nil)
+
+	suite.Require().NoError(suite.exportService.GenerateExport(context.Background(), "export-id"))
+
+	suite.WithinDuration(time.Now().Add(time.Hour), expiresAt, time.Minute)
+	suite.Require().Len(messages, 1)
+	var msg notification.Message
+	suite.Require().NoError(json.Unmarshal([]byte(messages[0].Payload), &msg))
+	suite.Equal(notification.KindDataExport, msg.Kind)
+	suite.Equal("test@example.com", msg.To)
+	link := linkPattern.FindString(msg.Text)
+	suite.Require().NotEmpty(link)
+	suite.True(strings.HasPrefix(link, testPublicBaseURL+service.DataExportRoutePrefix+"export-id/download?"))
+
+	ready := *pending
+	ready.Status = models.DataExportStatusReady
+	ready.BlobKey = blobKey
+	ready.ExpiresAt = &expiresAt
+	suite.mockExportRepo.On("GetDataExportByPublicID", mock.Anything, "export-id").Return(&ready, nil)
+
+	parsed, err := url.Parse(link)
+	suite.Require().NoError(err)
+	query := parsed.Query()
+
+	body, info, err := suite.exportService.OpenDownload(context.Background(), "export-id", query.Get("expires"), query.Get("signature"))
+	suite.Require().NoError(err)
+	defer body.Close()
+	suite.Equal("application/zip", info.ContentType)
+
+	data, err := io.ReadAll(body)
+	suite.Require().NoError(err)
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	suite.Require().NoError(err)
+
+	files := map[string]string{}
+	for _, file := range archive.File {
+		rc, err := file.Open()
+		suite.Require().NoError(err)
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		suite.Require().NoError(err)
+		files[file.Name] = string(content)
+	}
+	suite.Contains(files, "manifest.json")
+	suite.Contains(files["profile.json"], `"email": "test@example.com"`)
+	suite.Contains(files["login_history.json"], models.AuditActionAccountLogin)
+	suite.NotContains(files["login_history.json"], models.AuditActionAccountUpdated)
+	suite.Contains(files["audit_events.json"], `"first_name"`)
+	suite.Contains(files["sessions.json"], `"id": "session-id"`)
+	suite.Contains(files["sessions.json"], `"revoked_at"`)
+	suite.Contains(files["consents.json"], `"client_name": "Example App"`)
+	suite.Contains(files["consents.json"], `"email"`)
+
+	suite.Run("status includes the link", func() {
+		export, err := suite.exportService.GetExport(context.Background(), 5, "export-id")
+		suite.Require().NoError(err)
+		suite.Equal(link, export.DownloadURL)
+	})
+
+	suite.Run("tampered signature", func() {
+		_, _, err := suite.exportService.OpenDownload(context.Background(), "export-id", query.Get("expires"), query.Get("signature")+"x")
+		suite.Require().Error(err)
+		suite.Equal(http.StatusForbidden, err.(*errors.AppError).Code)
+	})
+
+	suite.Run("extended expiry", func() {
+		_, _, err := suite.exportService.OpenDownload(context.Background(), "export-id", "99999999999", query.Get("signature"))
+		suite.Require().Error(err)
+		suite.Equal(http.StatusForbidden, err.(*errors.AppError).Code)
+	})
+
+	suite.Run("generating again is a no-op", func() {
+		suite.NoError(suite.exportService.GenerateExport(context.Background(), "export-id"))
+		suite.mockExportRepo.AssertNumberOfCalls(suite.T(), "MarkDataExportReady", 1)
+	})
+}
+
+func (suite *DataExportServiceTestSuite) TestPurgeExpiredExports() {
+	data := []byte("archive")
+	suite.Require().NoError(suite.store.Put(context.Background(), "exports/5/old.zip", bytes.NewReader(data), int64(len(data)), "application/zip"))
+
+	suite.mockExportRepo.On("ListExpiredDataExports", mock.Anything, mock.Anything, 10).
+		Return([]models.DataExport{
+			{Model: gorm.Model{ID: 1}, BlobKey: "exports/5/old.zip"},
+			{Model: gorm.Model{ID: 2}, BlobKey: "exports/5/already-gone.zip"},
+		}, nil)
+	suite.mockExportRepo.On("DeleteDataExport", mock.Anything, uint(1)).Return(nil)
+	suite.mockExportRepo.On("DeleteDataExport", mock.Anything, uint(2)).Return(nil)
+
+	n, err := suite.exportService.PurgeExpiredExports(context.Background(), 10)
+
+	suite.Require().NoError(err)
+	suite.Equal(2, n)
+	_, _, err = suite.store.Get(context.Background(), "exports/5/old.zip")
+	suite.ErrorIs(err, storage.ErrNotFound)
+	suite.mockExportRepo.AssertExpectations(suite.T())
+}
+
+func TestDataExportServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(DataExportServiceTestSuite))
+}
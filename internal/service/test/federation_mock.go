@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockFederationRepository is a mock implementation of FederationRepository
+type MockFederationRepository struct {
+	mock.Mock
+}
+
+func (m *MockFederationRepository) CreateFederatedLogin(ctx context.Context, login *models.FederatedLogin) error {
+	args := m.Called(ctx, login)
+	return args.Error(0)
+}
+
+func (m *MockFederationRepository) ConsumeFederatedLogin(ctx context.Context, stateHash string) (*models.FederatedLogin, error) {
+	args := m.Called(ctx, stateHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.FederatedLogin), args.Error(1)
+}
+
+func (m *MockFederationRepository) GetIdentity(ctx context.Context, provider, subject string) (*models.Identity, error) {
+	args := m.Called(ctx, provider, subject)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Identity), args.Error(1)
+}
+
+func (m *MockFederationRepository) RecordIdentityLogin(ctx context.Context, identity *models.Identity, loginAt time.Time, audit models.AuditEvent) error {
+	args := m.Called(ctx, identity, loginAt, audit)
+	return args.Error(0)
+}
+
+func (m *MockFederationRepository) LinkIdentity(ctx context.Context, identity *models.Identity, loginAt time.Time, audits ...models.AuditEvent) error {
+	args := m.Called(ctx, identity, loginAt, audits)
+	return args.Error(0)
+}
+
+func (m *MockFederationRepository) CreateFederatedAccount(ctx context.Context, account *models.Account, identity *models.Identity, audits ...models.AuditEvent) error {
+	args := m.Called(ctx, account, identity, audits)
+	return args.Error(0)
+}
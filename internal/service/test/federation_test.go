@@ -0,0 +1,533 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/federation"
+	"github.com/ssoydabas/auth-service/internal/oauth"
+	"github.com/ssoydabas/auth-service/internal/saml"
+	"github.com/ssoydabas/auth-service/internal/saml/samltest"
+	"github.com/ssoydabas/auth-service/internal/service"
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/ssoydabas/auth-service/pkg/config"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+)
+
+const (
+	testProviderID     = "lincoln-high"
+	testSAMLProviderID = "acme"
+	testProviderClient = "auth-service"
+	testProviderSecret = "upstream-secret"
+	testReturnTo       = "/oauth/authorize?client_id=app&response_type=code"
+)
+
+// mockIdP is an OpenID Connect provider on httptest. It issues a code for
+// every authorization request it is shown and answers the token request for
+// it with an ID token carrying claims.
+type mockIdP struct {
+	server *httptest.Server
+	key    *oauth.SigningKey
+	claims jwt.MapClaims
+	nonce  string
+	codes  map[string]url.Values
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	key, err := oauth.GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idp := &mockIdP{key: key, codes: map[string]url.Values{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, idp.key.JWKS())
+	})
+	mux.HandleFunc("/token", idp.token)
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+
+	return idp
+}
+
+// authorize plays the user signing in at the provider: it returns the code
+// the provider would send back for the authorization request at redirectURL.
+func (idp *mockIdP) authorize(redirectURL string) string {
+	u, _ := url.Parse(redirectURL)
+	code := "code-" + u.Query().Get("state")[:8]
+	idp.codes[code] = u.Query()
+	return code
+}
+
+func (idp *mockIdP) token(w http.ResponseWriter, r *http.Request) {
+	clientID, secret, _ := r.BasicAuth()
+	request, ok := idp.codes[r.FormValue("code")]
+	if !ok || clientID != testProviderClient || secret != testProviderSecret ||
+		r.FormValue("redirect_uri") != request.Get("redirect_uri") ||
+		!oauth.VerifyPKCE(r.FormValue("code_verifier"), request.Get("code_challenge"), request.Get("code_challenge_method")) {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	delete(idp.codes, r.FormValue("code"))
+
+	nonce := request.Get("nonce")
+	if idp.nonce != "" {
+		nonce = idp.nonce
+	}
+	claims := jwt.MapClaims{
+		"iss":   idp.server.URL,
+		"aud":   testProviderClient,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(5 * time.Minute).Unix(),
+		"nonce": nonce,
+	}
+	for name, value := range idp.claims {
+		claims[name] = value
+	}
+	idToken, _ := idp.key.Sign(claims)
+
+	writeJSON(w, map[string]string{"access_token": "upstream-access-token", "token_type": "Bearer", "id_token": idToken})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+type FederationTestSuite struct {
+	suite.Suite
+	idp                *mockIdP
+	samlIdP            *samltest.IdentityProvider
+	mockFederationRepo *MockFederationRepository
+	mockOAuthRepo      *MockOAuthRepository
+	mockAccountRepo    *MockAccountRepository
+	mockRoleRepo       *MockRoleRepository
+	mockAuditRepo      *MockAuditRepository
+	federationService  service.FederationService
+	login              *models.FederatedLogin
+}
+
+func (suite *FederationTestSuite) SetupSuite() {
+	idp, err := samltest.NewIdentityProvider("https://idp.acme.example.com/saml", "https://idp.acme.example.com/saml/sso")
+	suite.Require().NoError(err)
+	suite.samlIdP = idp
+}
+
+func (suite *FederationTestSuite) SetupTest() {
+	suite.idp = newMockIdP(suite.T())
+	suite.idp.claims = jwt.MapClaims{
+		"sub":            "teacher-42",
+		"email":          "ada@lincoln.example.edu",
+		"email_verified": true,
+		"given_name":     "Ada",
+		"family_name":    "Lovelace",
+		"picture":        "https://idp.example.edu/photos/42.png",
+		"locale":         "en-GB",
+		"phone_number":   "not a phone number",
+	}
+
+	suite.mockFederationRepo = new(MockFederationRepository)
+	suite.mockOAuthRepo = new(MockOAuthRepository)
+	suite.mockAccountRepo = new(MockAccountRepository)
+	suite.mockRoleRepo = new(MockRoleRepository)
+	suite.mockAuditRepo = new(MockAuditRepository)
+	suite.mockAuditRepo.On("RecordAuditEvent", mock.Anything, mock.Anything).Return(nil).Maybe()
+	suite.mockOAuthRepo.On("CreateSession", mock.Anything, mock.AnythingOfType("*models.Session")).Return(nil).Maybe()
+	suite.mockRoleRepo.On("GetRoleByName", mock.Anything, "common").Return(&models.Role{Model: gorm.Model{ID: 3}, Name: "common"}, nil).Maybe()
+	suite.mockFederationRepo.On("CreateFederatedLogin", mock.Anything, mock.AnythingOfType("*models.FederatedLogin")).
+		Run(func(args mock.Arguments) { suite.login = args.Get(1).(*models.FederatedLogin) }).
+		Return(nil).Maybe()
+
+	cfg := config.Config{
+		PublicBaseURL:      testPublicBaseURL,
+		DefaultRole:        "common",
+		SessionTTL:         time.Hour,
+		FederationLoginTTL: 10 * time.Minute,
+	}
+	connectors := []federation.Connector{{
+		ID:           testProviderID,
+		Name:         "Lincoln High",
+		Issuer:       suite.idp.server.URL,
+		ClientID:     testProviderClient,
+		ClientSecret: testProviderSecret,
+		Scopes:       []string{"openid", "profile", "email"},
+	}, {
+		ID:           testSAMLProviderID,
+		Name:         "Acme",
+		Protocol:     federation.ProtocolSAML,
+		SAMLMetadata: string(suite.samlIdP.Metadata()),
+		TrustEmail:   true,
+	}}
+	suite.federationService = service.NewFederationService(suite.mockFederationRepo, suite.mockOAuthRepo, suite.mockAccountRepo,
+		suite.mockRoleRepo, suite.mockAuditRepo, connectors, cfg)
+}
+
+// signIn starts a sign-in, has the provider approve it and returns the
+// callback request together with the state the browser kept.
+func (suite *FederationTestSuite) signIn() (dto.FederationCallbackRequest, string) {
+	start, err := suite.federationService.StartLogin(context.Background(), testProviderID, testReturnTo)
+	suite.Require().NoError(err)
+
+	suite.mockFederationRepo.On("ConsumeFederatedLogin", mock.Anything, oauth.HashToken(start.State)).Return(suite.login, nil).Once()
+
+	return dto.FederationCallbackRequest{
+		Code:   suite.idp.authorize(start.RedirectURL),
+		State:  start.State,
+		Issuer: suite.idp.server.URL,
+	}, start.State
+}
+
+func (suite *FederationTestSuite) TestStartLogin() {
+	start, err := suite.federationService.StartLogin(context.Background(), testProviderID, testReturnTo)
+	suite.Require().NoError(err)
+
+	redirect, err := url.Parse(start.RedirectURL)
+	suite.Require().NoError(err)
+	query := redirect.Query()
+	suite.Equal(suite.idp.server.URL+"/authorize", redirect.Scheme+"://"+redirect.Host+redirect.Path)
+	suite.Equal("code", query.Get("response_type"))
+	suite.Equal(testProviderClient, query.Get("client_id"))
+	suite.Equal(testPublicBaseURL+"/oauth/federated/"+testProviderID+"/callback", query.Get("redirect_uri"))
+	suite.Equal("openid profile email", query.Get("scope"))
+	suite.Equal(start.State, query.Get("state"))
+	suite.Equal(oauth.MethodS256, query.Get("code_challenge_method"))
+
+	suite.Require().NotNil(suite.login)
+	suite.Equal(oauth.HashToken(start.State), suite.login.StateHash)
+	suite.Equal(oauth.S256Challenge(suite.login.CodeVerifier), query.Get("code_challenge"))
+	suite.Equal(suite.login.Nonce, query.Get("nonce"))
+	suite.Equal(testReturnTo, suite.login.ReturnTo)
+	suite.Equal(testProviderID, suite.login.Provider)
+	suite.WithinDuration(time.Now().Add(10*time.Minute), start.ExpiresAt, time.Minute)
+}
+
+func (suite *FederationTestSuite) TestStartLoginRejectsBadRequests() {
+	for _, returnTo := range []string{"", "https://evil.example.com/", "//evil.example.com/oauth/", "/api/v1/accounts", "/oauth/\\evil"} {
+		_, err := suite.federationService.StartLogin(context.Background(), testProviderID, returnTo)
+		suite.Require().Error(err, returnTo)
+		suite.Equal(oauth.ErrorInvalidRequest, err.(*oauth.Error).Code)
+	}
+
+	_, err := suite.federationService.StartLogin(context.Background(), "unknown", testReturnTo)
+	suite.Require().Error(err)
+	suite.Equal(oauth.ErrorInvalidRequest, err.(*oauth.Error).Code)
+	suite.mockFederationRepo.AssertNotCalled(suite.T(), "CreateFederatedLogin", mock.Anything, mock.Anything)
+}
+
+func (suite *FederationTestSuite) TestFinishLoginWithLinkedIdentity() {
+	req, state := suite.signIn()
+	suite.mockFederationRepo.On("GetIdentity", mock.Anything, testProviderID, "teacher-42").
+		Return(&models.Identity{ID: 5, AccountID: 7, Provider: testProviderID, Subject: "teacher-42"}, nil)
+	suite.mockAccountRepo.On("GetAccountByID", mock.Anything, "7", false).
+		Return(&models.Account{Model: gorm.Model{ID: 7}, Email: "ada@lincoln.example.edu"}, nil)
+	suite.mockFederationRepo.On("RecordIdentityLogin", mock.Anything, mock.MatchedBy(func(identity *models.Identity) bool {
+		return identity.ID == 5 && identity.Email == "ada@lincoln.example.edu"
+	}), mock.Anything, mock.MatchedBy(func(event models.AuditEvent) bool {
+		return event.Action == models.AuditActionAccountLogin && *event.ActorID == 7
+	})).Return(nil)
+
+	result, err := suite.federationService.FinishLogin(context.Background(), testProviderID, state, req)
+	suite.Require().NoError(err)
+
+	suite.Equal(testReturnTo, result.ReturnTo)
+	suite.NotEmpty(result.SessionToken)
+	suite.mockOAuthRepo.AssertCalled(suite.T(), "CreateSession", mock.Anything, mock.MatchedBy(func(session *models.Session) bool {
+		return session.AccountID == 7 && session.TokenHash == oauth.HashToken(result.SessionToken) &&
+			len(session.AMR) == 1 && session.AMR[0] == oauth.AMRFederated
+	}))
+	suite.mockFederationRepo.AssertExpectations(suite.T())
+}
+
+func (suite *FederationTestSuite) TestFinishLoginLinksAccountWithVerifiedEmail() {
+	req, state := suite.signIn()
+	suite.mockFederationRepo.On("GetIdentity", mock.Anything, testProviderID, "teacher-42").Return(nil, gorm.ErrRecordNotFound)
+	suite.mockAccountRepo.On("GetAccountByEmail", mock.Anything, "ada@lincoln.example.edu").
+		Return(&models.Account{Model: gorm.Model{ID: 7}, Email: "ada@lincoln.example.edu", VerificationStatus: "verified"}, nil)
+	suite.mockFederationRepo.On("LinkIdentity", mock.Anything, mock.MatchedBy(func(identity *models.Identity) bool {
+		return identity.AccountID == 7 && identity.Provider == testProviderID && identity.Subject == "teacher-42"
+	}), mock.Anything, mock.MatchedBy(func(audits []models.AuditEvent) bool {
+		return len(audits) == 2 && audits[0].Action == models.AuditActionIdentityLinked && audits[1].Action == models.AuditActionAccountLogin
+	})).Return(nil)
+
+	result, err := suite.federationService.FinishLogin(context.Background(), testProviderID, state, req)
+	suite.Require().NoError(err)
+
+	suite.Equal(testReturnTo, result.ReturnTo)
+	suite.mockFederationRepo.AssertExpectations(suite.T())
+}
+
+func (suite *FederationTestSuite) TestFinishLoginCreatesAccount() {
+	req, state := suite.signIn()
+	suite.mockFederationRepo.On("GetIdentity", mock.Anything, testProviderID, "teacher-42").Return(nil, gorm.ErrRecordNotFound)
+	suite.mockAccountRepo.On("GetAccountByEmail", mock.Anything, "ada@lincoln.example.edu").Return(nil, gorm.ErrRecordNotFound)
+
+	var created *models.Account
+	suite.mockFederationRepo.On("CreateFederatedAccount", mock.Anything, mock.AnythingOfType("*models.Account"), mock.MatchedBy(func(identity *models.Identity) bool {
+		return identity.Provider == testProviderID && identity.Subject == "teacher-42" && identity.Email == "ada@lincoln.example.edu"
+	}), mock.MatchedBy(func(audits []models.AuditEvent) bool {
+		return len(audits) == 3 && audits[0].Action == models.AuditActionAccountCreated
+	})).Run(func(args mock.Arguments) {
+		created = args.Get(1).(*models.Account)
+		created.ID = 12
+	}).Return(nil)
+
+	_, err := suite.federationService.FinishLogin(context.Background(), testProviderID, state, req)
+	suite.Require().NoError(err)
+
+	suite.Require().NotNil(created)
+	suite.Equal("Ada", created.FirstName)
+	suite.Equal("Lovelace", created.LastName)
+	suite.Equal("ada@lincoln.example.edu", created.Email)
+	suite.Equal("verified", created.VerificationStatus)
+	suite.Equal("https://idp.example.edu/photos/42.png", created.PhotoUrl)
+	suite.Equal("en-GB", created.Locale)
+	suite.Empty(created.Phone, "an invalid phone number is left out")
+	suite.Empty(created.AccountPassword.Password)
+	suite.Require().Len(created.Roles, 1)
+	suite.Equal("common", created.Roles[0].Name)
+	suite.mockOAuthRepo.AssertCalled(suite.T(), "CreateSession", mock.Anything, mock.MatchedBy(func(session *models.Session) bool {
+		return session.AccountID == 12
+	}))
+}
+
+func (suite *FederationTestSuite) TestFinishLoginRequiresVerifiedEmail() {
+	suite.idp.claims["email_verified"] = false
+	req, state := suite.signIn()
+	suite.mockFederationRepo.On("GetIdentity", mock.Anything, testProviderID, "teacher-42").Return(nil, gorm.ErrRecordNotFound)
+
+	_, err := suite.federationService.FinishLogin(context.Background(), testProviderID, state, req)
+	suite.Require().Error(err)
+	suite.Equal(oauth.ErrorAccessDenied, err.(*oauth.Error).Code)
+	suite.mockAccountRepo.AssertNotCalled(suite.T(), "GetAccountByEmail", mock.Anything, mock.Anything)
+	suite.mockOAuthRepo.AssertNotCalled(suite.T(), "CreateSession", mock.Anything, mock.Anything)
+}
+
+func (suite *FederationTestSuite) TestFinishLoginDoesNotLinkUnverifiedAccount() {
+	req, state := suite.signIn()
+	suite.mockFederationRepo.On("GetIdentity", mock.Anything, testProviderID, "teacher-42").Return(nil, gorm.ErrRecordNotFound)
+	suite.mockAccountRepo.On("GetAccountByEmail", mock.Anything, "ada@lincoln.example.edu").
+		Return(&models.Account{Model: gorm.Model{ID: 7}, Email: "ada@lincoln.example.edu", VerificationStatus: "pending"}, nil)
+
+	_, err := suite.federationService.FinishLogin(context.Background(), testProviderID, state, req)
+	suite.Require().Error(err)
+	suite.Equal(oauth.ErrorAccessDenied, err.(*oauth.Error).Code)
+	suite.mockFederationRepo.AssertNotCalled(suite.T(), "LinkIdentity", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *FederationTestSuite) TestFinishLoginRejectsSuspendedAccount() {
+	req, state := suite.signIn()
+	suspendedAt := time.Now().Add(-time.Hour)
+	suite.mockFederationRepo.On("GetIdentity", mock.Anything, testProviderID, "teacher-42").
+		Return(&models.Identity{ID: 5, AccountID: 7, Provider: testProviderID, Subject: "teacher-42"}, nil)
+	suite.mockAccountRepo.On("GetAccountByID", mock.Anything, "7", false).
+		Return(&models.Account{Model: gorm.Model{ID: 7}, SuspendedAt: &suspendedAt}, nil)
+
+	_, err := suite.federationService.FinishLogin(context.Background(), testProviderID, state, req)
+	suite.Require().Error(err)
+	suite.Equal(oauth.ErrorAccessDenied, err.(*oauth.Error).Code)
+	suite.mockOAuthRepo.AssertNotCalled(suite.T(), "CreateSession", mock.Anything, mock.Anything)
+}
+
+func (suite *FederationTestSuite) TestFinishLoginRejectsStateFromAnotherBrowser() {
+	req, _ := suite.signIn()
+
+	_, err := suite.federationService.FinishLogin(context.Background(), testProviderID, "another-browser", req)
+	suite.Require().Error(err)
+	suite.Equal(oauth.ErrorInvalidRequest, err.(*oauth.Error).Code)
+	suite.mockFederationRepo.AssertNotCalled(suite.T(), "ConsumeFederatedLogin", mock.Anything, mock.Anything)
+}
+
+func (suite *FederationTestSuite) TestFinishLoginRejectsUsedOrExpiredState() {
+	req, state := suite.signIn()
+	suite.login.ExpiresAt = time.Now().Add(-time.Second)
+
+	_, err := suite.federationService.FinishLogin(context.Background(), testProviderID, state, req)
+	suite.Require().Error(err)
+	suite.Equal(oauth.ErrorInvalidRequest, err.(*oauth.Error).Code)
+
+	suite.mockFederationRepo.On("ConsumeFederatedLogin", mock.Anything, oauth.HashToken(state)).Return(nil, gorm.ErrRecordNotFound)
+	_, err = suite.federationService.FinishLogin(context.Background(), testProviderID, state, req)
+	suite.Require().Error(err)
+	suite.Equal(oauth.ErrorInvalidRequest, err.(*oauth.Error).Code)
+}
+
+func (suite *FederationTestSuite) TestFinishLoginRejectsWrongNonce() {
+	suite.idp.nonce = "replayed-nonce"
+	req, state := suite.signIn()
+
+	_, err := suite.federationService.FinishLogin(context.Background(), testProviderID, state, req)
+	suite.Require().Error(err)
+	suite.Equal(oauth.ErrorAccessDenied, err.(*oauth.Error).Code)
+	suite.mockAuditRepo.AssertCalled(suite.T(), "RecordAuditEvent", mock.Anything, mock.MatchedBy(func(event models.AuditEvent) bool {
+		return event.Action == models.AuditActionAccountLogin && event.Outcome == models.AuditOutcomeFailure
+	}))
+	suite.mockFederationRepo.AssertNotCalled(suite.T(), "GetIdentity", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *FederationTestSuite) TestFinishLoginRejectsProviderErrors() {
+	req, state := suite.signIn()
+	req.Code = ""
+	req.Error = "access_denied"
+
+	_, err := suite.federationService.FinishLogin(context.Background(), testProviderID, state, req)
+	suite.Require().Error(err)
+	suite.Equal(oauth.ErrorAccessDenied, err.(*oauth.Error).Code)
+
+	req, state = suite.signIn()
+	req.Issuer = "https://mix-up.example.com"
+	_, err = suite.federationService.FinishLogin(context.Background(), testProviderID, state, req)
+	suite.Require().Error(err)
+	suite.Equal(oauth.ErrorInvalidRequest, err.(*oauth.Error).Code)
+	suite.mockFederationRepo.AssertNotCalled(suite.T(), "GetIdentity", mock.Anything, mock.Anything, mock.Anything)
+}
+
+// samlSignIn starts a sign-in with the SAML provider and returns the form
+// the provider posts back, with the response samlResponse describes, and
+// the state the browser kept.
+func (suite *FederationTestSuite) samlSignIn(response samltest.Response) (dto.SAMLResponseRequest, string) {
+	start, err := suite.federationService.StartLogin(context.Background(), testSAMLProviderID, testReturnTo)
+	suite.Require().NoError(err)
+	suite.mockFederationRepo.On("ConsumeFederatedLogin", mock.Anything, oauth.HashToken(start.State)).Return(suite.login, nil).Once()
+
+	response.InResponseTo = suite.login.Nonce
+	response.Destination = testPublicBaseURL + "/oauth/federated/" + testSAMLProviderID + "/acs"
+	response.Audience = testPublicBaseURL + "/oauth/federated/" + testSAMLProviderID + "/metadata"
+	encoded, err := suite.samlIdP.EncodedResponse(response)
+	suite.Require().NoError(err)
+
+	return dto.SAMLResponseRequest{SAMLResponse: encoded, RelayState: start.State}, start.State
+}
+
+func (suite *FederationTestSuite) TestStartSAMLLogin() {
+	start, err := suite.federationService.StartLogin(context.Background(), testSAMLProviderID, testReturnTo)
+	suite.Require().NoError(err)
+
+	redirect, err := url.Parse(start.RedirectURL)
+	suite.Require().NoError(err)
+	suite.Equal("idp.acme.example.com", redirect.Host)
+	suite.Equal(start.State, redirect.Query().Get("RelayState"))
+	suite.NotEmpty(redirect.Query().Get("SAMLRequest"))
+	suite.True(start.CrossSitePost)
+
+	suite.Require().NotNil(suite.login)
+	suite.Equal(testSAMLProviderID, suite.login.Provider)
+	suite.Equal(oauth.HashToken(start.State), suite.login.StateHash)
+	suite.NotEmpty(suite.login.Nonce, "the ID of the AuthnRequest")
+	suite.Empty(suite.login.CodeVerifier)
+}
+
+func (suite *FederationTestSuite) TestSAMLMetadata() {
+	metadata, err := suite.federationService.SAMLMetadata(testSAMLProviderID)
+	suite.Require().NoError(err)
+	suite.Contains(string(metadata), `entityID="`+testPublicBaseURL+`/oauth/federated/acme/metadata"`)
+	suite.Contains(string(metadata), `Location="`+testPublicBaseURL+`/oauth/federated/acme/acs"`)
+
+	_, err = suite.federationService.SAMLMetadata(testProviderID)
+	suite.Require().Error(err)
+	suite.Equal(oauth.ErrorInvalidRequest, err.(*oauth.Error).Code)
+}
+
+func (suite *FederationTestSuite) TestFinishSAMLLoginCreatesAccount() {
+	req, state := suite.samlSignIn(samltest.Response{
+		NameID: "u-1234",
+		Attributes: map[string]string{
+			"urn:oid:0.9.2342.19200300.100.1.3": "grace@acme.example.com",
+			"urn:oid:2.5.4.42":                  "Grace",
+			"urn:oid:2.5.4.4":                   "Hopper",
+		},
+		SignAssertion: true,
+	})
+	suite.mockFederationRepo.On("GetIdentity", mock.Anything, testSAMLProviderID, "u-1234").Return(nil, gorm.ErrRecordNotFound)
+	suite.mockAccountRepo.On("GetAccountByEmail", mock.Anything, "grace@acme.example.com").Return(nil, gorm.ErrRecordNotFound)
+
+	var created *models.Account
+	suite.mockFederationRepo.On("CreateFederatedAccount", mock.Anything, mock.AnythingOfType("*models.Account"), mock.MatchedBy(func(identity *models.Identity) bool {
+		return identity.Provider == testSAMLProviderID && identity.Subject == "u-1234"
+	}), mock.Anything).Run(func(args mock.Arguments) {
+		created = args.Get(1).(*models.Account)
+		created.ID = 13
+	}).Return(nil)
+
+	result, err := suite.federationService.FinishSAMLLogin(context.Background(), testSAMLProviderID, state, req)
+	suite.Require().NoError(err)
+
+	suite.Equal(testReturnTo, result.ReturnTo)
+	suite.Require().NotNil(created)
+	suite.Equal("Grace", created.FirstName)
+	suite.Equal("Hopper", created.LastName)
+	suite.Equal("grace@acme.example.com", created.Email)
+	suite.mockOAuthRepo.AssertCalled(suite.T(), "CreateSession", mock.Anything, mock.MatchedBy(func(session *models.Session) bool {
+		return session.AccountID == 13 && session.AMR[0] == oauth.AMRFederated
+	}))
+}
+
+func (suite *FederationTestSuite) TestFinishSAMLLoginWithLinkedIdentity() {
+	req, state := suite.samlSignIn(samltest.Response{
+		NameID:       "grace@acme.example.com",
+		NameIDFormat: saml.NameIDFormatEmail,
+		SignResponse: true,
+	})
+	suite.mockFederationRepo.On("GetIdentity", mock.Anything, testSAMLProviderID, "grace@acme.example.com").
+		Return(&models.Identity{ID: 6, AccountID: 7, Provider: testSAMLProviderID, Subject: "grace@acme.example.com"}, nil)
+	suite.mockAccountRepo.On("GetAccountByID", mock.Anything, "7", false).Return(&models.Account{Model: gorm.Model{ID: 7}}, nil)
+	suite.mockFederationRepo.On("RecordIdentityLogin", mock.Anything, mock.MatchedBy(func(identity *models.Identity) bool {
+		return identity.Email == "grace@acme.example.com"
+	}), mock.Anything, mock.Anything).Return(nil)
+
+	_, err := suite.federationService.FinishSAMLLogin(context.Background(), testSAMLProviderID, state, req)
+	suite.Require().NoError(err)
+	suite.mockFederationRepo.AssertExpectations(suite.T())
+}
+
+func (suite *FederationTestSuite) TestFinishSAMLLoginRejectsUnverifiedResponses() {
+	req, state := suite.samlSignIn(samltest.Response{NameID: "u-1234"})
+
+	_, err := suite.federationService.FinishSAMLLogin(context.Background(), testSAMLProviderID, state, req)
+	suite.Require().Error(err)
+	suite.Equal(oauth.ErrorAccessDenied, err.(*oauth.Error).Code)
+	suite.mockAuditRepo.AssertCalled(suite.T(), "RecordAuditEvent", mock.Anything, mock.MatchedBy(func(event models.AuditEvent) bool {
+		return event.Outcome == models.AuditOutcomeFailure && strings.Contains(event.Metadata, `"reason":"provider_error"`)
+	}))
+
+	// A response for another sign-in of the same browser.
+	other, _ := suite.samlSignIn(samltest.Response{NameID: "u-1234", SignAssertion: true})
+	req, state = suite.samlSignIn(samltest.Response{NameID: "u-1234", SignAssertion: true})
+	req.SAMLResponse = other.SAMLResponse
+	_, err = suite.federationService.FinishSAMLLogin(context.Background(), testSAMLProviderID, state, req)
+	suite.Require().Error(err)
+	suite.Equal(oauth.ErrorAccessDenied, err.(*oauth.Error).Code)
+
+	req, _ = suite.samlSignIn(samltest.Response{NameID: "u-1234", SignAssertion: true})
+	_, err = suite.federationService.FinishSAMLLogin(context.Background(), testSAMLProviderID, "another-browser", req)
+	suite.Require().Error(err)
+	suite.Equal(oauth.ErrorInvalidRequest, err.(*oauth.Error).Code)
+	suite.mockFederationRepo.AssertNotCalled(suite.T(), "GetIdentity", mock.Anything, mock.Anything, mock.Anything)
+
+	_, err = suite.federationService.FinishLogin(context.Background(), testSAMLProviderID, state, dto.FederationCallbackRequest{State: state, Code: "code"})
+	suite.Require().Error(err)
+	suite.Equal(oauth.ErrorInvalidRequest, err.(*oauth.Error).Code)
+}
+
+func TestFederationSuite(t *testing.T) {
+	suite.Run(t, new(FederationTestSuite))
+}
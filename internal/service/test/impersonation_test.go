@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/ssoydabas/auth-service/pkg/errors"
+	"github.com/stretchr/testify/mock"
+)
+
+func parseTestToken(token string) jwt.MapClaims {
+	claims := jwt.MapClaims{}
+	_, _ = jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+		return []byte(os.Getenv("JWT_SECRET")), nil
+	})
+	return claims
+}
+
+func (suite *AccountServiceTestSuite) TestStartImpersonation() {
+	tests := []struct {
+		name       string
+		req        dto.StartImpersonationRequest
+		setupMocks func()
+		wantStatus int
+	}{
+		{
+			name:       "reason is required",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "cannot impersonate yourself",
+			req:  dto.StartImpersonationRequest{Reason: "ticket 42"},
+			setupMocks: func() {
+				suite.mockRepo.On("GetAccountByID", mock.Anything, "2", false).
+					Return(suite.createTestAccount(1, "admin@example.com", "+1234567891"), nil)
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name: "cannot impersonate another administrator",
+			req:  dto.StartImpersonationRequest{Reason: "ticket 42"},
+			setupMocks: func() {
+				suite.mockRepo.On("GetAccountByID", mock.Anything, "2", false).
+					Return(suite.createTestAccount(2, "test@example.com", "+1234567890"), nil)
+				suite.mockRoleRepo.On("AccountHasPermission", mock.Anything, uint(2), models.PermissionAccountsImpersonate).
+					Return(true, nil)
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name: "cannot impersonate a suspended account",
+			req:  dto.StartImpersonationRequest{Reason: "ticket 42"},
+			setupMocks: func() {
+				suite.mockRepo.On("GetAccountByID", mock.Anything, "2", false).
+					Return(suite.suspendedAccount(nil), nil)
+				suite.mockRoleRepo.On("AccountHasPermission", mock.Anything, uint(2), models.PermissionAccountsImpersonate).
+					Return(false, nil)
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name: "impersonation token is issued",
+			req:  dto.StartImpersonationRequest{Reason: "ticket 42"},
+			setupMocks: func() {
+				suite.mockRepo.On("GetAccountByID", mock.Anything, "2", false).
+					Return(suite.createTestAccount(2, "test@example.com", "+1234567890"), nil)
+				suite.mockRoleRepo.On("AccountHasPermission", mock.Anything, uint(2), models.PermissionAccountsImpersonate).
+					Return(false, nil)
+				suite.mockRepo.On("CreateImpersonationSession", mock.Anything,
+					mock.MatchedBy(func(session *models.ImpersonationSession) bool {
+						return session.AdminID == 1 && session.AccountID == 2 && session.Reason == "ticket 42"
+					}),
+					mock.MatchedBy(func(event models.AuditEvent) bool {
+						return event.Action == models.AuditActionImpersonationStarted && *event.ActorID == 1 && *event.TargetID == 2
+					})).Return(nil)
+			},
+			wantStatus: http.StatusCreated,
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			suite.mockRepo.ExpectedCalls = nil
+			suite.mockRoleRepo.ExpectedCalls = nil
+			if tt.setupMocks != nil {
+				tt.setupMocks()
+			}
+
+			impersonation, err := suite.service.StartImpersonation(context.Background(), 1, "2", tt.req)
+
+			if tt.wantStatus != http.StatusCreated {
+				suite.Require().Error(err)
+				suite.Equal(tt.wantStatus, err.(*errors.AppError).Code)
+				suite.mockRepo.AssertNotCalled(suite.T(), "CreateImpersonationSession", mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+
+			suite.Require().NoError(err)
+			claims := parseTestToken(impersonation.Token)
+			suite.Equal(float64(2), claims["sub"])
+			suite.Equal(map[string]interface{}{"sub": float64(1)}, claims["act"])
+			suite.Equal(impersonation.SessionID, claims["jti"])
+
+			exp, err := claims.GetExpirationTime()
+			suite.Require().NoError(err)
+			suite.WithinDuration(time.Now().Add(15*time.Minute), exp.Time, time.Minute)
+			suite.mockRepo.AssertExpectations(suite.T())
+		})
+	}
+}
+
+func (suite *AccountServiceTestSuite) impersonationToken(sessionID string) string {
+	token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": 2,
+		"act": map[string]interface{}{"sub": 1},
+		"jti": sessionID,
+		"exp": time.Now().Add(15 * time.Minute).Unix(),
+	}).SignedString([]byte(os.Getenv("JWT_SECRET")))
+	return token
+}
+
+func (suite *AccountServiceTestSuite) TestGetAccountByImpersonationToken() {
+	ended := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name    string
+		session *models.ImpersonationSession
+		wantErr bool
+	}{
+		{
+			name:    "active session",
+			session: &models.ImpersonationSession{PublicID: "session", AdminID: 1, AccountID: 2, ExpiresAt: time.Now().Add(time.Minute)},
+		},
+		{
+			name:    "stopped session",
+			session: &models.ImpersonationSession{PublicID: "session", AdminID: 1, AccountID: 2, ExpiresAt: time.Now().Add(time.Minute), EndedAt: &ended},
+			wantErr: true,
+		},
+		{
+			name:    "session of another administrator",
+			session: &models.ImpersonationSession{PublicID: "session", AdminID: 3, AccountID: 2, ExpiresAt: time.Now().Add(time.Minute)},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			suite.mockRepo.ExpectedCalls = nil
+			suite.mockRepo.On("GetAccountByID", mock.Anything, "2", false).
+				Return(suite.createTestAccount(2, "test@example.com", "+1234567890"), nil)
+			suite.mockRepo.On("GetImpersonationSession", mock.Anything, "session").Return(tt.session, nil)
+
+			account, err := suite.service.GetAccountByToken(context.Background(), suite.impersonationToken("session"))
+
+			if tt.wantErr {
+				suite.Require().Error(err)
+				suite.Equal(http.StatusUnauthorized, err.(*errors.AppError).Code)
+				return
+			}
+
+			suite.Require().NoError(err)
+			suite.Require().NotNil(account.ImpersonatedBy)
+			suite.Equal(uint(1), *account.ImpersonatedBy)
+		})
+	}
+}
+
+func (suite *AccountServiceTestSuite) TestStopImpersonation() {
+	suite.Run("regular token", func() {
+		err := suite.service.StopImpersonation(context.Background(), suite.generateTestToken(2))
+
+		suite.Require().Error(err)
+		suite.Equal(http.StatusBadRequest, err.(*errors.AppError).Code)
+	})
+
+	suite.Run("session is ended", func() {
+		suite.mockRepo.ExpectedCalls = nil
+		suite.mockRepo.On("EndImpersonationSession", mock.Anything, "session", mock.Anything,
+			mock.MatchedBy(func(event models.AuditEvent) bool {
+				return event.Action == models.AuditActionImpersonationStopped && *event.ActorID == 1 && *event.TargetID == 2
+			})).Return(true, nil)
+
+		suite.NoError(suite.service.StopImpersonation(context.Background(), suite.impersonationToken("session")))
+		suite.mockRepo.AssertExpectations(suite.T())
+	})
+
+	suite.Run("session was already ended", func() {
+		suite.mockRepo.ExpectedCalls = nil
+		suite.mockRepo.On("EndImpersonationSession", mock.Anything, "session", mock.Anything, mock.Anything).Return(false, nil)
+
+		err := suite.service.StopImpersonation(context.Background(), suite.impersonationToken("session"))
+
+		suite.Require().Error(err)
+		suite.Equal(http.StatusUnauthorized, err.(*errors.AppError).Code)
+	})
+}
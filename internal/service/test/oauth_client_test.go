@@ -0,0 +1,324 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/notification"
+	"github.com/ssoydabas/auth-service/internal/oauth"
+	"github.com/ssoydabas/auth-service/internal/service"
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/ssoydabas/auth-service/pkg/config"
+	"github.com/ssoydabas/auth-service/pkg/errors"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+)
+
+const (
+	testServiceClientID = "billing-job"
+	testClientSecret    = "the-client-secret"
+)
+
+type OAuthClientTestSuite struct {
+	suite.Suite
+	mockOAuthRepo *MockOAuthRepository
+	mockAuditRepo *MockAuditRepository
+	assertionKey  *rsa.PrivateKey
+	oauthService  service.OAuthService
+}
+
+func (suite *OAuthClientTestSuite) SetupSuite() {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	suite.Require().NoError(err)
+	suite.assertionKey = key
+}
+
+func (suite *OAuthClientTestSuite) SetupTest() {
+	suite.mockOAuthRepo = new(MockOAuthRepository)
+	suite.mockAuditRepo = new(MockAuditRepository)
+	suite.mockAuditRepo.On("RecordAuditEvent", mock.Anything, mock.Anything).Return(nil).Maybe()
+	mockAccountRepo := new(MockAccountRepository)
+
+	cfg := config.Config{
+		PublicBaseURL:                testPublicBaseURL,
+		OAuthAccessTokenTTL:          time.Hour,
+		OAuthClientSecretGracePeriod: 24 * time.Hour,
+	}
+
+	templates, err := notification.NewRenderer("", "Auth Service")
+	suite.Require().NoError(err)
+	signingKey, err := oauth.GenerateSigningKey()
+	suite.Require().NoError(err)
+	accountService := service.NewAccountService(mockAccountRepo, new(MockRoleRepository), suite.mockAuditRepo, templates, cfg)
+	suite.oauthService = service.NewOAuthService(suite.mockOAuthRepo, mockAccountRepo, suite.mockAuditRepo, accountService, signingKey, cfg)
+}
+
+func (suite *OAuthClientTestSuite) serviceClient(method string) *models.OAuthClient {
+	client := &models.OAuthClient{
+		Model:                   gorm.Model{ID: 3},
+		ClientID:                testServiceClientID,
+		Name:                    "Billing job",
+		RedirectURIs:            []string{},
+		Scopes:                  []string{"invoices:read", "invoices:write"},
+		GrantTypes:              []string{oauth.GrantClientCredentials},
+		TokenEndpointAuthMethod: method,
+		SecretHash:              oauth.HashToken(testClientSecret),
+	}
+	if method == oauth.AuthMethodPrivateKeyJWT {
+		client.SecretHash = ""
+		keys, err := json.Marshal(oauth.NewSigningKey(suite.assertionKey).JWKS())
+		suite.Require().NoError(err)
+		client.JWKS = keys
+	}
+	suite.mockOAuthRepo.On("GetOAuthClient", mock.Anything, testServiceClientID).Return(client, nil)
+	return client
+}
+
+func (suite *OAuthClientTestSuite) clientCredentialsRequest() dto.TokenRequest {
+	return dto.TokenRequest{
+		GrantType:    oauth.GrantClientCredentials,
+		ClientID:     testServiceClientID,
+		ClientSecret: testClientSecret,
+		BasicAuth:    true,
+	}
+}
+
+func (suite *OAuthClientTestSuite) assertion(claims jwt.MapClaims) string {
+	signed, err := oauth.NewSigningKey(suite.assertionKey).Sign(claims)
+	suite.Require().NoError(err)
+	return signed
+}
+
+func (suite *OAuthClientTestSuite) assertionClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss": testServiceClientID,
+		"sub": testServiceClientID,
+		"aud": testPublicBaseURL + "/oauth/token",
+		"jti": uuid.New().String(),
+		"exp": time.Now().Add(time.Minute).Unix(),
+	}
+}
+
+func (suite *OAuthClientTestSuite) oauthError(err error) *oauth.Error {
+	suite.Require().Error(err)
+	oauthErr, ok := err.(*oauth.Error)
+	suite.Require().True(ok, "expected an OAuth error, got %T", err)
+	return oauthErr
+}
+
+func (suite *OAuthClientTestSuite) TestClientCredentialsWithBasicAuth() {
+	suite.serviceClient(oauth.AuthMethodClientSecretBasic)
+
+	req := suite.clientCredentialsRequest()
+	req.Scope = "invoices:read"
+	response, err := suite.oauthService.Token(context.Background(), req)
+
+	suite.Require().NoError(err)
+	suite.Equal("invoices:read", response.Scope)
+	suite.Empty(response.RefreshToken, "no refresh token without a user")
+	suite.Empty(response.IDToken)
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(response.AccessToken, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(os.Getenv("JWT_SECRET")), nil
+	})
+	suite.Require().NoError(err)
+	suite.Equal(testServiceClientID, claims["sub"])
+	suite.Equal(testServiceClientID, claims["client_id"])
+	suite.Equal("invoices:read", claims["scope"])
+}
+
+func (suite *OAuthClientTestSuite) TestClientCredentialsRejectsWrongSecretAndMethod() {
+	suite.serviceClient(oauth.AuthMethodClientSecretBasic)
+
+	req := suite.clientCredentialsRequest()
+	req.ClientSecret = "wrong"
+	_, err := suite.oauthService.Token(context.Background(), req)
+	oauthErr := suite.oauthError(err)
+	suite.Equal(oauth.ErrorInvalidClient, oauthErr.Code)
+	suite.Equal(401, oauthErr.Status)
+
+	req = suite.clientCredentialsRequest()
+	req.BasicAuth = false
+	_, err = suite.oauthService.Token(context.Background(), req)
+	suite.Equal(oauth.ErrorInvalidClient, suite.oauthError(err).Code, "the secret must come the way the client registered")
+
+	req = suite.clientCredentialsRequest()
+	req.ClientSecret, req.BasicAuth = "", false
+	_, err = suite.oauthService.Token(context.Background(), req)
+	suite.Equal(oauth.ErrorInvalidClient, suite.oauthError(err).Code)
+}
+
+func (suite *OAuthClientTestSuite) TestClientCredentialsRejectsScopeBeyondClient() {
+	suite.serviceClient(oauth.AuthMethodClientSecretPost)
+
+	req := suite.clientCredentialsRequest()
+	req.BasicAuth = false
+	req.Scope = "invoices:read admin"
+	_, err := suite.oauthService.Token(context.Background(), req)
+
+	suite.Equal(oauth.ErrorInvalidScope, suite.oauthError(err).Code)
+}
+
+func (suite *OAuthClientTestSuite) TestClientCredentialsRequiresRegisteredGrant() {
+	client := suite.serviceClient(oauth.AuthMethodClientSecretBasic)
+	client.GrantTypes = []string{oauth.GrantAuthorizationCode}
+
+	_, err := suite.oauthService.Token(context.Background(), suite.clientCredentialsRequest())
+
+	suite.Equal(oauth.ErrorUnauthorizedClient, suite.oauthError(err).Code)
+}
+
+func (suite *OAuthClientTestSuite) TestPreviousSecretWorksDuringGracePeriod() {
+	client := suite.serviceClient(oauth.AuthMethodClientSecretBasic)
+	client.SecretHash = oauth.HashToken("the-new-secret")
+	client.PreviousSecretHash = oauth.HashToken(testClientSecret)
+	expiresAt := time.Now().Add(time.Hour)
+	client.PreviousSecretExpiresAt = &expiresAt
+
+	_, err := suite.oauthService.Token(context.Background(), suite.clientCredentialsRequest())
+	suite.Require().NoError(err)
+
+	expiresAt = time.Now().Add(-time.Second)
+	_, err = suite.oauthService.Token(context.Background(), suite.clientCredentialsRequest())
+	suite.Equal(oauth.ErrorInvalidClient, suite.oauthError(err).Code)
+}
+
+func (suite *OAuthClientTestSuite) TestPrivateKeyJWT() {
+	suite.serviceClient(oauth.AuthMethodPrivateKeyJWT)
+	claims := suite.assertionClaims()
+	suite.mockOAuthRepo.On("RecordClientAssertion", mock.Anything, mock.MatchedBy(func(assertion *models.OAuthClientAssertion) bool {
+		return assertion.ClientID == testServiceClientID && assertion.JTI == claims["jti"]
+	})).Return(true, nil).Once()
+
+	req := dto.TokenRequest{
+		GrantType:           oauth.GrantClientCredentials,
+		ClientAssertionType: oauth.ClientAssertionTypeJWTBearer,
+		ClientAssertion:     suite.assertion(claims),
+	}
+	response, err := suite.oauthService.Token(context.Background(), req)
+
+	suite.Require().NoError(err)
+	suite.NotEmpty(response.AccessToken)
+
+	// The same assertion cannot be used twice.
+	suite.mockOAuthRepo.On("RecordClientAssertion", mock.Anything, mock.Anything).Return(false, nil).Once()
+	_, err = suite.oauthService.Token(context.Background(), req)
+	suite.Equal(oauth.ErrorInvalidClient, suite.oauthError(err).Code)
+}
+
+func (suite *OAuthClientTestSuite) TestPrivateKeyJWTRejectsInvalidAssertions() {
+	suite.serviceClient(oauth.AuthMethodPrivateKeyJWT)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	suite.Require().NoError(err)
+	forged, err := oauth.NewSigningKey(otherKey).Sign(suite.assertionClaims())
+	suite.Require().NoError(err)
+
+	wrongAudience := suite.assertionClaims()
+	wrongAudience["aud"] = "https://other.example.com/token"
+	longLived := suite.assertionClaims()
+	longLived["exp"] = time.Now().Add(time.Hour).Unix()
+	noJTI := suite.assertionClaims()
+	delete(noJTI, "jti")
+
+	for name, assertion := range map[string]string{
+		"forged":         forged,
+		"wrong audience": suite.assertion(wrongAudience),
+		"long lived":     suite.assertion(longLived),
+		"no jti":         suite.assertion(noJTI),
+	} {
+		_, err := suite.oauthService.Token(context.Background(), dto.TokenRequest{
+			GrantType:           oauth.GrantClientCredentials,
+			ClientAssertionType: oauth.ClientAssertionTypeJWTBearer,
+			ClientAssertion:     assertion,
+		})
+		suite.Equal(oauth.ErrorInvalidClient, suite.oauthError(err).Code, name)
+	}
+	suite.mockOAuthRepo.AssertNotCalled(suite.T(), "RecordClientAssertion", mock.Anything, mock.Anything)
+}
+
+func (suite *OAuthClientTestSuite) TestCreateConfidentialClientReturnsSecretOnce() {
+	var stored *models.OAuthClient
+	suite.mockOAuthRepo.On("CreateOAuthClient", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { stored = args.Get(1).(*models.OAuthClient) }).
+		Return(nil)
+
+	client, err := suite.oauthService.CreateClient(context.Background(), 1, dto.CreateOAuthClientRequest{
+		Name:                    "Billing job",
+		Scopes:                  []string{"invoices:read"},
+		GrantTypes:              []string{oauth.GrantClientCredentials},
+		TokenEndpointAuthMethod: oauth.AuthMethodClientSecretBasic,
+	})
+
+	suite.Require().NoError(err)
+	suite.Require().NotNil(stored)
+	suite.NotEmpty(client.ClientSecret)
+	suite.Equal(oauth.HashToken(client.ClientSecret), stored.SecretHash, "only the hash of the secret is stored")
+	suite.Equal([]string{oauth.GrantClientCredentials}, client.GrantTypes)
+	suite.Equal(oauth.AuthMethodClientSecretBasic, client.TokenEndpointAuthMethod)
+
+	clients := []models.OAuthClient{*stored}
+	suite.mockOAuthRepo.On("ListOAuthClients", mock.Anything).Return(clients, nil)
+	listed, err := suite.oauthService.ListClients(context.Background())
+	suite.Require().NoError(err)
+	suite.Empty(listed[0].ClientSecret)
+}
+
+func (suite *OAuthClientTestSuite) TestRotateClientSecret() {
+	suite.serviceClient(oauth.AuthMethodClientSecretBasic)
+
+	var changes map[string]interface{}
+	suite.mockOAuthRepo.On("UpdateOAuthClient", mock.Anything, uint(3), mock.Anything,
+		mock.MatchedBy(func(event models.AuditEvent) bool {
+			return event.Action == models.AuditActionOAuthClientSecretRotated && *event.ActorID == 1
+		})).
+		Run(func(args mock.Arguments) { changes = args.Get(2).(map[string]interface{}) }).
+		Return(nil)
+
+	client, err := suite.oauthService.RotateClientSecret(context.Background(), 1, testServiceClientID)
+
+	suite.Require().NoError(err)
+	suite.NotEmpty(client.ClientSecret)
+	suite.NotNil(client.SecretRotatedAt)
+	suite.Equal(oauth.HashToken(client.ClientSecret), changes["secret_hash"])
+	suite.Equal(oauth.HashToken(testClientSecret), changes["previous_secret_hash"])
+	suite.WithinDuration(time.Now().Add(24*time.Hour), changes["previous_secret_expires_at"].(time.Time), 5*time.Second)
+}
+
+func (suite *OAuthClientTestSuite) TestRotateClientSecretRequiresSecretClient() {
+	suite.serviceClient(oauth.AuthMethodPrivateKeyJWT)
+
+	_, err := suite.oauthService.RotateClientSecret(context.Background(), 1, testServiceClientID)
+
+	suite.Equal(errors.BadRequestError("Client does not authenticate with a secret"), err)
+}
+
+func (suite *OAuthClientTestSuite) TestDisableClient() {
+	suite.serviceClient(oauth.AuthMethodClientSecretBasic)
+	suite.mockOAuthRepo.On("DisableOAuthClient", mock.Anything, testServiceClientID, mock.Anything,
+		mock.MatchedBy(func(event models.AuditEvent) bool { return event.Action == models.AuditActionOAuthClientDisabled })).
+		Return(nil)
+
+	client, err := suite.oauthService.DisableClient(context.Background(), 1, testServiceClientID)
+
+	suite.Require().NoError(err)
+	suite.NotNil(client.DisabledAt)
+
+	suite.mockOAuthRepo.On("GetOAuthClient", mock.Anything, "unknown").Return(nil, gorm.ErrRecordNotFound)
+	_, err = suite.oauthService.DisableClient(context.Background(), 1, "unknown")
+	suite.Equal(errors.NotFoundError("Client not found"), err)
+}
+
+func TestOAuthClientSuite(t *testing.T) {
+	suite.Run(t, new(OAuthClientTestSuite))
+}
@@ -0,0 +1,337 @@
+package service
+
+import (
+	"context"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/notification"
+	"github.com/ssoydabas/auth-service/internal/oauth"
+	"github.com/ssoydabas/auth-service/internal/service"
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/ssoydabas/auth-service/pkg/config"
+	"github.com/ssoydabas/auth-service/pkg/errors"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+)
+
+const (
+	testDeviceClientID = "tv-app"
+	testDeviceCode     = "the-device-code"
+	testUserCode       = "WDJBMJHT"
+	testSessionToken   = "the-session-token"
+)
+
+type OAuthDeviceTestSuite struct {
+	suite.Suite
+	mockOAuthRepo   *MockOAuthRepository
+	mockAccountRepo *MockAccountRepository
+	oauthService    service.OAuthService
+}
+
+func (suite *OAuthDeviceTestSuite) SetupTest() {
+	suite.mockOAuthRepo = new(MockOAuthRepository)
+	suite.mockAccountRepo = new(MockAccountRepository)
+	mockAuditRepo := new(MockAuditRepository)
+	mockAuditRepo.On("RecordAuditEvent", mock.Anything, mock.Anything).Return(nil).Maybe()
+
+	cfg := config.Config{
+		PublicBaseURL:           testPublicBaseURL,
+		OAuthAccessTokenTTL:     time.Hour,
+		OAuthRefreshTokenTTL:    30 * 24 * time.Hour,
+		OAuthDeviceCodeTTL:      10 * time.Minute,
+		OAuthDevicePollInterval: 5 * time.Second,
+	}
+
+	templates, err := notification.NewRenderer("", "Auth Service")
+	suite.Require().NoError(err)
+	signingKey, err := oauth.GenerateSigningKey()
+	suite.Require().NoError(err)
+	accountService := service.NewAccountService(suite.mockAccountRepo, new(MockRoleRepository), mockAuditRepo, templates, cfg)
+	suite.oauthService = service.NewOAuthService(suite.mockOAuthRepo, suite.mockAccountRepo, mockAuditRepo, accountService, signingKey, cfg)
+
+	suite.mockOAuthRepo.On("GetOAuthClient", mock.Anything, testDeviceClientID).Return(&models.OAuthClient{
+		Model:                   gorm.Model{ID: 4},
+		ClientID:                testDeviceClientID,
+		Name:                    "TV app",
+		RedirectURIs:            []string{},
+		Scopes:                  []string{"openid", "profile"},
+		GrantTypes:              []string{oauth.GrantDeviceCode, oauth.GrantRefreshToken},
+		TokenEndpointAuthMethod: oauth.AuthMethodNone,
+	}, nil).Maybe()
+	suite.mockAccountRepo.On("GetAccountByID", mock.Anything, "7", false).
+		Return(&models.Account{Model: gorm.Model{ID: 7}, Email: "jane@example.com"}, nil).Maybe()
+	suite.mockOAuthRepo.On("GetGrant", mock.Anything, uint(7), testDeviceClientID).Return(&models.OAuthGrant{
+		AccountID: 7,
+		ClientID:  testDeviceClientID,
+		Scopes:    []string{"openid"},
+	}, nil).Maybe()
+}
+
+// deviceCode registers a pending device code, reachable by both its device
+// code and its user code.
+func (suite *OAuthDeviceTestSuite) deviceCode() *models.OAuthDeviceCode {
+	code := &models.OAuthDeviceCode{
+		Model:          gorm.Model{ID: 11},
+		DeviceCodeHash: oauth.HashToken(testDeviceCode),
+		UserCodeHash:   oauth.HashToken(testUserCode),
+		ClientID:       testDeviceClientID,
+		Scope:          "openid profile",
+		PollInterval:   5,
+		ExpiresAt:      time.Now().Add(10 * time.Minute),
+	}
+	suite.mockOAuthRepo.On("GetDeviceCodeByHash", mock.Anything, oauth.HashToken(testDeviceCode)).Return(code, nil).Maybe()
+	suite.mockOAuthRepo.On("GetDeviceCodeByUserCode", mock.Anything, oauth.HashToken(testUserCode)).Return(code, nil).Maybe()
+	return code
+}
+
+func (suite *OAuthDeviceTestSuite) approve(code *models.OAuthDeviceCode) {
+	accountID := uint(7)
+	authTime := time.Now().Add(-time.Minute).Truncate(time.Second)
+	approvedAt := time.Now()
+	code.AccountID = &accountID
+	code.SessionID = "session-id"
+	code.AuthTime = &authTime
+	code.AMR = []string{oauth.AMRPassword}
+	code.ApprovedAt = &approvedAt
+}
+
+func (suite *OAuthDeviceTestSuite) activeSession() {
+	suite.mockOAuthRepo.On("GetSessionByTokenHash", mock.Anything, oauth.HashToken(testSessionToken)).Return(&models.Session{
+		PublicID:  "session-id",
+		AccountID: 7,
+		AuthTime:  time.Now().Add(-time.Minute),
+		AMR:       []string{oauth.AMRPassword},
+		ExpiresAt: time.Now().Add(time.Hour),
+	}, nil)
+}
+
+func (suite *OAuthDeviceTestSuite) pollRequest() dto.TokenRequest {
+	return dto.TokenRequest{
+		GrantType:  oauth.GrantDeviceCode,
+		ClientID:   testDeviceClientID,
+		DeviceCode: testDeviceCode,
+	}
+}
+
+func (suite *OAuthDeviceTestSuite) oauthError(err error) *oauth.Error {
+	suite.Require().Error(err)
+	oauthErr, ok := err.(*oauth.Error)
+	suite.Require().True(ok, "expected an OAuth error, got %T", err)
+	return oauthErr
+}
+
+func (suite *OAuthDeviceTestSuite) TestDeviceAuthorizationIssuesCodes() {
+	var stored *models.OAuthDeviceCode
+	suite.mockOAuthRepo.On("CreateDeviceCode", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { stored = args.Get(1).(*models.OAuthDeviceCode) }).
+		Return(nil)
+
+	response, err := suite.oauthService.DeviceAuthorization(context.Background(), dto.DeviceAuthorizationRequest{
+		ClientID: testDeviceClientID,
+		Scope:    "profile",
+	})
+
+	suite.Require().NoError(err)
+	suite.Equal(testPublicBaseURL+"/oauth/device", response.VerificationURI)
+	suite.Equal(int64(600), response.ExpiresIn)
+	suite.Equal(5, response.Interval)
+
+	userCode, ok := oauth.NormalizeUserCode(response.UserCode)
+	suite.Require().True(ok)
+	suite.Equal(testPublicBaseURL+"/oauth/device?"+url.Values{"user_code": {response.UserCode}}.Encode(), response.VerificationURIComplete)
+
+	suite.Require().NotNil(stored)
+	suite.Equal(oauth.HashToken(response.DeviceCode), stored.DeviceCodeHash)
+	suite.Equal(oauth.HashToken(userCode), stored.UserCodeHash)
+	suite.Equal(testDeviceClientID, stored.ClientID)
+	suite.Equal("profile", stored.Scope)
+	suite.Nil(stored.AccountID)
+}
+
+func (suite *OAuthDeviceTestSuite) TestDeviceAuthorizationChecksClient() {
+	suite.mockOAuthRepo.On("GetOAuthClient", mock.Anything, testClientID).Return(&models.OAuthClient{
+		ClientID:                testClientID,
+		Scopes:                  []string{"openid"},
+		GrantTypes:              []string{oauth.GrantAuthorizationCode},
+		TokenEndpointAuthMethod: oauth.AuthMethodNone,
+	}, nil)
+
+	_, err := suite.oauthService.DeviceAuthorization(context.Background(), dto.DeviceAuthorizationRequest{ClientID: testClientID})
+	suite.Equal(oauth.ErrorUnauthorizedClient, suite.oauthError(err).Code)
+
+	_, err = suite.oauthService.DeviceAuthorization(context.Background(), dto.DeviceAuthorizationRequest{
+		ClientID: testDeviceClientID,
+		Scope:    "admin",
+	})
+	suite.Equal(oauth.ErrorInvalidScope, suite.oauthError(err).Code)
+
+	suite.mockOAuthRepo.AssertNotCalled(suite.T(), "CreateDeviceCode", mock.Anything, mock.Anything)
+}
+
+func (suite *OAuthDeviceTestSuite) TestPollBeforeApprovalIsPending() {
+	suite.deviceCode()
+	suite.mockOAuthRepo.On("UpdateDeviceCodePoll", mock.Anything, uint(11), mock.Anything, 5).Return(nil)
+
+	_, err := suite.oauthService.Token(context.Background(), suite.pollRequest())
+
+	suite.Equal(oauth.ErrorAuthorizationPending, suite.oauthError(err).Code)
+	suite.Equal(400, suite.oauthError(err).Status)
+}
+
+func (suite *OAuthDeviceTestSuite) TestPollingTooFastSlowsDown() {
+	code := suite.deviceCode()
+	lastPolledAt := time.Now().Add(-2 * time.Second)
+	code.LastPolledAt = &lastPolledAt
+	suite.mockOAuthRepo.On("UpdateDeviceCodePoll", mock.Anything, uint(11), mock.Anything, 10).Return(nil)
+
+	_, err := suite.oauthService.Token(context.Background(), suite.pollRequest())
+
+	suite.Equal(oauth.ErrorSlowDown, suite.oauthError(err).Code)
+	suite.mockOAuthRepo.AssertExpectations(suite.T())
+}
+
+func (suite *OAuthDeviceTestSuite) TestPollRejectsDeniedExpiredAndForeignCodes() {
+	code := suite.deviceCode()
+	suite.mockOAuthRepo.On("UpdateDeviceCodePoll", mock.Anything, uint(11), mock.Anything, 5).Return(nil)
+
+	deniedAt := time.Now()
+	code.DeniedAt = &deniedAt
+	_, err := suite.oauthService.Token(context.Background(), suite.pollRequest())
+	suite.Equal(oauth.ErrorAccessDenied, suite.oauthError(err).Code)
+
+	code.ExpiresAt = time.Now().Add(-time.Second)
+	_, err = suite.oauthService.Token(context.Background(), suite.pollRequest())
+	suite.Equal(oauth.ErrorExpiredToken, suite.oauthError(err).Code)
+
+	code.ClientID = "another-client"
+	_, err = suite.oauthService.Token(context.Background(), suite.pollRequest())
+	suite.Equal(oauth.ErrorInvalidGrant, suite.oauthError(err).Code)
+
+	suite.mockOAuthRepo.On("GetDeviceCodeByHash", mock.Anything, oauth.HashToken("unknown")).Return(nil, gorm.ErrRecordNotFound)
+	req := suite.pollRequest()
+	req.DeviceCode = "unknown"
+	_, err = suite.oauthService.Token(context.Background(), req)
+	suite.Equal(oauth.ErrorInvalidGrant, suite.oauthError(err).Code)
+}
+
+func (suite *OAuthDeviceTestSuite) TestPollAfterApprovalIssuesTokensOnce() {
+	code := suite.deviceCode()
+	suite.approve(code)
+	suite.mockOAuthRepo.On("UpdateDeviceCodePoll", mock.Anything, uint(11), mock.Anything, 5).Return(nil)
+	suite.mockOAuthRepo.On("ConsumeDeviceCode", mock.Anything, uint(11), mock.Anything).Return(true, nil).Once()
+	suite.mockOAuthRepo.On("CreateRefreshToken", mock.Anything, mock.MatchedBy(func(token *models.OAuthRefreshToken) bool {
+		return token.ClientID == testDeviceClientID && token.AccountID == 7 && token.SessionID == "session-id" &&
+			token.Scope == "openid profile" && token.AuthorizationCodeID == nil
+	})).Return(nil)
+
+	response, err := suite.oauthService.Token(context.Background(), suite.pollRequest())
+
+	suite.Require().NoError(err)
+	suite.NotEmpty(response.AccessToken)
+	suite.NotEmpty(response.RefreshToken)
+	suite.NotEmpty(response.IDToken, "the openid scope was granted")
+	suite.Equal("openid profile", response.Scope)
+
+	suite.mockOAuthRepo.On("ConsumeDeviceCode", mock.Anything, uint(11), mock.Anything).Return(false, nil)
+	_, err = suite.oauthService.Token(context.Background(), suite.pollRequest())
+	suite.Equal(oauth.ErrorInvalidGrant, suite.oauthError(err).Code)
+}
+
+func (suite *OAuthDeviceTestSuite) TestVerifyDeviceCode() {
+	suite.deviceCode()
+
+	result, err := suite.oauthService.VerifyDeviceCode(context.Background(), "", testUserCode)
+	suite.Require().NoError(err)
+	suite.True(result.LoginRequired)
+
+	suite.activeSession()
+
+	result, err = suite.oauthService.VerifyDeviceCode(context.Background(), testSessionToken, "")
+	suite.Require().NoError(err)
+	suite.Equal(&service.DeviceVerification{}, result, "without a code the user is asked for one")
+
+	result, err = suite.oauthService.VerifyDeviceCode(context.Background(), testSessionToken, "wdjb-mjht")
+	suite.Require().NoError(err)
+	suite.Equal(&service.DeviceVerification{
+		UserCode:   "WDJB-MJHT",
+		ClientName: "TV app",
+		Scopes:     []string{"openid", "profile"},
+	}, result)
+
+	_, err = suite.oauthService.VerifyDeviceCode(context.Background(), testSessionToken, "not a code")
+	suite.Equal(errors.ErrorTypeBadRequest, err.(*errors.AppError).Type)
+
+	suite.mockOAuthRepo.On("GetDeviceCodeByUserCode", mock.Anything, oauth.HashToken("BCDFGHJK")).Return(nil, gorm.ErrRecordNotFound)
+	_, err = suite.oauthService.VerifyDeviceCode(context.Background(), testSessionToken, "BCDF-GHJK")
+	suite.Equal(errors.ErrorTypeNotFound, err.(*errors.AppError).Type)
+}
+
+func (suite *OAuthDeviceTestSuite) TestVerifyDeviceCodeRejectsDecidedCode() {
+	code := suite.deviceCode()
+	suite.approve(code)
+	suite.activeSession()
+
+	_, err := suite.oauthService.VerifyDeviceCode(context.Background(), testSessionToken, testUserCode)
+
+	suite.Equal(errors.ErrorTypeNotFound, err.(*errors.AppError).Type)
+}
+
+func (suite *OAuthDeviceTestSuite) TestApproveDeviceCode() {
+	suite.deviceCode()
+	suite.activeSession()
+	suite.mockOAuthRepo.On("DecideDeviceCode", mock.Anything,
+		mock.MatchedBy(func(code *models.OAuthDeviceCode) bool {
+			return code.ApprovedAt != nil && code.DeniedAt == nil && code.AccountID != nil && *code.AccountID == 7 &&
+				code.SessionID == "session-id" && code.AuthTime != nil
+		}),
+		mock.MatchedBy(func(grant *models.OAuthGrant) bool {
+			return grant.AccountID == 7 && grant.ClientID == testDeviceClientID &&
+				slices.Equal([]string{"openid", "profile"}, grant.Scopes)
+		}),
+		mock.MatchedBy(func(event models.AuditEvent) bool {
+			return event.Action == models.AuditActionOAuthDeviceAuthorized && event.Outcome == models.AuditOutcomeSuccess
+		})).Return(true, nil)
+
+	result, err := suite.oauthService.DecideDeviceCode(context.Background(), testSessionToken, testUserCode, true)
+
+	suite.Require().NoError(err)
+	suite.Equal("TV app", result.ClientName)
+	suite.mockOAuthRepo.AssertExpectations(suite.T())
+}
+
+func (suite *OAuthDeviceTestSuite) TestDenyDeviceCode() {
+	suite.deviceCode()
+	suite.activeSession()
+	suite.mockOAuthRepo.On("DecideDeviceCode", mock.Anything,
+		mock.MatchedBy(func(code *models.OAuthDeviceCode) bool {
+			return code.DeniedAt != nil && code.ApprovedAt == nil && code.AccountID == nil
+		}),
+		mock.MatchedBy(func(grant *models.OAuthGrant) bool { return grant == nil }),
+		mock.MatchedBy(func(event models.AuditEvent) bool {
+			return event.Action == models.AuditActionOAuthDeviceAuthorized && event.Outcome == models.AuditOutcomeFailure
+		})).Return(true, nil)
+
+	_, err := suite.oauthService.DecideDeviceCode(context.Background(), testSessionToken, testUserCode, false)
+
+	suite.Require().NoError(err)
+	suite.mockOAuthRepo.AssertExpectations(suite.T())
+}
+
+func (suite *OAuthDeviceTestSuite) TestDecideDeviceCodeLosingRace() {
+	suite.deviceCode()
+	suite.activeSession()
+	suite.mockOAuthRepo.On("DecideDeviceCode", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+
+	_, err := suite.oauthService.DecideDeviceCode(context.Background(), testSessionToken, testUserCode, true)
+
+	suite.Equal(errors.ErrorTypeNotFound, err.(*errors.AppError).Type)
+}
+
+func TestOAuthDeviceSuite(t *testing.T) {
+	suite.Run(t, new(OAuthDeviceTestSuite))
+}
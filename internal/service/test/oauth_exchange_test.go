@@ -0,0 +1,253 @@
+package service
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/notification"
+	"github.com/ssoydabas/auth-service/internal/oauth"
+	"github.com/ssoydabas/auth-service/internal/service"
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/ssoydabas/auth-service/pkg/config"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+)
+
+const testExchangeClientID = "orders-service"
+
+type OAuthExchangeTestSuite struct {
+	suite.Suite
+	mockOAuthRepo   *MockOAuthRepository
+	mockAccountRepo *MockAccountRepository
+	mockAuditRepo   *MockAuditRepository
+	signingKey      *oauth.SigningKey
+	oauthService    service.OAuthService
+}
+
+func (suite *OAuthExchangeTestSuite) SetupTest() {
+	suite.mockOAuthRepo = new(MockOAuthRepository)
+	suite.mockAccountRepo = new(MockAccountRepository)
+	suite.mockAuditRepo = new(MockAuditRepository)
+	suite.mockAuditRepo.On("RecordAuditEvent", mock.Anything, mock.Anything).Return(nil).Maybe()
+
+	cfg := config.Config{
+		PublicBaseURL:       testPublicBaseURL,
+		OAuthAccessTokenTTL: time.Hour,
+		OAuthTokenExchangePolicy: map[string]string{
+			testExchangeClientID: "inventory-api billing-api",
+		},
+	}
+
+	templates, err := notification.NewRenderer("", "Auth Service")
+	suite.Require().NoError(err)
+	suite.signingKey, err = oauth.GenerateSigningKey()
+	suite.Require().NoError(err)
+	accountService := service.NewAccountService(suite.mockAccountRepo, new(MockRoleRepository), suite.mockAuditRepo, templates, cfg)
+	suite.oauthService = service.NewOAuthService(suite.mockOAuthRepo, suite.mockAccountRepo, suite.mockAuditRepo, accountService, suite.signingKey, cfg)
+
+	suite.mockOAuthRepo.On("GetOAuthClient", mock.Anything, testExchangeClientID).Return(&models.OAuthClient{
+		Model:                   gorm.Model{ID: 5},
+		ClientID:                testExchangeClientID,
+		Name:                    "Orders service",
+		RedirectURIs:            []string{},
+		Scopes:                  []string{"orders:read", "inventory:read"},
+		GrantTypes:              []string{oauth.GrantTokenExchange, oauth.GrantClientCredentials},
+		TokenEndpointAuthMethod: oauth.AuthMethodClientSecretBasic,
+		SecretHash:              oauth.HashToken(testClientSecret),
+	}, nil).Maybe()
+	suite.mockAccountRepo.On("GetAccountByID", mock.Anything, "7", false).
+		Return(&models.Account{Model: gorm.Model{ID: 7}, Email: "jane@example.com"}, nil).Maybe()
+}
+
+func (suite *OAuthExchangeTestSuite) token(claims jwt.MapClaims) string {
+	if _, ok := claims["exp"]; !ok {
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(os.Getenv("JWT_SECRET")))
+	suite.Require().NoError(err)
+	return token
+}
+
+// exchangedToken is a token of an earlier exchange.
+func (suite *OAuthExchangeTestSuite) exchangedToken(claims jwt.MapClaims) string {
+	if _, ok := claims["exp"]; !ok {
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+	}
+	token, err := suite.signingKey.SignType(claims, "at+jwt")
+	suite.Require().NoError(err)
+	return token
+}
+
+// claims verifies an exchanged token the way the services in its audience
+// do, with the published key set.
+func (suite *OAuthExchangeTestSuite) claims(token string) jwt.MapClaims {
+	claims := jwt.MapClaims{}
+	parsed, err := jwt.ParseWithClaims(token, claims, suite.oauthService.JWKS().Keyfunc)
+	suite.Require().NoError(err)
+	suite.Equal("at+jwt", parsed.Header["typ"])
+	return claims
+}
+
+func (suite *OAuthExchangeTestSuite) exchangeRequest(subjectToken string, audience ...string) dto.TokenRequest {
+	return dto.TokenRequest{
+		GrantType:        oauth.GrantTokenExchange,
+		ClientID:         testExchangeClientID,
+		ClientSecret:     testClientSecret,
+		BasicAuth:        true,
+		SubjectToken:     subjectToken,
+		SubjectTokenType: oauth.TokenTypeAccessToken,
+		Audience:         audience,
+	}
+}
+
+func (suite *OAuthExchangeTestSuite) oauthError(err error) *oauth.Error {
+	suite.Require().Error(err)
+	oauthErr, ok := err.(*oauth.Error)
+	suite.Require().True(ok, "expected an OAuth error, got %T", err)
+	return oauthErr
+}
+
+func (suite *OAuthExchangeTestSuite) TestTokenExchange() {
+	subjectExpiry := time.Now().Add(10 * time.Minute)
+	subject := suite.token(jwt.MapClaims{"sub": 7, "sid": "session-1", "exp": subjectExpiry.Unix()})
+
+	response, err := suite.oauthService.Token(context.Background(), suite.exchangeRequest(subject, "inventory-api"))
+
+	suite.Require().NoError(err)
+	suite.Equal(oauth.TokenTypeAccessToken, response.IssuedTokenType)
+	suite.Equal(oauth.TokenTypeBearer, response.TokenType)
+	suite.Empty(response.Scope, "a token of the service's own sign-in passes on no scope unless requested")
+	suite.Empty(response.RefreshToken)
+	suite.LessOrEqual(response.ExpiresIn, int64(10*60), "the exchanged token never outlives the subject token")
+
+	claims := suite.claims(response.AccessToken)
+	suite.Equal(float64(7), claims["sub"])
+	suite.Equal([]interface{}{"inventory-api"}, claims["aud"])
+	suite.Equal(testExchangeClientID, claims["client_id"])
+	suite.Equal("session-1", claims["sid"])
+	suite.Equal(map[string]interface{}{"sub": testExchangeClientID}, claims["act"])
+	suite.Equal(float64(subjectExpiry.Unix()), claims["exp"])
+
+	suite.mockAuditRepo.AssertCalled(suite.T(), "RecordAuditEvent", mock.Anything, mock.MatchedBy(func(event models.AuditEvent) bool {
+		return event.Action == models.AuditActionOAuthTokenExchanged
+	}))
+
+	_, err = jwt.Parse(response.AccessToken, func(token *jwt.Token) (interface{}, error) {
+		return []byte(os.Getenv("JWT_SECRET")), nil
+	})
+	suite.Error(err, "the service's secret does not verify exchanged tokens")
+}
+
+func (suite *OAuthExchangeTestSuite) TestTokenExchangeOfUnscopedToken() {
+	subject := suite.token(jwt.MapClaims{"sub": 7})
+
+	req := suite.exchangeRequest(subject, "inventory-api")
+	req.Scope = "inventory:read"
+	response, err := suite.oauthService.Token(context.Background(), req)
+	suite.Require().NoError(err)
+	suite.Equal("inventory:read", response.Scope)
+
+	req.Scope = "inventory:write"
+	_, err = suite.oauthService.Token(context.Background(), req)
+	suite.Equal(oauth.ErrorInvalidScope, suite.oauthError(err).Code)
+}
+
+func (suite *OAuthExchangeTestSuite) TestTokenExchangeDownScopes() {
+	subject := suite.token(jwt.MapClaims{"sub": 7, "client_id": testClientID, "scope": "openid orders:read"})
+
+	response, err := suite.oauthService.Token(context.Background(), suite.exchangeRequest(subject, "inventory-api"))
+	suite.Require().NoError(err)
+	suite.Equal("orders:read", response.Scope, "only scopes both the subject token and the client have are passed on")
+
+	req := suite.exchangeRequest(subject, "inventory-api")
+	req.Scope = "inventory:read"
+	_, err = suite.oauthService.Token(context.Background(), req)
+	suite.Equal(oauth.ErrorInvalidScope, suite.oauthError(err).Code)
+}
+
+func (suite *OAuthExchangeTestSuite) TestTokenExchangeNestsActors() {
+	subject := suite.exchangedToken(jwt.MapClaims{
+		"sub":       7,
+		"aud":       []string{testExchangeClientID},
+		"client_id": "gateway",
+		"act":       map[string]interface{}{"sub": "gateway"},
+	})
+	actor := suite.token(jwt.MapClaims{"sub": testExchangeClientID, "client_id": testExchangeClientID})
+
+	req := suite.exchangeRequest(subject)
+	req.Resource = []string{"billing-api"}
+	req.ActorToken = actor
+	req.ActorTokenType = oauth.TokenTypeAccessToken
+	response, err := suite.oauthService.Token(context.Background(), req)
+
+	suite.Require().NoError(err)
+	claims := suite.claims(response.AccessToken)
+	suite.Equal([]interface{}{"billing-api"}, claims["aud"])
+	suite.Equal(map[string]interface{}{
+		"sub": testExchangeClientID,
+		"act": map[string]interface{}{"sub": "gateway"},
+	}, claims["act"])
+}
+
+func (suite *OAuthExchangeTestSuite) TestTokenExchangeRejections() {
+	subject := suite.token(jwt.MapClaims{"sub": 7})
+
+	cases := []struct {
+		name   string
+		modify func(*dto.TokenRequest)
+		code   string
+	}{
+		{"audience missing", func(req *dto.TokenRequest) { req.Audience = nil }, oauth.ErrorInvalidRequest},
+		{"audience outside the policy", func(req *dto.TokenRequest) { req.Audience = []string{"admin-api"} }, oauth.ErrorInvalidTarget},
+		{"wrong subject token type", func(req *dto.TokenRequest) { req.SubjectTokenType = "urn:ietf:params:oauth:token-type:id_token" }, oauth.ErrorInvalidRequest},
+		{"refresh token requested", func(req *dto.TokenRequest) {
+			req.RequestedTokenType = "urn:ietf:params:oauth:token-type:refresh_token"
+		}, oauth.ErrorInvalidRequest},
+		{"invalid subject token", func(req *dto.TokenRequest) { req.SubjectToken = "not-a-token" }, oauth.ErrorInvalidGrant},
+		{"client token as subject", func(req *dto.TokenRequest) {
+			req.SubjectToken = suite.token(jwt.MapClaims{"sub": testServiceClientID, "client_id": testServiceClientID})
+		}, oauth.ErrorInvalidGrant},
+		{"impersonation token as subject", func(req *dto.TokenRequest) {
+			req.SubjectToken = suite.token(jwt.MapClaims{"sub": 7, "act": map[string]interface{}{"sub": 1}})
+		}, oauth.ErrorInvalidGrant},
+		{"subject token for another audience", func(req *dto.TokenRequest) {
+			req.SubjectToken = suite.exchangedToken(jwt.MapClaims{"sub": 7, "aud": []string{"billing-api"}})
+		}, oauth.ErrorInvalidGrant},
+		{"ID token as subject", func(req *dto.TokenRequest) {
+			token, err := suite.signingKey.Sign(jwt.MapClaims{"sub": 7, "aud": []string{testExchangeClientID}, "exp": time.Now().Add(time.Hour).Unix()})
+			suite.Require().NoError(err)
+			req.SubjectToken = token
+		}, oauth.ErrorInvalidGrant},
+		{"actor token of another client", func(req *dto.TokenRequest) {
+			req.ActorToken = suite.token(jwt.MapClaims{"sub": testServiceClientID, "client_id": testServiceClientID})
+			req.ActorTokenType = oauth.TokenTypeAccessToken
+		}, oauth.ErrorInvalidGrant},
+	}
+
+	for _, tc := range cases {
+		suite.Run(tc.name, func() {
+			req := suite.exchangeRequest(subject, "inventory-api")
+			tc.modify(&req)
+			_, err := suite.oauthService.Token(context.Background(), req)
+			suite.Equal(tc.code, suite.oauthError(err).Code)
+		})
+	}
+}
+
+func (suite *OAuthExchangeTestSuite) TestExchangedTokensAreNotAcceptedByTheService() {
+	subject := suite.token(jwt.MapClaims{"sub": 7})
+	response, err := suite.oauthService.Token(context.Background(), suite.exchangeRequest(subject, "inventory-api"))
+	suite.Require().NoError(err)
+
+	_, err = suite.oauthService.UserInfo(context.Background(), response.AccessToken)
+	suite.Error(err)
+}
+
+func TestOAuthExchangeSuite(t *testing.T) {
+	suite.Run(t, new(OAuthExchangeTestSuite))
+}
@@ -0,0 +1,223 @@
+package service
+
+import (
+	"context"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/notification"
+	"github.com/ssoydabas/auth-service/internal/oauth"
+	"github.com/ssoydabas/auth-service/internal/service"
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/ssoydabas/auth-service/pkg/config"
+	"github.com/ssoydabas/auth-service/pkg/errors"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+)
+
+const testConsentSession = "consent-session-token"
+
+type OAuthGrantTestSuite struct {
+	suite.Suite
+	mockOAuthRepo *MockOAuthRepository
+	mockAuditRepo *MockAuditRepository
+	oauthService  service.OAuthService
+}
+
+func (suite *OAuthGrantTestSuite) SetupTest() {
+	suite.mockOAuthRepo = new(MockOAuthRepository)
+	suite.mockAuditRepo = new(MockAuditRepository)
+	suite.mockAuditRepo.On("RecordAuditEvent", mock.Anything, mock.Anything).Return(nil).Maybe()
+	mockAccountRepo := new(MockAccountRepository)
+
+	cfg := config.Config{
+		PublicBaseURL:       testPublicBaseURL,
+		SessionTTL:          time.Hour,
+		OAuthCodeTTL:        time.Minute,
+		OAuthAccessTokenTTL: time.Hour,
+	}
+
+	templates, err := notification.NewRenderer("", "Auth Service")
+	suite.Require().NoError(err)
+	signingKey, err := oauth.GenerateSigningKey()
+	suite.Require().NoError(err)
+	accountService := service.NewAccountService(mockAccountRepo, new(MockRoleRepository), suite.mockAuditRepo, templates, cfg)
+	suite.oauthService = service.NewOAuthService(suite.mockOAuthRepo, mockAccountRepo, suite.mockAuditRepo, accountService, signingKey, cfg)
+
+	suite.mockOAuthRepo.On("GetOAuthClient", mock.Anything, testClientID).Return(&models.OAuthClient{
+		Model:                   gorm.Model{ID: 1},
+		ClientID:                testClientID,
+		Name:                    "Example SPA",
+		RedirectURIs:            []string{testRedirectURI},
+		Scopes:                  []string{"openid", "profile", "email"},
+		GrantTypes:              []string{oauth.GrantAuthorizationCode, oauth.GrantRefreshToken},
+		TokenEndpointAuthMethod: oauth.AuthMethodNone,
+	}, nil).Maybe()
+	suite.mockOAuthRepo.On("GetSessionByTokenHash", mock.Anything, oauth.HashToken(testConsentSession)).Return(&models.Session{
+		PublicID:  "session-id",
+		AccountID: 7,
+		AuthTime:  time.Now().Add(-time.Minute),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}, nil).Maybe()
+	mockAccountRepo.On("GetAccountByID", mock.Anything, "7", false).
+		Return(&models.Account{Model: gorm.Model{ID: 7}, Email: "jane@example.com"}, nil).Maybe()
+}
+
+func (suite *OAuthGrantTestSuite) authorizeRequest(scope string) dto.AuthorizeRequest {
+	return dto.AuthorizeRequest{
+		ResponseType:        oauth.ResponseTypeCode,
+		ClientID:            testClientID,
+		RedirectURI:         testRedirectURI,
+		Scope:               scope,
+		State:               "af0ifjsldkj",
+		CodeChallenge:       oauth.S256Challenge(testCodeVerifier),
+		CodeChallengeMethod: oauth.MethodS256,
+	}
+}
+
+// granted registers the scopes account 7 has already allowed the client.
+func (suite *OAuthGrantTestSuite) granted(scopes ...string) {
+	if len(scopes) == 0 {
+		suite.mockOAuthRepo.On("GetGrant", mock.Anything, uint(7), testClientID).Return(nil, gorm.ErrRecordNotFound)
+		return
+	}
+	suite.mockOAuthRepo.On("GetGrant", mock.Anything, uint(7), testClientID).Return(&models.OAuthGrant{
+		AccountID: 7,
+		ClientID:  testClientID,
+		Scopes:    scopes,
+	}, nil)
+}
+
+func (suite *OAuthGrantTestSuite) oauthError(err error) *oauth.Error {
+	suite.Require().Error(err)
+	oauthErr, ok := err.(*oauth.Error)
+	suite.Require().True(ok, "expected an OAuth error, got %T", err)
+	return oauthErr
+}
+
+func (suite *OAuthGrantTestSuite) TestAuthorizeAsksForConsentToNewScopes() {
+	suite.granted("openid", "profile")
+
+	result, err := suite.oauthService.Authorize(context.Background(), suite.authorizeRequest("openid profile email"), testConsentSession)
+
+	suite.Require().NoError(err)
+	suite.True(result.ConsentRequired)
+	suite.Equal("Example SPA", result.ClientName)
+	suite.Equal([]string{"email"}, result.Scopes, "only scopes not granted yet are asked for")
+	suite.Empty(result.RedirectURL)
+	suite.mockOAuthRepo.AssertNotCalled(suite.T(), "CreateAuthorizationCode", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *OAuthGrantTestSuite) TestAuthorizeWithoutGrantAsksForEveryScope() {
+	suite.granted()
+
+	result, err := suite.oauthService.Authorize(context.Background(), suite.authorizeRequest("openid profile"), testConsentSession)
+
+	suite.Require().NoError(err)
+	suite.True(result.ConsentRequired)
+	suite.Equal([]string{"openid", "profile"}, result.Scopes)
+}
+
+func (suite *OAuthGrantTestSuite) TestPromptNoneReturnsConsentRequired() {
+	suite.granted()
+	req := suite.authorizeRequest("profile")
+	req.Prompt = oauth.PromptNone
+
+	_, err := suite.oauthService.Authorize(context.Background(), req, testConsentSession)
+
+	oauthErr := suite.oauthError(err)
+	suite.Equal(oauth.ErrorConsentRequired, oauthErr.Code)
+	suite.True(oauthErr.Redirectable())
+}
+
+func (suite *OAuthGrantTestSuite) TestPromptConsentAsksDespiteGrant() {
+	suite.granted("openid", "profile", "email")
+	req := suite.authorizeRequest("profile")
+	req.Prompt = oauth.PromptConsent
+
+	result, err := suite.oauthService.Authorize(context.Background(), req, testConsentSession)
+
+	suite.Require().NoError(err)
+	suite.True(result.ConsentRequired)
+	suite.Equal([]string{"profile"}, result.Scopes)
+}
+
+func (suite *OAuthGrantTestSuite) TestConsentExtendsGrantAndIssuesCode() {
+	suite.granted("openid")
+	suite.mockOAuthRepo.On("SaveGrant", mock.Anything,
+		mock.MatchedBy(func(grant *models.OAuthGrant) bool {
+			return grant.AccountID == 7 && grant.ClientID == testClientID &&
+				slices.Equal([]string{"openid", "email"}, grant.Scopes)
+		}),
+		mock.MatchedBy(func(event models.AuditEvent) bool {
+			return event.Action == models.AuditActionOAuthConsentGranted && event.Outcome == models.AuditOutcomeSuccess
+		})).Return(nil)
+	suite.mockOAuthRepo.On("CreateAuthorizationCode", mock.Anything,
+		mock.MatchedBy(func(code *models.OAuthAuthorizationCode) bool { return code.Scope == "email" }),
+		mock.Anything).Return(nil)
+
+	result, err := suite.oauthService.Consent(context.Background(), suite.authorizeRequest("email"), testConsentSession, true)
+
+	suite.Require().NoError(err)
+	redirect, err := url.Parse(result.RedirectURL)
+	suite.Require().NoError(err)
+	suite.NotEmpty(redirect.Query().Get("code"))
+	suite.Equal("af0ifjsldkj", redirect.Query().Get("state"))
+	suite.mockOAuthRepo.AssertExpectations(suite.T())
+}
+
+func (suite *OAuthGrantTestSuite) TestConsentDeniedReturnsAccessDenied() {
+	_, err := suite.oauthService.Consent(context.Background(), suite.authorizeRequest("email"), testConsentSession, false)
+
+	oauthErr := suite.oauthError(err)
+	suite.Equal(oauth.ErrorAccessDenied, oauthErr.Code)
+	suite.True(oauthErr.Redirectable())
+	suite.mockOAuthRepo.AssertNotCalled(suite.T(), "SaveGrant", mock.Anything, mock.Anything, mock.Anything)
+	suite.mockAuditRepo.AssertCalled(suite.T(), "RecordAuditEvent", mock.Anything, mock.MatchedBy(func(event models.AuditEvent) bool {
+		return event.Action == models.AuditActionOAuthConsentGranted && event.Outcome == models.AuditOutcomeFailure
+	}))
+}
+
+func (suite *OAuthGrantTestSuite) TestConsentWithoutSessionRequiresLogin() {
+	result, err := suite.oauthService.Consent(context.Background(), suite.authorizeRequest("email"), "", true)
+
+	suite.Require().NoError(err)
+	suite.True(result.LoginRequired)
+	suite.mockOAuthRepo.AssertNotCalled(suite.T(), "SaveGrant", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *OAuthGrantTestSuite) TestListGrants() {
+	suite.mockOAuthRepo.On("GetOAuthClient", mock.Anything, "removed-client").Return(nil, gorm.ErrRecordNotFound)
+	suite.mockOAuthRepo.On("ListGrants", mock.Anything, uint(7)).Return([]models.OAuthGrant{
+		{AccountID: 7, ClientID: testClientID, Scopes: []string{"openid", "profile"}},
+		{AccountID: 7, ClientID: "removed-client", Scopes: []string{"email"}},
+	}, nil)
+
+	grants, err := suite.oauthService.ListGrants(context.Background(), 7)
+
+	suite.Require().NoError(err)
+	suite.Require().Len(grants, 2)
+	suite.Equal("Example SPA", grants[0].ClientName)
+	suite.Equal([]string{"openid", "profile"}, grants[0].Scopes)
+	suite.Empty(grants[1].ClientName)
+}
+
+func (suite *OAuthGrantTestSuite) TestRevokeGrant() {
+	suite.mockOAuthRepo.On("RevokeGrant", mock.Anything, uint(7), testClientID, mock.Anything,
+		mock.MatchedBy(func(event models.AuditEvent) bool { return event.Action == models.AuditActionOAuthGrantRevoked })).
+		Return(true, nil)
+	suite.mockOAuthRepo.On("RevokeGrant", mock.Anything, uint(7), "unknown", mock.Anything, mock.Anything).Return(false, nil)
+
+	suite.NoError(suite.oauthService.RevokeGrant(context.Background(), 7, testClientID))
+
+	err := suite.oauthService.RevokeGrant(context.Background(), 7, "unknown")
+	suite.Equal(errors.ErrorTypeNotFound, err.(*errors.AppError).Type)
+}
+
+func TestOAuthGrantSuite(t *testing.T) {
+	suite.Run(t, new(OAuthGrantTestSuite))
+}
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockOAuthRepository is a mock implementation of OAuthRepository
+type MockOAuthRepository struct {
+	mock.Mock
+}
+
+func (m *MockOAuthRepository) CreateOAuthClient(ctx context.Context, client *models.OAuthClient, audit models.AuditEvent) error {
+	args := m.Called(ctx, client, audit)
+	return args.Error(0)
+}
+
+func (m *MockOAuthRepository) GetOAuthClient(ctx context.Context, clientID string) (*models.OAuthClient, error) {
+	args := m.Called(ctx, clientID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.OAuthClient), args.Error(1)
+}
+
+func (m *MockOAuthRepository) ListOAuthClients(ctx context.Context) ([]models.OAuthClient, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.OAuthClient), args.Error(1)
+}
+
+func (m *MockOAuthRepository) UpdateOAuthClient(ctx context.Context, id uint, changes map[string]interface{}, audit models.AuditEvent) error {
+	args := m.Called(ctx, id, changes, audit)
+	return args.Error(0)
+}
+
+func (m *MockOAuthRepository) ReplaceOAuthClientMetadata(ctx context.Context, client *models.OAuthClient, audit models.AuditEvent) error {
+	args := m.Called(ctx, client, audit)
+	return args.Error(0)
+}
+
+func (m *MockOAuthRepository) DisableOAuthClient(ctx context.Context, clientID string, disabledAt time.Time, audit models.AuditEvent) error {
+	args := m.Called(ctx, clientID, disabledAt, audit)
+	return args.Error(0)
+}
+
+func (m *MockOAuthRepository) RecordClientAssertion(ctx context.Context, assertion *models.OAuthClientAssertion) (bool, error) {
+	args := m.Called(ctx, assertion)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockOAuthRepository) CreateInitialAccessToken(ctx context.Context, token *models.OAuthInitialAccessToken, audit models.AuditEvent) error {
+	args := m.Called(ctx, token, audit)
+	return args.Error(0)
+}
+
+func (m *MockOAuthRepository) GetInitialAccessToken(ctx context.Context, tokenHash string) (*models.OAuthInitialAccessToken, error) {
+	args := m.Called(ctx, tokenHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.OAuthInitialAccessToken), args.Error(1)
+}
+
+func (m *MockOAuthRepository) RegisterOAuthClient(ctx context.Context, client *models.OAuthClient, initialTokenHash string, registeredAt time.Time, audit models.AuditEvent) (bool, error) {
+	args := m.Called(ctx, client, initialTokenHash, registeredAt, audit)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockOAuthRepository) CreateSession(ctx context.Context, session *models.Session) error {
+	args := m.Called(ctx, session)
+	return args.Error(0)
+}
+
+func (m *MockOAuthRepository) GetSessionByTokenHash(ctx context.Context, tokenHash string) (*models.Session, error) {
+	args := m.Called(ctx, tokenHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Session), args.Error(1)
+}
+
+func (m *MockOAuthRepository) GetSessionByPublicID(ctx context.Context, publicID string) (*models.Session, error) {
+	args := m.Called(ctx, publicID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Session), args.Error(1)
+}
+
+func (m *MockOAuthRepository) ListSessions(ctx context.Context, accountID uint) ([]models.Session, error) {
+	args := m.Called(ctx, accountID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Session), args.Error(1)
+}
+
+func (m *MockOAuthRepository) ListSessionClients(ctx context.Context, sessionID string) ([]models.OAuthClient, error) {
+	args := m.Called(ctx, sessionID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.OAuthClient), args.Error(1)
+}
+
+func (m *MockOAuthRepository) RevokeSession(ctx context.Context, sessionID string, revokedAt time.Time, audit models.AuditEvent, outbox ...models.OutboxMessage) (bool, error) {
+	args := m.Called(ctx, sessionID, revokedAt, audit, outbox)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockOAuthRepository) CreateAuthorizationCode(ctx context.Context, code *models.OAuthAuthorizationCode, audit models.AuditEvent) error {
+	args := m.Called(ctx, code, audit)
+	return args.Error(0)
+}
+
+func (m *MockOAuthRepository) ConsumeAuthorizationCode(ctx context.Context, codeHash string, usedAt time.Time) (*models.OAuthAuthorizationCode, bool, error) {
+	args := m.Called(ctx, codeHash, usedAt)
+	if args.Get(0) == nil {
+		return nil, args.Bool(1), args.Error(2)
+	}
+	return args.Get(0).(*models.OAuthAuthorizationCode), args.Bool(1), args.Error(2)
+}
+
+func (m *MockOAuthRepository) CreateRefreshToken(ctx context.Context, token *models.OAuthRefreshToken) error {
+	args := m.Called(ctx, token)
+	return args.Error(0)
+}
+
+func (m *MockOAuthRepository) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*models.OAuthRefreshToken, error) {
+	args := m.Called(ctx, tokenHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.OAuthRefreshToken), args.Error(1)
+}
+
+func (m *MockOAuthRepository) RotateRefreshToken(ctx context.Context, id uint, next *models.OAuthRefreshToken, revokedAt time.Time) (bool, error) {
+	args := m.Called(ctx, id, next, revokedAt)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockOAuthRepository) RevokeRefreshTokenFamily(ctx context.Context, familyID string, revokedAt time.Time, audit models.AuditEvent) error {
+	args := m.Called(ctx, familyID, revokedAt, audit)
+	return args.Error(0)
+}
+
+func (m *MockOAuthRepository) RevokeRefreshTokensByAuthorizationCode(ctx context.Context, codeID uint, revokedAt time.Time) error {
+	args := m.Called(ctx, codeID, revokedAt)
+	return args.Error(0)
+}
+
+func (m *MockOAuthRepository) CreateDeviceCode(ctx context.Context, code *models.OAuthDeviceCode) error {
+	args := m.Called(ctx, code)
+	return args.Error(0)
+}
+
+func (m *MockOAuthRepository) GetDeviceCodeByHash(ctx context.Context, deviceCodeHash string) (*models.OAuthDeviceCode, error) {
+	args := m.Called(ctx, deviceCodeHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.OAuthDeviceCode), args.Error(1)
+}
+
+func (m *MockOAuthRepository) GetDeviceCodeByUserCode(ctx context.Context, userCodeHash string) (*models.OAuthDeviceCode, error) {
+	args := m.Called(ctx, userCodeHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.OAuthDeviceCode), args.Error(1)
+}
+
+func (m *MockOAuthRepository) UpdateDeviceCodePoll(ctx context.Context, id uint, polledAt time.Time, pollInterval int) error {
+	args := m.Called(ctx, id, polledAt, pollInterval)
+	return args.Error(0)
+}
+
+func (m *MockOAuthRepository) DecideDeviceCode(ctx context.Context, code *models.OAuthDeviceCode, grant *models.OAuthGrant, audit models.AuditEvent) (bool, error) {
+	args := m.Called(ctx, code, grant, audit)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockOAuthRepository) ConsumeDeviceCode(ctx context.Context, id uint, usedAt time.Time) (bool, error) {
+	args := m.Called(ctx, id, usedAt)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockOAuthRepository) GetGrant(ctx context.Context, accountID uint, clientID string) (*models.OAuthGrant, error) {
+	args := m.Called(ctx, accountID, clientID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.OAuthGrant), args.Error(1)
+}
+
+func (m *MockOAuthRepository) ListGrants(ctx context.Context, accountID uint) ([]models.OAuthGrant, error) {
+	args := m.Called(ctx, accountID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.OAuthGrant), args.Error(1)
+}
+
+func (m *MockOAuthRepository) SaveGrant(ctx context.Context, grant *models.OAuthGrant, audit models.AuditEvent) error {
+	args := m.Called(ctx, grant, audit)
+	return args.Error(0)
+}
+
+func (m *MockOAuthRepository) RevokeGrant(ctx context.Context, accountID uint, clientID string, revokedAt time.Time, audit models.AuditEvent) (bool, error) {
+	args := m.Called(ctx, accountID, clientID, revokedAt, audit)
+	return args.Bool(0), args.Error(1)
+}
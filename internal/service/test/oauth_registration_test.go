@@ -0,0 +1,380 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/notification"
+	"github.com/ssoydabas/auth-service/internal/oauth"
+	"github.com/ssoydabas/auth-service/internal/service"
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/ssoydabas/auth-service/pkg/config"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+)
+
+const (
+	testInitialAccessToken = "the-initial-access-token"
+	testRegistrationToken  = "the-registration-access-token"
+	testRegisteredClientID = "registered-client"
+)
+
+type OAuthRegistrationTestSuite struct {
+	suite.Suite
+	mockOAuthRepo *MockOAuthRepository
+	clientKey     *oauth.SigningKey
+	oauthService  service.OAuthService
+}
+
+func (suite *OAuthRegistrationTestSuite) SetupTest() {
+	suite.mockOAuthRepo = new(MockOAuthRepository)
+	mockAuditRepo := new(MockAuditRepository)
+	mockAuditRepo.On("RecordAuditEvent", mock.Anything, mock.Anything).Return(nil).Maybe()
+	mockAccountRepo := new(MockAccountRepository)
+
+	cfg := config.Config{
+		PublicBaseURL:              testPublicBaseURL,
+		OAuthAccessTokenTTL:        time.Hour,
+		OAuthInitialAccessTokenTTL: 24 * time.Hour,
+		OAuthRegistrationScopes:    []string{"openid", "profile", "invoices:read"},
+	}
+
+	templates, err := notification.NewRenderer("", "Auth Service")
+	suite.Require().NoError(err)
+	signingKey, err := oauth.GenerateSigningKey()
+	suite.Require().NoError(err)
+	suite.clientKey, err = oauth.GenerateSigningKey()
+	suite.Require().NoError(err)
+	accountService := service.NewAccountService(mockAccountRepo, new(MockRoleRepository), mockAuditRepo, templates, cfg)
+	suite.oauthService = service.NewOAuthService(suite.mockOAuthRepo, mockAccountRepo, mockAuditRepo, accountService, signingKey, cfg)
+
+	suite.mockOAuthRepo.On("GetInitialAccessToken", mock.Anything, oauth.HashToken(testInitialAccessToken)).Return(&models.OAuthInitialAccessToken{
+		ID:        4,
+		TokenHash: oauth.HashToken(testInitialAccessToken),
+		CreatedBy: 1,
+		ExpiresAt: time.Now().Add(time.Hour),
+	}, nil).Maybe()
+	suite.mockOAuthRepo.On("GetInitialAccessToken", mock.Anything, oauth.HashToken("unknown-token")).Return(nil, gorm.ErrRecordNotFound).Maybe()
+}
+
+// registeredClient stores a client registered through the registration
+// endpoint that authenticates with method.
+func (suite *OAuthRegistrationTestSuite) registeredClient(method string) *models.OAuthClient {
+	client := &models.OAuthClient{
+		Model:                   gorm.Model{ID: 9, CreatedAt: time.Now()},
+		ClientID:                testRegisteredClientID,
+		Name:                    "Reporting",
+		RedirectURIs:            []string{testRedirectURI},
+		Scopes:                  []string{"openid", "profile"},
+		GrantTypes:              []string{oauth.GrantAuthorizationCode},
+		TokenEndpointAuthMethod: method,
+		RegistrationTokenHash:   oauth.HashToken(testRegistrationToken),
+	}
+	if method == oauth.AuthMethodClientSecretBasic {
+		client.SecretHash = oauth.HashToken(testClientSecret)
+	}
+	suite.mockOAuthRepo.On("GetOAuthClient", mock.Anything, testRegisteredClientID).Return(client, nil)
+	return client
+}
+
+func (suite *OAuthRegistrationTestSuite) oauthError(err error) *oauth.Error {
+	suite.Require().Error(err)
+	oauthErr, ok := err.(*oauth.Error)
+	suite.Require().True(ok, "expected an OAuth error, got %T", err)
+	return oauthErr
+}
+
+func (suite *OAuthRegistrationTestSuite) TestIssueInitialAccessToken() {
+	var stored *models.OAuthInitialAccessToken
+	suite.mockOAuthRepo.On("CreateInitialAccessToken", mock.Anything, mock.Anything,
+		mock.MatchedBy(func(event models.AuditEvent) bool {
+			return event.Action == models.AuditActionOAuthInitialTokenIssued && *event.ActorID == 1
+		})).
+		Run(func(args mock.Arguments) { stored = args.Get(1).(*models.OAuthInitialAccessToken) }).
+		Return(nil)
+
+	token, err := suite.oauthService.IssueInitialAccessToken(context.Background(), 1, dto.CreateInitialAccessTokenRequest{Description: "Reporting team"})
+
+	suite.Require().NoError(err)
+	suite.Require().NotNil(stored)
+	suite.Equal(oauth.HashToken(token.Token), stored.TokenHash, "only the hash of the token is stored")
+	suite.Equal("Reporting team", stored.Description)
+	suite.Equal(uint(1), stored.CreatedBy)
+	suite.WithinDuration(time.Now().Add(24*time.Hour), stored.ExpiresAt, time.Minute)
+}
+
+func (suite *OAuthRegistrationTestSuite) TestRegisterClientWithDefaults() {
+	var stored *models.OAuthClient
+	suite.mockOAuthRepo.On("RegisterOAuthClient", mock.Anything, mock.Anything, oauth.HashToken(testInitialAccessToken), mock.Anything,
+		mock.MatchedBy(func(event models.AuditEvent) bool {
+			return event.Action == models.AuditActionOAuthClientCreated && *event.ActorID == 1
+		})).
+		Run(func(args mock.Arguments) { stored = args.Get(1).(*models.OAuthClient) }).
+		Return(true, nil)
+
+	response, err := suite.oauthService.RegisterClient(context.Background(), testInitialAccessToken, dto.ClientRegistrationRequest{
+		ClientName:   "Reporting",
+		RedirectURIs: []string{testRedirectURI},
+		Scope:        "openid profile",
+	})
+
+	suite.Require().NoError(err)
+	suite.Require().NotNil(stored)
+	suite.Equal(oauth.AuthMethodClientSecretBasic, response.TokenEndpointAuthMethod, "RFC 7591 defaults to client_secret_basic")
+	suite.Equal([]string{oauth.GrantAuthorizationCode}, response.GrantTypes)
+	suite.Equal([]string{oauth.ResponseTypeCode}, response.ResponseTypes)
+	suite.Equal("openid profile", response.Scope)
+	suite.Equal(testPublicBaseURL+"/oauth/register/"+stored.ClientID, response.RegistrationClientURI)
+	suite.Equal(oauth.HashToken(response.ClientSecret), stored.SecretHash)
+	suite.Equal(oauth.HashToken(response.RegistrationAccessToken), stored.RegistrationTokenHash)
+	suite.Require().NotNil(response.ClientSecretExpiresAt)
+	suite.Zero(*response.ClientSecretExpiresAt, "secrets do not expire")
+}
+
+func (suite *OAuthRegistrationTestSuite) TestRegisterClientDefaultsScope() {
+	suite.mockOAuthRepo.On("RegisterOAuthClient", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(true, nil)
+
+	response, err := suite.oauthService.RegisterClient(context.Background(), testInitialAccessToken, dto.ClientRegistrationRequest{
+		ClientName:              "Invoice sync",
+		GrantTypes:              []string{oauth.GrantClientCredentials},
+		TokenEndpointAuthMethod: oauth.AuthMethodClientSecretPost,
+	})
+
+	suite.Require().NoError(err)
+	suite.Equal("openid profile invoices:read", response.Scope)
+	suite.Empty(response.ResponseTypes)
+}
+
+func (suite *OAuthRegistrationTestSuite) TestRegisterClientRequiresInitialAccessToken() {
+	request := dto.ClientRegistrationRequest{ClientName: "Reporting", RedirectURIs: []string{testRedirectURI}}
+
+	_, err := suite.oauthService.RegisterClient(context.Background(), "", request)
+	suite.Equal(oauth.ErrorInvalidToken, suite.oauthError(err).Code)
+
+	_, err = suite.oauthService.RegisterClient(context.Background(), "unknown-token", request)
+	suite.Equal(oauth.ErrorInvalidToken, suite.oauthError(err).Code)
+
+	// A token used up by a concurrent registration is refused as well.
+	suite.mockOAuthRepo.On("RegisterOAuthClient", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+	_, err = suite.oauthService.RegisterClient(context.Background(), testInitialAccessToken, request)
+	suite.Equal(oauth.ErrorInvalidToken, suite.oauthError(err).Code)
+}
+
+func (suite *OAuthRegistrationTestSuite) TestRegisterClientRefusesUsedToken() {
+	usedAt := time.Now()
+	suite.mockOAuthRepo.On("GetInitialAccessToken", mock.Anything, oauth.HashToken("used-token")).Return(&models.OAuthInitialAccessToken{
+		CreatedBy: 1,
+		ExpiresAt: time.Now().Add(time.Hour),
+		UsedAt:    &usedAt,
+	}, nil)
+	suite.mockOAuthRepo.On("GetInitialAccessToken", mock.Anything, oauth.HashToken("expired-token")).Return(&models.OAuthInitialAccessToken{
+		CreatedBy: 1,
+		ExpiresAt: time.Now().Add(-time.Minute),
+	}, nil)
+
+	request := dto.ClientRegistrationRequest{ClientName: "Reporting", RedirectURIs: []string{testRedirectURI}}
+	for _, token := range []string{"used-token", "expired-token"} {
+		_, err := suite.oauthService.RegisterClient(context.Background(), token, request)
+		suite.Equal(oauth.ErrorInvalidToken, suite.oauthError(err).Code, token)
+	}
+	suite.mockOAuthRepo.AssertNotCalled(suite.T(), "RegisterOAuthClient", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *OAuthRegistrationTestSuite) TestRegisterClientValidatesMetadata() {
+	jwks := suite.clientKey.JWKS()
+
+	cases := []struct {
+		name string
+		req  dto.ClientRegistrationRequest
+		code string
+	}{
+		{"plain http redirect URI", dto.ClientRegistrationRequest{ClientName: "Reporting", RedirectURIs: []string{"http://app.example.com/callback"}}, oauth.ErrorInvalidRedirectURI},
+		{"no redirect URI", dto.ClientRegistrationRequest{ClientName: "Reporting"}, oauth.ErrorInvalidClientMetadata},
+		{"no name", dto.ClientRegistrationRequest{RedirectURIs: []string{testRedirectURI}}, oauth.ErrorInvalidClientMetadata},
+		{"token exchange", dto.ClientRegistrationRequest{ClientName: "Reporting", GrantTypes: []string{oauth.GrantTokenExchange}}, oauth.ErrorInvalidClientMetadata},
+		{"implicit response type", dto.ClientRegistrationRequest{ClientName: "Reporting", RedirectURIs: []string{testRedirectURI}, ResponseTypes: []string{"token"}}, oauth.ErrorInvalidClientMetadata},
+		{"code without its grant", dto.ClientRegistrationRequest{ClientName: "Reporting", GrantTypes: []string{oauth.GrantClientCredentials}, ResponseTypes: []string{oauth.ResponseTypeCode}}, oauth.ErrorInvalidClientMetadata},
+		{"scope beyond the registrable scopes", dto.ClientRegistrationRequest{ClientName: "Reporting", RedirectURIs: []string{testRedirectURI}, Scope: "openid admin"}, oauth.ErrorInvalidClientMetadata},
+		{"private_key_jwt without keys", dto.ClientRegistrationRequest{ClientName: "Reporting", GrantTypes: []string{oauth.GrantClientCredentials}, TokenEndpointAuthMethod: oauth.AuthMethodPrivateKeyJWT}, oauth.ErrorInvalidClientMetadata},
+		{"jwks and jwks_uri", dto.ClientRegistrationRequest{ClientName: "Reporting", GrantTypes: []string{oauth.GrantClientCredentials}, TokenEndpointAuthMethod: oauth.AuthMethodPrivateKeyJWT, JWKS: &jwks, JWKSURI: "https://app.example.com/jwks"}, oauth.ErrorInvalidClientMetadata},
+		{"plain http jwks_uri", dto.ClientRegistrationRequest{ClientName: "Reporting", GrantTypes: []string{oauth.GrantClientCredentials}, TokenEndpointAuthMethod: oauth.AuthMethodPrivateKeyJWT, JWKSURI: "http://app.example.com/jwks"}, oauth.ErrorInvalidClientMetadata},
+		{"jwks_uri with a secret", dto.ClientRegistrationRequest{ClientName: "Reporting", GrantTypes: []string{oauth.GrantClientCredentials}, JWKSURI: "https://app.example.com/jwks"}, oauth.ErrorInvalidClientMetadata},
+	}
+
+	for _, tc := range cases {
+		suite.Run(tc.name, func() {
+			_, err := suite.oauthService.RegisterClient(context.Background(), testInitialAccessToken, tc.req)
+			suite.Equal(tc.code, suite.oauthError(err).Code)
+		})
+	}
+	suite.mockOAuthRepo.AssertNotCalled(suite.T(), "RegisterOAuthClient", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *OAuthRegistrationTestSuite) TestReadRegistration() {
+	suite.registeredClient(oauth.AuthMethodClientSecretBasic)
+
+	response, err := suite.oauthService.GetClientRegistration(context.Background(), testRegisteredClientID, testRegistrationToken)
+	suite.Require().NoError(err)
+	suite.Equal(testRegisteredClientID, response.ClientID)
+	suite.Equal("Reporting", response.ClientName)
+	suite.Equal(testRegistrationToken, response.RegistrationAccessToken)
+	suite.Empty(response.ClientSecret, "the secret cannot be read back")
+
+	_, err = suite.oauthService.GetClientRegistration(context.Background(), testRegisteredClientID, "wrong-token")
+	suite.Equal(oauth.ErrorInvalidToken, suite.oauthError(err).Code)
+}
+
+func (suite *OAuthRegistrationTestSuite) TestRegistrationOfOtherClientsIsHidden() {
+	suite.mockOAuthRepo.On("GetOAuthClient", mock.Anything, "unknown").Return(nil, gorm.ErrRecordNotFound)
+	suite.mockOAuthRepo.On("GetOAuthClient", mock.Anything, testClientID).Return(&models.OAuthClient{
+		ClientID: testClientID,
+		Name:     "Registered by an administrator",
+	}, nil)
+
+	for _, clientID := range []string{"unknown", testClientID} {
+		_, err := suite.oauthService.GetClientRegistration(context.Background(), clientID, testRegistrationToken)
+		suite.Equal(oauth.ErrorInvalidToken, suite.oauthError(err).Code, clientID)
+	}
+}
+
+func (suite *OAuthRegistrationTestSuite) TestUpdateRegistrationReplacesMetadata() {
+	suite.registeredClient(oauth.AuthMethodClientSecretBasic)
+	var stored *models.OAuthClient
+	suite.mockOAuthRepo.On("ReplaceOAuthClientMetadata", mock.Anything, mock.Anything,
+		mock.MatchedBy(func(event models.AuditEvent) bool { return event.Action == models.AuditActionOAuthClientUpdated })).
+		Run(func(args mock.Arguments) { stored = args.Get(1).(*models.OAuthClient) }).
+		Return(nil)
+
+	response, err := suite.oauthService.UpdateClientRegistration(context.Background(), testRegisteredClientID, testRegistrationToken, dto.ClientRegistrationRequest{
+		ClientID:                testRegisteredClientID,
+		ClientName:              "Reporting (mobile)",
+		RedirectURIs:            []string{"com.example.reporting:/callback"},
+		GrantTypes:              []string{oauth.GrantAuthorizationCode, oauth.GrantRefreshToken},
+		TokenEndpointAuthMethod: oauth.AuthMethodNone,
+	})
+
+	suite.Require().NoError(err)
+	suite.Require().NotNil(stored)
+	suite.Equal("Reporting (mobile)", stored.Name)
+	suite.Equal([]string{"com.example.reporting:/callback"}, stored.RedirectURIs)
+	suite.Equal([]string{"openid", "profile", "invoices:read"}, stored.Scopes, "left out fields take their defaults")
+	suite.Empty(stored.SecretHash, "a public client holds no secret")
+	suite.Equal(oauth.HashToken(testRegistrationToken), stored.RegistrationTokenHash)
+	suite.Nil(response.ClientSecretExpiresAt)
+}
+
+func (suite *OAuthRegistrationTestSuite) TestUpdateRegistrationIssuesSecretToNewConfidentialClient() {
+	suite.registeredClient(oauth.AuthMethodNone)
+	var stored *models.OAuthClient
+	suite.mockOAuthRepo.On("ReplaceOAuthClientMetadata", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { stored = args.Get(1).(*models.OAuthClient) }).
+		Return(nil)
+
+	response, err := suite.oauthService.UpdateClientRegistration(context.Background(), testRegisteredClientID, testRegistrationToken, dto.ClientRegistrationRequest{
+		ClientID:     testRegisteredClientID,
+		ClientName:   "Reporting",
+		RedirectURIs: []string{testRedirectURI},
+	})
+
+	suite.Require().NoError(err)
+	suite.NotEmpty(response.ClientSecret)
+	suite.Equal(oauth.HashToken(response.ClientSecret), stored.SecretHash)
+}
+
+func (suite *OAuthRegistrationTestSuite) TestUpdateRegistrationRejections() {
+	suite.registeredClient(oauth.AuthMethodClientSecretBasic)
+	base := dto.ClientRegistrationRequest{ClientName: "Reporting", RedirectURIs: []string{testRedirectURI}}
+
+	otherClient := base
+	otherClient.ClientID = "another-client"
+	wrongSecret := base
+	wrongSecret.ClientID = testRegisteredClientID
+	wrongSecret.ClientSecret = "not-the-secret"
+
+	for name, req := range map[string]dto.ClientRegistrationRequest{"other client_id": otherClient, "wrong secret": wrongSecret} {
+		_, err := suite.oauthService.UpdateClientRegistration(context.Background(), testRegisteredClientID, testRegistrationToken, req)
+		suite.Equal(oauth.ErrorInvalidRequest, suite.oauthError(err).Code, name)
+	}
+	suite.mockOAuthRepo.AssertNotCalled(suite.T(), "ReplaceOAuthClientMetadata", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *OAuthRegistrationTestSuite) TestDeleteRegistrationDisablesClient() {
+	client := suite.registeredClient(oauth.AuthMethodClientSecretBasic)
+	suite.mockOAuthRepo.On("DisableOAuthClient", mock.Anything, testRegisteredClientID, mock.Anything,
+		mock.MatchedBy(func(event models.AuditEvent) bool { return event.Action == models.AuditActionOAuthClientDisabled })).
+		Run(func(args mock.Arguments) {
+			disabledAt := args.Get(2).(time.Time)
+			client.DisabledAt = &disabledAt
+		}).
+		Return(nil)
+
+	err := suite.oauthService.DeleteClientRegistration(context.Background(), testRegisteredClientID, testRegistrationToken)
+	suite.Require().NoError(err)
+
+	_, err = suite.oauthService.GetClientRegistration(context.Background(), testRegisteredClientID, testRegistrationToken)
+	suite.Equal(oauth.ErrorInvalidToken, suite.oauthError(err).Code, "the registration access token stops working")
+}
+
+func (suite *OAuthRegistrationTestSuite) TestPrivateKeyJWTWithJWKSURI() {
+	available := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !available {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		suite.NoError(json.NewEncoder(w).Encode(suite.clientKey.JWKS()))
+	}))
+	defer server.Close()
+
+	suite.mockOAuthRepo.On("GetOAuthClient", mock.Anything, testServiceClientID).Return(&models.OAuthClient{
+		ClientID:                testServiceClientID,
+		Name:                    "Billing job",
+		RedirectURIs:            []string{},
+		Scopes:                  []string{"invoices:read"},
+		GrantTypes:              []string{oauth.GrantClientCredentials},
+		TokenEndpointAuthMethod: oauth.AuthMethodPrivateKeyJWT,
+		JWKSURI:                 server.URL + "/jwks",
+	}, nil)
+	suite.mockOAuthRepo.On("RecordClientAssertion", mock.Anything, mock.Anything).Return(true, nil)
+
+	request := func() dto.TokenRequest {
+		assertion, err := suite.clientKey.Sign(jwt.MapClaims{
+			"iss": testServiceClientID,
+			"sub": testServiceClientID,
+			"aud": testPublicBaseURL + "/oauth/token",
+			"jti": uuid.New().String(),
+			"exp": time.Now().Add(time.Minute).Unix(),
+		})
+		suite.Require().NoError(err)
+		return dto.TokenRequest{
+			GrantType:           oauth.GrantClientCredentials,
+			ClientAssertionType: oauth.ClientAssertionTypeJWTBearer,
+			ClientAssertion:     assertion,
+		}
+	}
+
+	response, err := suite.oauthService.Token(context.Background(), request())
+	suite.Require().NoError(err)
+	suite.NotEmpty(response.AccessToken)
+
+	available = false
+	_, err = suite.oauthService.Token(context.Background(), request())
+	suite.Equal(oauth.ErrorInvalidClient, suite.oauthError(err).Code)
+}
+
+func (suite *OAuthRegistrationTestSuite) TestDiscoveryAdvertisesRegistration() {
+	suite.Equal(testPublicBaseURL+"/oauth/register", suite.oauthService.Discovery().RegistrationEndpoint)
+}
+
+func TestOAuthRegistrationSuite(t *testing.T) {
+	suite.Run(t, new(OAuthRegistrationTestSuite))
+}
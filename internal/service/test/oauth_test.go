@@ -0,0 +1,726 @@
+package service
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/notification"
+	"github.com/ssoydabas/auth-service/internal/oauth"
+	"github.com/ssoydabas/auth-service/internal/service"
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/ssoydabas/auth-service/pkg/config"
+	"github.com/ssoydabas/auth-service/pkg/errors"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+)
+
+const (
+	testClientID     = "spa-client"
+	testRedirectURI  = "https://app.example.com/callback"
+	testCodeVerifier = "dBjftJeZ4CVP-mJ92K1s-DhgS5bE8f7eGW7gNZM0rUY"
+)
+
+type OAuthServiceTestSuite struct {
+	suite.Suite
+	mockOAuthRepo   *MockOAuthRepository
+	mockAccountRepo *MockAccountRepository
+	mockAuditRepo   *MockAuditRepository
+	signingKey      *oauth.SigningKey
+	accountService  service.AccountService
+	oauthService    service.OAuthService
+}
+
+func (suite *OAuthServiceTestSuite) SetupSuite() {
+	key, err := oauth.GenerateSigningKey()
+	suite.Require().NoError(err)
+	suite.signingKey = key
+}
+
+func (suite *OAuthServiceTestSuite) SetupTest() {
+	suite.mockOAuthRepo = new(MockOAuthRepository)
+	suite.mockAccountRepo = new(MockAccountRepository)
+	suite.mockAuditRepo = new(MockAuditRepository)
+	suite.mockAuditRepo.On("RecordAuditEvent", mock.Anything, mock.Anything).Return(nil).Maybe()
+
+	cfg := config.Config{
+		PublicBaseURL:        testPublicBaseURL,
+		SessionTTL:           24 * time.Hour,
+		OAuthCodeTTL:         time.Minute,
+		OAuthAccessTokenTTL:  time.Hour,
+		OAuthRefreshTokenTTL: 30 * 24 * time.Hour,
+	}
+
+	templates, err := notification.NewRenderer("", "Auth Service")
+	suite.Require().NoError(err)
+	suite.accountService = service.NewAccountService(suite.mockAccountRepo, new(MockRoleRepository), suite.mockAuditRepo, templates, cfg)
+	suite.oauthService = service.NewOAuthService(suite.mockOAuthRepo, suite.mockAccountRepo, suite.mockAuditRepo, suite.accountService, suite.signingKey, cfg)
+
+	suite.mockOAuthRepo.On("GetOAuthClient", mock.Anything, testClientID).Return(&models.OAuthClient{
+		Model:                   gorm.Model{ID: 1},
+		ClientID:                testClientID,
+		Name:                    "Example SPA",
+		RedirectURIs:            []string{testRedirectURI},
+		Scopes:                  []string{"openid", "profile", "email", "phone"},
+		GrantTypes:              []string{oauth.GrantAuthorizationCode, oauth.GrantRefreshToken},
+		TokenEndpointAuthMethod: oauth.AuthMethodNone,
+	}, nil).Maybe()
+	suite.mockOAuthRepo.On("GetOAuthClient", mock.Anything, mock.Anything).Return(nil, gorm.ErrRecordNotFound).Maybe()
+	// Account 7 has allowed the client every scope, so its authorization
+	// requests complete without asking for consent.
+	suite.mockOAuthRepo.On("GetGrant", mock.Anything, uint(7), testClientID).Return(&models.OAuthGrant{
+		AccountID: 7,
+		ClientID:  testClientID,
+		Scopes:    []string{"openid", "profile", "email", "phone"},
+	}, nil).Maybe()
+}
+
+func (suite *OAuthServiceTestSuite) authorizeRequest() dto.AuthorizeRequest {
+	return dto.AuthorizeRequest{
+		ResponseType:        oauth.ResponseTypeCode,
+		ClientID:            testClientID,
+		RedirectURI:         testRedirectURI,
+		Scope:               "profile",
+		State:               "af0ifjsldkj",
+		CodeChallenge:       oauth.S256Challenge(testCodeVerifier),
+		CodeChallengeMethod: oauth.MethodS256,
+	}
+}
+
+func (suite *OAuthServiceTestSuite) expectAccount(id uint) {
+	suite.mockAccountRepo.On("GetAccountByID", mock.Anything, strconv.FormatUint(uint64(id), 10), false).
+		Return(&models.Account{Model: gorm.Model{ID: id}, Email: "jane@example.com"}, nil).Maybe()
+}
+
+func (suite *OAuthServiceTestSuite) activeSession(token string) *models.Session {
+	session := &models.Session{
+		PublicID:  "session-id",
+		TokenHash: oauth.HashToken(token),
+		AccountID: 7,
+		AuthTime:  time.Now().Add(-time.Minute),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	suite.mockOAuthRepo.On("GetSessionByTokenHash", mock.Anything, oauth.HashToken(token)).Return(session, nil)
+	return session
+}
+
+func (suite *OAuthServiceTestSuite) redirectQuery(redirectURL string) url.Values {
+	u, err := url.Parse(redirectURL)
+	suite.Require().NoError(err)
+	suite.Equal("app.example.com", u.Host)
+	suite.Equal("/callback", u.Path)
+	return u.Query()
+}
+
+func (suite *OAuthServiceTestSuite) oauthError(err error) *oauth.Error {
+	suite.Require().Error(err)
+	oauthErr, ok := err.(*oauth.Error)
+	suite.Require().True(ok, "expected an OAuth error, got %T", err)
+	return oauthErr
+}
+
+func (suite *OAuthServiceTestSuite) TestAuthorizeRejectsUnknownClientWithoutRedirect() {
+	req := suite.authorizeRequest()
+	req.ClientID = "unknown"
+
+	_, err := suite.oauthService.Authorize(context.Background(), req, "")
+
+	oauthErr := suite.oauthError(err)
+	suite.Equal(oauth.ErrorInvalidClient, oauthErr.Code)
+	suite.False(oauthErr.Redirectable())
+}
+
+func (suite *OAuthServiceTestSuite) TestAuthorizeRejectsUnregisteredRedirectURI() {
+	req := suite.authorizeRequest()
+	req.RedirectURI = "https://evil.example.com/callback"
+
+	_, err := suite.oauthService.Authorize(context.Background(), req, "")
+
+	oauthErr := suite.oauthError(err)
+	suite.Equal(oauth.ErrorInvalidRequest, oauthErr.Code)
+	suite.False(oauthErr.Redirectable(), "an unregistered redirect URI must never be redirected to")
+}
+
+func (suite *OAuthServiceTestSuite) TestAuthorizeRequiresPKCE() {
+	for _, method := range []string{"", "plain"} {
+		req := suite.authorizeRequest()
+		req.CodeChallengeMethod = method
+
+		_, err := suite.oauthService.Authorize(context.Background(), req, "")
+
+		oauthErr := suite.oauthError(err)
+		suite.Equal(oauth.ErrorInvalidRequest, oauthErr.Code)
+		suite.Equal(testRedirectURI, oauthErr.RedirectURI)
+		suite.Equal("af0ifjsldkj", oauthErr.State)
+	}
+}
+
+func (suite *OAuthServiceTestSuite) TestAuthorizeRejectsScopeBeyondClient() {
+	req := suite.authorizeRequest()
+	req.Scope = "profile admin"
+
+	_, err := suite.oauthService.Authorize(context.Background(), req, "")
+
+	oauthErr := suite.oauthError(err)
+	suite.Equal(oauth.ErrorInvalidScope, oauthErr.Code)
+	suite.True(oauthErr.Redirectable())
+}
+
+func (suite *OAuthServiceTestSuite) TestAuthorizeWithoutSessionRequiresLogin() {
+	result, err := suite.oauthService.Authorize(context.Background(), suite.authorizeRequest(), "")
+
+	suite.Require().NoError(err)
+	suite.True(result.LoginRequired)
+	suite.Equal("Example SPA", result.ClientName)
+	suite.mockOAuthRepo.AssertNotCalled(suite.T(), "CreateAuthorizationCode", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *OAuthServiceTestSuite) TestAuthorizeWithSessionIssuesCode() {
+	suite.activeSession("session-token")
+	suite.expectAccount(7)
+
+	var stored *models.OAuthAuthorizationCode
+	suite.mockOAuthRepo.On("CreateAuthorizationCode", mock.Anything, mock.Anything,
+		mock.MatchedBy(func(event models.AuditEvent) bool {
+			return event.Action == models.AuditActionOAuthAuthorized && *event.ActorID == 7
+		})).
+		Run(func(args mock.Arguments) { stored = args.Get(1).(*models.OAuthAuthorizationCode) }).
+		Return(nil)
+
+	result, err := suite.oauthService.Authorize(context.Background(), suite.authorizeRequest(), "session-token")
+
+	suite.Require().NoError(err)
+	suite.False(result.LoginRequired)
+	query := suite.redirectQuery(result.RedirectURL)
+	suite.Equal("af0ifjsldkj", query.Get("state"))
+	suite.Equal(testPublicBaseURL, query.Get("iss"))
+	suite.NotEmpty(query.Get("code"))
+
+	suite.Require().NotNil(stored)
+	suite.Equal(oauth.HashToken(query.Get("code")), stored.CodeHash, "only the hash of the code is stored")
+	suite.Equal(testClientID, stored.ClientID)
+	suite.Equal(uint(7), stored.AccountID)
+	suite.Equal("session-id", stored.SessionID)
+	suite.Equal("profile", stored.Scope)
+	suite.WithinDuration(time.Now().Add(time.Minute), stored.ExpiresAt, 5*time.Second)
+}
+
+func (suite *OAuthServiceTestSuite) TestAuthorizeIgnoresExpiredSession() {
+	session := suite.activeSession("session-token")
+	session.ExpiresAt = time.Now().Add(-time.Minute)
+
+	result, err := suite.oauthService.Authorize(context.Background(), suite.authorizeRequest(), "session-token")
+
+	suite.Require().NoError(err)
+	suite.True(result.LoginRequired)
+}
+
+func (suite *OAuthServiceTestSuite) TestAuthorizeCopiesOpenIDParametersToCode() {
+	session := suite.activeSession("session-token")
+	session.AMR = []string{oauth.AMRPassword}
+	suite.expectAccount(7)
+
+	var stored *models.OAuthAuthorizationCode
+	suite.mockOAuthRepo.On("CreateAuthorizationCode", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { stored = args.Get(1).(*models.OAuthAuthorizationCode) }).
+		Return(nil)
+
+	req := suite.authorizeRequest()
+	req.Scope = "openid profile"
+	req.Nonce = "n-0S6_WzA2Mj"
+	_, err := suite.oauthService.Authorize(context.Background(), req, "session-token")
+
+	suite.Require().NoError(err)
+	suite.Require().NotNil(stored)
+	suite.Equal("n-0S6_WzA2Mj", stored.Nonce)
+	suite.Equal(session.AuthTime, stored.AuthTime)
+	suite.Equal([]string{oauth.AMRPassword}, stored.AMR)
+}
+
+func (suite *OAuthServiceTestSuite) TestAuthorizePromptNoneWithoutSession() {
+	req := suite.authorizeRequest()
+	req.Prompt = oauth.PromptNone
+
+	_, err := suite.oauthService.Authorize(context.Background(), req, "")
+
+	oauthErr := suite.oauthError(err)
+	suite.Equal(oauth.ErrorLoginRequired, oauthErr.Code)
+	suite.Equal(testRedirectURI, oauthErr.RedirectURI)
+}
+
+func (suite *OAuthServiceTestSuite) TestAuthorizePromptLoginIgnoresSession() {
+	suite.activeSession("session-token")
+	suite.expectAccount(7)
+
+	req := suite.authorizeRequest()
+	req.Prompt = oauth.PromptLogin
+	result, err := suite.oauthService.Authorize(context.Background(), req, "session-token")
+
+	suite.Require().NoError(err)
+	suite.True(result.LoginRequired)
+}
+
+func (suite *OAuthServiceTestSuite) TestAuthorizeMaxAgeRequiresRecentLogin() {
+	session := suite.activeSession("session-token")
+	session.AuthTime = time.Now().Add(-10 * time.Minute)
+	suite.expectAccount(7)
+
+	req := suite.authorizeRequest()
+	req.MaxAge = "300"
+	result, err := suite.oauthService.Authorize(context.Background(), req, "session-token")
+
+	suite.Require().NoError(err)
+	suite.True(result.LoginRequired)
+}
+
+func (suite *OAuthServiceTestSuite) TestAuthorizeRejectsInvalidPromptAndMaxAge() {
+	req := suite.authorizeRequest()
+	req.Prompt = "select_account"
+	_, err := suite.oauthService.Authorize(context.Background(), req, "")
+	suite.Equal(oauth.ErrorInvalidRequest, suite.oauthError(err).Code)
+
+	req = suite.authorizeRequest()
+	req.MaxAge = "-1"
+	_, err = suite.oauthService.Authorize(context.Background(), req, "")
+	suite.Equal(oauth.ErrorInvalidRequest, suite.oauthError(err).Code)
+}
+
+func (suite *OAuthServiceTestSuite) TestLoginStartsSessionAndIssuesCode() {
+	account := &models.Account{Model: gorm.Model{ID: 7}, Email: "jane@example.com"}
+	suite.mockAccountRepo.On("GetAccountByEmailOrPhone", mock.Anything, "jane@example.com", "").Return(account, nil)
+	suite.mockAccountRepo.On("GetAccountPasswordByAccountID", mock.Anything, uint(7)).
+		Return(&models.AccountPassword{Password: service.HashPassword("correctpassword")}, nil)
+	suite.mockAccountRepo.On("UpdateLastLoginAt", mock.Anything, uint(7), mock.Anything,
+		mock.MatchedBy(func(event models.AuditEvent) bool { return event.Action == models.AuditActionAccountLogin })).
+		Return(nil)
+
+	var session *models.Session
+	suite.mockOAuthRepo.On("CreateSession", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { session = args.Get(1).(*models.Session) }).
+		Return(nil)
+	suite.mockOAuthRepo.On("CreateAuthorizationCode", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	result, err := suite.oauthService.Login(context.Background(), suite.authorizeRequest(), dto.AuthenticateAccountRequest{
+		Email:    "jane@example.com",
+		Password: "correctpassword",
+	})
+
+	suite.Require().NoError(err)
+	suite.NotEmpty(result.SessionToken)
+	suite.Require().NotNil(session)
+	suite.Equal(oauth.HashToken(result.SessionToken), session.TokenHash)
+	suite.Equal(uint(7), session.AccountID)
+	suite.Equal(session.ExpiresAt, result.SessionExpiresAt)
+	suite.Equal([]string{oauth.AMRPassword}, session.AMR)
+	suite.NotEmpty(suite.redirectQuery(result.RedirectURL).Get("code"))
+}
+
+func (suite *OAuthServiceTestSuite) TestLoginRejectsWrongPassword() {
+	account := &models.Account{Model: gorm.Model{ID: 7}, Email: "jane@example.com"}
+	suite.mockAccountRepo.On("GetAccountByEmailOrPhone", mock.Anything, "jane@example.com", "").Return(account, nil)
+	suite.mockAccountRepo.On("GetAccountPasswordByAccountID", mock.Anything, uint(7)).
+		Return(&models.AccountPassword{Password: service.HashPassword("correctpassword")}, nil)
+
+	_, err := suite.oauthService.Login(context.Background(), suite.authorizeRequest(), dto.AuthenticateAccountRequest{
+		Email:    "jane@example.com",
+		Password: "wrongpassword",
+	})
+
+	suite.Equal(errors.AuthError("Invalid credentials"), err)
+	suite.mockOAuthRepo.AssertNotCalled(suite.T(), "CreateSession", mock.Anything, mock.Anything)
+}
+
+func (suite *OAuthServiceTestSuite) authorizationCode(used bool) *models.OAuthAuthorizationCode {
+	code := &models.OAuthAuthorizationCode{
+		Model:               gorm.Model{ID: 11},
+		CodeHash:            oauth.HashToken("the-code"),
+		ClientID:            testClientID,
+		AccountID:           7,
+		SessionID:           "session-id",
+		RedirectURI:         testRedirectURI,
+		Scope:               "profile",
+		CodeChallenge:       oauth.S256Challenge(testCodeVerifier),
+		CodeChallengeMethod: oauth.MethodS256,
+		ExpiresAt:           time.Now().Add(time.Minute),
+	}
+	suite.mockOAuthRepo.On("ConsumeAuthorizationCode", mock.Anything, oauth.HashToken("the-code"), mock.Anything).Return(code, !used, nil)
+	return code
+}
+
+func (suite *OAuthServiceTestSuite) codeTokenRequest() dto.TokenRequest {
+	return dto.TokenRequest{
+		GrantType:    oauth.GrantAuthorizationCode,
+		ClientID:     testClientID,
+		Code:         "the-code",
+		RedirectURI:  testRedirectURI,
+		CodeVerifier: testCodeVerifier,
+	}
+}
+
+func (suite *OAuthServiceTestSuite) TestTokenExchangesAuthorizationCode() {
+	suite.authorizationCode(false)
+	suite.expectAccount(7)
+
+	var refresh *models.OAuthRefreshToken
+	suite.mockOAuthRepo.On("CreateRefreshToken", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { refresh = args.Get(1).(*models.OAuthRefreshToken) }).
+		Return(nil)
+
+	response, err := suite.oauthService.Token(context.Background(), suite.codeTokenRequest())
+
+	suite.Require().NoError(err)
+	suite.Equal(oauth.TokenTypeBearer, response.TokenType)
+	suite.Equal(int64(3600), response.ExpiresIn)
+	suite.Equal("profile", response.Scope)
+
+	suite.Require().NotNil(refresh)
+	suite.Equal(oauth.HashToken(response.RefreshToken), refresh.TokenHash)
+	suite.Equal(uint(11), *refresh.AuthorizationCodeID)
+	suite.NotEmpty(refresh.FamilyID)
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(response.AccessToken, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(os.Getenv("JWT_SECRET")), nil
+	})
+	suite.Require().NoError(err)
+	suite.Equal(float64(7), claims["sub"])
+	suite.Equal(testPublicBaseURL, claims["iss"])
+	suite.Equal(testClientID, claims["client_id"])
+	suite.Equal("profile", claims["scope"])
+	suite.Equal("session-id", claims["sid"])
+	suite.Empty(response.IDToken, "no ID token without the openid scope")
+}
+
+func (suite *OAuthServiceTestSuite) TestAccessTokenIsRefusedByAccountEndpoints() {
+	suite.authorizationCode(false)
+	suite.expectAccount(7)
+	suite.mockOAuthRepo.On("CreateRefreshToken", mock.Anything, mock.Anything).Return(nil)
+
+	response, err := suite.oauthService.Token(context.Background(), suite.codeTokenRequest())
+	suite.Require().NoError(err)
+
+	// /accounts/me and the other endpoints behind the guard resolve the
+	// bearer token with GetAccountByToken.
+	_, err = suite.accountService.GetAccountByToken(context.Background(), response.AccessToken)
+	suite.Error(err)
+
+	_, err = suite.oauthService.UserInfo(context.Background(), suite.sessionToken())
+	suite.Equal(oauth.ErrorInvalidToken, suite.oauthError(err).Code, "userinfo refuses the service's own tokens")
+}
+
+func (suite *OAuthServiceTestSuite) TestTokenIssuesIDTokenForOpenIDScope() {
+	code := suite.authorizationCode(false)
+	code.Scope = "openid profile"
+	code.Nonce = "n-0S6_WzA2Mj"
+	code.AuthTime = time.Now().Add(-time.Minute).Truncate(time.Second)
+	code.AMR = []string{oauth.AMRPassword}
+	suite.expectAccount(7)
+	suite.mockOAuthRepo.On("CreateRefreshToken", mock.Anything, mock.Anything).Return(nil)
+
+	response, err := suite.oauthService.Token(context.Background(), suite.codeTokenRequest())
+
+	suite.Require().NoError(err)
+	suite.Require().NotEmpty(response.IDToken)
+
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(response.IDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		return suite.signingKey.PublicKey(), nil
+	}, jwt.WithValidMethods([]string{oauth.SigningAlgorithm}))
+	suite.Require().NoError(err)
+	suite.Equal(suite.signingKey.KeyID(), token.Header["kid"])
+	suite.Equal("7", claims["sub"])
+	suite.Equal(testPublicBaseURL, claims["iss"])
+	suite.Equal(testClientID, claims["aud"])
+	suite.Equal("n-0S6_WzA2Mj", claims["nonce"])
+	suite.Equal(float64(code.AuthTime.Unix()), claims["auth_time"])
+	suite.Equal([]interface{}{"pwd"}, claims["amr"])
+	suite.Equal(oauth.TokenHash(response.AccessToken), claims["at_hash"])
+}
+
+func (suite *OAuthServiceTestSuite) TestRefreshIssuesIDTokenWithoutNonce() {
+	token := suite.refreshToken(false)
+	token.Scope = "openid profile"
+	token.AuthTime = time.Now().Add(-time.Hour).Truncate(time.Second)
+	suite.expectAccount(7)
+
+	var next *models.OAuthRefreshToken
+	suite.mockOAuthRepo.On("RotateRefreshToken", mock.Anything, uint(21), mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { next = args.Get(2).(*models.OAuthRefreshToken) }).
+		Return(true, nil)
+
+	response, err := suite.oauthService.Token(context.Background(), suite.refreshRequest(""))
+
+	suite.Require().NoError(err)
+	suite.Require().NotNil(next)
+	suite.Equal(token.AuthTime, next.AuthTime, "the sign-in time carries over to the rotated token")
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(response.IDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		return suite.signingKey.PublicKey(), nil
+	})
+	suite.Require().NoError(err)
+	suite.Equal(float64(token.AuthTime.Unix()), claims["auth_time"])
+	suite.NotContains(claims, "nonce")
+}
+
+func (suite *OAuthServiceTestSuite) TestTokenRejectsWrongCodeVerifier() {
+	suite.authorizationCode(false)
+
+	req := suite.codeTokenRequest()
+	req.CodeVerifier = "a-different-verifier-that-is-long-enough-to-be-valid"
+	_, err := suite.oauthService.Token(context.Background(), req)
+
+	suite.Equal(oauth.ErrorInvalidGrant, suite.oauthError(err).Code)
+	suite.mockOAuthRepo.AssertNotCalled(suite.T(), "CreateRefreshToken", mock.Anything, mock.Anything)
+}
+
+func (suite *OAuthServiceTestSuite) TestTokenRejectsMismatchedRedirectURI() {
+	suite.authorizationCode(false)
+
+	req := suite.codeTokenRequest()
+	req.RedirectURI = "https://app.example.com/other"
+	_, err := suite.oauthService.Token(context.Background(), req)
+
+	suite.Equal(oauth.ErrorInvalidGrant, suite.oauthError(err).Code)
+}
+
+func (suite *OAuthServiceTestSuite) TestTokenRejectsExpiredCode() {
+	code := suite.authorizationCode(false)
+	code.ExpiresAt = time.Now().Add(-time.Second)
+
+	_, err := suite.oauthService.Token(context.Background(), suite.codeTokenRequest())
+
+	suite.Equal(oauth.ErrorInvalidGrant, suite.oauthError(err).Code)
+}
+
+func (suite *OAuthServiceTestSuite) TestTokenRevokesTokensWhenCodeIsReused() {
+	suite.authorizationCode(true)
+	suite.mockOAuthRepo.On("RevokeRefreshTokensByAuthorizationCode", mock.Anything, uint(11), mock.Anything).Return(nil)
+
+	_, err := suite.oauthService.Token(context.Background(), suite.codeTokenRequest())
+
+	suite.Equal(oauth.ErrorInvalidGrant, suite.oauthError(err).Code)
+	suite.mockOAuthRepo.AssertCalled(suite.T(), "RevokeRefreshTokensByAuthorizationCode", mock.Anything, uint(11), mock.Anything)
+	suite.mockAuditRepo.AssertCalled(suite.T(), "RecordAuditEvent", mock.Anything, mock.MatchedBy(func(event models.AuditEvent) bool {
+		return event.Action == models.AuditActionOAuthTokenReused && event.Outcome == models.AuditOutcomeFailure
+	}))
+}
+
+func (suite *OAuthServiceTestSuite) TestTokenRejectsUnknownClientAndGrantType() {
+	req := suite.codeTokenRequest()
+	req.ClientID = "unknown"
+	_, err := suite.oauthService.Token(context.Background(), req)
+	oauthErr := suite.oauthError(err)
+	suite.Equal(oauth.ErrorInvalidClient, oauthErr.Code)
+	suite.Equal(401, oauthErr.Status)
+
+	req = suite.codeTokenRequest()
+	req.GrantType = "password"
+	_, err = suite.oauthService.Token(context.Background(), req)
+	suite.Equal(oauth.ErrorUnsupportedGrantType, suite.oauthError(err).Code)
+}
+
+func (suite *OAuthServiceTestSuite) refreshToken(revoked bool) *models.OAuthRefreshToken {
+	codeID := uint(11)
+	token := &models.OAuthRefreshToken{
+		Model:               gorm.Model{ID: 21},
+		TokenHash:           oauth.HashToken("the-refresh-token"),
+		FamilyID:            "family-id",
+		AuthorizationCodeID: &codeID,
+		ClientID:            testClientID,
+		AccountID:           7,
+		SessionID:           "session-id",
+		Scope:               "profile email",
+		ExpiresAt:           time.Now().Add(time.Hour),
+	}
+	if revoked {
+		revokedAt := time.Now().Add(-time.Minute)
+		token.RevokedAt = &revokedAt
+	}
+	suite.mockOAuthRepo.On("GetRefreshTokenByHash", mock.Anything, oauth.HashToken("the-refresh-token")).Return(token, nil)
+	suite.mockOAuthRepo.On("GetSessionByPublicID", mock.Anything, "session-id").
+		Return(&models.Session{PublicID: "session-id", AccountID: 7, ExpiresAt: time.Now().Add(time.Hour)}, nil).Maybe()
+	return token
+}
+
+func (suite *OAuthServiceTestSuite) refreshRequest(scope string) dto.TokenRequest {
+	return dto.TokenRequest{
+		GrantType:    oauth.GrantRefreshToken,
+		ClientID:     testClientID,
+		RefreshToken: "the-refresh-token",
+		Scope:        scope,
+	}
+}
+
+func (suite *OAuthServiceTestSuite) TestRefreshRotatesToken() {
+	suite.refreshToken(false)
+	suite.expectAccount(7)
+
+	var next *models.OAuthRefreshToken
+	suite.mockOAuthRepo.On("RotateRefreshToken", mock.Anything, uint(21), mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { next = args.Get(2).(*models.OAuthRefreshToken) }).
+		Return(true, nil)
+
+	response, err := suite.oauthService.Token(context.Background(), suite.refreshRequest("email"))
+
+	suite.Require().NoError(err)
+	suite.Equal("email", response.Scope, "the access token can be narrowed")
+	suite.Require().NotNil(next)
+	suite.Equal(oauth.HashToken(response.RefreshToken), next.TokenHash)
+	suite.NotEqual("the-refresh-token", response.RefreshToken)
+	suite.Equal("family-id", next.FamilyID)
+	suite.Equal("profile email", next.Scope, "the refresh token keeps the granted scope")
+}
+
+func (suite *OAuthServiceTestSuite) TestRefreshRejectsWiderScope() {
+	suite.refreshToken(false)
+
+	_, err := suite.oauthService.Token(context.Background(), suite.refreshRequest("profile admin"))
+
+	suite.Equal(oauth.ErrorInvalidScope, suite.oauthError(err).Code)
+	suite.mockOAuthRepo.AssertNotCalled(suite.T(), "RotateRefreshToken", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *OAuthServiceTestSuite) TestRefreshReuseRevokesFamily() {
+	suite.refreshToken(true)
+	suite.mockOAuthRepo.On("RevokeRefreshTokenFamily", mock.Anything, "family-id", mock.Anything,
+		mock.MatchedBy(func(event models.AuditEvent) bool {
+			return event.Action == models.AuditActionOAuthTokenReused && event.Outcome == models.AuditOutcomeFailure && *event.TargetID == 7
+		})).Return(nil)
+
+	_, err := suite.oauthService.Token(context.Background(), suite.refreshRequest(""))
+
+	suite.Equal(oauth.ErrorInvalidGrant, suite.oauthError(err).Code)
+	suite.mockOAuthRepo.AssertExpectations(suite.T())
+}
+
+func (suite *OAuthServiceTestSuite) TestRefreshLosingRotationRaceRevokesFamily() {
+	suite.refreshToken(false)
+	suite.expectAccount(7)
+	suite.mockOAuthRepo.On("RotateRefreshToken", mock.Anything, uint(21), mock.Anything, mock.Anything).Return(false, nil)
+	suite.mockOAuthRepo.On("RevokeRefreshTokenFamily", mock.Anything, "family-id", mock.Anything, mock.Anything).Return(nil)
+
+	_, err := suite.oauthService.Token(context.Background(), suite.refreshRequest(""))
+
+	suite.Equal(oauth.ErrorInvalidGrant, suite.oauthError(err).Code)
+	suite.mockOAuthRepo.AssertCalled(suite.T(), "RevokeRefreshTokenFamily", mock.Anything, "family-id", mock.Anything, mock.Anything)
+}
+
+func (suite *OAuthServiceTestSuite) TestRefreshRejectsSuspendedAccount() {
+	suite.refreshToken(false)
+	suspendedAt := time.Now().Add(-time.Hour)
+	suite.mockAccountRepo.On("GetAccountByID", mock.Anything, "7", false).
+		Return(&models.Account{Model: gorm.Model{ID: 7}, SuspendedAt: &suspendedAt}, nil)
+
+	_, err := suite.oauthService.Token(context.Background(), suite.refreshRequest(""))
+
+	suite.Equal(oauth.ErrorInvalidGrant, suite.oauthError(err).Code)
+}
+
+func (suite *OAuthServiceTestSuite) TestCreateClient() {
+	var stored *models.OAuthClient
+	suite.mockOAuthRepo.On("CreateOAuthClient", mock.Anything, mock.Anything,
+		mock.MatchedBy(func(event models.AuditEvent) bool {
+			return event.Action == models.AuditActionOAuthClientCreated && *event.ActorID == 1
+		})).
+		Run(func(args mock.Arguments) { stored = args.Get(1).(*models.OAuthClient) }).
+		Return(nil)
+
+	client, err := suite.oauthService.CreateClient(context.Background(), 1, dto.CreateOAuthClientRequest{
+		Name:         "Mobile app",
+		RedirectURIs: []string{"com.example.app:/callback"},
+		Scopes:       []string{"profile", "profile", "email"},
+	})
+
+	suite.Require().NoError(err)
+	suite.Require().NotNil(stored)
+	suite.NotEmpty(client.ClientID)
+	suite.Equal(stored.ClientID, client.ClientID)
+	suite.Equal([]string{"profile", "email"}, client.Scopes)
+}
+
+func (suite *OAuthServiceTestSuite) accessToken(scope string) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":       7,
+		"client_id": testClientID,
+		"scope":     scope,
+		"exp":       time.Now().Add(time.Hour).Unix(),
+	})
+	token.Header["typ"] = "at+jwt"
+	signed, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	suite.Require().NoError(err)
+	return signed
+}
+
+// sessionToken is a token of account 7 like AuthenticateAccount issues.
+func (suite *OAuthServiceTestSuite) sessionToken() string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": 7,
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte(os.Getenv("JWT_SECRET")))
+	suite.Require().NoError(err)
+	return token
+}
+
+func (suite *OAuthServiceTestSuite) TestUserInfoFiltersClaimsByScope() {
+	suite.mockAccountRepo.On("GetAccountByID", mock.Anything, "7", false).Return(&models.Account{
+		Model:              gorm.Model{ID: 7, UpdatedAt: time.Unix(1700000000, 0)},
+		FirstName:          "Jane",
+		LastName:           "Doe",
+		Email:              "jane@example.com",
+		Phone:              "+15551234567",
+		VerificationStatus: "verified",
+	}, nil)
+
+	info, err := suite.oauthService.UserInfo(context.Background(), suite.accessToken("openid email"))
+
+	suite.Require().NoError(err)
+	suite.Equal(map[string]any{
+		"sub":            "7",
+		"email":          "jane@example.com",
+		"email_verified": true,
+	}, info)
+
+	info, err = suite.oauthService.UserInfo(context.Background(), suite.accessToken("openid profile phone"))
+
+	suite.Require().NoError(err)
+	suite.Equal("Jane", info["given_name"])
+	suite.Equal("Doe", info["family_name"])
+	suite.Equal("Jane Doe", info["name"])
+	suite.Equal(int64(1700000000), info["updated_at"])
+	suite.Equal("+15551234567", info["phone_number"])
+	suite.NotContains(info, "email")
+}
+
+func (suite *OAuthServiceTestSuite) TestUserInfoRequiresOpenIDScope() {
+	_, err := suite.oauthService.UserInfo(context.Background(), suite.accessToken("profile"))
+	oauthErr := suite.oauthError(err)
+	suite.Equal(oauth.ErrorInsufficientScope, oauthErr.Code)
+	suite.Equal(403, oauthErr.Status)
+
+	_, err = suite.oauthService.UserInfo(context.Background(), "not-a-token")
+	suite.Equal(oauth.ErrorInvalidToken, suite.oauthError(err).Code)
+}
+
+func (suite *OAuthServiceTestSuite) TestDiscovery() {
+	metadata := suite.oauthService.Discovery()
+
+	suite.Equal(testPublicBaseURL, metadata.Issuer)
+	suite.Equal(testPublicBaseURL+"/oauth/jwks", metadata.JWKSURI)
+	suite.Equal(testPublicBaseURL+"/oauth/userinfo", metadata.UserinfoEndpoint)
+	suite.Equal(testPublicBaseURL+"/oauth/device_authorization", metadata.DeviceAuthorizationEndpoint)
+	suite.Contains(metadata.GrantTypesSupported, oauth.GrantDeviceCode)
+	suite.Contains(metadata.ScopesSupported, oauth.ScopeOpenID)
+	suite.Equal([]string{oauth.SigningAlgorithm}, metadata.IDTokenSigningAlgValuesSupported)
+	suite.Equal(suite.signingKey.KeyID(), suite.oauthService.JWKS().Keys[0].KeyID)
+}
+
+func TestOAuthServiceSuite(t *testing.T) {
+	suite.Run(t, new(OAuthServiceTestSuite))
+}
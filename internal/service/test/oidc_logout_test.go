@@ -0,0 +1,258 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/notification"
+	"github.com/ssoydabas/auth-service/internal/oauth"
+	"github.com/ssoydabas/auth-service/internal/outbox"
+	"github.com/ssoydabas/auth-service/internal/service"
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/ssoydabas/auth-service/pkg/config"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+)
+
+const (
+	testLogoutSession     = "logout-session-token"
+	testPostLogoutURI     = "https://app.example.com/signed-out"
+	testBackchannelLogout = "https://app.example.com/backchannel-logout"
+)
+
+type OIDCLogoutTestSuite struct {
+	suite.Suite
+	mockOAuthRepo *MockOAuthRepository
+	signingKey    *oauth.SigningKey
+	oauthService  service.OAuthService
+}
+
+func (suite *OIDCLogoutTestSuite) SetupTest() {
+	suite.mockOAuthRepo = new(MockOAuthRepository)
+	mockAuditRepo := new(MockAuditRepository)
+	mockAuditRepo.On("RecordAuditEvent", mock.Anything, mock.Anything).Return(nil).Maybe()
+	mockAccountRepo := new(MockAccountRepository)
+
+	cfg := config.Config{
+		PublicBaseURL:       testPublicBaseURL,
+		SessionTTL:          time.Hour,
+		OAuthAccessTokenTTL: time.Hour,
+	}
+
+	templates, err := notification.NewRenderer("", "Auth Service")
+	suite.Require().NoError(err)
+	suite.signingKey, err = oauth.GenerateSigningKey()
+	suite.Require().NoError(err)
+	accountService := service.NewAccountService(mockAccountRepo, new(MockRoleRepository), mockAuditRepo, templates, cfg)
+	suite.oauthService = service.NewOAuthService(suite.mockOAuthRepo, mockAccountRepo, mockAuditRepo, accountService, suite.signingKey, cfg)
+
+	suite.mockOAuthRepo.On("GetOAuthClient", mock.Anything, testClientID).Return(&models.OAuthClient{
+		Model:                   gorm.Model{ID: 1},
+		ClientID:                testClientID,
+		Name:                    "Example SPA",
+		RedirectURIs:            []string{testRedirectURI},
+		PostLogoutRedirectURIs:  []string{testPostLogoutURI},
+		BackchannelLogoutURI:    testBackchannelLogout,
+		Scopes:                  []string{"openid", "profile"},
+		GrantTypes:              []string{oauth.GrantAuthorizationCode, oauth.GrantRefreshToken},
+		TokenEndpointAuthMethod: oauth.AuthMethodNone,
+	}, nil).Maybe()
+	suite.mockOAuthRepo.On("GetOAuthClient", mock.Anything, "unknown").Return(nil, gorm.ErrRecordNotFound).Maybe()
+	suite.mockOAuthRepo.On("GetSessionByTokenHash", mock.Anything, oauth.HashToken(testLogoutSession)).Return(&models.Session{
+		PublicID:  "session-id",
+		AccountID: 7,
+		AuthTime:  time.Now().Add(-time.Minute),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}, nil).Maybe()
+	mockAccountRepo.On("GetAccountByID", mock.Anything, "7", false).
+		Return(&models.Account{Model: gorm.Model{ID: 7}, Email: "jane@example.com"}, nil).Maybe()
+}
+
+// idToken signs an ID token of account 7 for the example client.
+func (suite *OIDCLogoutTestSuite) idToken(sessionID string, expiresAt time.Time) string {
+	token, err := suite.signingKey.Sign(jwt.MapClaims{
+		"iss": testPublicBaseURL,
+		"sub": "7",
+		"aud": testClientID,
+		"sid": sessionID,
+		"iat": expiresAt.Add(-time.Hour).Unix(),
+		"exp": expiresAt.Unix(),
+	})
+	suite.Require().NoError(err)
+	return token
+}
+
+func (suite *OIDCLogoutTestSuite) expectRevocation() {
+	suite.mockOAuthRepo.On("ListSessionClients", mock.Anything, "session-id").Return([]models.OAuthClient{
+		{ClientID: testClientID, BackchannelLogoutURI: testBackchannelLogout},
+		{ClientID: "cli", BackchannelLogoutURI: ""},
+	}, nil)
+	suite.mockOAuthRepo.On("RevokeSession", mock.Anything, "session-id", mock.Anything,
+		mock.MatchedBy(func(event models.AuditEvent) bool { return event.Action == models.AuditActionOAuthLogout }),
+		mock.MatchedBy(func(messages []models.OutboxMessage) bool {
+			if len(messages) != 1 || messages[0].Topic != outbox.TopicBackchannelLogout {
+				return false
+			}
+			var logout outbox.BackchannelLogout
+			if err := json.Unmarshal([]byte(messages[0].Payload), &logout); err != nil {
+				return false
+			}
+			return logout == outbox.BackchannelLogout{
+				ClientID:  testClientID,
+				LogoutURI: testBackchannelLogout,
+				Subject:   "7",
+				SessionID: "session-id",
+			}
+		})).Return(true, nil)
+}
+
+func (suite *OIDCLogoutTestSuite) oauthError(err error) *oauth.Error {
+	suite.Require().Error(err)
+	oauthErr, ok := err.(*oauth.Error)
+	suite.Require().True(ok, "expected an OAuth error, got %T", err)
+	return oauthErr
+}
+
+func (suite *OIDCLogoutTestSuite) TestLogoutWithHintEndsSession() {
+	suite.expectRevocation()
+
+	result, err := suite.oauthService.EndSession(context.Background(), dto.EndSessionRequest{
+		IDTokenHint:           suite.idToken("session-id", time.Now().Add(-time.Minute)),
+		PostLogoutRedirectURI: testPostLogoutURI,
+		State:                 "xyz",
+	}, testLogoutSession, false)
+
+	suite.Require().NoError(err)
+	suite.False(result.ConfirmationRequired)
+	redirect, err := url.Parse(result.RedirectURL)
+	suite.Require().NoError(err)
+	suite.Equal("app.example.com", redirect.Host)
+	suite.Equal("/signed-out", redirect.Path)
+	suite.Equal("xyz", redirect.Query().Get("state"))
+	suite.mockOAuthRepo.AssertExpectations(suite.T())
+}
+
+func (suite *OIDCLogoutTestSuite) TestRefreshAfterLogoutIsRejected() {
+	// The refresh token was issued during the session but missed by the
+	// revocation, for example because it was rotated at the same moment.
+	suite.expectRevocation()
+	session := &models.Session{PublicID: "session-id", AccountID: 7, ExpiresAt: time.Now().Add(time.Hour)}
+	suite.mockOAuthRepo.On("GetSessionByPublicID", mock.Anything, "session-id").Return(session, nil)
+	suite.mockOAuthRepo.On("GetRefreshTokenByHash", mock.Anything, oauth.HashToken("the-refresh-token")).Return(&models.OAuthRefreshToken{
+		Model:     gorm.Model{ID: 21},
+		FamilyID:  "family-id",
+		ClientID:  testClientID,
+		AccountID: 7,
+		SessionID: "session-id",
+		Scope:     "openid profile",
+		ExpiresAt: time.Now().Add(time.Hour),
+	}, nil)
+
+	_, err := suite.oauthService.EndSession(context.Background(), dto.EndSessionRequest{
+		IDTokenHint: suite.idToken("session-id", time.Now().Add(-time.Minute)),
+	}, testLogoutSession, false)
+	suite.Require().NoError(err)
+	for _, call := range suite.mockOAuthRepo.Calls {
+		if call.Method == "RevokeSession" {
+			revokedAt := call.Arguments.Get(2).(time.Time)
+			session.RevokedAt = &revokedAt
+		}
+	}
+	suite.Require().NotNil(session.RevokedAt)
+
+	_, err = suite.oauthService.Token(context.Background(), dto.TokenRequest{
+		GrantType:    oauth.GrantRefreshToken,
+		ClientID:     testClientID,
+		RefreshToken: "the-refresh-token",
+	})
+
+	suite.Equal(oauth.ErrorInvalidGrant, suite.oauthError(err).Code)
+	suite.mockOAuthRepo.AssertNotCalled(suite.T(), "RotateRefreshToken", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	suite.mockOAuthRepo.AssertNotCalled(suite.T(), "RevokeRefreshTokenFamily", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *OIDCLogoutTestSuite) TestLogoutWithoutHintAsksForConfirmation() {
+	req := dto.EndSessionRequest{ClientID: testClientID, PostLogoutRedirectURI: testPostLogoutURI}
+
+	result, err := suite.oauthService.EndSession(context.Background(), req, testLogoutSession, false)
+	suite.Require().NoError(err)
+	suite.True(result.ConfirmationRequired)
+	suite.Equal("Example SPA", result.ClientName)
+	suite.mockOAuthRepo.AssertNotCalled(suite.T(), "RevokeSession", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	suite.expectRevocation()
+	result, err = suite.oauthService.EndSession(context.Background(), req, testLogoutSession, true)
+	suite.Require().NoError(err)
+	suite.False(result.ConfirmationRequired)
+	suite.Equal(testPostLogoutURI, result.RedirectURL)
+	suite.mockOAuthRepo.AssertExpectations(suite.T())
+}
+
+func (suite *OIDCLogoutTestSuite) TestHintOfAnotherSessionAsksForConfirmation() {
+	result, err := suite.oauthService.EndSession(context.Background(), dto.EndSessionRequest{
+		IDTokenHint: suite.idToken("other-session", time.Now().Add(time.Hour)),
+	}, testLogoutSession, false)
+
+	suite.Require().NoError(err)
+	suite.True(result.ConfirmationRequired)
+}
+
+func (suite *OIDCLogoutTestSuite) TestLogoutWithoutSession() {
+	result, err := suite.oauthService.EndSession(context.Background(), dto.EndSessionRequest{
+		ClientID:              testClientID,
+		PostLogoutRedirectURI: testPostLogoutURI,
+	}, "", false)
+
+	suite.Require().NoError(err)
+	suite.False(result.ConfirmationRequired)
+	suite.Equal(testPostLogoutURI, result.RedirectURL)
+	suite.mockOAuthRepo.AssertNotCalled(suite.T(), "ListSessionClients", mock.Anything, mock.Anything)
+}
+
+func (suite *OIDCLogoutTestSuite) TestLogoutRejections() {
+	otherKey, err := oauth.GenerateSigningKey()
+	suite.Require().NoError(err)
+	forged, err := otherKey.Sign(jwt.MapClaims{"iss": testPublicBaseURL, "sub": "7", "aud": testClientID, "sid": "session-id"})
+	suite.Require().NoError(err)
+	logoutToken, err := suite.signingKey.SignType(oauth.LogoutClaims(testPublicBaseURL, testClientID, "7", "session-id", time.Now()), oauth.LogoutTokenType)
+	suite.Require().NoError(err)
+
+	cases := []struct {
+		name string
+		req  dto.EndSessionRequest
+		code string
+	}{
+		{"unregistered redirect URI", dto.EndSessionRequest{ClientID: testClientID, PostLogoutRedirectURI: "https://evil.example.com/"}, oauth.ErrorInvalidRequest},
+		{"redirect URI without client", dto.EndSessionRequest{PostLogoutRedirectURI: testPostLogoutURI}, oauth.ErrorInvalidRequest},
+		{"unknown client", dto.EndSessionRequest{ClientID: "unknown"}, oauth.ErrorInvalidClient},
+		{"hint of another client", dto.EndSessionRequest{ClientID: "unknown", IDTokenHint: suite.idToken("session-id", time.Now().Add(time.Hour))}, oauth.ErrorInvalidRequest},
+		{"hint signed by another key", dto.EndSessionRequest{IDTokenHint: forged}, oauth.ErrorInvalidRequest},
+		{"logout token as hint", dto.EndSessionRequest{IDTokenHint: logoutToken}, oauth.ErrorInvalidRequest},
+	}
+
+	for _, tc := range cases {
+		suite.Run(tc.name, func() {
+			_, err := suite.oauthService.EndSession(context.Background(), tc.req, testLogoutSession, true)
+			suite.Equal(tc.code, suite.oauthError(err).Code)
+		})
+	}
+	suite.mockOAuthRepo.AssertNotCalled(suite.T(), "RevokeSession", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *OIDCLogoutTestSuite) TestDiscoveryAdvertisesLogout() {
+	discovery := suite.oauthService.Discovery()
+
+	suite.Equal(testPublicBaseURL+"/oauth/logout", discovery.EndSessionEndpoint)
+	suite.True(discovery.BackchannelLogoutSupported)
+	suite.True(discovery.BackchannelLogoutSessionSupported)
+}
+
+func TestOIDCLogoutSuite(t *testing.T) {
+	suite.Run(t, new(OIDCLogoutTestSuite))
+}
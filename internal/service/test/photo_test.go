@@ -0,0 +1,88 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ssoydabas/auth-service/internal/service"
+	"github.com/ssoydabas/auth-service/internal/storage"
+	"github.com/ssoydabas/auth-service/pkg/config"
+	"github.com/ssoydabas/auth-service/pkg/errors"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type PhotoServiceTestSuite struct {
+	suite.Suite
+	mockRepo     *MockAccountRepository
+	store        storage.BlobStore
+	photoService service.PhotoService
+}
+
+const testPublicBaseURL = "https://auth.example.com"
+
+func (suite *PhotoServiceTestSuite) SetupTest() {
+	suite.mockRepo = new(MockAccountRepository)
+	suite.store = storage.NewLocalStore(suite.T().TempDir())
+	suite.photoService = service.NewPhotoService(suite.mockRepo, suite.store, config.Config{
+		PublicBaseURL: testPublicBaseURL,
+		PhotoMaxBytes: 1 << 20,
+	})
+}
+
+func testPNG() []byte {
+	var buf bytes.Buffer
+	_ = png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 400, 300)))
+	return buf.Bytes()
+}
+
+func (suite *PhotoServiceTestSuite) TestUploadReplacesPreviousPhoto() {
+	account := (&AccountServiceTestSuite{}).createTestAccount(5, "test@example.com", "+1234567890")
+	suite.mockRepo.On("GetAccountByID", mock.Anything, "5", false).Return(account, nil)
+	suite.mockRepo.On("UpdateAccountPhoto", mock.Anything, uint(5), mock.Anything).
+		Run(func(args mock.Arguments) { account.PhotoUrl = args.String(2) }).
+		Return(nil)
+
+	first, err := suite.photoService.UploadAccountPhoto(context.Background(), 5, bytes.NewReader(testPNG()))
+	suite.Require().NoError(err)
+	suite.True(strings.HasPrefix(first.PhotoUrl, testPublicBaseURL+service.PhotoRoutePrefix+"5/"))
+	suite.Equal(first.Variants["large"], first.PhotoUrl)
+	suite.Len(first.Variants, 3)
+
+	for _, url := range first.Variants {
+		body, info, err := suite.photoService.GetPhoto(context.Background(), strings.TrimPrefix(url, testPublicBaseURL+service.PhotoRoutePrefix))
+		suite.Require().NoError(err)
+		data, _ := io.ReadAll(body)
+		body.Close()
+		suite.Equal("image/png", info.ContentType)
+		suite.Equal("image/png", http.DetectContentType(data))
+	}
+
+	second, err := suite.photoService.UploadAccountPhoto(context.Background(), 5, bytes.NewReader(testPNG()))
+	suite.Require().NoError(err)
+	suite.NotEqual(first.PhotoUrl, second.PhotoUrl)
+
+	_, _, err = suite.photoService.GetPhoto(context.Background(), strings.TrimPrefix(first.PhotoUrl, testPublicBaseURL+service.PhotoRoutePrefix))
+	suite.Error(err)
+	suite.Equal(http.StatusNotFound, err.(*errors.AppError).Code)
+}
+
+func (suite *PhotoServiceTestSuite) TestUploadRejectsNonImages() {
+	suite.mockRepo.On("GetAccountByID", mock.Anything, "5", false).
+		Return((&AccountServiceTestSuite{}).createTestAccount(5, "test@example.com", "+1234567890"), nil)
+
+	_, err := suite.photoService.UploadAccountPhoto(context.Background(), 5, strings.NewReader("%PDF-1.4 not an image"))
+	suite.Require().Error(err)
+	suite.Equal(http.StatusUnsupportedMediaType, err.(*errors.AppError).Code)
+	suite.mockRepo.AssertNotCalled(suite.T(), "UpdateAccountPhoto", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestPhotoServiceSuite(t *testing.T) {
+	suite.Run(t, new(PhotoServiceTestSuite))
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockRoleRepository is a mock implementation of RoleRepository
+type MockRoleRepository struct {
+	mock.Mock
+}
+
+func (m *MockRoleRepository) ListRoles(ctx context.Context) ([]models.Role, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Role), args.Error(1)
+}
+
+func (m *MockRoleRepository) GetRoleByID(ctx context.Context, id string) (*models.Role, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Role), args.Error(1)
+}
+
+func (m *MockRoleRepository) GetRoleByName(ctx context.Context, name string) (*models.Role, error) {
+	args := m.Called(ctx, name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Role), args.Error(1)
+}
+
+func (m *MockRoleRepository) GetRolesByNames(ctx context.Context, names []string) ([]models.Role, error) {
+	args := m.Called(ctx, names)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Role), args.Error(1)
+}
+
+func (m *MockRoleRepository) CreateRole(ctx context.Context, role *models.Role, audit models.AuditEvent) error {
+	args := m.Called(ctx, role, audit)
+	return args.Error(0)
+}
+
+func (m *MockRoleRepository) UpdateRole(ctx context.Context, role *models.Role, audit models.AuditEvent) error {
+	args := m.Called(ctx, role, audit)
+	return args.Error(0)
+}
+
+func (m *MockRoleRepository) DeleteRole(ctx context.Context, roleID uint, audit models.AuditEvent) error {
+	args := m.Called(ctx, roleID, audit)
+	return args.Error(0)
+}
+
+func (m *MockRoleRepository) ListPermissions(ctx context.Context) ([]models.Permission, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Permission), args.Error(1)
+}
+
+func (m *MockRoleRepository) GetPermissionByID(ctx context.Context, id string) (*models.Permission, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Permission), args.Error(1)
+}
+
+func (m *MockRoleRepository) GetPermissionsByNames(ctx context.Context, names []string) ([]models.Permission, error) {
+	args := m.Called(ctx, names)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Permission), args.Error(1)
+}
+
+func (m *MockRoleRepository) CreatePermission(ctx context.Context, permission *models.Permission, audit models.AuditEvent) error {
+	args := m.Called(ctx, permission, audit)
+	return args.Error(0)
+}
+
+func (m *MockRoleRepository) DeletePermission(ctx context.Context, permissionID uint, audit models.AuditEvent) error {
+	args := m.Called(ctx, permissionID, audit)
+	return args.Error(0)
+}
+
+func (m *MockRoleRepository) ReplaceAccountRoles(ctx context.Context, accountID uint, roles []models.Role, audit models.AuditEvent) error {
+	args := m.Called(ctx, accountID, roles, audit)
+	return args.Error(0)
+}
+
+func (m *MockRoleRepository) AccountHasPermission(ctx context.Context, accountID uint, permission string) (bool, error) {
+	args := m.Called(ctx, accountID, permission)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRoleRepository) CountAccountsWithRole(ctx context.Context, name string) (int64, error) {
+	args := m.Called(ctx, name)
+	return args.Get(0).(int64), args.Error(1)
+}
@@ -0,0 +1,446 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/service"
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/ssoydabas/auth-service/pkg/config"
+	"github.com/ssoydabas/auth-service/pkg/errors"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+)
+
+type RoleServiceTestSuite struct {
+	suite.Suite
+	mockRepo     *MockAccountRepository
+	mockRoleRepo *MockRoleRepository
+	roleService  service.RoleService
+}
+
+func (suite *RoleServiceTestSuite) SetupTest() {
+	suite.mockRepo = new(MockAccountRepository)
+	suite.mockRoleRepo = new(MockRoleRepository)
+	suite.roleService = service.NewRoleService(suite.mockRoleRepo, suite.mockRepo, config.Config{
+		DefaultRole: models.RoleCommon,
+	})
+}
+
+func (suite *RoleServiceTestSuite) resetMocks() {
+	suite.mockRepo.ExpectedCalls = nil
+	suite.mockRepo.Calls = nil
+	suite.mockRoleRepo.ExpectedCalls = nil
+	suite.mockRoleRepo.Calls = nil
+}
+
+func (suite *RoleServiceTestSuite) createTestAccount(id uint, email, phone string) *models.Account {
+	return (&AccountServiceTestSuite{}).createTestAccount(id, email, phone)
+}
+
+func (suite *RoleServiceTestSuite) createTestRole(id uint, name string, system bool, permissions ...string) *models.Role {
+	role := &models.Role{Model: gorm.Model{ID: id}, Name: name, System: system}
+	for _, permission := range permissions {
+		role.Permissions = append(role.Permissions, models.Permission{Name: permission})
+	}
+	return role
+}
+
+func auditMetadata(event models.AuditEvent) map[string]any {
+	var metadata map[string]any
+	if err := json.Unmarshal([]byte(event.Metadata), &metadata); err != nil {
+		return nil
+	}
+	return metadata
+}
+
+func (suite *RoleServiceTestSuite) TestHasPermission() {
+	suite.mockRoleRepo.On("AccountHasPermission", mock.Anything, uint(7), models.PermissionRolesAssign).Return(true, nil)
+	suite.mockRoleRepo.On("AccountHasPermission", mock.Anything, uint(7), models.PermissionRolesWrite).Return(false, nil)
+
+	allowed, err := suite.roleService.HasPermission(context.Background(), &dto.AccountResponse{ID: 7}, models.PermissionRolesAssign)
+	suite.NoError(err)
+	suite.True(allowed)
+
+	allowed, err = suite.roleService.HasPermission(context.Background(), &dto.AccountResponse{ID: 7}, models.PermissionRolesWrite)
+	suite.NoError(err)
+	suite.False(allowed)
+}
+
+func (suite *RoleServiceTestSuite) TestCreateRole() {
+	tests := []struct {
+		name          string
+		req           dto.CreateRoleRequest
+		setupMocks    func()
+		wantErr       bool
+		expectedError error
+	}{
+		{
+			name: "duplicate name",
+			req:  dto.CreateRoleRequest{Name: models.RoleTeacher},
+			setupMocks: func() {
+				suite.mockRoleRepo.On("GetRoleByName", mock.Anything, models.RoleTeacher).
+					Return(suite.createTestRole(4, models.RoleTeacher, true), nil)
+			},
+			wantErr:       true,
+			expectedError: errors.ConflictError("role name already in use"),
+		},
+		{
+			name: "unknown permission",
+			req:  dto.CreateRoleRequest{Name: "support", Permissions: []string{models.PermissionAccountsRead, "billing:refund"}},
+			setupMocks: func() {
+				suite.mockRoleRepo.On("GetRoleByName", mock.Anything, "support").Return(nil, gorm.ErrRecordNotFound)
+				suite.mockRoleRepo.On("GetPermissionsByNames", mock.Anything, []string{models.PermissionAccountsRead, "billing:refund"}).
+					Return([]models.Permission{{Name: models.PermissionAccountsRead}}, nil)
+			},
+			wantErr:       true,
+			expectedError: errors.BadRequestError("Unknown permission: billing:refund"),
+		},
+		{
+			name: "successful creation is audited",
+			req:  dto.CreateRoleRequest{Name: "support", Description: "Support desk", Permissions: []string{models.PermissionAccountsRead}},
+			setupMocks: func() {
+				suite.mockRoleRepo.On("GetRoleByName", mock.Anything, "support").Return(nil, gorm.ErrRecordNotFound)
+				suite.mockRoleRepo.On("GetPermissionsByNames", mock.Anything, []string{models.PermissionAccountsRead}).
+					Return([]models.Permission{{Name: models.PermissionAccountsRead}}, nil)
+				suite.mockRoleRepo.On("CreateRole", mock.Anything,
+					mock.MatchedBy(func(role *models.Role) bool {
+						return role.Name == "support" && !role.System && len(role.Permissions) == 1
+					}),
+					mock.MatchedBy(func(event models.AuditEvent) bool {
+						return event.Action == models.AuditActionRoleCreated && *event.ActorID == 1 &&
+							auditMetadata(event)["name"] == "support"
+					})).Return(nil)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			suite.resetMocks()
+			tt.setupMocks()
+
+			role, err := suite.roleService.CreateRole(context.Background(), 1, tt.req)
+			if tt.wantErr {
+				suite.Error(err)
+				if tt.expectedError != nil {
+					suite.Equal(tt.expectedError.Error(), err.Error())
+				}
+			} else {
+				suite.NoError(err)
+				suite.Equal(tt.req.Name, role.Name)
+				suite.Equal(tt.req.Permissions, role.Permissions)
+			}
+
+			suite.mockRoleRepo.AssertExpectations(suite.T())
+		})
+	}
+}
+
+func (suite *RoleServiceTestSuite) TestUpdateRole() {
+	tests := []struct {
+		name          string
+		req           dto.UpdateRoleRequest
+		setupMocks    func()
+		wantErr       bool
+		expectedError error
+	}{
+		{
+			name: "built-in roles cannot be renamed",
+			req:  dto.UpdateRoleRequest{Name: "instructor"},
+			setupMocks: func() {
+				suite.mockRoleRepo.On("GetRoleByID", mock.Anything, "4").
+					Return(suite.createTestRole(4, models.RoleTeacher, true), nil)
+			},
+			wantErr:       true,
+			expectedError: errors.BadRequestError("Built-in roles cannot be renamed"),
+		},
+		{
+			name: "permissions of a built-in role can change",
+			req:  dto.UpdateRoleRequest{Name: models.RoleTeacher, Permissions: []string{models.PermissionAccountsRead}},
+			setupMocks: func() {
+				suite.mockRoleRepo.On("GetRoleByID", mock.Anything, "4").
+					Return(suite.createTestRole(4, models.RoleTeacher, true), nil)
+				suite.mockRoleRepo.On("GetPermissionsByNames", mock.Anything, []string{models.PermissionAccountsRead}).
+					Return([]models.Permission{{Name: models.PermissionAccountsRead}}, nil)
+				suite.mockRoleRepo.On("UpdateRole", mock.Anything, mock.Anything,
+					mock.MatchedBy(func(event models.AuditEvent) bool {
+						metadata := auditMetadata(event)
+						from := metadata["from"].(map[string]any)
+						to := metadata["to"].(map[string]any)
+						return event.Action == models.AuditActionRoleUpdated && *event.TargetID == 4 &&
+							len(from["permissions"].([]any)) == 0 && len(to["permissions"].([]any)) == 1
+					})).Return(nil)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			suite.resetMocks()
+			tt.setupMocks()
+
+			role, err := suite.roleService.UpdateRole(context.Background(), 1, "4", tt.req)
+			if tt.wantErr {
+				suite.Error(err)
+				if tt.expectedError != nil {
+					suite.Equal(tt.expectedError.Error(), err.Error())
+				}
+			} else {
+				suite.NoError(err)
+				suite.Equal(tt.req.Name, role.Name)
+				suite.Equal(tt.req.Permissions, role.Permissions)
+			}
+
+			suite.mockRoleRepo.AssertExpectations(suite.T())
+		})
+	}
+}
+
+func (suite *RoleServiceTestSuite) TestDeleteRole() {
+	tests := []struct {
+		name          string
+		role          *models.Role
+		wantErr       bool
+		expectedError error
+	}{
+		{
+			name:          "built-in role",
+			role:          suite.createTestRole(2, models.RoleAdmin, true),
+			wantErr:       true,
+			expectedError: errors.BadRequestError("Built-in roles cannot be deleted"),
+		},
+		{
+			name:          "default role",
+			role:          suite.createTestRole(9, models.RoleCommon, false),
+			wantErr:       true,
+			expectedError: errors.BadRequestError("The default role cannot be deleted"),
+		},
+		{
+			name: "custom role",
+			role: suite.createTestRole(9, "support", false),
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			suite.resetMocks()
+			suite.mockRoleRepo.On("GetRoleByID", mock.Anything, "9").Return(tt.role, nil)
+			if !tt.wantErr {
+				suite.mockRoleRepo.On("DeleteRole", mock.Anything, tt.role.ID,
+					mock.MatchedBy(func(event models.AuditEvent) bool {
+						return event.Action == models.AuditActionRoleDeleted && auditMetadata(event)["name"] == tt.role.Name
+					})).Return(nil)
+			}
+
+			err := suite.roleService.DeleteRole(context.Background(), 1, "9")
+			if tt.wantErr {
+				suite.Error(err)
+				suite.Equal(tt.expectedError.Error(), err.Error())
+			} else {
+				suite.NoError(err)
+			}
+
+			suite.mockRoleRepo.AssertExpectations(suite.T())
+		})
+	}
+}
+
+func (suite *RoleServiceTestSuite) TestDeletePermission() {
+	suite.mockRoleRepo.On("GetPermissionByID", mock.Anything, "1").
+		Return(&models.Permission{Model: gorm.Model{ID: 1}, Name: models.PermissionAccountsRead, System: true}, nil)
+
+	err := suite.roleService.DeletePermission(context.Background(), 1, "1")
+	suite.Error(err)
+	suite.Equal(errors.BadRequestError("Built-in permissions cannot be deleted").Error(), err.Error())
+	suite.mockRoleRepo.AssertNotCalled(suite.T(), "DeletePermission", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *RoleServiceTestSuite) TestAssignRoles() {
+	tests := []struct {
+		name          string
+		actorID       uint
+		accountID     string
+		roles         []string
+		setupMocks    func()
+		wantRoles     []string
+		wantErr       bool
+		expectedError error
+	}{
+		{
+			name:      "account not found",
+			actorID:   1,
+			accountID: "999",
+			roles:     []string{models.RoleTeacher},
+			setupMocks: func() {
+				suite.mockRepo.On("GetAccountByID", mock.Anything, "999", false).
+					Return(nil, errors.NotFoundError("Account not found"))
+			},
+			wantErr:       true,
+			expectedError: errors.NotFoundError("Account not found"),
+		},
+		{
+			name:      "cannot change own roles",
+			actorID:   1,
+			accountID: "1",
+			roles:     []string{models.RoleCommon},
+			setupMocks: func() {
+				suite.mockRepo.On("GetAccountByID", mock.Anything, "1", false).
+					Return(suite.createTestAccount(1, "admin@example.com", "+1234567890"), nil)
+			},
+			wantErr:       true,
+			expectedError: errors.BadRequestError("You cannot change your own roles"),
+		},
+		{
+			name:      "unknown role",
+			actorID:   1,
+			accountID: "2",
+			roles:     []string{models.RoleTeacher, "wizard"},
+			setupMocks: func() {
+				suite.mockRepo.On("GetAccountByID", mock.Anything, "2", false).
+					Return(suite.createTestAccount(2, "test@example.com", "+1234567891"), nil)
+				suite.mockRoleRepo.On("GetRolesByNames", mock.Anything, []string{models.RoleTeacher, "wizard"}).
+					Return([]models.Role{*suite.createTestRole(4, models.RoleTeacher, true)}, nil)
+			},
+			wantErr:       true,
+			expectedError: errors.BadRequestError("Unknown role: wizard"),
+		},
+		{
+			name:      "unchanged roles are a no-op",
+			actorID:   1,
+			accountID: "2",
+			roles:     []string{models.RoleCommon, models.RoleCommon},
+			setupMocks: func() {
+				account := suite.createTestAccount(2, "test@example.com", "+1234567891")
+				account.Roles = []models.Role{*suite.createTestRole(1, models.RoleCommon, true)}
+				suite.mockRepo.On("GetAccountByID", mock.Anything, "2", false).Return(account, nil)
+				suite.mockRoleRepo.On("GetRolesByNames", mock.Anything, []string{models.RoleCommon}).
+					Return([]models.Role{*suite.createTestRole(1, models.RoleCommon, true)}, nil)
+			},
+			wantRoles: []string{models.RoleCommon},
+		},
+		{
+			name:      "successful role change is audited",
+			actorID:   1,
+			accountID: "2",
+			roles:     []string{models.RoleTeacher, models.RoleManager},
+			setupMocks: func() {
+				account := suite.createTestAccount(2, "test@example.com", "+1234567891")
+				account.Roles = []models.Role{*suite.createTestRole(1, models.RoleCommon, true)}
+				roles := []models.Role{
+					*suite.createTestRole(3, models.RoleManager, true),
+					*suite.createTestRole(4, models.RoleTeacher, true),
+				}
+				suite.mockRepo.On("GetAccountByID", mock.Anything, "2", false).Return(account, nil)
+				suite.mockRoleRepo.On("GetRolesByNames", mock.Anything, []string{models.RoleManager, models.RoleTeacher}).
+					Return(roles, nil)
+				suite.mockRoleRepo.On("ReplaceAccountRoles", mock.Anything, uint(2), roles,
+					mock.MatchedBy(func(event models.AuditEvent) bool {
+						metadata := auditMetadata(event)
+						return event.Action == models.AuditActionRolesChanged &&
+							*event.ActorID == 1 && *event.TargetID == 2 &&
+							len(metadata["from"].([]any)) == 1 && len(metadata["to"].([]any)) == 2
+					})).Return(nil)
+			},
+			wantRoles: []string{models.RoleManager, models.RoleTeacher},
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			suite.resetMocks()
+			tt.setupMocks()
+
+			account, err := suite.roleService.AssignRoles(context.Background(), tt.actorID, tt.accountID, dto.AssignRolesRequest{Roles: tt.roles})
+			if tt.wantErr {
+				suite.Error(err)
+				if tt.expectedError != nil {
+					suite.Equal(tt.expectedError.Error(), err.Error())
+				}
+			} else {
+				suite.NoError(err)
+				suite.Equal(tt.wantRoles, account.Roles)
+			}
+
+			suite.mockRepo.AssertExpectations(suite.T())
+			suite.mockRoleRepo.AssertExpectations(suite.T())
+		})
+	}
+}
+
+func (suite *RoleServiceTestSuite) TestBootstrapAdmin() {
+	tests := []struct {
+		name        string
+		email       string
+		setupMocks  func()
+		wantErr     bool
+		wantGranted bool
+	}{
+		{
+			name:       "no initial admin configured",
+			setupMocks: func() {},
+		},
+		{
+			name:  "admin already exists",
+			email: "admin@example.com",
+			setupMocks: func() {
+				suite.mockRoleRepo.On("CountAccountsWithRole", mock.Anything, models.RoleAdmin).Return(int64(1), nil)
+			},
+		},
+		{
+			name:  "account not registered yet",
+			email: "admin@example.com",
+			setupMocks: func() {
+				suite.mockRoleRepo.On("CountAccountsWithRole", mock.Anything, models.RoleAdmin).Return(int64(0), nil)
+				suite.mockRepo.On("GetAccountByEmail", mock.Anything, "admin@example.com").Return(nil, gorm.ErrRecordNotFound)
+			},
+			wantErr: true,
+		},
+		{
+			name:  "admin role is added and audited",
+			email: "admin@example.com",
+			setupMocks: func() {
+				account := suite.createTestAccount(2, "admin@example.com", "+1234567891")
+				account.Roles = []models.Role{*suite.createTestRole(1, models.RoleCommon, true)}
+				admin := suite.createTestRole(5, models.RoleAdmin, true)
+				suite.mockRoleRepo.On("CountAccountsWithRole", mock.Anything, models.RoleAdmin).Return(int64(0), nil)
+				suite.mockRepo.On("GetAccountByEmail", mock.Anything, "admin@example.com").Return(account, nil)
+				suite.mockRoleRepo.On("GetRoleByName", mock.Anything, models.RoleAdmin).Return(admin, nil)
+				suite.mockRoleRepo.On("ReplaceAccountRoles", mock.Anything, uint(2), []models.Role{account.Roles[0], *admin},
+					mock.MatchedBy(func(event models.AuditEvent) bool {
+						metadata := auditMetadata(event)
+						return event.Action == models.AuditActionRolesChanged &&
+							event.ActorID == nil && *event.TargetID == 2 &&
+							metadata["reason"] == "initial_admin"
+					})).Return(nil)
+			},
+			wantGranted: true,
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			suite.resetMocks()
+			tt.setupMocks()
+
+			roleService := service.NewRoleService(suite.mockRoleRepo, suite.mockRepo, config.Config{InitialAdminEmail: tt.email})
+			granted, err := roleService.BootstrapAdmin(context.Background())
+			if tt.wantErr {
+				suite.Error(err)
+			} else {
+				suite.NoError(err)
+			}
+			suite.Equal(tt.wantGranted, granted)
+
+			suite.mockRepo.AssertExpectations(suite.T())
+			suite.mockRoleRepo.AssertExpectations(suite.T())
+		})
+	}
+}
+
+func TestRoleServiceSuite(t *testing.T) {
+	suite.Run(t, new(RoleServiceTestSuite))
+}
@@ -0,0 +1,145 @@
+package siem
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+
+	"github.com/ssoydabas/auth-service/models"
+)
+
+type FileConfig struct {
+	Path string
+	// MaxBytes is the size at which the file is rotated. Zero disables
+	// rotation.
+	MaxBytes int64
+	// MaxBackups is the number of rotated files kept as Path.1 (newest) to
+	// Path.MaxBackups (oldest).
+	MaxBackups int
+}
+
+// fileSink appends events as JSON lines and rotates the file when it would
+// grow past MaxBytes. Each batch is buffered and synced to disk before
+// Write returns.
+type fileSink struct {
+	cfg       FileConfig
+	formatter Formatter
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+func NewFileSink(cfg FileConfig) Sink {
+	return &fileSink{
+		cfg:       cfg,
+		formatter: JSONFormatter{},
+	}
+}
+
+func (s *fileSink) Name() string {
+	return SinkFile
+}
+
+func (s *fileSink) Write(ctx context.Context, events []models.AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.open(); err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(s.file)
+	for _, event := range events {
+		line, err := s.formatter.Format(event)
+		if err != nil {
+			return err
+		}
+		line = append(line, '\n')
+
+		if s.cfg.MaxBytes > 0 && s.size > 0 && s.size+int64(len(line)) > s.cfg.MaxBytes {
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			if err := s.rotate(); err != nil {
+				return err
+			}
+			w.Reset(s.file)
+		}
+
+		if _, err := w.Write(line); err != nil {
+			return err
+		}
+		s.size += int64(len(line))
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+func (s *fileSink) open() error {
+	if s.file != nil {
+		return nil
+	}
+
+	file, err := os.OpenFile(s.cfg.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("audit file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("audit file: %w", err)
+	}
+
+	s.file = file
+	s.size = info.Size()
+	return nil
+}
+
+// rotate shifts Path.N to Path.N+1, dropping the oldest, moves Path to
+// Path.1 and opens a new Path.
+func (s *fileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	s.file = nil
+
+	if s.cfg.MaxBackups > 0 {
+		for i := s.cfg.MaxBackups - 1; i >= 1; i-- {
+			err := os.Rename(s.backup(i), s.backup(i+1))
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+		}
+		if err := os.Rename(s.cfg.Path, s.backup(1)); err != nil {
+			return err
+		}
+	} else if err := os.Remove(s.cfg.Path); err != nil {
+		return err
+	}
+
+	return s.open()
+}
+
+func (s *fileSink) backup(n int) string {
+	return fmt.Sprintf("%s.%d", s.cfg.Path, n)
+}
+
+func (s *fileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
@@ -0,0 +1,160 @@
+package siem
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ssoydabas/auth-service/models"
+)
+
+const (
+	FormatJSON = "json"
+	FormatCEF  = "cef"
+)
+
+// Formatter renders an audit event as a single line, without the trailing
+// newline.
+type Formatter interface {
+	Format(event models.AuditEvent) ([]byte, error)
+}
+
+// NewFormatter returns the formatter for name, one of FormatJSON and
+// FormatCEF.
+func NewFormatter(name string) (Formatter, error) {
+	switch name {
+	case FormatJSON:
+		return JSONFormatter{}, nil
+	case FormatCEF:
+		return NewCEFFormatter(), nil
+	default:
+		return nil, fmt.Errorf("unknown audit event format: %q", name)
+	}
+}
+
+// Record is the JSON form of an audit event sent to a SIEM.
+type Record struct {
+	ID        uint            `json:"id"`
+	Sequence  uint64          `json:"sequence"`
+	Time      string          `json:"time"`
+	Action    string          `json:"action"`
+	Outcome   string          `json:"outcome"`
+	ActorID   *uint           `json:"actor_id,omitempty"`
+	TargetID  *uint           `json:"target_id,omitempty"`
+	IP        string          `json:"ip,omitempty"`
+	UserAgent string          `json:"user_agent,omitempty"`
+	Metadata  json.RawMessage `json:"metadata,omitempty"`
+	PrevHash  string          `json:"prev_hash"`
+	Hash      string          `json:"hash"`
+}
+
+// JSONFormatter renders events as Record objects.
+type JSONFormatter struct{}
+
+func (JSONFormatter) Format(event models.AuditEvent) ([]byte, error) {
+	record := Record{
+		ID:        event.ID,
+		Sequence:  event.Sequence,
+		Time:      event.CreatedAt.UTC().Format(time.RFC3339Nano),
+		Action:    event.Action,
+		Outcome:   event.Outcome,
+		ActorID:   event.ActorID,
+		TargetID:  event.TargetID,
+		IP:        event.IP,
+		UserAgent: event.UserAgent,
+		PrevHash:  event.PrevHash,
+		Hash:      event.Hash,
+	}
+	if event.Metadata != "" && event.Metadata != "{}" && json.Valid([]byte(event.Metadata)) {
+		record.Metadata = json.RawMessage(event.Metadata)
+	}
+
+	return json.Marshal(record)
+}
+
+// CEFFormatter renders events in ArcSight Common Event Format. The action is
+// both the signature ID and the name; failures get a higher severity than
+// successes.
+type CEFFormatter struct {
+	Vendor  string
+	Product string
+	Version string
+}
+
+func NewCEFFormatter() CEFFormatter {
+	return CEFFormatter{
+		Vendor:  "ssoydabas",
+		Product: "auth-service",
+		Version: "1.0",
+	}
+}
+
+const (
+	cefSeveritySuccess = 3
+	cefSeverityFailure = 7
+)
+
+func (f CEFFormatter) Format(event models.AuditEvent) ([]byte, error) {
+	severity := cefSeveritySuccess
+	if event.Outcome == models.AuditOutcomeFailure {
+		severity = cefSeverityFailure
+	}
+
+	extension := [][2]string{
+		{"rt", strconv.FormatInt(event.CreatedAt.UnixMilli(), 10)},
+		{"act", event.Action},
+		{"outcome", event.Outcome},
+		{"externalId", strconv.FormatUint(uint64(event.ID), 10)},
+		{"cn1Label", "sequence"},
+		{"cn1", strconv.FormatUint(event.Sequence, 10)},
+	}
+	if event.ActorID != nil {
+		extension = append(extension, [2]string{"suid", strconv.FormatUint(uint64(*event.ActorID), 10)})
+	}
+	if event.TargetID != nil {
+		extension = append(extension, [2]string{"duid", strconv.FormatUint(uint64(*event.TargetID), 10)})
+	}
+	if event.IP != "" {
+		extension = append(extension, [2]string{"src", event.IP})
+	}
+	if event.UserAgent != "" {
+		extension = append(extension, [2]string{"requestClientApplication", event.UserAgent})
+	}
+	if event.Metadata != "" && event.Metadata != "{}" {
+		extension = append(extension, [2]string{"cs1Label", "metadata"}, [2]string{"cs1", event.Metadata})
+	}
+	extension = append(extension,
+		[2]string{"cs2Label", "prevHash"}, [2]string{"cs2", event.PrevHash},
+		[2]string{"cs3Label", "hash"}, [2]string{"cs3", event.Hash},
+	)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "CEF:0|%s|%s|%s|%s|%s|%d|",
+		cefHeader(f.Vendor), cefHeader(f.Product), cefHeader(f.Version),
+		cefHeader(event.Action), cefHeader(event.Action), severity)
+	for i, pair := range extension {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(pair[0])
+		b.WriteByte('=')
+		b.WriteString(cefExtension(pair[1]))
+	}
+
+	return []byte(b.String()), nil
+}
+
+var (
+	cefHeaderEscaper    = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r", " ", "\n", " ")
+	cefExtensionEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r", `\r`, "\n", `\n`)
+)
+
+func cefHeader(value string) string {
+	return cefHeaderEscaper.Replace(value)
+}
+
+func cefExtension(value string) string {
+	return cefExtensionEscaper.Replace(value)
+}
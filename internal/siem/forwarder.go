@@ -0,0 +1,121 @@
+package siem
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ssoydabas/auth-service/internal/outbox"
+	"github.com/ssoydabas/auth-service/internal/repository"
+)
+
+type Config struct {
+	PollInterval   time.Duration
+	BatchSize      int
+	ClaimLease     time.Duration
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+}
+
+// Forwarder feeds committed audit events to the sinks. Sinks do not sit in
+// the request path: each one has a worker that reads the audit log from its
+// own cursor, one batch at a time, and only reads the next batch once the
+// sink has accepted the previous one. A slow or unreachable sink therefore
+// falls behind, and catches up later, without slowing down the requests
+// that record events or the other sinks.
+//
+// Cursors are leased, so when several instances run only one of them
+// forwards to a given sink at a time.
+type Forwarder struct {
+	auditRepository repository.AuditRepository
+	sinks           []Sink
+	owner           string
+	cfg             Config
+}
+
+func NewForwarder(auditRepository repository.AuditRepository, sinks []Sink, cfg Config) *Forwarder {
+	return &Forwarder{
+		auditRepository: auditRepository,
+		sinks:           sinks,
+		owner:           uuid.New().String(),
+		cfg:             cfg,
+	}
+}
+
+// Run forwards events to every sink until ctx is cancelled, then closes the
+// sinks.
+func (f *Forwarder) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, sink := range f.sinks {
+		wg.Add(1)
+		go func(sink Sink) {
+			defer wg.Done()
+			f.run(ctx, sink)
+		}(sink)
+	}
+	wg.Wait()
+
+	for _, sink := range f.sinks {
+		if err := sink.Close(); err != nil {
+			log.Printf("siem: closing %s sink: %v", sink.Name(), err)
+		}
+	}
+}
+
+func (f *Forwarder) run(ctx context.Context, sink Sink) {
+	failures := 0
+	for {
+		wait := f.cfg.PollInterval
+
+		// Keep forwarding while full batches come back.
+		for {
+			n, err := f.ForwardOnce(ctx, sink)
+			if err != nil {
+				failures++
+				wait = outbox.Backoff(failures, f.cfg.RetryBaseDelay, f.cfg.RetryMaxDelay)
+				log.Printf("siem: %s sink failed, retrying in %s: %v", sink.Name(), wait, err)
+				break
+			}
+			failures = 0
+			if n < f.cfg.BatchSize || ctx.Err() != nil {
+				break
+			}
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// ForwardOnce sends the next batch of events after sink's cursor and
+// advances the cursor once the sink accepted them. It returns the number of
+// events sent, which is zero when another instance holds the cursor.
+func (f *Forwarder) ForwardOnce(ctx context.Context, sink Sink) (int, error) {
+	cursor, err := f.auditRepository.ClaimAuditSinkCursor(ctx, sink.Name(), f.owner, f.cfg.ClaimLease)
+	if err != nil || cursor == nil {
+		return 0, err
+	}
+
+	events, err := f.auditRepository.ListAuditChain(ctx, cursor.Sequence, cursor.EventID, f.cfg.BatchSize)
+	if err != nil || len(events) == 0 {
+		return 0, err
+	}
+
+	if err := sink.Write(ctx, events); err != nil {
+		return 0, err
+	}
+
+	last := events[len(events)-1]
+	if _, err := f.auditRepository.AdvanceAuditSinkCursor(ctx, sink.Name(), f.owner, last.Sequence, last.ID); err != nil {
+		return 0, err
+	}
+
+	return len(events), nil
+}
@@ -0,0 +1,54 @@
+// Package siem forwards audit events to a security team's SIEM through
+// pluggable sinks.
+package siem
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/ssoydabas/auth-service/pkg/config"
+)
+
+// Sink receives audit events in chain order. Write either accepts the whole
+// batch or returns an error, in which case the batch is sent again later, so
+// a sink may see an event more than once but never skips one.
+type Sink interface {
+	Name() string
+	Write(ctx context.Context, events []models.AuditEvent) error
+	Close() error
+}
+
+const (
+	SinkSyslog = "syslog"
+	SinkFile   = "file"
+)
+
+// NewSinks builds the sinks listed in AUDIT_SINKS.
+func NewSinks(cfg config.Config) ([]Sink, error) {
+	var sinks []Sink
+	for _, name := range cfg.AuditSinks {
+		switch name {
+		case SinkSyslog:
+			formatter, err := NewFormatter(cfg.AuditSyslogFormat)
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, NewSyslogSink(SyslogConfig{
+				Network:  cfg.AuditSyslogNetwork,
+				Address:  cfg.AuditSyslogAddress,
+				Facility: cfg.AuditSyslogFacility,
+				AppName:  cfg.AuditSyslogAppName,
+			}, formatter))
+		case SinkFile:
+			sinks = append(sinks, NewFileSink(FileConfig{
+				Path:       cfg.AuditFilePath,
+				MaxBytes:   cfg.AuditFileMaxBytes,
+				MaxBackups: cfg.AuditFileMaxBackups,
+			}))
+		default:
+			return nil, fmt.Errorf("unknown audit sink: %q", name)
+		}
+	}
+	return sinks, nil
+}
@@ -0,0 +1,160 @@
+package siem
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ssoydabas/auth-service/models"
+)
+
+const (
+	syslogSeverityWarning = 4
+	syslogSeverityInfo    = 6
+
+	syslogDialTimeout  = 5 * time.Second
+	syslogWriteTimeout = 10 * time.Second
+)
+
+type SyslogConfig struct {
+	// Network is "udp", "tcp", "unix" (stream) or "unixgram".
+	Network  string
+	Address  string
+	Facility int
+	AppName  string
+}
+
+// syslogSink sends each event as an RFC 5424 message. Stream transports use
+// the octet-counting framing of RFC 6587; datagram transports send one
+// message per datagram. The connection is opened on first use and reopened
+// after a write error.
+type syslogSink struct {
+	cfg       SyslogConfig
+	formatter Formatter
+	hostname  string
+	procID    string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func NewSyslogSink(cfg SyslogConfig, formatter Formatter) Sink {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	return &syslogSink{
+		cfg:       cfg,
+		formatter: formatter,
+		hostname:  hostname,
+		procID:    strconv.Itoa(os.Getpid()),
+	}
+}
+
+func (s *syslogSink) Name() string {
+	return SinkSyslog
+}
+
+func (s *syslogSink) Write(ctx context.Context, events []models.AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		dialer := net.Dialer{Timeout: syslogDialTimeout}
+		conn, err := dialer.DialContext(ctx, s.cfg.Network, s.cfg.Address)
+		if err != nil {
+			return fmt.Errorf("syslog: dial %s %s: %w", s.cfg.Network, s.cfg.Address, err)
+		}
+		s.conn = conn
+	}
+
+	deadline := time.Now().Add(syslogWriteTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := s.conn.SetWriteDeadline(deadline); err != nil {
+		return s.fail(err)
+	}
+
+	for _, event := range events {
+		message, err := s.message(event)
+		if err != nil {
+			return err
+		}
+		if s.stream() {
+			message = append([]byte(strconv.Itoa(len(message))+" "), message...)
+		}
+		if _, err := s.conn.Write(message); err != nil {
+			return s.fail(err)
+		}
+	}
+
+	return nil
+}
+
+// message renders event as an RFC 5424 message with the action as MSGID.
+func (s *syslogSink) message(event models.AuditEvent) ([]byte, error) {
+	body, err := s.formatter.Format(event)
+	if err != nil {
+		return nil, err
+	}
+
+	severity := syslogSeverityInfo
+	if event.Outcome == models.AuditOutcomeFailure {
+		severity = syslogSeverityWarning
+	}
+
+	header := fmt.Sprintf("<%d>1 %s %s %s %s %s - ",
+		s.cfg.Facility*8+severity,
+		event.CreatedAt.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		syslogField(s.hostname, 255),
+		syslogField(s.cfg.AppName, 48),
+		syslogField(s.procID, 128),
+		syslogField(event.Action, 32),
+	)
+
+	return append([]byte(header), body...), nil
+}
+
+func (s *syslogSink) stream() bool {
+	return s.cfg.Network == "tcp" || s.cfg.Network == "tcp4" || s.cfg.Network == "tcp6" || s.cfg.Network == "unix"
+}
+
+// fail drops the connection so the next write reconnects.
+func (s *syslogSink) fail(err error) error {
+	s.conn.Close()
+	s.conn = nil
+	return fmt.Errorf("syslog: %w", err)
+}
+
+func (s *syslogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// syslogField makes value a valid RFC 5424 header field: printable ASCII
+// without spaces, at most max characters, and "-" when empty.
+func syslogField(value string, max int) string {
+	field := make([]byte, 0, len(value))
+	for i := 0; i < len(value) && len(field) < max; i++ {
+		if c := value[i]; c > 32 && c < 127 {
+			field = append(field, c)
+		}
+	}
+	if len(field) == 0 {
+		return "-"
+	}
+	return string(field)
+}
@@ -0,0 +1,322 @@
+package siem
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	servicetest "github.com/ssoydabas/auth-service/internal/service/test"
+	"github.com/ssoydabas/auth-service/internal/siem"
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type SinkTestSuite struct {
+	suite.Suite
+}
+
+func (suite *SinkTestSuite) event(id uint, outcome string) models.AuditEvent {
+	actorID := uint(7)
+	return models.AuditEvent{
+		ID:        id,
+		Sequence:  uint64(id),
+		CreatedAt: time.Date(2026, 3, 4, 5, 6, 7, 891000000, time.UTC),
+		Action:    models.AuditActionAccountLogin,
+		ActorID:   &actorID,
+		IP:        "203.0.113.7",
+		UserAgent: "curl/8.0",
+		Outcome:   outcome,
+		Metadata:  `{"reason": "a=b|c"}`,
+		PrevHash:  "prev",
+		Hash:      fmt.Sprintf("hash-%d", id),
+	}
+}
+
+func (suite *SinkTestSuite) TestJSONFormatter() {
+	line, err := siem.JSONFormatter{}.Format(suite.event(1, models.AuditOutcomeSuccess))
+	suite.Require().NoError(err)
+
+	var record siem.Record
+	suite.Require().NoError(json.Unmarshal(line, &record))
+	suite.Equal(uint64(1), record.Sequence)
+	suite.Equal("2026-03-04T05:06:07.891Z", record.Time)
+	suite.Equal(models.AuditActionAccountLogin, record.Action)
+	suite.Equal("203.0.113.7", record.IP)
+	suite.Equal("hash-1", record.Hash)
+	suite.JSONEq(`{"reason": "a=b|c"}`, string(record.Metadata))
+}
+
+func (suite *SinkTestSuite) TestCEFFormatter() {
+	event := suite.event(1, models.AuditOutcomeFailure)
+	event.UserAgent = "line1\nline2"
+
+	line, err := siem.NewCEFFormatter().Format(event)
+	suite.Require().NoError(err)
+
+	suite.True(strings.HasPrefix(string(line), "CEF:0|ssoydabas|auth-service|1.0|account.login|account.login|7|rt=1772600767891 "), string(line))
+	suite.Contains(string(line), "outcome=failure")
+	suite.Contains(string(line), "suid=7")
+	suite.Contains(string(line), "src=203.0.113.7")
+	suite.Contains(string(line), `requestClientApplication=line1\nline2`)
+	suite.Contains(string(line), `cs1={"reason": "a\=b|c"}`)
+	suite.Contains(string(line), "cs3Label=hash cs3=hash-1")
+	suite.NotContains(string(line), "\n")
+}
+
+// syslogPattern matches an RFC 5424 message carrying a JSON body.
+var syslogPattern = regexp.MustCompile(`^<(\d+)>1 (\S+) (\S+) auth-service (\d+) account\.login - (\{.*\})$`)
+
+func (suite *SinkTestSuite) assertSyslogMessage(message string, pri int, id uint) {
+	match := syslogPattern.FindStringSubmatch(message)
+	suite.Require().NotNil(match, message)
+	suite.Equal(strconv.Itoa(pri), match[1])
+	suite.Equal("2026-03-04T05:06:07.891000Z", match[2])
+	suite.Equal(strconv.Itoa(os.Getpid()), match[4])
+
+	var record siem.Record
+	suite.Require().NoError(json.Unmarshal([]byte(match[5]), &record))
+	suite.Equal(id, record.ID)
+}
+
+func (suite *SinkTestSuite) newSyslogSink(network, address string) siem.Sink {
+	return siem.NewSyslogSink(siem.SyslogConfig{
+		Network:  network,
+		Address:  address,
+		Facility: 10,
+		AppName:  "auth-service",
+	}, siem.JSONFormatter{})
+}
+
+func (suite *SinkTestSuite) TestSyslogDatagram() {
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	suite.Require().NoError(err)
+	defer udp.Close()
+
+	socketPath := filepath.Join(suite.T().TempDir(), "syslog.sock")
+	unixgram, err := net.ListenPacket("unixgram", socketPath)
+	suite.Require().NoError(err)
+	defer unixgram.Close()
+
+	for _, tt := range []struct {
+		network  string
+		listener net.PacketConn
+	}{
+		{"udp", udp},
+		{"unixgram", unixgram},
+	} {
+		suite.Run(tt.network, func() {
+			sink := suite.newSyslogSink(tt.network, tt.listener.LocalAddr().String())
+			defer sink.Close()
+
+			suite.Require().NoError(sink.Write(context.Background(), []models.AuditEvent{
+				suite.event(1, models.AuditOutcomeSuccess),
+				suite.event(2, models.AuditOutcomeFailure),
+			}))
+
+			buf := make([]byte, 64*1024)
+			suite.Require().NoError(tt.listener.SetReadDeadline(time.Now().Add(5 * time.Second)))
+			n, _, err := tt.listener.ReadFrom(buf)
+			suite.Require().NoError(err)
+			suite.assertSyslogMessage(string(buf[:n]), 10*8+6, 1)
+
+			n, _, err = tt.listener.ReadFrom(buf)
+			suite.Require().NoError(err)
+			suite.assertSyslogMessage(string(buf[:n]), 10*8+4, 2)
+		})
+	}
+}
+
+func (suite *SinkTestSuite) TestSyslogStream() {
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	suite.Require().NoError(err)
+	defer tcp.Close()
+
+	unix, err := net.Listen("unix", filepath.Join(suite.T().TempDir(), "syslog.sock"))
+	suite.Require().NoError(err)
+	defer unix.Close()
+
+	for _, tt := range []struct {
+		network  string
+		listener net.Listener
+	}{
+		{"tcp", tcp},
+		{"unix", unix},
+	} {
+		suite.Run(tt.network, func() {
+			received := make(chan []string, 1)
+			go func() {
+				conn, err := tt.listener.Accept()
+				if err != nil {
+					received <- nil
+					return
+				}
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+
+				// RFC 6587 octet counting: "<length> <message>".
+				var messages []string
+				for len(messages) < 2 {
+					prefix, err := reader.ReadString(' ')
+					if err != nil {
+						break
+					}
+					length, err := strconv.Atoi(strings.TrimSpace(prefix))
+					if err != nil {
+						break
+					}
+					message := make([]byte, length)
+					if _, err := io.ReadFull(reader, message); err != nil {
+						break
+					}
+					messages = append(messages, string(message))
+				}
+				received <- messages
+			}()
+
+			sink := suite.newSyslogSink(tt.network, tt.listener.Addr().String())
+			defer sink.Close()
+
+			suite.Require().NoError(sink.Write(context.Background(), []models.AuditEvent{
+				suite.event(1, models.AuditOutcomeSuccess),
+				suite.event(2, models.AuditOutcomeSuccess),
+			}))
+
+			select {
+			case messages := <-received:
+				suite.Require().Len(messages, 2)
+				suite.assertSyslogMessage(messages[0], 86, 1)
+				suite.assertSyslogMessage(messages[1], 86, 2)
+			case <-time.After(5 * time.Second):
+				suite.Fail("no messages received")
+			}
+		})
+	}
+}
+
+func (suite *SinkTestSuite) TestSyslogUnreachable() {
+	sink := suite.newSyslogSink("tcp", "127.0.0.1:1")
+	suite.Error(sink.Write(context.Background(), []models.AuditEvent{suite.event(1, models.AuditOutcomeSuccess)}))
+}
+
+func (suite *SinkTestSuite) TestFileSinkRotates() {
+	path := filepath.Join(suite.T().TempDir(), "audit.jsonl")
+
+	line, err := siem.JSONFormatter{}.Format(suite.event(1, models.AuditOutcomeSuccess))
+	suite.Require().NoError(err)
+
+	// Two lines fit in a file, and two rotated files are kept.
+	sink := siem.NewFileSink(siem.FileConfig{Path: path, MaxBytes: int64(2*(len(line)+1) + 5), MaxBackups: 2})
+	defer sink.Close()
+
+	for id := uint(1); id <= 7; id++ {
+		suite.Require().NoError(sink.Write(context.Background(), []models.AuditEvent{suite.event(id, models.AuditOutcomeSuccess)}))
+	}
+
+	ids := func(name string) []uint {
+		data, err := os.ReadFile(name)
+		suite.Require().NoError(err)
+
+		var ids []uint
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			var record siem.Record
+			suite.Require().NoError(json.Unmarshal([]byte(line), &record))
+			ids = append(ids, record.ID)
+		}
+		return ids
+	}
+
+	suite.Equal([]uint{7}, ids(path))
+	suite.Equal([]uint{5, 6}, ids(path+".1"))
+	suite.Equal([]uint{3, 4}, ids(path+".2"))
+	suite.NoFileExists(path + ".3")
+}
+
+func TestSinkSuite(t *testing.T) {
+	suite.Run(t, new(SinkTestSuite))
+}
+
+// recordingSink keeps the events it was given, or fails when err is set.
+type recordingSink struct {
+	events []models.AuditEvent
+	err    error
+}
+
+func (s *recordingSink) Name() string { return "recording" }
+
+func (s *recordingSink) Write(ctx context.Context, events []models.AuditEvent) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.events = append(s.events, events...)
+	return nil
+}
+
+func (s *recordingSink) Close() error { return nil }
+
+type ForwarderTestSuite struct {
+	suite.Suite
+	mockAuditRepo *servicetest.MockAuditRepository
+	sink          *recordingSink
+	forwarder     *siem.Forwarder
+}
+
+func (suite *ForwarderTestSuite) SetupTest() {
+	suite.mockAuditRepo = new(servicetest.MockAuditRepository)
+	suite.sink = &recordingSink{}
+	suite.forwarder = siem.NewForwarder(suite.mockAuditRepo, []siem.Sink{suite.sink}, siem.Config{
+		PollInterval: time.Second,
+		BatchSize:    2,
+		ClaimLease:   time.Minute,
+	})
+}
+
+func (suite *ForwarderTestSuite) TestForwardsFromCursor() {
+	events := []models.AuditEvent{{ID: 11, Sequence: 4}, {ID: 12, Sequence: 5}}
+	suite.mockAuditRepo.On("ClaimAuditSinkCursor", mock.Anything, "recording", mock.Anything, time.Minute).
+		Return(&models.AuditSinkCursor{Name: "recording", Sequence: 3, EventID: 10}, nil)
+	suite.mockAuditRepo.On("ListAuditChain", mock.Anything, uint64(3), uint(10), 2).Return(events, nil)
+	suite.mockAuditRepo.On("AdvanceAuditSinkCursor", mock.Anything, "recording", mock.Anything, uint64(5), uint(12)).Return(true, nil)
+
+	n, err := suite.forwarder.ForwardOnce(context.Background(), suite.sink)
+	suite.Require().NoError(err)
+	suite.Equal(2, n)
+	suite.Equal(events, suite.sink.events)
+	suite.mockAuditRepo.AssertExpectations(suite.T())
+}
+
+func (suite *ForwarderTestSuite) TestFailingSinkKeepsCursor() {
+	suite.sink.err = fmt.Errorf("connection refused")
+	suite.mockAuditRepo.On("ClaimAuditSinkCursor", mock.Anything, "recording", mock.Anything, time.Minute).
+		Return(&models.AuditSinkCursor{Name: "recording"}, nil)
+	suite.mockAuditRepo.On("ListAuditChain", mock.Anything, uint64(0), uint(0), 2).
+		Return([]models.AuditEvent{{ID: 1, Sequence: 1}}, nil)
+
+	_, err := suite.forwarder.ForwardOnce(context.Background(), suite.sink)
+	suite.Error(err)
+	suite.mockAuditRepo.AssertNotCalled(suite.T(), "AdvanceAuditSinkCursor", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *ForwarderTestSuite) TestCursorHeldElsewhere() {
+	suite.mockAuditRepo.On("ClaimAuditSinkCursor", mock.Anything, "recording", mock.Anything, time.Minute).
+		Return(nil, nil)
+
+	n, err := suite.forwarder.ForwardOnce(context.Background(), suite.sink)
+	suite.Require().NoError(err)
+	suite.Zero(n)
+	suite.mockAuditRepo.AssertNotCalled(suite.T(), "ListAuditChain", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestForwarderSuite(t *testing.T) {
+	suite.Run(t, new(ForwarderTestSuite))
+}
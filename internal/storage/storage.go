@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ssoydabas/auth-service/pkg/config"
+)
+
+const (
+	BackendLocal = "local"
+	BackendS3    = "s3"
+)
+
+// ErrNotFound is returned by Get when no blob is stored under the key.
+var ErrNotFound = errors.New("blob not found")
+
+// BlobInfo describes a stored blob.
+type BlobInfo struct {
+	ContentType string
+	Size        int64
+}
+
+// BlobStore stores opaque blobs under slash-separated keys such as
+// "photos/12/abc/64.jpg".
+type BlobStore interface {
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, BlobInfo, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// NewBlobStore returns the blob store selected by cfg.BlobStore.
+func NewBlobStore(cfg config.Config) (BlobStore, error) {
+	switch cfg.BlobStore {
+	case BackendLocal:
+		return NewLocalStore(cfg.BlobLocalDir), nil
+	case BackendS3:
+		return NewS3Store(S3Config{
+			Endpoint:        cfg.S3Endpoint,
+			Region:          cfg.S3Region,
+			Bucket:          cfg.S3Bucket,
+			AccessKeyID:     cfg.S3AccessKeyID,
+			SecretAccessKey: cfg.S3SecretAccessKey,
+			PathStyle:       cfg.S3PathStyle,
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown blob store: %q", cfg.BlobStore)
+	}
+}
+
+// ValidKey reports whether key is a relative, slash-separated path without
+// empty, "." or ".." segments, so it cannot escape the store's root.
+func ValidKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return false
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+	}
+	return true
+}
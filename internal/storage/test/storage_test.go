@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ssoydabas/auth-service/internal/storage"
+	"github.com/stretchr/testify/suite"
+)
+
+// fakeS3 is a minimal path-style S3 endpoint that checks each request
+// carries a SigV4 authorization header and a matching payload hash.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	types   map[string]string
+	errors  []string
+}
+
+var authorizationPattern = regexp.MustCompile(`^AWS4-HMAC-SHA256 Credential=test-key/\d{8}/eu-test-1/s3/aws4_request, SignedHeaders=([a-z0-9;-]+), Signature=[0-9a-f]{64}$`)
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	body, _ := io.ReadAll(r.Body)
+	sum := sha256.Sum256(body)
+
+	match := authorizationPattern.FindStringSubmatch(r.Header.Get("Authorization"))
+	switch {
+	case match == nil:
+		f.errors = append(f.errors, "bad authorization: "+r.Header.Get("Authorization"))
+	case !strings.Contains(match[1], "host") || !strings.Contains(match[1], "x-amz-date"):
+		f.errors = append(f.errors, "unsigned headers: "+match[1])
+	case r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]):
+		f.errors = append(f.errors, "payload hash mismatch")
+	}
+
+	key := strings.TrimPrefix(r.URL.Path, "/avatars/")
+	switch r.Method {
+	case http.MethodPut:
+		f.objects[key] = body
+		f.types[key] = r.Header.Get("Content-Type")
+	case http.MethodGet:
+		data, ok := f.objects[key]
+		if !ok {
+			http.Error(w, "<Error><Code>NoSuchKey</Code></Error>", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", f.types[key])
+		_, _ = w.Write(data)
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+type StorageTestSuite struct {
+	suite.Suite
+	ctx context.Context
+}
+
+func (suite *StorageTestSuite) SetupTest() {
+	suite.ctx = context.Background()
+}
+
+func (suite *StorageTestSuite) roundTrip(store storage.BlobStore) {
+	key := "photos/1/abc/large.png"
+
+	suite.Require().NoError(store.Put(suite.ctx, key, strings.NewReader("png-bytes"), 9, "image/png"))
+
+	body, info, err := store.Get(suite.ctx, key)
+	suite.Require().NoError(err)
+	data, _ := io.ReadAll(body)
+	body.Close()
+	suite.Equal("png-bytes", string(data))
+	suite.Equal("image/png", info.ContentType)
+
+	suite.Require().NoError(store.Delete(suite.ctx, key))
+	suite.Require().NoError(store.Delete(suite.ctx, key), "deleting a missing blob is not an error")
+
+	_, _, err = store.Get(suite.ctx, key)
+	suite.ErrorIs(err, storage.ErrNotFound)
+}
+
+func (suite *StorageTestSuite) TestLocalStore() {
+	root := suite.T().TempDir()
+	store := storage.NewLocalStore(root)
+
+	suite.roundTrip(store)
+
+	suite.Require().NoError(store.Put(suite.ctx, "photos/2/x/small.jpg", strings.NewReader("jpg"), 3, "image/jpeg"))
+	_, err := os.Stat(filepath.Join(root, "photos", "2", "x", "small.jpg"))
+	suite.NoError(err)
+}
+
+func (suite *StorageTestSuite) TestKeysCannotEscapeTheRoot() {
+	store := storage.NewLocalStore(suite.T().TempDir())
+
+	for _, key := range []string{"", "/etc/passwd", "../secret", "photos/../../secret", "photos//x", `photos\x`} {
+		suite.Error(store.Put(suite.ctx, key, strings.NewReader("x"), 1, "text/plain"), key)
+		_, _, err := store.Get(suite.ctx, key)
+		suite.ErrorIs(err, storage.ErrNotFound, key)
+	}
+}
+
+func (suite *StorageTestSuite) TestS3Store() {
+	fake := &fakeS3{objects: map[string][]byte{}, types: map[string]string{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	store := storage.NewS3Store(storage.S3Config{
+		Endpoint:        server.URL,
+		Region:          "eu-test-1",
+		Bucket:          "avatars",
+		AccessKeyID:     "test-key",
+		SecretAccessKey: "test-secret",
+		PathStyle:       true,
+	})
+
+	suite.roundTrip(store)
+	suite.Empty(fake.errors)
+}
+
+func TestStorageSuite(t *testing.T) {
+	suite.Run(t, new(StorageTestSuite))
+}
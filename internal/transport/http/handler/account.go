@@ -6,6 +6,7 @@ import (
 
 	"github.com/ssoydabas/auth-service/internal/dto"
 	"github.com/ssoydabas/auth-service/internal/service"
+	"github.com/ssoydabas/auth-service/models"
 	"github.com/ssoydabas/auth-service/pkg/errors"
 	"github.com/ssoydabas/auth-service/pkg/validator"
 
@@ -25,34 +26,43 @@ type AccountHandler interface {
 	ResetPassword(c echo.Context) error
 	GetAccountEmailVerificationTokenByID(c echo.Context) error
 	VerifyAccountEmail(c echo.Context) error
+	ResendEmailVerification(c echo.Context) error
+	UpdateCurrentAccount(c echo.Context) error
 }
 
 type accountHandler struct {
 	accountService service.AccountService
+	guard          *guard
 }
 
-func NewAccountHandler(accountService service.AccountService) AccountHandler {
+func NewAccountHandler(accountService service.AccountService, roleService service.RoleService) AccountHandler {
 	return &accountHandler{
 		accountService: accountService,
+		guard:          newGuard(accountService, roleService),
 	}
 }
 
 func (h *accountHandler) AddRoutes(e *echo.Group) {
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
-		AllowOrigins: []string{"http://localhost:3000"},
-		AllowMethods: []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete},
-		AllowHeaders: []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept},
+		AllowOrigins:  []string{"http://localhost:3000"},
+		AllowMethods:  []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete},
+		AllowHeaders:  []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization, headerIfMatch},
+		ExposeHeaders: []string{headerETag},
 	}))
 
 	e.POST("/accounts", h.CreateAccount)
-	e.GET("/accounts/:id", h.GetAccountByID)
-	e.GET("/accounts/email/:email", h.GetAccountByEmail)
+	e.GET("/accounts/:id", h.GetAccountByID, h.guard.require(models.PermissionAccountsRead))
+	e.GET("/accounts/email/:email", h.GetAccountByEmail, h.guard.require(models.PermissionAccountsRead))
 	e.POST("/accounts/authenticate", h.AuthenticateAccount)
 	e.GET("/accounts/me", h.GetAccountByToken)
+	e.PATCH("/accounts/me", h.UpdateCurrentAccount, h.guard.authenticate, h.guard.denyImpersonation)
+	e.DELETE("/accounts/me", h.DeleteCurrentAccount, h.guard.authenticate, h.guard.denyImpersonation)
+	e.POST("/accounts/me/impersonation/stop", h.StopImpersonation, h.guard.authenticate)
 	e.POST("/accounts/set-reset-password-token", h.SetResetPasswordToken)
 	e.POST("/accounts/reset-password", h.ResetPassword)
-	e.GET("/accounts/get-email-verification-token/:id", h.GetAccountEmailVerificationTokenByID)
+	e.GET("/accounts/get-email-verification-token/:id", h.GetAccountEmailVerificationTokenByID, h.guard.require(models.PermissionAccountsWrite))
 	e.POST("/accounts/verify-email", h.VerifyAccountEmail)
+	e.POST("/accounts/verify-email/resend", h.ResendEmailVerification)
 }
 
 // @Summary Create a new account
@@ -61,7 +71,7 @@ func (h *accountHandler) AddRoutes(e *echo.Group) {
 // @Accept json
 // @Produce json
 // @Param account body dto.CreateAccountRequest true "Account details"
-// @Success 201 {object} dto.VerificationCodeResponse "Account created successfully"
+// @Success 201 {object} dto.MessageResponse "Account created; the verification token is sent by email"
 // @Failure 400 {object} dto.ValidationErrorResponse
 // @Failure 409 {object} dto.ErrorData
 // @Failure 500 {object} dto.ErrorData
@@ -79,16 +89,15 @@ func (h *accountHandler) CreateAccount(c echo.Context) error {
 		return errors.BadRequestError(err.Error())
 	}
 
-	token, err := h.accountService.CreateAccount(c.Request().Context(), req)
-	if err != nil {
+	if err := h.accountService.CreateAccount(c.Request().Context(), req); err != nil {
 		if appErr, ok := err.(*errors.AppError); ok {
 			return appErr
 		}
 		return errors.InternalError(err)
 	}
 
-	return c.JSON(http.StatusCreated, dto.VerificationCodeResponse{
-		VerificationCode: token,
+	return c.JSON(http.StatusCreated, dto.MessageResponse{
+		Message: "Account created, a verification email has been sent",
 	})
 }
 
@@ -134,11 +143,14 @@ func (h *accountHandler) AuthenticateAccount(c echo.Context) error {
 // @Tags accounts
 // @Accept json
 // @Produce json
+// @Security BearerAuth
 // @Param id path integer true "Account ID"
 // @Success 200 {object} dto.StandardResponse{data=dto.AccountResponse}
 // @Failure 400 {object} dto.ErrorData
 // @Failure 404 {object} dto.ErrorData
 // @Failure 500 {object} dto.ErrorData
+// @Failure 401 {object} dto.ErrorData
+// @Failure 403 {object} dto.ErrorData
 // @Router /accounts/{id} [get]
 func (h *accountHandler) GetAccountByID(c echo.Context) error {
 	id := c.Param("id")
@@ -158,6 +170,7 @@ func (h *accountHandler) GetAccountByID(c echo.Context) error {
 		return errors.InternalError(err)
 	}
 
+	c.Response().Header().Set(headerETag, account.ETag)
 	return c.JSON(http.StatusOK, mapAccountToResponse(*account))
 }
 
@@ -166,11 +179,14 @@ func (h *accountHandler) GetAccountByID(c echo.Context) error {
 // @Tags accounts
 // @Accept json
 // @Produce json
+// @Security BearerAuth
 // @Param email path string true "Email address"
 // @Success 200 {object} dto.StandardResponse{data=dto.AccountResponse}
 // @Failure 400 {object} dto.ErrorData
 // @Failure 404 {object} dto.ErrorData
 // @Failure 500 {object} dto.ErrorData
+// @Failure 401 {object} dto.ErrorData
+// @Failure 403 {object} dto.ErrorData
 // @Router /accounts/email/{email} [get]
 func (h *accountHandler) GetAccountByEmail(c echo.Context) error {
 	email := c.Param("email")
@@ -189,6 +205,93 @@ func (h *accountHandler) GetAccountByEmail(c echo.Context) error {
 	return c.JSON(http.StatusOK, mapAccountToResponse(*account))
 }
 
+// @Summary Update current account
+// @Description Update the current account's profile with a JSON merge patch (RFC 7396). Send the ETag of the last read in If-Match; a stale ETag is rejected with 412.
+// @Tags accounts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param If-Match header string true "ETag of the account"
+// @Param request body dto.UpdateAccountRequest true "Fields to change; null clears a field"
+// @Success 200 {object} dto.AccountResponse
+// @Header 200 {string} ETag "New ETag of the account"
+// @Failure 400 {object} dto.ValidationErrorResponse
+// @Failure 401 {object} dto.ErrorData
+// @Failure 403 {object} dto.ErrorData
+// @Failure 412 {object} dto.ErrorData
+// @Failure 415 {object} dto.ErrorData
+// @Failure 428 {object} dto.ErrorData
+// @Failure 500 {object} dto.ErrorData
+// @Router /accounts/me [patch]
+func (h *accountHandler) UpdateCurrentAccount(c echo.Context) error {
+	patch, err := readMergePatch(c)
+	if err != nil {
+		return err
+	}
+
+	actor := currentAccount(c)
+	account, err := h.accountService.UpdateAccount(c.Request().Context(), actor.ID, strconv.FormatUint(uint64(actor.ID), 10), c.Request().Header.Get(headerIfMatch), patch)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			return appErr
+		}
+		return errors.InternalError(err)
+	}
+
+	c.Response().Header().Set(headerETag, account.ETag)
+	return c.JSON(http.StatusOK, mapAccountToResponse(*account))
+}
+
+// @Summary Delete current account
+// @Description Delete the current account after confirming its password. The account is deactivated at once and permanently removed once the grace period ends; until then an administrator can restore it.
+// @Tags accounts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body dto.DeleteAccountRequest true "Password confirmation"
+// @Success 202 {object} dto.AccountDeletionResponse
+// @Failure 400 {object} dto.ValidationErrorResponse
+// @Failure 401 {object} dto.ErrorData
+// @Failure 500 {object} dto.ErrorData
+// @Router /accounts/me [delete]
+func (h *accountHandler) DeleteCurrentAccount(c echo.Context) error {
+	var req dto.DeleteAccountRequest
+	if err := c.Bind(&req); err != nil {
+		return errors.BadRequestError("Invalid request body")
+	}
+
+	deletion, err := h.accountService.DeleteAccount(c.Request().Context(), currentAccount(c).ID, req)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			return appErr
+		}
+		return errors.InternalError(err)
+	}
+
+	return c.JSON(http.StatusAccepted, deletion)
+}
+
+// @Summary Stop impersonating
+// @Description End the impersonation session of the impersonation token in the Authorization header. The token stops working immediately.
+// @Tags Authorization
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} dto.MessageResponse
+// @Failure 400 {object} dto.ErrorData
+// @Failure 401 {object} dto.ErrorData
+// @Failure 500 {object} dto.ErrorData
+// @Router /accounts/me/impersonation/stop [post]
+func (h *accountHandler) StopImpersonation(c echo.Context) error {
+	if err := h.accountService.StopImpersonation(c.Request().Context(), bearerToken(c)); err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			return appErr
+		}
+		return errors.InternalError(err)
+	}
+
+	return c.JSON(http.StatusOK, dto.MessageResponse{Message: "Impersonation stopped"})
+}
+
 // @Summary Get current account details
 // @Description Get account details using JWT token
 // @Tags accounts
@@ -201,16 +304,11 @@ func (h *accountHandler) GetAccountByEmail(c echo.Context) error {
 // @Failure 500 {object} dto.ErrorData
 // @Router /accounts/me [get]
 func (h *accountHandler) GetAccountByToken(c echo.Context) error {
-	authHeader := c.Request().Header.Get("Authorization")
-	if authHeader == "" {
+	token := bearerToken(c)
+	if token == "" {
 		return errors.BadRequestError("Missing authorization header")
 	}
 
-	token := authHeader
-	if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
-		token = authHeader[7:]
-	}
-
 	account, err := h.accountService.GetAccountByToken(c.Request().Context(), token)
 	if err != nil {
 		if appErr, ok := err.(*errors.AppError); ok {
@@ -219,18 +317,18 @@ func (h *accountHandler) GetAccountByToken(c echo.Context) error {
 		return errors.InternalError(err)
 	}
 
+	c.Response().Header().Set(headerETag, account.ETag)
 	return c.JSON(http.StatusOK, mapAccountToResponse(*account))
 }
 
 // @Summary Request password reset
-// @Description Request a password reset token to be sent to email, either email or phone number is required
+// @Description Request a password reset token to be sent to email, either email or phone number is required. The response is the same whether or not the account exists.
 // @Tags accounts
 // @Accept json
 // @Produce json
 // @Param request body dto.SetResetPasswordTokenRequest true "Email address"
-// @Success 200 {object} dto.StandardResponse{data=string} "Reset token"
+// @Success 202 {object} dto.MessageResponse
 // @Failure 400 {object} dto.ValidationErrorResponse
-// @Failure 404 {object} dto.ErrorData
 // @Failure 500 {object} dto.ErrorData
 // @Router /accounts/set-reset-password-token [post]
 func (h *accountHandler) SetResetPasswordToken(c echo.Context) error {
@@ -246,16 +344,15 @@ func (h *accountHandler) SetResetPasswordToken(c echo.Context) error {
 		return errors.BadRequestError(err.Error())
 	}
 
-	token, err := h.accountService.SetResetPasswordToken(c.Request().Context(), req)
-	if err != nil {
+	if err := h.accountService.SetResetPasswordToken(c.Request().Context(), req); err != nil {
 		if appErr, ok := err.(*errors.AppError); ok {
 			return appErr
 		}
 		return errors.InternalError(err)
 	}
 
-	return c.JSON(http.StatusOK, dto.TokenResponse{
-		Token: token,
+	return c.JSON(http.StatusAccepted, dto.MessageResponse{
+		Message: "If the account exists, a password reset email has been sent",
 	})
 }
 
@@ -298,11 +395,14 @@ func (h *accountHandler) ResetPassword(c echo.Context) error {
 // @Tags accounts
 // @Accept json
 // @Produce json
+// @Security BearerAuth
 // @Param id path integer true "Account ID"
 // @Success 200 {object} dto.StandardResponse{data=string} "Verification token"
 // @Failure 400 {object} dto.ErrorData
 // @Failure 404 {object} dto.ErrorData
 // @Failure 500 {object} dto.ErrorData
+// @Failure 401 {object} dto.ErrorData
+// @Failure 403 {object} dto.ErrorData
 // @Router /accounts/email-verification-token/{id} [get]
 func (h *accountHandler) GetAccountEmailVerificationTokenByID(c echo.Context) error {
 	id := c.Param("id")
@@ -360,3 +460,38 @@ func (h *accountHandler) VerifyAccountEmail(c echo.Context) error {
 
 	return c.NoContent(http.StatusOK)
 }
+
+// @Summary Resend verification email
+// @Description Issue a new email verification token and send it, invalidating the previous one. The response is the same whether or not the email is registered.
+// @Tags accounts
+// @Accept json
+// @Produce json
+// @Param request body dto.ResendEmailVerificationRequest true "Email address"
+// @Success 202 {object} dto.MessageResponse
+// @Failure 400 {object} dto.ValidationErrorResponse
+// @Failure 500 {object} dto.ErrorData
+// @Router /accounts/verify-email/resend [post]
+func (h *accountHandler) ResendEmailVerification(c echo.Context) error {
+	var req dto.ResendEmailVerificationRequest
+	if err := c.Bind(&req); err != nil {
+		return errors.BadRequestError("Invalid request body")
+	}
+
+	if err := req.Validate(); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			return errors.ValidationError("Validation failed", validationErrors)
+		}
+		return errors.BadRequestError(err.Error())
+	}
+
+	if err := h.accountService.ResendEmailVerification(c.Request().Context(), req); err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			return appErr
+		}
+		return errors.InternalError(err)
+	}
+
+	return c.JSON(http.StatusAccepted, dto.MessageResponse{
+		Message: "If the account exists and is pending verification, a verification email has been sent",
+	})
+}
@@ -0,0 +1,600 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/service"
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/ssoydabas/auth-service/pkg/errors"
+	"github.com/ssoydabas/auth-service/pkg/validator"
+
+	"github.com/labstack/echo/v4"
+)
+
+type AdminHandler interface {
+	AddRoutes(e *echo.Group)
+
+	ListRoles(c echo.Context) error
+	GetRole(c echo.Context) error
+	CreateRole(c echo.Context) error
+	UpdateRole(c echo.Context) error
+	DeleteRole(c echo.Context) error
+
+	ListPermissions(c echo.Context) error
+	CreatePermission(c echo.Context) error
+	DeletePermission(c echo.Context) error
+
+	ListAccounts(c echo.Context) error
+	UpdateAccount(c echo.Context) error
+	AssignRoles(c echo.Context) error
+}
+
+type adminHandler struct {
+	accountService service.AccountService
+	roleService    service.RoleService
+	guard          *guard
+}
+
+func NewAdminHandler(accountService service.AccountService, roleService service.RoleService) AdminHandler {
+	return &adminHandler{
+		accountService: accountService,
+		roleService:    roleService,
+		guard:          newGuard(accountService, roleService),
+	}
+}
+
+func (h *adminHandler) AddRoutes(e *echo.Group) {
+	admin := e.Group("/admin")
+
+	admin.GET("/roles", h.ListRoles, h.guard.require(models.PermissionRolesRead))
+	admin.GET("/roles/:id", h.GetRole, h.guard.require(models.PermissionRolesRead))
+	admin.POST("/roles", h.CreateRole, h.guard.require(models.PermissionRolesWrite))
+	admin.PUT("/roles/:id", h.UpdateRole, h.guard.require(models.PermissionRolesWrite))
+	admin.DELETE("/roles/:id", h.DeleteRole, h.guard.require(models.PermissionRolesWrite))
+
+	admin.GET("/permissions", h.ListPermissions, h.guard.require(models.PermissionRolesRead))
+	admin.POST("/permissions", h.CreatePermission, h.guard.require(models.PermissionRolesWrite))
+	admin.DELETE("/permissions/:id", h.DeletePermission, h.guard.require(models.PermissionRolesWrite))
+
+	admin.GET("/accounts", h.ListAccounts, h.guard.require(models.PermissionAccountsRead))
+	admin.PATCH("/accounts/:id", h.UpdateAccount, h.guard.require(models.PermissionAccountsWrite))
+	admin.PUT("/accounts/:id/roles", h.AssignRoles, h.guard.require(models.PermissionRolesAssign))
+	admin.POST("/accounts/:id/suspend", h.SuspendAccount, h.guard.require(models.PermissionAccountsSuspend))
+	admin.POST("/accounts/:id/reinstate", h.ReinstateAccount, h.guard.require(models.PermissionAccountsSuspend))
+	admin.POST("/accounts/:id/restore", h.RestoreAccount, h.guard.require(models.PermissionAccountsWrite))
+	admin.POST("/accounts/:id/impersonate", h.StartImpersonation, h.guard.require(models.PermissionAccountsImpersonate))
+}
+
+// @Summary List roles
+// @Description List every role together with the permissions it grants
+// @Tags Authorization
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} dto.RoleResponse
+// @Failure 401 {object} dto.ErrorData
+// @Failure 403 {object} dto.ErrorData
+// @Failure 500 {object} dto.ErrorData
+// @Router /admin/roles [get]
+func (h *adminHandler) ListRoles(c echo.Context) error {
+	roles, err := h.roleService.ListRoles(c.Request().Context())
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			return appErr
+		}
+		return errors.InternalError(err)
+	}
+
+	return c.JSON(http.StatusOK, roles)
+}
+
+// @Summary Get a role
+// @Description Get a single role together with the permissions it grants
+// @Tags Authorization
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path integer true "Role ID"
+// @Success 200 {object} dto.RoleResponse
+// @Failure 400 {object} dto.ErrorData
+// @Failure 401 {object} dto.ErrorData
+// @Failure 403 {object} dto.ErrorData
+// @Failure 404 {object} dto.ErrorData
+// @Failure 500 {object} dto.ErrorData
+// @Router /admin/roles/{id} [get]
+func (h *adminHandler) GetRole(c echo.Context) error {
+	id := c.Param("id")
+	if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+		return errors.BadRequestError("Invalid role ID: must be a positive number")
+	}
+
+	role, err := h.roleService.GetRole(c.Request().Context(), id)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			return appErr
+		}
+		return errors.InternalError(err)
+	}
+
+	return c.JSON(http.StatusOK, role)
+}
+
+// @Summary Create a role
+// @Description Create a custom role granting the given permissions
+// @Tags Authorization
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body dto.CreateRoleRequest true "Role"
+// @Success 201 {object} dto.RoleResponse
+// @Failure 400 {object} dto.ValidationErrorResponse
+// @Failure 401 {object} dto.ErrorData
+// @Failure 403 {object} dto.ErrorData
+// @Failure 409 {object} dto.ErrorData
+// @Failure 500 {object} dto.ErrorData
+// @Router /admin/roles [post]
+func (h *adminHandler) CreateRole(c echo.Context) error {
+	var req dto.CreateRoleRequest
+	if err := c.Bind(&req); err != nil {
+		return errors.BadRequestError("Invalid request body")
+	}
+
+	if err := req.Validate(); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			return errors.ValidationError("Validation failed", validationErrors)
+		}
+		return errors.BadRequestError(err.Error())
+	}
+
+	role, err := h.roleService.CreateRole(c.Request().Context(), currentAccount(c).ID, req)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			return appErr
+		}
+		return errors.InternalError(err)
+	}
+
+	return c.JSON(http.StatusCreated, role)
+}
+
+// @Summary Update a role
+// @Description Replace a role's name, description and permissions. Built-in roles cannot be renamed.
+// @Tags Authorization
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path integer true "Role ID"
+// @Param request body dto.UpdateRoleRequest true "Role"
+// @Success 200 {object} dto.RoleResponse
+// @Failure 400 {object} dto.ValidationErrorResponse
+// @Failure 401 {object} dto.ErrorData
+// @Failure 403 {object} dto.ErrorData
+// @Failure 404 {object} dto.ErrorData
+// @Failure 409 {object} dto.ErrorData
+// @Failure 500 {object} dto.ErrorData
+// @Router /admin/roles/{id} [put]
+func (h *adminHandler) UpdateRole(c echo.Context) error {
+	id := c.Param("id")
+	if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+		return errors.BadRequestError("Invalid role ID: must be a positive number")
+	}
+
+	var req dto.UpdateRoleRequest
+	if err := c.Bind(&req); err != nil {
+		return errors.BadRequestError("Invalid request body")
+	}
+
+	if err := req.Validate(); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			return errors.ValidationError("Validation failed", validationErrors)
+		}
+		return errors.BadRequestError(err.Error())
+	}
+
+	role, err := h.roleService.UpdateRole(c.Request().Context(), currentAccount(c).ID, id, req)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			return appErr
+		}
+		return errors.InternalError(err)
+	}
+
+	return c.JSON(http.StatusOK, role)
+}
+
+// @Summary Delete a role
+// @Description Delete a custom role and remove it from every account. Built-in roles and the default role cannot be deleted.
+// @Tags Authorization
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path integer true "Role ID"
+// @Success 204
+// @Failure 400 {object} dto.ErrorData
+// @Failure 401 {object} dto.ErrorData
+// @Failure 403 {object} dto.ErrorData
+// @Failure 404 {object} dto.ErrorData
+// @Failure 500 {object} dto.ErrorData
+// @Router /admin/roles/{id} [delete]
+func (h *adminHandler) DeleteRole(c echo.Context) error {
+	id := c.Param("id")
+	if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+		return errors.BadRequestError("Invalid role ID: must be a positive number")
+	}
+
+	if err := h.roleService.DeleteRole(c.Request().Context(), currentAccount(c).ID, id); err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			return appErr
+		}
+		return errors.InternalError(err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// @Summary List permissions
+// @Description List every permission that can be granted to a role
+// @Tags Authorization
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} dto.PermissionResponse
+// @Failure 401 {object} dto.ErrorData
+// @Failure 403 {object} dto.ErrorData
+// @Failure 500 {object} dto.ErrorData
+// @Router /admin/permissions [get]
+func (h *adminHandler) ListPermissions(c echo.Context) error {
+	permissions, err := h.roleService.ListPermissions(c.Request().Context())
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			return appErr
+		}
+		return errors.InternalError(err)
+	}
+
+	return c.JSON(http.StatusOK, permissions)
+}
+
+// @Summary Create a permission
+// @Description Create a custom permission that can then be granted to roles
+// @Tags Authorization
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body dto.CreatePermissionRequest true "Permission"
+// @Success 201 {object} dto.PermissionResponse
+// @Failure 400 {object} dto.ValidationErrorResponse
+// @Failure 401 {object} dto.ErrorData
+// @Failure 403 {object} dto.ErrorData
+// @Failure 409 {object} dto.ErrorData
+// @Failure 500 {object} dto.ErrorData
+// @Router /admin/permissions [post]
+func (h *adminHandler) CreatePermission(c echo.Context) error {
+	var req dto.CreatePermissionRequest
+	if err := c.Bind(&req); err != nil {
+		return errors.BadRequestError("Invalid request body")
+	}
+
+	if err := req.Validate(); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			return errors.ValidationError("Validation failed", validationErrors)
+		}
+		return errors.BadRequestError(err.Error())
+	}
+
+	permission, err := h.roleService.CreatePermission(c.Request().Context(), currentAccount(c).ID, req)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			return appErr
+		}
+		return errors.InternalError(err)
+	}
+
+	return c.JSON(http.StatusCreated, permission)
+}
+
+// @Summary Delete a permission
+// @Description Delete a custom permission and revoke it from every role. Built-in permissions cannot be deleted.
+// @Tags Authorization
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path integer true "Permission ID"
+// @Success 204
+// @Failure 400 {object} dto.ErrorData
+// @Failure 401 {object} dto.ErrorData
+// @Failure 403 {object} dto.ErrorData
+// @Failure 404 {object} dto.ErrorData
+// @Failure 500 {object} dto.ErrorData
+// @Router /admin/permissions/{id} [delete]
+func (h *adminHandler) DeletePermission(c echo.Context) error {
+	id := c.Param("id")
+	if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+		return errors.BadRequestError("Invalid permission ID: must be a positive number")
+	}
+
+	if err := h.roleService.DeletePermission(c.Request().Context(), currentAccount(c).ID, id); err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			return appErr
+		}
+		return errors.InternalError(err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// @Summary List accounts
+// @Description List accounts with filters, sorting and either page-number or cursor pagination
+// @Tags Authorization
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param role query string false "Only accounts holding this role"
+// @Param verification_status query string false "pending or verified"
+// @Param created_from query string false "Created at or after (RFC 3339)"
+// @Param created_to query string false "Created before (RFC 3339)"
+// @Param last_login_from query string false "Last login at or after (RFC 3339)"
+// @Param last_login_to query string false "Last login before (RFC 3339)"
+// @Param q query string false "Search in first name, last name, email and phone"
+// @Param sort query string false "Sort field, prefix with - for descending (default -created_at)"
+// @Param page query integer false "Page number, starting at 1"
+// @Param page_size query integer false "Page size (default 20, max 100)"
+// @Param cursor query string false "nextCursor of the previous page"
+// @Success 200 {object} dto.PaginatedResponse{data=[]dto.AccountResponse}
+// @Failure 400 {object} dto.ValidationErrorResponse
+// @Failure 401 {object} dto.ErrorData
+// @Failure 403 {object} dto.ErrorData
+// @Failure 500 {object} dto.ErrorData
+// @Router /admin/accounts [get]
+func (h *adminHandler) ListAccounts(c echo.Context) error {
+	var req dto.ListAccountsRequest
+	if err := c.Bind(&req); err != nil {
+		return errors.BadRequestError("Invalid query parameters")
+	}
+
+	if err := req.Validate(); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			return errors.ValidationError("Validation failed", validationErrors)
+		}
+		return errors.BadRequestError(err.Error())
+	}
+
+	accounts, err := h.accountService.ListAccounts(c.Request().Context(), req)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			return appErr
+		}
+		return errors.InternalError(err)
+	}
+
+	return c.JSON(http.StatusOK, accounts)
+}
+
+// @Summary Update an account
+// @Description Update an account's profile with a JSON merge patch (RFC 7396). Send the ETag of the last read in If-Match; a stale ETag is rejected with 412.
+// @Tags Authorization
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path integer true "Account ID"
+// @Param If-Match header string true "ETag of the account"
+// @Param request body dto.UpdateAccountRequest true "Fields to change; null clears a field"
+// @Success 200 {object} dto.AccountResponse
+// @Header 200 {string} ETag "New ETag of the account"
+// @Failure 400 {object} dto.ValidationErrorResponse
+// @Failure 401 {object} dto.ErrorData
+// @Failure 403 {object} dto.ErrorData
+// @Failure 404 {object} dto.ErrorData
+// @Failure 412 {object} dto.ErrorData
+// @Failure 415 {object} dto.ErrorData
+// @Failure 428 {object} dto.ErrorData
+// @Failure 500 {object} dto.ErrorData
+// @Router /admin/accounts/{id} [patch]
+func (h *adminHandler) UpdateAccount(c echo.Context) error {
+	id := c.Param("id")
+	if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+		return errors.BadRequestError("Invalid account ID: must be a positive number")
+	}
+
+	patch, err := readMergePatch(c)
+	if err != nil {
+		return err
+	}
+
+	account, err := h.accountService.UpdateAccount(c.Request().Context(), currentAccount(c).ID, id, c.Request().Header.Get(headerIfMatch), patch)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			return appErr
+		}
+		return errors.InternalError(err)
+	}
+
+	c.Response().Header().Set(headerETag, account.ETag)
+	return c.JSON(http.StatusOK, mapAccountToResponse(*account))
+}
+
+// @Summary Replace an account's roles
+// @Description Replace the set of roles held by an account. The change is recorded in the audit log.
+// @Tags Authorization
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path integer true "Account ID"
+// @Param request body dto.AssignRolesRequest true "New roles"
+// @Success 200 {object} dto.AccountResponse
+// @Failure 400 {object} dto.ValidationErrorResponse
+// @Failure 401 {object} dto.ErrorData
+// @Failure 403 {object} dto.ErrorData
+// @Failure 404 {object} dto.ErrorData
+// @Failure 500 {object} dto.ErrorData
+// @Router /admin/accounts/{id}/roles [put]
+func (h *adminHandler) AssignRoles(c echo.Context) error {
+	id := c.Param("id")
+	if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+		return errors.BadRequestError("Invalid account ID: must be a positive number")
+	}
+
+	var req dto.AssignRolesRequest
+	if err := c.Bind(&req); err != nil {
+		return errors.BadRequestError("Invalid request body")
+	}
+
+	if err := req.Validate(); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			return errors.ValidationError("Validation failed", validationErrors)
+		}
+		return errors.BadRequestError(err.Error())
+	}
+
+	account, err := h.roleService.AssignRoles(c.Request().Context(), currentAccount(c).ID, id, req)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			return appErr
+		}
+		return errors.InternalError(err)
+	}
+
+	return c.JSON(http.StatusOK, mapAccountToResponse(*account))
+}
+
+// @Summary Suspend an account
+// @Description Suspend an account until the given time, or until it is reinstated when no time is given. A suspended account cannot sign in and its tokens are rejected with 403 ACCOUNT_SUSPENDED. The suspension is recorded in the audit log.
+// @Tags Authorization
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path integer true "Account ID"
+// @Param request body dto.SuspendAccountRequest true "Reason and optional end time"
+// @Success 200 {object} dto.AccountResponse
+// @Failure 400 {object} dto.ValidationErrorResponse
+// @Failure 401 {object} dto.ErrorData
+// @Failure 403 {object} dto.ErrorData
+// @Failure 404 {object} dto.ErrorData
+// @Failure 500 {object} dto.ErrorData
+// @Router /admin/accounts/{id}/suspend [post]
+func (h *adminHandler) SuspendAccount(c echo.Context) error {
+	id := c.Param("id")
+	if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+		return errors.BadRequestError("Invalid account ID: must be a positive number")
+	}
+
+	var req dto.SuspendAccountRequest
+	if err := c.Bind(&req); err != nil {
+		return errors.BadRequestError("Invalid request body")
+	}
+
+	account, err := h.accountService.SuspendAccount(c.Request().Context(), currentAccount(c).ID, id, req)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			return appErr
+		}
+		return errors.InternalError(err)
+	}
+
+	c.Response().Header().Set(headerETag, account.ETag)
+	return c.JSON(http.StatusOK, mapAccountToResponse(*account))
+}
+
+// @Summary Reinstate an account
+// @Description Lift the suspension of an account. The change is recorded in the audit log.
+// @Tags Authorization
+// @Produce json
+// @Security BearerAuth
+// @Param id path integer true "Account ID"
+// @Success 200 {object} dto.AccountResponse
+// @Failure 400 {object} dto.ErrorData
+// @Failure 401 {object} dto.ErrorData
+// @Failure 403 {object} dto.ErrorData
+// @Failure 404 {object} dto.ErrorData
+// @Failure 409 {object} dto.ErrorData
+// @Failure 500 {object} dto.ErrorData
+// @Router /admin/accounts/{id}/reinstate [post]
+func (h *adminHandler) ReinstateAccount(c echo.Context) error {
+	id := c.Param("id")
+	if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+		return errors.BadRequestError("Invalid account ID: must be a positive number")
+	}
+
+	account, err := h.accountService.ReinstateAccount(c.Request().Context(), currentAccount(c).ID, id)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			return appErr
+		}
+		return errors.InternalError(err)
+	}
+
+	c.Response().Header().Set(headerETag, account.ETag)
+	return c.JSON(http.StatusOK, mapAccountToResponse(*account))
+}
+
+// @Summary Restore a deleted account
+// @Description Restore an account deleted by its owner, as long as the deletion grace period has not ended. The change is recorded in the audit log.
+// @Tags Authorization
+// @Produce json
+// @Security BearerAuth
+// @Param id path integer true "Account ID"
+// @Success 200 {object} dto.AccountResponse
+// @Failure 400 {object} dto.ErrorData
+// @Failure 401 {object} dto.ErrorData
+// @Failure 403 {object} dto.ErrorData
+// @Failure 404 {object} dto.ErrorData
+// @Failure 409 {object} dto.ErrorData
+// @Failure 500 {object} dto.ErrorData
+// @Router /admin/accounts/{id}/restore [post]
+func (h *adminHandler) RestoreAccount(c echo.Context) error {
+	id := c.Param("id")
+	if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+		return errors.BadRequestError("Invalid account ID: must be a positive number")
+	}
+
+	account, err := h.accountService.RestoreAccount(c.Request().Context(), currentAccount(c).ID, id)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			return appErr
+		}
+		return errors.InternalError(err)
+	}
+
+	c.Response().Header().Set(headerETag, account.ETag)
+	return c.JSON(http.StatusOK, mapAccountToResponse(*account))
+}
+
+// @Summary Impersonate an account
+// @Description Issue a short-lived token that acts as the account. Its sub claim is the account and its act claim the administrator (RFC 8693). Impersonation tokens cannot change credentials or delete the account. Starting and stopping impersonation is recorded in the audit log.
+// @Tags Authorization
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path integer true "Account ID"
+// @Param request body dto.StartImpersonationRequest true "Reason for the impersonation"
+// @Success 201 {object} dto.ImpersonationResponse
+// @Failure 400 {object} dto.ValidationErrorResponse
+// @Failure 401 {object} dto.ErrorData
+// @Failure 403 {object} dto.ErrorData
+// @Failure 404 {object} dto.ErrorData
+// @Failure 409 {object} dto.ErrorData
+// @Failure 500 {object} dto.ErrorData
+// @Router /admin/accounts/{id}/impersonate [post]
+func (h *adminHandler) StartImpersonation(c echo.Context) error {
+	id := c.Param("id")
+	if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+		return errors.BadRequestError("Invalid account ID: must be a positive number")
+	}
+
+	var req dto.StartImpersonationRequest
+	if err := c.Bind(&req); err != nil {
+		return errors.BadRequestError("Invalid request body")
+	}
+
+	impersonation, err := h.accountService.StartImpersonation(c.Request().Context(), currentAccount(c).ID, id, req)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			return appErr
+		}
+		return errors.InternalError(err)
+	}
+
+	return c.JSON(http.StatusCreated, impersonation)
+}
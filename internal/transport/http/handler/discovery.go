@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/ssoydabas/auth-service/internal/service"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+type DiscoveryHandler interface {
+	AddRoutes(e *echo.Group)
+
+	OpenIDConfiguration(c echo.Context) error
+}
+
+type discoveryHandler struct {
+	oauthService service.OAuthService
+}
+
+// NewDiscoveryHandler serves the documents under /.well-known.
+func NewDiscoveryHandler(oauthService service.OAuthService) DiscoveryHandler {
+	return &discoveryHandler{oauthService: oauthService}
+}
+
+func (h *discoveryHandler) AddRoutes(e *echo.Group) {
+	e.GET("/openid-configuration", h.OpenIDConfiguration, middleware.CORSWithConfig(middleware.CORSConfig{
+		AllowOrigins: []string{"*"},
+		AllowMethods: []string{http.MethodGet},
+	}))
+}
+
+// @Summary OpenID Connect discovery
+// @Description Return the OpenID Provider metadata (OpenID Connect Discovery 1.0) that clients use to find the endpoints, signing keys and supported features.
+// @Tags Authentication
+// @Produce json
+// @Success 200 {object} dto.OpenIDConfigurationResponse
+// @Router /.well-known/openid-configuration [get]
+func (h *discoveryHandler) OpenIDConfiguration(c echo.Context) error {
+	c.Response().Header().Set(echo.HeaderCacheControl, "public, max-age=3600")
+	return c.JSON(http.StatusOK, h.oauthService.Discovery())
+}
@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ssoydabas/auth-service/internal/service"
+	"github.com/ssoydabas/auth-service/pkg/errors"
+
+	"github.com/labstack/echo/v4"
+)
+
+type ExportHandler interface {
+	AddRoutes(e *echo.Group)
+
+	RequestExport(c echo.Context) error
+	GetExport(c echo.Context) error
+	DownloadExport(c echo.Context) error
+}
+
+type exportHandler struct {
+	exportService service.DataExportService
+	guard         *guard
+}
+
+func NewExportHandler(accountService service.AccountService, roleService service.RoleService, exportService service.DataExportService) ExportHandler {
+	return &exportHandler{
+		exportService: exportService,
+		guard:         newGuard(accountService, roleService),
+	}
+}
+
+func (h *exportHandler) AddRoutes(e *echo.Group) {
+	e.POST("/accounts/me/export", h.RequestExport, h.guard.authenticate, h.guard.denyImpersonation)
+	e.GET("/accounts/me/exports/:id", h.GetExport, h.guard.authenticate, h.guard.denyImpersonation)
+	e.GET("/exports/:id/download", h.DownloadExport)
+}
+
+// @Summary Export account data
+// @Description Request a machine-readable archive of the current account's personal data. The archive is generated in the background; when it is ready a signed, expiring download link is emailed to the account and returned by the status endpoint. While an export is still being generated the same export is returned.
+// @Tags accounts
+// @Produce json
+// @Security BearerAuth
+// @Success 202 {object} dto.DataExportResponse
+// @Failure 401 {object} dto.ErrorData
+// @Failure 403 {object} dto.ErrorData
+// @Failure 500 {object} dto.ErrorData
+// @Router /accounts/me/export [post]
+func (h *exportHandler) RequestExport(c echo.Context) error {
+	export, err := h.exportService.RequestExport(c.Request().Context(), currentAccount(c).ID)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			return appErr
+		}
+		return errors.InternalError(err)
+	}
+
+	return c.JSON(http.StatusAccepted, export)
+}
+
+// @Summary Get data export status
+// @Description Get the status of a data export of the current account, with its download link once it is ready.
+// @Tags accounts
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Export ID"
+// @Success 200 {object} dto.DataExportResponse
+// @Failure 401 {object} dto.ErrorData
+// @Failure 403 {object} dto.ErrorData
+// @Failure 404 {object} dto.ErrorData
+// @Failure 500 {object} dto.ErrorData
+// @Router /accounts/me/exports/{id} [get]
+func (h *exportHandler) GetExport(c echo.Context) error {
+	export, err := h.exportService.GetExport(c.Request().Context(), currentAccount(c).ID, c.Param("id"))
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			return appErr
+		}
+		return errors.InternalError(err)
+	}
+
+	return c.JSON(http.StatusOK, export)
+}
+
+// @Summary Download a data export
+// @Description Download a data export archive through a signed link. The link works without authentication until it expires.
+// @Tags accounts
+// @Produce application/zip
+// @Param id path string true "Export ID"
+// @Param expires query integer true "Expiry of the link as a Unix timestamp"
+// @Param signature query string true "Link signature"
+// @Success 200 {file} binary
+// @Failure 403 {object} dto.ErrorData
+// @Failure 404 {object} dto.ErrorData
+// @Router /exports/{id}/download [get]
+func (h *exportHandler) DownloadExport(c echo.Context) error {
+	body, info, err := h.exportService.OpenDownload(c.Request().Context(), c.Param("id"), c.QueryParam("expires"), c.QueryParam("signature"))
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			return appErr
+		}
+		return errors.InternalError(err)
+	}
+	defer body.Close()
+
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="account-data-%s.zip"`, time.Now().UTC().Format("2006-01-02")))
+	header.Set("Cache-Control", "no-store")
+	header.Set("X-Content-Type-Options", "nosniff")
+
+	return c.Stream(http.StatusOK, info.ContentType, body)
+}
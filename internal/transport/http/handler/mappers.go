@@ -15,5 +15,10 @@ func mapAccountToResponse(account dto.AccountResponse) dto.AccountResponse {
 		CreatedAt:          account.CreatedAt,
 		UpdatedAt:          account.UpdatedAt,
 		VerificationStatus: account.VerificationStatus,
+		Roles:              account.Roles,
+		LastLoginAt:        account.LastLoginAt,
+		Locale:             account.Locale,
+		Suspension:         account.Suspension,
+		ImpersonatedBy:     account.ImpersonatedBy,
 	}
 }
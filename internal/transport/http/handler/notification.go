@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/notification"
+	"github.com/ssoydabas/auth-service/internal/service"
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/ssoydabas/auth-service/pkg/errors"
+
+	"github.com/labstack/echo/v4"
+)
+
+type NotificationHandler interface {
+	AddRoutes(e *echo.Group)
+
+	PreviewTemplate(c echo.Context) error
+}
+
+type notificationHandler struct {
+	templates *notification.Renderer
+	guard     *guard
+}
+
+func NewNotificationHandler(accountService service.AccountService, roleService service.RoleService, templates *notification.Renderer) NotificationHandler {
+	return &notificationHandler{
+		templates: templates,
+		guard:     newGuard(accountService, roleService),
+	}
+}
+
+func (h *notificationHandler) AddRoutes(e *echo.Group) {
+	admin := e.Group("/admin")
+
+	admin.GET("/notifications/templates/:kind/preview", h.PreviewTemplate, h.guard.require(models.PermissionNotificationsPreview))
+}
+
+// @Summary Preview a notification template
+// @Description Render a notification template with sample data. The locale query parameter falls back to Accept-Language.
+// @Tags Authorization
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param kind path string true "Message kind" Enums(email_verification, password_reset, magic_link, security_alert)
+// @Param locale query string false "Locale, e.g. en or tr"
+// @Success 200 {object} dto.NotificationPreviewResponse
+// @Failure 401 {object} dto.ErrorData
+// @Failure 403 {object} dto.ErrorData
+// @Failure 404 {object} dto.ErrorData
+// @Failure 500 {object} dto.ErrorData
+// @Router /admin/notifications/templates/{kind}/preview [get]
+func (h *notificationHandler) PreviewTemplate(c echo.Context) error {
+	kind := notification.MessageKind(c.Param("kind"))
+	if !h.templates.HasKind(kind) {
+		return errors.NotFoundError("Notification template not found")
+	}
+
+	locale := h.templates.ResolveLocale(c.QueryParam("locale"), c.Request().Header.Get("Accept-Language"))
+
+	content, err := h.templates.Render(kind, locale, notification.SampleData(kind))
+	if err != nil {
+		return errors.InternalError(err)
+	}
+
+	return c.JSON(http.StatusOK, dto.NotificationPreviewResponse{
+		Kind:    string(kind),
+		Locale:  locale,
+		Subject: content.Subject,
+		Text:    content.Text,
+		HTML:    content.HTML,
+	})
+}
@@ -0,0 +1,569 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/oauth"
+	"github.com/ssoydabas/auth-service/internal/service"
+	"github.com/ssoydabas/auth-service/internal/transport/http/pages"
+	"github.com/ssoydabas/auth-service/pkg/errors"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+const (
+	sessionCookieName = "auth_session"
+	csrfCookieName    = "auth_csrf"
+	csrfFormField     = "csrf_token"
+)
+
+// authorizePath is the authorization endpoint; the consent page posts to
+// consentPath with the same query.
+const (
+	authorizePath = "/oauth/authorize"
+	consentPath   = authorizePath + "/consent"
+)
+
+type OAuthHandler interface {
+	AddRoutes(e *echo.Group)
+
+	Authorize(c echo.Context) error
+	Login(c echo.Context) error
+	Consent(c echo.Context) error
+	EndSession(c echo.Context) error
+	ConfirmEndSession(c echo.Context) error
+	Token(c echo.Context) error
+	DeviceAuthorization(c echo.Context) error
+	Device(c echo.Context) error
+	DeviceLogin(c echo.Context) error
+	DeviceConfirm(c echo.Context) error
+	RegisterClient(c echo.Context) error
+	GetClientRegistration(c echo.Context) error
+	UpdateClientRegistration(c echo.Context) error
+	DeleteClientRegistration(c echo.Context) error
+	FederatedLogin(c echo.Context) error
+	FederatedCallback(c echo.Context) error
+	SAMLMetadata(c echo.Context) error
+	SAMLAssertionConsumer(c echo.Context) error
+	UserInfo(c echo.Context) error
+	JWKS(c echo.Context) error
+}
+
+type oauthHandler struct {
+	oauthService      service.OAuthService
+	federationService service.FederationService
+	pages             *pages.Renderer
+	issuer            string
+	secure            bool
+}
+
+// NewOAuthHandler serves the OAuth endpoints under /oauth. issuer is the
+// public base URL of the service; cookies are marked Secure when it is
+// served over https. The sign-in pages offer the identity providers of
+// federationService next to the password form.
+func NewOAuthHandler(oauthService service.OAuthService, federationService service.FederationService, renderer *pages.Renderer, issuer string) OAuthHandler {
+	return &oauthHandler{
+		oauthService:      oauthService,
+		federationService: federationService,
+		pages:             renderer,
+		issuer:            issuer,
+		secure:            strings.HasPrefix(issuer, "https://"),
+	}
+}
+
+func (h *oauthHandler) AddRoutes(e *echo.Group) {
+	// The token and device authorization endpoints are called from browser
+	// apps on other origins. They take no cookies, so any origin may call
+	// them.
+	tokenCORS := middleware.CORSWithConfig(middleware.CORSConfig{
+		AllowOrigins: []string{"*"},
+		AllowMethods: []string{http.MethodPost},
+		AllowHeaders: []string{echo.HeaderContentType, echo.HeaderAuthorization},
+	})
+
+	// Userinfo takes a bearer token rather than cookies, and the keys are
+	// public, so both are open to any origin as well.
+	userInfoCORS := middleware.CORSWithConfig(middleware.CORSConfig{
+		AllowOrigins: []string{"*"},
+		AllowMethods: []string{http.MethodGet, http.MethodPost},
+		AllowHeaders: []string{echo.HeaderContentType, echo.HeaderAuthorization},
+	})
+
+	e.GET("/authorize", h.Authorize)
+	e.POST("/authorize", h.Login)
+	e.POST("/authorize/consent", h.Consent)
+	e.Match([]string{http.MethodGet, http.MethodPost}, "/logout", h.EndSession)
+	e.POST("/logout/confirm", h.ConfirmEndSession)
+	e.Match([]string{http.MethodPost, http.MethodOptions}, "/token", h.Token, tokenCORS)
+	e.Match([]string{http.MethodPost, http.MethodOptions}, "/device_authorization", h.DeviceAuthorization, tokenCORS)
+	e.GET("/device", h.Device)
+	e.POST("/device/login", h.DeviceLogin)
+	e.POST("/device/confirm", h.DeviceConfirm)
+	e.POST("/register", h.RegisterClient)
+	e.GET("/register/:client_id", h.GetClientRegistration)
+	e.PUT("/register/:client_id", h.UpdateClientRegistration)
+	e.DELETE("/register/:client_id", h.DeleteClientRegistration)
+	e.GET("/federated/:provider", h.FederatedLogin)
+	e.GET("/federated/:provider/callback", h.FederatedCallback)
+	e.GET("/federated/:provider/metadata", h.SAMLMetadata)
+	e.POST("/federated/:provider/acs", h.SAMLAssertionConsumer)
+	e.Match([]string{http.MethodGet, http.MethodPost, http.MethodOptions}, "/userinfo", h.UserInfo, userInfoCORS)
+	e.GET("/jwks", h.JWKS, userInfoCORS)
+}
+
+// @Summary OAuth authorization endpoint
+// @Description Start an OAuth 2.0 authorization code flow (RFC 6749 section 4.1) with PKCE (RFC 7636, S256 only). Requesting the openid scope makes it an OpenID Connect authentication request. When the browser has a session and the user has already allowed the client the requested scopes, the user is redirected back to redirect_uri at once with code, state and iss; otherwise a sign-in or consent page is shown. Errors are returned to redirect_uri unless the client or redirect URI is invalid.
+// @Tags Authentication
+// @Produce html
+// @Param response_type query string true "Must be code"
+// @Param client_id query string true "Client ID"
+// @Param redirect_uri query string false "Registered redirect URI; optional when the client has exactly one"
+// @Param scope query string false "Space-delimited scopes; defaults to all scopes of the client"
+// @Param state query string false "Opaque value returned to the client"
+// @Param code_challenge query string true "BASE64URL(SHA256(code_verifier))"
+// @Param code_challenge_method query string true "Must be S256"
+// @Param nonce query string false "Value copied into the ID token"
+// @Param prompt query string false "none to fail with login_required or consent_required instead of showing a page, login to always show the sign-in page, consent to always ask for consent"
+// @Param max_age query int false "Maximum age in seconds of the sign-in before the user must sign in again"
+// @Success 200 {string} string "Sign-in or consent page"
+// @Success 302 {string} string "Redirect to the client"
+// @Failure 400 {string} string "Error page"
+// @Router /oauth/authorize [get]
+func (h *oauthHandler) Authorize(c echo.Context) error {
+	var req dto.AuthorizeRequest
+	if err := (&echo.DefaultBinder{}).BindQueryParams(c, &req); err != nil {
+		return h.renderError(c, oauth.InvalidRequest("Invalid query parameters"))
+	}
+
+	result, err := h.oauthService.Authorize(c.Request().Context(), req, h.sessionToken(c))
+	if err != nil {
+		return h.authorizeError(c, err)
+	}
+
+	if result.LoginRequired {
+		return h.renderLogin(c, http.StatusOK, c.Request().URL.RequestURI(), loginReturnTo(c), result.ClientName, "", "")
+	}
+	if result.ConsentRequired {
+		return h.renderConsent(c, result)
+	}
+
+	return h.redirect(c, result)
+}
+
+// @Summary OAuth sign-in
+// @Description Submit the sign-in page of the authorization endpoint. The query string is that of the authorization request. Credentials are checked like POST /accounts/authenticate; on success a session cookie is set and the user is redirected back to the client, or asked for consent first.
+// @Tags Authentication
+// @Accept x-www-form-urlencoded
+// @Produce html
+// @Param identifier formData string true "Email or phone number"
+// @Param password formData string true "Password"
+// @Param csrf_token formData string true "Token from the sign-in page"
+// @Success 200 {string} string "Consent page"
+// @Success 303 {string} string "Redirect to the client"
+// @Failure 401 {string} string "Sign-in page with an error"
+// @Router /oauth/authorize [post]
+func (h *oauthHandler) Login(c echo.Context) error {
+	var req dto.AuthorizeRequest
+	if err := (&echo.DefaultBinder{}).BindQueryParams(c, &req); err != nil {
+		return h.renderError(c, oauth.InvalidRequest("Invalid query parameters"))
+	}
+
+	identifier, credentials := loginCredentials(c)
+
+	if !h.validCSRF(c) {
+		return h.retryLogin(c, req, http.StatusForbidden, identifier, "Your sign-in form expired. Please try again.")
+	}
+
+	result, err := h.oauthService.Login(c.Request().Context(), req, credentials)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			return h.retryLogin(c, req, appErr.Code, identifier, loginErrorMessage(appErr))
+		}
+		return h.authorizeError(c, err)
+	}
+
+	c.SetCookie(h.cookie(sessionCookieName, result.SessionToken, result.SessionExpiresAt))
+	if result.ConsentRequired {
+		return h.renderConsent(c, result)
+	}
+
+	h.clearCookie(c, csrfCookieName)
+	return h.redirect(c, result)
+}
+
+// @Summary OAuth consent
+// @Description Submit the consent page of the authorization endpoint. The query string is that of the authorization request. Allowing adds the requested scopes to the client's grant and redirects back to the client with a code; denying redirects back with access_denied. An expired form or session leads back to the authorization endpoint.
+// @Tags Authentication
+// @Accept x-www-form-urlencoded
+// @Produce html
+// @Param decision formData string true "approve or deny"
+// @Param csrf_token formData string true "Token from the consent page"
+// @Success 303 {string} string "Redirect to the client or the authorization endpoint"
+// @Failure 400 {string} string "Error page"
+// @Router /oauth/authorize/consent [post]
+func (h *oauthHandler) Consent(c echo.Context) error {
+	var req dto.AuthorizeRequest
+	if err := (&echo.DefaultBinder{}).BindQueryParams(c, &req); err != nil {
+		return h.renderError(c, oauth.InvalidRequest("Invalid query parameters"))
+	}
+
+	// A stale form or an unexpected answer starts over at the authorization
+	// endpoint, which asks again.
+	restart := authorizePath + "?" + c.QueryString()
+	decision := c.FormValue("decision")
+	if !h.validCSRF(c) || (decision != "approve" && decision != "deny") {
+		return c.Redirect(http.StatusSeeOther, restart)
+	}
+
+	result, err := h.oauthService.Consent(c.Request().Context(), req, h.sessionToken(c), decision == "approve")
+	if err != nil {
+		return h.authorizeError(c, err)
+	}
+	if result.LoginRequired {
+		return c.Redirect(http.StatusSeeOther, restart)
+	}
+
+	h.clearCookie(c, csrfCookieName)
+	return h.redirect(c, result)
+}
+
+// @Summary OAuth token endpoint
+// @Description Exchange an authorization code (grant_type=authorization_code, with code, redirect_uri, client_id and code_verifier) or a refresh token (grant_type=refresh_token, with refresh_token, client_id and an optional narrower scope) for tokens, obtain an access token for a confidential client itself (grant_type=client_credentials, with an optional scope), or poll for the tokens of a device authorization (grant_type=urn:ietf:params:oauth:grant-type:device_code, with device_code); until the user decides the answer is authorization_pending, or slow_down when the device polls faster than its interval. A confidential client can also exchange a user's access token for one restricted to other services (grant_type=urn:ietf:params:oauth:grant-type:token-exchange, with subject_token, subject_token_type, audience or resource, and an optional actor_token); the exchange policy decides which audiences each client may request. Confidential clients authenticate with HTTP Basic, client_secret in the body, or a private_key_jwt client_assertion, whichever they registered. Refresh tokens are rotated on every use; presenting a rotated refresh token revokes all tokens derived from the same authorization.
+// @Tags Authentication
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param grant_type formData string true "authorization_code, refresh_token, client_credentials, urn:ietf:params:oauth:grant-type:device_code or urn:ietf:params:oauth:grant-type:token-exchange"
+// @Param client_id formData string false "Client ID; required unless the client authenticates with HTTP Basic or a client assertion"
+// @Param client_secret formData string false "Client secret, for client_secret_post"
+// @Param client_assertion_type formData string false "urn:ietf:params:oauth:client-assertion-type:jwt-bearer, for private_key_jwt"
+// @Param client_assertion formData string false "Signed JWT, for private_key_jwt"
+// @Param code formData string false "Authorization code"
+// @Param redirect_uri formData string false "Redirect URI of the authorization request"
+// @Param code_verifier formData string false "PKCE code verifier"
+// @Param refresh_token formData string false "Refresh token"
+// @Param device_code formData string false "Device code"
+// @Param subject_token formData string false "Access token of the user the new token acts for"
+// @Param subject_token_type formData string false "urn:ietf:params:oauth:token-type:access_token"
+// @Param actor_token formData string false "Access token of the party acting for the user"
+// @Param actor_token_type formData string false "urn:ietf:params:oauth:token-type:access_token"
+// @Param requested_token_type formData string false "urn:ietf:params:oauth:token-type:access_token"
+// @Param audience formData []string false "Service the new token is meant for" collectionFormat(multi)
+// @Param resource formData []string false "URI of the service the new token is meant for" collectionFormat(multi)
+// @Param scope formData string false "Scope of the new access token"
+// @Success 200 {object} dto.OAuthTokenResponse
+// @Failure 400 {object} dto.OAuthErrorResponse
+// @Failure 401 {object} dto.OAuthErrorResponse
+// @Router /oauth/token [post]
+func (h *oauthHandler) Token(c echo.Context) error {
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	c.Response().Header().Set("Pragma", "no-cache")
+
+	var req dto.TokenRequest
+	if err := c.Bind(&req); err != nil {
+		return oauthJSONError(c, oauth.InvalidRequest("Invalid request body"))
+	}
+	if err := basicClientCredentials(c, &req.ClientID, &req.ClientSecret, &req.BasicAuth); err != nil {
+		return oauthJSONError(c, err)
+	}
+
+	response, err := h.oauthService.Token(c.Request().Context(), req)
+	if err != nil {
+		return clientJSONError(c, err, req.BasicAuth)
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// @Summary OpenID Connect userinfo endpoint
+// @Description Return claims about the user an access token with the openid scope was issued for. profile adds name, given_name, family_name, picture, locale and updated_at; email adds email and email_verified; phone adds phone_number.
+// @Tags Authentication
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} dto.OAuthErrorResponse
+// @Failure 403 {object} dto.OAuthErrorResponse
+// @Router /oauth/userinfo [get]
+func (h *oauthHandler) UserInfo(c echo.Context) error {
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+
+	token, ok := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+	if !ok || token == "" {
+		c.Response().Header().Set(echo.HeaderWWWAuthenticate, "Bearer")
+		return c.NoContent(http.StatusUnauthorized)
+	}
+
+	claims, err := h.oauthService.UserInfo(c.Request().Context(), token)
+	if err != nil {
+		if oauthErr, ok := err.(*oauth.Error); ok {
+			c.Response().Header().Set(echo.HeaderWWWAuthenticate, fmt.Sprintf(`Bearer error=%q, error_description=%q`, oauthErr.Code, oauthErr.Description))
+		}
+		return oauthJSONError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, claims)
+}
+
+// @Summary OpenID Connect signing keys
+// @Description Return the JSON Web Key Set that verifies ID tokens.
+// @Tags Authentication
+// @Produce json
+// @Success 200 {object} oauth.JWKS
+// @Router /oauth/jwks [get]
+func (h *oauthHandler) JWKS(c echo.Context) error {
+	c.Response().Header().Set(echo.HeaderCacheControl, "public, max-age=3600")
+	return c.JSON(http.StatusOK, h.oauthService.JWKS())
+}
+
+// basicClientCredentials takes the client ID and secret from an HTTP Basic
+// Authorization header, where both are form-encoded (RFC 6749 section
+// 2.3.1), into the fields of a request. They may not also be sent in the
+// body.
+func basicClientCredentials(c echo.Context, clientID, clientSecret *string, basicAuth *bool) error {
+	username, password, ok := c.Request().BasicAuth()
+	if !ok {
+		return nil
+	}
+
+	id, err := url.QueryUnescape(username)
+	if err != nil {
+		return oauth.InvalidRequest("Malformed Authorization header")
+	}
+	secret, err := url.QueryUnescape(password)
+	if err != nil {
+		return oauth.InvalidRequest("Malformed Authorization header")
+	}
+
+	if *clientSecret != "" {
+		return oauth.InvalidRequest("Only one client authentication method may be used")
+	}
+	if *clientID != "" && *clientID != id {
+		return oauth.InvalidRequest("client_id does not match the Authorization header")
+	}
+
+	*clientID = id
+	*clientSecret = secret
+	*basicAuth = true
+	return nil
+}
+
+// clientJSONError writes err as the error response of an endpoint clients
+// authenticate at. A client that tried HTTP Basic authentication is told to
+// retry with it (RFC 6749 section 5.2).
+func clientJSONError(c echo.Context, err error, basicAuth bool) error {
+	if oauthErr, ok := err.(*oauth.Error); ok && oauthErr.Code == oauth.ErrorInvalidClient && basicAuth {
+		c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Basic realm="oauth"`)
+	}
+	return oauthJSONError(c, err)
+}
+
+// oauthJSONError writes err as an RFC 6749 error response.
+func oauthJSONError(c echo.Context, err error) error {
+	oauthErr, ok := err.(*oauth.Error)
+	if !ok {
+		oauthErr = oauth.ServerError("")
+	}
+
+	return c.JSON(oauthErr.Status, dto.OAuthErrorResponse{
+		Error:            oauthErr.Code,
+		ErrorDescription: oauthErr.Description,
+	})
+}
+
+// authorizeError answers a failed authorization request: redirectable
+// errors go back to the client, the others are shown to the user.
+func (h *oauthHandler) authorizeError(c echo.Context, err error) error {
+	oauthErr, ok := err.(*oauth.Error)
+	if !ok {
+		oauthErr = oauth.ServerError("")
+	}
+
+	if oauthErr.Redirectable() {
+		return c.Redirect(h.redirectStatus(c), oauthErr.RedirectURL(h.issuer))
+	}
+
+	return h.renderError(c, oauthErr)
+}
+
+func (h *oauthHandler) redirect(c echo.Context, result *service.AuthorizeResult) error {
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	return c.Redirect(h.redirectStatus(c), result.RedirectURL)
+}
+
+// redirectStatus answers a submitted form with 303 so the browser follows
+// the redirect with GET.
+func (h *oauthHandler) redirectStatus(c echo.Context) int {
+	if c.Request().Method == http.MethodPost {
+		return http.StatusSeeOther
+	}
+	return http.StatusFound
+}
+
+// retryLogin shows the sign-in page again after a rejected attempt. The
+// authorization request is checked again, without a session, to name the
+// client.
+func (h *oauthHandler) retryLogin(c echo.Context, req dto.AuthorizeRequest, status int, identifier, message string) error {
+	result, err := h.oauthService.Authorize(c.Request().Context(), req, "")
+	if err != nil {
+		return h.authorizeError(c, err)
+	}
+
+	return h.renderLogin(c, status, c.Request().URL.RequestURI(), loginReturnTo(c), result.ClientName, identifier, message)
+}
+
+// loginReturnTo is where signing in with an identity provider leads back
+// to from the sign-in page of the authorization endpoint: the authorization
+// request, without a prompt=login the new session already answers.
+func loginReturnTo(c echo.Context) string {
+	query := c.Request().URL.Query()
+	if query.Get("prompt") == oauth.PromptLogin {
+		query.Del("prompt")
+	}
+	return authorizePath + "?" + query.Encode()
+}
+
+// renderLogin shows the sign-in page, which posts to action. Signing in
+// with an identity provider instead leads back to returnTo.
+func (h *oauthHandler) renderLogin(c echo.Context, status int, action, returnTo, clientName, identifier, message string) error {
+	csrfToken, err := h.newCSRFToken(c)
+	if err != nil {
+		return h.renderError(c, oauth.ServerError(""))
+	}
+
+	return h.render(c, status, pages.Login, pages.Data{
+		Title:      "Sign in",
+		ClientName: clientName,
+		Action:     action,
+		CSRFToken:  csrfToken,
+		Identifier: identifier,
+		Error:      message,
+		Providers:  h.providerLinks(returnTo),
+	})
+}
+
+// renderConsent asks the user to allow the client the scopes in result.
+// The form posts to the consent endpoint with the authorization request.
+func (h *oauthHandler) renderConsent(c echo.Context, result *service.AuthorizeResult) error {
+	csrfToken, err := h.newCSRFToken(c)
+	if err != nil {
+		return h.renderError(c, oauth.ServerError(""))
+	}
+
+	return h.render(c, http.StatusOK, pages.Consent, pages.Data{
+		Title:      "Allow access",
+		ClientName: result.ClientName,
+		Action:     consentPath + "?" + c.QueryString(),
+		CSRFToken:  csrfToken,
+		Scopes:     result.Scopes,
+	})
+}
+
+func (h *oauthHandler) renderError(c echo.Context, oauthErr *oauth.Error) error {
+	message := oauthErr.Description
+	if message == "" {
+		message = "The request could not be completed."
+	}
+
+	return h.render(c, oauthErr.Status, pages.Error, pages.Data{
+		Title:   "Sign-in error",
+		Error:   message,
+		Message: "Return to the application and try again.",
+	})
+}
+
+// render writes an HTML page that must not be cached or framed by other
+// sites.
+func (h *oauthHandler) render(c echo.Context, status int, name string, data pages.Data) error {
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentType, echo.MIMETextHTMLCharsetUTF8)
+	header.Set(echo.HeaderCacheControl, "no-store")
+	header.Set(echo.HeaderXFrameOptions, "DENY")
+	header.Set(echo.HeaderContentSecurityPolicy, "frame-ancestors 'none'")
+
+	c.Response().WriteHeader(status)
+	return h.pages.Render(c.Response(), name, data)
+}
+
+// newCSRFToken sets the cookie of a double-submit token for a form and
+// returns the token to put in the form.
+func (h *oauthHandler) newCSRFToken(c echo.Context) (string, error) {
+	token, err := oauth.NewToken()
+	if err != nil {
+		return "", err
+	}
+	c.SetCookie(h.cookie(csrfCookieName, token, time.Time{}))
+	return token, nil
+}
+
+// validCSRF checks the double-submit token of a submitted form against its
+// cookie.
+func (h *oauthHandler) validCSRF(c echo.Context) bool {
+	cookie, err := c.Cookie(csrfCookieName)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(c.FormValue(csrfFormField))) == 1
+}
+
+func (h *oauthHandler) sessionToken(c echo.Context) string {
+	cookie, err := c.Cookie(sessionCookieName)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+// cookie builds a cookie scoped to the OAuth endpoints. A zero expiresAt
+// makes it a browser-session cookie.
+func (h *oauthHandler) cookie(name, value string, expiresAt time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/oauth",
+		Expires:  expiresAt,
+		HttpOnly: true,
+		Secure:   h.secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+func (h *oauthHandler) clearCookie(c echo.Context, name string) {
+	cookie := h.cookie(name, "", time.Time{})
+	cookie.MaxAge = -1
+	c.SetCookie(cookie)
+}
+
+// loginCredentials reads the sign-in form. The identifier is an email
+// address if it contains @, otherwise a phone number.
+func loginCredentials(c echo.Context) (string, dto.AuthenticateAccountRequest) {
+	identifier := strings.TrimSpace(c.FormValue("identifier"))
+
+	credentials := dto.AuthenticateAccountRequest{Password: c.FormValue("password")}
+	if strings.Contains(identifier, "@") {
+		credentials.Email = identifier
+	} else {
+		credentials.Phone = identifier
+	}
+
+	return identifier, credentials
+}
+
+// loginErrorMessage turns a rejected sign-in into the message shown on the
+// sign-in page. Unknown accounts and wrong passwords get the same message
+// so the page does not reveal which accounts exist.
+func loginErrorMessage(err *errors.AppError) string {
+	switch err.Type {
+	case errors.ErrorTypeNotFound, errors.ErrorTypeAuth, errors.ErrorTypeValidation, errors.ErrorTypeBadRequest:
+		return "Incorrect email, phone or password."
+	case errors.ErrorTypeInternal:
+		return "Something went wrong. Please try again."
+	default:
+		return err.Message
+	}
+}
@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/service"
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/ssoydabas/auth-service/pkg/errors"
+	"github.com/ssoydabas/auth-service/pkg/validator"
+
+	"github.com/labstack/echo/v4"
+)
+
+type OAuthClientHandler interface {
+	AddRoutes(e *echo.Group)
+
+	CreateOAuthClient(c echo.Context) error
+	ListOAuthClients(c echo.Context) error
+	RotateOAuthClientSecret(c echo.Context) error
+	DisableOAuthClient(c echo.Context) error
+	CreateInitialAccessToken(c echo.Context) error
+}
+
+type oauthClientHandler struct {
+	oauthService service.OAuthService
+	guard        *guard
+}
+
+func NewOAuthClientHandler(accountService service.AccountService, roleService service.RoleService, oauthService service.OAuthService) OAuthClientHandler {
+	return &oauthClientHandler{
+		oauthService: oauthService,
+		guard:        newGuard(accountService, roleService),
+	}
+}
+
+func (h *oauthClientHandler) AddRoutes(e *echo.Group) {
+	admin := e.Group("/admin")
+
+	admin.POST("/oauth/clients", h.CreateOAuthClient, h.guard.require(models.PermissionOAuthClients))
+	admin.GET("/oauth/clients", h.ListOAuthClients, h.guard.require(models.PermissionOAuthClients))
+	admin.POST("/oauth/clients/:client_id/secret", h.RotateOAuthClientSecret, h.guard.require(models.PermissionOAuthClients))
+	admin.POST("/oauth/clients/:client_id/disable", h.DisableOAuthClient, h.guard.require(models.PermissionOAuthClients))
+	admin.POST("/oauth/initial-access-tokens", h.CreateInitialAccessToken, h.guard.require(models.PermissionOAuthClients))
+}
+
+// @Summary Register an OAuth client
+// @Description Register an OAuth client. Public clients (token_endpoint_auth_method none, the default) sign accounts in with the authorization code flow and PKCE. Confidential clients authenticate with a generated secret (client_secret_basic or client_secret_post), returned only in this response, or with JWTs signed by a key in jwks (private_key_jwt), and may use the client_credentials grant. Redirect URIs must use https, http on a loopback address, or a private-use scheme such as com.example.app.
+// @Tags Authorization
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param client body dto.CreateOAuthClientRequest true "Client details"
+// @Success 201 {object} dto.OAuthClientResponse
+// @Failure 400 {object} dto.ValidationErrorResponse
+// @Failure 401 {object} dto.ErrorData
+// @Failure 403 {object} dto.ErrorData
+// @Failure 500 {object} dto.ErrorData
+// @Router /admin/oauth/clients [post]
+func (h *oauthClientHandler) CreateOAuthClient(c echo.Context) error {
+	var req dto.CreateOAuthClientRequest
+	if err := c.Bind(&req); err != nil {
+		return errors.BadRequestError("Invalid request body")
+	}
+
+	if err := req.Validate(); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			return errors.ValidationError("Validation failed", validationErrors)
+		}
+		return errors.BadRequestError(err.Error())
+	}
+
+	client, err := h.oauthService.CreateClient(c.Request().Context(), currentAccount(c).ID, req)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			return appErr
+		}
+		return errors.InternalError(err)
+	}
+
+	return c.JSON(http.StatusCreated, client)
+}
+
+// @Summary List OAuth clients
+// @Description List the registered OAuth clients
+// @Tags Authorization
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} dto.OAuthClientResponse
+// @Failure 401 {object} dto.ErrorData
+// @Failure 403 {object} dto.ErrorData
+// @Failure 500 {object} dto.ErrorData
+// @Router /admin/oauth/clients [get]
+func (h *oauthClientHandler) ListOAuthClients(c echo.Context) error {
+	clients, err := h.oauthService.ListClients(c.Request().Context())
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			return appErr
+		}
+		return errors.InternalError(err)
+	}
+
+	return c.JSON(http.StatusOK, clients)
+}
+
+// @Summary Rotate an OAuth client secret
+// @Description Generate a new secret for a client that authenticates with one. The new secret is only returned in this response. The previous secret keeps working for OAUTH_CLIENT_SECRET_GRACE_PERIOD.
+// @Tags Authorization
+// @Produce json
+// @Security BearerAuth
+// @Param client_id path string true "Client ID"
+// @Success 200 {object} dto.OAuthClientResponse
+// @Failure 400 {object} dto.ErrorData
+// @Failure 401 {object} dto.ErrorData
+// @Failure 403 {object} dto.ErrorData
+// @Failure 404 {object} dto.ErrorData
+// @Failure 409 {object} dto.ErrorData
+// @Failure 500 {object} dto.ErrorData
+// @Router /admin/oauth/clients/{client_id}/secret [post]
+func (h *oauthClientHandler) RotateOAuthClientSecret(c echo.Context) error {
+	client, err := h.oauthService.RotateClientSecret(c.Request().Context(), currentAccount(c).ID, c.Param("client_id"))
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			return appErr
+		}
+		return errors.InternalError(err)
+	}
+
+	return c.JSON(http.StatusOK, client)
+}
+
+// @Summary Disable an OAuth client
+// @Description Stop a client from signing accounts in and obtaining tokens, and revoke its refresh tokens. Access tokens already issued stay valid until they expire.
+// @Tags Authorization
+// @Produce json
+// @Security BearerAuth
+// @Param client_id path string true "Client ID"
+// @Success 200 {object} dto.OAuthClientResponse
+// @Failure 401 {object} dto.ErrorData
+// @Failure 403 {object} dto.ErrorData
+// @Failure 404 {object} dto.ErrorData
+// @Failure 409 {object} dto.ErrorData
+// @Failure 500 {object} dto.ErrorData
+// @Router /admin/oauth/clients/{client_id}/disable [post]
+func (h *oauthClientHandler) DisableOAuthClient(c echo.Context) error {
+	client, err := h.oauthService.DisableClient(c.Request().Context(), currentAccount(c).ID, c.Param("client_id"))
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			return appErr
+		}
+		return errors.InternalError(err)
+	}
+
+	return c.JSON(http.StatusOK, client)
+}
+
+// @Summary Issue an OAuth initial access token
+// @Description Issue a token that registers one client at the dynamic client registration endpoint, /oauth/register. Hand it to the team that registers the client. It expires after OAUTH_INITIAL_ACCESS_TOKEN_TTL and is only returned in this response.
+// @Tags Authorization
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param token body dto.CreateInitialAccessTokenRequest true "Token details"
+// @Success 201 {object} dto.InitialAccessTokenResponse
+// @Failure 400 {object} dto.ValidationErrorResponse
+// @Failure 401 {object} dto.ErrorData
+// @Failure 403 {object} dto.ErrorData
+// @Failure 500 {object} dto.ErrorData
+// @Router /admin/oauth/initial-access-tokens [post]
+func (h *oauthClientHandler) CreateInitialAccessToken(c echo.Context) error {
+	var req dto.CreateInitialAccessTokenRequest
+	if err := c.Bind(&req); err != nil {
+		return errors.BadRequestError("Invalid request body")
+	}
+
+	if err := req.Validate(); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			return errors.ValidationError("Validation failed", validationErrors)
+		}
+		return errors.BadRequestError(err.Error())
+	}
+
+	token, err := h.oauthService.IssueInitialAccessToken(c.Request().Context(), currentAccount(c).ID, req)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			return appErr
+		}
+		return errors.InternalError(err)
+	}
+
+	return c.JSON(http.StatusCreated, token)
+}
@@ -0,0 +1,214 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/oauth"
+	"github.com/ssoydabas/auth-service/internal/service"
+	"github.com/ssoydabas/auth-service/internal/transport/http/pages"
+	"github.com/ssoydabas/auth-service/pkg/errors"
+
+	"github.com/labstack/echo/v4"
+)
+
+// devicePath is the verification URI of the device authorization grant.
+const devicePath = "/oauth/device"
+
+// @Summary OAuth device authorization endpoint
+// @Description Start the device authorization grant (RFC 8628) on a device without a usable browser. Show user_code and verification_uri (or verification_uri_complete, for example as a QR code) to the user, then poll POST /oauth/token with grant_type=urn:ietf:params:oauth:grant-type:device_code and device_code every interval seconds until the user has decided or the codes expire. Clients authenticate as they do at the token endpoint.
+// @Tags Authentication
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param client_id formData string false "Client ID; required unless the client authenticates with HTTP Basic or a client assertion"
+// @Param client_secret formData string false "Client secret, for client_secret_post"
+// @Param client_assertion_type formData string false "urn:ietf:params:oauth:client-assertion-type:jwt-bearer, for private_key_jwt"
+// @Param client_assertion formData string false "Signed JWT, for private_key_jwt"
+// @Param scope formData string false "Space-delimited scopes; defaults to all scopes of the client"
+// @Success 200 {object} dto.DeviceAuthorizationResponse
+// @Failure 400 {object} dto.OAuthErrorResponse
+// @Failure 401 {object} dto.OAuthErrorResponse
+// @Router /oauth/device_authorization [post]
+func (h *oauthHandler) DeviceAuthorization(c echo.Context) error {
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	c.Response().Header().Set("Pragma", "no-cache")
+
+	var req dto.DeviceAuthorizationRequest
+	if err := c.Bind(&req); err != nil {
+		return oauthJSONError(c, oauth.InvalidRequest("Invalid request body"))
+	}
+	if err := basicClientCredentials(c, &req.ClientID, &req.ClientSecret, &req.BasicAuth); err != nil {
+		return oauthJSONError(c, err)
+	}
+
+	response, err := h.oauthService.DeviceAuthorization(c.Request().Context(), req)
+	if err != nil {
+		return clientJSONError(c, err, req.BasicAuth)
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// @Summary OAuth device verification page
+// @Description The page users open to approve a device. Without a session a sign-in page is shown first; then the user enters the code shown on the device, unless user_code is given, and is asked to allow or deny the device.
+// @Tags Authentication
+// @Produce html
+// @Param user_code query string false "Code shown on the device"
+// @Success 200 {string} string "Sign-in, code entry or confirmation page"
+// @Failure 400 {string} string "Code entry page with an error"
+// @Failure 404 {string} string "Code entry page with an error"
+// @Router /oauth/device [get]
+func (h *oauthHandler) Device(c echo.Context) error {
+	userCode := strings.TrimSpace(c.QueryParam("user_code"))
+
+	result, err := h.oauthService.VerifyDeviceCode(c.Request().Context(), h.sessionToken(c), userCode)
+	if err != nil {
+		return h.deviceError(c, userCode, err)
+	}
+
+	switch {
+	case result.LoginRequired:
+		return h.renderLogin(c, http.StatusOK, deviceURL(devicePath+"/login", userCode), deviceURL(devicePath, userCode), "", "", "")
+	case result.UserCode == "":
+		return h.renderDevice(c, http.StatusOK, "", "")
+	default:
+		return h.renderDeviceConfirm(c, result)
+	}
+}
+
+// @Summary OAuth device sign-in
+// @Description Submit the sign-in page of the device verification page. Credentials are checked like POST /accounts/authenticate; on success a session cookie is set and the user is redirected back to the verification page.
+// @Tags Authentication
+// @Accept x-www-form-urlencoded
+// @Produce html
+// @Param user_code query string false "Code shown on the device"
+// @Param identifier formData string true "Email or phone number"
+// @Param password formData string true "Password"
+// @Param csrf_token formData string true "Token from the sign-in page"
+// @Success 303 {string} string "Redirect to the verification page"
+// @Failure 401 {string} string "Sign-in page with an error"
+// @Router /oauth/device/login [post]
+func (h *oauthHandler) DeviceLogin(c echo.Context) error {
+	userCode := strings.TrimSpace(c.QueryParam("user_code"))
+	action := deviceURL(devicePath+"/login", userCode)
+	identifier, credentials := loginCredentials(c)
+
+	if !h.validCSRF(c) {
+		return h.renderLogin(c, http.StatusForbidden, action, deviceURL(devicePath, userCode), "", identifier, "Your sign-in form expired. Please try again.")
+	}
+
+	result, err := h.oauthService.SignIn(c.Request().Context(), credentials)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			return h.renderLogin(c, appErr.Code, action, deviceURL(devicePath, userCode), "", identifier, loginErrorMessage(appErr))
+		}
+		return h.renderError(c, oauth.ServerError(""))
+	}
+
+	h.clearCookie(c, csrfCookieName)
+	c.SetCookie(h.cookie(sessionCookieName, result.SessionToken, result.SessionExpiresAt))
+
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	return c.Redirect(http.StatusSeeOther, deviceURL(devicePath, userCode))
+}
+
+// @Summary OAuth device confirmation
+// @Description Allow or deny the device behind a user code. An allowed device receives tokens for the signed-in account at its next poll of the token endpoint; a denied one receives access_denied.
+// @Tags Authentication
+// @Accept x-www-form-urlencoded
+// @Produce html
+// @Param user_code formData string true "Code shown on the device"
+// @Param decision formData string true "approve or deny"
+// @Param csrf_token formData string true "Token from the confirmation page"
+// @Success 200 {string} string "Result page"
+// @Success 303 {string} string "Redirect to sign in again"
+// @Failure 400 {string} string "Code entry page with an error"
+// @Failure 404 {string} string "Code entry page with an error"
+// @Router /oauth/device/confirm [post]
+func (h *oauthHandler) DeviceConfirm(c echo.Context) error {
+	userCode := strings.TrimSpace(c.FormValue("user_code"))
+
+	if !h.validCSRF(c) {
+		return h.renderDevice(c, http.StatusForbidden, userCode, "Your form expired. Please try again.")
+	}
+
+	decision := c.FormValue("decision")
+	if decision != "approve" && decision != "deny" {
+		return h.renderDevice(c, http.StatusBadRequest, userCode, "Choose whether to allow the device.")
+	}
+	approve := decision == "approve"
+
+	result, err := h.oauthService.DecideDeviceCode(c.Request().Context(), h.sessionToken(c), userCode, approve)
+	if err != nil {
+		return h.deviceError(c, userCode, err)
+	}
+	if result.LoginRequired {
+		return c.Redirect(http.StatusSeeOther, deviceURL(devicePath, userCode))
+	}
+
+	h.clearCookie(c, csrfCookieName)
+
+	if !approve {
+		return h.render(c, http.StatusOK, pages.Notice, pages.Data{
+			Title:   "Device denied",
+			Message: result.ClientName + " was not given access to your account. You can close this page.",
+		})
+	}
+
+	return h.render(c, http.StatusOK, pages.Notice, pages.Data{
+		Title:   "Device connected",
+		Message: result.ClientName + " now has access to your account. You can return to your device.",
+	})
+}
+
+// deviceError answers a failed verification. Problems with the code the
+// user entered are shown on the code entry page so it can be corrected.
+func (h *oauthHandler) deviceError(c echo.Context, userCode string, err error) error {
+	switch e := err.(type) {
+	case *errors.AppError:
+		message := e.Message
+		if e.Type == errors.ErrorTypeInternal {
+			message = "Something went wrong. Please try again."
+		}
+		return h.renderDevice(c, e.Code, userCode, message)
+	case *oauth.Error:
+		return h.renderError(c, e)
+	default:
+		return h.renderError(c, oauth.ServerError(""))
+	}
+}
+
+func (h *oauthHandler) renderDevice(c echo.Context, status int, userCode, message string) error {
+	return h.render(c, status, pages.Device, pages.Data{
+		Title:    "Connect a device",
+		Action:   devicePath,
+		UserCode: userCode,
+		Error:    message,
+	})
+}
+
+func (h *oauthHandler) renderDeviceConfirm(c echo.Context, result *service.DeviceVerification) error {
+	csrfToken, err := h.newCSRFToken(c)
+	if err != nil {
+		return h.renderError(c, oauth.ServerError(""))
+	}
+
+	return h.render(c, http.StatusOK, pages.DeviceConfirm, pages.Data{
+		Title:      "Connect a device",
+		ClientName: result.ClientName,
+		Action:     devicePath + "/confirm",
+		CSRFToken:  csrfToken,
+		UserCode:   result.UserCode,
+		Scopes:     result.Scopes,
+	})
+}
+
+// deviceURL returns path with the user code, if any, in its query.
+func deviceURL(path, userCode string) string {
+	if userCode == "" {
+		return path
+	}
+	return path + "?" + url.Values{"user_code": {userCode}}.Encode()
+}
@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/oauth"
+	"github.com/ssoydabas/auth-service/internal/transport/http/pages"
+
+	"github.com/labstack/echo/v4"
+)
+
+// federationCookieName holds the state of a sign-in sent to an identity
+// provider, binding the callback to the browser that started it.
+const federationCookieName = "auth_federation"
+
+// federatedPath starts a sign-in with the identity provider named after it.
+const federatedPath = "/oauth/federated"
+
+// @Summary Sign in with an identity provider
+// @Description Start signing in with an upstream OpenID Connect, OAuth 2.0 or SAML 2.0 identity provider configured in FEDERATION_PROVIDERS_FILE. The browser is sent to the provider with an authorization code request using PKCE, and the provider sends it back to /oauth/federated/{provider}/callback; or with a SAML AuthnRequest, and the provider posts the response to /oauth/federated/{provider}/acs. The sign-in pages link here for every configured provider.
+// @Tags Authentication
+// @Produce html
+// @Param provider path string true "Connector ID of the provider"
+// @Param return_to query string true "Page of the authorization server to return to once signed in, such as the authorization request"
+// @Success 302 {string} string "Redirect to the identity provider"
+// @Failure 400 {string} string "Error page"
+// @Router /oauth/federated/{provider} [get]
+func (h *oauthHandler) FederatedLogin(c echo.Context) error {
+	start, err := h.federationService.StartLogin(c.Request().Context(), c.Param("provider"), c.QueryParam("return_to"))
+	if err != nil {
+		return h.authorizeError(c, err)
+	}
+
+	cookie := h.cookie(federationCookieName, start.State, start.ExpiresAt)
+	if start.CrossSitePost {
+		// Browsers only send a cookie along with a form another site posts
+		// if it is SameSite=None, which they only accept over https.
+		cookie.SameSite = http.SameSiteDefaultMode
+		if h.secure {
+			cookie.SameSite = http.SameSiteNoneMode
+		}
+	}
+	c.SetCookie(cookie)
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	return c.Redirect(http.StatusFound, start.RedirectURL)
+}
+
+// @Summary Identity provider callback
+// @Description The redirect URI registered at upstream identity providers. The code is exchanged with the PKCE verifier, the ID token verified and the user signed in to the account linked to their identity. On the first sign-in the identity is linked to the account with the same email address, if both the provider and the account have verified it, or a new account is created from the provider's claims. A session cookie is then set and the user is sent back to the page the sign-in started from.
+// @Tags Authentication
+// @Produce html
+// @Param provider path string true "Connector ID of the provider"
+// @Param code query string false "Authorization code"
+// @Param state query string true "State of the sign-in"
+// @Param iss query string false "Issuer of the response"
+// @Param error query string false "Error code when the provider did not sign the user in"
+// @Success 302 {string} string "Redirect to the page the sign-in started from"
+// @Failure 400 {string} string "Error page"
+// @Router /oauth/federated/{provider}/callback [get]
+func (h *oauthHandler) FederatedCallback(c echo.Context) error {
+	var req dto.FederationCallbackRequest
+	if err := (&echo.DefaultBinder{}).BindQueryParams(c, &req); err != nil {
+		return h.renderError(c, oauth.InvalidRequest("Invalid query parameters"))
+	}
+
+	state := ""
+	if cookie, err := c.Cookie(federationCookieName); err == nil {
+		state = cookie.Value
+	}
+	h.clearCookie(c, federationCookieName)
+
+	result, err := h.federationService.FinishLogin(c.Request().Context(), c.Param("provider"), state, req)
+	if err != nil {
+		return h.authorizeError(c, err)
+	}
+
+	c.SetCookie(h.cookie(sessionCookieName, result.SessionToken, result.SessionExpiresAt))
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	return c.Redirect(http.StatusFound, result.ReturnTo)
+}
+
+// @Summary SAML service provider metadata
+// @Description The metadata of the service as a SAML 2.0 service provider to a SAML identity provider configured in FEDERATION_PROVIDERS_FILE, for registering it there. Its entity ID is the URL of this document and its assertion consumer service /oauth/federated/{provider}/acs, with the HTTP-POST binding.
+// @Tags Authentication
+// @Produce xml
+// @Param provider path string true "Connector ID of the provider"
+// @Success 200 {string} string "EntityDescriptor of the service provider"
+// @Failure 400 {object} dto.OAuthErrorResponse
+// @Router /oauth/federated/{provider}/metadata [get]
+func (h *oauthHandler) SAMLMetadata(c echo.Context) error {
+	metadata, err := h.federationService.SAMLMetadata(c.Param("provider"))
+	if err != nil {
+		return oauthJSONError(c, err)
+	}
+
+	return c.Blob(http.StatusOK, "application/samlmetadata+xml", metadata)
+}
+
+// @Summary SAML assertion consumer service
+// @Description Where SAML identity providers post their responses, with the HTTP-POST binding. The response must answer the AuthnRequest of a sign-in started in the same browser, be signed with a certificate from the provider's metadata, as a whole or in its assertion, and hold one assertion for this service that is still valid. The user is then signed in like at /oauth/federated/{provider}/callback: a session cookie is set and the user is sent back to the page the sign-in started from.
+// @Tags Authentication
+// @Accept x-www-form-urlencoded
+// @Produce html
+// @Param provider path string true "Connector ID of the provider"
+// @Param SAMLResponse formData string true "Base64 encoded response"
+// @Param RelayState formData string true "State of the sign-in"
+// @Success 303 {string} string "Redirect to the page the sign-in started from"
+// @Failure 400 {string} string "Error page"
+// @Router /oauth/federated/{provider}/acs [post]
+func (h *oauthHandler) SAMLAssertionConsumer(c echo.Context) error {
+	req := dto.SAMLResponseRequest{
+		SAMLResponse: c.FormValue("SAMLResponse"),
+		RelayState:   c.FormValue("RelayState"),
+	}
+
+	state := ""
+	if cookie, err := c.Cookie(federationCookieName); err == nil {
+		state = cookie.Value
+	}
+	h.clearCookie(c, federationCookieName)
+
+	result, err := h.federationService.FinishSAMLLogin(c.Request().Context(), c.Param("provider"), state, req)
+	if err != nil {
+		return h.authorizeError(c, err)
+	}
+
+	c.SetCookie(h.cookie(sessionCookieName, result.SessionToken, result.SessionExpiresAt))
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	return c.Redirect(http.StatusSeeOther, result.ReturnTo)
+}
+
+// providerLinks returns the links that sign in with each identity provider
+// and lead back to returnTo.
+func (h *oauthHandler) providerLinks(returnTo string) []pages.Link {
+	var links []pages.Link
+	for _, provider := range h.federationService.Providers() {
+		links = append(links, pages.Link{
+			Label: provider.Name,
+			URL:   federatedPath + "/" + url.PathEscape(provider.ID) + "?" + url.Values{"return_to": {returnTo}}.Encode(),
+		})
+	}
+	return links
+}
@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/ssoydabas/auth-service/internal/service"
+	"github.com/ssoydabas/auth-service/pkg/errors"
+
+	"github.com/labstack/echo/v4"
+)
+
+type OAuthGrantHandler interface {
+	AddRoutes(e *echo.Group)
+
+	ListOAuthGrants(c echo.Context) error
+	RevokeOAuthGrant(c echo.Context) error
+}
+
+type oauthGrantHandler struct {
+	oauthService service.OAuthService
+	guard        *guard
+}
+
+func NewOAuthGrantHandler(accountService service.AccountService, roleService service.RoleService, oauthService service.OAuthService) OAuthGrantHandler {
+	return &oauthGrantHandler{
+		oauthService: oauthService,
+		guard:        newGuard(accountService, roleService),
+	}
+}
+
+func (h *oauthGrantHandler) AddRoutes(e *echo.Group) {
+	e.GET("/accounts/me/grants", h.ListOAuthGrants, h.guard.authenticate)
+	e.DELETE("/accounts/me/grants/:client_id", h.RevokeOAuthGrant, h.guard.authenticate)
+}
+
+// @Summary List OAuth grants
+// @Description List the OAuth clients the current account has allowed to access it, with the scopes allowed to each.
+// @Tags accounts
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} dto.OAuthGrantResponse
+// @Failure 401 {object} dto.ErrorData
+// @Failure 500 {object} dto.ErrorData
+// @Router /accounts/me/grants [get]
+func (h *oauthGrantHandler) ListOAuthGrants(c echo.Context) error {
+	grants, err := h.oauthService.ListGrants(c.Request().Context(), currentAccount(c).ID)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			return appErr
+		}
+		return errors.InternalError(err)
+	}
+
+	return c.JSON(http.StatusOK, grants)
+}
+
+// @Summary Revoke an OAuth grant
+// @Description Withdraw the access the current account allowed a client. The client's refresh tokens for the account are revoked and it has to ask for consent again. Access tokens already issued stay valid until they expire.
+// @Tags accounts
+// @Security BearerAuth
+// @Param client_id path string true "Client ID"
+// @Success 204
+// @Failure 401 {object} dto.ErrorData
+// @Failure 404 {object} dto.ErrorData
+// @Failure 500 {object} dto.ErrorData
+// @Router /accounts/me/grants/{client_id} [delete]
+func (h *oauthGrantHandler) RevokeOAuthGrant(c echo.Context) error {
+	if err := h.oauthService.RevokeGrant(c.Request().Context(), currentAccount(c).ID, c.Param("client_id")); err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			return appErr
+		}
+		return errors.InternalError(err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/oauth"
+	"github.com/ssoydabas/auth-service/internal/service"
+	"github.com/ssoydabas/auth-service/internal/transport/http/pages"
+
+	"github.com/labstack/echo/v4"
+)
+
+// logoutPath is the end session endpoint; the confirmation page posts to
+// logoutConfirmPath with the logout request in the query.
+const (
+	logoutPath        = "/oauth/logout"
+	logoutConfirmPath = logoutPath + "/confirm"
+)
+
+// @Summary OpenID Connect end session endpoint
+// @Description Sign the user out of the browser's session (OpenID Connect RP-Initiated Logout 1.0). With an id_token_hint issued during the session the session ends at once; otherwise the user is asked to confirm first. Ending the session revokes the refresh tokens issued during it and sends a logout token to the back-channel logout URI of every client that signed in with it. The browser is then sent to post_logout_redirect_uri with state, which must be registered for the client named by client_id or the hint, or shown a notice.
+// @Tags Authentication
+// @Accept x-www-form-urlencoded
+// @Produce html
+// @Param id_token_hint query string false "ID token issued to the client"
+// @Param client_id query string false "Client ID; required with post_logout_redirect_uri unless id_token_hint is given"
+// @Param post_logout_redirect_uri query string false "Registered post-logout redirect URI"
+// @Param state query string false "Opaque value returned to the client"
+// @Success 200 {string} string "Confirmation or notice page"
+// @Success 302 {string} string "Redirect to the client"
+// @Failure 400 {string} string "Error page"
+// @Router /oauth/logout [get]
+func (h *oauthHandler) EndSession(c echo.Context) error {
+	var req dto.EndSessionRequest
+	if err := c.Bind(&req); err != nil {
+		return h.renderError(c, oauth.InvalidRequest("Invalid logout request"))
+	}
+
+	result, err := h.oauthService.EndSession(c.Request().Context(), req, h.sessionToken(c), false)
+	if err != nil {
+		return h.logoutError(c, err)
+	}
+	if result.ConfirmationRequired {
+		return h.renderLogout(c, req, result)
+	}
+
+	return h.loggedOut(c, result)
+}
+
+// @Summary OpenID Connect logout confirmation
+// @Description Submit the confirmation page of the end session endpoint. The query string is that of the logout request. An expired form leads back to the end session endpoint.
+// @Tags Authentication
+// @Accept x-www-form-urlencoded
+// @Produce html
+// @Param csrf_token formData string true "Token from the confirmation page"
+// @Success 200 {string} string "Notice page"
+// @Success 303 {string} string "Redirect to the client or the end session endpoint"
+// @Failure 400 {string} string "Error page"
+// @Router /oauth/logout/confirm [post]
+func (h *oauthHandler) ConfirmEndSession(c echo.Context) error {
+	var req dto.EndSessionRequest
+	if err := (&echo.DefaultBinder{}).BindQueryParams(c, &req); err != nil {
+		return h.renderError(c, oauth.InvalidRequest("Invalid logout request"))
+	}
+
+	if !h.validCSRF(c) {
+		return c.Redirect(http.StatusSeeOther, logoutPath+"?"+c.QueryString())
+	}
+
+	result, err := h.oauthService.EndSession(c.Request().Context(), req, h.sessionToken(c), true)
+	if err != nil {
+		return h.logoutError(c, err)
+	}
+
+	h.clearCookie(c, csrfCookieName)
+	return h.loggedOut(c, result)
+}
+
+// loggedOut clears the session cookie and sends the browser back to the
+// client, or tells the user they are signed out.
+func (h *oauthHandler) loggedOut(c echo.Context, result *service.LogoutResult) error {
+	h.clearCookie(c, sessionCookieName)
+
+	if result.RedirectURL != "" {
+		return h.redirect(c, &service.AuthorizeResult{RedirectURL: result.RedirectURL})
+	}
+
+	return h.render(c, http.StatusOK, pages.Notice, pages.Data{
+		Title:   "Signed out",
+		Message: "You have been signed out. You can close this page.",
+	})
+}
+
+// renderLogout asks the user to confirm the logout request req. The form
+// posts the request in the query, since a request sent as a form has none.
+func (h *oauthHandler) renderLogout(c echo.Context, req dto.EndSessionRequest, result *service.LogoutResult) error {
+	csrfToken, err := h.newCSRFToken(c)
+	if err != nil {
+		return h.renderError(c, oauth.ServerError(""))
+	}
+
+	query := url.Values{}
+	for name, value := range map[string]string{
+		"id_token_hint":            req.IDTokenHint,
+		"client_id":                req.ClientID,
+		"post_logout_redirect_uri": req.PostLogoutRedirectURI,
+		"state":                    req.State,
+	} {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+
+	return h.render(c, http.StatusOK, pages.Logout, pages.Data{
+		Title:      "Sign out",
+		ClientName: result.ClientName,
+		Action:     logoutConfirmPath + "?" + query.Encode(),
+		CSRFToken:  csrfToken,
+	})
+}
+
+// logoutError shows a rejected logout request. The redirect URI of such a
+// request cannot be trusted, so the user is never sent back.
+func (h *oauthHandler) logoutError(c echo.Context, err error) error {
+	oauthErr, ok := err.(*oauth.Error)
+	if !ok {
+		oauthErr = oauth.ServerError("")
+	}
+
+	message := oauthErr.Description
+	if message == "" {
+		message = "The request could not be completed."
+	}
+
+	return h.render(c, oauthErr.Status, pages.Error, pages.Data{
+		Title:   "Sign-out error",
+		Error:   message,
+		Message: "Return to the application and try again.",
+	})
+}
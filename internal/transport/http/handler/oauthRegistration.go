@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/oauth"
+
+	"github.com/labstack/echo/v4"
+)
+
+// @Summary OAuth dynamic client registration
+// @Description Register a client with the metadata in the body (RFC 7591). The request must carry an initial access token issued by an administrator as a Bearer token; each token registers one client. token_endpoint_auth_method defaults to client_secret_basic, grant_types to authorization_code and scope to OAUTH_REGISTRATION_SCOPES, which also bounds it. private_key_jwt clients give their keys in jwks or publish them at jwks_uri. The response holds the client secret, if any, and the registration access token that reads, updates and deletes the registration at registration_client_uri; neither can be retrieved later.
+// @Tags Authorization
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param client body dto.ClientRegistrationRequest true "Client metadata"
+// @Success 201 {object} dto.ClientRegistrationResponse
+// @Failure 400 {object} dto.OAuthErrorResponse
+// @Failure 401 {object} dto.OAuthErrorResponse
+// @Router /oauth/register [post]
+func (h *oauthHandler) RegisterClient(c echo.Context) error {
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	c.Response().Header().Set("Pragma", "no-cache")
+
+	var req dto.ClientRegistrationRequest
+	if err := c.Bind(&req); err != nil {
+		return oauthJSONError(c, oauth.InvalidRequest("Invalid request body"))
+	}
+
+	response, err := h.oauthService.RegisterClient(c.Request().Context(), bearerToken(c), req)
+	if err != nil {
+		return bearerJSONError(c, err)
+	}
+
+	c.Response().Header().Set(echo.HeaderLocation, response.RegistrationClientURI)
+	return c.JSON(http.StatusCreated, response)
+}
+
+// @Summary Read an OAuth client registration
+// @Description Return the registered metadata of a client (RFC 7592 section 2.1). The request must carry the client's registration access token as a Bearer token.
+// @Tags Authorization
+// @Produce json
+// @Security BearerAuth
+// @Param client_id path string true "Client ID"
+// @Success 200 {object} dto.ClientRegistrationResponse
+// @Failure 401 {object} dto.OAuthErrorResponse
+// @Router /oauth/register/{client_id} [get]
+func (h *oauthHandler) GetClientRegistration(c echo.Context) error {
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	c.Response().Header().Set("Pragma", "no-cache")
+
+	response, err := h.oauthService.GetClientRegistration(c.Request().Context(), c.Param("client_id"), bearerToken(c))
+	if err != nil {
+		return bearerJSONError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// @Summary Update an OAuth client registration
+// @Description Replace the registered metadata of a client (RFC 7592 section 2.2). The body must name the client in client_id and hold all of its metadata; fields left out are cleared or take their defaults. client_secret, if sent, must be the client's secret. A client that switches to a secret-based token_endpoint_auth_method gets a new secret in the response. The request must carry the client's registration access token as a Bearer token.
+// @Tags Authorization
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param client_id path string true "Client ID"
+// @Param client body dto.ClientRegistrationRequest true "Client metadata"
+// @Success 200 {object} dto.ClientRegistrationResponse
+// @Failure 400 {object} dto.OAuthErrorResponse
+// @Failure 401 {object} dto.OAuthErrorResponse
+// @Router /oauth/register/{client_id} [put]
+func (h *oauthHandler) UpdateClientRegistration(c echo.Context) error {
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	c.Response().Header().Set("Pragma", "no-cache")
+
+	var req dto.ClientRegistrationRequest
+	if err := c.Bind(&req); err != nil {
+		return oauthJSONError(c, oauth.InvalidRequest("Invalid request body"))
+	}
+
+	response, err := h.oauthService.UpdateClientRegistration(c.Request().Context(), c.Param("client_id"), bearerToken(c), req)
+	if err != nil {
+		return bearerJSONError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// @Summary Delete an OAuth client registration
+// @Description Delete a client (RFC 7592 section 2.3). The client can no longer sign users in or obtain tokens, its refresh tokens are revoked and its registration access token stops working. The request must carry the registration access token as a Bearer token.
+// @Tags Authorization
+// @Security BearerAuth
+// @Param client_id path string true "Client ID"
+// @Success 204
+// @Failure 401 {object} dto.OAuthErrorResponse
+// @Router /oauth/register/{client_id} [delete]
+func (h *oauthHandler) DeleteClientRegistration(c echo.Context) error {
+	if err := h.oauthService.DeleteClientRegistration(c.Request().Context(), c.Param("client_id"), bearerToken(c)); err != nil {
+		return bearerJSONError(c, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// bearerJSONError writes err as the error response of an endpoint that takes
+// a Bearer token. Token errors carry a challenge (RFC 6750 section 3).
+func bearerJSONError(c echo.Context, err error) error {
+	if oauthErr, ok := err.(*oauth.Error); ok && oauthErr.Status != http.StatusBadRequest && oauthErr.Status < http.StatusInternalServerError {
+		c.Response().Header().Set(echo.HeaderWWWAuthenticate, fmt.Sprintf(`Bearer error=%q, error_description=%q`, oauthErr.Code, oauthErr.Description))
+	}
+	return oauthJSONError(c, err)
+}
@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"io"
+	"mime"
+
+	"github.com/ssoydabas/auth-service/pkg/errors"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	headerETag    = "ETag"
+	headerIfMatch = "If-Match"
+
+	mimeMergePatchJSON = "application/merge-patch+json"
+
+	maxPatchBodySize = 64 << 10
+)
+
+// readMergePatch reads a JSON merge patch body. Both the merge patch media
+// type and plain application/json are accepted.
+func readMergePatch(c echo.Context) ([]byte, error) {
+	mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	if mediaType != mimeMergePatchJSON && mediaType != echo.MIMEApplicationJSON {
+		return nil, errors.UnsupportedMediaTypeError("Content-Type must be " + mimeMergePatchJSON)
+	}
+
+	patch, err := io.ReadAll(io.LimitReader(c.Request().Body, maxPatchBodySize+1))
+	if err != nil {
+		return nil, errors.BadRequestError("Invalid request body")
+	}
+	if len(patch) > maxPatchBodySize {
+		return nil, errors.BadRequestError("Request body is too large")
+	}
+
+	return patch, nil
+}
@@ -0,0 +1,113 @@
+package handler
+
+import (
+	stderrors "errors"
+	"net/http"
+	"strings"
+
+	"github.com/ssoydabas/auth-service/internal/service"
+	"github.com/ssoydabas/auth-service/pkg/errors"
+
+	"github.com/labstack/echo/v4"
+)
+
+// multipartOverhead is allowed on top of the photo size limit for the
+// multipart boundaries and part headers.
+const multipartOverhead = 64 << 10
+
+type PhotoHandler interface {
+	AddRoutes(e *echo.Group)
+
+	UploadPhoto(c echo.Context) error
+	GetPhoto(c echo.Context) error
+}
+
+type photoHandler struct {
+	photoService service.PhotoService
+	maxBytes     int64
+	guard        *guard
+}
+
+func NewPhotoHandler(accountService service.AccountService, roleService service.RoleService, photoService service.PhotoService, maxBytes int64) PhotoHandler {
+	return &photoHandler{
+		photoService: photoService,
+		maxBytes:     maxBytes,
+		guard:        newGuard(accountService, roleService),
+	}
+}
+
+func (h *photoHandler) AddRoutes(e *echo.Group) {
+	e.PUT("/accounts/me/photo", h.UploadPhoto, h.guard.authenticate)
+	e.GET("/photos/*", h.GetPhoto)
+}
+
+// @Summary Upload profile photo
+// @Description Upload a JPEG, PNG or GIF profile photo as the "photo" field of a multipart form. The image is re-encoded without metadata and stored in several sizes; photo_url is set to the largest.
+// @Tags accounts
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param photo formData file true "Image file"
+// @Success 200 {object} dto.PhotoResponse
+// @Failure 400 {object} dto.ErrorData
+// @Failure 401 {object} dto.ErrorData
+// @Failure 413 {object} dto.ErrorData
+// @Failure 415 {object} dto.ErrorData
+// @Failure 500 {object} dto.ErrorData
+// @Router /accounts/me/photo [put]
+func (h *photoHandler) UploadPhoto(c echo.Context) error {
+	req := c.Request()
+	req.Body = http.MaxBytesReader(c.Response(), req.Body, h.maxBytes+multipartOverhead)
+
+	header, err := c.FormFile("photo")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if stderrors.As(err, &maxBytesErr) {
+			return errors.PayloadTooLargeError("Photo is too large")
+		}
+		return errors.BadRequestError("A photo file is required in the \"photo\" form field")
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		return errors.BadRequestError("Invalid photo upload")
+	}
+	defer file.Close()
+
+	photo, err := h.photoService.UploadAccountPhoto(req.Context(), currentAccount(c).ID, file)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			return appErr
+		}
+		return errors.InternalError(err)
+	}
+
+	return c.JSON(http.StatusOK, photo)
+}
+
+// @Summary Get a stored photo
+// @Description Serve a stored profile photo. Photo URLs change on every upload, so responses are cacheable indefinitely.
+// @Tags accounts
+// @Produce image/jpeg,image/png
+// @Param path path string true "Photo path"
+// @Success 200 {file} binary
+// @Failure 404 {object} dto.ErrorData
+// @Router /photos/{path} [get]
+func (h *photoHandler) GetPhoto(c echo.Context) error {
+	key := strings.TrimPrefix(c.Param("*"), "/")
+
+	body, info, err := h.photoService.GetPhoto(c.Request().Context(), key)
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); ok {
+			return appErr
+		}
+		return errors.InternalError(err)
+	}
+	defer body.Close()
+
+	header := c.Response().Header()
+	header.Set("Cache-Control", "public, max-age=31536000, immutable")
+	header.Set("X-Content-Type-Options", "nosniff")
+
+	return c.Stream(http.StatusOK, info.ContentType, body)
+}
@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/transport/http/handler"
+	"github.com/ssoydabas/auth-service/pkg/errors"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type AccountHandlerTestSuite struct {
+	suite.Suite
+	accountService *MockAccountService
+	echo           *echo.Echo
+	err            error
+}
+
+func (suite *AccountHandlerTestSuite) SetupTest() {
+	suite.accountService = new(MockAccountService)
+	suite.err = nil
+
+	suite.echo = echo.New()
+	suite.echo.HTTPErrorHandler = func(err error, c echo.Context) {
+		suite.err = err
+	}
+	handler.NewAccountHandler(suite.accountService, new(MockRoleService)).AddRoutes(suite.echo.Group(""))
+}
+
+func (suite *AccountHandlerTestSuite) TestUpdateCurrentAccountRejectsImpersonation() {
+	// Profile changes made while impersonating would be recorded as the
+	// account's own, so they are refused.
+	adminID := uint(1)
+	suite.accountService.On("GetAccountByToken", mock.Anything, "impersonation-token").
+		Return(&dto.AccountResponse{ID: 2, ImpersonatedBy: &adminID}, nil)
+
+	req := httptest.NewRequest(http.MethodPatch, "/accounts/me", strings.NewReader(`{"first_name": "Mallory"}`))
+	req.Header.Set(echo.HeaderContentType, "application/merge-patch+json")
+	req.Header.Set(echo.HeaderAuthorization, "Bearer impersonation-token")
+	req.Header.Set("If-Match", `"etag"`)
+	suite.echo.ServeHTTP(httptest.NewRecorder(), req)
+
+	suite.Require().Error(suite.err)
+	suite.Equal(http.StatusForbidden, suite.err.(*errors.AppError).Code)
+}
+
+func TestAccountHandlerSuite(t *testing.T) {
+	suite.Run(t, new(AccountHandlerTestSuite))
+}
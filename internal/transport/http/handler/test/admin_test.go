@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/transport/http/handler"
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/ssoydabas/auth-service/pkg/errors"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type AdminHandlerTestSuite struct {
+	suite.Suite
+	accountService *MockAccountService
+	roleService    *MockRoleService
+	echo           *echo.Echo
+	err            error
+}
+
+func (suite *AdminHandlerTestSuite) SetupTest() {
+	suite.accountService = new(MockAccountService)
+	suite.roleService = new(MockRoleService)
+	suite.err = nil
+
+	suite.echo = echo.New()
+	suite.echo.HTTPErrorHandler = func(err error, c echo.Context) {
+		suite.err = err
+	}
+	handler.NewAdminHandler(suite.accountService, suite.roleService).AddRoutes(suite.echo.Group(""))
+}
+
+func (suite *AdminHandlerTestSuite) assignRoles(token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPut, "/admin/accounts/1/roles", strings.NewReader(`{"roles": ["admin"]}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	rec := httptest.NewRecorder()
+	suite.echo.ServeHTTP(rec, req)
+	return rec
+}
+
+func (suite *AdminHandlerTestSuite) TestAssignRoles() {
+	account := &dto.AccountResponse{ID: 2}
+	suite.accountService.On("GetAccountByToken", mock.Anything, "token").Return(account, nil)
+	suite.roleService.On("HasPermission", mock.Anything, account, models.PermissionRolesAssign).Return(true, nil)
+	suite.roleService.On("AssignRoles", mock.Anything, uint(2), "1", dto.AssignRolesRequest{Roles: []string{"admin"}}).
+		Return(&dto.AccountResponse{ID: 1}, nil)
+
+	rec := suite.assignRoles("token")
+
+	suite.Require().NoError(suite.err)
+	suite.Equal(http.StatusOK, rec.Code)
+}
+
+func (suite *AdminHandlerTestSuite) TestAdminRoutesRejectImpersonation() {
+	// The impersonated account may assign roles, but the administrator
+	// impersonating it must not be able to grant themselves its powers.
+	adminID := uint(1)
+	suite.accountService.On("GetAccountByToken", mock.Anything, "impersonation-token").
+		Return(&dto.AccountResponse{ID: 2, ImpersonatedBy: &adminID}, nil)
+	suite.roleService.On("HasPermission", mock.Anything, mock.Anything, mock.Anything).Return(true, nil).Maybe()
+
+	suite.assignRoles("impersonation-token")
+
+	suite.Require().Error(suite.err)
+	suite.Equal(http.StatusForbidden, suite.err.(*errors.AppError).Code)
+	suite.roleService.AssertNotCalled(suite.T(), "AssignRoles", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAdminHandlerSuite(t *testing.T) {
+	suite.Run(t, new(AdminHandlerTestSuite))
+}
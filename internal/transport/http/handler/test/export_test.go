@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/transport/http/handler"
+	"github.com/ssoydabas/auth-service/pkg/errors"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type ExportHandlerTestSuite struct {
+	suite.Suite
+	accountService *MockAccountService
+	exportService  *MockDataExportService
+	echo           *echo.Echo
+	err            error
+}
+
+func (suite *ExportHandlerTestSuite) SetupTest() {
+	suite.accountService = new(MockAccountService)
+	suite.exportService = new(MockDataExportService)
+	suite.err = nil
+
+	suite.echo = echo.New()
+	suite.echo.HTTPErrorHandler = func(err error, c echo.Context) {
+		suite.err = err
+	}
+	handler.NewExportHandler(suite.accountService, new(MockRoleService), suite.exportService).AddRoutes(suite.echo.Group(""))
+}
+
+func (suite *ExportHandlerTestSuite) serve(method, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	rec := httptest.NewRecorder()
+	suite.echo.ServeHTTP(rec, req)
+	return rec
+}
+
+func (suite *ExportHandlerTestSuite) TestRequestExport() {
+	suite.accountService.On("GetAccountByToken", mock.Anything, "token").Return(&dto.AccountResponse{ID: 2}, nil)
+	suite.exportService.On("RequestExport", mock.Anything, uint(2)).
+		Return(&dto.DataExportResponse{ID: "export-id", Status: "pending"}, nil)
+
+	rec := suite.serve(http.MethodPost, "/accounts/me/export", "token")
+
+	suite.Require().NoError(suite.err)
+	suite.Equal(http.StatusAccepted, rec.Code)
+}
+
+func (suite *ExportHandlerTestSuite) TestExportRoutesRejectImpersonation() {
+	// An administrator impersonating the account must not be able to take
+	// a copy of its personal data.
+	adminID := uint(1)
+	suite.accountService.On("GetAccountByToken", mock.Anything, "impersonation-token").
+		Return(&dto.AccountResponse{ID: 2, ImpersonatedBy: &adminID}, nil)
+
+	for _, route := range []struct{ method, path string }{
+		{http.MethodPost, "/accounts/me/export"},
+		{http.MethodGet, "/accounts/me/exports/export-id"},
+	} {
+		suite.Run(route.method+" "+route.path, func() {
+			suite.err = nil
+
+			suite.serve(route.method, route.path, "impersonation-token")
+
+			suite.Require().Error(suite.err)
+			suite.Equal(http.StatusForbidden, suite.err.(*errors.AppError).Code)
+		})
+	}
+
+	suite.exportService.AssertNotCalled(suite.T(), "RequestExport", mock.Anything, mock.Anything)
+	suite.exportService.AssertNotCalled(suite.T(), "GetExport", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestExportHandlerSuite(t *testing.T) {
+	suite.Run(t, new(ExportHandlerTestSuite))
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/service"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockAccountService is a mock implementation of the AccountService methods
+// the guard uses. Calling any other method panics.
+type MockAccountService struct {
+	service.AccountService
+	mock.Mock
+}
+
+func (m *MockAccountService) GetAccountByToken(ctx context.Context, token string) (*dto.AccountResponse, error) {
+	args := m.Called(ctx, token)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dto.AccountResponse), args.Error(1)
+}
+
+// MockRoleService is a mock implementation of the RoleService methods the
+// admin handler tests use. Calling any other method panics.
+type MockRoleService struct {
+	service.RoleService
+	mock.Mock
+}
+
+func (m *MockRoleService) HasPermission(ctx context.Context, account *dto.AccountResponse, permission string) (bool, error) {
+	args := m.Called(ctx, account, permission)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRoleService) AssignRoles(ctx context.Context, actorID uint, accountID string, req dto.AssignRolesRequest) (*dto.AccountResponse, error) {
+	args := m.Called(ctx, actorID, accountID, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dto.AccountResponse), args.Error(1)
+}
+
+// MockDataExportService is a mock implementation of the DataExportService
+// methods the export handler tests use. Calling any other method panics.
+type MockDataExportService struct {
+	service.DataExportService
+	mock.Mock
+}
+
+func (m *MockDataExportService) RequestExport(ctx context.Context, accountID uint) (*dto.DataExportResponse, error) {
+	args := m.Called(ctx, accountID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dto.DataExportResponse), args.Error(1)
+}
+
+func (m *MockDataExportService) GetExport(ctx context.Context, accountID uint, id string) (*dto.DataExportResponse, error) {
+	args := m.Called(ctx, accountID, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dto.DataExportResponse), args.Error(1)
+}
@@ -0,0 +1,92 @@
+// Package pages renders the HTML pages the authorization server shows in
+// the browser, such as the sign-in and consent pages of the OAuth
+// authorization endpoint, the device verification pages and the logout
+// confirmation.
+package pages
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"io"
+)
+
+//go:embed templates
+var embeddedTemplates embed.FS
+
+// Page names.
+const (
+	Login         = "login"
+	Error         = "error"
+	Notice        = "notice"
+	Device        = "device"
+	DeviceConfirm = "device_confirm"
+	Consent       = "consent"
+	Logout        = "logout"
+)
+
+// Data is the data every page is rendered with. Fields that do not apply to
+// a page are left empty.
+type Data struct {
+	Brand      string
+	Title      string
+	ClientName string
+	Action     string
+	CSRFToken  string
+	Identifier string
+	UserCode   string
+	Scopes     []string
+	Error      string
+	Message    string
+	Providers  []Link
+}
+
+// Link is a link shown on a page, such as a button that signs in with an
+// identity provider.
+type Link struct {
+	Label string
+	URL   string
+}
+
+// Renderer renders the embedded pages. Each page is parsed together with the
+// shared layout, which calls its "content" block.
+type Renderer struct {
+	brand string
+	pages map[string]*template.Template
+}
+
+func NewRenderer(brand string) (*Renderer, error) {
+	r := &Renderer{brand: brand, pages: map[string]*template.Template{}}
+
+	for _, name := range []string{Login, Error, Notice, Device, DeviceConfirm, Consent, Logout} {
+		page, err := template.ParseFS(embeddedTemplates, "templates/layout.html", "templates/"+name+".html")
+		if err != nil {
+			return nil, fmt.Errorf("parse page %s: %w", name, err)
+		}
+		r.pages[name] = page
+	}
+
+	return r, nil
+}
+
+// Render writes page to w. The page is rendered into a buffer first so a
+// template error never leaves a half-written response.
+func (r *Renderer) Render(w io.Writer, name string, data Data) error {
+	page, ok := r.pages[name]
+	if !ok {
+		return fmt.Errorf("unknown page %q", name)
+	}
+
+	if data.Brand == "" {
+		data.Brand = r.brand
+	}
+
+	var buf bytes.Buffer
+	if err := page.ExecuteTemplate(&buf, "layout", data); err != nil {
+		return err
+	}
+
+	_, err := buf.WriteTo(w)
+	return err
+}
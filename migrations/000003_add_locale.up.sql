@@ -0,0 +1,2 @@
+ALTER TABLE accounts
+ADD COLUMN locale VARCHAR(35) NOT NULL DEFAULT '';
@@ -0,0 +1,4 @@
+ALTER TABLE account_tokens
+DROP COLUMN email_verification_sent_at,
+DROP COLUMN email_verification_window_from,
+DROP COLUMN email_verification_send_count;
@@ -0,0 +1,4 @@
+ALTER TABLE account_tokens
+ADD COLUMN email_verification_sent_at TIMESTAMP WITH TIME ZONE,
+ADD COLUMN email_verification_window_from TIMESTAMP WITH TIME ZONE,
+ADD COLUMN email_verification_send_count INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,18 @@
+ALTER TABLE accounts
+ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'common' CHECK (role IN ('common', 'admin', 'manager', 'teacher', 'student'));
+
+-- Accounts keep the first built-in role they hold; custom roles are lost.
+UPDATE accounts SET role = picked.name
+FROM (
+    SELECT DISTINCT ON (account_roles.account_id) account_roles.account_id, roles.name
+    FROM account_roles JOIN roles ON roles.id = account_roles.role_id
+    WHERE roles.name IN ('admin', 'manager', 'teacher', 'student', 'common')
+    ORDER BY account_roles.account_id, CASE roles.name
+        WHEN 'admin' THEN 1 WHEN 'manager' THEN 2 WHEN 'teacher' THEN 3 WHEN 'student' THEN 4 ELSE 5 END
+) AS picked
+WHERE accounts.id = picked.account_id;
+
+DROP TABLE IF EXISTS account_roles;
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS roles;
+DROP TABLE IF EXISTS permissions;
@@ -0,0 +1,65 @@
+CREATE TABLE permissions (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    name TEXT NOT NULL,
+    description TEXT,
+    system BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE UNIQUE INDEX idx_permission_name ON permissions (name);
+CREATE INDEX idx_permissions_deleted_at ON permissions (deleted_at);
+
+CREATE TABLE roles (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    name TEXT NOT NULL,
+    description TEXT,
+    system BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE UNIQUE INDEX idx_role_name ON roles (name);
+CREATE INDEX idx_roles_deleted_at ON roles (deleted_at);
+
+CREATE TABLE role_permissions (
+    role_id BIGINT NOT NULL REFERENCES roles (id) ON DELETE CASCADE,
+    permission_id BIGINT NOT NULL REFERENCES permissions (id) ON DELETE CASCADE,
+    PRIMARY KEY (role_id, permission_id)
+);
+
+CREATE TABLE account_roles (
+    account_id BIGINT NOT NULL REFERENCES accounts (id) ON DELETE CASCADE,
+    role_id BIGINT NOT NULL REFERENCES roles (id) ON DELETE CASCADE,
+    PRIMARY KEY (account_id, role_id)
+);
+
+CREATE INDEX idx_account_roles_role_id ON account_roles (role_id);
+
+INSERT INTO permissions (created_at, updated_at, name, description, system) VALUES
+    (NOW(), NOW(), 'accounts:read', 'View any account', TRUE),
+    (NOW(), NOW(), 'accounts:write', 'Modify any account', TRUE),
+    (NOW(), NOW(), 'roles:read', 'View roles and permissions', TRUE),
+    (NOW(), NOW(), 'roles:write', 'Create, update and delete roles and permissions', TRUE),
+    (NOW(), NOW(), 'roles:assign', 'Change the roles of an account', TRUE),
+    (NOW(), NOW(), 'notifications:preview', 'Preview notification templates', TRUE);
+
+INSERT INTO roles (created_at, updated_at, name, system) VALUES
+    (NOW(), NOW(), 'admin', TRUE),
+    (NOW(), NOW(), 'manager', TRUE),
+    (NOW(), NOW(), 'teacher', TRUE),
+    (NOW(), NOW(), 'student', TRUE),
+    (NOW(), NOW(), 'common', TRUE);
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT roles.id, permissions.id FROM roles, permissions
+WHERE roles.name = 'admin'
+   OR (roles.name = 'manager' AND permissions.name IN ('accounts:read', 'roles:read'));
+
+INSERT INTO account_roles (account_id, role_id)
+SELECT accounts.id, roles.id FROM accounts JOIN roles ON roles.name = accounts.role;
+
+ALTER TABLE accounts
+DROP COLUMN role;
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_accounts_search;
+DROP INDEX IF EXISTS idx_accounts_verification_status;
+DROP INDEX IF EXISTS idx_accounts_last_name_id;
+DROP INDEX IF EXISTS idx_accounts_first_name_id;
+DROP INDEX IF EXISTS idx_accounts_last_login_at_id;
+DROP INDEX IF EXISTS idx_accounts_created_at_id;
@@ -0,0 +1,13 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS idx_accounts_created_at_id ON accounts (created_at, id);
+CREATE INDEX IF NOT EXISTS idx_accounts_last_login_at_id ON accounts ((COALESCE(last_login_at, '0001-01-01 00:00:00+00'::timestamptz)), id);
+CREATE INDEX IF NOT EXISTS idx_accounts_first_name_id ON accounts (first_name, id);
+CREATE INDEX IF NOT EXISTS idx_accounts_last_name_id ON accounts (last_name, id);
+CREATE INDEX IF NOT EXISTS idx_accounts_verification_status ON accounts (verification_status);
+CREATE INDEX IF NOT EXISTS idx_accounts_search ON accounts USING GIN (
+    first_name gin_trgm_ops,
+    last_name gin_trgm_ops,
+    email gin_trgm_ops,
+    phone gin_trgm_ops
+);
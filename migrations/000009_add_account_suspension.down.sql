@@ -0,0 +1,7 @@
+DELETE FROM permissions WHERE name = 'accounts:suspend';
+
+ALTER TABLE accounts
+    DROP COLUMN IF EXISTS suspended_by_id,
+    DROP COLUMN IF EXISTS suspension_reason,
+    DROP COLUMN IF EXISTS suspended_until,
+    DROP COLUMN IF EXISTS suspended_at;
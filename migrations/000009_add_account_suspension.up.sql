@@ -0,0 +1,14 @@
+ALTER TABLE accounts
+    ADD COLUMN suspended_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN suspended_until TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN suspension_reason TEXT,
+    ADD COLUMN suspended_by_id BIGINT;
+
+INSERT INTO permissions (created_at, updated_at, name, description, system)
+VALUES (NOW(), NOW(), 'accounts:suspend', 'Suspend and reinstate accounts', TRUE)
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT roles.id, permissions.id FROM roles, permissions
+WHERE roles.name = 'admin' AND permissions.name = 'accounts:suspend'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,17 @@
+CREATE TABLE data_exports (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    public_id TEXT NOT NULL,
+    account_id BIGINT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    blob_key TEXT,
+    size BIGINT,
+    expires_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_data_exports_deleted_at ON data_exports (deleted_at);
+CREATE UNIQUE INDEX idx_data_exports_public_id ON data_exports (public_id);
+CREATE INDEX idx_data_exports_account_id ON data_exports (account_id);
+CREATE INDEX idx_data_exports_expires_at ON data_exports (expires_at);
@@ -0,0 +1,3 @@
+DELETE FROM permissions WHERE name = 'accounts:impersonate';
+
+DROP TABLE IF EXISTS impersonation_sessions;
@@ -0,0 +1,26 @@
+CREATE TABLE impersonation_sessions (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    public_id TEXT NOT NULL,
+    admin_id BIGINT NOT NULL,
+    account_id BIGINT NOT NULL,
+    reason TEXT NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    ended_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_impersonation_sessions_deleted_at ON impersonation_sessions (deleted_at);
+CREATE UNIQUE INDEX idx_impersonation_sessions_public_id ON impersonation_sessions (public_id);
+CREATE INDEX idx_impersonation_sessions_admin_id ON impersonation_sessions (admin_id);
+CREATE INDEX idx_impersonation_sessions_account_id ON impersonation_sessions (account_id);
+
+INSERT INTO permissions (created_at, updated_at, name, description, system)
+VALUES (NOW(), NOW(), 'accounts:impersonate', 'Act as another account', TRUE)
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT roles.id, permissions.id FROM roles, permissions
+WHERE roles.name = 'admin' AND permissions.name = 'accounts:impersonate'
+ON CONFLICT DO NOTHING;
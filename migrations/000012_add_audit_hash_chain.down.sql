@@ -0,0 +1,12 @@
+DELETE FROM permissions WHERE name = 'audit:read';
+
+DROP INDEX IF EXISTS idx_audit_events_outcome;
+DROP INDEX IF EXISTS idx_audit_events_sequence;
+
+ALTER TABLE audit_events
+    DROP COLUMN IF EXISTS hash,
+    DROP COLUMN IF EXISTS prev_hash,
+    DROP COLUMN IF EXISTS outcome,
+    DROP COLUMN IF EXISTS user_agent,
+    DROP COLUMN IF EXISTS ip,
+    DROP COLUMN IF EXISTS sequence;
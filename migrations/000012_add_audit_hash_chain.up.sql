@@ -0,0 +1,22 @@
+ALTER TABLE audit_events
+    ADD COLUMN sequence BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN ip TEXT NOT NULL DEFAULT '',
+    ADD COLUMN user_agent TEXT NOT NULL DEFAULT '',
+    ADD COLUMN outcome TEXT NOT NULL DEFAULT 'success',
+    ADD COLUMN prev_hash TEXT NOT NULL DEFAULT '',
+    ADD COLUMN hash TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX idx_audit_events_sequence ON audit_events (sequence);
+CREATE INDEX idx_audit_events_outcome ON audit_events (outcome);
+
+-- Existing events are linked into the hash chain by the service on startup,
+-- since the hashes are computed in Go (see pkg/postgres/audit.go).
+
+INSERT INTO permissions (created_at, updated_at, name, description, system)
+VALUES (NOW(), NOW(), 'audit:read', 'View and verify the audit log', TRUE)
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT roles.id, permissions.id FROM roles, permissions
+WHERE roles.name = 'admin' AND permissions.name = 'audit:read'
+ON CONFLICT DO NOTHING;
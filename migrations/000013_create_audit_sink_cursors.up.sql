@@ -0,0 +1,8 @@
+CREATE TABLE audit_sink_cursors (
+    name TEXT PRIMARY KEY,
+    sequence BIGINT NOT NULL DEFAULT 0,
+    event_id BIGINT NOT NULL DEFAULT 0,
+    owner TEXT NOT NULL DEFAULT '',
+    lease_until TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE
+);
@@ -0,0 +1,6 @@
+DELETE FROM permissions WHERE name = 'oauth:clients';
+
+DROP TABLE IF EXISTS o_auth_refresh_tokens;
+DROP TABLE IF EXISTS o_auth_authorization_codes;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS o_auth_clients;
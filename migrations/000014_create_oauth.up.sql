@@ -0,0 +1,88 @@
+CREATE TABLE o_auth_clients (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    client_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    redirect_uris JSONB NOT NULL,
+    scopes JSONB NOT NULL,
+    disabled_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_o_auth_clients_deleted_at ON o_auth_clients (deleted_at);
+CREATE UNIQUE INDEX idx_o_auth_clients_client_id ON o_auth_clients (client_id);
+
+CREATE TABLE sessions (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    public_id TEXT NOT NULL,
+    token_hash TEXT NOT NULL,
+    account_id BIGINT NOT NULL,
+    auth_time TIMESTAMP WITH TIME ZONE NOT NULL,
+    ip TEXT,
+    user_agent TEXT,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_sessions_deleted_at ON sessions (deleted_at);
+CREATE UNIQUE INDEX idx_sessions_public_id ON sessions (public_id);
+CREATE UNIQUE INDEX idx_sessions_token_hash ON sessions (token_hash);
+CREATE INDEX idx_sessions_account_id ON sessions (account_id);
+
+CREATE TABLE o_auth_authorization_codes (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    code_hash TEXT NOT NULL,
+    client_id TEXT NOT NULL,
+    account_id BIGINT NOT NULL,
+    session_id TEXT,
+    redirect_uri TEXT,
+    scope TEXT,
+    code_challenge TEXT NOT NULL,
+    code_challenge_method TEXT NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_o_auth_authorization_codes_deleted_at ON o_auth_authorization_codes (deleted_at);
+CREATE UNIQUE INDEX idx_o_auth_authorization_codes_code_hash ON o_auth_authorization_codes (code_hash);
+CREATE INDEX idx_o_auth_authorization_codes_client_id ON o_auth_authorization_codes (client_id);
+CREATE INDEX idx_o_auth_authorization_codes_account_id ON o_auth_authorization_codes (account_id);
+
+CREATE TABLE o_auth_refresh_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    token_hash TEXT NOT NULL,
+    family_id TEXT NOT NULL,
+    authorization_code_id BIGINT,
+    client_id TEXT NOT NULL,
+    account_id BIGINT NOT NULL,
+    session_id TEXT,
+    scope TEXT,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_o_auth_refresh_tokens_deleted_at ON o_auth_refresh_tokens (deleted_at);
+CREATE UNIQUE INDEX idx_o_auth_refresh_tokens_token_hash ON o_auth_refresh_tokens (token_hash);
+CREATE INDEX idx_o_auth_refresh_tokens_family_id ON o_auth_refresh_tokens (family_id);
+CREATE INDEX idx_o_auth_refresh_tokens_authorization_code_id ON o_auth_refresh_tokens (authorization_code_id);
+CREATE INDEX idx_o_auth_refresh_tokens_client_id ON o_auth_refresh_tokens (client_id);
+CREATE INDEX idx_o_auth_refresh_tokens_account_id ON o_auth_refresh_tokens (account_id);
+
+INSERT INTO permissions (created_at, updated_at, name, description, system)
+VALUES (NOW(), NOW(), 'oauth:clients', 'Manage OAuth clients', TRUE)
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT roles.id, permissions.id FROM roles, permissions
+WHERE roles.name = 'admin' AND permissions.name = 'oauth:clients'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,10 @@
+ALTER TABLE o_auth_refresh_tokens
+    DROP COLUMN IF EXISTS amr,
+    DROP COLUMN IF EXISTS auth_time;
+
+ALTER TABLE o_auth_authorization_codes
+    DROP COLUMN IF EXISTS amr,
+    DROP COLUMN IF EXISTS auth_time,
+    DROP COLUMN IF EXISTS nonce;
+
+ALTER TABLE sessions DROP COLUMN IF EXISTS amr;
@@ -0,0 +1,10 @@
+ALTER TABLE sessions ADD COLUMN amr JSONB;
+
+ALTER TABLE o_auth_authorization_codes
+    ADD COLUMN nonce TEXT,
+    ADD COLUMN auth_time TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN amr JSONB;
+
+ALTER TABLE o_auth_refresh_tokens
+    ADD COLUMN auth_time TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN amr JSONB;
@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS o_auth_client_assertions;
+
+ALTER TABLE o_auth_clients
+    DROP COLUMN IF EXISTS jwks,
+    DROP COLUMN IF EXISTS secret_rotated_at,
+    DROP COLUMN IF EXISTS previous_secret_expires_at,
+    DROP COLUMN IF EXISTS previous_secret_hash,
+    DROP COLUMN IF EXISTS secret_hash,
+    DROP COLUMN IF EXISTS token_endpoint_auth_method,
+    DROP COLUMN IF EXISTS grant_types;
@@ -0,0 +1,21 @@
+ALTER TABLE o_auth_clients
+    ADD COLUMN grant_types JSONB,
+    ADD COLUMN token_endpoint_auth_method TEXT NOT NULL DEFAULT 'none',
+    ADD COLUMN secret_hash TEXT,
+    ADD COLUMN previous_secret_hash TEXT,
+    ADD COLUMN previous_secret_expires_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN secret_rotated_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN jwks JSONB;
+
+-- Clients registered before grant types existed sign users in.
+UPDATE o_auth_clients SET grant_types = '["authorization_code", "refresh_token"]' WHERE grant_types IS NULL;
+
+CREATE TABLE o_auth_client_assertions (
+    id BIGSERIAL PRIMARY KEY,
+    client_id TEXT NOT NULL,
+    jti TEXT NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_oauth_client_assertion ON o_auth_client_assertions (client_id, jti);
+CREATE INDEX idx_o_auth_client_assertions_expires_at ON o_auth_client_assertions (expires_at);
@@ -0,0 +1,27 @@
+CREATE TABLE o_auth_device_codes (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    device_code_hash TEXT NOT NULL,
+    user_code_hash TEXT NOT NULL,
+    client_id TEXT NOT NULL,
+    scope TEXT,
+    account_id BIGINT,
+    session_id TEXT,
+    auth_time TIMESTAMP WITH TIME ZONE,
+    amr JSONB,
+    poll_interval BIGINT NOT NULL,
+    last_polled_at TIMESTAMP WITH TIME ZONE,
+    approved_at TIMESTAMP WITH TIME ZONE,
+    denied_at TIMESTAMP WITH TIME ZONE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_o_auth_device_codes_deleted_at ON o_auth_device_codes (deleted_at);
+CREATE UNIQUE INDEX idx_o_auth_device_codes_device_code_hash ON o_auth_device_codes (device_code_hash);
+CREATE UNIQUE INDEX idx_o_auth_device_codes_user_code_hash ON o_auth_device_codes (user_code_hash);
+CREATE INDEX idx_o_auth_device_codes_client_id ON o_auth_device_codes (client_id);
+CREATE INDEX idx_o_auth_device_codes_account_id ON o_auth_device_codes (account_id);
+CREATE INDEX idx_o_auth_device_codes_expires_at ON o_auth_device_codes (expires_at);
@@ -0,0 +1,11 @@
+CREATE TABLE o_auth_grants (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    account_id BIGINT NOT NULL,
+    client_id TEXT NOT NULL,
+    scopes JSONB NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_oauth_grant ON o_auth_grants (account_id, client_id);
+CREATE INDEX idx_o_auth_grants_client_id ON o_auth_grants (client_id);
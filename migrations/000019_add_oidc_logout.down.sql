@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_o_auth_device_codes_session_id;
+DROP INDEX IF EXISTS idx_o_auth_refresh_tokens_session_id;
+DROP INDEX IF EXISTS idx_o_auth_authorization_codes_session_id;
+
+ALTER TABLE o_auth_clients
+    DROP COLUMN IF EXISTS backchannel_logout_uri,
+    DROP COLUMN IF EXISTS post_logout_redirect_uris;
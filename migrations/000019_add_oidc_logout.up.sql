@@ -0,0 +1,7 @@
+ALTER TABLE o_auth_clients
+    ADD COLUMN post_logout_redirect_uris JSONB,
+    ADD COLUMN backchannel_logout_uri TEXT;
+
+CREATE INDEX idx_o_auth_authorization_codes_session_id ON o_auth_authorization_codes (session_id);
+CREATE INDEX idx_o_auth_refresh_tokens_session_id ON o_auth_refresh_tokens (session_id);
+CREATE INDEX idx_o_auth_device_codes_session_id ON o_auth_device_codes (session_id);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS o_auth_initial_access_tokens;
+
+ALTER TABLE o_auth_clients
+    DROP COLUMN IF EXISTS registration_token_hash,
+    DROP COLUMN IF EXISTS jwks_uri;
@@ -0,0 +1,16 @@
+ALTER TABLE o_auth_clients
+    ADD COLUMN jwks_uri TEXT,
+    ADD COLUMN registration_token_hash TEXT;
+
+CREATE TABLE o_auth_initial_access_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE,
+    token_hash TEXT NOT NULL,
+    description TEXT,
+    created_by BIGINT NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    client_id TEXT
+);
+
+CREATE UNIQUE INDEX idx_o_auth_initial_access_tokens_token_hash ON o_auth_initial_access_tokens (token_hash);
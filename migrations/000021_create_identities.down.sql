@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_phone;
+CREATE UNIQUE INDEX idx_phone ON accounts (phone);
+
+DROP TABLE IF EXISTS federated_logins;
+DROP TABLE IF EXISTS identities;
@@ -0,0 +1,31 @@
+CREATE TABLE identities (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    account_id BIGINT NOT NULL,
+    provider TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    email TEXT,
+    last_login_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE UNIQUE INDEX idx_identity_subject ON identities (provider, subject);
+CREATE INDEX idx_identities_account_id ON identities (account_id);
+
+CREATE TABLE federated_logins (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE,
+    state_hash TEXT NOT NULL,
+    provider TEXT NOT NULL,
+    nonce TEXT NOT NULL,
+    code_verifier TEXT NOT NULL,
+    return_to TEXT NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_federated_logins_state_hash ON federated_logins (state_hash);
+CREATE INDEX idx_federated_logins_expires_at ON federated_logins (expires_at);
+
+-- Accounts created on a federated sign-in may have no phone number.
+DROP INDEX IF EXISTS idx_phone;
+CREATE UNIQUE INDEX idx_phone ON accounts (phone) WHERE phone <> '';
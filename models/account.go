@@ -11,12 +11,24 @@ type Account struct {
 	FirstName          string     `json:"first_name" validate:"required,min=2,max=50"`
 	LastName           string     `json:"last_name" validate:"required,min=2,max=50"`
 	Email              string     `json:"email" validate:"required,email" gorm:"uniqueIndex:idx_email"`
-	Phone              string     `json:"phone" validate:"required,e164" gorm:"uniqueIndex:idx_phone"`
+	Phone              string     `json:"phone" validate:"required,e164" gorm:"uniqueIndex:idx_phone,where:phone <> ''"`
 	PhotoUrl           string     `json:"photo_url" validate:"omitempty,url"`
 	VerificationStatus string     `json:"verification_status" validate:"required,oneof=pending verified"`
-	Role               string     `json:"role" validate:"required,oneof=common admin manager teacher student" gorm:"default:common"`
 	LastLoginAt        *time.Time `json:"last_login_at"`
+	Locale             string     `json:"locale" validate:"omitempty,bcp47_language_tag"`
+
+	SuspendedAt      *time.Time `json:"suspended_at"`
+	SuspendedUntil   *time.Time `json:"suspended_until"`
+	SuspensionReason string     `json:"suspension_reason"`
+	SuspendedByID    *uint      `json:"suspended_by_id"`
 
 	AccountPassword AccountPassword `json:"account_password" gorm:"foreignKey:AccountID"`
 	AccountTokens   AccountToken    `json:"account_tokens" gorm:"foreignKey:AccountID"`
+	Roles           []Role          `json:"roles" gorm:"many2many:account_roles"`
+}
+
+// IsSuspended reports whether the account is suspended at the given time. A
+// suspension without an end time lasts until the account is reinstated.
+func (a *Account) IsSuspended(at time.Time) bool {
+	return a.SuspendedAt != nil && (a.SuspendedUntil == nil || at.Before(*a.SuspendedUntil))
 }
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
@@ -11,4 +13,8 @@ type AccountToken struct {
 	ResetEmailToken        string `json:"reset_email_token" gorm:"unique index"`
 	EmailVerificationToken string `json:"email_verification_token" gorm:"unique index"`
 	PhoneVerificationToken string `json:"phone_verification_token" gorm:"unique index"`
+
+	EmailVerificationSentAt     *time.Time `json:"email_verification_sent_at"`
+	EmailVerificationWindowFrom *time.Time `json:"email_verification_window_from"`
+	EmailVerificationSendCount  int        `json:"email_verification_send_count" gorm:"not null;default:0"`
 }
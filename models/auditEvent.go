@@ -0,0 +1,67 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	AuditActionAccountLogin             = "account.login"
+	AuditActionAccountCreated           = "account.created"
+	AuditActionIdentityLinked           = "account.identity_linked"
+	AuditActionAccountUpdated           = "account.updated"
+	AuditActionAccountSuspended         = "account.suspended"
+	AuditActionAccountReinstated        = "account.reinstated"
+	AuditActionAccountDeleted           = "account.deleted"
+	AuditActionAccountRestored          = "account.restored"
+	AuditActionAccountPurged            = "account.purged"
+	AuditActionEmailVerified            = "account.email_verified"
+	AuditActionPasswordResetRequested   = "account.password_reset_requested"
+	AuditActionPasswordReset            = "account.password_reset"
+	AuditActionDataExported             = "account.data_exported"
+	AuditActionImpersonationStarted     = "impersonation.started"
+	AuditActionImpersonationStopped     = "impersonation.stopped"
+	AuditActionRolesChanged             = "account.roles_changed"
+	AuditActionRoleCreated              = "role.created"
+	AuditActionRoleUpdated              = "role.updated"
+	AuditActionRoleDeleted              = "role.deleted"
+	AuditActionPermissionCreated        = "permission.created"
+	AuditActionPermissionDeleted        = "permission.deleted"
+	AuditActionOAuthClientCreated       = "oauth.client_created"
+	AuditActionOAuthClientSecretRotated = "oauth.client_secret_rotated"
+	AuditActionOAuthClientDisabled      = "oauth.client_disabled"
+	AuditActionOAuthClientUpdated       = "oauth.client_updated"
+	AuditActionOAuthInitialTokenIssued  = "oauth.initial_access_token_issued"
+	AuditActionOAuthAuthorized          = "oauth.authorized"
+	AuditActionOAuthTokenReused         = "oauth.token_reused"
+	AuditActionOAuthDeviceAuthorized    = "oauth.device_authorized"
+	AuditActionOAuthTokenExchanged      = "oauth.token_exchanged"
+	AuditActionOAuthConsentGranted      = "oauth.consent_granted"
+	AuditActionOAuthGrantRevoked        = "oauth.grant_revoked"
+	AuditActionOAuthLogout              = "oauth.logout"
+)
+
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeFailure = "failure"
+)
+
+// AuditEvent records a sensitive operation. Audit events are append-only,
+// so unlike the other models it does not embed gorm.Model.
+//
+// Events form a hash chain: Sequence numbers them without gaps, and Hash
+// covers the event's fields together with PrevHash, the Hash of the event
+// before it. Editing or removing an event breaks the chain from that point.
+type AuditEvent struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Sequence  uint64    `json:"sequence" gorm:"not null;default:0;index"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+	Action    string    `json:"action" gorm:"not null;index"`
+	ActorID   *uint     `json:"actor_id" gorm:"index"`
+	TargetID  *uint     `json:"target_id" gorm:"index"`
+	IP        string    `json:"ip" gorm:"not null;default:''"`
+	UserAgent string    `json:"user_agent" gorm:"not null;default:''"`
+	Outcome   string    `json:"outcome" gorm:"not null;default:'success';index"`
+	Metadata  string    `json:"metadata" gorm:"type:jsonb"`
+	PrevHash  string    `json:"prev_hash" gorm:"not null;default:''"`
+	Hash      string    `json:"hash" gorm:"not null;default:''"`
+}
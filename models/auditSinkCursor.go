@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// AuditSinkCursor records how far a SIEM sink has read the audit log, as
+// the sequence and ID of the last event it accepted. The instance named by
+// Owner forwards to the sink until LeaseUntil.
+type AuditSinkCursor struct {
+	Name       string     `json:"name" gorm:"primaryKey"`
+	Sequence   uint64     `json:"sequence" gorm:"not null;default:0"`
+	EventID    uint       `json:"event_id" gorm:"not null;default:0"`
+	Owner      string     `json:"owner" gorm:"not null;default:''"`
+	LeaseUntil *time.Time `json:"lease_until"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DataExportStatusPending = "pending"
+	DataExportStatusReady   = "ready"
+)
+
+// DataExport tracks an archive of an account's personal data. The archive
+// is generated in the background; BlobKey and ExpiresAt are set once it is
+// ready. PublicID identifies the export in URLs so that exports cannot be
+// enumerated.
+type DataExport struct {
+	gorm.Model
+	PublicID  string     `json:"public_id" gorm:"not null;uniqueIndex"`
+	AccountID uint       `json:"account_id" gorm:"not null;index"`
+	Status    string     `json:"status" gorm:"not null;default:pending"`
+	BlobKey   string     `json:"blob_key"`
+	Size      int64      `json:"size"`
+	ExpiresAt *time.Time `json:"expires_at" gorm:"index"`
+}
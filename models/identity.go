@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// Identity links an account to its user at an upstream identity provider.
+// Provider is the ID of the provider's connector and Subject the provider's
+// stable ID of the user; Email is the address the provider last shared.
+type Identity struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	AccountID   uint       `json:"account_id" gorm:"not null;index"`
+	Provider    string     `json:"provider" gorm:"not null;uniqueIndex:idx_identity_subject"`
+	Subject     string     `json:"subject" gorm:"not null;uniqueIndex:idx_identity_subject"`
+	Email       string     `json:"email"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+}
+
+// FederatedLogin is a sign-in sent to an upstream identity provider that
+// has not come back yet. Only the hash of its state is stored; the browser
+// holds the state in a cookie, so the callback is only accepted in the
+// browser that started the sign-in. It is deleted when the callback uses it.
+// For a SAML provider, Nonce holds the ID of the AuthnRequest and there is
+// no CodeVerifier.
+type FederatedLogin struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt    time.Time `json:"created_at"`
+	StateHash    string    `json:"-" gorm:"not null;uniqueIndex"`
+	Provider     string    `json:"provider" gorm:"not null"`
+	Nonce        string    `json:"-" gorm:"not null"`
+	CodeVerifier string    `json:"-" gorm:"not null"`
+	ReturnTo     string    `json:"return_to" gorm:"not null"`
+	ExpiresAt    time.Time `json:"expires_at" gorm:"not null;index"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ImpersonationSession records an administrator acting as another account.
+// Its PublicID is the jti of the impersonation token, so ending the session
+// revokes the token before it expires.
+type ImpersonationSession struct {
+	gorm.Model
+	PublicID  string     `json:"public_id" gorm:"not null;uniqueIndex"`
+	AdminID   uint       `json:"admin_id" gorm:"not null;index"`
+	AccountID uint       `json:"account_id" gorm:"not null;index"`
+	Reason    string     `json:"reason" gorm:"not null"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	EndedAt   *time.Time `json:"ended_at"`
+}
+
+// IsActive reports whether the session can still be used at the given time.
+func (s *ImpersonationSession) IsActive(at time.Time) bool {
+	return s.EndedAt == nil && at.Before(s.ExpiresAt)
+}
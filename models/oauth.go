@@ -0,0 +1,186 @@
+package models
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OAuthClient is an application registered with the OAuth 2.0 authorization
+// server. ClientID is the public identifier used in requests. Scopes lists
+// the scopes the client may request and GrantTypes the grants it may use.
+//
+// Public clients authenticate with none and hold no secret. Confidential
+// clients authenticate with a secret, of which only the hash is stored, or
+// with a JWT signed by a key in JWKS. PreviousSecretHash keeps the secret
+// replaced by the last rotation valid until PreviousSecretExpiresAt, so
+// deployments can switch over without downtime.
+//
+// PostLogoutRedirectURIs are where the end session endpoint may send the
+// browser after signing the user out. BackchannelLogoutURI, if set,
+// receives a logout token whenever a session the client signed in with
+// ends (OpenID Connect Back-Channel Logout 1.0).
+//
+// A private_key_jwt client registers its keys in JWKS or publishes them at
+// JWKSURI. Clients registered through dynamic client registration hold a
+// registration access token, of which only RegistrationTokenHash is stored,
+// to read, update and delete their registration (RFC 7592).
+type OAuthClient struct {
+	gorm.Model
+	ClientID                string          `json:"client_id" gorm:"not null;uniqueIndex"`
+	Name                    string          `json:"name" gorm:"not null"`
+	RedirectURIs            []string        `json:"redirect_uris" gorm:"type:jsonb;serializer:json;not null"`
+	PostLogoutRedirectURIs  []string        `json:"post_logout_redirect_uris" gorm:"type:jsonb;serializer:json"`
+	BackchannelLogoutURI    string          `json:"backchannel_logout_uri"`
+	Scopes                  []string        `json:"scopes" gorm:"type:jsonb;serializer:json;not null"`
+	GrantTypes              []string        `json:"grant_types" gorm:"type:jsonb;serializer:json"`
+	TokenEndpointAuthMethod string          `json:"token_endpoint_auth_method" gorm:"not null;default:none"`
+	SecretHash              string          `json:"-"`
+	PreviousSecretHash      string          `json:"-"`
+	PreviousSecretExpiresAt *time.Time      `json:"-"`
+	SecretRotatedAt         *time.Time      `json:"secret_rotated_at"`
+	JWKS                    json.RawMessage `json:"-" gorm:"type:jsonb;serializer:json"`
+	JWKSURI                 string          `json:"jwks_uri"`
+	RegistrationTokenHash   string          `json:"-"`
+	DisabledAt              *time.Time      `json:"disabled_at"`
+}
+
+// AllowsGrant reports whether the client is registered for grantType.
+func (c *OAuthClient) AllowsGrant(grantType string) bool {
+	return slices.Contains(c.GrantTypes, grantType)
+}
+
+// OAuthClientAssertion records the ID of a private_key_jwt assertion a
+// client has used, until the assertion expires, so it cannot be replayed.
+type OAuthClientAssertion struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	ClientID  string    `json:"client_id" gorm:"not null;uniqueIndex:idx_oauth_client_assertion"`
+	JTI       string    `json:"jti" gorm:"not null;uniqueIndex:idx_oauth_client_assertion"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"not null;index"`
+}
+
+// OAuthInitialAccessToken authorizes one dynamic client registration
+// (RFC 7591 section 3). Only the hash of the token is stored. UsedAt is set
+// and ClientID names the registered client once the token has been used.
+type OAuthInitialAccessToken struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	CreatedAt   time.Time  `json:"created_at"`
+	TokenHash   string     `json:"-" gorm:"not null;uniqueIndex"`
+	Description string     `json:"description"`
+	CreatedBy   uint       `json:"created_by" gorm:"not null"`
+	ExpiresAt   time.Time  `json:"expires_at" gorm:"not null"`
+	UsedAt      *time.Time `json:"used_at"`
+	ClientID    string     `json:"client_id"`
+}
+
+// Session is a browser sign-in at the authorization server. The cookie holds
+// a random token of which only the hash is stored; PublicID identifies the
+// session elsewhere, for example in tokens issued during it. AMR lists how
+// the user authenticated (RFC 8176).
+type Session struct {
+	gorm.Model
+	PublicID  string     `json:"public_id" gorm:"not null;uniqueIndex"`
+	TokenHash string     `json:"-" gorm:"not null;uniqueIndex"`
+	AccountID uint       `json:"account_id" gorm:"not null;index"`
+	AuthTime  time.Time  `json:"auth_time" gorm:"not null"`
+	AMR       []string   `json:"amr" gorm:"type:jsonb;serializer:json"`
+	IP        string     `json:"ip"`
+	UserAgent string     `json:"user_agent"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	RevokedAt *time.Time `json:"revoked_at"`
+}
+
+// IsActive reports whether the session can still be used at the given time.
+func (s *Session) IsActive(at time.Time) bool {
+	return s.RevokedAt == nil && at.Before(s.ExpiresAt)
+}
+
+// OAuthAuthorizationCode is a single-use code issued by the authorization
+// endpoint. Only the hash of the code is stored. UsedAt is set when the code
+// is redeemed. AuthTime and AMR are copied from the session so ID tokens
+// issued for the code describe the sign-in behind it.
+type OAuthAuthorizationCode struct {
+	gorm.Model
+	CodeHash            string     `json:"-" gorm:"not null;uniqueIndex"`
+	ClientID            string     `json:"client_id" gorm:"not null;index"`
+	AccountID           uint       `json:"account_id" gorm:"not null;index"`
+	SessionID           string     `json:"session_id" gorm:"index"`
+	RedirectURI         string     `json:"redirect_uri"`
+	Scope               string     `json:"scope"`
+	CodeChallenge       string     `json:"-" gorm:"not null"`
+	CodeChallengeMethod string     `json:"-" gorm:"not null"`
+	Nonce               string     `json:"-"`
+	AuthTime            time.Time  `json:"auth_time"`
+	AMR                 []string   `json:"amr" gorm:"type:jsonb;serializer:json"`
+	ExpiresAt           time.Time  `json:"expires_at" gorm:"not null"`
+	UsedAt              *time.Time `json:"used_at"`
+}
+
+// OAuthDeviceCode is a device authorization request (RFC 8628). The device
+// polls the token endpoint with the device code while the user enters the
+// user code in a browser; only the hashes of both codes are stored. The
+// user's decision sets ApprovedAt, together with the account and session it
+// was made in, or DeniedAt. PollInterval is the number of seconds the device must
+// wait between polls and grows when it polls too fast. UsedAt is set when
+// tokens are issued for the code.
+type OAuthDeviceCode struct {
+	gorm.Model
+	DeviceCodeHash string     `json:"-" gorm:"not null;uniqueIndex"`
+	UserCodeHash   string     `json:"-" gorm:"not null;uniqueIndex"`
+	ClientID       string     `json:"client_id" gorm:"not null;index"`
+	Scope          string     `json:"scope"`
+	AccountID      *uint      `json:"account_id" gorm:"index"`
+	SessionID      string     `json:"session_id" gorm:"index"`
+	AuthTime       *time.Time `json:"auth_time"`
+	AMR            []string   `json:"amr" gorm:"type:jsonb;serializer:json"`
+	PollInterval   int        `json:"poll_interval" gorm:"not null"`
+	LastPolledAt   *time.Time `json:"last_polled_at"`
+	ApprovedAt     *time.Time `json:"approved_at"`
+	DeniedAt       *time.Time `json:"denied_at"`
+	ExpiresAt      time.Time  `json:"expires_at" gorm:"not null;index"`
+	UsedAt         *time.Time `json:"used_at"`
+}
+
+// IsPending reports whether the user can still approve or deny the code at
+// the given time.
+func (d *OAuthDeviceCode) IsPending(at time.Time) bool {
+	return d.ApprovedAt == nil && d.DeniedAt == nil && at.Before(d.ExpiresAt)
+}
+
+// OAuthGrant records the scopes an account has allowed a client to use.
+// The authorization endpoint asks for consent only for scopes outside it.
+// Revoking the grant revokes the client's refresh tokens for the account.
+type OAuthGrant struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	AccountID uint      `json:"account_id" gorm:"not null;uniqueIndex:idx_oauth_grant"`
+	ClientID  string    `json:"client_id" gorm:"not null;uniqueIndex:idx_oauth_grant;index"`
+	Scopes    []string  `json:"scopes" gorm:"type:jsonb;serializer:json;not null"`
+}
+
+// OAuthRefreshToken is a refresh token issued to a client. Only the hash of
+// the token is stored. Refresh tokens are rotated on use: every token
+// derived from the same authorization shares a FamilyID, so a rotated token
+// that is presented again revokes the whole family.
+type OAuthRefreshToken struct {
+	gorm.Model
+	TokenHash           string     `json:"-" gorm:"not null;uniqueIndex"`
+	FamilyID            string     `json:"family_id" gorm:"not null;index"`
+	AuthorizationCodeID *uint      `json:"authorization_code_id" gorm:"index"`
+	ClientID            string     `json:"client_id" gorm:"not null;index"`
+	AccountID           uint       `json:"account_id" gorm:"not null;index"`
+	SessionID           string     `json:"session_id" gorm:"index"`
+	Scope               string     `json:"scope"`
+	AuthTime            time.Time  `json:"auth_time"`
+	AMR                 []string   `json:"amr" gorm:"type:jsonb;serializer:json"`
+	ExpiresAt           time.Time  `json:"expires_at" gorm:"not null"`
+	RevokedAt           *time.Time `json:"revoked_at"`
+}
+
+// IsActive reports whether the token can still be used at the given time.
+func (t *OAuthRefreshToken) IsActive(at time.Time) bool {
+	return t.RevokedAt == nil && at.Before(t.ExpiresAt)
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	OutboxStatusPending   = "pending"
+	OutboxStatusDelivered = "delivered"
+	OutboxStatusDead      = "dead"
+)
+
+type OutboxMessage struct {
+	gorm.Model
+	Topic       string     `json:"topic" gorm:"not null"`
+	Payload     string     `json:"payload" gorm:"type:jsonb;not null"`
+	Status      string     `json:"status" gorm:"not null;default:pending;index:idx_outbox_status_available_at,priority:1"`
+	Attempts    int        `json:"attempts" gorm:"not null;default:0"`
+	AvailableAt time.Time  `json:"available_at" gorm:"not null;index:idx_outbox_status_available_at,priority:2"`
+	LastError   string     `json:"last_error"`
+	DeliveredAt *time.Time `json:"delivered_at"`
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+const (
+	RoleCommon  = "common"
+	RoleAdmin   = "admin"
+	RoleManager = "manager"
+	RoleTeacher = "teacher"
+	RoleStudent = "student"
+)
+
+const (
+	PermissionAccountsRead         = "accounts:read"
+	PermissionAccountsWrite        = "accounts:write"
+	PermissionAccountsSuspend      = "accounts:suspend"
+	PermissionAccountsImpersonate  = "accounts:impersonate"
+	PermissionRolesRead            = "roles:read"
+	PermissionRolesWrite           = "roles:write"
+	PermissionRolesAssign          = "roles:assign"
+	PermissionNotificationsPreview = "notifications:preview"
+	PermissionAuditRead            = "audit:read"
+	PermissionOAuthClients         = "oauth:clients"
+)
+
+type Role struct {
+	gorm.Model
+	Name        string       `json:"name" gorm:"uniqueIndex:idx_role_name;not null"`
+	Description string       `json:"description"`
+	System      bool         `json:"system" gorm:"not null;default:false"`
+	Permissions []Permission `json:"permissions" gorm:"many2many:role_permissions"`
+}
+
+type Permission struct {
+	gorm.Model
+	Name        string `json:"name" gorm:"uniqueIndex:idx_permission_name;not null"`
+	Description string `json:"description"`
+	System      bool   `json:"system" gorm:"not null;default:false"`
+}
+
+// BuiltinPermissions are the permissions the service itself checks. They are
+// seeded on startup and cannot be deleted.
+var BuiltinPermissions = map[string]string{
+	PermissionAccountsRead:         "View any account",
+	PermissionAccountsWrite:        "Modify any account",
+	PermissionAccountsSuspend:      "Suspend and reinstate accounts",
+	PermissionAccountsImpersonate:  "Act as another account",
+	PermissionRolesRead:            "View roles and permissions",
+	PermissionRolesWrite:           "Create, update and delete roles and permissions",
+	PermissionRolesAssign:          "Change the roles of an account",
+	PermissionNotificationsPreview: "Preview notification templates",
+	PermissionAuditRead:            "View and verify the audit log",
+	PermissionOAuthClients:         "Manage OAuth clients",
+}
+
+// BuiltinRoles are seeded on startup with these permissions when they do not
+// exist yet. They cannot be renamed or deleted, but their permissions can be
+// changed.
+var BuiltinRoles = map[string][]string{
+	RoleAdmin: {
+		PermissionAccountsRead,
+		PermissionAccountsWrite,
+		PermissionAccountsSuspend,
+		PermissionAccountsImpersonate,
+		PermissionRolesRead,
+		PermissionRolesWrite,
+		PermissionRolesAssign,
+		PermissionNotificationsPreview,
+		PermissionAuditRead,
+		PermissionOAuthClients,
+	},
+	RoleManager: {
+		PermissionAccountsRead,
+		PermissionRolesRead,
+	},
+	RoleTeacher: {},
+	RoleStudent: {},
+	RoleCommon:  {},
+}
@@ -0,0 +1,22 @@
+package clientinfo
+
+import "context"
+
+type contextKey struct{}
+
+// Info describes the client that made a request.
+type Info struct {
+	IP        string
+	UserAgent string
+}
+
+// WithInfo stores the request's client information.
+func WithInfo(ctx context.Context, info Info) context.Context {
+	return context.WithValue(ctx, contextKey{}, info)
+}
+
+// FromContext returns the client information stored in ctx, if any.
+func FromContext(ctx context.Context) Info {
+	info, _ := ctx.Value(contextKey{}).(Info)
+	return info
+}
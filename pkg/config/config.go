@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/kelseyhightower/envconfig"
@@ -12,6 +13,85 @@ type Config struct {
 	Env         string `envconfig:"ENV" required:"true" validate:"oneof=development staging production"`
 	DatabaseURL string `envconfig:"DATABASE_URL" required:"true" validate:"startswith=postgresql://"`
 	JWTSecret   string `envconfig:"JWT_SECRET" required:"true"`
+
+	NotifierTransport string `envconfig:"NOTIFIER_TRANSPORT" default:"file" validate:"oneof=smtp file memory"`
+	NotifierFilePath  string `envconfig:"NOTIFIER_FILE_PATH" default:"notifications.jsonl"`
+	SMTPHost          string `envconfig:"SMTP_HOST" default:"localhost"`
+	SMTPPort          int    `envconfig:"SMTP_PORT" default:"25"`
+	SMTPUsername      string `envconfig:"SMTP_USERNAME"`
+	SMTPPassword      string `envconfig:"SMTP_PASSWORD"`
+	SMTPFrom          string `envconfig:"SMTP_FROM" default:"no-reply@localhost"`
+
+	NotificationTemplateDir string `envconfig:"NOTIFICATION_TEMPLATE_DIR"`
+	NotificationBrandName   string `envconfig:"NOTIFICATION_BRAND_NAME" default:"Auth Service"`
+
+	DefaultRole       string `envconfig:"DEFAULT_ROLE" default:"common"`
+	InitialAdminEmail string `envconfig:"INITIAL_ADMIN_EMAIL"`
+
+	PublicBaseURL string `envconfig:"PUBLIC_BASE_URL" default:"http://localhost:8080"`
+
+	BlobStore         string `envconfig:"BLOB_STORE" default:"local" validate:"oneof=local s3"`
+	BlobLocalDir      string `envconfig:"BLOB_LOCAL_DIR" default:"uploads"`
+	S3Endpoint        string `envconfig:"S3_ENDPOINT"`
+	S3Region          string `envconfig:"S3_REGION" default:"us-east-1"`
+	S3Bucket          string `envconfig:"S3_BUCKET"`
+	S3AccessKeyID     string `envconfig:"S3_ACCESS_KEY_ID"`
+	S3SecretAccessKey string `envconfig:"S3_SECRET_ACCESS_KEY"`
+	S3PathStyle       bool   `envconfig:"S3_PATH_STYLE" default:"true"`
+
+	PhotoMaxBytes int64 `envconfig:"PHOTO_MAX_BYTES" default:"5242880"`
+
+	AccountDeletionGracePeriod time.Duration `envconfig:"ACCOUNT_DELETION_GRACE_PERIOD" default:"720h"`
+	AccountPurgeInterval       time.Duration `envconfig:"ACCOUNT_PURGE_INTERVAL" default:"1h"`
+	AccountPurgeBatchSize      int           `envconfig:"ACCOUNT_PURGE_BATCH_SIZE" default:"50"`
+
+	DataExportTTL time.Duration `envconfig:"DATA_EXPORT_TTL" default:"24h"`
+
+	ImpersonationTTL time.Duration `envconfig:"IMPERSONATION_TTL" default:"15m"`
+
+	SessionTTL           time.Duration `envconfig:"SESSION_TTL" default:"168h"`
+	OAuthCodeTTL         time.Duration `envconfig:"OAUTH_CODE_TTL" default:"1m"`
+	OAuthAccessTokenTTL  time.Duration `envconfig:"OAUTH_ACCESS_TOKEN_TTL" default:"1h"`
+	OAuthRefreshTokenTTL time.Duration `envconfig:"OAUTH_REFRESH_TOKEN_TTL" default:"720h"`
+	OIDCSigningKeyFile   string        `envconfig:"OIDC_SIGNING_KEY_FILE"`
+
+	OAuthClientSecretGracePeriod time.Duration `envconfig:"OAUTH_CLIENT_SECRET_GRACE_PERIOD" default:"24h"`
+
+	OAuthDeviceCodeTTL      time.Duration `envconfig:"OAUTH_DEVICE_CODE_TTL" default:"10m"`
+	OAuthDevicePollInterval time.Duration `envconfig:"OAUTH_DEVICE_POLL_INTERVAL" default:"5s"`
+
+	OAuthTokenExchangePolicy map[string]string `envconfig:"OAUTH_TOKEN_EXCHANGE_POLICY"`
+
+	OAuthInitialAccessTokenTTL time.Duration `envconfig:"OAUTH_INITIAL_ACCESS_TOKEN_TTL" default:"24h"`
+	OAuthRegistrationScopes    []string      `envconfig:"OAUTH_REGISTRATION_SCOPES" default:"openid,profile,email"`
+
+	FederationProvidersFile string        `envconfig:"FEDERATION_PROVIDERS_FILE"`
+	FederationLoginTTL      time.Duration `envconfig:"FEDERATION_LOGIN_TTL" default:"10m"`
+
+	VerificationResendCooldown time.Duration `envconfig:"VERIFICATION_RESEND_COOLDOWN" default:"1m"`
+	VerificationResendDailyCap int           `envconfig:"VERIFICATION_RESEND_DAILY_CAP" default:"5"`
+
+	AuditSinks              []string      `envconfig:"AUDIT_SINKS"`
+	AuditSinkPollInterval   time.Duration `envconfig:"AUDIT_SINK_POLL_INTERVAL" default:"1s"`
+	AuditSinkBatchSize      int           `envconfig:"AUDIT_SINK_BATCH_SIZE" default:"100"`
+	AuditSinkClaimLease     time.Duration `envconfig:"AUDIT_SINK_CLAIM_LEASE" default:"1m"`
+	AuditSinkRetryBaseDelay time.Duration `envconfig:"AUDIT_SINK_RETRY_BASE_DELAY" default:"1s"`
+	AuditSinkRetryMaxDelay  time.Duration `envconfig:"AUDIT_SINK_RETRY_MAX_DELAY" default:"5m"`
+	AuditSyslogNetwork      string        `envconfig:"AUDIT_SYSLOG_NETWORK" default:"udp" validate:"oneof=udp tcp unix unixgram"`
+	AuditSyslogAddress      string        `envconfig:"AUDIT_SYSLOG_ADDRESS" default:"localhost:514"`
+	AuditSyslogFacility     int           `envconfig:"AUDIT_SYSLOG_FACILITY" default:"10" validate:"range(0,23)"`
+	AuditSyslogAppName      string        `envconfig:"AUDIT_SYSLOG_APP_NAME" default:"auth-service"`
+	AuditSyslogFormat       string        `envconfig:"AUDIT_SYSLOG_FORMAT" default:"json" validate:"oneof=json cef"`
+	AuditFilePath           string        `envconfig:"AUDIT_FILE_PATH" default:"audit.jsonl"`
+	AuditFileMaxBytes       int64         `envconfig:"AUDIT_FILE_MAX_BYTES" default:"104857600"`
+	AuditFileMaxBackups     int           `envconfig:"AUDIT_FILE_MAX_BACKUPS" default:"5"`
+
+	OutboxPollInterval   time.Duration `envconfig:"OUTBOX_POLL_INTERVAL" default:"2s"`
+	OutboxBatchSize      int           `envconfig:"OUTBOX_BATCH_SIZE" default:"20"`
+	OutboxMaxAttempts    int           `envconfig:"OUTBOX_MAX_ATTEMPTS" default:"10"`
+	OutboxRetryBaseDelay time.Duration `envconfig:"OUTBOX_RETRY_BASE_DELAY" default:"10s"`
+	OutboxRetryMaxDelay  time.Duration `envconfig:"OUTBOX_RETRY_MAX_DELAY" default:"1h"`
+	OutboxClaimLease     time.Duration `envconfig:"OUTBOX_CLAIM_LEASE" default:"5m"`
 }
 
 func LoadConfig() (*Config, error) {
@@ -15,6 +15,13 @@ const (
 	ErrorTypeBadRequest   ErrorType = "BAD_REQUEST"
 	ErrorTypeUnauthorized ErrorType = "UNAUTHORIZED"
 	ErrorTypeConflict     ErrorType = "CONFLICT_ERROR"
+	ErrorTypeForbidden    ErrorType = "FORBIDDEN"
+
+	ErrorTypePreconditionFailed   ErrorType = "PRECONDITION_FAILED"
+	ErrorTypePreconditionRequired ErrorType = "PRECONDITION_REQUIRED"
+	ErrorTypeUnsupportedMedia     ErrorType = "UNSUPPORTED_MEDIA_TYPE"
+	ErrorTypePayloadTooLarge      ErrorType = "PAYLOAD_TOO_LARGE"
+	ErrorTypeAccountSuspended     ErrorType = "ACCOUNT_SUSPENDED"
 )
 
 type AppError struct {
@@ -35,6 +42,13 @@ var statusCodeMap = map[ErrorType]int{
 	ErrorTypeInternal:     http.StatusInternalServerError,
 	ErrorTypeBadRequest:   http.StatusBadRequest,
 	ErrorTypeUnauthorized: http.StatusUnauthorized,
+	ErrorTypeForbidden:    http.StatusForbidden,
+
+	ErrorTypePreconditionFailed:   http.StatusPreconditionFailed,
+	ErrorTypePreconditionRequired: http.StatusPreconditionRequired,
+	ErrorTypeUnsupportedMedia:     http.StatusUnsupportedMediaType,
+	ErrorTypePayloadTooLarge:      http.StatusRequestEntityTooLarge,
+	ErrorTypeAccountSuspended:     http.StatusForbidden,
 }
 
 func ValidationError(message string, errors any) *AppError {
@@ -86,3 +100,61 @@ func ConflictError(message string) *AppError {
 		Code:    http.StatusConflict,
 	}
 }
+
+func ForbiddenError(message string) *AppError {
+	return &AppError{
+		Type:    ErrorTypeForbidden,
+		Message: message,
+		Code:    statusCodeMap[ErrorTypeForbidden],
+	}
+}
+
+// PreconditionFailedError reports that a conditional request, such as one
+// carrying If-Match, no longer matches the current state of the resource.
+func PreconditionFailedError(message string) *AppError {
+	return &AppError{
+		Type:    ErrorTypePreconditionFailed,
+		Message: message,
+		Code:    statusCodeMap[ErrorTypePreconditionFailed],
+	}
+}
+
+// PreconditionRequiredError reports that a request must be made conditional.
+func PreconditionRequiredError(message string) *AppError {
+	return &AppError{
+		Type:    ErrorTypePreconditionRequired,
+		Message: message,
+		Code:    statusCodeMap[ErrorTypePreconditionRequired],
+	}
+}
+
+func UnsupportedMediaTypeError(message string) *AppError {
+	return &AppError{
+		Type:    ErrorTypeUnsupportedMedia,
+		Message: message,
+		Code:    statusCodeMap[ErrorTypeUnsupportedMedia],
+	}
+}
+
+func PayloadTooLargeError(message string) *AppError {
+	return &AppError{
+		Type:    ErrorTypePayloadTooLarge,
+		Message: message,
+		Code:    statusCodeMap[ErrorTypePayloadTooLarge],
+	}
+}
+
+// AccountSuspendedError reports that the account is suspended. It has its own
+// type so clients can tell it apart from a missing permission. until is
+// empty for suspensions without an end time.
+func AccountSuspendedError(until string) *AppError {
+	err := &AppError{
+		Type:    ErrorTypeAccountSuspended,
+		Message: "Account is suspended",
+		Code:    statusCodeMap[ErrorTypeAccountSuspended],
+	}
+	if until != "" {
+		err.Errors = map[string]string{"suspended_until": until}
+	}
+	return err
+}
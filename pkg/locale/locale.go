@@ -0,0 +1,16 @@
+package locale
+
+import "context"
+
+type contextKey struct{}
+
+// WithAcceptLanguage stores the request's Accept-Language header value.
+func WithAcceptLanguage(ctx context.Context, acceptLanguage string) context.Context {
+	return context.WithValue(ctx, contextKey{}, acceptLanguage)
+}
+
+// AcceptLanguage returns the Accept-Language value stored in ctx, if any.
+func AcceptLanguage(ctx context.Context) string {
+	acceptLanguage, _ := ctx.Value(contextKey{}).(string)
+	return acceptLanguage
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/ssoydabas/auth-service/pkg/clientinfo"
+)
+
+// maxUserAgentLength bounds the User-Agent value kept for the audit log.
+const maxUserAgentLength = 512
+
+// ClientInfo makes the client IP and User-Agent header available to the
+// service layer through the request context.
+func ClientInfo(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+
+		userAgent := req.UserAgent()
+		if len(userAgent) > maxUserAgentLength {
+			userAgent = strings.ToValidUTF8(userAgent[:maxUserAgentLength], "")
+		}
+
+		c.SetRequest(req.WithContext(clientinfo.WithInfo(req.Context(), clientinfo.Info{
+			IP:        c.RealIP(),
+			UserAgent: userAgent,
+		})))
+		return next(c)
+	}
+}
@@ -0,0 +1,18 @@
+package middleware
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/ssoydabas/auth-service/pkg/locale"
+)
+
+// Locale makes the Accept-Language header available to the service layer
+// through the request context.
+func Locale(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if acceptLanguage := c.Request().Header.Get("Accept-Language"); acceptLanguage != "" {
+			req := c.Request()
+			c.SetRequest(req.WithContext(locale.WithAcceptLanguage(req.Context(), acceptLanguage)))
+		}
+		return next(c)
+	}
+}
@@ -0,0 +1,56 @@
+package postgres
+
+import (
+	"github.com/ssoydabas/auth-service/internal/audit"
+	"github.com/ssoydabas/auth-service/models"
+
+	"gorm.io/gorm"
+)
+
+const auditChainBatchSize = 500
+
+// chainAuditEvents links audit events that are not yet part of the hash
+// chain into it, oldest first. Those are events written before the chain
+// existed, or by an older instance during a rolling upgrade. It is a no-op
+// once every event is chained.
+func chainAuditEvents(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", audit.ChainLockKey).Error; err != nil {
+			return err
+		}
+
+		var last []models.AuditEvent
+		if err := tx.Where("hash <> ''").Order("sequence DESC").Limit(1).Find(&last).Error; err != nil {
+			return err
+		}
+		var previous *models.AuditEvent
+		if len(last) > 0 {
+			previous = &last[0]
+		}
+
+		for {
+			var events []models.AuditEvent
+			if err := tx.Where("hash = ''").Order("id").Limit(auditChainBatchSize).Find(&events).Error; err != nil {
+				return err
+			}
+			if len(events) == 0 {
+				return nil
+			}
+
+			for i := range events {
+				event := &events[i]
+				audit.Seal(event, previous)
+				if err := tx.Model(&models.AuditEvent{}).Where("id = ?", event.ID).Updates(map[string]interface{}{
+					"created_at": event.CreatedAt,
+					"outcome":    event.Outcome,
+					"sequence":   event.Sequence,
+					"prev_hash":  event.PrevHash,
+					"hash":       event.Hash,
+				}).Error; err != nil {
+					return err
+				}
+				previous = event
+			}
+		}
+	})
+}
@@ -32,12 +32,46 @@ func ConnectPQ(config config.Config) (*gorm.DB, error) {
 	return db, nil
 }
 
-// AutoMigrate migrates the models to the database.
+// AutoMigrate migrates the models to the database, creates the indexes GORM
+// cannot declare, seeds the built-in roles and links existing audit events
+// into the hash chain.
 func AutoMigrate(db *gorm.DB) error {
-	return db.AutoMigrate(
+	if err := db.AutoMigrate(
+		&models.Permission{},
+		&models.Role{},
 		&models.Account{},
 		&models.AccountPassword{},
 		&models.AccountToken{},
-	)
+		&models.OutboxMessage{},
+		&models.AuditEvent{},
+		&models.AuditSinkCursor{},
+		&models.DataExport{},
+		&models.ImpersonationSession{},
+		&models.OAuthClient{},
+		&models.Session{},
+		&models.OAuthAuthorizationCode{},
+		&models.OAuthRefreshToken{},
+		&models.OAuthClientAssertion{},
+		&models.OAuthDeviceCode{},
+		&models.OAuthGrant{},
+		&models.OAuthInitialAccessToken{},
+		&models.Identity{},
+		&models.FederatedLogin{},
+	); err != nil {
+		return err
+	}
+
+	if err := ensureAccountIndexes(db); err != nil {
+		return err
+	}
+
+	if err := SeedRoles(db); err != nil {
+		return err
+	}
+
+	if err := migrateLegacyRoleColumn(db); err != nil {
+		return err
+	}
 
+	return chainAuditEvents(db)
 }
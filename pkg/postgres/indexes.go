@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// accountListingIndexes back the sort orders and filters of the admin account
+// listing. They mirror migrations/000008_add_account_listing_indexes and are
+// created here because GORM tags cannot express composite or expression
+// indexes on the embedded gorm.Model columns.
+var accountListingIndexes = []string{
+	`CREATE INDEX IF NOT EXISTS idx_accounts_created_at_id ON accounts (created_at, id)`,
+	`CREATE INDEX IF NOT EXISTS idx_accounts_last_login_at_id ON accounts ((COALESCE(last_login_at, '0001-01-01 00:00:00+00'::timestamptz)), id)`,
+	`CREATE INDEX IF NOT EXISTS idx_accounts_first_name_id ON accounts (first_name, id)`,
+	`CREATE INDEX IF NOT EXISTS idx_accounts_last_name_id ON accounts (last_name, id)`,
+	`CREATE INDEX IF NOT EXISTS idx_accounts_verification_status ON accounts (verification_status)`,
+	`CREATE INDEX IF NOT EXISTS idx_account_roles_role_id ON account_roles (role_id)`,
+}
+
+const accountSearchIndex = `CREATE INDEX IF NOT EXISTS idx_accounts_search ON accounts USING GIN (
+	first_name gin_trgm_ops,
+	last_name gin_trgm_ops,
+	email gin_trgm_ops,
+	phone gin_trgm_ops
+)`
+
+// ensureAccountIndexes creates the account listing indexes. The trigram
+// search index needs the pg_trgm extension; when the database user may not
+// create it, search still works but scans the table.
+func ensureAccountIndexes(db *gorm.DB) error {
+	if err := ensurePartialPhoneIndex(db); err != nil {
+		return err
+	}
+
+	for _, statement := range accountListingIndexes {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := db.Exec(`CREATE EXTENSION IF NOT EXISTS pg_trgm`).Error; err != nil {
+		log.Printf("pg_trgm is unavailable, account search will not be indexed: %v", err)
+		return nil
+	}
+
+	return db.Exec(accountSearchIndex).Error
+}
+
+// ensurePartialPhoneIndex limits the unique phone index to accounts that
+// have a phone number, since accounts created on a federated sign-in may
+// not. Databases created before migrations/000021_create_identities have
+// the index on every row; it is replaced here because AutoMigrate leaves
+// existing indexes alone.
+func ensurePartialPhoneIndex(db *gorm.DB) error {
+	var partial bool
+	if err := db.Raw(`SELECT EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = 'idx_phone' AND indexdef LIKE '% WHERE %')`).Scan(&partial).Error; err != nil {
+		return err
+	}
+	if partial {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`DROP INDEX IF EXISTS idx_phone`).Error; err != nil {
+			return err
+		}
+		return tx.Exec(`CREATE UNIQUE INDEX idx_phone ON accounts (phone) WHERE phone <> ''`).Error
+	})
+}
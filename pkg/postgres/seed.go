@@ -0,0 +1,92 @@
+package postgres
+
+import (
+	"slices"
+
+	"github.com/ssoydabas/auth-service/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SeedRoles makes sure the built-in permissions and roles exist. Built-in
+// roles only receive their default permissions when they are first created,
+// so later changes made through the admin API are kept. The exception is a
+// built-in permission added by a newer release: it is granted once to the
+// built-in roles that list it.
+func SeedRoles(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var added []string
+		for name, description := range models.BuiltinPermissions {
+			permission := models.Permission{Name: name, Description: description, System: true}
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&permission)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 1 {
+				added = append(added, name)
+			}
+		}
+
+		for roleName, permissionNames := range models.BuiltinRoles {
+			for _, permissionName := range permissionNames {
+				if !slices.Contains(added, permissionName) {
+					continue
+				}
+				if err := tx.Exec(`
+					INSERT INTO role_permissions (role_id, permission_id)
+					SELECT roles.id, permissions.id FROM roles, permissions
+					WHERE roles.name = ? AND permissions.name = ?
+					ON CONFLICT DO NOTHING
+				`, roleName, permissionName).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		for name, permissionNames := range models.BuiltinRoles {
+			var count int64
+			if err := tx.Model(&models.Role{}).Where("name = ?", name).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				continue
+			}
+
+			var permissions []models.Permission
+			if len(permissionNames) > 0 {
+				if err := tx.Where("name IN ?", permissionNames).Find(&permissions).Error; err != nil {
+					return err
+				}
+			}
+
+			role := models.Role{Name: name, System: true, Permissions: permissions}
+			if err := tx.Create(&role).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// migrateLegacyRoleColumn moves the single accounts.role column into the
+// account_roles join table and drops the column. It is a no-op once the
+// column is gone.
+func migrateLegacyRoleColumn(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&models.Account{}, "role") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`
+			INSERT INTO account_roles (account_id, role_id)
+			SELECT accounts.id, roles.id FROM accounts JOIN roles ON roles.name = accounts.role
+			ON CONFLICT DO NOTHING
+		`).Error; err != nil {
+			return err
+		}
+
+		return tx.Migrator().DropColumn(&models.Account{}, "role")
+	})
+}
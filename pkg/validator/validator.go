@@ -1,6 +1,7 @@
 package validator
 
 import (
+	"regexp"
 	"strings"
 
 	"github.com/go-playground/validator/v10"
@@ -40,14 +41,111 @@ var validationMessages = map[string]map[string]string{
 		"required": "Phone number is required",
 		"e164":     "Phone number must be in E.164 format (e.g., +1234567890)",
 	},
+	"PhotoUrl": {
+		"url": "Photo URL must be a valid URL",
+	},
+	"Locale": {
+		"bcp47_language_tag": "Locale must be a BCP 47 language tag (e.g., en, tr-TR)",
+	},
+	"Roles": {
+		"required": "At least one role is required",
+		"min":      "At least one role is required",
+	},
+	"Name": {
+		"required":   "Name is required",
+		"min":        "Name is too short",
+		"max":        "Name is too long",
+		"identifier": "Name may only contain lowercase letters, digits, '-', '_' and ':' and must start with a letter",
+	},
+	"RedirectURIs": {
+		"required": "At least one redirect URI is required",
+		"min":      "At least one redirect URI is required",
+		"max":      "A client cannot register more than 10 redirect URIs",
+	},
+	"Scopes": {
+		"required": "At least one scope is required",
+		"min":      "At least one scope is required",
+	},
+	"GrantTypes": {
+		"unique": "Grant types must not repeat",
+		"oneof":  "Grant types must be authorization_code, refresh_token, client_credentials, urn:ietf:params:oauth:grant-type:device_code or urn:ietf:params:oauth:grant-type:token-exchange",
+	},
+	"TokenEndpointAuthMethod": {
+		"oneof": "Token endpoint auth method must be none, client_secret_basic, client_secret_post or private_key_jwt",
+	},
+	"Description": {
+		"max": "Description cannot exceed 255 characters",
+	},
+	"Role": {
+		"identifier": "Role must be a role name",
+	},
+	"VerificationStatus": {
+		"oneof": "Verification status must be pending or verified",
+	},
+	"CreatedFrom": {
+		"datetime": "created_from must be an RFC 3339 timestamp",
+	},
+	"CreatedTo": {
+		"datetime": "created_to must be an RFC 3339 timestamp",
+	},
+	"LastLoginFrom": {
+		"datetime": "last_login_from must be an RFC 3339 timestamp",
+	},
+	"LastLoginTo": {
+		"datetime": "last_login_to must be an RFC 3339 timestamp",
+	},
+	"Reason": {
+		"required": "Reason is required",
+		"max":      "Reason cannot exceed 500 characters",
+	},
+	"Until": {
+		"datetime": "until must be an RFC 3339 timestamp",
+	},
+	"Action": {
+		"max": "Action cannot exceed 100 characters",
+	},
+	"Outcome": {
+		"oneof": "Outcome must be success or failure",
+	},
+	"From": {
+		"datetime": "from must be an RFC 3339 timestamp",
+	},
+	"To": {
+		"datetime": "to must be an RFC 3339 timestamp",
+	},
+	"Search": {
+		"max": "Search cannot exceed 100 characters",
+	},
+	"Sort": {
+		"oneof": "Sort must be one of created_at, last_login_at, email, first_name or last_name, optionally prefixed with -",
+	},
+	"Page": {
+		"min": "Page must be at least 1",
+	},
+	"PageSize": {
+		"min": "Page size must be at least 1",
+		"max": "Page size cannot exceed 100",
+	},
 	"Password": {
 		"required": "Password is required",
 		"min":      "Password must be at least 8 characters long",
 	},
 }
 
+// identifierPattern matches role and permission names such as "admin" or
+// "accounts:read".
+var identifierPattern = regexp.MustCompile(`^[a-z][a-z0-9_:-]*$`)
+
+func validateIdentifier(fl validator.FieldLevel) bool {
+	return identifierPattern.MatchString(fl.Field().String())
+}
+
 func ValidateStruct(s interface{}) error {
 	validate := validator.New()
+	if err := validate.RegisterValidation("identifier", validateIdentifier); err != nil {
+		return err
+	}
+
 	err := validate.Struct(s)
 	if err == nil {
 		return nil
@@ -7,10 +7,16 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/notification"
+	"github.com/ssoydabas/auth-service/internal/purge"
 	"github.com/ssoydabas/auth-service/internal/repository"
 	"github.com/ssoydabas/auth-service/internal/service"
+	"github.com/ssoydabas/auth-service/internal/storage"
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/ssoydabas/auth-service/pkg/clientinfo"
 	"github.com/ssoydabas/auth-service/pkg/config"
 	pkgerrors "github.com/ssoydabas/auth-service/pkg/errors"
 	"github.com/ssoydabas/auth-service/pkg/postgres"
@@ -65,7 +71,10 @@ func (suite *AccountIntegrationTestSuite) SetupSuite() {
 	suite.db = db
 
 	accountRepo := repository.NewAccountRepository(db)
-	suite.service = service.NewAccountService(accountRepo)
+	roleRepo := repository.NewRoleRepository(db)
+	templates, err := notification.NewRenderer("", cfg.NotificationBrandName)
+	suite.Require().NoError(err)
+	suite.service = service.NewAccountService(accountRepo, roleRepo, repository.NewAuditRepository(db), templates, *cfg)
 
 	suite.ctx = context.Background()
 }
@@ -94,6 +103,7 @@ func (suite *AccountIntegrationTestSuite) SetupTest() {
 		END $$;
 	`).Error
 	suite.Require().NoError(err)
+	suite.Require().NoError(postgres.SeedRoles(suite.db))
 }
 
 func (suite *AccountIntegrationTestSuite) TestCreateAndAuthenticateAccount() {
@@ -105,9 +115,8 @@ func (suite *AccountIntegrationTestSuite) TestCreateAndAuthenticateAccount() {
 		LastName:  "Doe",
 	}
 
-	token, err := suite.service.CreateAccount(suite.ctx, createReq)
+	err := suite.service.CreateAccount(suite.ctx, createReq)
 	suite.NoError(err)
-	suite.NotEmpty(token)
 
 	authReq := dto.AuthenticateAccountRequest{
 		Email:    "test@example.com",
@@ -134,18 +143,17 @@ func (suite *AccountIntegrationTestSuite) TestDuplicateEmailAndPhone() {
 		LastName:  "Doe",
 	}
 
-	token, err := suite.service.CreateAccount(suite.ctx, createReq)
+	err := suite.service.CreateAccount(suite.ctx, createReq)
 	suite.NoError(err)
-	suite.NotEmpty(token)
 
 	createReq.FirstName = "Jane"
-	_, err = suite.service.CreateAccount(suite.ctx, createReq)
+	err = suite.service.CreateAccount(suite.ctx, createReq)
 	suite.Error(err)
 	suite.IsType(pkgerrors.ConflictError(""), err)
 
 	createReq.Email = "different@example.com"
 	createReq.Phone = "+1234567890"
-	_, err = suite.service.CreateAccount(suite.ctx, createReq)
+	err = suite.service.CreateAccount(suite.ctx, createReq)
 	suite.Error(err)
 	suite.IsType(pkgerrors.ConflictError(""), err)
 }
@@ -159,9 +167,8 @@ func (suite *AccountIntegrationTestSuite) TestEmailVerificationFlow() {
 		LastName:  "Doe",
 	}
 
-	token, err := suite.service.CreateAccount(suite.ctx, createReq)
+	err := suite.service.CreateAccount(suite.ctx, createReq)
 	suite.NoError(err)
-	suite.NotEmpty(token)
 
 	account, err := suite.service.GetAccountByEmail(suite.ctx, createReq.Email)
 	suite.NoError(err)
@@ -178,6 +185,12 @@ func (suite *AccountIntegrationTestSuite) TestEmailVerificationFlow() {
 	err = suite.service.VerifyAccountEmail(suite.ctx, verifyReq)
 	suite.NoError(err)
 
+	events, _, err := repository.NewAuditRepository(suite.db).ListAuditEvents(suite.ctx, repository.ListAuditEventsParams{Action: models.AuditActionEmailVerified, Limit: 10})
+	suite.Require().NoError(err)
+	suite.Require().Len(events, 1)
+	suite.Equal(models.AuditOutcomeSuccess, events[0].Outcome)
+	suite.Equal(account.ID, *events[0].TargetID)
+
 	err = suite.service.VerifyAccountEmail(suite.ctx, verifyReq)
 	suite.Error(err)
 	suite.IsType(pkgerrors.NotFoundError(""), err)
@@ -192,17 +205,22 @@ func (suite *AccountIntegrationTestSuite) TestPasswordResetFlow() {
 		LastName:  "Doe",
 	}
 
-	token, err := suite.service.CreateAccount(suite.ctx, createReq)
+	err := suite.service.CreateAccount(suite.ctx, createReq)
 	suite.NoError(err)
-	suite.NotEmpty(token)
 
 	resetTokenReq := dto.SetResetPasswordTokenRequest{
 		Email: "test@example.com",
 		Phone: "+1234567890",
 	}
 
-	resetToken, err := suite.service.SetResetPasswordToken(suite.ctx, resetTokenReq)
+	err = suite.service.SetResetPasswordToken(suite.ctx, resetTokenReq)
 	suite.NoError(err)
+
+	// The token is only sent by email.
+	var tokens models.AccountToken
+	suite.Require().NoError(suite.db.Joins("JOIN accounts ON accounts.id = account_tokens.account_id").
+		Where("accounts.email = ?", createReq.Email).First(&tokens).Error)
+	resetToken := tokens.ResetPasswordToken
 	suite.NotEmpty(resetToken)
 
 	resetReq := dto.ResetPasswordRequest{
@@ -236,6 +254,126 @@ func (suite *AccountIntegrationTestSuite) TestPasswordResetFlow() {
 	suite.IsType(pkgerrors.NotFoundError(""), err)
 }
 
+func (suite *AccountIntegrationTestSuite) TestLookupIgnoresEmptyPhone() {
+	// Federated sign-in creates accounts without a phone number.
+	federated := models.Account{
+		Email:              "federated@example.com",
+		FirstName:          "Ada",
+		LastName:           "Lovelace",
+		VerificationStatus: "verified",
+	}
+	suite.Require().NoError(repository.NewAccountRepository(suite.db).CreateAccount(suite.ctx, federated))
+
+	err := suite.service.CreateAccount(suite.ctx, dto.CreateAccountRequest{
+		Email:     "test@example.com",
+		Password:  "password123",
+		Phone:     "+1234567890",
+		FirstName: "John",
+		LastName:  "Doe",
+	})
+	suite.Require().NoError(err)
+
+	err = suite.service.SetResetPasswordToken(suite.ctx, dto.SetResetPasswordTokenRequest{Email: "nobody@unknown.example.com"})
+	suite.NoError(err)
+	var issued int64
+	suite.Require().NoError(suite.db.Model(&models.AccountToken{}).Where("reset_password_token <> ''").Count(&issued).Error)
+	suite.Zero(issued, "no account gets a reset token for an unknown email")
+
+	_, err = suite.service.AuthenticateAccount(suite.ctx, dto.AuthenticateAccountRequest{Email: "nobody@unknown.example.com", Password: ""})
+	suite.Error(err)
+	suite.IsType(pkgerrors.NotFoundError(""), err)
+
+	token, err := suite.service.AuthenticateAccount(suite.ctx, dto.AuthenticateAccountRequest{Email: "test@example.com", Password: "password123"})
+	suite.NoError(err)
+	suite.NotEmpty(token)
+}
+
+func (suite *AccountIntegrationTestSuite) TestDeleteAndPurgeAccount() {
+	createReq := dto.CreateAccountRequest{
+		Email:     "test@example.com",
+		Password:  "password123",
+		Phone:     "+1234567890",
+		FirstName: "John",
+		LastName:  "Doe",
+	}
+
+	err := suite.service.CreateAccount(suite.ctx, createReq)
+	suite.Require().NoError(err)
+
+	account, err := suite.service.GetAccountByEmail(suite.ctx, createReq.Email)
+	suite.Require().NoError(err)
+
+	_, err = suite.service.DeleteAccount(suite.ctx, account.ID, dto.DeleteAccountRequest{Password: createReq.Password})
+	suite.Require().NoError(err)
+
+	_, err = suite.service.AuthenticateAccount(suite.ctx, dto.AuthenticateAccountRequest{Email: createReq.Email, Password: createReq.Password})
+	suite.Error(err)
+
+	// The email and phone stay reserved during the grace period.
+	err = suite.service.CreateAccount(suite.ctx, createReq)
+	suite.IsType(pkgerrors.ConflictError(""), err)
+
+	suite.Require().NoError(suite.db.Exec("UPDATE accounts SET deleted_at = NOW() - INTERVAL '400 days' WHERE id = ?", account.ID).Error)
+
+	accountRepo := repository.NewAccountRepository(suite.db)
+	store := storage.NewLocalStore(suite.T().TempDir())
+	photoService := service.NewPhotoService(accountRepo, store, config.Config{})
+	exportService := service.NewDataExportService(repository.NewDataExportRepository(suite.db), accountRepo, repository.NewAuditRepository(suite.db), repository.NewOAuthRepository(suite.db), store, nil, config.Config{})
+	purger := purge.NewPurger(accountRepo, photoService, exportService, purge.Config{GracePeriod: 24 * time.Hour, BatchSize: 10})
+	n, err := purger.PurgeOnce(suite.ctx)
+	suite.Require().NoError(err)
+	suite.Equal(1, n)
+
+	var remaining int64
+	suite.Require().NoError(suite.db.Unscoped().Model(&models.AccountPassword{}).Where("account_id = ?", account.ID).Count(&remaining).Error)
+	suite.Zero(remaining)
+
+	err = suite.service.CreateAccount(suite.ctx, createReq)
+	suite.NoError(err)
+}
+
+func (suite *AccountIntegrationTestSuite) TestAuditChain() {
+	createReq := dto.CreateAccountRequest{
+		Email:     "test@example.com",
+		Password:  "password123",
+		Phone:     "+1234567890",
+		FirstName: "John",
+		LastName:  "Doe",
+	}
+
+	err := suite.service.CreateAccount(suite.ctx, createReq)
+	suite.Require().NoError(err)
+
+	ctx := clientinfo.WithInfo(suite.ctx, clientinfo.Info{IP: "203.0.113.7", UserAgent: "integration-test"})
+	_, err = suite.service.AuthenticateAccount(ctx, dto.AuthenticateAccountRequest{Email: createReq.Email, Password: createReq.Password})
+	suite.Require().NoError(err)
+	_, err = suite.service.AuthenticateAccount(ctx, dto.AuthenticateAccountRequest{Email: createReq.Email, Password: "wrongpassword"})
+	suite.Require().Error(err)
+
+	auditRepo := repository.NewAuditRepository(suite.db)
+	page, _, err := auditRepo.ListAuditEvents(suite.ctx, repository.ListAuditEventsParams{Action: models.AuditActionAccountLogin, Limit: 10})
+	suite.Require().NoError(err)
+	suite.Require().Len(page, 2)
+	suite.Equal(models.AuditOutcomeFailure, page[0].Outcome)
+	suite.Equal(models.AuditOutcomeSuccess, page[1].Outcome)
+	suite.Equal("203.0.113.7", page[0].IP)
+	suite.Equal("integration-test", page[0].UserAgent)
+
+	auditService := service.NewAuditService(auditRepo)
+	result, err := auditService.VerifyAuditChain(suite.ctx)
+	suite.Require().NoError(err)
+	suite.True(result.Valid)
+	suite.Equal(int64(2), result.Checked)
+
+	suite.Require().NoError(suite.db.Exec("UPDATE audit_events SET outcome = 'success' WHERE id = ?", page[0].ID).Error)
+
+	result, err = auditService.VerifyAuditChain(suite.ctx)
+	suite.Require().NoError(err)
+	suite.False(result.Valid)
+	suite.Require().NotNil(result.BrokenAt)
+	suite.Equal(page[0].ID, result.BrokenAt.EventID)
+}
+
 func TestAccountIntegrationSuite(t *testing.T) {
 	suite.Run(t, new(AccountIntegrationTestSuite))
 }
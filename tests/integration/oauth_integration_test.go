@@ -0,0 +1,623 @@
+package integration
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/oauth"
+	"github.com/ssoydabas/auth-service/internal/outbox"
+	"github.com/ssoydabas/auth-service/internal/repository"
+	"github.com/ssoydabas/auth-service/internal/service"
+	"github.com/ssoydabas/auth-service/models"
+	"github.com/ssoydabas/auth-service/pkg/config"
+)
+
+func (suite *AccountIntegrationTestSuite) newOAuthService() service.OAuthService {
+	return suite.newOAuthServiceWithConfig(func(*config.Config) {})
+}
+
+func (suite *AccountIntegrationTestSuite) newOAuthServiceWithConfig(configure func(*config.Config)) service.OAuthService {
+	cfg, err := config.LoadConfig()
+	suite.Require().NoError(err)
+	cfg.SessionTTL = time.Hour
+	cfg.OAuthCodeTTL = time.Minute
+	cfg.OAuthAccessTokenTTL = time.Hour
+	cfg.OAuthRefreshTokenTTL = time.Hour
+	configure(cfg)
+
+	signingKey, err := oauth.GenerateSigningKey()
+	suite.Require().NoError(err)
+
+	return service.NewOAuthService(repository.NewOAuthRepository(suite.db), repository.NewAccountRepository(suite.db),
+		repository.NewAuditRepository(suite.db), suite.service, signingKey, *cfg)
+}
+
+func (suite *AccountIntegrationTestSuite) TestOAuthAuthorizationCodeFlow() {
+	createReq := dto.CreateAccountRequest{
+		Email:     "test@example.com",
+		Password:  "password123",
+		Phone:     "+1234567890",
+		FirstName: "John",
+		LastName:  "Doe",
+	}
+	err := suite.service.CreateAccount(suite.ctx, createReq)
+	suite.Require().NoError(err)
+
+	oauthService := suite.newOAuthService()
+	client, err := oauthService.CreateClient(suite.ctx, 0, dto.CreateOAuthClientRequest{
+		Name:         "Example SPA",
+		RedirectURIs: []string{"https://app.example.com/callback"},
+		Scopes:       []string{"profile"},
+	})
+	suite.Require().NoError(err)
+
+	verifier := "dBjftJeZ4CVP-mJ92K1s-DhgS5bE8f7eGW7gNZM0rUY"
+	authorizeReq := dto.AuthorizeRequest{
+		ResponseType:        oauth.ResponseTypeCode,
+		ClientID:            client.ClientID,
+		RedirectURI:         "https://app.example.com/callback",
+		State:               "state-1",
+		CodeChallenge:       oauth.S256Challenge(verifier),
+		CodeChallengeMethod: oauth.MethodS256,
+	}
+
+	result, err := oauthService.Authorize(suite.ctx, authorizeReq, "")
+	suite.Require().NoError(err)
+	suite.True(result.LoginRequired)
+
+	result, err = oauthService.Login(suite.ctx, authorizeReq, dto.AuthenticateAccountRequest{Email: createReq.Email, Password: createReq.Password})
+	suite.Require().NoError(err)
+	suite.Require().NotEmpty(result.SessionToken)
+	suite.Require().True(result.ConsentRequired)
+	sessionToken := result.SessionToken
+
+	result, err = oauthService.Consent(suite.ctx, authorizeReq, sessionToken, true)
+	suite.Require().NoError(err)
+	redirect, err := url.Parse(result.RedirectURL)
+	suite.Require().NoError(err)
+	code := redirect.Query().Get("code")
+
+	tokenReq := dto.TokenRequest{
+		GrantType:    oauth.GrantAuthorizationCode,
+		ClientID:     client.ClientID,
+		Code:         code,
+		RedirectURI:  authorizeReq.RedirectURI,
+		CodeVerifier: verifier,
+	}
+	tokens, err := oauthService.Token(suite.ctx, tokenReq)
+	suite.Require().NoError(err)
+	suite.Equal("profile", tokens.Scope)
+
+	account, err := suite.service.GetAccountByToken(suite.ctx, tokens.AccessToken)
+	suite.Require().NoError(err)
+	suite.Equal(createReq.Email, account.Email)
+
+	// The code is single use, and redeeming it again revokes its tokens.
+	_, err = oauthService.Token(suite.ctx, tokenReq)
+	suite.Require().Error(err)
+	_, err = oauthService.Token(suite.ctx, dto.TokenRequest{GrantType: oauth.GrantRefreshToken, ClientID: client.ClientID, RefreshToken: tokens.RefreshToken})
+	suite.Require().Error(err)
+
+	// A session skips the sign-in page and the grant the consent page; refresh
+	// tokens rotate and a reused one revokes its family.
+	result, err = oauthService.Authorize(suite.ctx, authorizeReq, sessionToken)
+	suite.Require().NoError(err)
+	redirect, err = url.Parse(result.RedirectURL)
+	suite.Require().NoError(err)
+	tokenReq.Code = redirect.Query().Get("code")
+	tokens, err = oauthService.Token(suite.ctx, tokenReq)
+	suite.Require().NoError(err)
+
+	refreshReq := dto.TokenRequest{GrantType: oauth.GrantRefreshToken, ClientID: client.ClientID, RefreshToken: tokens.RefreshToken}
+	rotated, err := oauthService.Token(suite.ctx, refreshReq)
+	suite.Require().NoError(err)
+	suite.NotEqual(tokens.RefreshToken, rotated.RefreshToken)
+
+	_, err = oauthService.Token(suite.ctx, refreshReq)
+	suite.Require().Error(err)
+	_, err = oauthService.Token(suite.ctx, dto.TokenRequest{GrantType: oauth.GrantRefreshToken, ClientID: client.ClientID, RefreshToken: rotated.RefreshToken})
+	suite.Require().Error(err, "reusing a rotated token revokes the whole family")
+
+	var reused int64
+	suite.Require().NoError(suite.db.Model(&models.AuditEvent{}).Where("action = ?", models.AuditActionOAuthTokenReused).Count(&reused).Error)
+	suite.Equal(int64(2), reused)
+}
+
+func (suite *AccountIntegrationTestSuite) TestOpenIDConnectFlow() {
+	createReq := dto.CreateAccountRequest{
+		Email:     "test@example.com",
+		Password:  "password123",
+		Phone:     "+1234567890",
+		FirstName: "John",
+		LastName:  "Doe",
+	}
+	err := suite.service.CreateAccount(suite.ctx, createReq)
+	suite.Require().NoError(err)
+
+	oauthService := suite.newOAuthService()
+	client, err := oauthService.CreateClient(suite.ctx, 0, dto.CreateOAuthClientRequest{
+		Name:         "Example SPA",
+		RedirectURIs: []string{"https://app.example.com/callback"},
+		Scopes:       []string{"openid", "profile", "email"},
+	})
+	suite.Require().NoError(err)
+
+	verifier := "dBjftJeZ4CVP-mJ92K1s-DhgS5bE8f7eGW7gNZM0rUY"
+	authorizeReq := dto.AuthorizeRequest{
+		ResponseType:        oauth.ResponseTypeCode,
+		ClientID:            client.ClientID,
+		RedirectURI:         "https://app.example.com/callback",
+		Scope:               "openid email",
+		Nonce:               "nonce-1",
+		CodeChallenge:       oauth.S256Challenge(verifier),
+		CodeChallengeMethod: oauth.MethodS256,
+	}
+
+	result, err := oauthService.Login(suite.ctx, authorizeReq, dto.AuthenticateAccountRequest{Email: createReq.Email, Password: createReq.Password})
+	suite.Require().NoError(err)
+	result, err = oauthService.Consent(suite.ctx, authorizeReq, result.SessionToken, true)
+	suite.Require().NoError(err)
+	redirect, err := url.Parse(result.RedirectURL)
+	suite.Require().NoError(err)
+
+	tokens, err := oauthService.Token(suite.ctx, dto.TokenRequest{
+		GrantType:    oauth.GrantAuthorizationCode,
+		ClientID:     client.ClientID,
+		Code:         redirect.Query().Get("code"),
+		RedirectURI:  authorizeReq.RedirectURI,
+		CodeVerifier: verifier,
+	})
+	suite.Require().NoError(err)
+	suite.Require().NotEmpty(tokens.IDToken)
+
+	// The ID token verifies against the published keys and carries what the
+	// authorization request and the sign-in recorded.
+	jwks := oauthService.JWKS()
+	suite.Require().Len(jwks.Keys, 1)
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(tokens.IDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		suite.Equal(jwks.Keys[0].KeyID, token.Header["kid"])
+		return publicKeyFromJWK(jwks.Keys[0])
+	})
+	suite.Require().NoError(err)
+	suite.Equal("nonce-1", claims["nonce"])
+	suite.Equal(client.ClientID, claims["aud"])
+	suite.Equal([]interface{}{oauth.AMRPassword}, claims["amr"])
+	suite.NotEmpty(claims["auth_time"])
+
+	info, err := oauthService.UserInfo(suite.ctx, tokens.AccessToken)
+	suite.Require().NoError(err)
+	suite.Equal(createReq.Email, info["email"])
+	suite.NotContains(info, "given_name", "profile was not requested")
+}
+
+func (suite *AccountIntegrationTestSuite) TestOAuthGrants() {
+	createReq := dto.CreateAccountRequest{
+		Email:     "test@example.com",
+		Password:  "password123",
+		Phone:     "+1234567890",
+		FirstName: "John",
+		LastName:  "Doe",
+	}
+	err := suite.service.CreateAccount(suite.ctx, createReq)
+	suite.Require().NoError(err)
+	account, err := suite.service.GetAccountByEmail(suite.ctx, createReq.Email)
+	suite.Require().NoError(err)
+
+	oauthService := suite.newOAuthService()
+	client, err := oauthService.CreateClient(suite.ctx, 0, dto.CreateOAuthClientRequest{
+		Name:         "Example SPA",
+		RedirectURIs: []string{"https://app.example.com/callback"},
+		Scopes:       []string{"profile", "email"},
+	})
+	suite.Require().NoError(err)
+
+	verifier := "dBjftJeZ4CVP-mJ92K1s-DhgS5bE8f7eGW7gNZM0rUY"
+	authorizeReq := dto.AuthorizeRequest{
+		ResponseType:        oauth.ResponseTypeCode,
+		ClientID:            client.ClientID,
+		RedirectURI:         "https://app.example.com/callback",
+		Scope:               "profile",
+		CodeChallenge:       oauth.S256Challenge(verifier),
+		CodeChallengeMethod: oauth.MethodS256,
+	}
+
+	signIn, err := oauthService.Login(suite.ctx, authorizeReq, dto.AuthenticateAccountRequest{Email: createReq.Email, Password: createReq.Password})
+	suite.Require().NoError(err)
+	suite.Require().True(signIn.ConsentRequired)
+	result, err := oauthService.Consent(suite.ctx, authorizeReq, signIn.SessionToken, true)
+	suite.Require().NoError(err)
+	redirect, err := url.Parse(result.RedirectURL)
+	suite.Require().NoError(err)
+	tokens, err := oauthService.Token(suite.ctx, dto.TokenRequest{
+		GrantType:    oauth.GrantAuthorizationCode,
+		ClientID:     client.ClientID,
+		Code:         redirect.Query().Get("code"),
+		RedirectURI:  authorizeReq.RedirectURI,
+		CodeVerifier: verifier,
+	})
+	suite.Require().NoError(err)
+
+	// A scope outside the grant needs consent again, for that scope only.
+	authorizeReq.Scope = "profile email"
+	result, err = oauthService.Authorize(suite.ctx, authorizeReq, signIn.SessionToken)
+	suite.Require().NoError(err)
+	suite.True(result.ConsentRequired)
+	suite.Equal([]string{"email"}, result.Scopes)
+
+	grants, err := oauthService.ListGrants(suite.ctx, account.ID)
+	suite.Require().NoError(err)
+	suite.Require().Len(grants, 1)
+	suite.Equal("Example SPA", grants[0].ClientName)
+	suite.Equal([]string{"profile"}, grants[0].Scopes)
+
+	// Revoking the grant kills the client's refresh tokens and brings the
+	// consent page back.
+	suite.Require().NoError(oauthService.RevokeGrant(suite.ctx, account.ID, client.ClientID))
+	_, err = oauthService.Token(suite.ctx, dto.TokenRequest{GrantType: oauth.GrantRefreshToken, ClientID: client.ClientID, RefreshToken: tokens.RefreshToken})
+	suite.Equal(oauth.ErrorInvalidGrant, err.(*oauth.Error).Code)
+
+	authorizeReq.Scope = "profile"
+	result, err = oauthService.Authorize(suite.ctx, authorizeReq, signIn.SessionToken)
+	suite.Require().NoError(err)
+	suite.True(result.ConsentRequired)
+
+	grants, err = oauthService.ListGrants(suite.ctx, account.ID)
+	suite.Require().NoError(err)
+	suite.Empty(grants)
+	suite.Require().Error(oauthService.RevokeGrant(suite.ctx, account.ID, client.ClientID))
+}
+
+func publicKeyFromJWK(key oauth.JWK) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(key.Modulus)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(key.Exponent)
+	if err != nil {
+		return nil, err
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+}
+
+func (suite *AccountIntegrationTestSuite) TestOAuthClientCredentials() {
+	oauthService := suite.newOAuthService()
+	client, err := oauthService.CreateClient(suite.ctx, 0, dto.CreateOAuthClientRequest{
+		Name:                    "Billing job",
+		Scopes:                  []string{"invoices:read", "invoices:write"},
+		GrantTypes:              []string{oauth.GrantClientCredentials},
+		TokenEndpointAuthMethod: oauth.AuthMethodClientSecretPost,
+	})
+	suite.Require().NoError(err)
+	suite.Require().NotEmpty(client.ClientSecret)
+
+	tokenReq := dto.TokenRequest{
+		GrantType:    oauth.GrantClientCredentials,
+		ClientID:     client.ClientID,
+		ClientSecret: client.ClientSecret,
+		Scope:        "invoices:read",
+	}
+	tokens, err := oauthService.Token(suite.ctx, tokenReq)
+	suite.Require().NoError(err)
+	suite.Equal("invoices:read", tokens.Scope)
+
+	// The token belongs to the client, not to an account.
+	_, err = suite.service.GetAccountByToken(suite.ctx, tokens.AccessToken)
+	suite.Require().Error(err)
+
+	// After a rotation both secrets work until the grace period ends.
+	rotated, err := oauthService.RotateClientSecret(suite.ctx, 0, client.ClientID)
+	suite.Require().NoError(err)
+	_, err = oauthService.Token(suite.ctx, tokenReq)
+	suite.Require().NoError(err)
+	tokenReq.ClientSecret = rotated.ClientSecret
+	_, err = oauthService.Token(suite.ctx, tokenReq)
+	suite.Require().NoError(err)
+
+	_, err = oauthService.DisableClient(suite.ctx, 0, client.ClientID)
+	suite.Require().NoError(err)
+	_, err = oauthService.Token(suite.ctx, tokenReq)
+	suite.Require().Error(err)
+}
+
+func (suite *AccountIntegrationTestSuite) TestOAuthDeviceAuthorization() {
+	err := suite.service.CreateAccount(suite.ctx, dto.CreateAccountRequest{
+		Email:     "test@example.com",
+		Password:  "password123",
+		Phone:     "+1234567890",
+		FirstName: "John",
+		LastName:  "Doe",
+	})
+	suite.Require().NoError(err)
+
+	oauthService := suite.newOAuthService()
+	client, err := oauthService.CreateClient(suite.ctx, 0, dto.CreateOAuthClientRequest{
+		Name:       "TV app",
+		Scopes:     []string{"openid", "profile"},
+		GrantTypes: []string{oauth.GrantDeviceCode, oauth.GrantRefreshToken},
+	})
+	suite.Require().NoError(err)
+
+	device, err := oauthService.DeviceAuthorization(suite.ctx, dto.DeviceAuthorizationRequest{ClientID: client.ClientID})
+	suite.Require().NoError(err)
+
+	pollReq := dto.TokenRequest{
+		GrantType:  oauth.GrantDeviceCode,
+		ClientID:   client.ClientID,
+		DeviceCode: device.DeviceCode,
+	}
+	_, err = oauthService.Token(suite.ctx, pollReq)
+	suite.Equal(oauth.ErrorAuthorizationPending, err.(*oauth.Error).Code)
+	_, err = oauthService.Token(suite.ctx, pollReq)
+	suite.Equal(oauth.ErrorSlowDown, err.(*oauth.Error).Code)
+
+	signIn, err := oauthService.SignIn(suite.ctx, dto.AuthenticateAccountRequest{Email: "test@example.com", Password: "password123"})
+	suite.Require().NoError(err)
+
+	verification, err := oauthService.VerifyDeviceCode(suite.ctx, signIn.SessionToken, device.UserCode)
+	suite.Require().NoError(err)
+	suite.Equal("TV app", verification.ClientName)
+
+	_, err = oauthService.DecideDeviceCode(suite.ctx, signIn.SessionToken, device.UserCode, true)
+	suite.Require().NoError(err)
+	_, err = oauthService.DecideDeviceCode(suite.ctx, signIn.SessionToken, device.UserCode, false)
+	suite.Require().Error(err, "a decided code cannot be decided again")
+
+	// Pretend the device waited for its interval.
+	suite.Require().NoError(suite.db.Model(&models.OAuthDeviceCode{}).
+		Where("client_id = ?", client.ClientID).
+		Update("last_polled_at", nil).Error)
+
+	tokens, err := oauthService.Token(suite.ctx, pollReq)
+	suite.Require().NoError(err)
+	suite.NotEmpty(tokens.RefreshToken)
+	suite.NotEmpty(tokens.IDToken)
+
+	account, err := suite.service.GetAccountByToken(suite.ctx, tokens.AccessToken)
+	suite.Require().NoError(err)
+	suite.Equal("test@example.com", account.Email)
+
+	suite.Require().NoError(suite.db.Model(&models.OAuthDeviceCode{}).
+		Where("client_id = ?", client.ClientID).
+		Update("last_polled_at", nil).Error)
+	_, err = oauthService.Token(suite.ctx, pollReq)
+	suite.Equal(oauth.ErrorInvalidGrant, err.(*oauth.Error).Code, "a device code is single-use")
+}
+
+func (suite *AccountIntegrationTestSuite) TestOAuthTokenExchange() {
+	createReq := dto.CreateAccountRequest{
+		Email:     "test@example.com",
+		Password:  "password123",
+		Phone:     "+1234567890",
+		FirstName: "John",
+		LastName:  "Doe",
+	}
+	err := suite.service.CreateAccount(suite.ctx, createReq)
+	suite.Require().NoError(err)
+	subjectToken, err := suite.service.AuthenticateAccount(suite.ctx, dto.AuthenticateAccountRequest{Email: createReq.Email, Password: createReq.Password})
+	suite.Require().NoError(err)
+
+	client, err := suite.newOAuthService().CreateClient(suite.ctx, 0, dto.CreateOAuthClientRequest{
+		Name:                    "Orders service",
+		Scopes:                  []string{"orders:read", "inventory:read"},
+		GrantTypes:              []string{oauth.GrantTokenExchange},
+		TokenEndpointAuthMethod: oauth.AuthMethodClientSecretPost,
+	})
+	suite.Require().NoError(err)
+
+	oauthService := suite.newOAuthServiceWithConfig(func(cfg *config.Config) {
+		cfg.OAuthTokenExchangePolicy = map[string]string{client.ClientID: "inventory-api"}
+	})
+	exchangeReq := dto.TokenRequest{
+		GrantType:        oauth.GrantTokenExchange,
+		ClientID:         client.ClientID,
+		ClientSecret:     client.ClientSecret,
+		SubjectToken:     subjectToken,
+		SubjectTokenType: oauth.TokenTypeAccessToken,
+		Audience:         []string{"inventory-api"},
+		Scope:            "inventory:read",
+	}
+	tokens, err := oauthService.Token(suite.ctx, exchangeReq)
+	suite.Require().NoError(err)
+	suite.Equal(oauth.TokenTypeAccessToken, tokens.IssuedTokenType)
+	suite.Equal("inventory:read", tokens.Scope)
+
+	// The exchanged token is meant for the inventory API, not for this service.
+	_, err = suite.service.GetAccountByToken(suite.ctx, tokens.AccessToken)
+	suite.Require().Error(err)
+
+	exchangeReq.Audience = []string{"billing-api"}
+	_, err = oauthService.Token(suite.ctx, exchangeReq)
+	suite.Equal(oauth.ErrorInvalidTarget, err.(*oauth.Error).Code)
+
+	var events int64
+	suite.Require().NoError(suite.db.Model(&models.AuditEvent{}).
+		Where("action = ?", models.AuditActionOAuthTokenExchanged).
+		Count(&events).Error)
+	suite.Equal(int64(1), events)
+}
+
+func (suite *AccountIntegrationTestSuite) TestOIDCLogout() {
+	createReq := dto.CreateAccountRequest{
+		Email:     "test@example.com",
+		Password:  "password123",
+		Phone:     "+1234567890",
+		FirstName: "John",
+		LastName:  "Doe",
+	}
+	err := suite.service.CreateAccount(suite.ctx, createReq)
+	suite.Require().NoError(err)
+
+	// The client's back-channel logout endpoint fails once, then accepts.
+	var logoutTokens []string
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logoutTokens = append(logoutTokens, r.FormValue("logout_token"))
+		if len(logoutTokens) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer receiver.Close()
+
+	oauthService := suite.newOAuthService()
+	client, err := oauthService.CreateClient(suite.ctx, 0, dto.CreateOAuthClientRequest{
+		Name:                   "Example SPA",
+		RedirectURIs:           []string{"https://app.example.com/callback"},
+		PostLogoutRedirectURIs: []string{"https://app.example.com/signed-out"},
+		BackchannelLogoutURI:   receiver.URL + "/logout",
+		Scopes:                 []string{"openid", "profile"},
+	})
+	suite.Require().NoError(err)
+
+	verifier := "dBjftJeZ4CVP-mJ92K1s-DhgS5bE8f7eGW7gNZM0rUY"
+	authorizeReq := dto.AuthorizeRequest{
+		ResponseType:        oauth.ResponseTypeCode,
+		ClientID:            client.ClientID,
+		RedirectURI:         "https://app.example.com/callback",
+		Scope:               "openid profile",
+		CodeChallenge:       oauth.S256Challenge(verifier),
+		CodeChallengeMethod: oauth.MethodS256,
+	}
+	signIn, err := oauthService.Login(suite.ctx, authorizeReq, dto.AuthenticateAccountRequest{Email: createReq.Email, Password: createReq.Password})
+	suite.Require().NoError(err)
+	result, err := oauthService.Consent(suite.ctx, authorizeReq, signIn.SessionToken, true)
+	suite.Require().NoError(err)
+	redirect, err := url.Parse(result.RedirectURL)
+	suite.Require().NoError(err)
+	tokens, err := oauthService.Token(suite.ctx, dto.TokenRequest{
+		GrantType:    oauth.GrantAuthorizationCode,
+		ClientID:     client.ClientID,
+		Code:         redirect.Query().Get("code"),
+		RedirectURI:  authorizeReq.RedirectURI,
+		CodeVerifier: verifier,
+	})
+	suite.Require().NoError(err)
+
+	logout, err := oauthService.EndSession(suite.ctx, dto.EndSessionRequest{
+		IDTokenHint:           tokens.IDToken,
+		PostLogoutRedirectURI: "https://app.example.com/signed-out",
+		State:                 "bye",
+	}, signIn.SessionToken, false)
+	suite.Require().NoError(err)
+	suite.False(logout.ConfirmationRequired)
+	suite.Equal("https://app.example.com/signed-out?state=bye", logout.RedirectURL)
+
+	// The session and its refresh tokens are gone.
+	result, err = oauthService.Authorize(suite.ctx, authorizeReq, signIn.SessionToken)
+	suite.Require().NoError(err)
+	suite.True(result.LoginRequired)
+	_, err = oauthService.Token(suite.ctx, dto.TokenRequest{GrantType: oauth.GrantRefreshToken, ClientID: client.ClientID, RefreshToken: tokens.RefreshToken})
+	suite.Equal(oauth.ErrorInvalidGrant, err.(*oauth.Error).Code)
+
+	// The logout token is delivered through the outbox, which retries the
+	// failed first attempt.
+	signingKey, err := oauth.GenerateSigningKey()
+	suite.Require().NoError(err)
+	dispatcher := suite.newDispatcher(3)
+	dispatcher.Register(outbox.TopicBackchannelLogout, outbox.BackchannelLogoutHandler(signingKey, "https://auth.example.com"))
+
+	_, err = dispatcher.DispatchOnce(suite.ctx)
+	suite.Require().NoError(err)
+	time.Sleep(10 * time.Millisecond)
+	_, err = dispatcher.DispatchOnce(suite.ctx)
+	suite.Require().NoError(err)
+
+	suite.Require().Len(logoutTokens, 2)
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(logoutTokens[1], claims, func(*jwt.Token) (interface{}, error) {
+		return signingKey.PublicKey(), nil
+	}, jwt.WithAudience(client.ClientID))
+	suite.Require().NoError(err)
+	idClaims := jwt.MapClaims{}
+	_, _, err = jwt.NewParser().ParseUnverified(tokens.IDToken, idClaims)
+	suite.Require().NoError(err)
+	suite.Equal(idClaims["sid"], claims["sid"])
+	suite.Equal(idClaims["sub"], claims["sub"])
+
+	var message models.OutboxMessage
+	suite.Require().NoError(suite.db.Where("topic = ?", outbox.TopicBackchannelLogout).First(&message).Error)
+	suite.Equal(models.OutboxStatusDelivered, message.Status)
+	suite.Equal(1, message.Attempts)
+}
+
+func (suite *AccountIntegrationTestSuite) TestOAuthDynamicClientRegistration() {
+	oauthService := suite.newOAuthService()
+	initialToken, err := oauthService.IssueInitialAccessToken(suite.ctx, 0, dto.CreateInitialAccessTokenRequest{Description: "Reporting team"})
+	suite.Require().NoError(err)
+
+	registration := dto.ClientRegistrationRequest{
+		ClientName: "Reporting job",
+		GrantTypes: []string{oauth.GrantClientCredentials},
+		Scope:      "profile",
+	}
+	client, err := oauthService.RegisterClient(suite.ctx, initialToken.Token, registration)
+	suite.Require().NoError(err)
+	suite.Require().NotEmpty(client.ClientSecret)
+	suite.Require().NotEmpty(client.RegistrationAccessToken)
+
+	// Each initial access token registers one client.
+	_, err = oauthService.RegisterClient(suite.ctx, initialToken.Token, registration)
+	suite.Equal(oauth.ErrorInvalidToken, err.(*oauth.Error).Code)
+
+	_, err = oauthService.Token(suite.ctx, dto.TokenRequest{
+		GrantType:    oauth.GrantClientCredentials,
+		ClientID:     client.ClientID,
+		ClientSecret: client.ClientSecret,
+		BasicAuth:    true,
+	})
+	suite.Require().NoError(err)
+
+	read, err := oauthService.GetClientRegistration(suite.ctx, client.ClientID, client.RegistrationAccessToken)
+	suite.Require().NoError(err)
+	suite.Equal("Reporting job", read.ClientName)
+	suite.Equal("profile", read.Scope)
+
+	// Switching to private_key_jwt with keys published at a JWKS URI drops
+	// the secret.
+	clientKey, err := oauth.GenerateSigningKey()
+	suite.Require().NoError(err)
+	keyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		suite.NoError(json.NewEncoder(w).Encode(clientKey.JWKS()))
+	}))
+	defer keyServer.Close()
+
+	registration.ClientID = client.ClientID
+	registration.TokenEndpointAuthMethod = oauth.AuthMethodPrivateKeyJWT
+	registration.JWKSURI = keyServer.URL + "/jwks"
+	updated, err := oauthService.UpdateClientRegistration(suite.ctx, client.ClientID, client.RegistrationAccessToken, registration)
+	suite.Require().NoError(err)
+	suite.Equal(keyServer.URL+"/jwks", updated.JWKSURI)
+
+	assertion, err := clientKey.Sign(jwt.MapClaims{
+		"iss": client.ClientID,
+		"sub": client.ClientID,
+		"aud": oauthService.Discovery().TokenEndpoint,
+		"jti": "assertion-1",
+		"exp": time.Now().Add(time.Minute).Unix(),
+	})
+	suite.Require().NoError(err)
+	_, err = oauthService.Token(suite.ctx, dto.TokenRequest{
+		GrantType:           oauth.GrantClientCredentials,
+		ClientAssertionType: oauth.ClientAssertionTypeJWTBearer,
+		ClientAssertion:     assertion,
+	})
+	suite.Require().NoError(err)
+	_, err = oauthService.Token(suite.ctx, dto.TokenRequest{
+		GrantType:    oauth.GrantClientCredentials,
+		ClientID:     client.ClientID,
+		ClientSecret: client.ClientSecret,
+		BasicAuth:    true,
+	})
+	suite.Equal(oauth.ErrorInvalidClient, err.(*oauth.Error).Code)
+
+	suite.Require().NoError(oauthService.DeleteClientRegistration(suite.ctx, client.ClientID, client.RegistrationAccessToken))
+	_, err = oauthService.GetClientRegistration(suite.ctx, client.ClientID, client.RegistrationAccessToken)
+	suite.Equal(oauth.ErrorInvalidToken, err.(*oauth.Error).Code)
+}
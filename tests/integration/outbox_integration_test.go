@@ -0,0 +1,125 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ssoydabas/auth-service/internal/dto"
+	"github.com/ssoydabas/auth-service/internal/notification"
+	"github.com/ssoydabas/auth-service/internal/outbox"
+	"github.com/ssoydabas/auth-service/internal/repository"
+	"github.com/ssoydabas/auth-service/models"
+)
+
+func (suite *AccountIntegrationTestSuite) newDispatcher(maxAttempts int) *outbox.Dispatcher {
+	return outbox.NewDispatcher(repository.NewOutboxRepository(suite.db), outbox.Config{
+		PollInterval:   time.Second,
+		BatchSize:      10,
+		MaxAttempts:    maxAttempts,
+		RetryBaseDelay: time.Millisecond,
+		RetryMaxDelay:  time.Millisecond,
+		ClaimLease:     time.Minute,
+	})
+}
+
+func (suite *AccountIntegrationTestSuite) TestOutboxDeliversVerificationEmail() {
+	err := suite.service.CreateAccount(suite.ctx, dto.CreateAccountRequest{
+		Email:     "outbox@example.com",
+		Password:  "password123",
+		Phone:     "+1234567891",
+		FirstName: "John",
+		LastName:  "Doe",
+	})
+	suite.Require().NoError(err)
+
+	var pending []models.OutboxMessage
+	suite.Require().NoError(suite.db.Where("status = ?", models.OutboxStatusPending).Find(&pending).Error)
+	suite.Require().Len(pending, 1)
+	suite.Equal(outbox.TopicNotification, pending[0].Topic)
+
+	notifier := notification.NewMemoryNotifier()
+	dispatcher := suite.newDispatcher(3)
+	dispatcher.Register(outbox.TopicNotification, outbox.NotificationHandler(notifier))
+
+	n, err := dispatcher.DispatchOnce(suite.ctx)
+	suite.Require().NoError(err)
+	suite.Equal(1, n)
+
+	msg, ok := notifier.Last("outbox@example.com")
+	suite.Require().True(ok)
+	suite.Equal(notification.KindEmailVerification, msg.Kind)
+	var tokens models.AccountToken
+	suite.Require().NoError(suite.db.Joins("JOIN accounts ON accounts.id = account_tokens.account_id").
+		Where("accounts.email = ?", "outbox@example.com").First(&tokens).Error)
+	suite.Contains(msg.Text, tokens.EmailVerificationToken)
+
+	var delivered models.OutboxMessage
+	suite.Require().NoError(suite.db.First(&delivered, pending[0].ID).Error)
+	suite.Equal(models.OutboxStatusDelivered, delivered.Status)
+	suite.NotNil(delivered.DeliveredAt)
+
+	n, err = dispatcher.DispatchOnce(suite.ctx)
+	suite.Require().NoError(err)
+	suite.Zero(n)
+}
+
+func (suite *AccountIntegrationTestSuite) TestOutboxRollsBackWithAccountChange() {
+	createReq := dto.CreateAccountRequest{
+		Email:     "rollback@example.com",
+		Password:  "password123",
+		Phone:     "+1234567892",
+		FirstName: "John",
+		LastName:  "Doe",
+	}
+	err := suite.service.CreateAccount(suite.ctx, createReq)
+	suite.Require().NoError(err)
+
+	// Violates the unique email index, so neither the account nor its
+	// outbox row may be written.
+	repo := repository.NewAccountRepository(suite.db)
+	message, err := outbox.NewNotificationMessage(notification.Message{To: createReq.Email})
+	suite.Require().NoError(err)
+	err = repo.CreateAccount(suite.ctx, models.Account{
+		FirstName:          "Jane",
+		LastName:           "Doe",
+		Email:              createReq.Email,
+		Phone:              "+1234567893",
+		VerificationStatus: "pending",
+	}, message)
+	suite.Require().Error(err)
+
+	var count int64
+	suite.Require().NoError(suite.db.Model(&models.OutboxMessage{}).Count(&count).Error)
+	suite.Equal(int64(1), count)
+}
+
+func (suite *AccountIntegrationTestSuite) TestOutboxRetriesAndDeadLetters() {
+	message, err := outbox.NewMessage("test.topic", map[string]string{"hello": "world"})
+	suite.Require().NoError(err)
+	message.Status = models.OutboxStatusPending
+	message.AvailableAt = time.Now()
+	suite.Require().NoError(suite.db.Create(&message).Error)
+
+	dispatcher := suite.newDispatcher(2)
+	dispatcher.Register("test.topic", func(ctx context.Context, payload []byte) error {
+		return errors.New("receiver unavailable")
+	})
+
+	_, err = dispatcher.DispatchOnce(suite.ctx)
+	suite.Require().NoError(err)
+
+	var stored models.OutboxMessage
+	suite.Require().NoError(suite.db.First(&stored, message.ID).Error)
+	suite.Equal(models.OutboxStatusPending, stored.Status)
+	suite.Equal(1, stored.Attempts)
+	suite.Equal("receiver unavailable", stored.LastError)
+
+	time.Sleep(5 * time.Millisecond)
+	_, err = dispatcher.DispatchOnce(suite.ctx)
+	suite.Require().NoError(err)
+
+	suite.Require().NoError(suite.db.First(&stored, message.ID).Error)
+	suite.Equal(models.OutboxStatusDead, stored.Status)
+	suite.Equal(2, stored.Attempts)
+}